@@ -0,0 +1,319 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An ApplyFunc is invoked by Apply for each node, before and after its
+// children are traversed. See Apply for the result.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses the syntax tree recursively starting with root, and calls
+// pre and post for each node, like Inspect. The nil children are not
+// traversed. The functions may be nil.
+//
+// If pre returns false, the children of the node and post are skipped. If
+// post returns false, the traversal is terminated and Apply returns
+// immediately.
+//
+// The node can be modified by Cursor.Replace, Delete, InsertBefore, and
+// InsertAfter of the current cursor in pre and post. Apply returns the root
+// which may be replaced. The replaced node is traversed instead of the
+// original one, but the inserted nodes are not traversed.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	parent := &struct{ Node }{root}
+	defer func() {
+		if r := recover(); r != nil && r != errAbort {
+			panic(r)
+		}
+		result = parent.Node
+	}()
+	a := &application{pre: pre, post: post}
+	a.apply(parent, "Node", nil, root)
+	return parent.Node
+}
+
+var errAbort = new(int) // a unique sentinel to terminate the traversal
+
+// A Cursor describes a node encountered during Apply. The parent and the
+// field holding the node are available to modify the tree in place.
+type Cursor struct {
+	parent Node
+	name   string
+	iter   *iterator // valid if the node is an element of slice
+	node   Node
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current node.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the parent field which contains the current node,
+// such as "Args" of Call. The name of the root node is "Node".
+func (c *Cursor) Name() string { return c.name }
+
+// Index returns the index of the current node in the slice of the parent
+// field, or -1 if the field is not a slice.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// field returns the parent field which contains the current node.
+func (c *Cursor) field() reflect.Value {
+	return reflect.Indirect(reflect.ValueOf(c.parent)).FieldByName(c.name)
+}
+
+// Replace replaces the current node with n. It panics if n cannot be
+// assigned to the field, such as Expr to *Stmts.
+func (c *Cursor) Replace(n Node) {
+	v := c.field()
+	if c.iter != nil {
+		v = v.Index(c.iter.index)
+	}
+	v.Set(nodeValue(n, v.Type()))
+	c.node = n
+}
+
+// Delete deletes the current node from the slice of the parent. It panics
+// if the current node is not an element of slice.
+func (c *Cursor) Delete() {
+	i := c.Index()
+	if i < 0 {
+		panic("ast: Delete node not contained in slice")
+	}
+	v := c.field()
+	l := v.Len()
+	reflect.Copy(v.Slice(i, l), v.Slice(i+1, l))
+	v.Index(l - 1).Set(reflect.Zero(v.Type().Elem()))
+	v.SetLen(l - 1)
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the current node in the slice of the parent.
+// It panics if the current node is not an element of slice.
+func (c *Cursor) InsertAfter(n Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("ast: InsertAfter node not contained in slice")
+	}
+	c.insert(i+1, n)
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current node in the slice of the parent.
+// It panics if the current node is not an element of slice.
+func (c *Cursor) InsertBefore(n Node) {
+	i := c.Index()
+	if i < 0 {
+		panic("ast: InsertBefore node not contained in slice")
+	}
+	c.insert(i, n)
+	c.iter.index++
+}
+
+func (c *Cursor) insert(i int, n Node) {
+	v := c.field()
+	elem := nodeValue(n, v.Type().Elem())
+	v.Set(reflect.Append(v, elem))
+	l := v.Len()
+	reflect.Copy(v.Slice(i+1, l), v.Slice(i, l))
+	v.Index(i).Set(elem)
+}
+
+// nodeValue returns n as a value of type t.
+func nodeValue(n Node, t reflect.Type) reflect.Value {
+	if n == nil {
+		return reflect.Zero(t)
+	}
+	v := reflect.ValueOf(n)
+	if !v.Type().AssignableTo(t) {
+		panic(fmt.Sprintf("ast: cannot assign %T to %v", n, t))
+	}
+	return v
+}
+
+// iterator is the position of the current node in the slice.
+type iterator struct {
+	index, step int
+}
+
+type application struct {
+	pre, post ApplyFunc
+	cursor    Cursor
+	iter      iterator
+}
+
+func (a *application) apply(parent Node, name string, iter *iterator, n Node) {
+	if n == nil {
+		return
+	}
+	if v := reflect.ValueOf(n); v.Kind() == reflect.Ptr && v.IsNil() {
+		return
+	}
+	saved := a.cursor
+	a.cursor = Cursor{parent: parent, name: name, iter: iter, node: n}
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	switch n := a.cursor.node.(type) {
+	case *InterpStr:
+		a.applyList(n, "Parts")
+	case *Insert:
+		a.apply(n, "Body", nil, n.Body)
+	case *Heredoc:
+		a.applyList(n, "Parts")
+	case *XStr:
+		a.applyList(n, "Parts")
+	case *InterpSymbol:
+		a.applyList(n, "Parts")
+	case *RegexpLit:
+		a.applyList(n, "Parts")
+	case *ArrayLit:
+		a.applyList(n, "Elems")
+	case *HashLit:
+		a.applyList(n, "Pairs")
+	case *RangeLit:
+		a.apply(n, "Low", nil, n.Low)
+		a.apply(n, "High", nil, n.High)
+	case *Pair:
+		a.apply(n, "Key", nil, n.Key)
+		a.apply(n, "Value", nil, n.Value)
+	case *Const:
+		a.apply(n, "Scope", nil, n.Scope)
+	case *Assign:
+		a.apply(n, "Lhs", nil, n.Lhs)
+		a.apply(n, "Rhs", nil, n.Rhs)
+	case *MultiAssign:
+		a.applyList(n, "Lhs")
+		a.apply(n, "Rhs", nil, n.Rhs)
+	case *Binary:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Y", nil, n.Y)
+	case *Unary:
+		a.apply(n, "X", nil, n.X)
+	case *Defined:
+		a.apply(n, "X", nil, n.X)
+	case *Splat:
+		a.apply(n, "Value", nil, n.Value)
+	case *BlockPass:
+		a.apply(n, "Value", nil, n.Value)
+	case *Call:
+		a.apply(n, "Recv", nil, n.Recv)
+		a.applyList(n, "Args")
+		a.apply(n, "Block", nil, n.Block)
+	case *Index:
+		a.apply(n, "Recv", nil, n.Recv)
+		a.applyList(n, "Args")
+	case *Block:
+		a.apply(n, "Params", nil, n.Params)
+		a.apply(n, "Body", nil, n.Body)
+	case *Yield:
+		a.applyList(n, "Args")
+	case *Super:
+		a.applyList(n, "Args")
+		a.apply(n, "Block", nil, n.Block)
+	case *Params:
+		a.applyList(n, "List")
+	case *Param:
+		a.apply(n, "Default", nil, n.Default)
+		a.apply(n, "Nested", nil, n.Nested)
+	case *Def:
+		a.apply(n, "Singleton", nil, n.Singleton)
+		a.apply(n, "Params", nil, n.Params)
+		a.apply(n, "Body", nil, n.Body)
+	case *ClassDef:
+		a.apply(n, "Path", nil, n.Path)
+		a.apply(n, "Super", nil, n.Super)
+		a.apply(n, "Body", nil, n.Body)
+	case *SingletonClassDef:
+		a.apply(n, "Target", nil, n.Target)
+		a.apply(n, "Body", nil, n.Body)
+	case *ModuleDef:
+		a.apply(n, "Path", nil, n.Path)
+		a.apply(n, "Body", nil, n.Body)
+	case *Alias:
+		a.apply(n, "New", nil, n.New)
+		a.apply(n, "Old", nil, n.Old)
+	case *Undef:
+		a.applyList(n, "Names")
+	case *If:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Then", nil, n.Then)
+		a.apply(n, "Else", nil, n.Else)
+	case *Ternary:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Then", nil, n.Then)
+		a.apply(n, "Else", nil, n.Else)
+	case *While:
+		a.apply(n, "Cond", nil, n.Cond)
+		a.apply(n, "Body", nil, n.Body)
+	case *For:
+		a.applyList(n, "Vars")
+		a.apply(n, "Iter", nil, n.Iter)
+		a.apply(n, "Body", nil, n.Body)
+	case *Case:
+		a.apply(n, "Subject", nil, n.Subject)
+		a.applyList(n, "Whens")
+		a.apply(n, "Else", nil, n.Else)
+	case *When:
+		a.applyList(n, "Conds")
+		a.apply(n, "Body", nil, n.Body)
+	case *Return:
+		a.applyList(n, "Args")
+	case *Break:
+		a.applyList(n, "Args")
+	case *Next:
+		a.applyList(n, "Args")
+	case *Begin:
+		a.apply(n, "Body", nil, n.Body)
+	case *RescueMod:
+		a.apply(n, "X", nil, n.X)
+		a.apply(n, "Rescue", nil, n.Rescue)
+	case *Paren:
+		a.apply(n, "Body", nil, n.Body)
+	case *Rescue:
+		a.applyList(n, "Classes")
+		a.apply(n, "Var", nil, n.Var)
+		a.apply(n, "Body", nil, n.Body)
+	case *BodyStmt:
+		a.apply(n, "Body", nil, n.Body)
+		a.applyList(n, "Rescues")
+		a.apply(n, "Else", nil, n.Else)
+		a.apply(n, "Ensure", nil, n.Ensure)
+	case *Stmts:
+		a.applyList(n, "List")
+	case *File:
+		a.apply(n, "Body", nil, n.Body)
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(errAbort)
+	}
+	a.cursor = saved
+}
+
+// applyList applies to the elements of the slice field of parent. The slice
+// is read for each element, since it may be modified by the cursor.
+func (a *application) applyList(parent Node, name string) {
+	saved := a.iter
+	a.iter.index = 0
+	for {
+		v := reflect.Indirect(reflect.ValueOf(parent)).FieldByName(name)
+		if a.iter.index >= v.Len() {
+			break
+		}
+		n, _ := v.Index(a.iter.index).Interface().(Node)
+		a.iter.step = 1
+		a.apply(parent, name, &a.iter, n)
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
@@ -0,0 +1,140 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/harukasan/ringo/token"
+)
+
+func TestApplyChildren(t *testing.T) {
+	for _, n := range nodes {
+		root, want := fill(n)
+		var got []string
+		Apply(root, func(c *Cursor) bool {
+			if c.Parent() == root {
+				got = append(got, c.Name())
+			}
+			return c.Node() == root
+		}, nil)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%T: children=%v (want=%v)", n, got, want)
+		}
+	}
+}
+
+// stmts returns a new Stmts of local variables.
+func stmts(names ...string) *Stmts {
+	s := &Stmts{}
+	for _, name := range names {
+		s.List = append(s.List, &LocalVar{Name: name})
+	}
+	return s
+}
+
+func names(s *Stmts) []string {
+	var list []string
+	for _, x := range s.List {
+		switch x := x.(type) {
+		case *LocalVar:
+			list = append(list, x.Name)
+		case *NumberLit:
+			list = append(list, x.Lit)
+		}
+	}
+	return list
+}
+
+func TestApplyModify(t *testing.T) {
+	rules := map[string]struct {
+		pre  func(c *Cursor)
+		want []string
+	}{
+		"replace": {
+			func(c *Cursor) {
+				if x, ok := c.Node().(*LocalVar); ok && x.Name == "b" {
+					c.Replace(&NumberLit{Kind: token.DecimalInteger, Lit: "1"})
+				}
+			},
+			[]string{"a", "1", "c"},
+		},
+		"delete": {
+			func(c *Cursor) {
+				if x, ok := c.Node().(*LocalVar); ok && x.Name != "c" {
+					c.Delete()
+				}
+			},
+			[]string{"c"},
+		},
+		"insert": {
+			func(c *Cursor) {
+				if x, ok := c.Node().(*LocalVar); ok && x.Name == "b" {
+					c.InsertBefore(&LocalVar{Name: "x"})
+					c.InsertAfter(&LocalVar{Name: "y"})
+				}
+			},
+			[]string{"a", "x", "b", "y", "c"},
+		},
+	}
+	for name, rule := range rules {
+		s := stmts("a", "b", "c")
+		var visited []string
+		Apply(s, func(c *Cursor) bool {
+			if x, ok := c.Node().(*LocalVar); ok {
+				visited = append(visited, x.Name)
+			}
+			rule.pre(c)
+			return true
+		}, nil)
+		if got := names(s); !reflect.DeepEqual(got, rule.want) {
+			t.Errorf("%s: stmts=%v (want=%v)", name, got, rule.want)
+		}
+		if want := []string{"a", "b", "c"}; !reflect.DeepEqual(visited, want) {
+			t.Errorf("%s: visited=%v (want=%v)", name, visited, want)
+		}
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	// f(a, b)
+	a, b := &LocalVar{Name: "a"}, &LocalVar{Name: "b"}
+	call := &Call{Name: "f", Args: []Expr{a, b}}
+	Apply(call, func(c *Cursor) bool {
+		switch c.Node() {
+		case call:
+			if c.Parent() == nil || c.Name() != "Node" || c.Index() != -1 {
+				t.Errorf("call: parent=%v name=%v index=%v", c.Parent(), c.Name(), c.Index())
+			}
+		case b:
+			if c.Parent() != call || c.Name() != "Args" || c.Index() != 1 {
+				t.Errorf("b: parent=%v name=%v index=%v", c.Parent(), c.Name(), c.Index())
+			}
+		}
+		return true
+	}, nil)
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	root := Apply(&LocalVar{Name: "a"}, nil, func(c *Cursor) bool {
+		c.Replace(&LocalVar{Name: "b"})
+		return true
+	})
+	if x, ok := root.(*LocalVar); !ok || x.Name != "b" {
+		t.Errorf("root=%v (want=b)", root)
+	}
+}
+
+func TestApplyAbort(t *testing.T) {
+	s := stmts("a", "b", "c")
+	var visited []string
+	Apply(s, nil, func(c *Cursor) bool {
+		if x, ok := c.Node().(*LocalVar); ok {
+			visited = append(visited, x.Name)
+			return x.Name != "b"
+		}
+		return true
+	})
+	if want := []string{"a", "b"}; !reflect.DeepEqual(visited, want) {
+		t.Errorf("visited=%v (want=%v)", visited, want)
+	}
+}
@@ -0,0 +1,569 @@
+/*
+Package ast declares the types used to represent the syntax tree of Ruby.
+
+All of Ruby constructs are expressions, so most nodes implement the Expr
+interface. The other nodes, such as Stmts, Params, or Rescue, are the parts
+of the expressions. Every node holds the offsets of its first character and
+just after its last character in the source, as returned by the scanner.
+
+The tree is traversed by Walk and Inspect, and modified in place by Apply.
+*/
+package ast
+
+import "github.com/harukasan/ringo/token"
+
+// Node is the interface implemented by all nodes.
+type Node interface {
+	Pos() int // offset of the first character of the node
+	End() int // offset just after the last character of the node
+}
+
+// Expr is the interface implemented by all expression nodes.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// Span holds the positions of the node. It is embedded in all nodes.
+type Span struct {
+	Start int // offset of the first character
+	Stop  int // offset just after the last character
+}
+
+// Pos returns the offset of the first character of the node.
+func (s Span) Pos() int { return s.Start }
+
+// End returns the offset just after the last character of the node.
+func (s Span) End() int { return s.Stop }
+
+// ----------------------------------------------------------------------------
+// Literals
+
+type (
+	// NumberLit is a numeric literal. Kind is one of the numeric tokens such
+	// as DecimalInteger or Float, and Lit is the literal as scanned. The value
+	// is evaluated by the literal package.
+	NumberLit struct {
+		Span
+		Kind token.Token
+		Lit  string
+	}
+
+	// StrLit is a string literal without interpolation such as 'a' or "a",
+	// or a static part of the interpolated string.
+	StrLit struct {
+		Span
+		Value string
+	}
+
+	// InterpStr is a string with interpolation such as "a#{b}c". Parts are
+	// *StrLit or *Insert.
+	InterpStr struct {
+		Span
+		Parts []Expr
+	}
+
+	// Insert is an interpolation in strings, such as #{x} or #@x.
+	Insert struct {
+		Span
+		Body *Stmts
+	}
+
+	// Heredoc is a here document. The body is given by Parts which are
+	// *StrLit or *Insert as same as InterpStr.
+	Heredoc struct {
+		Span
+		Term   string // terminator such as EOS or 'EOS'
+		Indent bool   // <<-EOS
+		Parts  []Expr
+	}
+
+	// XStr is a command output such as `ls` or %x(ls).
+	XStr struct {
+		Span
+		Parts []Expr
+	}
+
+	// SymbolLit is a symbol such as :a, :"a", or %s(a).
+	SymbolLit struct {
+		Span
+		Name string
+	}
+
+	// InterpSymbol is a symbol with interpolation such as :"a#{b}".
+	InterpSymbol struct {
+		Span
+		Parts []Expr
+	}
+
+	// RegexpLit is a regular expression such as /a/i or %r{a}.
+	RegexpLit struct {
+		Span
+		Parts   []Expr
+		Options string
+	}
+
+	// ArrayLit is an array such as [1, 2] or %w(a b).
+	ArrayLit struct {
+		Span
+		Elems []Expr
+	}
+
+	// HashLit is a hash such as {a: 1, "b" => 2}. The braces are omitted for
+	// the hash at the end of arguments.
+	HashLit struct {
+		Span
+		Pairs  []*Pair
+		Braces bool
+	}
+
+	// RangeLit is a range such as 1..2 or 1...2. Low or High is nil for the
+	// endless or beginless range.
+	RangeLit struct {
+		Span
+		Low, High Expr
+		Exclusive bool // ...
+	}
+
+	// PseudoVar is a keyword which works as a variable, such as self, nil,
+	// true, false, __FILE__, __LINE__, or __ENCODING__.
+	PseudoVar struct {
+		Span
+		Kind token.Token
+	}
+)
+
+// Pair is a key and value pair of hash. Key is nil for a double splat such
+// as **h.
+type Pair struct {
+	Span
+	Key   Expr
+	Value Expr
+}
+
+// ----------------------------------------------------------------------------
+// Variables
+
+type (
+	// LocalVar is a local variable. The identifier which is not assigned
+	// before is a method call, not a local variable.
+	LocalVar struct {
+		Span
+		Name string
+	}
+
+	// InstanceVar is an instance variable such as @a.
+	InstanceVar struct {
+		Span
+		Name string
+	}
+
+	// ClassVar is a class variable such as @@a.
+	ClassVar struct {
+		Span
+		Name string
+	}
+
+	// GlobalVar is a global variable such as $a.
+	GlobalVar struct {
+		Span
+		Name string
+	}
+
+	// Const is a constant such as A, B::A, or ::A.
+	Const struct {
+		Span
+		Scope Expr // B of B::A, or nil
+		Top   bool // ::A
+		Name  string
+	}
+)
+
+// ----------------------------------------------------------------------------
+// Operators and assignments
+
+type (
+	// Assign is an assignment such as a = 1 or a += 1. Op is Assign or one of
+	// the compound assign operators.
+	Assign struct {
+		Span
+		Lhs Expr
+		Op  token.Token
+		Rhs Expr
+	}
+
+	// MultiAssign is a multiple assignment such as a, *b = 1, 2. The right
+	// hand side of multiple values is given as an *ArrayLit. The nested
+	// group of the left hand side such as (b, c) in a, (b, c) = 1, [2, 3]
+	// is a MultiAssign whose Rhs is nil.
+	MultiAssign struct {
+		Span
+		Lhs []Expr
+		Rhs Expr
+	}
+
+	// Binary is a binary operation such as a + b, a && b, or a and b.
+	Binary struct {
+		Span
+		Op    token.Token
+		OpPos int
+		X, Y  Expr
+	}
+
+	// Unary is an unary operation such as -a, !a, or not a.
+	Unary struct {
+		Span
+		Op token.Token
+		X  Expr
+	}
+
+	// Defined is the defined? operator.
+	Defined struct {
+		Span
+		X Expr
+	}
+
+	// Splat is an expanded array such as *a in arguments and left hand side,
+	// or a double splat such as **h.
+	Splat struct {
+		Span
+		Double bool
+		Value  Expr // nil for anonymous splat of parameters such as *
+	}
+
+	// BlockPass is a block argument such as &blk.
+	BlockPass struct {
+		Span
+		Value Expr
+	}
+)
+
+// ----------------------------------------------------------------------------
+// Calls
+
+type (
+	// Call is a method call. Recv is nil for the call without receiver.
+	Call struct {
+		Span
+		Recv   Expr
+		Op     token.Token // Dot or Colon2, None without receiver
+		Name   string
+		Args   []Expr // may contain *Splat, *BlockPass, and *HashLit
+		Parens bool   // whether the arguments are enclosed by parentheses
+		Block  *Block
+	}
+
+	// Index is an element reference such as a[1].
+	Index struct {
+		Span
+		Recv Expr
+		Args []Expr
+	}
+
+	// Block is a block given to the method call, such as { |x| x } or
+	// do |x| x end.
+	Block struct {
+		Span
+		Params *Params
+		Body   *BodyStmt
+		Braces bool
+	}
+
+	// Yield is a yield expression.
+	Yield struct {
+		Span
+		Args []Expr
+	}
+
+	// Super is a super call. Args is nil for super without arguments that
+	// passes the same arguments, and empty for super().
+	Super struct {
+		Span
+		Args  []Expr
+		Block *Block
+	}
+)
+
+// ParamKind is the kind of parameters.
+type ParamKind int
+
+// Parameter kinds:
+const (
+	RequiredParam    ParamKind = iota // a
+	OptionalParam                     // a = 1
+	RestParam                         // *a
+	PostParam                         // a of (*r, a)
+	KeywordParam                      // a: or a: 1
+	KeywordRestParam                  // **a
+	BlockParam                        // &a
+)
+
+// Param is a parameter of method or block.
+type Param struct {
+	Span
+	Kind    ParamKind
+	Name    string  // empty for anonymous parameters such as *
+	Default Expr    // default value of optional or keyword parameter
+	Nested  *Params // destructuring parameter such as (a, b) of |(a, b), c|
+}
+
+// Params is the parameter list of method or block.
+type Params struct {
+	Span
+	List []*Param
+}
+
+// ----------------------------------------------------------------------------
+// Definitions
+
+type (
+	// Def is a method definition. Singleton is the receiver of singleton
+	// method, such as self of def self.a.
+	Def struct {
+		Span
+		Singleton Expr
+		Name      string
+		Params    *Params
+		Body      *BodyStmt
+	}
+
+	// ClassDef is a class definition.
+	ClassDef struct {
+		Span
+		Path  *Const
+		Super Expr
+		Body  *BodyStmt
+	}
+
+	// SingletonClassDef is a singleton class definition such as
+	// class << self.
+	SingletonClassDef struct {
+		Span
+		Target Expr
+		Body   *BodyStmt
+	}
+
+	// ModuleDef is a module definition.
+	ModuleDef struct {
+		Span
+		Path *Const
+		Body *BodyStmt
+	}
+
+	// Alias is an alias of method or global variable. New and Old are
+	// *SymbolLit, or *GlobalVar.
+	Alias struct {
+		Span
+		New, Old Expr
+	}
+
+	// Undef is an undef of methods.
+	Undef struct {
+		Span
+		Names []Expr
+	}
+)
+
+// ----------------------------------------------------------------------------
+// Control flow
+
+type (
+	// If is an if or unless expression, or its modifier form such as a if b.
+	// Else is nil, *Stmts, or *If of elsif.
+	If struct {
+		Span
+		Unless bool
+		Mod    bool
+		Cond   Expr
+		Then   *Stmts
+		Else   Node
+	}
+
+	// Ternary is a conditional operator such as a ? b : c.
+	Ternary struct {
+		Span
+		Cond, Then, Else Expr
+	}
+
+	// While is a while or until loop, or its modifier form. DoWhile reports
+	// the modifier form applied to begin...end, which runs the body first.
+	While struct {
+		Span
+		Until   bool
+		Mod     bool
+		DoWhile bool
+		Cond    Expr
+		Body    *Stmts
+	}
+
+	// For is a for loop.
+	For struct {
+		Span
+		Vars []Expr
+		Iter Expr
+		Body *Stmts
+	}
+
+	// Case is a case expression. Subject is nil for case without subject.
+	Case struct {
+		Span
+		Subject Expr
+		Whens   []*When
+		Else    *Stmts
+	}
+
+	// Return is a return expression.
+	Return struct {
+		Span
+		Args []Expr
+	}
+
+	// Break is a break expression.
+	Break struct {
+		Span
+		Args []Expr
+	}
+
+	// Next is a next expression.
+	Next struct {
+		Span
+		Args []Expr
+	}
+
+	// Redo is a redo expression.
+	Redo struct {
+		Span
+	}
+
+	// Retry is a retry expression.
+	Retry struct {
+		Span
+	}
+
+	// Begin is a begin...end block.
+	Begin struct {
+		Span
+		Body *BodyStmt
+	}
+
+	// RescueMod is a rescue modifier such as a rescue b.
+	RescueMod struct {
+		Span
+		X      Expr
+		Rescue Expr
+	}
+
+	// Paren is an expression enclosed by parentheses.
+	Paren struct {
+		Span
+		Body *Stmts
+	}
+)
+
+// When is a when clause of case.
+type When struct {
+	Span
+	Conds []Expr
+	Body  *Stmts
+}
+
+// Rescue is a rescue clause. Classes is empty to rescue StandardError, and
+// Var is the variable to hold the exception, such as e of => e.
+type Rescue struct {
+	Span
+	Classes []Expr
+	Var     Expr
+	Body    *Stmts
+}
+
+// BodyStmt is the body of def, class, module, block, and begin, which may
+// have rescue, else, and ensure clauses.
+type BodyStmt struct {
+	Span
+	Body    *Stmts
+	Rescues []*Rescue
+	Else    *Stmts
+	Ensure  *Stmts
+}
+
+// Stmts is a sequence of expressions.
+type Stmts struct {
+	Span
+	List []Expr
+}
+
+// File is a source file.
+type File struct {
+	Span
+	Name string
+	Body *Stmts
+}
+
+// ----------------------------------------------------------------------------
+// Errors
+
+type (
+	// BadExpr is a placeholder for the expression containing syntax errors.
+	// The tokens in the span are skipped by the parser.
+	BadExpr struct {
+		Span
+	}
+
+	// MissingExpr is a placeholder for the expression which is required but
+	// not written, such as the right hand side of a = in the end of line. The
+	// span is empty.
+	MissingExpr struct {
+		Span
+	}
+)
+
+func (*NumberLit) exprNode()         {}
+func (*StrLit) exprNode()            {}
+func (*InterpStr) exprNode()         {}
+func (*Insert) exprNode()            {}
+func (*Heredoc) exprNode()           {}
+func (*XStr) exprNode()              {}
+func (*SymbolLit) exprNode()         {}
+func (*InterpSymbol) exprNode()      {}
+func (*RegexpLit) exprNode()         {}
+func (*ArrayLit) exprNode()          {}
+func (*HashLit) exprNode()           {}
+func (*RangeLit) exprNode()          {}
+func (*PseudoVar) exprNode()         {}
+func (*LocalVar) exprNode()          {}
+func (*InstanceVar) exprNode()       {}
+func (*ClassVar) exprNode()          {}
+func (*GlobalVar) exprNode()         {}
+func (*Const) exprNode()             {}
+func (*Assign) exprNode()            {}
+func (*MultiAssign) exprNode()       {}
+func (*Binary) exprNode()            {}
+func (*Unary) exprNode()             {}
+func (*Defined) exprNode()           {}
+func (*Splat) exprNode()             {}
+func (*BlockPass) exprNode()         {}
+func (*Call) exprNode()              {}
+func (*Index) exprNode()             {}
+func (*Yield) exprNode()             {}
+func (*Super) exprNode()             {}
+func (*Def) exprNode()               {}
+func (*ClassDef) exprNode()          {}
+func (*SingletonClassDef) exprNode() {}
+func (*ModuleDef) exprNode()         {}
+func (*Alias) exprNode()             {}
+func (*Undef) exprNode()             {}
+func (*If) exprNode()                {}
+func (*Ternary) exprNode()           {}
+func (*While) exprNode()             {}
+func (*For) exprNode()               {}
+func (*Case) exprNode()              {}
+func (*Return) exprNode()            {}
+func (*Break) exprNode()             {}
+func (*Next) exprNode()              {}
+func (*Redo) exprNode()              {}
+func (*Retry) exprNode()             {}
+func (*Begin) exprNode()             {}
+func (*RescueMod) exprNode()         {}
+func (*Paren) exprNode()             {}
+func (*BadExpr) exprNode()           {}
+func (*MissingExpr) exprNode()       {}
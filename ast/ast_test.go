@@ -0,0 +1,46 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/harukasan/ringo/token"
+)
+
+func TestSpan(t *testing.T) {
+	// a + 1
+	x := &LocalVar{Span{0, 1}, "a"}
+	y := &NumberLit{Span{4, 5}, token.DecimalInteger, "1"}
+	rules := map[Node][2]int{
+		x:                                        {0, 1},
+		y:                                        {4, 5},
+		&Binary{Span{0, 5}, token.Plus, 2, x, y}: {0, 5},
+		&Stmts{Span{0, 5}, []Expr{x}}:            {0, 5},
+	}
+	for n, want := range rules {
+		if n.Pos() != want[0] || n.End() != want[1] {
+			t.Errorf("%T: Pos()=%v (want=%v), End()=%v (want=%v)", n, n.Pos(), want[0], n.End(), want[1])
+		}
+	}
+}
+
+// exprs lists all expression nodes, and fails to compile if any of them does
+// not implement Expr.
+var exprs = []Expr{
+	&NumberLit{}, &StrLit{}, &InterpStr{}, &Insert{}, &Heredoc{}, &XStr{},
+	&SymbolLit{}, &InterpSymbol{}, &RegexpLit{}, &ArrayLit{}, &HashLit{},
+	&RangeLit{}, &PseudoVar{}, &LocalVar{}, &InstanceVar{}, &ClassVar{},
+	&GlobalVar{}, &Const{}, &Assign{}, &MultiAssign{}, &Binary{}, &Unary{},
+	&Defined{}, &Splat{}, &BlockPass{}, &Call{}, &Index{}, &Yield{},
+	&Super{}, &Def{}, &ClassDef{}, &SingletonClassDef{}, &ModuleDef{},
+	&Alias{}, &Undef{}, &If{}, &Ternary{}, &While{}, &For{}, &Case{},
+	&Return{}, &Break{}, &Next{}, &Redo{}, &Retry{}, &Begin{},
+	&RescueMod{}, &Paren{}, &BadExpr{}, &MissingExpr{},
+}
+
+func TestExprZeroSpan(t *testing.T) {
+	for _, x := range exprs {
+		if x.Pos() != 0 || x.End() != 0 {
+			t.Errorf("%T: Pos()=%v, End()=%v (want=0, 0)", x, x.Pos(), x.End())
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package ast
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the syntax tree in depth-first order. It starts by calling
+// v.Visit(node), and walks the children of node if the visitor is not nil.
+// The nil children are not visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	// literals
+	case *NumberLit, *StrLit, *SymbolLit, *PseudoVar:
+		// nothing to do
+	case *InterpStr:
+		walkExprs(v, n.Parts)
+	case *Insert:
+		walkStmts(v, n.Body)
+	case *Heredoc:
+		walkExprs(v, n.Parts)
+	case *XStr:
+		walkExprs(v, n.Parts)
+	case *InterpSymbol:
+		walkExprs(v, n.Parts)
+	case *RegexpLit:
+		walkExprs(v, n.Parts)
+	case *ArrayLit:
+		walkExprs(v, n.Elems)
+	case *HashLit:
+		for _, x := range n.Pairs {
+			Walk(v, x)
+		}
+	case *RangeLit:
+		walkExpr(v, n.Low)
+		walkExpr(v, n.High)
+	case *Pair:
+		walkExpr(v, n.Key)
+		walkExpr(v, n.Value)
+
+	// variables
+	case *LocalVar, *InstanceVar, *ClassVar, *GlobalVar:
+		// nothing to do
+	case *Const:
+		walkExpr(v, n.Scope)
+
+	// operators
+	case *Assign:
+		walkExpr(v, n.Lhs)
+		walkExpr(v, n.Rhs)
+	case *MultiAssign:
+		walkExprs(v, n.Lhs)
+		walkExpr(v, n.Rhs)
+	case *Binary:
+		walkExpr(v, n.X)
+		walkExpr(v, n.Y)
+	case *Unary:
+		walkExpr(v, n.X)
+	case *Defined:
+		walkExpr(v, n.X)
+	case *Splat:
+		walkExpr(v, n.Value)
+	case *BlockPass:
+		walkExpr(v, n.Value)
+
+	// calls
+	case *Call:
+		walkExpr(v, n.Recv)
+		walkExprs(v, n.Args)
+		if n.Block != nil {
+			Walk(v, n.Block)
+		}
+	case *Index:
+		walkExpr(v, n.Recv)
+		walkExprs(v, n.Args)
+	case *Block:
+		if n.Params != nil {
+			Walk(v, n.Params)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *Yield:
+		walkExprs(v, n.Args)
+	case *Super:
+		walkExprs(v, n.Args)
+		if n.Block != nil {
+			Walk(v, n.Block)
+		}
+	case *Params:
+		for _, x := range n.List {
+			Walk(v, x)
+		}
+	case *Param:
+		walkExpr(v, n.Default)
+		if n.Nested != nil {
+			Walk(v, n.Nested)
+		}
+
+	// definitions
+	case *Def:
+		walkExpr(v, n.Singleton)
+		if n.Params != nil {
+			Walk(v, n.Params)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *ClassDef:
+		if n.Path != nil {
+			Walk(v, n.Path)
+		}
+		walkExpr(v, n.Super)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *SingletonClassDef:
+		walkExpr(v, n.Target)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *ModuleDef:
+		if n.Path != nil {
+			Walk(v, n.Path)
+		}
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *Alias:
+		walkExpr(v, n.New)
+		walkExpr(v, n.Old)
+	case *Undef:
+		walkExprs(v, n.Names)
+
+	// control flow
+	case *If:
+		walkExpr(v, n.Cond)
+		walkStmts(v, n.Then)
+		if n.Else != nil && !isNilNode(n.Else) {
+			Walk(v, n.Else)
+		}
+	case *Ternary:
+		walkExpr(v, n.Cond)
+		walkExpr(v, n.Then)
+		walkExpr(v, n.Else)
+	case *While:
+		walkExpr(v, n.Cond)
+		walkStmts(v, n.Body)
+	case *For:
+		walkExprs(v, n.Vars)
+		walkExpr(v, n.Iter)
+		walkStmts(v, n.Body)
+	case *Case:
+		walkExpr(v, n.Subject)
+		for _, x := range n.Whens {
+			Walk(v, x)
+		}
+		walkStmts(v, n.Else)
+	case *When:
+		walkExprs(v, n.Conds)
+		walkStmts(v, n.Body)
+	case *Return:
+		walkExprs(v, n.Args)
+	case *Break:
+		walkExprs(v, n.Args)
+	case *Next:
+		walkExprs(v, n.Args)
+	case *Redo, *Retry:
+		// nothing to do
+	case *Begin:
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+	case *RescueMod:
+		walkExpr(v, n.X)
+		walkExpr(v, n.Rescue)
+	case *Paren:
+		walkStmts(v, n.Body)
+	case *Rescue:
+		walkExprs(v, n.Classes)
+		walkExpr(v, n.Var)
+		walkStmts(v, n.Body)
+	case *BodyStmt:
+		walkStmts(v, n.Body)
+		for _, x := range n.Rescues {
+			Walk(v, x)
+		}
+		walkStmts(v, n.Else)
+		walkStmts(v, n.Ensure)
+	case *Stmts:
+		walkExprs(v, n.List)
+	case *File:
+		walkStmts(v, n.Body)
+
+	// errors
+	case *BadExpr, *MissingExpr:
+		// nothing to do
+	}
+
+	v.Visit(nil)
+}
+
+func walkExpr(v Visitor, x Expr) {
+	if x != nil {
+		Walk(v, x)
+	}
+}
+
+func walkExprs(v Visitor, list []Expr) {
+	for _, x := range list {
+		walkExpr(v, x)
+	}
+}
+
+func walkStmts(v Visitor, s *Stmts) {
+	if s != nil {
+		Walk(v, s)
+	}
+}
+
+// isNilNode returns whether the node is a nil pointer such as (*Stmts)(nil).
+func isNilNode(n Node) bool {
+	switch n := n.(type) {
+	case *Stmts:
+		return n == nil
+	case *If:
+		return n == nil
+	}
+	return false
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the syntax tree in depth-first order. It starts by
+// calling f(node), and walks the children of node if f returns true. After
+// the children are visited, f(nil) is called.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,98 @@
+package ast
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/harukasan/ringo/token"
+)
+
+// nodes lists all nodes including the parts of expressions.
+var nodes = append([]Node{
+	&Pair{}, &Block{}, &Params{}, &Param{}, &When{}, &Rescue{}, &BodyStmt{},
+	&Stmts{}, &File{},
+}, exprNodes()...)
+
+func exprNodes() []Node {
+	var list []Node
+	for _, x := range exprs {
+		list = append(list, x)
+	}
+	return list
+}
+
+// fill returns a new node of the same type as n whose all children are set.
+// It also returns the names of the fields of children.
+func fill(n Node) (Node, []string) {
+	v := reflect.New(reflect.TypeOf(n).Elem())
+	var names []string
+	for i := 0; i < v.Elem().NumField(); i++ {
+		f := v.Elem().Field(i)
+		name := v.Elem().Type().Field(i).Name
+		if c, ok := child(f.Type()); ok {
+			f.Set(c)
+			names = append(names, name)
+			continue
+		}
+		if f.Kind() == reflect.Slice {
+			if c, ok := child(f.Type().Elem()); ok {
+				f.Set(reflect.Append(f, c, c))
+				names = append(names, name, name)
+			}
+		}
+	}
+	return v.Interface().(Node), names
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+// child returns a new child node of the type t.
+func child(t reflect.Type) (reflect.Value, bool) {
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(nodeType):
+		return reflect.ValueOf(&BadExpr{}), true
+	case t.Kind() == reflect.Ptr && t.Implements(nodeType):
+		return reflect.New(t.Elem()), true
+	}
+	return reflect.Value{}, false
+}
+
+func TestWalkChildren(t *testing.T) {
+	for _, n := range nodes {
+		root, want := fill(n)
+		got := 0
+		depth := 0
+		Inspect(root, func(n Node) bool {
+			if n == nil {
+				depth--
+				return false
+			}
+			if depth == 1 {
+				got++
+			}
+			depth++
+			return true
+		})
+		if got != len(want) {
+			t.Errorf("%T: children=%d (want=%d: %v)", n, got, len(want), want)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	// a + f(1)
+	a := &LocalVar{Span{0, 1}, "a"}
+	one := &NumberLit{Span{6, 7}, token.DecimalInteger, "1"}
+	call := &Call{Span: Span{4, 8}, Name: "f", Args: []Expr{one}, Parens: true}
+	root := &Binary{Span{0, 8}, token.Plus, 2, a, call}
+
+	var got []Node
+	Inspect(root, func(n Node) bool {
+		got = append(got, n)
+		return n != call
+	})
+	want := []Node{root, a, nil, call, nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nodes=%v (want=%v)", got, want)
+	}
+}
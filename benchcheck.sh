@@ -0,0 +1,19 @@
+#!/bin/bash
+#
+# Run the scanner benchmarks and check the throughput against the target
+# which is documented in README.md.
+
+min_mbs=${MIN_MBS:-50}
+
+go test -run NONE -bench . -benchmem ./scanner | tee bench_output.txt | awk -v min="$min_mbs" '
+/^Benchmark/ {
+  print
+  for (i = 2; i <= NF; i++) {
+    if ($i == "MB/s" && $(i-1) + 0 < min + 0) {
+      printf("%s: %s MB/s is below the target %s MB/s\n", $1, $(i-1), min)
+      failed = 1
+    }
+  }
+}
+END { exit failed }
+'
@@ -0,0 +1,103 @@
+// Command ringo runs a Ruby program.
+//
+// Usage:
+//
+//	ringo [-e script] [-vm] [--dump=insns] [file]
+//
+// The program is read from the standard input if neither the script nor the
+// file is given. It runs by the tree-walking interpreter, or by the bytecode
+// VM if -vm is given. --dump=insns prints the instructions compiled for the
+// VM instead of running the program.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/diagnostics"
+	"github.com/harukasan/ringo/interp"
+	"github.com/harukasan/ringo/object"
+	"github.com/harukasan/ringo/parser"
+	"github.com/harukasan/ringo/vm"
+)
+
+func main() {
+	script := flag.String("e", "", "run the `script` instead of the file")
+	useVM := flag.Bool("vm", false, "run by the bytecode VM")
+	dump := flag.String("dump", "", "print the `insns` instead of running")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ringo [-e script] [-vm] [--dump=insns] [file]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	name, src, err := readSource(*script, flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ringo: %v\n", err)
+		os.Exit(1)
+	}
+	switch *dump {
+	case "":
+	case "insns":
+		os.Exit(dumpInsns(name, src))
+	default:
+		fmt.Fprintf(os.Stderr, "ringo: unknown dump %q\n", *dump)
+		os.Exit(2)
+	}
+	os.Exit(run(name, src, *useVM))
+}
+
+// readSource returns the name and the source of the program.
+func readSource(script string, args []string) (string, []byte, error) {
+	switch {
+	case script != "":
+		return "-e", []byte(script), nil
+	case len(args) > 0:
+		src, err := ioutil.ReadFile(args[0])
+		return args[0], src, err
+	}
+	src, err := ioutil.ReadAll(os.Stdin)
+	return "-", src, err
+}
+
+// parse parses the program, printing the syntax errors.
+func parse(name string, src []byte) (*ast.File, bool) {
+	f, err := parser.ParseFile(name, src)
+	if err != nil {
+		diagnostics.NewPrinter(os.Stderr).PrintError(diagnostics.NewFile(name, src), err)
+		return nil, false
+	}
+	return f, true
+}
+
+// run runs the program and returns the exit status.
+func run(name string, src []byte, useVM bool) int {
+	f, ok := parse(name, src)
+	if !ok {
+		return 1
+	}
+	var err error
+	if useVM {
+		_, err = vm.New(object.New()).Run(f, src)
+	} else {
+		_, err = interp.New(object.New()).Run(f, src)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// dumpInsns prints the instructions of the program.
+func dumpInsns(name string, src []byte) int {
+	f, ok := parse(name, src)
+	if !ok {
+		return 1
+	}
+	fmt.Print(vm.Compile(f, src).Disasm())
+	return 0
+}
@@ -0,0 +1,167 @@
+package debug
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Category is a named group of debug messages that can be turned on
+// individually. The name is separated by dots, such as "scanner.ctx".
+type Category struct {
+	name    string
+	enabled int32 // accessed atomically
+}
+
+// rule enables or disables categories matched to the pattern.
+type rule struct {
+	pattern string
+	enable  bool
+}
+
+var registry = struct {
+	sync.Mutex
+	categories map[string]*Category
+	rules      []rule
+}{
+	categories: map[string]*Category{},
+}
+
+func init() {
+	Configure(os.Getenv("RINGO_DEBUG"))
+	if strings.EqualFold(os.Getenv("RINGO_DEBUG_FORMAT"), "json") {
+		OutputFormat = JSON
+	}
+}
+
+// NewCategory returns the category with the given name. It returns the same
+// category for the same name.
+func NewCategory(name string) *Category {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if c, ok := registry.categories[name]; ok {
+		return c
+	}
+	c := &Category{name: name}
+	c.apply(registry.rules)
+	registry.categories[name] = c
+	return c
+}
+
+// Categories returns the sorted names of all categories.
+func Categories() []string {
+	registry.Lock()
+	defer registry.Unlock()
+
+	names := make([]string, 0, len(registry.categories))
+	for name := range registry.categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// match returns whether the pattern matches to the category name. The
+// pattern matches to the name itself and its sub categories, and "*" matches
+// to all categories.
+func match(pattern, name string) bool {
+	return pattern == "*" || pattern == name || strings.HasPrefix(name, pattern+".")
+}
+
+func (c *Category) apply(rules []rule) {
+	var enabled int32
+	for _, r := range rules {
+		if match(r.pattern, c.name) {
+			enabled = 0
+			if r.enable {
+				enabled = 1
+			}
+		}
+	}
+	atomic.StoreInt32(&c.enabled, enabled)
+}
+
+func addRules(enable bool, patterns []string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	for _, p := range patterns {
+		registry.rules = append(registry.rules, rule{pattern: p, enable: enable})
+	}
+	for _, c := range registry.categories {
+		c.apply(registry.rules)
+	}
+}
+
+// EnableCategory turns on the categories matched to the patterns. The
+// patterns also apply to categories created later.
+func EnableCategory(patterns ...string) {
+	addRules(true, patterns)
+}
+
+// DisableCategory turns off the categories matched to the patterns.
+func DisableCategory(patterns ...string) {
+	addRules(false, patterns)
+}
+
+// ResetCategories turns off all categories and forgets the patterns.
+func ResetCategories() {
+	registry.Lock()
+	defer registry.Unlock()
+
+	registry.rules = nil
+	for _, c := range registry.categories {
+		c.apply(nil)
+	}
+}
+
+// Configure enables and disables categories by the comma separated spec in
+// the same form as RINGO_DEBUG environment variable, such as
+// "scanner,-scanner.next,parser".
+func Configure(spec string) {
+	for _, p := range strings.Split(spec, ",") {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "":
+		case p[0] == '-':
+			DisableCategory(p[1:])
+		default:
+			EnableCategory(p)
+		}
+	}
+}
+
+// Name returns the name of the category.
+func (c *Category) Name() string {
+	return c.name
+}
+
+// Enabled returns whether the category is turned on.
+func (c *Category) Enabled() bool {
+	return atomic.LoadInt32(&c.enabled) != 0
+}
+
+// Print prints debug message of the category in manner of fmt.Sprint.
+func (c *Category) Print(v ...interface{}) {
+	if c.Enabled() {
+		output(2, c.name, fmt.Sprint(v...))
+	}
+}
+
+// Println prints debug message of the category in manner of fmt.Sprintln.
+func (c *Category) Println(v ...interface{}) {
+	if c.Enabled() {
+		output(2, c.name, fmt.Sprintln(v...))
+	}
+}
+
+// Printf prints debug message of the category in manner of fmt.Sprintf.
+func (c *Category) Printf(format string, v ...interface{}) {
+	if c.Enabled() {
+		output(2, c.name, fmt.Sprintf(format, v...))
+	}
+}
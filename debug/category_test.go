@@ -0,0 +1,153 @@
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	rules := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"scanner", "scanner", true},
+		{"scanner", "scanner.ctx", true},
+		{"scanner.ctx", "scanner.ctx", true},
+		{"scanner.ctx", "scanner", false},
+		{"scanner", "scanners", false},
+		{"scanner.ctx", "scanner.next", false},
+		{"*", "parser", true},
+	}
+	for _, r := range rules {
+		if got := match(r.pattern, r.name); got != r.want {
+			t.Errorf("match(%q, %q)=%v (want=%v)", r.pattern, r.name, got, r.want)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defer ResetCategories()
+
+	tests := map[string]map[string]bool{
+		"": {
+			"test.a": false,
+		},
+		"test": {
+			"test.a":   true,
+			"test.b.c": true,
+		},
+		"test, -test.b": {
+			"test.a":   true,
+			"test.b":   false,
+			"test.b.c": false,
+		},
+		"*,-test.b,test.b.c": {
+			"test.a":   true,
+			"test.b":   false,
+			"test.b.c": true,
+		},
+	}
+	for spec, wants := range tests {
+		ResetCategories()
+		Configure(spec)
+		for name, want := range wants {
+			if got := NewCategory(name).Enabled(); got != want {
+				t.Errorf("Configure(%q): %v enabled=%v (want=%v)", spec, name, got, want)
+			}
+		}
+	}
+}
+
+func TestNewCategory(t *testing.T) {
+	defer ResetCategories()
+
+	a := NewCategory("test.same")
+	if b := NewCategory("test.same"); a != b {
+		t.Errorf("NewCategory must return the same category for the same name")
+	}
+	EnableCategory("test.same")
+	if !a.Enabled() {
+		t.Errorf("%v must be enabled", a.Name())
+	}
+	found := false
+	for _, name := range Categories() {
+		found = found || name == "test.same"
+	}
+	if !found {
+		t.Errorf("Categories()=%v must contain test.same", Categories())
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	defer func(w io.Writer) {
+		Output = w
+		OutputFormat = Text
+		now = time.Now
+		ResetCategories()
+	}(Output)
+
+	var buf bytes.Buffer
+	Output = &buf
+	OutputFormat = JSON
+	now = func() time.Time { return time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	c := NewCategory("test.json")
+	EnableCategory("test.json")
+	c.Println("hello")
+
+	var got record
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("output must be JSON: %v: %q", err, buf.String())
+	}
+	want := record{
+		Time:     now(),
+		Category: "test.json",
+		File:     "category_test.go",
+		Line:     102,
+		Func:     "debug.TestJSONFormat",
+		Msg:      "hello",
+	}
+	if got != want {
+		t.Errorf("got=%+v (want=%+v)", got, want)
+	}
+}
+
+func TestConcurrentOutput(t *testing.T) {
+	defer func(w io.Writer) {
+		Output = w
+		ResetCategories()
+	}(Output)
+
+	var buf bytes.Buffer
+	Output = &buf
+	c := NewCategory("test.concurrent")
+	EnableCategory("test.concurrent")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Print("message")
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 1000 {
+		t.Fatalf("lines=%v (want=%v)", len(lines), 1000)
+	}
+	for _, l := range lines {
+		if !strings.HasSuffix(l, "> test.concurrent: message") {
+			t.Errorf("broken line: %q", l)
+		}
+	}
+}
@@ -9,47 +9,145 @@ The debug messages can enable by Enable variable, for instance:
 	// Output:
 	// debug message
 
+Messages can also be grouped by named categories, such as "scanner.ctx" or
+"parser", that are turned on individually:
+
+	var trace = debug.NewCategory("scanner.ctx")
+
+	debug.EnableCategory("scanner")
+	trace.Printf("push: %v", ctx)
+
+The categories can be enabled by RINGO_DEBUG environment variable that takes
+a comma separated list of categories. A category prefixed by '-' is disabled.
+RINGO_DEBUG_FORMAT=json switches the output to JSON lines (see Format).
+
+	RINGO_DEBUG=scanner,-scanner.next go test ./...
+
+Tracing in hot paths, such as the scanner reading every byte, must be guarded
+by the Tracing constant so that the arguments are not evaluated and boxed in
+normal builds:
+
+	if debug.Tracing {
+		debug.Printf("next: offset=%v", offset)
+	}
+
+The Tracing constant is true only when the package is built with the debug
+build tag:
+
+	go test -tags debug ./...
 */
 package debug
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"runtime"
+	"sync"
+	"time"
 )
 
 // Enable specifies whether the debug prints messages into the Output.
 var Enable = false
 
 // Output specifies the destination to print out messages.
-var Output = os.Stderr
+// Messages are written by a single Write call each, serialized between
+// goroutines.
+var Output io.Writer = os.Stderr
 
-func output(s string) {
-	_, file, line, _ := runtime.Caller(2)
-	fmt.Fprintf(Output, "%s:%d> %s", path.Base(file), line, s)
-	if s[len(s)-1] != '\n' {
-		fmt.Fprint(Output, "\n")
+// Format represents a output format of messages.
+type Format int
+
+// Output formats:
+const (
+	// Text prints a message per line, prefixed by the caller location and
+	// the category.
+	Text Format = iota
+
+	// JSON prints a JSON object per line, which has the time, category,
+	// caller location and message.
+	JSON
+)
+
+// OutputFormat specifies the format to print out messages.
+var OutputFormat = Text
+
+var (
+	outputMu sync.Mutex
+	now      = time.Now
+)
+
+// record is a message printed in JSON format.
+type record struct {
+	Time     time.Time `json:"time"`
+	Category string    `json:"category,omitempty"`
+	File     string    `json:"file"`
+	Line     int       `json:"line"`
+	Func     string    `json:"func"`
+	Msg      string    `json:"msg"`
+}
+
+// output writes the message s. depth is the number of stack frames to skip
+// to find the caller.
+func output(depth int, category, s string) {
+	r := record{
+		Time:     now(),
+		Category: category,
 	}
+	pc, file, line, ok := runtime.Caller(depth)
+	if ok {
+		r.File = path.Base(file)
+		r.Line = line
+		if f := runtime.FuncForPC(pc); f != nil {
+			r.Func = path.Base(f.Name())
+		}
+	}
+	if len(s) > 0 && s[len(s)-1] == '\n' {
+		s = s[:len(s)-1]
+	}
+	r.Msg = s
+
+	var buf bytes.Buffer
+	switch OutputFormat {
+	case JSON:
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(r); err != nil {
+			fmt.Fprintf(&buf, "{\"msg\":%q}\n", err.Error())
+		}
+	default:
+		if category != "" {
+			fmt.Fprintf(&buf, "%s:%d> %s: %s\n", r.File, r.Line, category, s)
+		} else {
+			fmt.Fprintf(&buf, "%s:%d> %s\n", r.File, r.Line, s)
+		}
+	}
+
+	outputMu.Lock()
+	Output.Write(buf.Bytes())
+	outputMu.Unlock()
 }
 
 // Print prints debug message in manner of fmt.Sprint.
 func Print(v ...interface{}) {
 	if Enable {
-		output(fmt.Sprint(v...))
+		output(2, "", fmt.Sprint(v...))
 	}
 }
 
 // Println prints debug message in manner of fmt.Sprintln.
 func Println(v ...interface{}) {
 	if Enable {
-		output(fmt.Sprintln(v...))
+		output(2, "", fmt.Sprintln(v...))
 	}
 }
 
 // Printf prints debug message in manner of fmt.Sprintf.
 func Printf(format string, v ...interface{}) {
 	if Enable {
-		output(fmt.Sprintf(format, v...))
+		output(2, "", fmt.Sprintf(format, v...))
 	}
 }
@@ -36,3 +36,16 @@ func ExamplePrintf() {
 	// Output:
 	// debug_test.go:35> a: true
 }
+
+func ExampleCategory() {
+	// set output to stdout for testing
+	debug.Output = os.Stdout
+	trace := debug.NewCategory("example.ctx")
+
+	debug.EnableCategory("example")
+	trace.Printf("push: %v", 1) // line: 46
+	debug.DisableCategory("example.ctx")
+	trace.Printf("push: %v", 2)
+	// Output:
+	// debug_test.go:46> example.ctx: push: 1
+}
@@ -0,0 +1,8 @@
+//go:build !debug
+// +build !debug
+
+package debug
+
+// Tracing reports whether the package is built with the debug build tag.
+// Without the tag, calls guarded by Tracing are removed by the compiler.
+const Tracing = false
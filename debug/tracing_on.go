@@ -0,0 +1,7 @@
+//go:build debug
+// +build debug
+
+package debug
+
+// Tracing reports whether the package is built with the debug build tag.
+const Tracing = true
@@ -0,0 +1,142 @@
+/*
+Package diagnostics renders errors in Ruby sources for humans. A diagnostic
+is printed with its position, the line of the source, and a caret line
+marking the range of the error:
+
+	t.rb:2:7: error: syntax error, unexpected 'end'
+	  |
+	2 | foo(1 end
+	  |       ^^^
+
+Labels attach secondary messages to the related ranges, such as the
+beginning of the unterminated heredoc. The errors of the parser and the
+scanner are converted by FromError.
+*/
+package diagnostics
+
+import (
+	"sort"
+
+	"github.com/harukasan/ringo/parser"
+	"github.com/harukasan/ringo/scanner"
+)
+
+// Severity is the severity of a diagnostic.
+type Severity int
+
+// Severities:
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return "error"
+}
+
+// Label is a secondary message attached to the range of the source.
+type Label struct {
+	Pos int // offset of the first character
+	End int // offset just after the last character
+	Msg string
+}
+
+// Diagnostic is a message about the range of the source. The range is a
+// point if End is not after Pos.
+type Diagnostic struct {
+	Severity Severity
+	Pos      int
+	End      int
+	Msg      string
+	Labels   []Label
+}
+
+// File is a source file to which the diagnostics refer.
+type File struct {
+	Name  string
+	Src   []byte
+	lines []int // offsets of the beginning of lines
+}
+
+// NewFile returns the file of the name and the source.
+func NewFile(name string, src []byte) *File {
+	f := &File{Name: name, Src: src, lines: []int{0}}
+	for i, c := range src {
+		if c == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	return f
+}
+
+// Position returns the line and column numbers of the offset, which start
+// at 1. The column counts bytes as the parser does.
+func (f *File) Position(off int) (line, column int) {
+	line = sort.SearchInts(f.lines, off+1)
+	return line, off - f.lines[line-1] + 1
+}
+
+// Line returns the n-th line without the new line.
+func (f *File) Line(n int) []byte {
+	if n < 1 || n > len(f.lines) {
+		return nil
+	}
+	begin := f.lines[n-1]
+	end := len(f.Src)
+	if n < len(f.lines) {
+		end = f.lines[n] - 1
+	}
+	if end > begin && f.Src[end-1] == '\r' {
+		end--
+	}
+	return f.Src[begin:end]
+}
+
+// FromError converts the errors returned by the parser or the scanner to
+// diagnostics. It returns a diagnostic of the message for other errors.
+func FromError(err error) []*Diagnostic {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case parser.ErrorList:
+		var ds []*Diagnostic
+		for _, e := range err {
+			ds = append(ds, fromParseError(e))
+		}
+		return ds
+	case *parser.Error:
+		return []*Diagnostic{fromParseError(err)}
+	case *scanner.ScanError:
+		return []*Diagnostic{{
+			Pos:    err.Pos,
+			End:    err.Pos,
+			Msg:    err.Err.Error(),
+			Labels: fromScanLabels(err.Labels),
+		}}
+	}
+	return []*Diagnostic{{Pos: -1, End: -1, Msg: err.Error()}}
+}
+
+func fromParseError(e *parser.Error) *Diagnostic {
+	return &Diagnostic{
+		Pos:    e.Pos,
+		End:    e.End,
+		Msg:    e.Msg,
+		Labels: fromScanLabels(e.Labels),
+	}
+}
+
+func fromScanLabels(labels []scanner.Label) []Label {
+	var ls []Label
+	for _, l := range labels {
+		ls = append(ls, Label{Pos: l.Pos, End: l.End, Msg: l.Msg})
+	}
+	return ls
+}
@@ -0,0 +1,106 @@
+package diagnostics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/harukasan/ringo/parser"
+)
+
+func TestPrint(t *testing.T) {
+	rules := map[string][]string{
+		"foo(1 end": {
+			"t.rb:1:7: error: syntax error, unexpected 'end', expecting ')'",
+			"  |",
+			"1 | foo(1 end",
+			"  |       ^^^",
+		},
+		"x = \"abc\ny": {
+			"t.rb:2:2: error: unterminated string meets end of file",
+			"  |",
+			"1 | x = \"abc",
+			"  |     - unterminated string opened here",
+			"2 | y",
+			"  |  ^",
+		},
+		"x = <<EOS\n\tabc\n\n": {
+			"t.rb:4:1: error: unterminated heredoc meets end of file",
+			"  |",
+			"1 | x = <<EOS",
+			"  |     ----- heredoc started here",
+			"...",
+			"4 | ",
+			"  | ^",
+		},
+		"\ta = (1 +\n\t)": {
+			"t.rb:2:2: error: syntax error, unexpected ')'",
+			"  |",
+			"2 | \t)",
+			"  | \t^",
+		},
+		"puts \"héllo\" end": {
+			"t.rb:1:15: error: syntax error, unexpected 'end', expecting end-of-input or new line",
+			"  |",
+			"1 | puts \"héllo\" end",
+			"  |              ^^^",
+		},
+	}
+	for input, want := range rules {
+		src := []byte(input)
+		_, err := parser.ParseFile("t.rb", src)
+		var buf bytes.Buffer
+		p := NewPrinter(&buf)
+		if err := p.PrintError(NewFile("t.rb", src), err); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != strings.Join(want, "\n")+"\n" {
+			t.Errorf("%q: output=\n%v\n(want=\n%v)", input, got, strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestPrintColor(t *testing.T) {
+	var buf bytes.Buffer
+	p := NewPrinter(&buf)
+	if p.Color {
+		t.Errorf("color=true (want=false for a buffer)")
+	}
+	p.Color = true
+	d := &Diagnostic{Severity: Warning, Pos: 2, End: 3, Msg: "m"}
+	if err := p.Print(NewFile("t.rb", []byte("a b")), d); err != nil {
+		t.Fatal(err)
+	}
+	want := "t.rb:1:3: \x1b[1;33mwarning:\x1b[0m \x1b[1mm\x1b[0m\n" +
+		"\x1b[1;34m  |\x1b[0m\n" +
+		"\x1b[1;34m1 |\x1b[0m a b\n" +
+		"\x1b[1;34m  |\x1b[0m   \x1b[1;33m^\x1b[0m\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output=%q (want=%q)", got, want)
+	}
+}
+
+func TestPrintWithoutPosition(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewPrinter(&buf).PrintError(NewFile("t.rb", nil), errors.New("oops")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "t.rb: error: oops\n"; got != want {
+		t.Errorf("output=%q (want=%q)", got, want)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	f := NewFile("t.rb", []byte("ab\r\ncd\n"))
+	rules := map[int][2]int{0: {1, 1}, 3: {1, 4}, 4: {2, 1}, 6: {2, 3}, 7: {3, 1}}
+	for off, want := range rules {
+		line, col := f.Position(off)
+		if line != want[0] || col != want[1] {
+			t.Errorf("%d: position=%d:%d (want=%d:%d)", off, line, col, want[0], want[1])
+		}
+	}
+	if got := string(f.Line(1)); got != "ab" {
+		t.Errorf("line=%q (want=%q)", got, "ab")
+	}
+}
@@ -0,0 +1,206 @@
+package diagnostics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ANSI escape sequences for the colours
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[1;31m"
+	colorYel   = "\x1b[1;33m"
+	colorCyan  = "\x1b[1;36m"
+	colorBlue  = "\x1b[1;34m"
+)
+
+// Printer writes diagnostics with the excerpts of the source.
+type Printer struct {
+	w     io.Writer
+	Color bool // whether to colour the output with ANSI escape sequences
+}
+
+// NewPrinter returns a printer writing to w. The output is coloured if w is
+// a terminal, unless NO_COLOR is set or TERM is dumb.
+func NewPrinter(w io.Writer) *Printer {
+	color := isTerminal(w) && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+	return &Printer{w: w, Color: color}
+}
+
+// isTerminal returns whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// PrintError prints the error converted by FromError.
+func (p *Printer) PrintError(f *File, err error) error {
+	for _, d := range FromError(err) {
+		if err := p.Print(f, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marker is an underline of a range in a line.
+type marker struct {
+	line    int
+	primary bool
+	begin   int // column of the first character, from 0
+	end     int // column just after the last character
+	msg     string
+}
+
+// Print prints the diagnostic.
+func (p *Printer) Print(f *File, d *Diagnostic) error {
+	var buf bytes.Buffer
+	if d.Pos < 0 || d.Pos > len(f.Src) {
+		p.header(&buf, f.Name, d)
+		_, err := p.w.Write(buf.Bytes())
+		return err
+	}
+	line, col := f.Position(d.Pos)
+	p.header(&buf, fmt.Sprintf("%s:%d:%d", f.Name, line, col), d)
+
+	markers := []marker{f.marker(d.Pos, d.End, "", true)}
+	for _, l := range d.Labels {
+		if l.Pos >= 0 && l.Pos <= len(f.Src) {
+			markers = append(markers, f.marker(l.Pos, l.End, l.Msg, false))
+		}
+	}
+	sort.Stable(byLine(markers))
+
+	width := len(strconv.Itoa(markers[len(markers)-1].line))
+	p.gutter(&buf, width, "")
+	buf.WriteByte('\n')
+	for i, m := range markers {
+		if i == 0 || markers[i-1].line != m.line {
+			if i > 0 && markers[i-1].line+1 < m.line {
+				p.gutter(&buf, width, "...")
+				buf.WriteByte('\n')
+			}
+			p.gutter(&buf, width, strconv.Itoa(m.line))
+			buf.WriteByte(' ')
+			buf.Write(f.Line(m.line))
+			buf.WriteByte('\n')
+		}
+		p.gutter(&buf, width, "")
+		buf.WriteByte(' ')
+		p.underline(&buf, f.Line(m.line), m, d.Severity)
+		buf.WriteByte('\n')
+	}
+	_, err := p.w.Write(buf.Bytes())
+	return err
+}
+
+func (p *Printer) header(buf *bytes.Buffer, pos string, d *Diagnostic) {
+	buf.WriteString(pos)
+	buf.WriteString(": ")
+	buf.WriteString(p.paint(severityColor(d.Severity), d.Severity.String()+":"))
+	buf.WriteByte(' ')
+	buf.WriteString(p.paint(colorBold, d.Msg))
+	buf.WriteByte('\n')
+}
+
+func severityColor(s Severity) string {
+	switch s {
+	case Warning:
+		return colorYel
+	case Note:
+		return colorCyan
+	}
+	return colorRed
+}
+
+// gutter writes the line number or the text aligned to the width.
+func (p *Printer) gutter(buf *bytes.Buffer, width int, text string) {
+	if text == "..." {
+		buf.WriteString(p.paint(colorBlue, text))
+		return
+	}
+	s := fmt.Sprintf("%*s |", width, text)
+	buf.WriteString(p.paint(colorBlue, s))
+}
+
+// underline writes the marker under the source line. The tabs before the
+// marker are kept to align with the source.
+func (p *Printer) underline(buf *bytes.Buffer, src []byte, m marker, s Severity) {
+	n := 0
+	for i := 0; i < len(src) && n < m.begin; n++ {
+		if src[i] == '\t' {
+			buf.WriteByte('\t')
+		} else {
+			buf.WriteByte(' ')
+		}
+		_, size := utf8.DecodeRune(src[i:])
+		i += size
+	}
+	for ; n < m.begin; n++ {
+		buf.WriteByte(' ')
+	}
+	c, color := "-", colorBlue
+	if m.primary {
+		c, color = "^", severityColor(s)
+	}
+	text := ""
+	for i := m.begin; i < m.end; i++ {
+		text += c
+	}
+	if m.msg != "" {
+		text += " " + m.msg
+	}
+	buf.WriteString(p.paint(color, text))
+}
+
+func (p *Printer) paint(color, s string) string {
+	if !p.Color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// marker returns the marker of the range. The range over multiple lines is
+// marked to the end of the first line, and the columns count characters.
+func (f *File) marker(pos, end int, msg string, primary bool) marker {
+	line, col := f.Position(pos)
+	src := f.Line(line)
+	begin := col - 1
+	if begin > len(src) {
+		begin = len(src)
+	}
+	stop := begin + end - pos
+	if stop < begin {
+		stop = begin
+	}
+	if stop > len(src) {
+		stop = len(src)
+	}
+	m := marker{
+		line:    line,
+		primary: primary,
+		begin:   utf8.RuneCount(src[:begin]),
+		msg:     msg,
+	}
+	m.end = m.begin + utf8.RuneCount(src[begin:stop])
+	if m.end <= m.begin {
+		m.end = m.begin + 1
+	}
+	return m
+}
+
+type byLine []marker
+
+func (l byLine) Len() int           { return len(l) }
+func (l byLine) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l byLine) Less(i, j int) bool { return l[i].line < l[j].line }
@@ -0,0 +1,229 @@
+// Package evaltest holds the scripts with the results expected by MRI,
+// which the interpreter and the VM are tested by.
+package evaltest
+
+import (
+	"strings"
+	"testing"
+)
+
+// Run runs the script and returns the output.
+type Run func(src string) (string, error)
+
+var outputs = map[string]string{
+	// literals and variables
+	`p 1, -2, 1.5, "a\tb", :c, [1, nil, true]`: "1\n-2\n1.5\n\"a\\tb\"\n:c\n[1, nil, true]\n",
+	`x = 2; puts "x=#{x * 3}"`:                 "x=6\n",
+	`a, *b, c = 1, 2, 3, 4; p a, b, c`:         "1\n[2, 3]\n4\n",
+	`a, (b, c), *d = 1, [2, 3], 4; (e, f), g = [5, [6]], 7; p [a, b, c, d, e, f, g]`: "[1, 2, 3, [4], 5, [6], 7]\n",
+	`a, b = [1, 2]; a, b = b, a; p [a, b]`:                                           "[2, 1]\n",
+	`x = 1 if false; p x`:                                                            "nil\n",
+	`$g = 1; $g += 1; p $g`:                                                          "2\n",
+	`A = 1; p A, Object::A, defined?(B)`:                                             "1\n1\nnil\n",
+	`p __LINE__, __FILE__`:                                                           "1\n\"t.rb\"\n",
+
+	// operators
+	`p 7 / -2, 7 % -2, 2 ** 10, 10.0 / 4`:                                      "-4\n-1\n1024\n2.5\n",
+	`p 9223372036854775807 + 1, 2 ** 64 / -3, -(2 ** 64) % 7, 1 << 70 >> 69`:   "9223372036854775808\n-6148914691236517206\n5\n2\n",
+	`p 1e20, 1e15, 0.00001, 2.675.round(2), 1.25.floor(1), 7.5.divmod(-2)`:     "1.0e+20\n1000000000000000.0\n1.0e-05\n2.68\n1.2\n[-4, -0.5]\n",
+	`p 2 ** 64 + 1 > 2.0 ** 64, 1250.round(-2), Float::INFINITY.infinite?`:     "true\n1300\n1\n",
+	`n = Float::NAN; p n.equal?(n), n == n, [n].include?(n), 0.0.equal?(-0.0)`: "true\nfalse\ntrue\nfalse\n",
+	`p Integer("0b101"), Integer("-0x1_F"), Integer("z", 36), 2.pow(100, 7)`:   "5\n-31\n35\n2\n",
+	`p !nil, (1 && 2), (nil || 3), 1 <=> 2`:                                    "true\n2\n3\n-1\n",
+	`a = nil; a ||= 1; a &&= a + 1; p a`:                                       "2\n",
+	`a = [1]; a[0] += 1; a[2] = 3; p a`:                                        "[2, nil, 3]\n",
+
+	// strings
+	`s = "héllo"; p s.length, s.bytesize, s[1, 3], s[-2, 5], s.b[1], s.encoding, __ENCODING__`: "5\n6\n\"éll\"\n\"lo\"\n\"\\xC3\"\n#<Encoding:UTF-8>\n#<Encoding:UTF-8>\n",
+	`s = "\xff"; p s.valid_encoding?, s.force_encoding("BINARY").valid_encoding?, s.encoding`:  "false\ntrue\n#<Encoding:ASCII-8BIT>\n",
+	`p "a,b,,c,,".split(","), " a  b c ".split(" ", 2), "abc".split("", -1)`:                   "[\"a\", \"b\", \"\", \"c\"]\n[\"a\", \"b c \"]\n[\"a\", \"b\", \"c\", \"\"]\n",
+	`p "hello".gsub("l", "[\\0]"), "abc".gsub("", "-"), "straße".upcase, "İ".downcase.length`:  "\"he[l][l]o\"\n\"-a-b-c-\"\n\"STRASSE\"\n2\n",
+	`p "%05.1f|%-4s|%+d|%x" % [3.14159, "é", 5, -255], "ab" * 2 + "c" << 100`:                  "\"003.1|é   |+5|..f01\"\n\"ababcd\"\n",
+	`s = "abc"; s[1] = "éé"; p s, s.encoding, "é".encode("UTF-8"), 255.chr.encoding`:           "\"aééc\"\n#<Encoding:UTF-8>\n\"é\"\n#<Encoding:ASCII-8BIT>\n",
+
+	// symbols
+	`p :upcase.to_proc.call("abc"), :a <=> :b, :b > :a, "ab".to_sym.equal?(:ab), :abc[1, 2]`: "\"ABC\"\n-1\ntrue\ntrue\n\"bc\"\n",
+	`p Symbol.all_symbols.include?(:zyx), :a.to_proc.lambda?, :a.to_proc.arity, :"a b"`:      "true\ntrue\n-2\n:\"a b\"\n",
+
+	// arrays and hashes
+	`a = [0, 1, 2, 3]; a[1, 2] = [:x]; p a, a[-1], a[1, 5], a[9, 1], [3, 1, 2].sort, [1, [2, [3]]].flatten(1)`:                                                                                               "[0, :x, 3]\n3\n[:x, 3]\nnil\n[1, 2, 3]\n[1, 2, [3]]\n",
+	`a = [1]; a << a; h = {a: 1, "b" => [2]}; h[:h] = h; p a, h`:                                                                                                                                             "[1, [...]]\n{:a=>1, \"b\"=>[2], :h=>{...}}\n",
+	`h = {[1, 2] => :a, 1.0 => :f}; p h[[1, 2]], h[1], h.key?(1.0), {**h, b: 2}.size`:                                                                                                                        ":a\nnil\ntrue\n3\n",
+	`h = Hash.new(0); h[:a] += 1; h.delete(:a); h[:b] = 2; h[:a] = 3; p h, h[:c], h.keys`:                                                                                                                    "{:b=>2, :a=>3}\n0\n[:b, :a]\n",
+	`k = [1]; h = {k => 1}; k << 2; p h[[1, 2]]; h.rehash; p h[[1, 2]], {}.compare_by_identity.compare_by_identity?`:                                                                                         "nil\n1\ntrue\n",
+	`p [1, 2, 2, 3].uniq, [1, 2] - [2], [0.1, 0.2, 0.3].sum, [[1, :a]].to_h, [1, [2, 3]].join("-"), "%{a}" % {a: 1}`:                                                                                         "[1, 2, 3]\n[1]\n0.6\n{1=>:a}\n\"1-2-3\"\n\"1\"\n",
+	`h = {a: 1, b: 2}; p h.map { |k, v| v * 2 }, h.find { |k, v| v > 1 }, h.sort_by { |k, v| -v }, h.min_by { |k, v| v }, h.sum { |k, v| v }`:                                                                "[2, 4]\n[:b, 2]\n[[:b, 2], [:a, 1]]\n[:a, 1]\n3\n",
+	`h = {a: 1, b: 2}; p h.group_by { |k, v| v.odd? }, h.inject(0) { |s, (k, v)| s + v }, h.each_with_object([]) { |(k, v), a| a << k }`:                                                                     "{true=>[[:a, 1]], false=>[[:b, 2]]}\n3\n[:a, :b]\n",
+	`class C; include Enumerable; def each; yield 1; yield 2; yield 3; ensure; print "e"; end; end; c = C.new; p c.map { |x| x * 2 }, c.find { |x| x > 1 }, c.first(2), c.include?(5), c.sort_by { |x| -x }`: "eeeee[2, 4, 6]\n2\n[1, 2]\nfalse\n[3, 2, 1]\n",
+
+	// ranges
+	`p 1...3, (1..), (..5), [*1..3], ("az".."bc").to_a, (1..100).sum`:                                                                                                               "1...3\n1..\n..5\n[1, 2, 3]\n[\"az\", \"ba\", \"bb\", \"bc\"]\n5050\n",
+	`p ("a".."z").include?("bb"), ("a".."z").cover?("bb"), (1..).include?(5), (1...10).max, (1..10).cover?(2...11)`:                                                                 "false\ntrue\ntrue\n9\ntrue\n",
+	`case 7 when 1..5 then p 1 when 6.. then p 2 end`:                                                                                                                               "2\n",
+	`a = [0, 1, 2, 3, 4]; a[1..2] = :x; p a, a[2..], a[..-3], "hello"[1...-1]`:                                                                                                      "[0, :x, 3, 4]\n[3, 4]\n[0, :x]\n\"ell\"\n",
+	`i = 0; r = []; while i < 9; i += 1; r << i if (i % 4 == 1)..(i % 4 == 2); end; p r`:                                                                                            "[1, 2, 5, 6, 9]\n",
+	`i = 0; r = []; while i < 9; i += 1; r << i if (i == 2)...(i == 2) or not (i == 1..i == 1); end; p r`:                                                                           "[2, 3, 4, 5, 6, 7, 8, 9]\n",
+	`(1..20).each { |i| print i if (i == 5)..(i == 8) }; def f; r = []; 5.times { |i| [0].each { r << i if (i == 1)...(i == 1) } }; r; end; p f, f`:                                 "5678[1, 2, 3, 4]\n[1, 2, 3, 4]\n",
+	`p (1..10**8).any? { |x| x > 3 }, (1..).all? { |x| x < 3 }, (1..).find { |x| x * x > 50 }, (1..).first(3), ("a"..).take(2), (1..).each_with_index { |x, i| break x if i == 2 }`: "true\nfalse\n8\n[1, 2, 3]\n[\"a\", \"b\"]\n3\n",
+
+	// conditionals and loops
+	`p(if 1 > 2 then :a elsif 2 > 1 then :b end)`:                                        ":b\n",
+	`p(unless true then 1 else 2 end, 1 ? 2 : 3)`:                                        "2\n2\n",
+	`case 5 when String then p 1 when 1, Integer then p 2 end`:                           "2\n",
+	`i = 0; while i < 5; i += 1; next if i.odd?; print i; end; puts`:                     "24\n",
+	`i = 0; i += 1 until i == 3; p i`:                                                    "3\n",
+	`i = 0; begin i += 1 end while i < 0; p i`:                                           "1\n",
+	`p(while true do break 5 end)`:                                                       "5\n",
+	`i = 0; r = false; while i < 2; i += 1; unless r; r = true; redo; end; print i; end`: "2",
+
+	// methods
+	`def f(n) n < 2 ? n : f(n - 1) + f(n - 2) end; p f(15)`:                "610\n",
+	`def m(a, b = 2, *r, c) [a, b, r, c] end; p m(1, 9), m(1, 2, 3, 4, 5)`: "[1, 2, [], 9]\n[1, 2, [3, 4], 5]\n",
+	`def f(a) return a, 1 if a; 2 end; p f(3), f(nil)`:                     "[3, 1]\n2\n",
+	`def f; i = 0; while true; return i if i > 2; i += 1; end; end; p f`:   "3\n",
+	`p self, self.class`: "main\nObject\n",
+
+	// classes
+	`class A; attr_accessor :x; def initialize(x) @x = x end; end; a = A.new(1); a.x += 1; p a.x`:                                                                                                            "2\n",
+	`class A; def f; g; end; private; def g; :g; end; end; p A.new.f`:                                                                                                                                        ":g\n",
+	`class A; def f(o) o.g end; protected; def g; :g end; end; class B < A; end; p B.new.f(A.new)`:                                                                                                           ":g\n",
+	`class A; def f(o) self.x = 1; begin o.x = 2; rescue NoMethodError; :err end end; private; def x=(v) end; end; p A.new.f(A.new)`:                                                                         ":err\n",
+	`class A; def to_s; "a"; end; end; class B < A; end; puts B.new; p B.superclass`:                                                                                                                         "a\nA\n",
+	`module M; X = 1; class C; def x; X; end; end; end; p M::C.new.x, M::C`:                                                                                                                                  "1\nM::C\n",
+	`class A; def a; 1; end; alias b a; undef a; end; p A.new.b, A.new.respond_to?(:a)`:                                                                                                                      "1\nfalse\n",
+	`A = Class.new { |c| p c == self; def f; :f; end }; p A.new.f, A.name`:                                                                                                                                   "true\n:f\n\"A\"\n",
+	`class V; include Comparable; attr_reader :n; def initialize(n) @n = n end; def <=>(o) n <=> o.n end; end; a, b = V.new(1), V.new(2); p a < b, a >= b, a == V.new(1), a.between?(a, b), a.clamp(b, b).n`: "true\nfalse\ntrue\ntrue\n2\n",
+	`p 5.clamp(1, 3), 0.clamp(1..3), "b".between?("a", "c")`:                                                                                                                                                 "3\n1\ntrue\n",
+
+	// exceptions
+	`begin; raise ArgumentError, "x"; rescue TypeError; p 1; rescue => e; p e; ensure; p 2; end`: "#<ArgumentError: x>\n2\n",
+	`n = 0; begin; n += 1; raise "e" if n < 3; rescue; retry; end; p n`:                          "3\n",
+	`p((raise "x" rescue 1))`:                    "1\n",
+	`x = begin; 1; rescue; 2; else; 3; end; p x`: "3\n",
+
+	// jumps through ensure and rescue clauses
+	`i = 0; while true; begin; i += 1; next if i < 2; break; ensure; print i; end; end; puts`: "12\n",
+	`def f; begin; return 1; ensure; p 2; end; end; p f`:                                      "2\n1\n",
+	`def f; begin; raise "x"; rescue; return 1; end; end; p f`:                                "1\n",
+	`x = [1, 2].size; p x; p((p 1; 2) + 3)`:                                                   "2\n1\n5\n",
+
+	// blocks
+	`def f; yield 1; yield 2; end; x = 10; f { |i| x += i }; p x`:                                                                                                                          "13\n",
+	`def g; block_given?; end; def h(&b) b; end; p g, g {}, h, h { |a, b| [a, b] }.call([1, 2]), [1, 2].map(&:to_s)`:                                                                       "false\ntrue\nnil\n[1, 2]\n[\"1\", \"2\"]\n",
+	`pr = proc { |a, b| [a, b] }; p pr.call(1), pr.call(1, 2, 3), pr.call([3, 4]), pr.arity, proc { |*a| a }.call([1])`:                                                                    "[1, nil]\n[1, 2]\n[3, 4]\n2\n[[1]]\n",
+	`p proc { |x, y = 2| }.arity, lambda { |x, y = 2| }.arity, ->(*a, b) {}.arity, proc(&->() {}).lambda?`:                                                                                 "1\n-2\n-2\ntrue\n",
+	`def f; [1, 2, 3].each { |i| return i * 10 if i == 2 }; end; def g; [yield, :after]; end; p f, g { break 1 }`:                                                                          "20\n1\n",
+	`p [1, 2, 3].map { |i| next 0 if i == 2; i }, ->(a) { return a + 1; 0 }.(1), lambda { break 3 }.call`:                                                                                  "[1, 0, 3]\n2\n3\n",
+	`add = ->(a, b, c) { a + b + c }; p add.curry[1][2][3], add.curry.(1, 2).(3), proc { |a, b| [a, b] }.curry[1][2]`:                                                                      "6\n6\n[1, 2]\n",
+	`p ->(a, b = 1, *c, d, &e) {}.parameters, proc { |a, (b, c)| [a, b, c] }.call(1, [2, 3]), proc { |a| }.parameters`:                                                                     "[[:req, :a], [:opt, :b], [:rest, :c], [:req, :d], [:block, :e]]\n[1, 2, 3]\n[[:opt, :a]]\n",
+	`def c; n = 0; [-> { n += 1 }, -> { n }]; end; inc, get = c; inc.(); inc.(); p get.(), defined?(n)`:                                                                                    "2\nnil\n",
+	`def f; begin; yield; ensure; puts "e"; end; end; def g; f { return 1 }; 2; end; p g, f { break 3 }`:                                                                                   "e\ne\n1\n3\n",
+	`fib = ->(n) { n < 2 ? n : fib.(n - 1) + fib.(n - 2) }; p fib.(10)`:                                                                                                                    "55\n",
+	`class A; def f(x) yield x; end; end; class B < A; def f(x) super; end; end; class C < A; def f(x) super(x + 1) { |v| v * 3 }; end; end; p B.new.f(3) { |v| v * 2 }, C.new.f(1) { 0 }`: "6\n6\n",
+	`i = 0; p [1].map { i += 1; redo if i < 3; i }, [[1, 2], [3, 4]].map { |a, b| a + b }`:                                                                                                 "[3]\n[3, 7]\n",
+	`def f(&b) b; end; def g; proc { return 1 }; end; b = f { break 1 }; [b, g].each { |pr| begin; pr.call; rescue LocalJumpError => e; p e.message; end }`:                                "\"break from proc-closure\"\n\"unexpected return\"\n",
+	`3.times { |i| print i }; 1.upto(3) { |i| print i }; 3.downto(1) { |i| print i }; p 0.times { p 0 }`:                                                                                   "0121233210\n",
+
+	// keyword arguments
+	`def f(a, k: 1, j:) [a, k, j]; end; def g(k: 1, **o) [k, o]; end; p f(0, j: 2), f(0, k: 3, j: 4), g, g(k: 2, x: 3)`:                       "[0, 1, 2]\n[0, 3, 4]\n[1, {}]\n[2, {:x=>3}]\n",
+	`def h(a = 5, k: a * 2) [a, k]; end; p h, h(1), h(1, k: 0), ->(a, k: 1, j:) {}.arity, ->(k: 1) {}.arity`:                                  "[5, 10]\n[1, 2]\n[1, 0]\n2\n-1\n",
+	`pr = proc { |a, k: 1| [a, k] }; p pr.call(1, k: 2), pr.call(3), [[1, 2]].map { |a, b, k: 0| a + b + k }`:                                 "[1, 2]\n[3, 1]\n[3]\n",
+	`class A; def f(a, k: 1, **o) [a, k, o]; end; end; class B < A; def f(a, k: 2, **) super; end; end; p B.new.f(0), B.new.f(0, k: 5, z: 1)`: "[0, 2, {}]\n[0, 5, {:z=>1}]\n",
+
+	// object model
+	`module A; def f; [:A]; end; end; module B; include A; def f; [:B] + super; end; end; module C; include A; def f; [:C] + super; end; end; class D; include B, C; def f; [:D] + super; end; end; p D.ancestors, D.new.f`: "[D, B, C, A, Object, Kernel, BasicObject]\n[:D, :B, :C, :A]\n",
+	`module P; def f; [:P] + super; end; end; class A; def f; [:A]; end; end; class B < A; prepend P; def f(*) [:B] + super; end; end; p B.ancestors[0, 3], B.new.f`:                                                        "[P, B, A]\n[:P, :B, :A]\n",
+	`class A; def self.make; new; end; class << self; def name2; "A2"; end; end; end; class B < A; end; p B.make.class, B.name2, B.singleton_class`:                                                                         "B\n\"A2\"\n#<Class:B>\n",
+	`o = Object.new; def o.f; 1; end; module M; def g; 2; end; end; o.extend(M); p o.f + o.g, o.singleton_methods, o.is_a?(M)`:                                                                                              "3\n[:f]\ntrue\n",
+	`class A; @@n = 0; def self.inc; @@n += 1; end; end; class B < A; def n; @@n; end; end; B.inc; A.inc; p B.new.n, defined?(@@x)`:                                                                                         "2\nnil\n",
+	`class A; def f(a, b = 1) [a, b]; end; end; class B < A; def f(a, b = 2) a = 0; super; end; end; p B.new.f(5)`:                                                                                                          "[0, 2]\n",
+
+	// optimized operators
+	`p 9223372036854775806 + 1, 7.0 / 2, [1, 2][-1], 5 % -3`:            "9223372036854775807\n3.5\n2\n-1\n",
+	`class Integer; def +(o) 0; end; end; p 1 + 2`:                      "0\n",
+	`a = [1]; a[0] ||= 2; a[1] ||= 3; p a; def g(*a) a end; p g(*a, 4)`: "[1, 3]\n[1, 3, 4]\n",
+}
+
+var errs = map[string]string{
+	`foo`:                             "undefined local variable or method `foo' for main:Object (NameError)",
+	`nil.foo`:                         "undefined method `foo' for nil:NilClass (NoMethodError)",
+	`foo()`:                           "undefined method `foo' for main:Object (NoMethodError)",
+	`foo { }`:                         "undefined method `foo' for main:Object (NoMethodError)",
+	`1 / 0`:                           "divided by 0 (ZeroDivisionError)",
+	`def f(a) end; f`:                 "wrong number of arguments (given 0, expected 1) (ArgumentError)",
+	`def f(a, b = 1) end; f(1, 2, 3)`: "wrong number of arguments (given 3, expected 1..2) (ArgumentError)",
+	`class A; private; def f; end; end; A.new.f`:      "private method `f' called for",
+	`class A; protected; def f; end; end; A.new.f`:    "protected method `f' called for",
+	`class A; include Comparable; end; A.new < A.new`: "comparison of A with A failed (ArgumentError)",
+	`1.clamp(3, 1)`: "min argument must be smaller than max argument (ArgumentError)",
+	`class A; private; def x=(v) end; end; A.new.x = 1`: "private method `x=' called for",
+	`raise "oops"`:                         "oops (RuntimeError)",
+	`class A; end; raise A`:                "exception class/object expected (TypeError)",
+	`X`:                                    "uninitialized constant X (NameError)",
+	`"a".freeze << "b"`:                    "can't modify frozen String: \"a\" (FrozenError)",
+	`def f; f; end; f`:                     "stack level too deep (SystemStackError)",
+	`Integer("0b102")`:                     "invalid value for Integer(): \"0b102\" (ArgumentError)",
+	`1.0 % 0`:                              "divided by 0 (ZeroDivisionError)",
+	`0 ** -1`:                              "divided by 0 (ZeroDivisionError)",
+	`(0.0 / 0).round`:                      "NaN (FloatDomainError)",
+	`:upcase.to_proc.call`:                 "no receiver given (ArgumentError)",
+	`[1, "a"].sort`:                        "comparison of Integer with String failed (ArgumentError)",
+	`{a: 1}.fetch(:b)`:                     "key not found: :b (KeyError)",
+	`a = []; a << a; a.flatten`:            "tried to flatten recursive array (ArgumentError)",
+	`[1][-2, 1] = 0`:                       "index -2 too small for array; minimum: -1 (IndexError)",
+	`(1..).to_a`:                           "cannot convert endless range to an array (RangeError)",
+	`1.."a"`:                               "bad value for range (ArgumentError)",
+	`[1][-3..] = 0`:                        "-3.. out of range (RangeError)",
+	`(1.0..2).size`:                        "can't iterate from Float (TypeError)",
+	`"é".encode("US-ASCII")`:               "U+00E9 from UTF-8 to US-ASCII (Encoding::UndefinedConversionError)",
+	`"é" + "\xff".b`:                       "incompatible character encodings: UTF-8 and ASCII-8BIT (Encoding::CompatibilityError)",
+	`"a".freeze.upcase!`:                   "can't modify frozen String: \"a\" (FrozenError)",
+	`"abc"[5] = "x"`:                       "index 5 out of string (IndexError)",
+	`format("%d %d", 1)`:                   "too few arguments (ArgumentError)",
+	`1 + "a"`:                              "String can't be coerced into Integer (TypeError)",
+	`break`:                                "unexpected break (LocalJumpError)",
+	`def f; yield; end; f`:                 "no block given (yield) (LocalJumpError)",
+	`->(a, b) {}.call(1)`:                  "wrong number of arguments (given 1, expected 2) (ArgumentError)",
+	`->(a) {}.curry(2)`:                    "wrong number of arguments (given 2, expected 1) (ArgumentError)",
+	`Proc.new`:                             "tried to create Proc object without a block (ArgumentError)",
+	`def f(a, k:) end; f(0)`:               "missing keyword: :k (ArgumentError)",
+	`def f(a:, b:) end; f`:                 "missing keywords: :a, :b (ArgumentError)",
+	`def f(k: 1) end; f(k: 2, x: 3, y: 4)`: "unknown keywords: :x, :y (ArgumentError)",
+	`def f(k: 1) end; f(1)`:                "wrong number of arguments (given 1, expected 0) (ArgumentError)",
+	`1.upto("a") {}`:                       "comparison of Integer with String failed (ArgumentError)",
+	`[1].map(&1)`:                          "wrong argument type Integer (expected Proc) (TypeError)",
+	`retry`:                                "retry outside of rescue clause (LocalJumpError)",
+	`@@a`:                                  "class variable access from toplevel (RuntimeError)",
+	`class A; def f; super; end; end; A.new.f`: "super: no superclass method `f' for",
+	`class A; p @@x; end`:                      "uninitialized class variable @@x in A (NameError)",
+	`module M; end; M.include(M)`:              "cyclic include detected (ArgumentError)",
+}
+
+// Outputs tests the outputs of the scripts.
+func Outputs(t *testing.T, run Run) {
+	for src, want := range outputs {
+		got, err := run(src)
+		if err != nil {
+			t.Errorf("%q: err=%v (want=nil)", src, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: output=%q (want=%q)", src, got, want)
+		}
+	}
+}
+
+// Errors tests the errors of the scripts, which start with the messages
+// expected.
+func Errors(t *testing.T, run Run) {
+	for src, want := range errs {
+		_, err := run(src)
+		if err == nil {
+			t.Errorf("%q: err=nil (want=%v)", src, want)
+			continue
+		}
+		if got := err.Error(); !strings.HasPrefix(got, want) {
+			t.Errorf("%q: err=%v (want=%v)", src, got, want)
+		}
+	}
+}
@@ -0,0 +1,752 @@
+package interp
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/literal"
+	"github.com/harukasan/ringo/object"
+	"github.com/harukasan/ringo/token"
+)
+
+// eval evaluates the expression.
+func (in *Interp) eval(fr *frame, x ast.Expr) object.Value {
+	rt := in.rt
+	switch x := x.(type) {
+	case *ast.NumberLit:
+		return in.number(x)
+	case *ast.StrLit:
+		return rt.NewString(x.Value)
+	case *ast.InterpStr:
+		return in.interpolate(fr, x.Parts)
+	case *ast.Heredoc:
+		return in.interpolate(fr, x.Parts)
+	case *ast.SymbolLit:
+		return object.Symbol(in.id(x))
+	case *ast.InterpSymbol:
+		return object.SymbolOf(string(in.interpolate(fr, x.Parts).B))
+	case *ast.ArrayLit:
+		return rt.NewArray(in.evalList(fr, x.Elems))
+	case *ast.PseudoVar:
+		return in.pseudoVar(fr, x)
+
+	case *ast.LocalVar:
+		if v, ok := fr.lookup(x.Name); ok {
+			return v
+		}
+		return object.Nil
+	case *ast.InstanceVar:
+		return rt.Ivar(fr.self, in.id(x))
+	case *ast.GlobalVar:
+		if v, ok := rt.Globals[x.Name]; ok {
+			return v
+		}
+		return object.Nil
+	case *ast.Const:
+		return in.constGet(fr, x)
+
+	case *ast.Assign:
+		return in.assign(fr, x)
+	case *ast.MultiAssign:
+		v := in.eval(fr, x.Rhs)
+		in.multiAssign(fr, x.Lhs, v)
+		return v
+	case *ast.Binary:
+		return in.binary(fr, x)
+	case *ast.Unary:
+		if x.Op == token.Not || x.Op == token.KeywordNot {
+			return rt.CallFrom(fr.self, in.cond(fr, x.X), idNot, nil, nil)
+		}
+		return rt.CallFrom(fr.self, in.eval(fr, x.X), in.id(x), nil, nil)
+	case *ast.Defined:
+		if s := in.defined(fr, x.X); s != "" {
+			return rt.NewString(s)
+		}
+		return object.Nil
+
+	case *ast.Call:
+		return in.call(fr, x)
+	case *ast.Index:
+		recv := in.eval(fr, x.Recv)
+		return rt.CallFrom(fr.self, recv, idAref, in.evalList(fr, x.Args), nil)
+
+	case *ast.Def:
+		return in.def(fr, x)
+	case *ast.ClassDef:
+		return in.classDef(fr, x)
+	case *ast.ModuleDef:
+		return in.moduleDef(fr, x)
+	case *ast.Alias:
+		return in.alias(fr, x)
+	case *ast.Undef:
+		for _, name := range x.Names {
+			rt.UndefMethod(fr.scope.class, in.symbolName(fr, name))
+		}
+		return object.Nil
+
+	case *ast.If:
+		if object.Truthy(in.cond(fr, x.Cond)) != x.Unless {
+			return in.evalStmts(fr, x.Then)
+		}
+		switch e := x.Else.(type) {
+		case *ast.Stmts:
+			return in.evalStmts(fr, e)
+		case *ast.If:
+			return in.eval(fr, e)
+		}
+		return object.Nil
+	case *ast.Ternary:
+		if object.Truthy(in.cond(fr, x.Cond)) {
+			return in.eval(fr, x.Then)
+		}
+		return in.eval(fr, x.Else)
+	case *ast.While:
+		return in.while(fr, x)
+	case *ast.Case:
+		return in.caseExpr(fr, x)
+	case *ast.Return:
+		v := in.jumpValue(fr, x.Args)
+		target := fr.returnFrame()
+		if target.done || target.classBody() {
+			rt.Raise(rt.LocalJumpError, "unexpected return")
+		}
+		panic(&returnJump{val: v, frame: target})
+	case *ast.Break:
+		panic(&loopJump{kind: "break", val: in.jumpValue(fr, x.Args)})
+	case *ast.Next:
+		panic(&loopJump{kind: "next", val: in.jumpValue(fr, x.Args)})
+	case *ast.Redo:
+		panic(&loopJump{kind: "redo"})
+	case *ast.Retry:
+		panic(&retryJump{})
+	case *ast.Begin:
+		return in.evalBodyStmt(fr, x.Body)
+	case *ast.RescueMod:
+		return in.rescueMod(fr, x)
+	case *ast.Paren:
+		return in.evalStmts(fr, x.Body)
+
+	case *ast.HashLit:
+		return in.hash(fr, x)
+	case *ast.RangeLit:
+		low, high := object.Value(object.Nil), object.Value(object.Nil)
+		if x.Low != nil {
+			low = in.eval(fr, x.Low)
+		}
+		if x.High != nil {
+			high = in.eval(fr, x.High)
+		}
+		return rt.NewRange(low, high, x.Exclusive)
+	case *ast.RegexpLit:
+		rt.NotImplemented("Regexp")
+	case *ast.XStr:
+		rt.NotImplemented("command output")
+	case *ast.Super:
+		return in.super(fr, x)
+	case *ast.Yield:
+		return rt.Yield(fr.blk, in.evalList(fr, x.Args)...)
+	case *ast.ClassVar:
+		return rt.CvarGet(in.cvarBase(fr), x.Name)
+	case *ast.SingletonClassDef:
+		c := rt.SingletonClassOf(in.eval(fr, x.Target))
+		return in.evalBody(fr, c, x.Body)
+	case *ast.For:
+		rt.NotImplemented("for loop")
+	case *ast.Splat, *ast.BlockPass:
+		rt.NotImplemented(nodeName(x) + " here")
+	}
+	rt.NotImplemented(nodeName(x))
+	return nil
+}
+
+// nodeName returns the name of the node type such as Splat.
+func nodeName(x ast.Node) string {
+	switch x.(type) {
+	case *ast.Splat:
+		return "splat"
+	case *ast.BlockPass:
+		return "block argument"
+	}
+	return "expression"
+}
+
+// evalStmts evaluates the expressions and returns the value of the last
+// one.
+func (in *Interp) evalStmts(fr *frame, stmts *ast.Stmts) object.Value {
+	var v object.Value = object.Nil
+	if stmts == nil {
+		return v
+	}
+	for _, x := range stmts.List {
+		v = in.eval(fr, x)
+	}
+	return v
+}
+
+// evalList evaluates the elements of arrays or arguments, expanding the
+// splats.
+func (in *Interp) evalList(fr *frame, list []ast.Expr) []object.Value {
+	vals := make([]object.Value, 0, len(list))
+	for _, x := range list {
+		switch x := x.(type) {
+		case *ast.Splat:
+			if x.Double {
+				h := in.rt.NewHash()
+				in.rt.MergeHash(h, in.eval(fr, x.Value))
+				vals = append(vals, h)
+				continue
+			}
+			vals = append(vals, in.splat(in.eval(fr, x.Value))...)
+		case *ast.BlockPass:
+			in.rt.NotImplemented("block argument")
+		default:
+			vals = append(vals, in.eval(fr, x))
+		}
+	}
+	return vals
+}
+
+// hash evaluates the hash literal, merging the double splats.
+func (in *Interp) hash(fr *frame, x *ast.HashLit) object.Value {
+	h := in.rt.NewHash()
+	for _, p := range x.Pairs {
+		if p.Key == nil {
+			in.rt.MergeHash(h, in.eval(fr, p.Value))
+			continue
+		}
+		k := in.eval(fr, p.Key)
+		in.rt.HashSet(h, k, in.eval(fr, p.Value))
+	}
+	return h
+}
+
+// splat returns the elements of the value expanded by *.
+func (in *Interp) splat(v object.Value) []object.Value {
+	switch v := v.(type) {
+	case *object.RArray:
+		return v.Elems
+	}
+	if v == object.Nil {
+		return nil
+	}
+	if in.rt.RespondTo(v, "to_a") {
+		if a, ok := in.rt.Send(v, "to_a").(*object.RArray); ok {
+			return a.Elems
+		}
+	}
+	return []object.Value{v}
+}
+
+// number returns the value of the numeric literal.
+func (in *Interp) number(x *ast.NumberLit) object.Value {
+	if v, ok := in.nums[x]; ok {
+		return v
+	}
+	n, err := literal.Parse(x.Kind, []byte(x.Lit))
+	if err != nil {
+		in.rt.Raise(in.rt.ArgumentError, "%s", err.Error())
+	}
+	var v object.Value
+	switch n := n.(type) {
+	case int64:
+		v = object.Fixnum(n)
+	case float64:
+		v = object.Float(n)
+	case *big.Int:
+		v = object.NewInteger(n)
+	case *big.Rat:
+		in.rt.NotImplemented("Rational")
+	case literal.Complex:
+		in.rt.NotImplemented("Complex")
+	}
+	in.nums[x] = v
+	return v
+}
+
+// IDs of the methods called by the syntax
+var (
+	idNot   = object.Intern("!")
+	idAref  = object.Intern("[]")
+	idAset  = object.Intern("[]=")
+	idMatch = object.Intern("=~")
+	idEqq   = object.Intern("===")
+)
+
+// id returns the ID of the name of the node, which is interned once for
+// each node as the number literals are converted once. The operators are
+// named by the methods called.
+func (in *Interp) id(x ast.Node) object.ID {
+	if id, ok := in.ids[x]; ok {
+		return id
+	}
+	var name string
+	switch x := x.(type) {
+	case *ast.SymbolLit:
+		name = x.Name
+	case *ast.InstanceVar:
+		name = x.Name
+	case *ast.GlobalVar:
+		name = x.Name
+	case *ast.Call:
+		name = x.Name
+	case *ast.Def:
+		name = x.Name
+	case *ast.Param:
+		name = x.Name
+	case *ast.Unary:
+		switch x.Op {
+		case token.Minus:
+			name = "-@"
+		case token.Plus:
+			name = "+@"
+		default:
+			name = x.Op.Text()
+		}
+	case *ast.Binary:
+		name = x.Op.Text()
+	case *ast.Assign:
+		name = x.Op.BaseOperator().Text()
+	}
+	id := object.Intern(name)
+	in.ids[x] = id
+	return id
+}
+
+// setter returns the ID of the attribute assignment such as name=.
+func (in *Interp) setter(x *ast.Call) object.ID {
+	if id, ok := in.setters[x]; ok {
+		return id
+	}
+	id := object.Intern(x.Name + "=")
+	in.setters[x] = id
+	return id
+}
+
+// interpolate returns the string of the parts, which are *ast.StrLit or
+// *ast.Insert.
+func (in *Interp) interpolate(fr *frame, parts []ast.Expr) *object.RString {
+	var buf bytes.Buffer
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *ast.StrLit:
+			buf.WriteString(p.Value)
+		case *ast.Insert:
+			buf.WriteString(in.rt.ToS(in.evalStmts(fr, p.Body)))
+		default:
+			buf.WriteString(in.rt.ToS(in.eval(fr, p)))
+		}
+	}
+	return &object.RString{B: buf.Bytes()}
+}
+
+func (in *Interp) pseudoVar(fr *frame, x *ast.PseudoVar) object.Value {
+	switch x.Kind {
+	case token.KeywordSelf:
+		return fr.self
+	case token.KeywordTrue:
+		return object.True
+	case token.KeywordFalse:
+		return object.False
+	case token.KeywordFILE:
+		return in.rt.NewString(in.file)
+	case token.KeywordLINE:
+		return object.Fixnum(in.line(x.Start))
+	case token.KeywordENCODING:
+		return in.rt.EncodingObject(object.UTF8)
+	}
+	return object.Nil
+}
+
+// constGet returns the constant. The constant without scope is looked up
+// in the lexical scopes, and then the ancestors of the current class.
+func (in *Interp) constGet(fr *frame, x *ast.Const) object.Value {
+	rt := in.rt
+	var c *object.RClass
+	switch {
+	case x.Top:
+		c = rt.Object
+	case x.Scope != nil:
+		v := in.eval(fr, x.Scope)
+		k, ok := v.(*object.RClass)
+		if !ok {
+			rt.Raise(rt.TypeError, "%s is not a class/module", rt.Inspect(v))
+		}
+		c = k
+	default:
+		for s := fr.scope; s.parent != nil; s = s.parent {
+			if v, ok := s.class.Consts[x.Name]; ok {
+				return v
+			}
+		}
+		c = fr.scope.class
+		if v, ok := rt.ConstGet(c, x.Name); ok {
+			return v
+		}
+		if v, ok := rt.Object.Consts[x.Name]; ok {
+			return v
+		}
+		rt.ConstMissing(c, x.Name)
+	}
+	v, ok := rt.ConstGet(c, x.Name)
+	if !ok {
+		rt.ConstMissing(c, x.Name)
+	}
+	return v
+}
+
+// constScope returns the class where the constant is defined.
+func (in *Interp) constScope(fr *frame, x *ast.Const) *object.RClass {
+	switch {
+	case x.Top:
+		return in.rt.Object
+	case x.Scope != nil:
+		v := in.eval(fr, x.Scope)
+		c, ok := v.(*object.RClass)
+		if !ok {
+			in.rt.Raise(in.rt.TypeError, "%s is not a class/module", in.rt.Inspect(v))
+		}
+		return c
+	}
+	return fr.scope.class
+}
+
+// binary evaluates the binary operation, which is a method call except for
+// the logical operators.
+func (in *Interp) binary(fr *frame, x *ast.Binary) object.Value {
+	rt := in.rt
+	switch x.Op {
+	case token.AndOperator, token.KeywordAnd:
+		v := in.eval(fr, x.X)
+		if !object.Truthy(v) {
+			return v
+		}
+		return in.eval(fr, x.Y)
+	case token.OrOperator, token.KeywordOr:
+		v := in.eval(fr, x.X)
+		if object.Truthy(v) {
+			return v
+		}
+		return in.eval(fr, x.Y)
+	}
+	a := in.eval(fr, x.X)
+	b := in.eval(fr, x.Y)
+	switch x.Op {
+	case token.NotMatch:
+		return object.Bool(!object.Truthy(rt.CallFrom(fr.self, a, idMatch, []object.Value{b}, nil)))
+	}
+	return rt.CallFrom(fr.self, a, in.id(x), []object.Value{b}, nil)
+}
+
+// cond evaluates the condition of if, unless, while, until, the ternary
+// operator or the not operator, where the range literals are the
+// flip-flops.
+func (in *Interp) cond(fr *frame, x ast.Expr) object.Value {
+	switch x := x.(type) {
+	case *ast.RangeLit:
+		if x.Low != nil && x.High != nil {
+			return object.Bool(in.flipFlop(fr, x))
+		}
+	case *ast.Binary:
+		switch x.Op {
+		case token.AndOperator, token.KeywordAnd:
+			if v := in.cond(fr, x.X); !object.Truthy(v) {
+				return v
+			}
+			return in.cond(fr, x.Y)
+		case token.OrOperator, token.KeywordOr:
+			if v := in.cond(fr, x.X); object.Truthy(v) {
+				return v
+			}
+			return in.cond(fr, x.Y)
+		}
+	case *ast.Paren:
+		if len(x.Body.List) == 1 {
+			return in.cond(fr, x.Body.List[0])
+		}
+	}
+	return in.eval(fr, x)
+}
+
+// flipFlop evaluates the flip-flop, which turns on when the beginning
+// condition holds, and turns off after the end condition holds. The end
+// condition of a..b is checked also when it turns on, but not of a...b.
+// The state is kept in the frame of the method, so that it lasts over the
+// calls of the block.
+func (in *Interp) flipFlop(fr *frame, x *ast.RangeLit) bool {
+	local := fr.localFrame()
+	if !local.flips[x] {
+		if !object.Truthy(in.cond(fr, x.Low)) {
+			return false
+		}
+		if local.flips == nil {
+			local.flips = map[*ast.RangeLit]bool{}
+		}
+		local.flips[x] = true
+		if x.Exclusive {
+			return true
+		}
+	}
+	if object.Truthy(in.cond(fr, x.High)) {
+		local.flips[x] = false
+	}
+	return true
+}
+
+// args evaluates the arguments of method call, and returns the block
+// passed by & as well.
+func (in *Interp) args(fr *frame, list []ast.Expr) ([]object.Value, object.Value) {
+	if n := len(list); n > 0 {
+		if b, ok := list[n-1].(*ast.BlockPass); ok {
+			args := in.evalList(fr, list[:n-1])
+			return args, in.rt.BlockArg(in.eval(fr, b.Value))
+		}
+	}
+	return in.evalList(fr, list), nil
+}
+
+// call evaluates the method call. The block literal is given as a proc,
+// whose break returns from the call.
+func (in *Interp) call(fr *frame, x *ast.Call) object.Value {
+	rt := in.rt
+	var recv object.Value
+	fcall := x.Recv == nil
+	if fcall {
+		recv = fr.self
+	} else {
+		recv = in.eval(fr, x.Recv)
+		if p, ok := x.Recv.(*ast.PseudoVar); ok && p.Kind == token.KeywordSelf {
+			fcall = true // private methods can be called by self.
+		}
+	}
+	args, blk := in.args(fr, x.Args)
+	if x.Recv == nil && len(args) == 0 && fr.method == nil && blk == nil && x.Block == nil {
+		if v, ok := visibilities[x.Name]; ok {
+			if _, ok := fr.self.(*object.RClass); ok {
+				fr.visibility = v
+				return object.Nil
+			}
+		}
+	}
+	if x.Recv == nil && len(x.Args) == 0 && !x.Parens && x.Block == nil {
+		m := rt.FindMethod(rt.ClassOf(recv), in.id(x))
+		if m == nil {
+			rt.RaiseNoMethod(recv, in.id(x), true)
+		}
+		return rt.CallMethod(m, recv, nil, nil)
+	}
+	if x.Block != nil {
+		return in.withBlock(fr, x.Block, func(blk object.Value) object.Value {
+			return in.send(fr, recv, in.id(x), args, blk, fcall)
+		})
+	}
+	return in.send(fr, recv, in.id(x), args, blk, fcall)
+}
+
+// send calls the method with the receiver omitted or self if fcall is true,
+// or with the explicit receiver from the self of the frame.
+func (in *Interp) send(fr *frame, recv object.Value, name object.ID, args []object.Value, blk object.Value, fcall bool) object.Value {
+	if fcall {
+		return in.rt.Call(recv, name, args, blk, true)
+	}
+	return in.rt.CallFrom(fr.self, recv, name, args, blk)
+}
+
+// super calls the method of the superclass. The arguments are the current
+// values of the parameters if they are omitted, and the block is the one
+// given to the method unless another is given.
+func (in *Interp) super(fr *frame, x *ast.Super) object.Value {
+	rt := in.rt
+	if fr.method == nil {
+		rt.Raise(rt.RuntimeError, "super called outside of method")
+	}
+	var args []object.Value
+	blk := fr.blk
+	if x.Args != nil {
+		var b object.Value
+		args, b = in.args(fr, x.Args)
+		if isBlockPass(x.Args) {
+			blk = b
+		}
+	} else if params := fr.method.Body.(*method).def.Params; params != nil {
+		var kw *object.RHash
+		for _, p := range params.List {
+			switch p.Kind {
+			case ast.RequiredParam, ast.OptionalParam, ast.PostParam:
+				v, _ := fr.lookup(p.Name)
+				args = append(args, v)
+			case ast.RestParam:
+				v, _ := fr.lookup(restName(p))
+				args = append(args, in.splat(v)...)
+			case ast.KeywordParam, ast.KeywordRestParam:
+				if kw == nil {
+					kw = rt.NewHash()
+				}
+				if p.Kind == ast.KeywordParam {
+					v, _ := fr.lookup(p.Name)
+					rt.HashSet(kw, object.Symbol(in.id(p)), v)
+				} else {
+					v, _ := fr.lookup(restName(p))
+					rt.MergeHash(kw, v)
+				}
+			}
+		}
+		if kw != nil {
+			args = append(args, kw)
+		}
+	}
+	if x.Block != nil {
+		return in.withBlock(fr, x.Block, func(blk object.Value) object.Value {
+			return rt.CallSuper(fr.self, fr.method, args, blk)
+		})
+	}
+	return rt.CallSuper(fr.self, fr.method, args, blk)
+}
+
+// isBlockPass reports whether the arguments end with the block passed by
+// &, which may be &nil passing no block.
+func isBlockPass(args []ast.Expr) bool {
+	if n := len(args); n > 0 {
+		_, ok := args[n-1].(*ast.BlockPass)
+		return ok
+	}
+	return false
+}
+
+// cvarBase returns the class to look up the class variables, which is the
+// class of the lexical scope.
+func (in *Interp) cvarBase(fr *frame) *object.RClass {
+	if fr.scope.parent == nil {
+		in.rt.Raise(in.rt.RuntimeError, "class variable access from toplevel")
+	}
+	return fr.scope.class
+}
+
+// visibilities are the methods changing the default visibility of the
+// following method definitions.
+var visibilities = map[string]object.Visibility{
+	"public":    object.Public,
+	"private":   object.Private,
+	"protected": object.Protected,
+}
+
+// jumpValue returns the value of return, break and next, which is an array
+// for multiple arguments.
+func (in *Interp) jumpValue(fr *frame, args []ast.Expr) object.Value {
+	switch len(args) {
+	case 0:
+		return object.Nil
+	case 1:
+		if _, ok := args[0].(*ast.Splat); !ok {
+			return in.eval(fr, args[0])
+		}
+	}
+	return in.rt.NewArray(in.evalList(fr, args))
+}
+
+// symbolName returns the name of the method given to alias and undef.
+func (in *Interp) symbolName(fr *frame, x ast.Expr) object.ID {
+	switch x := x.(type) {
+	case *ast.SymbolLit, *ast.GlobalVar:
+		return in.id(x)
+	}
+	return object.Intern(in.rt.SymbolName(in.eval(fr, x)))
+}
+
+// defined returns the description of the expression for defined?, or an
+// empty string if it is not defined.
+func (in *Interp) defined(fr *frame, x ast.Expr) (desc string) {
+	rt := in.rt
+	switch x := x.(type) {
+	case *ast.LocalVar:
+		return "local-variable"
+	case *ast.InstanceVar:
+		if rt.IvarDefined(fr.self, in.id(x)) {
+			return "instance-variable"
+		}
+		return ""
+	case *ast.GlobalVar:
+		if _, ok := rt.Globals[x.Name]; ok {
+			return "global-variable"
+		}
+		return ""
+	case *ast.ClassVar:
+		if fr.scope.parent != nil && rt.CvarDefined(fr.scope.class, x.Name) {
+			return "class variable"
+		}
+		return ""
+	case *ast.Super:
+		if fr.method != nil && rt.FindSuperMethod(fr.self, fr.method) != nil {
+			return "super"
+		}
+		return ""
+	case *ast.Yield:
+		if fr.blk != nil {
+			return "yield"
+		}
+		return ""
+	case *ast.Const:
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(*object.Error); !ok {
+					panic(r)
+				}
+				desc = ""
+			}
+		}()
+		in.constGet(fr, x)
+		return "constant"
+	case *ast.Call:
+		if x.Recv == nil {
+			if rt.FindMethod(rt.ClassOf(fr.self), in.id(x)) != nil {
+				return "method"
+			}
+			return ""
+		}
+		if in.defined(fr, x.Recv) == "" {
+			return ""
+		}
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(*object.Error); !ok {
+					panic(r)
+				}
+				desc = ""
+			}
+		}()
+		if rt.RespondTo(in.eval(fr, x.Recv), x.Name) {
+			return "method"
+		}
+		return ""
+	case *ast.PseudoVar:
+		switch x.Kind {
+		case token.KeywordSelf:
+			return "self"
+		case token.KeywordNil:
+			return "nil"
+		case token.KeywordTrue:
+			return "true"
+		case token.KeywordFalse:
+			return "false"
+		}
+		return "expression"
+	case *ast.Assign, *ast.MultiAssign:
+		return "assignment"
+	case *ast.Paren:
+		if len(x.Body.List) == 1 {
+			return in.defined(fr, x.Body.List[0])
+		}
+	case *ast.Binary:
+		switch x.Op {
+		case token.AndOperator, token.OrOperator, token.KeywordAnd, token.KeywordOr:
+			return "expression"
+		}
+		return "method"
+	case *ast.Unary:
+		if x.Op == token.KeywordNot {
+			return "expression"
+		}
+		return "method"
+	}
+	return "expression"
+}
@@ -0,0 +1,613 @@
+/*
+Package interp implements the tree-walking interpreter which evaluates the
+syntax tree directly.
+
+The interpreter runs the methods written in Ruby for the runtime of the
+object package. Each method call creates a frame holding self and the
+local variables. The blocks run in the frames linked to the frame where
+they are defined, so that they can access its local variables. The jumps
+such as break, next, redo, retry and return are implemented by Go panics,
+which are recovered by the loops, the blocks and the method calls, as
+well as the Ruby exceptions.
+*/
+package interp
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/object"
+	"github.com/harukasan/ringo/parser"
+)
+
+// maxDepth is the limit of nested method calls.
+const maxDepth = 10000
+
+// Interp is an interpreter evaluating the syntax tree.
+type Interp struct {
+	rt      *object.Runtime
+	file    string
+	lines   []int // offsets of the beginning of lines
+	depth   int
+	nums    map[*ast.NumberLit]object.Value
+	ids     map[ast.Node]object.ID  // names of the nodes interned
+	setters map[*ast.Call]object.ID // attribute assignments interned
+	cur     *frame                  // frame running
+}
+
+// New returns an interpreter running the methods of the runtime.
+func New(rt *object.Runtime) *Interp {
+	in := &Interp{
+		rt:      rt,
+		nums:    map[*ast.NumberLit]object.Value{},
+		ids:     map[ast.Node]object.ID{},
+		setters: map[*ast.Call]object.ID{},
+	}
+	rt.Invoker = in
+	return in
+}
+
+// Eval parses and runs the source.
+func (in *Interp) Eval(filename string, src []byte) (object.Value, error) {
+	f, err := parser.ParseFile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	return in.Run(f, src)
+}
+
+// Run runs the file parsed from the source. The error is an *object.Error
+// for the uncaught exception.
+func (in *Interp) Run(f *ast.File, src []byte) (v object.Value, err error) {
+	in.file = f.Name
+	// intern the symbol literals before running as MRI does in parsing, so
+	// that Symbol.all_symbols includes them
+	ast.Inspect(f, func(n ast.Node) bool {
+		if x, ok := n.(*ast.SymbolLit); ok {
+			in.ids[x] = object.Intern(x.Name)
+		}
+		return true
+	})
+	in.lines = []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			in.lines = append(in.lines, i+1)
+		}
+	}
+	fr := &frame{
+		self:       in.rt.Main,
+		locals:     map[string]object.Value{},
+		scope:      &scope{class: in.rt.Object},
+		visibility: object.Private,
+	}
+	prev := in.cur
+	in.cur = fr
+	defer func() {
+		in.cur = prev
+		if r := recover(); r != nil {
+			v, err = nil, in.toplevelError(fr, r)
+		}
+	}()
+	return in.evalStmts(fr, f.Body), nil
+}
+
+// toplevelError returns the error of the panic which is not recovered. The
+// return at the top level stops the program without errors.
+func (in *Interp) toplevelError(fr *frame, r interface{}) error {
+	switch r := r.(type) {
+	case *object.Error:
+		return r
+	case *returnJump:
+		if r.frame == fr {
+			return nil
+		}
+	case *loopJump:
+		exc := in.rt.NewException(in.rt.LocalJumpError, fmt.Sprintf("unexpected %s", r.kind))
+		return &object.Error{Exception: exc}
+	case *retryJump:
+		exc := in.rt.NewException(in.rt.LocalJumpError, "retry outside of rescue clause")
+		return &object.Error{Exception: exc}
+	}
+	panic(r)
+}
+
+// line returns the line number of the offset.
+func (in *Interp) line(pos int) int {
+	return sort.SearchInts(in.lines, pos+1)
+}
+
+// frame is the context of a method call, a block call, a class body, or
+// the top level.
+type frame struct {
+	self       object.Value
+	locals     map[string]object.Value
+	outer      *frame         // frame where the block is defined, nil outside blocks
+	scope      *scope         // lexical scope for constants and definitions
+	method     *object.Method // nil outside methods
+	visibility object.Visibility
+	flips      map[*ast.RangeLit]bool // states of the flip-flops, kept outside blocks
+	blk        object.Value           // block given to the method, nil if not given
+	lambda     bool                   // whether the block is run as a lambda
+	done       bool                   // whether the method or the lambda has returned
+}
+
+// lookup returns the local variable, which is looked up also in the frames
+// where the blocks are defined.
+func (fr *frame) lookup(name string) (object.Value, bool) {
+	for ; fr != nil; fr = fr.outer {
+		if v, ok := fr.locals[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// setLocal assigns the local variable of the frame defining it, or
+// declares it in the current frame.
+func (fr *frame) setLocal(name string, v object.Value) {
+	for f := fr; f != nil; f = f.outer {
+		if _, ok := f.locals[name]; ok {
+			f.locals[name] = v
+			return
+		}
+	}
+	fr.locals[name] = v
+}
+
+// returnFrame returns the frame which return leaves, that is the frame of
+// the method where the block is defined, or the frame of the lambda.
+func (fr *frame) returnFrame() *frame {
+	for fr.outer != nil && !fr.lambda {
+		fr = fr.outer
+	}
+	return fr
+}
+
+// localFrame returns the frame of the method or the top level where the
+// block is defined.
+func (fr *frame) localFrame() *frame {
+	for fr.outer != nil {
+		fr = fr.outer
+	}
+	return fr
+}
+
+// classBody reports whether the frame runs a class body.
+func (fr *frame) classBody() bool {
+	return fr.outer == nil && fr.method == nil && fr.scope.parent != nil
+}
+
+// scope is the lexical scope of class and module definitions.
+type scope struct {
+	class  *object.RClass
+	parent *scope
+}
+
+// jumps are panicked by break, next, redo, retry and return.
+type (
+	loopJump struct {
+		kind string // break, next, or redo
+		val  object.Value
+	}
+
+	retryJump struct{}
+
+	returnJump struct {
+		val   object.Value
+		frame *frame // frame of the method to return
+	}
+
+	// breakJump is panicked by break in the block, which returns from the
+	// method call given the block.
+	breakJump struct {
+		val  object.Value
+		proc *object.RProc
+	}
+)
+
+// method is the body of the method defined in Ruby.
+type method struct {
+	def   *ast.Def
+	scope *scope
+}
+
+// Invoke runs the method defined by the interpreter.
+func (in *Interp) Invoke(m *object.Method, self object.Value, args []object.Value, blk object.Value) (ret object.Value) {
+	body := m.Body.(*method)
+	if in.depth >= maxDepth {
+		in.rt.Raise(in.rt.SystemStackError, "stack level too deep")
+	}
+	in.depth++
+	fr := &frame{
+		self:   self,
+		locals: map[string]object.Value{},
+		scope:  body.scope,
+		method: m,
+		blk:    blk,
+	}
+	prev := in.cur
+	in.cur = fr
+	defer func() {
+		in.depth--
+		in.cur = prev
+		fr.done = true
+		if r := recover(); r != nil {
+			if j, ok := r.(*returnJump); ok && j.frame == fr {
+				ret = j.val
+				return
+			}
+			panic(r)
+		}
+	}()
+	in.bindParams(fr, body.def.Params, args, blk)
+	return in.evalBodyStmt(fr, body.def.Body)
+}
+
+// block is the body of the proc of a block literal.
+type block struct {
+	node  *ast.Block
+	outer *frame
+	done  bool // whether the method call given the block has returned
+}
+
+// newProc returns the proc of the block literal in the frame.
+func (in *Interp) newProc(fr *frame, x *ast.Block) *object.RProc {
+	return &object.RProc{
+		Body:    &block{node: x, outer: fr},
+		Params:  procParams(x.Params),
+		Literal: true,
+		Desc:    fmt.Sprintf(" %s:%d", in.file, in.line(x.Start)),
+	}
+}
+
+// withBlock calls f with the proc of the block literal, and returns the
+// value of break in the block instead if it is broken.
+func (in *Interp) withBlock(fr *frame, x *ast.Block, f func(blk object.Value) object.Value) (v object.Value) {
+	p := in.newProc(fr, x)
+	defer func() {
+		p.Body.(*block).done = true
+		if r := recover(); r != nil {
+			if j, ok := r.(*breakJump); ok && j.proc == p {
+				v = j.val
+				return
+			}
+			panic(r)
+		}
+	}()
+	return f(p)
+}
+
+// CallBlock runs the block of the proc. The arguments of procs are
+// adjusted to the parameters, while lambdas check the number of them.
+func (in *Interp) CallBlock(p *object.RProc, args []object.Value, blk object.Value) object.Value {
+	outer := p.Body.(*block).outer
+	return in.callBlock(p, &frame{
+		self:       outer.self,
+		locals:     map[string]object.Value{},
+		outer:      outer,
+		scope:      outer.scope,
+		method:     outer.method,
+		visibility: outer.visibility,
+		blk:        outer.blk,
+		lambda:     p.Lambda,
+	}, args, blk)
+}
+
+// ClassExec runs the block of the proc as the body of the class, where
+// self is the class and the methods are defined in the class.
+func (in *Interp) ClassExec(p *object.RProc, c *object.RClass) object.Value {
+	outer := p.Body.(*block).outer
+	return in.callBlock(p, &frame{
+		self:   c,
+		locals: map[string]object.Value{},
+		outer:  outer,
+		scope:  &scope{class: c, parent: outer.scope},
+		method: outer.method,
+		blk:    outer.blk,
+		lambda: p.Lambda,
+	}, []object.Value{c}, nil)
+}
+
+// callBlock runs the block in the frame.
+func (in *Interp) callBlock(p *object.RProc, fr *frame, args []object.Value, blk object.Value) (ret object.Value) {
+	b := p.Body.(*block)
+	if in.depth >= maxDepth {
+		in.rt.Raise(in.rt.SystemStackError, "stack level too deep")
+	}
+	in.depth++
+	prev := in.cur
+	in.cur = fr
+	defer func() {
+		in.depth--
+		in.cur = prev
+		fr.done = true
+		if r := recover(); r != nil {
+			if j, ok := r.(*returnJump); ok && j.frame == fr {
+				ret = j.val
+				return
+			}
+			panic(r)
+		}
+	}()
+	if !p.Lambda {
+		args = procArgs(b.node.Params, args)
+	}
+	in.bindParams(fr, b.node.Params, args, blk)
+	for {
+		v, redo := in.blockBody(fr, p)
+		if !redo {
+			return v
+		}
+	}
+}
+
+// blockBody evaluates the body of the block, where next leaves the block
+// with the value, and redo restarts the body. break leaves the lambda, or
+// returns from the method call given the block.
+func (in *Interp) blockBody(fr *frame, p *object.RProc) (v object.Value, redo bool) {
+	b := p.Body.(*block)
+	defer func() {
+		if r := recover(); r != nil {
+			j, ok := r.(*loopJump)
+			if !ok {
+				panic(r)
+			}
+			switch j.kind {
+			case "next":
+				v = j.val
+			case "redo":
+				redo = true
+			case "break":
+				if p.Lambda {
+					v = j.val
+					return
+				}
+				if b.done {
+					in.rt.Raise(in.rt.LocalJumpError, "break from proc-closure")
+				}
+				panic(&breakJump{val: j.val, proc: p})
+			}
+		}
+	}()
+	return in.evalBodyStmt(fr, b.node.Body), false
+}
+
+// BlockGiven reports whether the block is given to the method running.
+func (in *Interp) BlockGiven() bool {
+	return in.cur != nil && in.cur.blk != nil
+}
+
+// procParams returns the parameters of the block for Proc#parameters.
+func procParams(params *ast.Params) []object.ProcParam {
+	if params == nil {
+		return nil
+	}
+	list := make([]object.ProcParam, len(params.List))
+	for i, p := range params.List {
+		kind := "req"
+		switch p.Kind {
+		case ast.OptionalParam:
+			kind = "opt"
+		case ast.RestParam:
+			kind = "rest"
+		case ast.KeywordParam:
+			kind = "key"
+			if p.Default == nil {
+				kind = "keyreq"
+			}
+		case ast.KeywordRestParam:
+			kind = "keyrest"
+		case ast.BlockParam:
+			kind = "block"
+		}
+		list[i] = object.ProcParam{Kind: kind, Name: p.Name}
+	}
+	return list
+}
+
+// procArgs adjusts the arguments of the proc to the parameters. An array
+// given alone is expanded for the parameters except for |a|, and the
+// missing arguments are nil, while the extra ones are dropped. The keyword
+// arguments are kept at the end for the keyword parameters.
+func procArgs(params *ast.Params, args []object.Value) []object.Value {
+	var req, opt, n int
+	rest, kw := false, false
+	if params != nil {
+		for _, p := range params.List {
+			switch p.Kind {
+			case ast.RequiredParam, ast.PostParam:
+				req++
+			case ast.OptionalParam:
+				opt++
+			case ast.RestParam:
+				rest = true
+			case ast.KeywordParam, ast.KeywordRestParam:
+				kw = true
+			}
+			if p.Kind != ast.BlockParam {
+				n++
+			}
+		}
+	}
+	if len(args) == 1 && req+opt > 0 && !(n == 1 && req == 1) {
+		if a, ok := args[0].(*object.RArray); ok {
+			args = a.Elems
+		}
+	}
+	var kwargs object.Value
+	if kw && len(args) > req {
+		if h, ok := args[len(args)-1].(*object.RHash); ok {
+			kwargs, args = h, args[:len(args)-1]
+		}
+	}
+	switch {
+	case len(args) < req:
+		args = append([]object.Value(nil), args...)
+		for len(args) < req {
+			args = append(args, object.Nil)
+		}
+	case !rest && len(args) > req+opt:
+		args = args[:req+opt]
+	}
+	if kwargs != nil {
+		args = append(args[:len(args):len(args)], kwargs)
+	}
+	return args
+}
+
+// arity returns the arity of the parameters, which is the number of the
+// required parameters, or -n-1 if optional parameters are accepted. The
+// required keywords are counted as one parameter, and the optional ones
+// make the arity negative only if no keyword is required.
+func arity(params *ast.Params) int {
+	if params == nil {
+		return 0
+	}
+	n, opt, keyreq, keyopt := 0, false, false, false
+	for _, p := range params.List {
+		switch p.Kind {
+		case ast.RequiredParam, ast.PostParam:
+			n++
+		case ast.OptionalParam, ast.RestParam:
+			opt = true
+		case ast.KeywordParam:
+			if p.Default == nil {
+				keyreq = true
+			} else {
+				keyopt = true
+			}
+		case ast.KeywordRestParam:
+			keyopt = true
+		}
+	}
+	if keyreq {
+		n++
+	} else if keyopt {
+		opt = true
+	}
+	if opt {
+		return -n - 1
+	}
+	return n
+}
+
+// bindParams assigns the arguments and the block to the parameters.
+func (in *Interp) bindParams(fr *frame, params *ast.Params, args []object.Value, blk object.Value) {
+	if params == nil {
+		if len(args) > 0 {
+			in.rt.Raise(in.rt.ArgumentError, "wrong number of arguments (given %d, expected 0)", len(args))
+		}
+		return
+	}
+	var req, opt int
+	rest, kw := false, false
+	for _, p := range params.List {
+		switch p.Kind {
+		case ast.RequiredParam, ast.PostParam:
+			req++
+		case ast.OptionalParam:
+			opt++
+		case ast.RestParam:
+			rest = true
+		case ast.KeywordParam, ast.KeywordRestParam:
+			kw = true
+		}
+	}
+	var kws *object.Keywords
+	if kw {
+		args, kws = in.rt.SplitKeywords(args, req)
+	}
+	if len(args) < req || !rest && len(args) > req+opt {
+		var expected string
+		switch {
+		case rest:
+			expected = fmt.Sprintf("%d+", req)
+		case opt > 0:
+			expected = fmt.Sprintf("%d..%d", req, req+opt)
+		default:
+			expected = fmt.Sprint(req)
+		}
+		in.rt.Raise(in.rt.ArgumentError, "wrong number of arguments (given %d, expected %s)", len(args), expected)
+	}
+
+	// The optional parameters take the arguments left by the required ones
+	// from the beginning, and the rest parameter takes the remainder.
+	avail := len(args) - req
+	i := 0
+	for k, p := range params.List {
+		switch p.Kind {
+		case ast.RequiredParam:
+			in.bindParam(fr, p, args[i])
+			i++
+		case ast.OptionalParam:
+			if avail > 0 {
+				fr.locals[p.Name] = args[i]
+				i++
+				avail--
+			} else {
+				fr.locals[p.Name] = in.eval(fr, p.Default)
+			}
+		case ast.RestParam:
+			post := 0
+			for _, q := range params.List[k+1:] {
+				if q.Kind == ast.PostParam {
+					post++
+				}
+			}
+			n := len(args) - i - post
+			fr.locals[restName(p)] = in.rt.NewArray(append([]object.Value(nil), args[i:i+n]...))
+			i += n
+		case ast.PostParam:
+			in.bindParam(fr, p, args[i])
+			i++
+		case ast.KeywordParam:
+			v, ok := kws.Take(in.id(p))
+			switch {
+			case ok:
+			case p.Default == nil:
+				kws.Missing(in.id(p))
+				v = object.Nil
+			default:
+				v = in.eval(fr, p.Default)
+			}
+			fr.locals[p.Name] = v
+		case ast.KeywordRestParam:
+			fr.locals[restName(p)] = kws.Rest()
+		case ast.BlockParam:
+			if blk == nil {
+				blk = object.Nil
+			}
+			fr.locals[p.Name] = blk
+		}
+	}
+	if kws != nil {
+		kws.Check()
+	}
+}
+
+// bindParam assigns the argument to the required parameter. The nested
+// parameters such as (a, b) take the elements of the array as a proc does.
+func (in *Interp) bindParam(fr *frame, p *ast.Param, v object.Value) {
+	if p.Nested == nil {
+		fr.locals[p.Name] = v
+		return
+	}
+	elems := []object.Value{v}
+	if a, ok := v.(*object.RArray); ok {
+		elems = a.Elems
+	}
+	in.bindParams(fr, p.Nested, procArgs(p.Nested, elems), nil)
+}
+
+// restName returns the local variable of the rest parameter, which is * or
+// ** for the anonymous one passed by super.
+func restName(p *ast.Param) string {
+	if p.Name == "" {
+		if p.Kind == ast.KeywordRestParam {
+			return "**"
+		}
+		return "*"
+	}
+	return p.Name
+}
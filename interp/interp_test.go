@@ -0,0 +1,25 @@
+package interp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/harukasan/ringo/internal/evaltest"
+	"github.com/harukasan/ringo/object"
+)
+
+func run(src string) (string, error) {
+	rt := object.New()
+	var out bytes.Buffer
+	rt.Stdout = &out
+	_, err := New(rt).Eval("t.rb", []byte(src))
+	return out.String(), err
+}
+
+func TestEval(t *testing.T) {
+	evaltest.Outputs(t, run)
+}
+
+func TestEvalErrors(t *testing.T) {
+	evaltest.Errors(t, run)
+}
@@ -0,0 +1,407 @@
+package interp
+
+import (
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/object"
+	"github.com/harukasan/ringo/token"
+)
+
+// assign evaluates the single assignment, including the compound
+// assignments such as a += 1 or a ||= 1.
+func (in *Interp) assign(fr *frame, x *ast.Assign) object.Value {
+	if x.Op == token.Assign {
+		v := in.eval(fr, x.Rhs)
+		in.assignTo(fr, x.Lhs, v)
+		return v
+	}
+
+	// The receiver and the arguments of the left hand side are evaluated
+	// only once.
+	var get func() object.Value
+	var set func(object.Value)
+	rt := in.rt
+	switch lhs := x.Lhs.(type) {
+	case *ast.Index:
+		recv := in.eval(fr, lhs.Recv)
+		args := in.evalList(fr, lhs.Args)
+		get = func() object.Value { return rt.CallFrom(fr.self, recv, idAref, args, nil) }
+		set = func(v object.Value) {
+			rt.CallFrom(fr.self, recv, idAset, append(append([]object.Value(nil), args...), v), nil)
+		}
+	case *ast.Call:
+		recv := in.eval(fr, lhs.Recv)
+		fcall := isSelf(lhs.Recv)
+		get = func() object.Value { return in.send(fr, recv, in.id(lhs), nil, nil, fcall) }
+		set = func(v object.Value) { in.send(fr, recv, in.setter(lhs), []object.Value{v}, nil, fcall) }
+	case *ast.Const:
+		c := in.constScope(fr, lhs)
+		get = func() object.Value {
+			if v, ok := rt.ConstGet(c, lhs.Name); ok {
+				return v
+			}
+			if x.Op == token.AssignOrOperator {
+				return object.Nil
+			}
+			rt.ConstMissing(c, lhs.Name)
+			return nil
+		}
+		set = func(v object.Value) { rt.ConstSet(c, lhs.Name, v) }
+	case *ast.ClassVar:
+		c := in.cvarBase(fr)
+		get = func() object.Value {
+			if x.Op == token.AssignOrOperator && !rt.CvarDefined(c, lhs.Name) {
+				return object.Nil
+			}
+			return rt.CvarGet(c, lhs.Name)
+		}
+		set = func(v object.Value) { rt.CvarSet(c, lhs.Name, v) }
+	default:
+		get = func() object.Value { return in.eval(fr, lhs) }
+		set = func(v object.Value) { in.assignTo(fr, lhs, v) }
+	}
+
+	old := get()
+	var v object.Value
+	switch x.Op {
+	case token.AssignAndOperator:
+		if !object.Truthy(old) {
+			return old
+		}
+		v = in.eval(fr, x.Rhs)
+	case token.AssignOrOperator:
+		if object.Truthy(old) {
+			return old
+		}
+		v = in.eval(fr, x.Rhs)
+	default:
+		v = rt.CallFrom(fr.self, old, in.id(x), []object.Value{in.eval(fr, x.Rhs)}, nil)
+	}
+	set(v)
+	return v
+}
+
+func isSelf(x ast.Expr) bool {
+	p, ok := x.(*ast.PseudoVar)
+	return ok && p.Kind == token.KeywordSelf
+}
+
+// assignTo assigns the value to the left hand side.
+func (in *Interp) assignTo(fr *frame, lhs ast.Expr, v object.Value) {
+	rt := in.rt
+	switch lhs := lhs.(type) {
+	case *ast.LocalVar:
+		fr.setLocal(lhs.Name, v)
+	case *ast.InstanceVar:
+		rt.SetIvar(fr.self, in.id(lhs), v)
+	case *ast.GlobalVar:
+		rt.Globals[lhs.Name] = v
+	case *ast.Const:
+		rt.ConstSet(in.constScope(fr, lhs), lhs.Name, v)
+	case *ast.Index:
+		recv := in.eval(fr, lhs.Recv)
+		args := append(in.evalList(fr, lhs.Args), v)
+		rt.CallFrom(fr.self, recv, idAset, args, nil)
+	case *ast.Call:
+		recv := in.eval(fr, lhs.Recv)
+		in.send(fr, recv, in.setter(lhs), []object.Value{v}, nil, isSelf(lhs.Recv))
+	case *ast.MultiAssign:
+		in.multiAssign(fr, lhs.Lhs, v)
+	case *ast.ClassVar:
+		rt.CvarSet(in.cvarBase(fr), lhs.Name, v)
+	default:
+		rt.NotImplemented("assignment")
+	}
+}
+
+// multiAssign assigns the elements of the value to the left hand sides.
+// The value which is not an array is assigned as an array of the value.
+func (in *Interp) multiAssign(fr *frame, lhs []ast.Expr, v object.Value) {
+	var elems []object.Value
+	if a, ok := v.(*object.RArray); ok {
+		elems = a.Elems
+	} else {
+		elems = []object.Value{v}
+	}
+	splat := -1
+	for i, x := range lhs {
+		if _, ok := x.(*ast.Splat); ok {
+			splat = i
+		}
+	}
+	at := func(i int) object.Value {
+		if i < len(elems) {
+			return elems[i]
+		}
+		return object.Nil
+	}
+	if splat < 0 {
+		for i, x := range lhs {
+			in.assignTo(fr, x, at(i))
+		}
+		return
+	}
+	for i, x := range lhs[:splat] {
+		in.assignTo(fr, x, at(i))
+	}
+	post := len(lhs) - splat - 1
+	n := len(elems) - splat - post
+	if n < 0 {
+		n = 0
+	}
+	if s := lhs[splat].(*ast.Splat); s.Value != nil {
+		var rest []object.Value
+		if n > 0 {
+			rest = append(rest, elems[splat:splat+n]...)
+		}
+		in.assignTo(fr, s.Value, in.rt.NewArray(rest))
+	}
+	for i, x := range lhs[splat+1:] {
+		in.assignTo(fr, x, at(splat+n+i))
+	}
+}
+
+// def defines the method in the current class, or the singleton method of
+// the receiver.
+func (in *Interp) def(fr *frame, x *ast.Def) object.Value {
+	rt := in.rt
+	m := &object.Method{
+		Name:       in.id(x),
+		Visibility: fr.visibility,
+		Arity:      arity(x.Params),
+		Body:       &method{def: x, scope: fr.scope},
+	}
+	if fr.method != nil {
+		m.Visibility = object.Public
+	}
+	switch x.Name {
+	case "initialize", "initialize_copy", "respond_to_missing?":
+		m.Visibility = object.Private
+	}
+	if x.Singleton != nil {
+		m.Visibility = object.Public
+		rt.AddMethod(rt.SingletonClassOf(in.eval(fr, x.Singleton)), m)
+		return object.Symbol(m.Name)
+	}
+	rt.AddMethod(fr.scope.class, m)
+	return object.Symbol(m.Name)
+}
+
+// classDef evaluates the class definition.
+func (in *Interp) classDef(fr *frame, x *ast.ClassDef) object.Value {
+	rt := in.rt
+	var super *object.RClass
+	if x.Super != nil {
+		v := in.eval(fr, x.Super)
+		c, ok := v.(*object.RClass)
+		if !ok || c.IsModule {
+			rt.Raise(rt.TypeError, "superclass must be a Class (%s given)", rt.ClassOf(v).RealClass().Name)
+		}
+		super = c
+	}
+	c := rt.DefineClass(x.Path.Name, super, in.constScope(fr, x.Path))
+	return in.evalBody(fr, c, x.Body)
+}
+
+// moduleDef evaluates the module definition.
+func (in *Interp) moduleDef(fr *frame, x *ast.ModuleDef) object.Value {
+	m := in.rt.DefineModule(x.Path.Name, in.constScope(fr, x.Path))
+	return in.evalBody(fr, m, x.Body)
+}
+
+// evalBody evaluates the body of the class or the module, where self is
+// the class.
+func (in *Interp) evalBody(fr *frame, c *object.RClass, body *ast.BodyStmt) object.Value {
+	cfr := &frame{
+		self:   c,
+		locals: map[string]object.Value{},
+		scope:  &scope{class: c, parent: fr.scope},
+	}
+	prev := in.cur
+	in.cur = cfr
+	defer func() { in.cur = prev }()
+	return in.evalBodyStmt(cfr, body)
+}
+
+// alias evaluates the alias of methods.
+func (in *Interp) alias(fr *frame, x *ast.Alias) object.Value {
+	if _, ok := x.New.(*ast.GlobalVar); ok {
+		in.rt.NotImplemented("alias of global variable")
+	}
+	in.rt.AliasMethod(fr.scope.class, in.symbolName(fr, x.New), in.symbolName(fr, x.Old))
+	return object.Nil
+}
+
+// while evaluates the while or until loop.
+func (in *Interp) while(fr *frame, x *ast.While) object.Value {
+	first := x.DoWhile
+	for {
+		if !first && object.Truthy(in.cond(fr, x.Cond)) == x.Until {
+			return object.Nil
+		}
+		first = false
+		if v, brk := in.loopBody(fr, x.Body); brk {
+			return v
+		}
+	}
+}
+
+// loopBody evaluates the body of loop. It returns the value of break and
+// true if the loop is broken.
+func (in *Interp) loopBody(fr *frame, body *ast.Stmts) (v object.Value, brk bool) {
+	for {
+		redo := false
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					j, ok := r.(*loopJump)
+					if !ok {
+						panic(r)
+					}
+					switch j.kind {
+					case "break":
+						v, brk = j.val, true
+					case "redo":
+						redo = true
+					}
+				}
+			}()
+			in.evalStmts(fr, body)
+		}()
+		if !redo {
+			return v, brk
+		}
+	}
+}
+
+// caseExpr evaluates the case expression, which compares the subject by
+// the === method of the conditions.
+func (in *Interp) caseExpr(fr *frame, x *ast.Case) object.Value {
+	var subject object.Value
+	if x.Subject != nil {
+		subject = in.eval(fr, x.Subject)
+	}
+	for _, w := range x.Whens {
+		for _, cond := range w.Conds {
+			var vals []object.Value
+			if s, ok := cond.(*ast.Splat); ok {
+				vals = in.splat(in.eval(fr, s.Value))
+			} else {
+				vals = []object.Value{in.eval(fr, cond)}
+			}
+			for _, v := range vals {
+				if subject == nil && object.Truthy(v) ||
+					subject != nil && object.Truthy(in.rt.Call(v, idEqq, []object.Value{subject}, nil, false)) {
+					return in.evalStmts(fr, w.Body)
+				}
+			}
+		}
+	}
+	return in.evalStmts(fr, x.Else)
+}
+
+// evalBodyStmt evaluates the body with the rescue, else and ensure
+// clauses.
+func (in *Interp) evalBodyStmt(fr *frame, b *ast.BodyStmt) object.Value {
+	if b == nil {
+		return object.Nil
+	}
+	if b.Ensure != nil {
+		defer in.evalStmts(fr, b.Ensure)
+	}
+	if len(b.Rescues) == 0 {
+		v := in.evalStmts(fr, b.Body)
+		if b.Else != nil {
+			v = in.evalStmts(fr, b.Else)
+		}
+		return v
+	}
+	for {
+		v, err := in.try(fr, b.Body)
+		if err == nil {
+			if b.Else != nil {
+				v = in.evalStmts(fr, b.Else)
+			}
+			return v
+		}
+		r := in.findRescue(fr, b.Rescues, err)
+		if r == nil {
+			panic(err)
+		}
+		v, retry := in.rescue(fr, r, err)
+		if !retry {
+			return v
+		}
+	}
+}
+
+// try evaluates the expressions, and returns the Ruby exception raised.
+func (in *Interp) try(fr *frame, body *ast.Stmts) (v object.Value, err *object.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*object.Error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+	return in.evalStmts(fr, body), nil
+}
+
+// findRescue returns the rescue clause matching the exception.
+func (in *Interp) findRescue(fr *frame, rescues []*ast.Rescue, err *object.Error) *ast.Rescue {
+	for _, r := range rescues {
+		if len(r.Classes) == 0 {
+			if in.rt.IsKindOf(err.Exception, in.rt.StandardError) {
+				return r
+			}
+			continue
+		}
+		for _, c := range in.evalList(fr, r.Classes) {
+			if _, ok := c.(*object.RClass); !ok {
+				in.rt.Raise(in.rt.TypeError, "class or module required for rescue clause")
+			}
+			if object.Truthy(in.rt.Send(c, "===", err.Exception)) {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// rescue evaluates the rescue clause, setting $! while the clause runs. It
+// reports whether retry is called.
+func (in *Interp) rescue(fr *frame, r *ast.Rescue, err *object.Error) (v object.Value, retry bool) {
+	rt := in.rt
+	prev, ok := rt.Globals["$!"]
+	rt.Globals["$!"] = err.Exception
+	defer func() {
+		if ok {
+			rt.Globals["$!"] = prev
+		} else {
+			delete(rt.Globals, "$!")
+		}
+		if p := recover(); p != nil {
+			if _, ok := p.(*retryJump); !ok {
+				panic(p)
+			}
+			retry = true
+		}
+	}()
+	if r.Var != nil {
+		in.assignTo(fr, r.Var, err.Exception)
+	}
+	return in.evalStmts(fr, r.Body), false
+}
+
+// rescueMod evaluates the rescue modifier, which rescues StandardError.
+func (in *Interp) rescueMod(fr *frame, x *ast.RescueMod) object.Value {
+	v, err := in.try(fr, &ast.Stmts{List: []ast.Expr{x.X}})
+	if err == nil {
+		return v
+	}
+	if !in.rt.IsKindOf(err.Exception, in.rt.StandardError) {
+		panic(err)
+	}
+	return in.eval(fr, x.Rescue)
+}
@@ -0,0 +1,283 @@
+/*
+Package literal evaluates the numeric literals returned by the scanner.
+
+The values are represented by the Go types as below:
+
+	integer    int64, or *big.Int if it overflows int64
+	float      float64
+	rational   *big.Rat
+	imaginary  Complex
+
+The literals are accepted in the form that the scanner emits, including the
+sign and the underscores, such as -0X1_F, 0_17, 1.5e-3, 3r or 1.5ri.
+*/
+package literal
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/harukasan/ringo/token"
+)
+
+// ErrSyntax indicates that the literal is not a valid numeric literal.
+var ErrSyntax = errors.New("invalid numeric literal")
+
+// ErrToken indicates that the token is not a numeric token.
+var ErrToken = errors.New("not a numeric token")
+
+// Error holds an error with the literal which is failed to be evaluated.
+type Error struct {
+	Literal string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	return "literal: " + strconv.Quote(e.Literal) + ": " + e.Err.Error()
+}
+
+// Complex represents a complex number. Each part is a value of integer,
+// float or rational. The real part of imaginary literal is always int64(0)
+// as Ruby does.
+type Complex struct {
+	Real interface{}
+	Imag interface{}
+}
+
+// Parse evaluates the literal of the numeric token.
+func Parse(t token.Token, lit []byte) (interface{}, error) {
+	switch t {
+	case token.BinaryInteger, token.DecimalInteger, token.OctadecimalInteger, token.HexadecimalInteger:
+		return Int(lit)
+	case token.Float:
+		return Float(lit)
+	case token.Rational:
+		return Rational(lit)
+	case token.Imaginary:
+		return Imaginary(lit)
+	}
+	return nil, &Error{string(lit), ErrToken}
+}
+
+// Int evaluates the integer literal, and returns int64 or *big.Int.
+func Int(lit []byte) (interface{}, error) {
+	neg, s := sign(string(lit))
+	base, s := prefix(s)
+	return parseInt(lit, neg, s, base)
+}
+
+// IntBase evaluates the integer in the base from 2 to 36 as Integer() of
+// Ruby does. The prefix is allowed only if it denotes the same base, so
+// "0x1f" is 31 in base 16 but an error in base 10. The base 0 means the base
+// given by the prefix as Int.
+func IntBase(lit []byte, base int) (interface{}, error) {
+	if base == 0 {
+		return Int(lit)
+	}
+	if base < 2 || base > 36 {
+		return nil, &Error{string(lit), ErrSyntax}
+	}
+	neg, s := sign(string(lit))
+	if b, rest := prefix(s); b == base {
+		s = rest
+	}
+	return parseInt(lit, neg, s, base)
+}
+
+// parseInt evaluates the digits of the literal without the sign and the
+// prefix.
+func parseInt(lit []byte, neg bool, s string, base int) (interface{}, error) {
+	digits, ok := clean(s, base)
+	if !ok {
+		return nil, &Error{string(lit), ErrSyntax}
+	}
+	if neg {
+		digits = "-" + digits
+	}
+	if n, err := strconv.ParseInt(digits, base, 64); err == nil {
+		return n, nil
+	}
+	n, ok := new(big.Int).SetString(digits, base)
+	if !ok {
+		return nil, &Error{string(lit), ErrSyntax}
+	}
+	return n, nil
+}
+
+// Float evaluates the float literal. The value is rounded to the nearest
+// float64, and the literal out of range becomes the infinity or zero as Ruby
+// does.
+func Float(lit []byte) (float64, error) {
+	s, ok := cleanFloat(string(lit))
+	if !ok {
+		return 0, &Error{string(lit), ErrSyntax}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && err.(*strconv.NumError).Err != strconv.ErrRange {
+		return 0, &Error{string(lit), ErrSyntax}
+	}
+	return f, nil
+}
+
+// Rational evaluates the rational literal which has "r" suffix, such as 3r
+// or 1.5r. The decimal fraction is evaluated exactly, so 1.5r is 3/2.
+func Rational(lit []byte) (*big.Rat, error) {
+	n := len(lit)
+	if n == 0 || lit[n-1] != 'r' {
+		return nil, &Error{string(lit), ErrSyntax}
+	}
+	body := lit[:n-1]
+	if isFloat(string(body)) {
+		s, ok := cleanFloat(string(body))
+		if !ok {
+			return nil, &Error{string(lit), ErrSyntax}
+		}
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, &Error{string(lit), ErrSyntax}
+		}
+		return r, nil
+	}
+	v, err := Int(body)
+	if err != nil {
+		return nil, &Error{string(lit), ErrSyntax}
+	}
+	switch v := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v), nil
+	case *big.Int:
+		return new(big.Rat).SetInt(v), nil
+	}
+	panic("unreachable")
+}
+
+// Imaginary evaluates the imaginary literal which has "i" suffix, such as 2i,
+// 1.5i or 3ri.
+func Imaginary(lit []byte) (Complex, error) {
+	n := len(lit)
+	if n == 0 || lit[n-1] != 'i' {
+		return Complex{}, &Error{string(lit), ErrSyntax}
+	}
+	body := lit[:n-1]
+	var v interface{}
+	var err error
+	switch {
+	case len(body) > 0 && body[len(body)-1] == 'r':
+		v, err = Rational(body)
+	case isFloat(string(body)):
+		v, err = Float(body)
+	default:
+		v, err = Int(body)
+	}
+	if err != nil {
+		return Complex{}, &Error{string(lit), ErrSyntax}
+	}
+	return Complex{Real: int64(0), Imag: v}, nil
+}
+
+// sign strips the sign of the literal.
+func sign(s string) (neg bool, rest string) {
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		return s[0] == '-', s[1:]
+	}
+	return false, s
+}
+
+// prefix strips the prefix of the integer literal, and returns the base.
+func prefix(s string) (base int, rest string) {
+	if len(s) < 2 || s[0] != '0' {
+		return 10, s
+	}
+	switch s[1] {
+	case 'x', 'X':
+		return 16, s[2:]
+	case 'b', 'B':
+		return 2, s[2:]
+	case 'o', 'O', '_':
+		return 8, s[2:]
+	case 'd', 'D':
+		return 10, s[2:]
+	}
+	return 8, s[1:]
+}
+
+// isDigit returns whether the character is a digit in the base, where the
+// letters are the digits from 10 to 35.
+func isDigit(c byte, base int) bool {
+	var n int
+	switch {
+	case token.IsDecimal(c):
+		n = int(c - '0')
+	case 'a' <= c && c <= 'z':
+		n = int(c-'a') + 10
+	case 'A' <= c && c <= 'Z':
+		n = int(c-'A') + 10
+	default:
+		return false
+	}
+	return n < base
+}
+
+// clean removes the underscores between the digits. It returns false if the
+// string has no digits, invalid digits or misplaced underscores.
+func clean(s string, base int) (string, bool) {
+	if s == "" {
+		return "", false
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' {
+			if i == 0 || i == len(s)-1 || s[i+1] == '_' {
+				return "", false
+			}
+			continue
+		}
+		if !isDigit(c, base) {
+			return "", false
+		}
+		b = append(b, c)
+	}
+	return string(b), true
+}
+
+// cleanFloat removes the underscores of the decimal float literal. The
+// underscores are allowed only between the digits.
+func cleanFloat(s string) (string, bool) {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case token.IsDecimal(c):
+		case c == '_':
+			if i == 0 || i == len(s)-1 || !token.IsDecimal(s[i-1]) || !token.IsDecimal(s[i+1]) {
+				return "", false
+			}
+			continue
+		case c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-':
+		default:
+			return "", false
+		}
+		b = append(b, c)
+	}
+	return string(b), true
+}
+
+// isFloat returns whether the literal is a decimal float, which has a
+// fraction or an exponent without the integer prefix.
+func isFloat(s string) bool {
+	_, s = sign(s)
+	if len(s) >= 2 && s[0] == '0' {
+		switch s[1] {
+		case 'x', 'X', 'b', 'B', 'o', 'O', 'd', 'D', '_':
+			return false
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '.' || s[i] == 'e' || s[i] == 'E' {
+			return true
+		}
+	}
+	return false
+}
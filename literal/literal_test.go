@@ -0,0 +1,145 @@
+package literal
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/harukasan/ringo/scanner"
+	"github.com/harukasan/ringo/token"
+)
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func rat(s string) *big.Rat {
+	r, _ := new(big.Rat).SetString(s)
+	return r
+}
+
+func TestParse(t *testing.T) {
+	rules := map[string]interface{}{
+		"0":                        int64(0),
+		"19":                       int64(19),
+		"1_000":                    int64(1000),
+		"-1":                       int64(-1),
+		"+1":                       int64(1),
+		"0d19":                     int64(19),
+		"0D1_9":                    int64(19),
+		"0b1010":                   int64(10),
+		"0B1_0":                    int64(2),
+		"017":                      int64(15),
+		"0_17":                     int64(15),
+		"0o17":                     int64(15),
+		"0O1_7":                    int64(15),
+		"0x1f":                     int64(31),
+		"-0X1_F":                   int64(-31),
+		"9223372036854775807":      int64(math.MaxInt64),
+		"-9223372036854775808":     int64(math.MinInt64),
+		"9223372036854775808":      bigInt("9223372036854775808"),
+		"-9223372036854775809":     bigInt("-9223372036854775809"),
+		"0xffffffffffffffffffff":   bigInt("1208925819614629174706175"),
+		"1.5":                      1.5,
+		"-1.5e-3":                  -1.5e-3,
+		"1e10":                     1e10,
+		"1E+3":                     1e3,
+		"1_000.000_1":              1000.0001,
+		"0.1":                      0.1,
+		"1e400":                    math.Inf(1),
+		"-1e400":                   math.Inf(-1),
+		"1e-400":                   0.0,
+		"3r":                       rat("3"),
+		"-3r":                      rat("-3"),
+		"1.5r":                     rat("3/2"),
+		"0.1r":                     rat("1/10"),
+		"0x1fr":                    rat("31"),
+		"017r":                     rat("15"),
+		"2i":                       Complex{int64(0), int64(2)},
+		"0b11i":                    Complex{int64(0), int64(3)},
+		"1.5i":                     Complex{int64(0), 1.5},
+		"1e3i":                     Complex{int64(0), 1e3},
+		"1.5ri":                    Complex{int64(0), rat("3/2")},
+		"-3ri":                     Complex{int64(0), rat("-3")},
+		"99999999999999999999i":    Complex{int64(0), bigInt("99999999999999999999")},
+		"12345678901234567890123r": rat("12345678901234567890123"),
+	}
+	for input, want := range rules {
+		s := scanner.NewString(input)
+		_, tok, lit := s.Scan()
+		got, err := Parse(tok, lit)
+		if err != nil {
+			t.Errorf("%v: err=%v (want=nil)", input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: value=%#v (want=%#v)", input, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	rules := map[string]struct {
+		t   token.Token
+		err error
+	}{
+		"":        {token.DecimalInteger, ErrSyntax},
+		"1__2":    {token.DecimalInteger, ErrSyntax},
+		"1_":      {token.DecimalInteger, ErrSyntax},
+		"0x":      {token.HexadecimalInteger, ErrSyntax},
+		"0b102":   {token.BinaryInteger, ErrSyntax},
+		"08":      {token.OctadecimalInteger, ErrSyntax},
+		"1._5":    {token.Float, ErrSyntax},
+		"1e_5":    {token.Float, ErrSyntax},
+		"inf":     {token.Float, ErrSyntax},
+		"0x1p3":   {token.Float, ErrSyntax},
+		"3":       {token.Rational, ErrSyntax},
+		"3r":      {token.Imaginary, ErrSyntax},
+		"1.5_ri":  {token.Imaginary, ErrSyntax},
+		"abc":     {token.String, ErrToken},
+		"1__000i": {token.Imaginary, ErrSyntax},
+	}
+	for input, want := range rules {
+		_, err := Parse(want.t, []byte(input))
+		if e, ok := err.(*Error); !ok || e.Err != want.err {
+			t.Errorf("%v: err=%v (want=%v)", input, err, want.err)
+		}
+	}
+}
+
+func TestIntBase(t *testing.T) {
+	rules := map[string]struct {
+		base int
+		want interface{}
+	}{
+		"0b101":          {0, int64(5)},
+		"-0x1_f":         {0, int64(-31)},
+		"0x1f":           {16, int64(31)},
+		"1f":             {16, int64(31)},
+		"0b1":            {16, int64(177)},
+		"017":            {8, int64(15)},
+		"010":            {10, int64(10)},
+		"0d10":           {10, int64(10)},
+		"z":              {36, int64(35)},
+		"-1_0":           {3, int64(-3)},
+		"zzzzzzzzzzzzzz": {36, bigInt("6140942214464815497215")},
+		"0X1F":           {10, nil},
+		"12":             {2, nil},
+		"1__0":           {10, nil},
+		"1":              {37, nil},
+	}
+	for input, want := range rules {
+		got, err := IntBase([]byte(input), want.base)
+		if want.want == nil {
+			if err == nil {
+				t.Errorf("%v base %d: value=%v (want error)", input, want.base, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, want.want) {
+			t.Errorf("%v base %d: value=%#v, err=%v (want=%#v)", input, want.base, got, err, want.want)
+		}
+	}
+}
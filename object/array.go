@@ -0,0 +1,1056 @@
+package object
+
+import (
+	"bytes"
+	"math"
+)
+
+// RArray is an Array.
+type RArray struct {
+	RObject
+	Elems []Value
+}
+
+// NewArray returns a new array of the elements.
+func (rt *Runtime) NewArray(elems []Value) *RArray {
+	return &RArray{Elems: elems}
+}
+
+// toAry returns the value as an array, or raises TypeError.
+func (rt *Runtime) toAry(v Value) *RArray {
+	if a, ok := v.(*RArray); ok {
+		return a
+	}
+	rt.TypeMismatch(v, "Array")
+	return nil
+}
+
+// index returns the index of the array normalized for negative indices,
+// and whether it is in the array.
+func (a *RArray) index(i Fixnum) (int, bool) {
+	n := int(i)
+	if n < 0 {
+		n += len(a.Elems)
+	}
+	return n, 0 <= n && n < len(a.Elems)
+}
+
+// subseq returns the n elements from the start as a new array, or nil if
+// the start is out of the array.
+func (rt *Runtime) subseq(a *RArray, start, n int) Value {
+	if start < 0 {
+		start += len(a.Elems)
+	}
+	if start < 0 || start > len(a.Elems) || n < 0 {
+		return Nil
+	}
+	if start+n > len(a.Elems) {
+		n = len(a.Elems) - start
+	}
+	return rt.NewArray(append([]Value(nil), a.Elems[start:start+n]...))
+}
+
+// splice replaces the n elements from the start with the values, filling
+// the gap with nil if the start is beyond the end.
+func (rt *Runtime) splice(a *RArray, start, n int, vals []Value) {
+	if start < 0 {
+		if start+len(a.Elems) < 0 {
+			rt.Raise(rt.IndexError, "index %d too small for array; minimum: -%d", start, len(a.Elems))
+		}
+		start += len(a.Elems)
+	}
+	if n < 0 {
+		rt.Raise(rt.IndexError, "negative length (%d)", n)
+	}
+	for len(a.Elems) < start {
+		a.Elems = append(a.Elems, Nil)
+	}
+	if start+n > len(a.Elems) {
+		n = len(a.Elems) - start
+	}
+	elems := make([]Value, 0, len(a.Elems)-n+len(vals))
+	elems = append(append(append(elems, a.Elems[:start]...), vals...), a.Elems[start+n:]...)
+	a.Elems = elems
+}
+
+// compareValues returns a <=> b, or the result of the block, as an int. It
+// raises ArgumentError if the values are not comparable.
+func (rt *Runtime) compareValues(a, b, blk Value) int {
+	var r Value
+	if blk != nil {
+		r = rt.Yield(blk, a, b)
+	} else {
+		r = rt.Send(a, "<=>", b)
+	}
+	return rt.cmpInt(r, a, b)
+}
+
+// cmpInt returns the sign of the result r of a <=> b, or raises
+// ArgumentError if it is not an integer.
+func (rt *Runtime) cmpInt(r, a, b Value) int {
+	switch r := r.(type) {
+	case Fixnum:
+		switch {
+		case r < 0:
+			return -1
+		case r > 0:
+			return 1
+		}
+		return 0
+	case *Bignum:
+		return r.Int.Sign()
+	}
+	rt.compareFailed(a, b)
+	return 0
+}
+
+// sortValues sorts the values by <=>, or by the block.
+func (rt *Runtime) sortValues(elems []Value, blk Value) {
+	mergeSort(elems, make([]Value, len(elems)), func(a, b Value) int {
+		return rt.compareValues(a, b, blk)
+	})
+}
+
+// mergeSort sorts the values stably, comparing the earlier values with the
+// later ones so that the errors of <=> are raised as MRI.
+func mergeSort(elems, buf []Value, cmp func(a, b Value) int) {
+	if len(elems) < 2 {
+		return
+	}
+	m := len(elems) / 2
+	mergeSort(elems[:m], buf[:m], cmp)
+	mergeSort(elems[m:], buf[m:], cmp)
+	copy(buf, elems)
+	i, j, k := 0, m, 0
+	for ; i < m && j < len(elems); k++ {
+		if cmp(buf[i], buf[j]) <= 0 {
+			elems[k] = buf[i]
+			i++
+		} else {
+			elems[k] = buf[j]
+			j++
+		}
+	}
+	k += copy(elems[k:], buf[i:m])
+	copy(elems[k:], buf[j:len(elems)])
+}
+
+// flatten appends the elements of the array flattened to the depth, or
+// all the levels if the depth is negative. It returns whether any array is
+// flattened.
+func (rt *Runtime) flatten(out []Value, a *RArray, depth int, stack map[*RArray]bool) ([]Value, bool) {
+	if stack[a] {
+		rt.Raise(rt.ArgumentError, "tried to flatten recursive array")
+	}
+	stack[a] = true
+	defer delete(stack, a)
+	changed := false
+	for _, e := range a.Elems {
+		if x, ok := e.(*RArray); ok && depth != 0 {
+			out, _ = rt.flatten(out, x, depth-1, stack)
+			changed = true
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, changed
+}
+
+// uniq returns the elements without the duplicates by eql? and hash, or by
+// the results of the block.
+func (rt *Runtime) uniq(elems []Value, blk Value) []Value {
+	seen := rt.NewHash()
+	var out []Value
+	for _, e := range elems {
+		k := e
+		if blk != nil {
+			k = rt.Yield(blk, e)
+		}
+		if _, ok := rt.HashGet(seen, k); !ok {
+			rt.HashSet(seen, k, True)
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// valueSet returns the hash of the elements as the keys.
+func (rt *Runtime) valueSet(elems []Value) *RHash {
+	h := rt.NewHash()
+	for _, e := range elems {
+		rt.HashSet(h, e, True)
+	}
+	return h
+}
+
+// join appends the elements joined with the separator, joining the nested
+// arrays recursively.
+func (rt *Runtime) join(buf *bytes.Buffer, a *RArray, sep []byte) {
+	rt.recursive("join", a, nil, func(recur bool) Value {
+		if recur {
+			rt.Raise(rt.ArgumentError, "recursive array join")
+		}
+		for i, e := range a.Elems {
+			if i > 0 {
+				buf.Write(sep)
+			}
+			switch e := e.(type) {
+			case *RString:
+				buf.Write(e.B)
+			case *RArray:
+				rt.join(buf, e, sep)
+			default:
+				buf.WriteString(rt.ToS(e))
+			}
+		}
+		return Nil
+	})
+}
+
+// arrayEqual returns whether the arrays have the elements equal by == or
+// eql?.
+func (rt *Runtime) arrayEqual(a *RArray, v Value, eql bool) bool {
+	o, ok := v.(*RArray)
+	if !ok {
+		if !eql && rt.RespondTo(v, "to_ary") {
+			return rt.Equal(v, a)
+		}
+		return false
+	}
+	if a == o {
+		return true
+	}
+	if len(a.Elems) != len(o.Elems) {
+		return false
+	}
+	return Truthy(rt.recursive("==", a, o, func(recur bool) Value {
+		if recur {
+			return True
+		}
+		for i := 0; i < len(a.Elems) && i < len(o.Elems); i++ {
+			if eql && !rt.Eql(a.Elems[i], o.Elems[i]) || !eql && !rt.Equal(a.Elems[i], o.Elems[i]) {
+				return False
+			}
+		}
+		return Bool(len(a.Elems) == len(o.Elems))
+	}))
+}
+
+// sum returns the sum of the values added to init. The floats are added by
+// the Kahan-Babuska summation as MRI.
+func (rt *Runtime) sum(init Value, elems []Value) Value {
+	v, i := init, 0
+	for ; i < len(elems) && isInteger(v) && isInteger(elems[i]); i++ {
+		v = rt.Send(v, "+", elems[i])
+	}
+	if i < len(elems) && isNumber(v) && isNumber(elems[i]) {
+		f, c := rt.toFloat(v, v), 0.0
+		for ; i < len(elems) && isNumber(elems[i]); i++ {
+			x := rt.toFloat(elems[i], elems[i])
+			t := f + x
+			switch {
+			case math.IsInf(t, 0) || math.IsNaN(t):
+				c = 0
+			case math.Abs(f) >= math.Abs(x):
+				c += (f - t) + x
+			default:
+				c += (x - t) + f
+			}
+			f = t
+		}
+		v = Float(f + c)
+	}
+	for ; i < len(elems); i++ {
+		v = rt.Send(v, "+", elems[i])
+	}
+	return v
+}
+
+func isNumber(v Value) bool {
+	switch v.(type) {
+	case Fixnum, *Bignum, Float:
+		return true
+	}
+	return false
+}
+
+func (rt *Runtime) initArray() {
+	a := rt.Array
+	rt.DefineMethod(a, "initialize", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 2 {
+			rt.CheckArity(len(args), 2)
+		}
+		rt.CheckFrozen(self)
+		if len(args) == 0 {
+			self.(*RArray).Elems = nil
+			return Nil
+		}
+		if o, ok := args[0].(*RArray); ok && len(args) == 1 {
+			self.(*RArray).Elems = append([]Value(nil), o.Elems...)
+			return Nil
+		}
+		n := rt.toInt(args[0])
+		if n < 0 {
+			rt.Raise(rt.ArgumentError, "negative array size")
+		}
+		fill := Value(Nil)
+		if len(args) == 2 {
+			fill = args[1]
+		}
+		elems := make([]Value, n)
+		for i := range elems {
+			if blk != nil {
+				elems[i] = rt.Yield(blk, Fixnum(i))
+			} else {
+				elems[i] = fill
+			}
+		}
+		self.(*RArray).Elems = elems
+		return Nil
+	})
+	rt.DefineMethod(a, "inspect", 0, arrayInspect)
+	rt.DefineMethod(a, "to_s", 0, arrayInspect)
+	rt.DefineMethod(a, "to_a", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if x := self.(*RArray); x.class != nil {
+			return rt.NewArray(append([]Value(nil), x.Elems...))
+		}
+		return self
+	})
+	rt.DefineMethod(a, "to_ary", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(a, "to_h", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		h := rt.NewHash()
+		for i, e := range self.(*RArray).Elems {
+			if blk != nil {
+				e = rt.Yield(blk, e)
+			}
+			pair, ok := e.(*RArray)
+			if !ok {
+				rt.Raise(rt.TypeError, "wrong element type %s at %d (expected array)", rt.ClassOf(e).RealClass().Name, i)
+			}
+			if len(pair.Elems) != 2 {
+				rt.Raise(rt.ArgumentError, "wrong array length at %d (expected 2, was %d)", i, len(pair.Elems))
+			}
+			rt.HashSet(h, pair.Elems[0], pair.Elems[1])
+		}
+		return h
+	})
+	rt.DefineMethod(a, "==", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.arrayEqual(self.(*RArray), args[0], false))
+	})
+	rt.DefineMethod(a, "eql?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.arrayEqual(self.(*RArray), args[0], true))
+	})
+	rt.DefineMethod(a, "hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		return rt.recursive("hash", self, nil, func(recur bool) Value {
+			h := uint64(len(x.Elems))
+			if !recur {
+				for _, e := range x.Elems {
+					h = h*31 + rt.hashValue(e)
+				}
+			}
+			return Fixnum(h >> 2)
+		})
+	})
+	rt.DefineMethod(a, "<=>", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		o, ok := args[0].(*RArray)
+		if !ok {
+			return Nil
+		}
+		return rt.recursive("<=>", x, o, func(recur bool) Value {
+			if recur {
+				return Nil
+			}
+			for i := 0; i < len(x.Elems) && i < len(o.Elems); i++ {
+				if r := rt.Send(x.Elems[i], "<=>", o.Elems[i]); r != Fixnum(0) {
+					return r
+				}
+			}
+			return compareFloat(float64(len(x.Elems)), float64(len(o.Elems)))
+		})
+	})
+	rt.DefineMethod(a, "[]", -2, arrayAref)
+	rt.DefineMethod(a, "slice", -2, arrayAref)
+	rt.DefineMethod(a, "[]=", -3, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 3 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 2..3)", len(args))
+		}
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		v := args[len(args)-1]
+		r, isRange := args[0].(*RRange)
+		if len(args) == 3 || isRange {
+			vals := []Value{v}
+			if o, ok := v.(*RArray); ok {
+				vals = append([]Value(nil), o.Elems...)
+			}
+			if isRange && len(args) == 2 {
+				start, n, _ := rt.rangeBegLen(r, len(x.Elems), 1)
+				rt.splice(x, start, n, vals)
+			} else {
+				rt.splice(x, int(rt.toInt(args[0])), int(rt.toInt(args[1])), vals)
+			}
+			return v
+		}
+		i, ok := x.index(rt.toInt(args[0]))
+		if !ok {
+			if i < 0 {
+				rt.Raise(rt.IndexError, "index %d too small for array; minimum: -%d", rt.toInt(args[0]), len(x.Elems))
+			}
+			for len(x.Elems) <= i {
+				x.Elems = append(x.Elems, Nil)
+			}
+		}
+		x.Elems[i] = v
+		return v
+	})
+	rt.DefineMethod(a, "at", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		if i, ok := x.index(rt.toInt(args[0])); ok {
+			return x.Elems[i]
+		}
+		return Nil
+	})
+	rt.DefineMethod(a, "fetch", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 2 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+		}
+		x := self.(*RArray)
+		n := rt.toInt(args[0])
+		if i, ok := x.index(n); ok {
+			return x.Elems[i]
+		}
+		switch {
+		case blk != nil:
+			return rt.Yield(blk, args[0])
+		case len(args) == 2:
+			return args[1]
+		}
+		rt.Raise(rt.IndexError, "index %d outside of array bounds: %d...%d", n, -len(x.Elems), len(x.Elems))
+		return nil
+	})
+	rt.DefineMethod(a, "dig", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.dig(arrayAref(rt, self, args[:1], nil), args[1:])
+	})
+	rt.DefineMethod(a, "values_at", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		var vals []Value
+		for _, n := range args {
+			if r, ok := n.(*RRange); ok {
+				start, size, _ := rt.rangeBegLen(r, len(x.Elems), 1)
+				for j := start; j < start+size; j++ {
+					if j < len(x.Elems) {
+						vals = append(vals, x.Elems[j])
+					} else {
+						vals = append(vals, Nil)
+					}
+				}
+				continue
+			}
+			v := Value(Nil)
+			if j, ok := x.index(rt.toInt(n)); ok {
+				v = x.Elems[j]
+			}
+			vals = append(vals, v)
+		}
+		return rt.NewArray(vals)
+	})
+	rt.DefineMethod(a, "<<", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		x.Elems = append(x.Elems, args[0])
+		return self
+	})
+	for _, name := range []string{"push", "append"} {
+		rt.DefineMethod(a, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			rt.CheckFrozen(self)
+			x := self.(*RArray)
+			x.Elems = append(x.Elems, args...)
+			return self
+		})
+	}
+	rt.DefineMethod(a, "pop", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		if len(args) == 0 {
+			if len(x.Elems) == 0 {
+				return Nil
+			}
+			v := x.Elems[len(x.Elems)-1]
+			x.Elems = x.Elems[:len(x.Elems)-1]
+			return v
+		}
+		n := rt.arraySize(args)
+		if n > len(x.Elems) {
+			n = len(x.Elems)
+		}
+		vals := append([]Value(nil), x.Elems[len(x.Elems)-n:]...)
+		x.Elems = x.Elems[:len(x.Elems)-n]
+		return rt.NewArray(vals)
+	})
+	rt.DefineMethod(a, "shift", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		if len(args) == 0 {
+			if len(x.Elems) == 0 {
+				return Nil
+			}
+			v := x.Elems[0]
+			x.Elems = x.Elems[1:]
+			return v
+		}
+		n := rt.arraySize(args)
+		if n > len(x.Elems) {
+			n = len(x.Elems)
+		}
+		vals := append([]Value(nil), x.Elems[:n]...)
+		x.Elems = x.Elems[n:]
+		return rt.NewArray(vals)
+	})
+	for _, name := range []string{"unshift", "prepend"} {
+		rt.DefineMethod(a, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			rt.CheckFrozen(self)
+			x := self.(*RArray)
+			x.Elems = append(append([]Value(nil), args...), x.Elems...)
+			return self
+		})
+	}
+	rt.DefineMethod(a, "insert", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		if len(args) == 1 {
+			return self
+		}
+		i := int(rt.toInt(args[0]))
+		if i < 0 {
+			if i+len(x.Elems)+1 < 0 {
+				rt.Raise(rt.IndexError, "index %d too small for array; minimum: -%d", i, len(x.Elems)+1)
+			}
+			i += len(x.Elems) + 1
+		}
+		rt.splice(x, i, 0, append([]Value(nil), args[1:]...))
+		return self
+	})
+	rt.DefineMethod(a, "concat", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		var elems []Value
+		for _, o := range args {
+			elems = append(elems, rt.toAry(o).Elems...)
+		}
+		x.Elems = append(x.Elems, elems...)
+		return self
+	})
+	rt.DefineMethod(a, "replace", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		self.(*RArray).Elems = append([]Value(nil), rt.toAry(args[0]).Elems...)
+		return self
+	})
+	rt.DefineMethod(a, "delete", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		var found Value
+		elems := x.Elems[:0:0]
+		for _, e := range x.Elems {
+			if rt.Equal(e, args[0]) {
+				found = e
+				continue
+			}
+			elems = append(elems, e)
+		}
+		if found == nil {
+			if blk != nil {
+				return rt.Yield(blk, args[0])
+			}
+			return Nil
+		}
+		rt.CheckFrozen(self)
+		x.Elems = elems
+		return found
+	})
+	rt.DefineMethod(a, "delete_at", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		i, ok := x.index(rt.toInt(args[0]))
+		if !ok {
+			return Nil
+		}
+		v := x.Elems[i]
+		rt.splice(x, i, 1, nil)
+		return v
+	})
+	rt.DefineMethod(a, "slice!", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		v := arrayAref(rt, self, args, nil)
+		switch {
+		case len(args) == 2 && v != Nil:
+			start := int(rt.toInt(args[0]))
+			if start < 0 {
+				start += len(x.Elems)
+			}
+			rt.splice(x, start, len(v.(*RArray).Elems), nil)
+		case len(args) == 1:
+			if r, ok := args[0].(*RRange); ok {
+				if v != Nil {
+					start, _, _ := rt.rangeBegLen(r, len(x.Elems), 0)
+					rt.splice(x, start, len(v.(*RArray).Elems), nil)
+				}
+			} else if i, ok := x.index(rt.toInt(args[0])); ok {
+				rt.splice(x, i, 1, nil)
+			}
+		}
+		return v
+	})
+	rt.DefineMethod(a, "clear", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		self.(*RArray).Elems = nil
+		return self
+	})
+	rt.DefineMethod(a, "fill", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		var v Value
+		if blk == nil {
+			if len(args) == 0 {
+				rt.CheckArity(0, -2)
+			}
+			v, args = args[0], args[1:]
+		}
+		if len(args) > 2 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..2)", len(args))
+		}
+		start, end := 0, len(x.Elems)
+		if len(args) > 0 && args[0] != Nil {
+			start = int(rt.toInt(args[0]))
+			if start < 0 {
+				start += len(x.Elems)
+				if start < 0 {
+					start = 0
+				}
+			}
+			if len(args) == 1 && start > end {
+				end = start
+			}
+		}
+		if len(args) == 2 && args[1] != Nil {
+			end = start + int(rt.toInt(args[1]))
+		}
+		for len(x.Elems) < end {
+			x.Elems = append(x.Elems, Nil)
+		}
+		for i := start; i < end; i++ {
+			if blk != nil {
+				x.Elems[i] = rt.Yield(blk, Fixnum(i))
+			} else {
+				x.Elems[i] = v
+			}
+		}
+		return self
+	})
+	rt.DefineMethod(a, "length", 0, arrayLength)
+	rt.DefineMethod(a, "size", 0, arrayLength)
+	rt.DefineMethod(a, "empty?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(len(self.(*RArray).Elems) == 0)
+	})
+	rt.DefineMethod(a, "first", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		if len(args) == 0 {
+			if len(x.Elems) > 0 {
+				return x.Elems[0]
+			}
+			return Nil
+		}
+		return rt.subseq(x, 0, rt.arraySize(args))
+	})
+	rt.DefineMethod(a, "last", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		if len(args) == 0 {
+			if len(x.Elems) > 0 {
+				return x.Elems[len(x.Elems)-1]
+			}
+			return Nil
+		}
+		n := rt.arraySize(args)
+		if n > len(x.Elems) {
+			n = len(x.Elems)
+		}
+		return rt.subseq(x, len(x.Elems)-n, n)
+	})
+	rt.DefineMethod(a, "take", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		n := int(rt.toInt(args[0]))
+		if n < 0 {
+			rt.Raise(rt.ArgumentError, "attempt to take negative size")
+		}
+		return rt.subseq(self.(*RArray), 0, n)
+	})
+	rt.DefineMethod(a, "drop", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		n := int(rt.toInt(args[0]))
+		if n < 0 {
+			rt.Raise(rt.ArgumentError, "attempt to drop negative size")
+		}
+		if n > len(x.Elems) {
+			n = len(x.Elems)
+		}
+		return rt.subseq(x, n, len(x.Elems)-n)
+	})
+	rt.DefineMethod(a, "+", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		o := rt.toAry(args[0])
+		elems := append([]Value(nil), self.(*RArray).Elems...)
+		return rt.NewArray(append(elems, o.Elems...))
+	})
+	rt.DefineMethod(a, "-", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		set := rt.valueSet(rt.toAry(args[0]).Elems)
+		var elems []Value
+		for _, e := range self.(*RArray).Elems {
+			if _, ok := rt.HashGet(set, e); !ok {
+				elems = append(elems, e)
+			}
+		}
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(a, "&", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		set := rt.valueSet(rt.toAry(args[0]).Elems)
+		var elems []Value
+		for _, e := range rt.uniq(self.(*RArray).Elems, nil) {
+			if _, ok := rt.HashGet(set, e); ok {
+				elems = append(elems, e)
+			}
+		}
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(a, "|", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		elems := append([]Value(nil), self.(*RArray).Elems...)
+		return rt.NewArray(rt.uniq(append(elems, rt.toAry(args[0]).Elems...), nil))
+	})
+	rt.DefineMethod(a, "*", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		if s, ok := args[0].(*RString); ok {
+			var buf bytes.Buffer
+			rt.join(&buf, x, s.B)
+			return &RString{B: buf.Bytes()}
+		}
+		n := rt.toInt(args[0])
+		if n < 0 {
+			rt.Raise(rt.ArgumentError, "negative argument")
+		}
+		if len(x.Elems) > 0 && int64(n) > int64(maxStringSize/len(x.Elems)) {
+			rt.Raise(rt.ArgumentError, "argument too big")
+		}
+		var elems []Value
+		for i := Fixnum(0); i < n; i++ {
+			elems = append(elems, x.Elems...)
+		}
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(a, "include?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		for _, e := range self.(*RArray).Elems {
+			if rt.Equal(e, args[0]) {
+				return True
+			}
+		}
+		return False
+	})
+	for _, name := range []string{"index", "find_index"} {
+		rt.DefineMethod(a, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			x := self.(*RArray)
+			if len(args) > 1 {
+				rt.CheckArity(len(args), 1)
+			}
+			for i := 0; i < len(x.Elems); i++ {
+				if len(args) == 1 && rt.Equal(x.Elems[i], args[0]) || len(args) == 0 && Truthy(rt.Yield(blk, x.Elems[i])) {
+					return Fixnum(i)
+				}
+			}
+			return Nil
+		})
+	}
+	rt.DefineMethod(a, "rindex", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		for i := len(x.Elems) - 1; i >= 0; i-- {
+			if i >= len(x.Elems) {
+				continue
+			}
+			if len(args) == 1 && rt.Equal(x.Elems[i], args[0]) || len(args) == 0 && Truthy(rt.Yield(blk, x.Elems[i])) {
+				return Fixnum(i)
+			}
+		}
+		return Nil
+	})
+	rt.DefineMethod(a, "assoc", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		for _, e := range self.(*RArray).Elems {
+			if x, ok := e.(*RArray); ok && len(x.Elems) > 0 && rt.Equal(x.Elems[0], args[0]) {
+				return x
+			}
+		}
+		return Nil
+	})
+	rt.DefineMethod(a, "count", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		if len(args) == 0 && blk == nil {
+			return Fixnum(len(x.Elems))
+		}
+		n := 0
+		for i := 0; i < len(x.Elems); i++ {
+			if len(args) > 0 && rt.Equal(x.Elems[i], args[0]) || len(args) == 0 && Truthy(rt.Yield(blk, x.Elems[i])) {
+				n++
+			}
+		}
+		return Fixnum(n)
+	})
+	rt.DefineMethod(a, "reverse", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewArray(reverseValues(self.(*RArray).Elems))
+	})
+	rt.DefineMethod(a, "reverse!", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		x.Elems = reverseValues(x.Elems)
+		return self
+	})
+	rt.DefineMethod(a, "rotate", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		n := 1
+		if len(args) > 0 {
+			n = int(rt.toInt(args[0]))
+		}
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		if len(x.Elems) == 0 {
+			return rt.NewArray(nil)
+		}
+		n %= len(x.Elems)
+		if n < 0 {
+			n += len(x.Elems)
+		}
+		elems := append([]Value(nil), x.Elems[n:]...)
+		return rt.NewArray(append(elems, x.Elems[:n]...))
+	})
+	rt.DefineMethod(a, "join", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		var sep []byte
+		if len(args) == 1 && args[0] != Nil {
+			sep = rt.toStr(args[0]).B
+		}
+		var buf bytes.Buffer
+		rt.join(&buf, self.(*RArray), sep)
+		return &RString{B: buf.Bytes()}
+	})
+	rt.DefineMethod(a, "compact", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewArray(compactValues(self.(*RArray).Elems))
+	})
+	rt.DefineMethod(a, "compact!", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		elems := compactValues(x.Elems)
+		if len(elems) == len(x.Elems) {
+			return Nil
+		}
+		x.Elems = elems
+		return self
+	})
+	rt.DefineMethod(a, "flatten", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		elems, _ := rt.flatten(nil, self.(*RArray), rt.flattenDepth(args), map[*RArray]bool{})
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(a, "flatten!", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		elems, changed := rt.flatten(nil, x, rt.flattenDepth(args), map[*RArray]bool{})
+		if !changed {
+			return Nil
+		}
+		x.Elems = elems
+		return self
+	})
+	rt.DefineMethod(a, "uniq", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewArray(rt.uniq(self.(*RArray).Elems, blk))
+	})
+	rt.DefineMethod(a, "uniq!", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		elems := rt.uniq(x.Elems, blk)
+		if len(elems) == len(x.Elems) {
+			return Nil
+		}
+		x.Elems = elems
+		return self
+	})
+	rt.DefineMethod(a, "sort", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		elems := append([]Value(nil), self.(*RArray).Elems...)
+		rt.sortValues(elems, blk)
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(a, "sort!", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RArray)
+		elems := append([]Value(nil), x.Elems...)
+		rt.sortValues(elems, blk)
+		x.Elems = elems
+		return self
+	})
+	rt.DefineMethod(a, "transpose", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		var rows [][]Value
+		for _, e := range x.Elems {
+			rows = append(rows, rt.toAry(e).Elems)
+		}
+		if len(rows) == 0 {
+			return rt.NewArray(nil)
+		}
+		cols := make([]Value, len(rows[0]))
+		for j := range cols {
+			col := make([]Value, len(rows))
+			for i, row := range rows {
+				if len(row) != len(rows[0]) {
+					rt.Raise(rt.IndexError, "element size differs (%d should be %d)", len(row), len(rows[0]))
+				}
+				col[i] = row[j]
+			}
+			cols[j] = rt.NewArray(col)
+		}
+		return rt.NewArray(cols)
+	})
+
+	// the iterations with the blocks
+	rt.DefineMethod(a, "each", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		for i := 0; i < len(x.Elems); i++ {
+			rt.Yield(blk, x.Elems[i])
+		}
+		return self
+	})
+	rt.DefineMethod(a, "each_index", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RArray)
+		for i := 0; i < len(x.Elems); i++ {
+			rt.Yield(blk, Fixnum(i))
+		}
+		return self
+	})
+	for _, name := range []string{"map!", "collect!"} {
+		rt.DefineMethod(a, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			rt.CheckFrozen(self)
+			x := self.(*RArray)
+			for i := 0; i < len(x.Elems); i++ {
+				x.Elems[i] = rt.Yield(blk, x.Elems[i])
+			}
+			return self
+		})
+	}
+	for _, name := range []string{"select!", "filter!", "keep_if", "reject!", "delete_if"} {
+		keep := name != "reject!" && name != "delete_if"
+		bang := name[len(name)-1] == '!'
+		rt.DefineMethod(a, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			rt.CheckFrozen(self)
+			x := self.(*RArray)
+			var elems []Value
+			for i := 0; i < len(x.Elems); i++ {
+				if e := x.Elems[i]; Truthy(rt.Yield(blk, e)) == keep {
+					elems = append(elems, e)
+				}
+			}
+			changed := len(elems) != len(x.Elems)
+			x.Elems = elems
+			if bang && !changed {
+				return Nil
+			}
+			return self
+		})
+	}
+}
+
+// arraySize returns the size of first(n) and the like, or raises
+// ArgumentError if it is negative.
+func (rt *Runtime) arraySize(args []Value) int {
+	if len(args) > 1 {
+		rt.CheckArity(len(args), 1)
+	}
+	n := int(rt.toInt(args[0]))
+	if n < 0 {
+		rt.Raise(rt.ArgumentError, "negative array size")
+	}
+	return n
+}
+
+func (rt *Runtime) flattenDepth(args []Value) int {
+	if len(args) > 1 {
+		rt.CheckArity(len(args), 1)
+	}
+	if len(args) == 0 || args[0] == Nil {
+		return -1
+	}
+	return int(rt.toInt(args[0]))
+}
+
+func reverseValues(elems []Value) []Value {
+	r := make([]Value, len(elems))
+	for i, e := range elems {
+		r[len(elems)-1-i] = e
+	}
+	return r
+}
+
+func compactValues(elems []Value) []Value {
+	var r []Value
+	for _, e := range elems {
+		if e != Nil {
+			r = append(r, e)
+		}
+	}
+	return r
+}
+
+func arrayLength(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return Fixnum(len(self.(*RArray).Elems))
+}
+
+func arrayAref(rt *Runtime, self Value, args []Value, blk Value) Value {
+	if len(args) > 2 {
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+	}
+	x := self.(*RArray)
+	if len(args) == 2 {
+		return rt.subseq(x, int(rt.toInt(args[0])), int(rt.toInt(args[1])))
+	}
+	if r, ok := args[0].(*RRange); ok {
+		start, n, ok := rt.rangeBegLen(r, len(x.Elems), 0)
+		if !ok {
+			return Nil
+		}
+		return rt.subseq(x, start, n)
+	}
+	if i, ok := x.index(rt.toInt(args[0])); ok {
+		return x.Elems[i]
+	}
+	return Nil
+}
+
+func arrayInspect(rt *Runtime, self Value, args []Value, blk Value) Value {
+	x := self.(*RArray)
+	if len(x.Elems) == 0 {
+		return rt.NewString("[]")
+	}
+	return rt.recursive("inspect", self, nil, func(recur bool) Value {
+		if recur {
+			return rt.NewString("[...]")
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i := 0; i < len(x.Elems); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(rt.Inspect(x.Elems[i]))
+		}
+		buf.WriteByte(']')
+		return &RString{B: buf.Bytes()}
+	})
+}
@@ -0,0 +1,157 @@
+package object
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// caseMapping is the case mapping of String#upcase and the like.
+type caseMapping int
+
+const (
+	caseUpper caseMapping = iota
+	caseLower
+	caseCapitalize
+	caseSwap
+)
+
+func (m caseMapping) String() string {
+	return [...]string{"upcase", "downcase", "capitalize", "swapcase"}[m]
+}
+
+// caseOpts is the options of the case mappings.
+type caseOpts struct {
+	ascii  bool // map only ASCII characters
+	turkic bool // map i to İ and ı to I
+	fold   bool // fold the case instead of downcasing
+}
+
+// caseOptions returns the options given as the symbols :ascii, :turkic,
+// :lithuanian or :fold.
+func (rt *Runtime) caseOptions(m caseMapping, args []Value) caseOpts {
+	var opts caseOpts
+	if len(args) > 2 {
+		rt.Raise(rt.ArgumentError, "too many options")
+	}
+	for _, arg := range args {
+		switch arg {
+		case SymbolOf("ascii"):
+			opts.ascii = true
+		case SymbolOf("turkic"):
+			opts.turkic = true
+		case SymbolOf("lithuanian"):
+		case SymbolOf("fold"):
+			if m != caseLower {
+				rt.Raise(rt.ArgumentError, "option :fold only allowed for downcasing")
+			}
+			opts.fold = true
+		default:
+			rt.Raise(rt.ArgumentError, "invalid option: %s", rt.Inspect(arg))
+		}
+	}
+	if opts.ascii && len(args) > 1 || opts.fold && len(args) > 1 {
+		rt.Raise(rt.ArgumentError, "too many options")
+	}
+	return opts
+}
+
+// specialCases is the unconditional mappings of the Unicode
+// SpecialCasing.txt, which map a character into multiple characters. Each
+// entry is the lower, title, upper and folded cases.
+var specialCases = map[rune][4]string{
+	'\u00DF': {"\u00DF", "Ss", "SS", "ss"},                                                 // ß
+	'\u0130': {"i\u0307", "\u0130", "\u0130", "i\u0307"},                                   // İ
+	'\u0149': {"\u0149", "\u02BCN", "\u02BCN", "\u02BCn"},                                  // ŉ
+	'\u01F0': {"\u01F0", "J\u030C", "J\u030C", "j\u030C"},                                  // ǰ
+	'\u0390': {"\u0390", "\u0399\u0308\u0301", "\u0399\u0308\u0301", "\u03B9\u0308\u0301"}, // ΐ
+	'\u03B0': {"\u03B0", "\u03A5\u0308\u0301", "\u03A5\u0308\u0301", "\u03C5\u0308\u0301"}, // ΰ
+	'\u0587': {"\u0587", "\u0535\u0582", "\u0535\u0552", "\u0565\u0582"},                   // և
+	'\uFB00': {"\uFB00", "Ff", "FF", "ff"},
+	'\uFB01': {"\uFB01", "Fi", "FI", "fi"},
+	'\uFB02': {"\uFB02", "Fl", "FL", "fl"},
+	'\uFB03': {"\uFB03", "Ffi", "FFI", "ffi"},
+	'\uFB04': {"\uFB04", "Ffl", "FFL", "ffl"},
+	'\uFB05': {"\uFB05", "St", "ST", "st"},
+	'\uFB06': {"\uFB06", "St", "ST", "st"},
+}
+
+// the indices of specialCases
+const (
+	toLower = iota
+	toTitle
+	toUpper
+	toFold
+)
+
+// caseMap maps the cases of the characters of b. The full Unicode case
+// mappings are applied to UTF-8, and only ASCII characters are mapped in
+// the other encodings.
+func caseMap(b []byte, e *Encoding, m caseMapping, opts caseOpts) []byte {
+	ascii := opts.ascii || e != UTF8
+	out := make([]byte, 0, len(b))
+	first := true
+	for i := 0; i < len(b); {
+		r, n := utf8.DecodeRune(b[i:])
+		if r == utf8.RuneError && n <= 1 || ascii && r >= utf8.RuneSelf {
+			out = append(out, b[i])
+			i, first = i+1, false
+			continue
+		}
+		to := toLower
+		switch m {
+		case caseUpper:
+			to = toUpper
+		case caseCapitalize:
+			if first {
+				to = toTitle
+			}
+		case caseSwap:
+			if !unicode.IsUpper(r) && !unicode.IsTitle(r) {
+				to = toUpper
+			}
+		}
+		if to == toLower && opts.fold {
+			to = toFold
+		}
+		out = mapRune(out, r, to, ascii, opts.turkic)
+		i, first = i+n, false
+	}
+	return out
+}
+
+// mapRune appends the case of the character.
+func mapRune(b []byte, r rune, to int, ascii, turkic bool) []byte {
+	switch {
+	case ascii:
+		if to == toLower || to == toFold {
+			if 'A' <= r && r <= 'Z' {
+				r += 'a' - 'A'
+			}
+		} else if 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return append(b, byte(r))
+	case turkic:
+		switch {
+		case r == 'i' && (to == toUpper || to == toTitle):
+			return append(b, "İ"...)
+		case r == 'I' && (to == toLower || to == toFold):
+			return append(b, "ı"...)
+		case r == 'İ' && (to == toLower || to == toFold):
+			return append(b, 'i')
+		}
+	}
+	if s, ok := specialCases[r]; ok {
+		return append(b, s[to]...)
+	}
+	switch to {
+	case toUpper:
+		r = unicode.ToUpper(r)
+	case toTitle:
+		r = unicode.ToTitle(r)
+	default:
+		r = unicode.ToLower(r)
+	}
+	var buf [utf8.UTFMax]byte
+	return append(b, buf[:utf8.EncodeRune(buf[:], r)]...)
+}
@@ -0,0 +1,520 @@
+package object
+
+import "fmt"
+
+// RClass is a class or a module.
+//
+// The modules are mixed in by the include classes, which are inserted in the
+// chain of Super sharing the tables of the modules like MRI. The methods of
+// the class prepending modules are moved to its origin, which is an include
+// class placed after the prepended modules.
+type RClass struct {
+	RObject
+	Name     string // full path such as A::B, or empty for anonymous classes
+	Super    *RClass
+	IsModule bool
+	Methods  map[ID]*Method // nil method for undefined method
+	Consts   map[string]Value
+	CVars    map[string]Value
+
+	Singleton bool    // singleton class of Attached
+	Attached  Value   // object of the singleton class
+	IClass    bool    // include class of Module
+	Module    *RClass // module or class of the include class
+	Origin    *RClass // include class holding the methods, or nil
+}
+
+// Inspect returns the name, or #<Class:0x...> for anonymous classes.
+func (c *RClass) Inspect() string {
+	if c.IClass {
+		return c.Module.Inspect()
+	}
+	if c.Singleton {
+		if a, ok := c.Attached.(*RClass); ok {
+			return "#<Class:" + a.Inspect() + ">"
+		}
+		o := c.Attached.(heapObject).object()
+		return fmt.Sprintf("#<Class:#<%s:0x%016x>>", c.Super.RealClass().Name, o.id*8)
+	}
+	if c.Name != "" {
+		return c.Name
+	}
+	kind := "Class"
+	if c.IsModule {
+		kind = "Module"
+	}
+	return fmt.Sprintf("#<%s:0x%016x>", kind, c.id*8)
+}
+
+// RealClass returns the class skipping the singleton classes and the
+// include classes.
+func (c *RClass) RealClass() *RClass {
+	for c != nil && (c.Singleton || c.IClass) {
+		c = c.Super
+	}
+	return c
+}
+
+// Superclass returns the superclass skipping the include classes, or nil.
+func (c *RClass) Superclass() *RClass {
+	k := c.Super
+	for k != nil && k.IClass {
+		k = k.Super
+	}
+	return k
+}
+
+// origin returns the class holding the methods of c.
+func (c *RClass) origin() *RClass {
+	if c.Origin != nil {
+		return c.Origin
+	}
+	return c
+}
+
+// newClass returns a class without the class of class, which is set by the
+// callers after Class is created.
+func (rt *Runtime) newClass(name string, super *RClass, module bool) *RClass {
+	c := &RClass{
+		Name:     name,
+		Super:    super,
+		IsModule: module,
+		Methods:  map[ID]*Method{},
+		Consts:   map[string]Value{},
+		CVars:    map[string]Value{},
+	}
+	rt.track(&c.RObject)
+	return c
+}
+
+// newIClass returns the include class of the module m, sharing the tables of
+// k which is m or the include class in its ancestors.
+func (rt *Runtime) newIClass(m, k, super *RClass) *RClass {
+	c := &RClass{
+		Name:     m.Name,
+		Super:    super,
+		IsModule: true,
+		Methods:  k.Methods,
+		Consts:   m.Consts,
+		CVars:    m.CVars,
+		IClass:   true,
+		Module:   m,
+	}
+	rt.track(&c.RObject)
+	return c
+}
+
+func (rt *Runtime) initClasses() {
+	rt.BasicObject = rt.newClass("BasicObject", nil, false)
+	rt.Object = rt.newClass("Object", rt.BasicObject, false)
+	rt.Module = rt.newClass("Module", rt.Object, false)
+	rt.Class = rt.newClass("Class", rt.Module, false)
+	for _, c := range []*RClass{rt.BasicObject, rt.Object, rt.Module, rt.Class} {
+		rt.Object.Consts[c.Name] = c
+	}
+	rt.Kernel = rt.DefineModule("Kernel", nil)
+	rt.Include(rt.Object, rt.Kernel)
+	rt.Comparable = rt.DefineModule("Comparable", nil)
+	rt.Enumerable = rt.DefineModule("Enumerable", nil)
+	rt.NilClass = rt.DefineClass("NilClass", rt.Object, nil)
+	rt.TrueClass = rt.DefineClass("TrueClass", rt.Object, nil)
+	rt.FalseClass = rt.DefineClass("FalseClass", rt.Object, nil)
+	rt.Numeric = rt.DefineClass("Numeric", rt.Object, nil)
+	rt.Include(rt.Numeric, rt.Comparable)
+	rt.Integer = rt.DefineClass("Integer", rt.Numeric, nil)
+	rt.Float = rt.DefineClass("Float", rt.Numeric, nil)
+	rt.String = rt.DefineClass("String", rt.Object, nil)
+	rt.Include(rt.String, rt.Comparable)
+	rt.Symbol = rt.DefineClass("Symbol", rt.Object, nil)
+	rt.Include(rt.Symbol, rt.Comparable)
+	rt.Array = rt.DefineClass("Array", rt.Object, nil)
+	rt.Include(rt.Array, rt.Enumerable)
+	rt.Hash = rt.DefineClass("Hash", rt.Object, nil)
+	rt.Include(rt.Hash, rt.Enumerable)
+	rt.Range = rt.DefineClass("Range", rt.Object, nil)
+	rt.Include(rt.Range, rt.Enumerable)
+	rt.Proc = rt.DefineClass("Proc", rt.Object, nil)
+}
+
+// DefineClass returns the class of the name under the namespace, defining it
+// if it does not exist. The namespace is Object if nil.
+func (rt *Runtime) DefineClass(name string, super *RClass, namespace *RClass) *RClass {
+	if namespace == nil {
+		namespace = rt.Object
+	}
+	if v, ok := namespace.Consts[name]; ok {
+		c, ok := v.(*RClass)
+		if !ok || c.IsModule {
+			rt.Raise(rt.TypeError, "%s is not a class", name)
+		}
+		if super != nil && c.Superclass() != super {
+			rt.Raise(rt.TypeError, "superclass mismatch for class %s", name)
+		}
+		return c
+	}
+	if super == nil {
+		super = rt.Object
+	}
+	c := rt.newClass(rt.qualify(namespace, name), super, false)
+	namespace.Consts[name] = c
+	rt.inherited(super, c)
+	return c
+}
+
+// DefineModule returns the module of the name under the namespace, defining
+// it if it does not exist. The namespace is Object if nil.
+func (rt *Runtime) DefineModule(name string, namespace *RClass) *RClass {
+	if namespace == nil {
+		namespace = rt.Object
+	}
+	if v, ok := namespace.Consts[name]; ok {
+		c, ok := v.(*RClass)
+		if !ok || !c.IsModule {
+			rt.Raise(rt.TypeError, "%s is not a module", name)
+		}
+		return c
+	}
+	m := rt.newClass(rt.qualify(namespace, name), nil, true)
+	namespace.Consts[name] = m
+	return m
+}
+
+// NewClass returns an anonymous class such as Class.new.
+func (rt *Runtime) NewClass(super *RClass) *RClass {
+	c := rt.newClass("", super, false)
+	rt.inherited(super, c)
+	return c
+}
+
+// inherited calls the hook of the superclass for the new class. The hook is
+// not called while the builtin classes are defined.
+func (rt *Runtime) inherited(super, c *RClass) {
+	if rt.Main != nil {
+		rt.Send(super, "inherited", c)
+	}
+}
+
+func (rt *Runtime) qualify(namespace *RClass, name string) string {
+	if namespace == rt.Object || namespace.Name == "" {
+		return name
+	}
+	return namespace.Name + "::" + name
+}
+
+// DefineMethod defines the builtin method of the class.
+func (rt *Runtime) DefineMethod(c *RClass, name string, arity int, fn BuiltinFunc) {
+	id := Intern(name)
+	c.origin().Methods[id] = &Method{Name: id, Owner: c, Arity: arity, Fn: fn}
+	rt.serial++
+}
+
+// definePrivate defines the private builtin method of the class.
+func (rt *Runtime) definePrivate(c *RClass, name string, arity int, fn BuiltinFunc) {
+	id := Intern(name)
+	c.origin().Methods[id] = &Method{Name: id, Owner: c, Visibility: Private, Arity: arity, Fn: fn}
+	rt.serial++
+}
+
+// AddMethod adds the method to the class.
+func (rt *Runtime) AddMethod(c *RClass, m *Method) {
+	rt.CheckFrozen(c)
+	m.Owner = c
+	c.origin().Methods[m.Name] = m
+	rt.serial++
+}
+
+// AliasMethod defines the new name of the method.
+func (rt *Runtime) AliasMethod(c *RClass, newName, oldName ID) {
+	m := rt.FindMethod(c, oldName)
+	if m == nil {
+		rt.Raise(rt.NameError, "undefined method `%s' for class `%s'", oldName, c.Inspect())
+	}
+	alias := *m
+	alias.Name = newName
+	c.origin().Methods[newName] = &alias
+	rt.serial++
+}
+
+// UndefMethod undefines the method, which hides the methods of ancestors.
+func (rt *Runtime) UndefMethod(c *RClass, name ID) {
+	if rt.FindMethod(c, name) == nil {
+		rt.Raise(rt.NameError, "undefined method `%s' for class `%s'", name, c.Inspect())
+	}
+	c.origin().Methods[name] = nil
+	rt.serial++
+}
+
+// SetVisibility changes the visibility of the method, copying it to the
+// class if it is defined by the ancestors.
+func (rt *Runtime) SetVisibility(c *RClass, name ID, v Visibility) {
+	m := rt.FindMethod(c, name)
+	if m == nil {
+		rt.Raise(rt.NameError, "undefined method `%s' for class `%s'", name, c.Inspect())
+	}
+	if m.Owner != c {
+		copied := *m
+		m = &copied
+		m.Owner = c
+		c.origin().Methods[name] = m
+	}
+	m.Visibility = v
+	rt.serial++
+}
+
+// RemoveMethod removes the method defined by the class.
+func (rt *Runtime) RemoveMethod(c *RClass, name ID) {
+	if c.origin().Methods[name] == nil {
+		rt.Raise(rt.NameError, "method `%s' not defined in %s", name, c.Inspect())
+	}
+	delete(c.origin().Methods, name)
+	rt.serial++
+}
+
+// MethodSerial returns the number incremented when any method is defined,
+// removed, or changed, which invalidates the caches of method lookups.
+func (rt *Runtime) MethodSerial() uint64 {
+	return rt.serial
+}
+
+// IsKindOf returns whether the value is an instance of the class or its
+// descendants.
+func (rt *Runtime) IsKindOf(v Value, c *RClass) bool {
+	return rt.isSubclass(rt.ClassOf(v), c)
+}
+
+// Ancestors returns the class and its ancestors including the modules, in
+// the order of the method resolution.
+func (rt *Runtime) Ancestors(c *RClass) []*RClass {
+	var list []*RClass
+	for ; c != nil; c = c.Super {
+		switch {
+		case c.Origin != nil:
+			// listed at the origin
+		case c.IClass:
+			list = append(list, c.Module)
+		default:
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// Include includes the module in the class. The module and the modules
+// included by it are inserted after the class unless they are already in
+// the ancestors, which is the same order as MRI for the diamonds.
+func (rt *Runtime) Include(c, m *RClass) {
+	rt.checkMixin(c, m, "include")
+	rt.includeModules(c, c.origin(), m, true)
+}
+
+// Prepend prepends the module to the class, moving the methods of the class
+// to its origin.
+func (rt *Runtime) Prepend(c, m *RClass) {
+	rt.checkMixin(c, m, "prepend")
+	if c.Origin == nil {
+		o := rt.newIClass(c, c, c.Super)
+		o.IsModule = c.IsModule
+		o.Consts = map[string]Value{}
+		o.CVars = map[string]Value{}
+		c.Methods = map[ID]*Method{}
+		c.Origin = o
+		c.Super = o
+	}
+	rt.includeModules(c, c, m, false)
+}
+
+// Extend includes the module in the singleton class of the value.
+func (rt *Runtime) Extend(v Value, m *RClass) {
+	rt.Include(rt.SingletonClassOf(v), m)
+}
+
+func (rt *Runtime) checkMixin(c, m *RClass, op string) {
+	if !m.IsModule || m.IClass {
+		rt.Raise(rt.TypeError, "wrong argument type %s (expected Module)", rt.ClassOf(m).RealClass().Name)
+	}
+	rt.CheckFrozen(c)
+	if rt.isSubclass(m, c) {
+		rt.Raise(rt.ArgumentError, "cyclic %s detected", op)
+	}
+}
+
+// includeModules inserts the include classes of m and its ancestors after
+// the class c, which is klass, its origin, or an include class in the
+// ancestors of klass. It follows include_modules_at of MRI: a module is
+// skipped if it is already included, moving the insertion point to it if it
+// is not beyond the superclass.
+func (rt *Runtime) includeModules(klass, c, m *RClass, searchSuper bool) {
+	origin := klass.origin()
+	for k := m; k != nil; k = k.Super {
+		if !k.IClass && k.Origin != nil {
+			continue // the methods are in the origin
+		}
+		mod := k
+		if k.IClass {
+			mod = k.Module
+		}
+		cSeen := klass == c
+		superSeen := false
+		found := false
+		if origin != c || searchSuper {
+			for p := klass.Super; p != nil; p = p.Super {
+				if p == origin && !searchSuper {
+					break
+				}
+				if p == c {
+					cSeen = true
+				}
+				if !p.IClass {
+					superSeen = true
+				} else if p.Module == mod {
+					if !superSeen && cSeen {
+						c = p
+					}
+					found = true
+					break
+				}
+			}
+		}
+		if found {
+			continue
+		}
+		c.Super = rt.newIClass(mod, k, c.Super)
+		c = c.Super
+	}
+	rt.serial++
+}
+
+// SingletonClassOf returns the singleton class of the value, creating it if
+// needed. The singleton classes of nil, true and false are their classes.
+func (rt *Runtime) SingletonClassOf(v Value) *RClass {
+	switch v.(type) {
+	case nilValue, Bool:
+		return rt.ClassOf(v)
+	case Fixnum, Float, Symbol:
+		rt.Raise(rt.TypeError, "can't define singleton")
+	}
+	o := v.(heapObject).object()
+	if c := o.class; c != nil && c.Singleton && c.Attached == v {
+		return c
+	}
+	var super *RClass
+	if c, ok := v.(*RClass); ok {
+		// The singleton class of a class inherits the singleton class of
+		// the superclass, so that the class methods are inherited.
+		switch s := c.Superclass(); {
+		case c.IsModule:
+			super = rt.Module
+		case s == nil:
+			super = rt.Class
+		default:
+			super = rt.SingletonClassOf(s)
+		}
+	} else {
+		super = rt.ClassOf(v)
+	}
+	sc := rt.newClass("", super, false)
+	sc.Singleton = true
+	sc.Attached = v
+	o.class = sc
+	return sc
+}
+
+// CvarGet returns the class variable of the class or its ancestors, raising
+// NameError if it is not defined.
+func (rt *Runtime) CvarGet(c *RClass, name string) Value {
+	k := rt.cvarLookup(c, name)
+	if k == nil {
+		rt.Raise(rt.NameError, "uninitialized class variable %s in %s", name, cvarBase(c).Inspect())
+	}
+	return k.CVars[name]
+}
+
+// CvarSet sets the class variable of the ancestor defining it, or of the
+// class if it is not defined yet.
+func (rt *Runtime) CvarSet(c *RClass, name string, v Value) {
+	k := rt.cvarLookup(c, name)
+	if k == nil {
+		k = cvarBase(c)
+	}
+	rt.CheckFrozen(k)
+	k.CVars[name] = v
+}
+
+// CvarDefined returns whether the class variable is defined by the class or
+// its ancestors.
+func (rt *Runtime) CvarDefined(c *RClass, name string) bool {
+	return rt.cvarLookup(c, name) != nil
+}
+
+// cvarBase returns the class looking up the class variables for c, which is
+// the attached class for the singleton classes of classes.
+func cvarBase(c *RClass) *RClass {
+	for c.Singleton {
+		a, ok := c.Attached.(*RClass)
+		if !ok {
+			break
+		}
+		c = a
+	}
+	return c
+}
+
+// cvarLookup returns the class or the include class holding the class
+// variable, or nil. It raises RuntimeError if the variable is also defined
+// by a different ancestor, which overtakes the one of the descendant.
+func (rt *Runtime) cvarLookup(c *RClass, name string) *RClass {
+	var front, target *RClass
+	for k := cvarBase(c); k != nil; k = k.Super {
+		if _, ok := k.CVars[name]; ok {
+			if front == nil {
+				front = k
+			}
+			target = k
+		}
+	}
+	if front != nil && !sameModule(front, target) {
+		rt.Raise(rt.RuntimeError, "class variable %s of %s is overtaken by %s", name, front.Inspect(), target.Inspect())
+	}
+	return front
+}
+
+func sameModule(a, b *RClass) bool {
+	if a.IClass {
+		a = a.Module
+	}
+	if b.IClass {
+		b = b.Module
+	}
+	return a == b
+}
+
+// ConstGet returns the constant of the class or its ancestors. The
+// constants of Object are found for modules.
+func (rt *Runtime) ConstGet(c *RClass, name string) (Value, bool) {
+	for k := c; k != nil; k = k.Super {
+		if v, ok := k.Consts[name]; ok {
+			return v, true
+		}
+	}
+	if c.IsModule {
+		v, ok := rt.Object.Consts[name]
+		return v, ok
+	}
+	return nil, false
+}
+
+// ConstMissing raises NameError for the uninitialized constant.
+func (rt *Runtime) ConstMissing(c *RClass, name string) {
+	if c == nil || c == rt.Object {
+		rt.Raise(rt.NameError, "uninitialized constant %s", name)
+	}
+	rt.Raise(rt.NameError, "uninitialized constant %s::%s", c.Inspect(), name)
+}
+
+// ConstSet sets the constant of the class, naming the anonymous class.
+func (rt *Runtime) ConstSet(c *RClass, name string, v Value) {
+	if k, ok := v.(*RClass); ok && k.Name == "" {
+		k.Name = rt.qualify(c, name)
+	}
+	c.Consts[name] = v
+}
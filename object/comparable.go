@@ -0,0 +1,53 @@
+package object
+
+func (rt *Runtime) initComparable() {
+	c := rt.Comparable
+	rt.defineCompare(c)
+	rt.DefineMethod(c, "==", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if self == args[0] {
+			return True
+		}
+		// Kernel#<=> calls == back, which is false in the recursion.
+		return rt.recursive("==", self, args[0], func(recur bool) Value {
+			if recur {
+				return False
+			}
+			r := rt.Send(self, "<=>", args[0])
+			return Bool(r != Nil && rt.cmpInt(r, self, args[0]) == 0)
+		})
+	})
+	rt.DefineMethod(c, "between?", 2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.compareValues(self, args[0], nil) >= 0 && rt.compareValues(self, args[1], nil) <= 0)
+	})
+	rt.DefineMethod(c, "clamp", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var min, max Value
+		switch len(args) {
+		case 1:
+			r, ok := args[0].(*RRange)
+			if !ok {
+				rt.Raise(rt.TypeError, "wrong argument type %s (expected Range)", rt.ClassOf(args[0]).RealClass().Name)
+			}
+			if r.Excl {
+				rt.Raise(rt.ArgumentError, "cannot clamp with an exclusive range")
+			}
+			min, max = r.Begin, r.End
+		case 2:
+			min, max = args[0], args[1]
+		default:
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+		}
+		if rt.compareValues(min, max, nil) > 0 {
+			rt.Raise(rt.ArgumentError, "min argument must be smaller than max argument")
+		}
+		switch c := rt.compareValues(self, min, nil); {
+		case c == 0:
+			return self
+		case c < 0:
+			return min
+		}
+		if rt.compareValues(self, max, nil) > 0 {
+			return max
+		}
+		return self
+	})
+}
@@ -0,0 +1,272 @@
+package object
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encoding is a character encoding of strings. A string is the bytes tagged
+// with the encoding, which may be invalid in the encoding. All the
+// supported encodings are compatible with ASCII.
+type Encoding struct {
+	Name    string
+	Aliases []string
+}
+
+// The supported encodings. Binary is ASCII-8BIT, which treats each byte as
+// a character.
+var (
+	UTF8    = &Encoding{Name: "UTF-8", Aliases: []string{"CP65001"}}
+	Binary  = &Encoding{Name: "ASCII-8BIT", Aliases: []string{"BINARY"}}
+	USASCII = &Encoding{Name: "US-ASCII", Aliases: []string{"ASCII", "ANSI_X3.4-1968", "646"}}
+)
+
+var encodings = []*Encoding{Binary, UTF8, USASCII}
+
+// FindEncoding returns the encoding of the name or the alias, which is case
+// insensitive, or nil if it is not supported.
+func FindEncoding(name string) *Encoding {
+	for _, e := range encodings {
+		if strings.EqualFold(e.Name, name) {
+			return e
+		}
+		for _, alias := range e.Aliases {
+			if strings.EqualFold(alias, name) {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// charLen returns the byte length of the first character of b, where an
+// invalid byte is a character.
+func (e *Encoding) charLen(b []byte) int {
+	if e == UTF8 {
+		_, n := utf8.DecodeRune(b)
+		return n
+	}
+	if len(b) == 0 {
+		return 0
+	}
+	return 1
+}
+
+// charCount returns the number of the characters of b.
+func (e *Encoding) charCount(b []byte) int {
+	if e == UTF8 {
+		return utf8.RuneCount(b)
+	}
+	return len(b)
+}
+
+// offsets returns the byte offsets of the characters of b, followed by the
+// length of b.
+func (e *Encoding) offsets(b []byte) []int {
+	offs := make([]int, 0, len(b)+1)
+	for i := 0; i < len(b); i += e.charLen(b[i:]) {
+		offs = append(offs, i)
+	}
+	return append(offs, len(b))
+}
+
+// Valid returns whether b is valid in the encoding.
+func (e *Encoding) Valid(b []byte) bool {
+	switch e {
+	case UTF8:
+		return utf8.Valid(b)
+	case USASCII:
+		return isASCII(b)
+	}
+	return true
+}
+
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// REncoding is an Encoding object.
+type REncoding struct {
+	RObject
+	Enc *Encoding
+}
+
+// EncodingObject returns the Encoding object of the encoding.
+func (rt *Runtime) EncodingObject(e *Encoding) *REncoding {
+	return rt.encodings[e]
+}
+
+// toEncoding returns the encoding of an Encoding object or the name.
+func (rt *Runtime) toEncoding(v Value) *Encoding {
+	switch v := v.(type) {
+	case *REncoding:
+		return v.Enc
+	case *RString:
+		if e := FindEncoding(string(v.B)); e != nil {
+			return e
+		}
+		rt.Raise(rt.ArgumentError, "unknown encoding name - %s", v.B)
+	}
+	rt.TypeMismatch(v, "String")
+	return nil
+}
+
+// compatibleEncoding returns the encoding of the concatenation of the
+// strings, or raises Encoding::CompatibilityError. The strings of the
+// different encodings are compatible if either is empty or ASCII only.
+func (rt *Runtime) compatibleEncoding(a, b *RString) *Encoding {
+	ea, eb := a.Encoding(), b.Encoding()
+	switch {
+	case ea == eb, len(b.B) == 0:
+		return ea
+	case len(a.B) == 0:
+		if isASCII(b.B) {
+			return ea
+		}
+		return eb
+	case isASCII(b.B):
+		return ea
+	case isASCII(a.B):
+		return eb
+	}
+	rt.Raise(rt.CompatibilityError, "incompatible character encodings: %s and %s", ea.Name, eb.Name)
+	return nil
+}
+
+// transcoding options of String#encode
+type transcodeOpts struct {
+	invalid, undef bool // replace the invalid or undefined characters
+	replace        []byte
+}
+
+// transcode converts b from the encoding to another. The supported
+// encodings share ASCII, so that the other characters are undefined in the
+// other encodings.
+func (rt *Runtime) transcode(b []byte, from, to *Encoding, opts transcodeOpts) []byte {
+	if from == to {
+		return append([]byte(nil), b...)
+	}
+	replace := opts.replace
+	if replace == nil {
+		replace = []byte("?")
+		if to == UTF8 {
+			replace = []byte("�")
+		}
+	}
+	var out []byte
+	for i := 0; i < len(b); {
+		n := from.charLen(b[i:])
+		c := b[i : i+n]
+		switch {
+		case c[0] < utf8.RuneSelf:
+			out = append(out, c...)
+		case !from.Valid(c):
+			if !opts.invalid {
+				msg := fmt.Sprintf("%s on %s", QuoteString(c), from.Name)
+				if from == UTF8 && !utf8.FullRune(b[i:]) {
+					msg = "incomplete " + QuoteString(b[i:]) + " on " + from.Name
+				}
+				rt.Raise(rt.InvalidByteSequenceError, "%s", msg)
+			}
+			out = append(out, replace...)
+		default:
+			if !opts.undef {
+				what := QuoteString(c)
+				if from == UTF8 {
+					r, _ := utf8.DecodeRune(c)
+					what = fmt.Sprintf("U+%04X", r)
+				}
+				rt.Raise(rt.UndefinedConversionError, "%s from %s to %s", what, from.Name, to.Name)
+			}
+			out = append(out, replace...)
+		}
+		i += n
+	}
+	return out
+}
+
+// transcodeOptions returns the options of String#encode given as a hash,
+// such as invalid: :replace, undef: :replace, replace: "?".
+func (rt *Runtime) transcodeOptions(opts Value) transcodeOpts {
+	var o transcodeOpts
+	o.invalid = rt.Send(opts, "[]", SymbolOf("invalid")) == SymbolOf("replace")
+	o.undef = rt.Send(opts, "[]", SymbolOf("undef")) == SymbolOf("replace")
+	if r := rt.Send(opts, "[]", SymbolOf("replace")); r != Nil {
+		o.replace = rt.toStr(r).B
+	}
+	return o
+}
+
+func (rt *Runtime) initEncoding() {
+	c := rt.DefineClass("Encoding", rt.Object, nil)
+	rt.Encoding = c
+	rt.encodings = map[*Encoding]*REncoding{}
+	for _, e := range encodings {
+		o := &REncoding{RObject: RObject{class: c, frozen: true}, Enc: e}
+		rt.encodings[e] = o
+		for _, name := range append([]string{e.Name}, e.Aliases...) {
+			name = strings.Replace(strings.ToUpper(name), "-", "_", -1)
+			if name[0] >= 'A' && name[0] <= 'Z' {
+				rt.ConstSet(c, name, o)
+			}
+		}
+	}
+	rt.EncodingError = rt.DefineClass("EncodingError", rt.StandardError, nil)
+	rt.CompatibilityError = rt.DefineClass("CompatibilityError", rt.EncodingError, c)
+	rt.UndefinedConversionError = rt.DefineClass("UndefinedConversionError", rt.EncodingError, c)
+	rt.InvalidByteSequenceError = rt.DefineClass("InvalidByteSequenceError", rt.EncodingError, c)
+
+	rt.UndefMethod(rt.SingletonClassOf(c), Intern("new"))
+	rt.DefineMethod(rt.SingletonClassOf(c), "list", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var list []Value
+		for _, e := range encodings {
+			list = append(list, rt.encodings[e])
+		}
+		return rt.NewArray(list)
+	})
+	rt.DefineMethod(rt.SingletonClassOf(c), "name_list", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var list []Value
+		for _, e := range encodings {
+			for _, name := range append([]string{e.Name}, e.Aliases...) {
+				list = append(list, rt.NewString(name))
+			}
+		}
+		return rt.NewArray(list)
+	})
+	rt.DefineMethod(rt.SingletonClassOf(c), "find", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.encodings[rt.toEncoding(args[0])]
+	})
+	defaultEncoding := func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.encodings[UTF8]
+	}
+	rt.DefineMethod(rt.SingletonClassOf(c), "default_external", 0, defaultEncoding)
+	rt.DefineMethod(rt.SingletonClassOf(c), "default_internal", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Nil
+	})
+	rt.DefineMethod(c, "to_s", 0, encodingName)
+	rt.DefineMethod(c, "name", 0, encodingName)
+	rt.DefineMethod(c, "inspect", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString("#<Encoding:" + self.(*REncoding).Enc.Name + ">")
+	})
+	rt.DefineMethod(c, "names", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		e := self.(*REncoding).Enc
+		var names []Value
+		for _, name := range append([]string{e.Name}, e.Aliases...) {
+			names = append(names, rt.NewString(name))
+		}
+		return rt.NewArray(names)
+	})
+	rt.DefineMethod(c, "ascii_compatible?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return True
+	})
+}
+
+func encodingName(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return rt.NewString(self.(*REncoding).Enc.Name)
+}
@@ -0,0 +1,467 @@
+package object
+
+// BreakIteration is panicked by the builtin blocks of Enumerable to stop
+// each early. The evaluators run the ensure clauses for it as for break.
+type BreakIteration struct{}
+
+var idEach = Intern("each")
+
+// iterate calls f for the elements given by each of the value until f
+// returns false. The values yielded together are packed into an array.
+func (rt *Runtime) iterate(self Value, f func(v Value) bool) {
+	brk := &BreakIteration{}
+	defer func() {
+		if r := recover(); r != nil && r != brk {
+			panic(r)
+		}
+	}()
+	blk := &RProc{
+		Fn: func(rt *Runtime, _ Value, args []Value, _ Value) Value {
+			if !f(packValues(args)) {
+				panic(brk)
+			}
+			return Nil
+		},
+		Params: []ProcParam{{Kind: "rest"}},
+	}
+	rt.Call(self, idEach, nil, blk, true)
+}
+
+func packValues(args []Value) Value {
+	switch len(args) {
+	case 0:
+		return Nil
+	case 1:
+		return args[0]
+	}
+	return &RArray{Elems: append([]Value(nil), args...)}
+}
+
+// enumToA returns the elements given by each.
+func (rt *Runtime) enumToA(self Value) []Value {
+	var elems []Value
+	rt.iterate(self, func(v Value) bool {
+		elems = append(elems, v)
+		return true
+	})
+	return elems
+}
+
+// enumCall calls the method of Enumerable for the value, which is the
+// fallback of the method overridden by the class.
+func (rt *Runtime) enumCall(self Value, name string, args []Value, blk Value) Value {
+	return rt.CallMethod(rt.Enumerable.Methods[Intern(name)], self, args, blk)
+}
+
+// enumTest returns whether the element matches the pattern by ===, or the
+// block returns truthy, or the element is truthy without them.
+func (rt *Runtime) enumTest(v Value, args []Value, blk Value) bool {
+	switch {
+	case len(args) > 0:
+		return Truthy(rt.Send(args[0], "===", v))
+	case blk != nil:
+		return Truthy(rt.Yield(blk, v))
+	}
+	return Truthy(v)
+}
+
+func (rt *Runtime) initEnumerable() {
+	e := rt.Enumerable
+	for _, name := range []string{"to_a", "entries"} {
+		rt.DefineMethod(e, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return rt.NewArray(rt.enumToA(self))
+		})
+	}
+	for _, name := range []string{"map", "collect"} {
+		rt.DefineMethod(e, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if blk == nil {
+				rt.NotImplemented("Enumerator")
+			}
+			var elems []Value
+			rt.iterate(self, func(v Value) bool {
+				elems = append(elems, rt.Yield(blk, v))
+				return true
+			})
+			return rt.NewArray(elems)
+		})
+	}
+	for _, name := range []string{"flat_map", "collect_concat"} {
+		rt.DefineMethod(e, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if blk == nil {
+				rt.NotImplemented("Enumerator")
+			}
+			var elems []Value
+			rt.iterate(self, func(v Value) bool {
+				r := rt.Yield(blk, v)
+				if a, ok := r.(*RArray); ok {
+					elems = append(elems, a.Elems...)
+				} else {
+					elems = append(elems, r)
+				}
+				return true
+			})
+			return rt.NewArray(elems)
+		})
+	}
+	for _, name := range []string{"select", "filter", "reject"} {
+		keep := name != "reject"
+		rt.DefineMethod(e, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if blk == nil {
+				rt.NotImplemented("Enumerator")
+			}
+			var elems []Value
+			rt.iterate(self, func(v Value) bool {
+				if Truthy(rt.Yield(blk, v)) == keep {
+					elems = append(elems, v)
+				}
+				return true
+			})
+			return rt.NewArray(elems)
+		})
+	}
+	rt.DefineMethod(e, "partition", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		var yes, no []Value
+		rt.iterate(self, func(v Value) bool {
+			if Truthy(rt.Yield(blk, v)) {
+				yes = append(yes, v)
+			} else {
+				no = append(no, v)
+			}
+			return true
+		})
+		return rt.NewArray([]Value{rt.NewArray(yes), rt.NewArray(no)})
+	})
+	rt.DefineMethod(e, "group_by", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		h := rt.NewHash()
+		rt.iterate(self, func(v Value) bool {
+			k := rt.Yield(blk, v)
+			if g, ok := rt.HashGet(h, k); ok {
+				g.(*RArray).Elems = append(g.(*RArray).Elems, v)
+			} else {
+				rt.HashSet(h, k, rt.NewArray([]Value{v}))
+			}
+			return true
+		})
+		return h
+	})
+	rt.DefineMethod(e, "tally", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		h := rt.NewHash()
+		rt.iterate(self, func(v Value) bool {
+			n, ok := rt.HashGet(h, v)
+			if !ok {
+				n = Fixnum(0)
+			}
+			rt.HashSet(h, v, intAdd(n, Fixnum(1)))
+			return true
+		})
+		return h
+	})
+	rt.DefineMethod(e, "each_with_index", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		i := 0
+		rt.iterate(self, func(v Value) bool {
+			rt.Yield(blk, v, Fixnum(i))
+			i++
+			return true
+		})
+		return self
+	})
+	rt.DefineMethod(e, "each_with_object", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		rt.iterate(self, func(v Value) bool {
+			rt.Yield(blk, v, args[0])
+			return true
+		})
+		return args[0]
+	})
+	for _, name := range []string{"find", "detect"} {
+		rt.DefineMethod(e, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if blk == nil {
+				rt.NotImplemented("Enumerator")
+			}
+			found := Value(Nil)
+			rt.iterate(self, func(v Value) bool {
+				if Truthy(rt.Yield(blk, v)) {
+					found = v
+					return false
+				}
+				return true
+			})
+			return found
+		})
+	}
+	rt.DefineMethod(e, "find_index", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		if len(args) == 0 && blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		i, found := 0, false
+		rt.iterate(self, func(v Value) bool {
+			if len(args) > 0 && rt.Equal(v, args[0]) || len(args) == 0 && Truthy(rt.Yield(blk, v)) {
+				found = true
+				return false
+			}
+			i++
+			return true
+		})
+		if !found {
+			return Nil
+		}
+		return Fixnum(i)
+	})
+	for _, name := range []string{"all?", "any?", "none?"} {
+		name := name
+		rt.DefineMethod(e, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if len(args) > 1 {
+				rt.CheckArity(len(args), 1)
+			}
+			result := name != "any?"
+			rt.iterate(self, func(v Value) bool {
+				ok := rt.enumTest(v, args, blk)
+				switch {
+				case name == "all?" && !ok, name == "none?" && ok:
+					result = false
+				case name == "any?" && ok:
+					result = true
+				default:
+					return true
+				}
+				return false
+			})
+			return Bool(result)
+		})
+	}
+	for _, name := range []string{"include?", "member?"} {
+		rt.DefineMethod(e, name, 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			found := false
+			rt.iterate(self, func(v Value) bool {
+				found = rt.Equal(v, args[0])
+				return !found
+			})
+			return Bool(found)
+		})
+	}
+	rt.DefineMethod(e, "count", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		n := 0
+		rt.iterate(self, func(v Value) bool {
+			if len(args) > 0 && rt.Equal(v, args[0]) || len(args) == 0 && (blk == nil || Truthy(rt.Yield(blk, v))) {
+				n++
+			}
+			return true
+		})
+		return Fixnum(n)
+	})
+	rt.DefineMethod(e, "first", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		if len(args) == 0 {
+			first := Value(Nil)
+			rt.iterate(self, func(v Value) bool {
+				first = v
+				return false
+			})
+			return first
+		}
+		return rt.NewArray(rt.enumTake(self, rt.arraySize(args)))
+	})
+	rt.DefineMethod(e, "take", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		n := int(rt.toInt(args[0]))
+		if n < 0 {
+			rt.Raise(rt.ArgumentError, "attempt to take negative size")
+		}
+		return rt.NewArray(rt.enumTake(self, n))
+	})
+	for _, name := range []string{"inject", "reduce"} {
+		rt.DefineMethod(e, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if len(args) > 2 {
+				rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..2)", len(args))
+			}
+			var op ID
+			if len(args) == 2 || len(args) == 1 && blk == nil {
+				op = rt.toID(args[len(args)-1])
+				args = args[:len(args)-1]
+			}
+			var acc Value
+			if len(args) == 1 {
+				acc = args[0]
+			}
+			rt.iterate(self, func(v Value) bool {
+				switch {
+				case acc == nil:
+					acc = v
+				case op != 0:
+					acc = rt.Call(acc, op, []Value{v}, nil, true)
+				default:
+					acc = rt.Yield(blk, acc, v)
+				}
+				return true
+			})
+			if acc == nil {
+				return Nil
+			}
+			return acc
+		})
+	}
+	rt.DefineMethod(e, "sum", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		init := Value(Fixnum(0))
+		if len(args) == 1 {
+			init = args[0]
+		}
+		var elems []Value
+		rt.iterate(self, func(v Value) bool {
+			if blk != nil {
+				v = rt.Yield(blk, v)
+			}
+			elems = append(elems, v)
+			return true
+		})
+		return rt.sum(init, elems)
+	})
+	for _, name := range []string{"min", "max"} {
+		sign := map[string]int{"min": -1, "max": 1}[name]
+		rt.DefineMethod(e, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if len(args) > 1 {
+				rt.CheckArity(len(args), 1)
+			}
+			if len(args) == 1 {
+				n := rt.arraySize(args)
+				elems := rt.enumToA(self)
+				mergeSort(elems, make([]Value, len(elems)), func(a, b Value) int {
+					return -sign * rt.compareValues(a, b, blk)
+				})
+				if n < len(elems) {
+					elems = elems[:n]
+				}
+				return rt.NewArray(elems)
+			}
+			var m Value = Nil
+			first := true
+			rt.iterate(self, func(v Value) bool {
+				if first || rt.compareValues(v, m, blk)*sign > 0 {
+					m, first = v, false
+				}
+				return true
+			})
+			return m
+		})
+	}
+	for _, name := range []string{"min_by", "max_by"} {
+		sign := map[string]int{"min_by": -1, "max_by": 1}[name]
+		rt.DefineMethod(e, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if blk == nil {
+				rt.NotImplemented("Enumerator")
+			}
+			var m, key Value = Nil, nil
+			rt.iterate(self, func(v Value) bool {
+				k := rt.Yield(blk, v)
+				if key == nil || rt.compareValues(k, key, nil)*sign > 0 {
+					m, key = v, k
+				}
+				return true
+			})
+			return m
+		})
+	}
+	rt.DefineMethod(e, "sort", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		elems := rt.enumToA(self)
+		rt.sortValues(elems, blk)
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(e, "sort_by", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		var pairs []Value
+		rt.iterate(self, func(v Value) bool {
+			pairs = append(pairs, rt.NewArray([]Value{rt.Yield(blk, v), v}))
+			return true
+		})
+		mergeSort(pairs, make([]Value, len(pairs)), func(a, b Value) int {
+			return rt.compareValues(a.(*RArray).Elems[0], b.(*RArray).Elems[0], nil)
+		})
+		for i, p := range pairs {
+			pairs[i] = p.(*RArray).Elems[1]
+		}
+		return rt.NewArray(pairs)
+	})
+	rt.DefineMethod(e, "uniq", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewArray(rt.uniq(rt.enumToA(self), blk))
+	})
+	rt.DefineMethod(e, "zip", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		others := make([][]Value, len(args))
+		for i, o := range args {
+			if a, ok := o.(*RArray); ok {
+				others[i] = a.Elems
+				continue
+			}
+			if !rt.RespondTo(o, "each") {
+				rt.Raise(rt.TypeError, "wrong argument type %s (must respond to :each)", rt.ClassOf(o).RealClass().Name)
+			}
+			others[i] = rt.enumToA(o)
+		}
+		var tuples []Value
+		rt.iterate(self, func(v Value) bool {
+			tuple := []Value{v}
+			for _, o := range others {
+				x := Value(Nil)
+				if len(tuples) < len(o) {
+					x = o[len(tuples)]
+				}
+				tuple = append(tuple, x)
+			}
+			tuples = append(tuples, rt.NewArray(tuple))
+			return true
+		})
+		return rt.NewArray(tuples)
+	})
+	rt.DefineMethod(e, "to_h", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		h := rt.NewHash()
+		rt.iterate(self, func(v Value) bool {
+			if blk != nil {
+				v = rt.Yield(blk, v)
+			}
+			pair, ok := v.(*RArray)
+			if !ok {
+				rt.Raise(rt.TypeError, "wrong element type %s (expected array)", rt.ClassOf(v).RealClass().Name)
+			}
+			if len(pair.Elems) != 2 {
+				rt.Raise(rt.ArgumentError, "element has wrong array length (expected 2, was %d)", len(pair.Elems))
+			}
+			rt.HashSet(h, pair.Elems[0], pair.Elems[1])
+			return true
+		})
+		return h
+	})
+}
+
+// enumTake returns the first n elements given by each.
+func (rt *Runtime) enumTake(self Value, n int) []Value {
+	elems := []Value{}
+	if n == 0 {
+		return elems
+	}
+	rt.iterate(self, func(v Value) bool {
+		elems = append(elems, v)
+		return len(elems) < n
+	})
+	return elems
+}
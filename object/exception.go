@@ -0,0 +1,139 @@
+package object
+
+import "fmt"
+
+// Error is a Ruby exception raised as a Go panic.
+type Error struct {
+	Exception *RObject
+}
+
+func (e *Error) Error() string {
+	msg := e.Message()
+	name := e.Class().Name
+	if msg == "" || msg == name {
+		return name
+	}
+	return msg + " (" + name + ")"
+}
+
+// Message returns the message of the exception.
+func (e *Error) Message() string {
+	if s, ok := e.Exception.Ivar(Intern("mesg")).(*RString); ok {
+		return string(s.B)
+	}
+	return e.Class().Name
+}
+
+// Class returns the class of the exception.
+func (e *Error) Class() *RClass {
+	return e.Exception.class.RealClass()
+}
+
+// NewException returns the exception of the class with the message.
+func (rt *Runtime) NewException(c *RClass, msg string) *RObject {
+	exc := rt.NewObject(c)
+	exc.SetIvar(Intern("mesg"), rt.NewString(msg))
+	return exc
+}
+
+// Raise raises the exception of the class with the formatted message.
+func (rt *Runtime) Raise(c *RClass, format string, args ...interface{}) {
+	panic(&Error{Exception: rt.NewException(c, fmt.Sprintf(format, args...))})
+}
+
+// RaiseException raises the exception object.
+func (rt *Runtime) RaiseException(exc Value) {
+	o, ok := exc.(*RObject)
+	if !ok || !rt.IsKindOf(o, rt.Exception) {
+		rt.Raise(rt.TypeError, "exception class/object expected")
+	}
+	panic(&Error{Exception: o})
+}
+
+// NotImplemented raises NotImplementedError for the feature which ringo
+// does not support yet.
+func (rt *Runtime) NotImplemented(feature string) {
+	rt.Raise(rt.NotImplementedError, "%s is not implemented", feature)
+}
+
+// TypeMismatch raises TypeError for the value which cannot be converted to
+// the class.
+func (rt *Runtime) TypeMismatch(v Value, to string) {
+	var from string
+	switch v.(type) {
+	case nilValue, Bool:
+		from = rt.Inspect(v)
+	default:
+		from = rt.ClassOf(v).RealClass().Name
+	}
+	rt.Raise(rt.TypeError, "no implicit conversion of %s into %s", from, to)
+}
+
+func (rt *Runtime) initException() {
+	rt.Exception = rt.DefineClass("Exception", rt.Object, nil)
+	rt.ScriptError = rt.DefineClass("ScriptError", rt.Exception, nil)
+	rt.NotImplementedError = rt.DefineClass("NotImplementedError", rt.ScriptError, nil)
+	rt.StandardError = rt.DefineClass("StandardError", rt.Exception, nil)
+	rt.ArgumentError = rt.DefineClass("ArgumentError", rt.StandardError, nil)
+	rt.NameError = rt.DefineClass("NameError", rt.StandardError, nil)
+	rt.NoMethodError = rt.DefineClass("NoMethodError", rt.NameError, nil)
+	rt.RuntimeError = rt.DefineClass("RuntimeError", rt.StandardError, nil)
+	rt.FrozenError = rt.DefineClass("FrozenError", rt.RuntimeError, nil)
+	rt.TypeError = rt.DefineClass("TypeError", rt.StandardError, nil)
+	rt.ZeroDivisionError = rt.DefineClass("ZeroDivisionError", rt.StandardError, nil)
+	rt.RangeError = rt.DefineClass("RangeError", rt.StandardError, nil)
+	rt.FloatDomainError = rt.DefineClass("FloatDomainError", rt.RangeError, nil)
+	rt.IndexError = rt.DefineClass("IndexError", rt.StandardError, nil)
+	rt.KeyError = rt.DefineClass("KeyError", rt.IndexError, nil)
+	rt.StopIteration = rt.DefineClass("StopIteration", rt.IndexError, nil)
+	rt.LocalJumpError = rt.DefineClass("LocalJumpError", rt.StandardError, nil)
+	rt.SystemStackError = rt.DefineClass("SystemStackError", rt.Exception, nil)
+
+	c := rt.Exception
+	rt.DefineMethod(rt.SingletonClassOf(c), "exception", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.Call(self, Intern("new"), args, blk, false)
+	})
+	rt.DefineMethod(c, "initialize", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		if len(args) == 1 {
+			rt.SetIvar(self, Intern("mesg"), args[0])
+		}
+		return Nil
+	})
+	rt.DefineMethod(c, "message", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString(rt.ToS(self))
+	})
+	rt.DefineMethod(c, "to_s", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if m := rt.Ivar(self, Intern("mesg")); m != Nil {
+			return rt.NewString(rt.ToS(m))
+		}
+		return rt.NewString(rt.ClassOf(self).RealClass().Name)
+	})
+	rt.DefineMethod(c, "inspect", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		name := rt.ClassOf(self).RealClass().Name
+		msg := rt.ToS(self)
+		if msg == "" || msg == name {
+			return rt.NewString(name)
+		}
+		return rt.NewString(fmt.Sprintf("#<%s: %s>", name, msg))
+	})
+	rt.DefineMethod(c, "full_message", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString(fmt.Sprintf("%s (%s)", rt.ToS(self), rt.ClassOf(self).RealClass().Name))
+	})
+	rt.DefineMethod(c, "==", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if self == args[0] {
+			return True
+		}
+		return Bool(rt.ClassOf(self) == rt.ClassOf(args[0]) && rt.ToS(self) == rt.ToS(args[0]))
+	})
+	rt.DefineMethod(c, "exception", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) == 0 {
+			return self
+		}
+		exc := rt.NewObject(rt.ClassOf(self).RealClass())
+		exc.SetIvar(Intern("mesg"), args[0])
+		return exc
+	})
+}
@@ -0,0 +1,444 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// FormatFloat returns the string of the float as Float#to_s, which is the
+// shortest digits read back to the same float. The exponent is written if
+// the decimal point is out of the digits from 1.0e-04 to 1.0e+16, such as
+// 1.0e+20.
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	sign := ""
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	i := strings.IndexByte(s, 'e')
+	digits := strings.Replace(s[:i], ".", "", 1)
+	exp, _ := strconv.Atoi(s[i+1:])
+	switch decpt := exp + 1; {
+	case 0 < decpt && decpt <= 16:
+		if len(digits) <= decpt {
+			return sign + digits + strings.Repeat("0", decpt-len(digits)) + ".0"
+		}
+		return sign + digits[:decpt] + "." + digits[decpt:]
+	case -4 < decpt && decpt <= 0:
+		return sign + "0." + strings.Repeat("0", -decpt) + digits
+	}
+	frac := digits[1:]
+	if frac == "" {
+		frac = "0"
+	}
+	return fmt.Sprintf("%s%s.%se%+03d", sign, digits[:1], frac, exp)
+}
+
+// floatToInt returns the integral float as an Integer.
+func (rt *Runtime) floatToInt(f float64) Value {
+	switch {
+	case math.IsNaN(f):
+		rt.Raise(rt.FloatDomainError, "NaN")
+	case math.IsInf(f, 0):
+		rt.Raise(rt.FloatDomainError, "%s", FormatFloat(f))
+	case f < -(1<<63) || f >= 1<<63:
+		n, _ := big.NewFloat(f).Int(nil)
+		return NewInteger(n)
+	}
+	return Fixnum(f)
+}
+
+// floatDivmod returns the floored quotient and the modulo which has the
+// same sign as y, as flodivmod of MRI.
+func (rt *Runtime) floatDivmod(x, y float64) (float64, float64) {
+	if math.IsNaN(y) {
+		return y, y
+	}
+	if y == 0 {
+		rt.Raise(rt.ZeroDivisionError, "divided by 0")
+	}
+	mod := x
+	if x != 0 && !(math.IsInf(y, 0) && !math.IsInf(x, 0)) {
+		mod = math.Mod(x, y)
+	}
+	div := x
+	if !math.IsInf(x, 0) || math.IsInf(y, 0) {
+		div = roundAway((x - mod) / y)
+	}
+	if y*mod < 0 {
+		mod += y
+		div--
+	}
+	return div, mod
+}
+
+// floatMod returns the modulo of x and y, which has the same sign as y.
+func (rt *Runtime) floatMod(x, y float64) float64 {
+	_, mod := rt.floatDivmod(x, y)
+	return mod
+}
+
+// roundMode is the rounding mode given by the half: option.
+type roundMode int
+
+const (
+	roundHalfUp roundMode = iota
+	roundHalfEven
+	roundHalfDown
+)
+
+// roundArgs returns the digits and the rounding mode of the arguments such
+// as round(2, half: :even). The options are given as a hash at the last.
+func (rt *Runtime) roundArgs(args []Value) (int, roundMode) {
+	mode := roundHalfUp
+	if n := len(args); n > 0 && rt.RespondTo(args[n-1], "to_hash") {
+		mode = rt.roundMode(rt.Send(args[n-1], "[]", SymbolOf("half")))
+		args = args[:n-1]
+	}
+	return rt.digitsArg(args), mode
+}
+
+// digitsArg returns the optional digits of round, floor, ceil and
+// truncate.
+func (rt *Runtime) digitsArg(args []Value) int {
+	switch {
+	case len(args) > 1:
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+	case len(args) == 0 || args[0] == Nil:
+		return 0
+	}
+	return int(rt.toInt(args[0]))
+}
+
+func (rt *Runtime) roundMode(v Value) roundMode {
+	var name string
+	switch v := v.(type) {
+	case nilValue:
+		return roundHalfUp
+	case Symbol:
+		name = v.Name()
+	case *RString:
+		name = string(v.B)
+	default:
+		rt.Raise(rt.ArgumentError, "invalid rounding mode: %s", rt.Inspect(v))
+	}
+	switch name {
+	case "up":
+		return roundHalfUp
+	case "even":
+		return roundHalfEven
+	case "down":
+		return roundHalfDown
+	}
+	rt.Raise(rt.ArgumentError, "invalid rounding mode: %s", name)
+	return 0
+}
+
+// roundAway rounds the half away from zero as round(3) of C.
+func roundAway(x float64) float64 {
+	t := math.Trunc(x)
+	if math.Abs(x-t) >= 0.5 {
+		t += math.Copysign(1, x)
+	}
+	return t
+}
+
+// roundHalf returns x * s rounded to an integral float. The result is
+// corrected when x * s is inexact as MRI does, so 2.675.round(2) is 2.68
+// though 2.675 * 100 is 267.49999999999997.
+func roundHalf(mode roundMode, x, s float64) float64 {
+	switch mode {
+	case roundHalfEven:
+		up, down := roundHalf(roundHalfUp, x, s), roundHalf(roundHalfDown, x, s)
+		if up != down && math.Mod(up, 2) != 0 {
+			return down
+		}
+		return up
+	case roundHalfDown:
+		f := roundAway(x * s)
+		if x > 0 && (f-0.5)/s >= x {
+			f--
+		} else if x < 0 && (f+0.5)/s <= x {
+			f++
+		}
+		return f
+	}
+	f := roundAway(x * s)
+	if s == 1 {
+		return f
+	}
+	if x > 0 && (f+0.5)/s <= x {
+		f++
+	} else if x < 0 && (f-0.5)/s >= x {
+		f--
+	}
+	return f
+}
+
+// roundRational rounds x at the digits exactly with big.Rat, which is used
+// where 10 ** ndigits is inexact in float64.
+func roundRational(x float64, ndigits int, mode roundMode) float64 {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(ndigits)), nil)
+	r := new(big.Rat).SetFloat64(x)
+	r.Mul(r, new(big.Rat).SetInt(p))
+	q, m := new(big.Int).QuoRem(new(big.Int).Abs(r.Num()), r.Denom(), new(big.Int))
+	switch c := m.Lsh(m, 1).Cmp(r.Denom()); {
+	case c > 0, c == 0 && mode == roundHalfUp, c == 0 && mode == roundHalfEven && q.Bit(0) == 1:
+		q.Add(q, bigOne)
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	f, _ := new(big.Rat).SetFrac(q, p).Float64()
+	return f
+}
+
+// floatRoundOverflow returns whether the float has no digits to be rounded
+// at ndigits, where binexp is the binary exponent of the float.
+func floatRoundOverflow(ndigits, binexp int) bool {
+	const floatDig = 15 + 2
+	if binexp > 0 {
+		return ndigits >= floatDig-binexp/4
+	}
+	return ndigits >= floatDig-(binexp/3-1)
+}
+
+// floatRoundUnderflow returns whether the float is rounded to zero at
+// ndigits.
+func floatRoundUnderflow(ndigits, binexp int) bool {
+	if binexp > 0 {
+		return ndigits < -(binexp/3 + 1)
+	}
+	return ndigits < -(binexp / 4)
+}
+
+// floatDigits returns whether the float is rounded at the positive ndigits
+// by the arithmetic of float64. Otherwise, the float is returned as is, or
+// is rounded to zero if zero is true.
+func floatDigits(x float64, ndigits int) (round, zero bool) {
+	if x == 0 || math.IsInf(x, 0) || math.IsNaN(x) {
+		return false, false
+	}
+	_, binexp := math.Frexp(x)
+	if floatRoundOverflow(ndigits, binexp) {
+		return false, false
+	}
+	if floatRoundUnderflow(ndigits, binexp) {
+		return false, true
+	}
+	return true, false
+}
+
+func (rt *Runtime) floatRound(x float64, ndigits int, mode roundMode) Value {
+	switch {
+	case ndigits < 0:
+		return rt.intRound(rt.floatToInt(math.Trunc(x)), ndigits, mode)
+	case ndigits == 0:
+		return rt.floatToInt(roundHalf(mode, x, 1))
+	}
+	switch round, zero := floatDigits(x, ndigits); {
+	case zero:
+		return Float(0)
+	case !round:
+		return Float(x)
+	case ndigits > 14:
+		return Float(roundRational(x, ndigits, mode))
+	}
+	s := math.Pow(10, float64(ndigits))
+	return Float(roundHalf(mode, x, s) / s)
+}
+
+func (rt *Runtime) floatFloor(x float64, ndigits int) Value {
+	if ndigits <= 0 {
+		return rt.intFloor(rt.floatToInt(math.Floor(x)), ndigits)
+	}
+	switch round, zero := floatDigits(x, ndigits); {
+	case zero && x > 0:
+		return Float(0)
+	case !round && !zero:
+		return Float(x)
+	}
+	s := math.Pow(10, float64(ndigits))
+	f := math.Floor(x * s)
+	if r := (f + 1) / s; r <= x {
+		return Float(r)
+	}
+	return Float(f / s)
+}
+
+func (rt *Runtime) floatCeil(x float64, ndigits int) Value {
+	if ndigits <= 0 {
+		return rt.intCeil(rt.floatToInt(math.Ceil(x)), ndigits)
+	}
+	switch round, zero := floatDigits(x, ndigits); {
+	case zero && x < 0:
+		return Float(math.Copysign(0, -1))
+	case !round && !zero:
+		return Float(x)
+	}
+	s := math.Pow(10, float64(ndigits))
+	f := math.Ceil(x * s)
+	if r := (f - 1) / s; r >= x {
+		return Float(r)
+	}
+	return Float(f / s)
+}
+
+func (rt *Runtime) initFloat() {
+	f := rt.Float
+	consts := map[string]Value{
+		"INFINITY":   Float(math.Inf(1)),
+		"NAN":        Float(math.NaN()),
+		"EPSILON":    Float(math.Nextafter(1, 2) - 1),
+		"MAX":        Float(math.MaxFloat64),
+		"MIN":        Float(2.2250738585072014e-308),
+		"DIG":        Fixnum(15),
+		"MANT_DIG":   Fixnum(53),
+		"RADIX":      Fixnum(2),
+		"MAX_EXP":    Fixnum(1024),
+		"MIN_EXP":    Fixnum(-1021),
+		"MAX_10_EXP": Fixnum(308),
+		"MIN_10_EXP": Fixnum(-307),
+	}
+	for name, v := range consts {
+		rt.ConstSet(f, name, v)
+	}
+
+	rt.DefineMethod(f, "+", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self.(Float) + Float(rt.toFloat(self, args[0]))
+	})
+	rt.DefineMethod(f, "-", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self.(Float) - Float(rt.toFloat(self, args[0]))
+	})
+	rt.DefineMethod(f, "*", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self.(Float) * Float(rt.toFloat(self, args[0]))
+	})
+	floatDiv := func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self.(Float) / Float(rt.toFloat(self, args[0]))
+	}
+	rt.DefineMethod(f, "/", 1, floatDiv)
+	rt.DefineMethod(f, "fdiv", 1, floatDiv)
+	rt.DefineMethod(f, "quo", 1, floatDiv)
+	floatMod := func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Float(rt.floatMod(float64(self.(Float)), rt.toFloat(self, args[0])))
+	}
+	rt.DefineMethod(f, "%", 1, floatMod)
+	rt.DefineMethod(f, "modulo", 1, floatMod)
+	rt.DefineMethod(f, "divmod", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		div, mod := rt.floatDivmod(float64(self.(Float)), rt.toFloat(self, args[0]))
+		return rt.NewArray([]Value{rt.floatToInt(div), Float(mod)})
+	})
+	rt.DefineMethod(f, "div", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		y := rt.toFloat(self, args[0])
+		if y == 0 {
+			rt.Raise(rt.ZeroDivisionError, "divided by 0")
+		}
+		return rt.floatToInt(math.Floor(float64(self.(Float)) / y))
+	})
+	rt.DefineMethod(f, "**", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Float(math.Pow(float64(self.(Float)), rt.toFloat(self, args[0])))
+	})
+	rt.DefineMethod(f, "-@", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return -self.(Float)
+	})
+	rt.DefineMethod(f, "+@", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(f, "==", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		switch b := args[0].(type) {
+		case Fixnum, *Bignum:
+			return Bool(compareIntFloat(b, float64(self.(Float))) == Fixnum(0))
+		case Float:
+			return Bool(self.(Float) == b)
+		}
+		return Bool(rt.Equal(args[0], self))
+	})
+	rt.DefineMethod(f, "<=>", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		switch b := args[0].(type) {
+		case Fixnum, *Bignum:
+			if c, ok := compareIntFloat(b, float64(self.(Float))).(Fixnum); ok {
+				return -c
+			}
+			return Nil
+		case Float:
+			return compareFloat(float64(self.(Float)), float64(b))
+		}
+		return Nil
+	})
+	rt.defineCompare(f)
+	rt.DefineMethod(f, "to_s", 0, floatToS)
+	rt.DefineMethod(f, "inspect", 0, floatToS)
+	rt.DefineMethod(f, "to_f", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	floatToI := func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.floatToInt(math.Trunc(float64(self.(Float))))
+	}
+	rt.DefineMethod(f, "to_i", 0, floatToI)
+	rt.DefineMethod(f, "to_int", 0, floatToI)
+	rt.DefineMethod(f, "truncate", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if x := float64(self.(Float)); x < 0 {
+			return rt.floatCeil(x, rt.digitsArg(args))
+		}
+		return rt.floatFloor(float64(self.(Float)), rt.digitsArg(args))
+	})
+	rt.DefineMethod(f, "floor", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.floatFloor(float64(self.(Float)), rt.digitsArg(args))
+	})
+	rt.DefineMethod(f, "ceil", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.floatCeil(float64(self.(Float)), rt.digitsArg(args))
+	})
+	rt.DefineMethod(f, "round", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		ndigits, mode := rt.roundArgs(args)
+		return rt.floatRound(float64(self.(Float)), ndigits, mode)
+	})
+	rt.DefineMethod(f, "abs", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Float(math.Abs(float64(self.(Float))))
+	})
+	rt.DefineMethod(f, "nan?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(math.IsNaN(float64(self.(Float))))
+	})
+	rt.DefineMethod(f, "infinite?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		switch x := float64(self.(Float)); {
+		case math.IsInf(x, 1):
+			return Fixnum(1)
+		case math.IsInf(x, -1):
+			return Fixnum(-1)
+		}
+		return Nil
+	})
+	rt.DefineMethod(f, "finite?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := float64(self.(Float))
+		return Bool(!math.IsInf(x, 0) && !math.IsNaN(x))
+	})
+	rt.DefineMethod(f, "zero?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self.(Float) == 0)
+	})
+	rt.DefineMethod(f, "next_float", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Float(math.Nextafter(float64(self.(Float)), math.Inf(1)))
+	})
+	rt.DefineMethod(f, "prev_float", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Float(math.Nextafter(float64(self.(Float)), math.Inf(-1)))
+	})
+	rt.DefineMethod(f, "eql?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self == args[0])
+	})
+	rt.DefineMethod(f, "hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Fixnum(rt.hashOf(self))
+	})
+}
+
+func floatToS(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return rt.NewString(FormatFloat(float64(self.(Float))))
+}
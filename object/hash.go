@@ -0,0 +1,719 @@
+package object
+
+import (
+	"bytes"
+	"math/big"
+)
+
+// RHash is a Hash, which keeps the insertion order of the keys. The keys
+// are compared by eql? and hash, or by the identities if Identity is set by
+// compare_by_identity.
+type RHash struct {
+	RObject
+	Default     Value
+	DefaultProc Value // nil if not set
+	Identity    bool
+
+	entries []*hashEntry // in the insertion order; nil for the deleted ones
+	buckets map[uint64][]*hashEntry
+	size    int
+	iter    int // number of the running iterations
+}
+
+type hashEntry struct {
+	key, value Value
+	hash       uint64
+	index      int // index in the entries
+}
+
+// NewHash returns a new empty hash.
+func (rt *Runtime) NewHash() *RHash {
+	return &RHash{Default: Nil}
+}
+
+// Len returns the number of the keys.
+func (h *RHash) Len() int {
+	return h.size
+}
+
+// toHash returns the value as a hash, converting by to_hash, or raises
+// TypeError.
+func (rt *Runtime) toHash(v Value) *RHash {
+	if h, ok := v.(*RHash); ok {
+		return h
+	}
+	if rt.RespondTo(v, "to_hash") {
+		if h, ok := rt.Send(v, "to_hash").(*RHash); ok {
+			return h
+		}
+	}
+	rt.TypeMismatch(v, "Hash")
+	return nil
+}
+
+// hashValue returns the hash of the value for the keys of hashes. The hash
+// method is called except for the builtin values.
+func (rt *Runtime) hashValue(v Value) uint64 {
+	switch v := v.(type) {
+	case Fixnum, Symbol, nilValue, Bool:
+		return rt.hashOf(v)
+	case Float:
+		if v == 0 {
+			return rt.hashOf(Float(0)) // -0.0.eql?(0.0)
+		}
+		return rt.hashOf(v)
+	case *RString:
+		if v.class == nil {
+			return stringHash(v)
+		}
+	}
+	switch n := rt.Send(v, "hash").(type) {
+	case Fixnum:
+		return uint64(n)
+	case *Bignum:
+		return bigHash(n.Int)
+	default:
+		rt.TypeMismatch(n, "Integer")
+	}
+	return 0
+}
+
+// Eql returns whether a.eql?(b).
+func (rt *Runtime) Eql(a, b Value) bool {
+	if a == b {
+		return true
+	}
+	switch a.(type) {
+	case Fixnum, Symbol, Float, nilValue, Bool:
+		return false
+	}
+	return Truthy(rt.Send(a, "eql?", b))
+}
+
+func (rt *Runtime) keyHash(h *RHash, k Value) uint64 {
+	if h.Identity {
+		return uint64(rt.ObjectID(k))
+	}
+	return rt.hashValue(k)
+}
+
+// lookup returns the entry of the key, or nil.
+func (rt *Runtime) lookup(h *RHash, k Value) *hashEntry {
+	if h.size == 0 {
+		return nil
+	}
+	for _, e := range h.buckets[rt.keyHash(h, k)] {
+		if h.Identity && e.key == k || !h.Identity && rt.Eql(k, e.key) {
+			return e
+		}
+	}
+	return nil
+}
+
+// HashGet returns the value of the key, and whether the key exists.
+func (rt *Runtime) HashGet(h *RHash, k Value) (Value, bool) {
+	if e := rt.lookup(h, k); e != nil {
+		return e.value, true
+	}
+	return Nil, false
+}
+
+// HashSet sets the value of the key. The unfrozen string keys are copied
+// and frozen so that they are not changed in the hash.
+func (rt *Runtime) HashSet(h *RHash, k, v Value) {
+	if e := rt.lookup(h, k); e != nil {
+		e.value = v
+		return
+	}
+	if h.iter > 0 {
+		rt.Raise(rt.RuntimeError, "can't add a new key into hash during iteration")
+	}
+	if s, ok := k.(*RString); ok && !h.Identity && !s.frozen {
+		k = rt.Dup(s)
+		rt.Freeze(k)
+	}
+	h.insert(&hashEntry{key: k, value: v, hash: rt.keyHash(h, k)})
+}
+
+func (h *RHash) insert(e *hashEntry) {
+	if h.buckets == nil {
+		h.buckets = map[uint64][]*hashEntry{}
+	}
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+	h.buckets[e.hash] = append(h.buckets[e.hash], e)
+	h.size++
+}
+
+// HashDelete deletes the key, and returns the value and whether the key
+// existed.
+func (rt *Runtime) HashDelete(h *RHash, k Value) (Value, bool) {
+	e := rt.lookup(h, k)
+	if e == nil {
+		return Nil, false
+	}
+	bucket := h.buckets[e.hash]
+	for i, x := range bucket {
+		if x == e {
+			bucket = append(bucket[:i:i], bucket[i+1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(h.buckets, e.hash)
+	} else {
+		h.buckets[e.hash] = bucket
+	}
+	h.entries[e.index] = nil
+	h.size--
+	if h.iter == 0 && len(h.entries) > 2*h.size+8 {
+		h.compact()
+	}
+	return e.value, true
+}
+
+// compact removes the deleted entries.
+func (h *RHash) compact() {
+	entries := make([]*hashEntry, 0, h.size)
+	for _, e := range h.entries {
+		if e != nil {
+			e.index = len(entries)
+			entries = append(entries, e)
+		}
+	}
+	h.entries = entries
+}
+
+func (h *RHash) clear() {
+	h.entries, h.buckets, h.size = nil, nil, 0
+}
+
+// each calls f for the keys and the values in the insertion order. The
+// keys may be deleted by f, but no key can be added during the iteration.
+func (h *RHash) each(f func(k, v Value)) {
+	h.iter++
+	defer func() { h.iter-- }()
+	for i := 0; i < len(h.entries); i++ {
+		if e := h.entries[i]; e != nil {
+			f(e.key, e.value)
+		}
+	}
+}
+
+// rehash rebuilds the hash by the current hashes of the keys. The value of
+// the later key wins if the keys are duplicated.
+func (rt *Runtime) rehash(h *RHash) {
+	if h.iter > 0 {
+		rt.Raise(rt.RuntimeError, "rehash during iteration")
+	}
+	entries := h.entries
+	h.clear()
+	for _, e := range entries {
+		if e == nil {
+			continue
+		}
+		if x := rt.lookup(h, e.key); x != nil {
+			x.value = e.value
+			continue
+		}
+		h.insert(&hashEntry{key: e.key, value: e.value, hash: rt.keyHash(h, e.key)})
+	}
+}
+
+// hashCopy returns a copy of the hash with the same default.
+func (rt *Runtime) hashCopy(h *RHash) *RHash {
+	c := &RHash{Default: h.Default, DefaultProc: h.DefaultProc, Identity: h.Identity}
+	rt.hashUpdate(c, h)
+	return c
+}
+
+func (rt *Runtime) hashUpdate(h, other *RHash) {
+	other.each(func(k, v Value) {
+		rt.HashSet(h, k, v)
+	})
+}
+
+// MergeHash merges the value converted by to_hash into the hash, which is
+// the double splat such as {**v}.
+func (rt *Runtime) MergeHash(h *RHash, v Value) {
+	rt.hashUpdate(h, rt.toHash(v))
+}
+
+// hashDefault returns the value for the missing key by the default proc or
+// the default value.
+func (rt *Runtime) hashDefault(h *RHash, k Value) Value {
+	if h.DefaultProc != nil {
+		return rt.Send(h.DefaultProc, "call", h, k)
+	}
+	return h.Default
+}
+
+// hashAref returns the value of the key, or the default.
+func (rt *Runtime) hashAref(h *RHash, k Value) Value {
+	if v, ok := rt.HashGet(h, k); ok {
+		return v
+	}
+	return rt.hashDefault(h, k)
+}
+
+// checkDefaultProc raises TypeError if the default proc is a lambda which
+// does not take two arguments.
+func (rt *Runtime) checkDefaultProc(v Value) {
+	p, ok := v.(*RProc)
+	if !ok {
+		rt.Raise(rt.TypeError, "wrong default_proc type %s (expected Proc)", rt.ClassOf(v).RealClass().Name)
+	}
+	if n := p.Arity(); p.Lambda && n != 2 && (n >= 0 || n < -3) {
+		if n < 0 {
+			n = -n - 1
+		}
+		rt.Raise(rt.TypeError, "default_proc takes two arguments (2 for %d)", n)
+	}
+}
+
+func (rt *Runtime) initHash() {
+	h := rt.Hash
+	rt.DefineMethod(rt.SingletonClassOf(h), "[]", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := rt.Allocate(self.(*RClass)).(*RHash)
+		if len(args) == 1 {
+			switch a := args[0].(type) {
+			case *RHash:
+				rt.hashUpdate(x, a)
+				return x
+			case *RArray:
+				for _, e := range a.Elems {
+					pair, ok := e.(*RArray)
+					if !ok || len(pair.Elems) < 1 || len(pair.Elems) > 2 {
+						rt.Raise(rt.ArgumentError, "wrong element type %s (expected array)", rt.ClassOf(e).RealClass().Name)
+					}
+					v := Value(Nil)
+					if len(pair.Elems) == 2 {
+						v = pair.Elems[1]
+					}
+					rt.HashSet(x, pair.Elems[0], v)
+				}
+				return x
+			}
+		}
+		if len(args)%2 != 0 {
+			rt.Raise(rt.ArgumentError, "odd number of arguments for Hash")
+		}
+		for i := 0; i < len(args); i += 2 {
+			rt.HashSet(x, args[i], args[i+1])
+		}
+		return x
+	})
+	rt.definePrivate(h, "initialize", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RHash)
+		if blk != nil {
+			if len(args) > 0 {
+				rt.CheckArity(len(args), 0)
+			}
+			rt.checkDefaultProc(blk)
+			x.DefaultProc = blk
+			return Nil
+		}
+		if len(args) > 1 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+		}
+		if len(args) == 1 {
+			x.Default = args[0]
+		}
+		return Nil
+	})
+	rt.DefineMethod(h, "inspect", 0, hashInspect)
+	rt.DefineMethod(h, "to_s", 0, hashInspect)
+	rt.DefineMethod(h, "to_hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(h, "to_h", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RHash)
+		if blk == nil {
+			if x.class == nil {
+				return self
+			}
+			c := rt.hashCopy(x)
+			c.Default, c.DefaultProc = Nil, nil
+			return c
+		}
+		c := rt.NewHash()
+		x.each(func(k, v Value) {
+			pair, ok := rt.Yield(blk, k, v).(*RArray)
+			if !ok || len(pair.Elems) != 2 {
+				rt.Raise(rt.TypeError, "wrong element type (expected array of 2 elements)")
+			}
+			rt.HashSet(c, pair.Elems[0], pair.Elems[1])
+		})
+		return c
+	})
+	rt.DefineMethod(h, "to_a", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var elems []Value
+		self.(*RHash).each(func(k, v Value) {
+			elems = append(elems, rt.NewArray([]Value{k, v}))
+		})
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(h, "==", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.hashEqual(self.(*RHash), args[0], false))
+	})
+	rt.DefineMethod(h, "eql?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.hashEqual(self.(*RHash), args[0], true))
+	})
+	rt.DefineMethod(h, "hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RHash)
+		return rt.recursive("hash", self, nil, func(recur bool) Value {
+			sum := uint64(x.size)
+			if !recur {
+				// the sum does not depend on the order of the keys
+				x.each(func(k, v Value) {
+					sum += rt.hashValue(k)*31 ^ rt.hashValue(v)
+				})
+			}
+			return Fixnum(sum >> 2)
+		})
+	})
+	rt.DefineMethod(h, "[]", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.hashAref(self.(*RHash), args[0])
+	})
+	rt.DefineMethod(h, "[]=", 2, hashAset)
+	rt.DefineMethod(h, "store", 2, hashAset)
+	rt.DefineMethod(h, "fetch", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 2 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+		}
+		if v, ok := rt.HashGet(self.(*RHash), args[0]); ok {
+			return v
+		}
+		switch {
+		case blk != nil:
+			return rt.Yield(blk, args[0])
+		case len(args) == 2:
+			return args[1]
+		}
+		rt.Raise(rt.KeyError, "key not found: %s", rt.Inspect(args[0]))
+		return nil
+	})
+	for _, name := range []string{"key?", "has_key?", "include?", "member?"} {
+		rt.DefineMethod(h, name, 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			_, ok := rt.HashGet(self.(*RHash), args[0])
+			return Bool(ok)
+		})
+	}
+	for _, name := range []string{"value?", "has_value?"} {
+		rt.DefineMethod(h, name, 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return Bool(rt.hashKeyOf(self.(*RHash), args[0]) != nil)
+		})
+	}
+	rt.DefineMethod(h, "key", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if k := rt.hashKeyOf(self.(*RHash), args[0]); k != nil {
+			return k
+		}
+		return Nil
+	})
+	rt.DefineMethod(h, "keys", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var keys []Value
+		self.(*RHash).each(func(k, v Value) {
+			keys = append(keys, k)
+		})
+		return rt.NewArray(keys)
+	})
+	rt.DefineMethod(h, "values", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var values []Value
+		self.(*RHash).each(func(k, v Value) {
+			values = append(values, v)
+		})
+		return rt.NewArray(values)
+	})
+	rt.DefineMethod(h, "values_at", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		values := make([]Value, len(args))
+		for i, k := range args {
+			values[i] = rt.hashAref(self.(*RHash), k)
+		}
+		return rt.NewArray(values)
+	})
+	rt.DefineMethod(h, "dig", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.dig(rt.hashAref(self.(*RHash), args[0]), args[1:])
+	})
+	rt.DefineMethod(h, "length", 0, hashLength)
+	rt.DefineMethod(h, "size", 0, hashLength)
+	rt.DefineMethod(h, "empty?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self.(*RHash).size == 0)
+	})
+	rt.DefineMethod(h, "delete", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		if v, ok := rt.HashDelete(self.(*RHash), args[0]); ok {
+			return v
+		}
+		if blk != nil {
+			return rt.Yield(blk, args[0])
+		}
+		return Nil
+	})
+	rt.DefineMethod(h, "clear", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		self.(*RHash).clear()
+		return self
+	})
+	rt.DefineMethod(h, "default", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+		}
+		x := self.(*RHash)
+		if x.DefaultProc != nil {
+			if len(args) == 0 {
+				return Nil
+			}
+			return rt.Send(x.DefaultProc, "call", self, args[0])
+		}
+		return x.Default
+	})
+	rt.DefineMethod(h, "default=", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RHash)
+		x.Default, x.DefaultProc = args[0], nil
+		return args[0]
+	})
+	rt.DefineMethod(h, "default_proc", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if p := self.(*RHash).DefaultProc; p != nil {
+			return p
+		}
+		return Nil
+	})
+	rt.DefineMethod(h, "default_proc=", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x := self.(*RHash)
+		if args[0] == Nil {
+			x.DefaultProc = nil
+			return Nil
+		}
+		rt.checkDefaultProc(args[0])
+		x.Default, x.DefaultProc = Nil, args[0]
+		return args[0]
+	})
+	rt.DefineMethod(h, "compare_by_identity", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := self.(*RHash)
+		if x.Identity {
+			return self
+		}
+		rt.CheckFrozen(self)
+		if x.iter > 0 {
+			rt.Raise(rt.RuntimeError, "compare_by_identity during iteration")
+		}
+		x.Identity = true
+		rt.rehash(x)
+		return self
+	})
+	rt.DefineMethod(h, "compare_by_identity?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self.(*RHash).Identity)
+	})
+	rt.DefineMethod(h, "rehash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		rt.rehash(self.(*RHash))
+		return self
+	})
+	rt.DefineMethod(h, "replace", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		x, o := self.(*RHash), rt.toHash(args[0])
+		if x == o {
+			return self
+		}
+		x.clear()
+		x.Identity = o.Identity
+		rt.hashUpdate(x, o)
+		x.Default, x.DefaultProc = o.Default, o.DefaultProc
+		return self
+	})
+	rt.DefineMethod(h, "merge", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := rt.hashCopy(self.(*RHash))
+		for _, o := range args {
+			rt.hashMerge(x, rt.toHash(o), blk)
+		}
+		return x
+	})
+	for _, name := range []string{"merge!", "update"} {
+		rt.DefineMethod(h, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			rt.CheckFrozen(self)
+			for _, o := range args {
+				rt.hashMerge(self.(*RHash), rt.toHash(o), blk)
+			}
+			return self
+		})
+	}
+	rt.DefineMethod(h, "invert", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := rt.NewHash()
+		self.(*RHash).each(func(k, v Value) {
+			rt.HashSet(x, v, k)
+		})
+		return x
+	})
+	for _, name := range []string{"each", "each_pair"} {
+		rt.DefineMethod(h, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			self.(*RHash).each(func(k, v Value) {
+				rt.Yield(blk, rt.NewArray([]Value{k, v}))
+			})
+			return self
+		})
+	}
+	rt.DefineMethod(h, "each_key", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		self.(*RHash).each(func(k, v Value) {
+			rt.Yield(blk, k)
+		})
+		return self
+	})
+	rt.DefineMethod(h, "each_value", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		self.(*RHash).each(func(k, v Value) {
+			rt.Yield(blk, v)
+		})
+		return self
+	})
+	for _, name := range []string{"select", "filter", "reject"} {
+		keep := name != "reject"
+		rt.DefineMethod(h, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			x := rt.NewHash()
+			self.(*RHash).each(func(k, v Value) {
+				if Truthy(rt.Yield(blk, k, v)) == keep {
+					rt.HashSet(x, k, v)
+				}
+			})
+			return x
+		})
+	}
+	for _, name := range []string{"delete_if", "keep_if"} {
+		keep := name == "keep_if"
+		rt.DefineMethod(h, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			rt.CheckFrozen(self)
+			x := self.(*RHash)
+			x.each(func(k, v Value) {
+				if Truthy(rt.Yield(blk, k, v)) != keep {
+					rt.HashDelete(x, k)
+				}
+			})
+			return self
+		})
+	}
+	rt.DefineMethod(h, "transform_values", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := rt.NewHash()
+		self.(*RHash).each(func(k, v Value) {
+			rt.HashSet(x, k, rt.Yield(blk, v))
+		})
+		return x
+	})
+	rt.DefineMethod(h, "transform_keys", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		x := rt.NewHash()
+		self.(*RHash).each(func(k, v Value) {
+			rt.HashSet(x, rt.Yield(blk, k), v)
+		})
+		return x
+	})
+}
+
+func hashAset(rt *Runtime, self Value, args []Value, blk Value) Value {
+	rt.CheckFrozen(self)
+	rt.HashSet(self.(*RHash), args[0], args[1])
+	return args[1]
+}
+
+func hashLength(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return Fixnum(self.(*RHash).size)
+}
+
+// hashKeyOf returns the first key of the value, or nil.
+func (rt *Runtime) hashKeyOf(h *RHash, v Value) Value {
+	for _, e := range h.entries {
+		if e != nil && rt.Equal(e.value, v) {
+			return e.key
+		}
+	}
+	return nil
+}
+
+// hashMerge merges other into h, calling the block for the duplicated keys
+// with the key, the old value and the new value.
+func (rt *Runtime) hashMerge(h, other *RHash, blk Value) {
+	other.each(func(k, v Value) {
+		if blk != nil {
+			if old, ok := rt.HashGet(h, k); ok {
+				v = rt.Yield(blk, k, old, v)
+			}
+		}
+		rt.HashSet(h, k, v)
+	})
+}
+
+// hashEqual returns whether the hashes have the same keys and the values
+// equal by == or eql?.
+func (rt *Runtime) hashEqual(h *RHash, v Value, eql bool) bool {
+	o, ok := v.(*RHash)
+	if !ok {
+		if !eql && rt.RespondTo(v, "to_hash") {
+			return rt.Equal(v, h)
+		}
+		return false
+	}
+	if h == o {
+		return true
+	}
+	if h.size != o.size {
+		return false
+	}
+	return Truthy(rt.recursive("==", h, o, func(recur bool) Value {
+		if recur {
+			return True
+		}
+		for _, e := range h.entries {
+			if e == nil {
+				continue
+			}
+			v, ok := rt.HashGet(o, e.key)
+			if !ok || eql && !rt.Eql(e.value, v) || !eql && !rt.Equal(e.value, v) {
+				return False
+			}
+		}
+		return True
+	}))
+}
+
+func hashInspect(rt *Runtime, self Value, args []Value, blk Value) Value {
+	x := self.(*RHash)
+	if x.size == 0 {
+		return rt.NewString("{}")
+	}
+	return rt.recursive("inspect", self, nil, func(recur bool) Value {
+		if recur {
+			return rt.NewString("{...}")
+		}
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		x.each(func(k, v Value) {
+			if buf.Len() > 1 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(rt.Inspect(k))
+			buf.WriteString("=>")
+			buf.WriteString(rt.Inspect(v))
+		})
+		buf.WriteByte('}')
+		return &RString{B: buf.Bytes()}
+	})
+}
+
+// dig returns the value digged by the keys, calling dig of the value.
+func (rt *Runtime) dig(v Value, keys []Value) Value {
+	if len(keys) == 0 || v == Nil {
+		return v
+	}
+	if !rt.RespondTo(v, "dig") {
+		rt.Raise(rt.TypeError, "%s does not have #dig method", rt.ClassOf(v).RealClass().Name)
+	}
+	return rt.Send(v, "dig", keys...)
+}
+
+// bigHash returns the hash of the big integer.
+func bigHash(n *big.Int) uint64 {
+	b := n.Bytes()
+	if n.Sign() < 0 {
+		b = append(b, '-')
+	}
+	return hashBytes(b)
+}
@@ -0,0 +1,740 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/harukasan/ringo/literal"
+)
+
+// Bignum is an Integer which does not fit in Fixnum. The Integers are
+// normalized by NewInteger, so a Bignum never holds the value of Fixnum.
+// The big.Int is shared and must not be modified.
+type Bignum struct {
+	Int *big.Int
+}
+
+func (*Bignum) value() {}
+
+var (
+	bigOne    = big.NewInt(1)
+	minFixnum = big.NewInt(math.MinInt64)
+)
+
+// maxPowBits is the limit of the bits of a ** b. The larger result is
+// Infinity as Ruby does.
+const maxPowBits = 32 * 1024 * 1024
+
+// NewInteger returns the Integer of n, which is Fixnum if n fits in int64.
+func NewInteger(n *big.Int) Value {
+	if n.BitLen() < 64 || n.Cmp(minFixnum) == 0 {
+		return Fixnum(n.Int64())
+	}
+	return &Bignum{n}
+}
+
+// isInteger returns whether the value is an Integer.
+func isInteger(v Value) bool {
+	switch v.(type) {
+	case Fixnum, *Bignum:
+		return true
+	}
+	return false
+}
+
+// bigOf returns the Integer as big.Int.
+func bigOf(v Value) *big.Int {
+	if n, ok := v.(*Bignum); ok {
+		return n.Int
+	}
+	return big.NewInt(int64(v.(Fixnum)))
+}
+
+// intToFloat returns the Integer as float64, rounded to the nearest.
+func intToFloat(v Value) float64 {
+	if n, ok := v.(Fixnum); ok {
+		return float64(n)
+	}
+	f, _ := new(big.Float).SetInt(bigOf(v)).Float64()
+	return f
+}
+
+func intAdd(a, b Value) Value {
+	if x, ok := a.(Fixnum); ok {
+		if y, ok := b.(Fixnum); ok {
+			if c := x + y; (c > x) == (y > 0) {
+				return c
+			}
+		}
+	}
+	return NewInteger(new(big.Int).Add(bigOf(a), bigOf(b)))
+}
+
+func intSub(a, b Value) Value {
+	if x, ok := a.(Fixnum); ok {
+		if y, ok := b.(Fixnum); ok {
+			if c := x - y; (c < x) == (y > 0) {
+				return c
+			}
+		}
+	}
+	return NewInteger(new(big.Int).Sub(bigOf(a), bigOf(b)))
+}
+
+func intMul(a, b Value) Value {
+	if x, ok := a.(Fixnum); ok {
+		if y, ok := b.(Fixnum); ok {
+			if x == 0 || y == 0 {
+				return Fixnum(0)
+			}
+			if c := x * y; c/y == x && !(x == -1 && y == math.MinInt64 || y == -1 && x == math.MinInt64) {
+				return c
+			}
+		}
+	}
+	return NewInteger(new(big.Int).Mul(bigOf(a), bigOf(b)))
+}
+
+// intDivmod returns the floored quotient and modulo of the Integers, so
+// that the modulo has the same sign as b.
+func (rt *Runtime) intDivmod(a, b Value) (Value, Value) {
+	if y, ok := b.(Fixnum); ok {
+		if y == 0 {
+			rt.Raise(rt.ZeroDivisionError, "divided by 0")
+		}
+		if x, ok := a.(Fixnum); ok && !(x == math.MinInt64 && y == -1) {
+			q, m := x/y, x%y
+			if m != 0 && (m < 0) != (y < 0) {
+				q--
+				m += y
+			}
+			return q, m
+		}
+	}
+	y := bigOf(b)
+	q, m := new(big.Int).QuoRem(bigOf(a), y, new(big.Int))
+	if m.Sign() != 0 && (m.Sign() < 0) != (y.Sign() < 0) {
+		q.Sub(q, bigOne)
+		m.Add(m, y)
+	}
+	return NewInteger(q), NewInteger(m)
+}
+
+// intPow returns a ** b of the Integers. The negative exponent results in
+// Float since Rational is not supported, while 0 ** -1 raises
+// ZeroDivisionError as Rational does.
+func (rt *Runtime) intPow(a, b Value) Value {
+	x := bigOf(a)
+	switch {
+	case intSign(b) < 0:
+		if x.Sign() == 0 {
+			rt.Raise(rt.ZeroDivisionError, "divided by 0")
+		}
+		return Float(math.Pow(intToFloat(a), intToFloat(b)))
+	case x.BitLen() <= 1: // 0, 1 and -1
+		return NewInteger(new(big.Int).Exp(x, bigOf(b), nil))
+	}
+	y, ok := b.(Fixnum)
+	if !ok || int64(y) > maxPowBits/int64(x.BitLen()) {
+		return Float(math.Pow(intToFloat(a), intToFloat(b)))
+	}
+	if r, ok := a.(Fixnum); ok {
+		if p, ok := powFixnum(r, y); ok {
+			return p
+		}
+	}
+	return NewInteger(new(big.Int).Exp(x, big.NewInt(int64(y)), nil))
+}
+
+// powFixnum returns a ** b by squaring, or false if it overflows.
+func powFixnum(a, b Fixnum) (Value, bool) {
+	r := Value(Fixnum(1))
+	for ; b > 0; b >>= 1 {
+		if b&1 == 1 {
+			if r = intMul(r, a); !isFixnum(r) {
+				return nil, false
+			}
+		}
+		if b > 1 {
+			c := intMul(a, a)
+			if !isFixnum(c) {
+				return nil, false
+			}
+			a = c.(Fixnum)
+		}
+	}
+	return r, true
+}
+
+func isFixnum(v Value) bool {
+	_, ok := v.(Fixnum)
+	return ok
+}
+
+// intModPow returns a ** b % m of the Integers, which has the same sign as
+// m.
+func (rt *Runtime) intModPow(a, b, m Value) Value {
+	if intSign(b) < 0 {
+		rt.Raise(rt.RangeError, "Integer#pow() 2nd argument not allowed to be negative when 3rd argument specified")
+	}
+	if intSign(m) == 0 {
+		rt.Raise(rt.ZeroDivisionError, "divided by 0")
+	}
+	y := bigOf(m)
+	r := new(big.Int).Exp(bigOf(a), bigOf(b), new(big.Int).Abs(y))
+	if r.Sign() != 0 && y.Sign() < 0 {
+		r.Add(r, y)
+	}
+	return NewInteger(r)
+}
+
+func intSign(v Value) int {
+	if n, ok := v.(Fixnum); ok {
+		switch {
+		case n < 0:
+			return -1
+		case n > 0:
+			return 1
+		}
+		return 0
+	}
+	return bigOf(v).Sign()
+}
+
+// intCmp compares the Integers, and returns -1, 0 or 1.
+func intCmp(a, b Value) int {
+	if x, ok := a.(Fixnum); ok {
+		if y, ok := b.(Fixnum); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return bigOf(a).Cmp(bigOf(b))
+}
+
+// compareIntFloat compares the Integer with the Float exactly without
+// rounding the Integer. It returns nil for NaN.
+func compareIntFloat(a Value, f float64) Value {
+	switch {
+	case math.IsNaN(f):
+		return Nil
+	case math.IsInf(f, 0):
+		if f > 0 {
+			return Fixnum(-1)
+		}
+		return Fixnum(1)
+	}
+	if x, ok := a.(Fixnum); ok && x > -1<<53 && x < 1<<53 {
+		return compareFloat(float64(x), f)
+	}
+	return Fixnum(new(big.Float).SetInt(bigOf(a)).Cmp(big.NewFloat(f)))
+}
+
+// intShift shifts the Integer to the left by n bits, or to the right by -n
+// bits.
+func (rt *Runtime) intShift(a Value, n int64) Value {
+	if x, ok := a.(Fixnum); ok {
+		switch {
+		case n <= -64:
+			if x < 0 {
+				return Fixnum(-1)
+			}
+			return Fixnum(0)
+		case n < 0:
+			return x >> uint(-n)
+		case n < 63:
+			if c := x << uint(n); c>>uint(n) == x {
+				return c
+			}
+		}
+	}
+	if n < 0 {
+		if x := bigOf(a); int64(x.BitLen()) < -n {
+			if x.Sign() < 0 {
+				return Fixnum(-1)
+			}
+			return Fixnum(0)
+		}
+		return NewInteger(new(big.Int).Rsh(bigOf(a), uint(-n)))
+	}
+	if intSign(a) == 0 {
+		return Fixnum(0)
+	}
+	if n > maxPowBits {
+		rt.Raise(rt.RangeError, "shift width too big")
+	}
+	return NewInteger(new(big.Int).Lsh(bigOf(a), uint(n)))
+}
+
+// shiftWidth returns the width of << and >>. The Bignum width is clamped
+// as it shifts out all the bits or is too big anyway.
+func (rt *Runtime) shiftWidth(v Value) int64 {
+	if n, ok := v.(*Bignum); ok {
+		if n.Int.Sign() < 0 {
+			return math.MinInt64 + 1
+		}
+		return math.MaxInt64
+	}
+	return int64(rt.toInt(v))
+}
+
+// intRoundZero returns whether the Integer is rounded to zero at the
+// negative ndigits, that is 10 ** -ndigits / 2 > |v|, estimated by the bytes
+// of v as MRI does.
+func intRoundZero(v Value, ndigits int) bool {
+	bytes := 8
+	if n, ok := v.(*Bignum); ok {
+		bytes = (n.Int.BitLen() + 63) / 64 * 8
+	}
+	return -0.415241*float64(ndigits)-0.125 > float64(bytes)
+}
+
+// intRoundDigits returns the floored quotient and modulo of the Integer by
+// 10 ** -ndigits, and the divisor.
+func (rt *Runtime) intRoundDigits(v Value, ndigits int) (q, m, p Value) {
+	p = NewInteger(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-ndigits)), nil))
+	q, m = rt.intDivmod(v, p)
+	return q, m, p
+}
+
+// intRound rounds the Integer at the negative ndigits, such as 1250 to 1300
+// at -2.
+func (rt *Runtime) intRound(v Value, ndigits int, mode roundMode) Value {
+	switch {
+	case ndigits >= 0:
+		return v
+	case intRoundZero(v, ndigits):
+		return Fixnum(0)
+	}
+	q, m, p := rt.intRoundDigits(v, ndigits)
+	up := false
+	switch c := intCmp(intMul(m, Fixnum(2)), p); {
+	case c != 0:
+		up = c > 0
+	case mode == roundHalfUp:
+		up = intSign(v) > 0
+	case mode == roundHalfDown:
+		up = intSign(v) < 0
+	default:
+		up = bigOf(q).Bit(0) == 1
+	}
+	if up {
+		q = intAdd(q, Fixnum(1))
+	}
+	return intMul(q, p)
+}
+
+// intFloor returns the largest multiple of 10 ** -ndigits not greater than
+// the Integer.
+func (rt *Runtime) intFloor(v Value, ndigits int) Value {
+	switch {
+	case ndigits >= 0:
+		return v
+	case intRoundZero(v, ndigits):
+		return Fixnum(0)
+	}
+	q, _, p := rt.intRoundDigits(v, ndigits)
+	return intMul(q, p)
+}
+
+// intCeil returns the smallest multiple of 10 ** -ndigits not less than the
+// Integer.
+func (rt *Runtime) intCeil(v Value, ndigits int) Value {
+	switch {
+	case ndigits >= 0:
+		return v
+	case intRoundZero(v, ndigits):
+		return Fixnum(0)
+	}
+	q, m, p := rt.intRoundDigits(v, ndigits)
+	if intSign(m) != 0 {
+		q = intAdd(q, Fixnum(1))
+	}
+	return intMul(q, p)
+}
+
+// FormatInt returns the string of the Integer in the base.
+func FormatInt(v Value, base int) string {
+	if n, ok := v.(Fixnum); ok {
+		return strconv.FormatInt(int64(n), base)
+	}
+	return bigOf(v).Text(base)
+}
+
+// ParseInt parses the string as Kernel#Integer does. The string is the
+// integer literal of the scanner such as -0b1_01 surrounded by the white
+// spaces. The base 0 means the base given by the prefix.
+func ParseInt(s string, base int) (Value, bool) {
+	n, err := literal.IntBase([]byte(strings.TrimSpace(s)), base)
+	if err != nil {
+		return nil, false
+	}
+	switch n := n.(type) {
+	case int64:
+		return Fixnum(n), true
+	case *big.Int:
+		return NewInteger(n), true
+	}
+	return nil, false
+}
+
+func (rt *Runtime) initInteger() {
+	i := rt.Integer
+	rt.DefineMethod(i, "+", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isInteger(args[0]) {
+			return intAdd(self, args[0])
+		}
+		return Float(intToFloat(self) + rt.toFloat(self, args[0]))
+	})
+	rt.DefineMethod(i, "-", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isInteger(args[0]) {
+			return intSub(self, args[0])
+		}
+		return Float(intToFloat(self) - rt.toFloat(self, args[0]))
+	})
+	rt.DefineMethod(i, "*", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isInteger(args[0]) {
+			return intMul(self, args[0])
+		}
+		return Float(intToFloat(self) * rt.toFloat(self, args[0]))
+	})
+	intDiv := func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isInteger(args[0]) {
+			q, _ := rt.intDivmod(self, args[0])
+			return q
+		}
+		return Float(intToFloat(self) / rt.toFloat(self, args[0]))
+	}
+	rt.DefineMethod(i, "/", 1, intDiv)
+	rt.DefineMethod(i, "div", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isInteger(args[0]) {
+			q, _ := rt.intDivmod(self, args[0])
+			return q
+		}
+		y := rt.toFloat(self, args[0])
+		if y == 0 {
+			rt.Raise(rt.ZeroDivisionError, "divided by 0")
+		}
+		return rt.floatToInt(math.Floor(intToFloat(self) / y))
+	})
+	intMod := func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isInteger(args[0]) {
+			_, m := rt.intDivmod(self, args[0])
+			return m
+		}
+		return Float(rt.floatMod(intToFloat(self), rt.toFloat(self, args[0])))
+	}
+	rt.DefineMethod(i, "%", 1, intMod)
+	rt.DefineMethod(i, "modulo", 1, intMod)
+	rt.DefineMethod(i, "divmod", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isInteger(args[0]) {
+			q, m := rt.intDivmod(self, args[0])
+			return rt.NewArray([]Value{q, m})
+		}
+		div, mod := rt.floatDivmod(intToFloat(self), rt.toFloat(self, args[0]))
+		return rt.NewArray([]Value{rt.floatToInt(div), Float(mod)})
+	})
+	rt.DefineMethod(i, "fdiv", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Float(intToFloat(self) / rt.toFloat(self, args[0]))
+	})
+	rt.DefineMethod(i, "**", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isInteger(args[0]) {
+			return rt.intPow(self, args[0])
+		}
+		return Float(math.Pow(intToFloat(self), rt.toFloat(self, args[0])))
+	})
+	rt.DefineMethod(i, "pow", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		switch len(args) {
+		case 1:
+			return rt.Send(self, "**", args[0])
+		case 2:
+			if !isInteger(args[0]) || !isInteger(args[1]) {
+				rt.Raise(rt.TypeError, "Integer#pow() 2nd argument not allowed unless all arguments are integers")
+			}
+			return rt.intModPow(self, args[0], args[1])
+		}
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+		return nil
+	})
+	rt.DefineMethod(i, "-@", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return intSub(Fixnum(0), self)
+	})
+	rt.DefineMethod(i, "+@", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(i, "~", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if n, ok := self.(Fixnum); ok {
+			return ^n
+		}
+		return NewInteger(new(big.Int).Not(bigOf(self)))
+	})
+	bitwise := map[string]struct {
+		fix func(a, b Fixnum) Fixnum
+		big func(z, a, b *big.Int) *big.Int
+	}{
+		"&": {func(a, b Fixnum) Fixnum { return a & b }, (*big.Int).And},
+		"|": {func(a, b Fixnum) Fixnum { return a | b }, (*big.Int).Or},
+		"^": {func(a, b Fixnum) Fixnum { return a ^ b }, (*big.Int).Xor},
+	}
+	for name, op := range bitwise {
+		op := op
+		rt.DefineMethod(i, name, 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if !isInteger(args[0]) {
+				rt.coerceFailed(self, args[0])
+			}
+			a, ok := self.(Fixnum)
+			b, ok2 := args[0].(Fixnum)
+			if ok && ok2 {
+				return op.fix(a, b)
+			}
+			return NewInteger(op.big(new(big.Int), bigOf(self), bigOf(args[0])))
+		})
+	}
+	rt.DefineMethod(i, "<<", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.intShift(self, rt.shiftWidth(args[0]))
+	})
+	rt.DefineMethod(i, ">>", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.intShift(self, -rt.shiftWidth(args[0]))
+	})
+	rt.DefineMethod(i, "[]", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		n := rt.shiftWidth(args[0])
+		switch {
+		case n < 0:
+			return Fixnum(0)
+		case n > math.MaxInt32:
+			n = math.MaxInt32
+		}
+		return Fixnum(bigOf(self).Bit(int(n)))
+	})
+	rt.DefineMethod(i, "==", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		switch b := args[0].(type) {
+		case Fixnum, *Bignum:
+			return Bool(intCmp(self, b) == 0)
+		case Float:
+			return Bool(compareIntFloat(self, float64(b)) == Fixnum(0))
+		}
+		return Bool(rt.Equal(args[0], self))
+	})
+	rt.DefineMethod(i, "<=>", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		switch b := args[0].(type) {
+		case Fixnum, *Bignum:
+			return Fixnum(intCmp(self, b))
+		case Float:
+			return compareIntFloat(self, float64(b))
+		}
+		return Nil
+	})
+	rt.defineCompare(i)
+	rt.DefineMethod(i, "to_s", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		base := 10
+		if len(args) > 0 {
+			base = int(rt.toInt(args[0]))
+			if base < 2 || base > 36 {
+				rt.Raise(rt.ArgumentError, "invalid radix %d", base)
+			}
+		}
+		return rt.NewString(FormatInt(self, base))
+	})
+	rt.DefineMethod(i, "inspect", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString(FormatInt(self, 10))
+	})
+	rt.DefineMethod(i, "round", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		ndigits, mode := rt.roundArgs(args)
+		return rt.intRound(self, ndigits, mode)
+	})
+	rt.DefineMethod(i, "floor", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.intFloor(self, rt.digitsArg(args))
+	})
+	rt.DefineMethod(i, "ceil", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.intCeil(self, rt.digitsArg(args))
+	})
+	rt.DefineMethod(i, "truncate", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if intSign(self) < 0 {
+			return rt.intCeil(self, rt.digitsArg(args))
+		}
+		return rt.intFloor(self, rt.digitsArg(args))
+	})
+	rt.DefineMethod(i, "to_i", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(i, "to_int", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(i, "to_f", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Float(intToFloat(self))
+	})
+	rt.DefineMethod(i, "chr", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+		}
+		n, ok := self.(Fixnum)
+		e := Binary
+		switch {
+		case len(args) == 1:
+			e = rt.toEncoding(args[0])
+		case n < 0x80:
+			e = USASCII
+		}
+		if !ok || n < 0 || n >= 0x80 && e == USASCII || n > 0xff && e == Binary {
+			rt.Raise(rt.RangeError, "%s out of char range", FormatInt(self, 10))
+		}
+		return stringConcat(rt, &RString{Enc: e}, []Value{n}, nil)
+	})
+	rt.DefineMethod(i, "ord", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(i, "succ", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return intAdd(self, Fixnum(1))
+	})
+	rt.DefineMethod(i, "pred", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return intSub(self, Fixnum(1))
+	})
+	rt.DefineMethod(i, "times", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		for n := Value(Fixnum(0)); intCmp(n, self) < 0; n = intAdd(n, Fixnum(1)) {
+			rt.Yield(blk, n)
+		}
+		return self
+	})
+	rt.DefineMethod(i, "upto", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		for n := self; rt.compareValues(n, args[0], nil) <= 0; n = intAdd(n, Fixnum(1)) {
+			rt.Yield(blk, n)
+		}
+		return self
+	})
+	rt.DefineMethod(i, "downto", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		for n := self; rt.compareValues(n, args[0], nil) >= 0; n = intSub(n, Fixnum(1)) {
+			rt.Yield(blk, n)
+		}
+		return self
+	})
+	rt.DefineMethod(i, "abs", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if intSign(self) < 0 {
+			return intSub(Fixnum(0), self)
+		}
+		return self
+	})
+	rt.DefineMethod(i, "bit_length", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		n := bigOf(self)
+		if n.Sign() < 0 {
+			n = new(big.Int).Not(n)
+		}
+		return Fixnum(n.BitLen())
+	})
+	rt.DefineMethod(i, "zero?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(intSign(self) == 0)
+	})
+	rt.DefineMethod(i, "even?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(bigOf(self).Bit(0) == 0)
+	})
+	rt.DefineMethod(i, "odd?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(bigOf(self).Bit(0) == 1)
+	})
+	rt.DefineMethod(i, "hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if n, ok := self.(*Bignum); ok {
+			return Fixnum(bigHash(n.Int))
+		}
+		return Fixnum(rt.hashOf(self))
+	})
+	rt.DefineMethod(i, "eql?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(isInteger(args[0]) && intCmp(self, args[0]) == 0)
+	})
+	rt.definePrivate(rt.Kernel, "Integer", -2, kernelInteger)
+}
+
+// kernelInteger converts the value to an Integer strictly, where the string
+// must be an integer literal.
+func kernelInteger(rt *Runtime, self Value, args []Value, blk Value) Value {
+	if len(args) > 2 {
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+	}
+	v := args[0]
+	if len(args) == 2 && args[1] != Nil {
+		base := int(rt.toInt(args[1]))
+		s, ok := v.(*RString)
+		switch {
+		case !ok:
+			rt.Raise(rt.ArgumentError, "base specified for non string value")
+		case base == 1 || base < 0 || base > 36:
+			rt.Raise(rt.ArgumentError, "invalid radix %d", base)
+		}
+		if n, ok := ParseInt(string(s.B), base); ok {
+			return n
+		}
+		rt.Raise(rt.ArgumentError, "invalid value for Integer(): %s", rt.Inspect(v))
+	}
+	switch v := v.(type) {
+	case Fixnum, *Bignum:
+		return v
+	case Float:
+		return rt.floatToInt(math.Trunc(float64(v)))
+	case *RString:
+		if n, ok := ParseInt(string(v.B), 0); ok {
+			return n
+		}
+		rt.Raise(rt.ArgumentError, "invalid value for Integer(): %s", rt.Inspect(v))
+	case nilValue:
+		rt.Raise(rt.TypeError, "can't convert nil into Integer")
+	}
+	for _, name := range []string{"to_int", "to_i"} {
+		if rt.RespondTo(v, name) {
+			if n := rt.Send(v, name); isInteger(n) {
+				return n
+			}
+			break
+		}
+	}
+	rt.Raise(rt.TypeError, "can't convert %s into Integer", rt.ClassOf(v).RealClass().Name)
+	return nil
+}
+
+// strToInt returns the leading integer of the string as String#to_i, which
+// ignores the invalid characters following the digits.
+func strToInt(b []byte, base int) Value {
+	s := strings.TrimLeft(string(b), " \t\n\v\f\r")
+	neg := len(s) > 0 && s[0] == '-'
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	if len(s) > 1 && s[0] == '0' {
+		p := map[int]string{2: "bB", 8: "oO_", 10: "dD", 16: "xX"}[base]
+		if strings.IndexByte(p, s[1]) >= 0 {
+			s = s[2:]
+		}
+	}
+	var digits []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' && len(digits) > 0 && i+1 < len(s) && s[i+1] != '_' {
+			continue
+		}
+		n := strings.IndexByte("0123456789abcdefghijklmnopqrstuvwxyz", c|0x20)
+		if c == '_' || n < 0 || n >= base {
+			break
+		}
+		digits = append(digits, c)
+	}
+	if neg {
+		digits = append([]byte{'-'}, digits...)
+	}
+	n, ok := new(big.Int).SetString(string(digits), base)
+	if !ok {
+		return Fixnum(0)
+	}
+	return NewInteger(n)
+}
@@ -0,0 +1,351 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+func (rt *Runtime) initKernel() {
+	b := rt.BasicObject
+	rt.definePrivate(b, "initialize", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Nil
+	})
+	rt.DefineMethod(b, "==", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(Identical(self, args[0]))
+	})
+	rt.DefineMethod(b, "equal?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(Identical(self, args[0]))
+	})
+	rt.DefineMethod(b, "!", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(!Truthy(self))
+	})
+	rt.DefineMethod(b, "!=", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(!rt.Equal(self, args[0]))
+	})
+	rt.DefineMethod(b, "__id__", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.ObjectID(self)
+	})
+	rt.DefineMethod(b, "__send__", -2, kernelSend)
+
+	k := rt.Kernel
+	rt.definePrivate(k, "puts", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var buf bytes.Buffer
+		if len(args) == 0 {
+			buf.WriteByte('\n')
+		}
+		for _, arg := range args {
+			rt.writeLines(&buf, arg)
+		}
+		rt.Stdout.Write(buf.Bytes())
+		return Nil
+	})
+	rt.definePrivate(k, "print", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var buf bytes.Buffer
+		for _, arg := range args {
+			buf.WriteString(rt.ToS(arg))
+		}
+		rt.Stdout.Write(buf.Bytes())
+		return Nil
+	})
+	rt.definePrivate(k, "p", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var buf bytes.Buffer
+		for _, arg := range args {
+			buf.WriteString(rt.Inspect(arg))
+			buf.WriteByte('\n')
+		}
+		rt.Stdout.Write(buf.Bytes())
+		switch len(args) {
+		case 0:
+			return Nil
+		case 1:
+			return args[0]
+		}
+		return rt.NewArray(append([]Value(nil), args...))
+	})
+	rt.definePrivate(k, "raise", -1, kernelRaise)
+	rt.definePrivate(k, "fail", -1, kernelRaise)
+	rt.DefineMethod(k, "class", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.ClassOf(self).RealClass()
+	})
+	rt.DefineMethod(k, "to_s", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString(rt.anyToS(self))
+	})
+	rt.DefineMethod(k, "inspect", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString(rt.inspectObject(self))
+	})
+	rt.DefineMethod(k, "nil?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return False
+	})
+	rt.DefineMethod(k, "===", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.Equal(self, args[0]))
+	})
+	rt.DefineMethod(k, "=~", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Nil
+	})
+	rt.DefineMethod(k, "<=>", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if rt.Equal(self, args[0]) {
+			return Fixnum(0)
+		}
+		return Nil
+	})
+	rt.DefineMethod(k, "eql?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self == args[0])
+	})
+	rt.DefineMethod(k, "hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.ObjectID(self)
+	})
+	rt.DefineMethod(k, "object_id", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.ObjectID(self)
+	})
+	rt.DefineMethod(k, "respond_to?", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		name := rt.toID(args[0])
+		if len(args) > 1 && Truthy(args[1]) {
+			return Bool(rt.FindMethod(rt.ClassOf(self), name) != nil)
+		}
+		m := rt.FindMethod(rt.ClassOf(self), name)
+		return Bool(m != nil && m.Visibility == Public)
+	})
+	rt.DefineMethod(k, "send", -2, kernelSend)
+	rt.DefineMethod(k, "public_send", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.Call(self, rt.toID(args[0]), args[1:], blk, false)
+	})
+	rt.DefineMethod(k, "is_a?", 1, kernelIsA)
+	rt.DefineMethod(k, "kind_of?", 1, kernelIsA)
+	rt.DefineMethod(k, "instance_of?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.ClassOf(self).RealClass() == rt.toClass(args[0]))
+	})
+	rt.DefineMethod(k, "instance_variable_get", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.Ivar(self, rt.ivarName(args[0]))
+	})
+	rt.DefineMethod(k, "instance_variable_set", 2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.SetIvar(self, rt.ivarName(args[0]), args[1])
+		return args[1]
+	})
+	rt.DefineMethod(k, "instance_variable_defined?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.IvarDefined(self, rt.ivarName(args[0])))
+	})
+	rt.DefineMethod(k, "instance_variables", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var names []Value
+		for _, name := range rt.ivarNames(self) {
+			names = append(names, SymbolOf(name))
+		}
+		return rt.NewArray(names)
+	})
+	rt.DefineMethod(k, "frozen?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.Frozen(self))
+	})
+	rt.DefineMethod(k, "freeze", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.Freeze(self)
+		return self
+	})
+	rt.DefineMethod(k, "dup", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.Dup(self)
+	})
+	rt.DefineMethod(k, "singleton_class", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.SingletonClassOf(self)
+	})
+	rt.DefineMethod(k, "singleton_methods", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		c := rt.ClassOf(self)
+		if !c.Singleton {
+			return rt.NewArray(nil)
+		}
+		return rt.methodList(c, false, func(m *Method) bool { return m.Visibility != Private })
+	})
+	rt.DefineMethod(k, "extend", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		mods := append([]Value(nil), args...)
+		for i := len(mods) - 1; i >= 0; i-- {
+			m, ok := mods[i].(*RClass)
+			if !ok || !m.IsModule {
+				rt.Raise(rt.TypeError, "wrong argument type %s (expected Module)", rt.ClassOf(mods[i]).RealClass().Name)
+			}
+			rt.Send(m, "extend_object", self)
+			rt.Send(m, "extended", self)
+		}
+		return self
+	})
+	rt.DefineMethod(k, "itself", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+
+	n := rt.NilClass
+	rt.DefineMethod(n, "to_s", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString("")
+	})
+	rt.DefineMethod(n, "to_a", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewArray(nil)
+	})
+	rt.DefineMethod(n, "to_i", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Fixnum(0)
+	})
+	rt.DefineMethod(n, "inspect", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString("nil")
+	})
+	rt.DefineMethod(n, "nil?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return True
+	})
+	for _, c := range []*RClass{rt.NilClass, rt.TrueClass, rt.FalseClass} {
+		rt.DefineMethod(c, "&", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return Bool(Truthy(self) && Truthy(args[0]))
+		})
+		rt.DefineMethod(c, "|", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return Bool(Truthy(self) || Truthy(args[0]))
+		})
+		rt.DefineMethod(c, "^", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return Bool(Truthy(self) != Truthy(args[0]))
+		})
+	}
+	for _, c := range []*RClass{rt.TrueClass, rt.FalseClass} {
+		rt.DefineMethod(c, "to_s", 0, boolToS)
+		rt.DefineMethod(c, "inspect", 0, boolToS)
+	}
+}
+
+func boolToS(rt *Runtime, self Value, args []Value, blk Value) Value {
+	if self == True {
+		return rt.NewString("true")
+	}
+	return rt.NewString("false")
+}
+
+func kernelSend(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return rt.Call(self, rt.toID(args[0]), args[1:], blk, true)
+}
+
+func kernelIsA(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return Bool(rt.IsKindOf(self, rt.toClass(args[0])))
+}
+
+// kernelRaise implements raise, which takes no arguments, a message, or an
+// exception class or object with an optional message.
+func kernelRaise(rt *Runtime, self Value, args []Value, blk Value) Value {
+	if len(args) > 2 {
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..2)", len(args))
+	}
+	if len(args) == 0 {
+		if err, ok := rt.Globals["$!"].(*RObject); ok {
+			rt.RaiseException(err)
+		}
+		rt.Raise(rt.RuntimeError, "unhandled exception")
+	}
+	if s, ok := args[0].(*RString); ok && len(args) == 1 {
+		rt.Raise(rt.RuntimeError, "%s", s.B)
+	}
+	if !rt.RespondTo(args[0], "exception") {
+		rt.Raise(rt.TypeError, "exception class/object expected")
+	}
+	exc := rt.Send(args[0], "exception", args[1:]...)
+	rt.RaiseException(exc)
+	return Nil
+}
+
+// toClass returns the value as a class, or raises TypeError.
+func (rt *Runtime) toClass(v Value) *RClass {
+	c, ok := v.(*RClass)
+	if !ok {
+		rt.Raise(rt.TypeError, "class or module required")
+	}
+	return c
+}
+
+// ivarName returns the name of instance variable given by a symbol or a
+// string, raising NameError for invalid names.
+func (rt *Runtime) ivarName(v Value) ID {
+	id := rt.toID(v)
+	if name := id.String(); len(name) < 2 || name[0] != '@' || name[1] == '@' {
+		rt.Raise(rt.NameError, "'%s' is not allowed as an instance variable name", name)
+	}
+	return id
+}
+
+// ivarNames returns the names of the instance variables in the order of
+// the names.
+func (rt *Runtime) ivarNames(v Value) []string {
+	o, ok := v.(heapObject)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for id := range o.object().ivars {
+		if name := id.String(); name[0] == '@' {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inspectObject returns the default inspect of objects with the instance
+// variables such as #<Foo:0x0010 @a=1>.
+func (rt *Runtime) inspectObject(v Value) string {
+	names := rt.ivarNames(v)
+	s := rt.anyToS(v)
+	if len(names) == 0 {
+		return s
+	}
+	var buf bytes.Buffer
+	buf.WriteString(s[:len(s)-1])
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, " %s=%s", name, rt.Inspect(rt.Ivar(v, Intern(name))))
+	}
+	buf.WriteByte('>')
+	return buf.String()
+}
+
+// writeLines writes the value as puts, which writes the elements of arrays
+// in lines.
+func (rt *Runtime) writeLines(w io.Writer, v Value) {
+	if a, ok := v.(*RArray); ok {
+		for _, e := range a.Elems {
+			rt.writeLines(w, e)
+		}
+		return
+	}
+	s := rt.ToS(v)
+	io.WriteString(w, s)
+	if len(s) == 0 || s[len(s)-1] != '\n' {
+		io.WriteString(w, "\n")
+	}
+}
+
+// Dup returns the shallow copy of the value which is not frozen.
+func (rt *Runtime) Dup(v Value) Value {
+	var o *RObject
+	var dup Value
+	switch v := v.(type) {
+	case *RString:
+		s := &RString{RObject: v.RObject, B: append([]byte(nil), v.B...), Enc: v.Enc}
+		o, dup = &s.RObject, s
+	case *RArray:
+		a := &RArray{RObject: v.RObject, Elems: append([]Value(nil), v.Elems...)}
+		o, dup = &a.RObject, a
+	case *RHash:
+		h := rt.hashCopy(v)
+		h.RObject = v.RObject
+		o, dup = &h.RObject, h
+	case *RObject:
+		c := *v
+		o, dup = &c, &c
+	case *RClass:
+		rt.NotImplemented("dup of class")
+	default:
+		return v
+	}
+	o.frozen = false
+	if o.class != nil && o.class.Singleton {
+		o.class = o.class.RealClass()
+	}
+	if o.ivars != nil {
+		ivars := make(map[ID]Value, len(o.ivars))
+		for k, x := range o.ivars {
+			ivars[k] = x
+		}
+		o.ivars = ivars
+	}
+	rt.track(o)
+	return dup
+}
@@ -0,0 +1,73 @@
+package object
+
+import "strings"
+
+// Keywords is the keyword arguments of a call, which the keyword parameters
+// take by the names. The keyword arguments are given as the trailing hash.
+type Keywords struct {
+	rt      *Runtime
+	hash    *RHash
+	missing []ID
+}
+
+// SplitKeywords splits the keyword arguments off the arguments of the
+// method which requires n positional arguments. The last argument is taken
+// as the keywords if it is a hash given beyond the required ones.
+func (rt *Runtime) SplitKeywords(args []Value, n int) ([]Value, *Keywords) {
+	kw := &Keywords{rt: rt}
+	if len(args) > n {
+		if h, ok := args[len(args)-1].(*RHash); ok {
+			kw.hash = rt.hashCopy(h)
+			args = args[:len(args)-1]
+		}
+	}
+	if kw.hash == nil {
+		kw.hash = rt.NewHash()
+	}
+	return args, kw
+}
+
+// Take returns the keyword argument of the name, removing it from the
+// keywords. It returns false if the keyword is not given.
+func (kw *Keywords) Take(name ID) (Value, bool) {
+	return kw.rt.HashDelete(kw.hash, Symbol(name))
+}
+
+// Missing records the required keyword which is not given.
+func (kw *Keywords) Missing(name ID) {
+	kw.missing = append(kw.missing, name)
+}
+
+// Rest returns the hash of the keywords not taken yet for **opts.
+func (kw *Keywords) Rest() *RHash {
+	h := kw.hash
+	kw.hash = kw.rt.NewHash()
+	return h
+}
+
+// Check raises ArgumentError if the required keywords are missing or the
+// unknown keywords are left.
+func (kw *Keywords) Check() {
+	if len(kw.missing) > 0 {
+		names := make([]string, len(kw.missing))
+		for i, name := range kw.missing {
+			names[i] = kw.rt.Inspect(Symbol(name))
+		}
+		kw.raise("missing", names)
+	}
+	if kw.hash.Len() > 0 {
+		var names []string
+		kw.hash.each(func(k, v Value) {
+			names = append(names, kw.rt.Inspect(k))
+		})
+		kw.raise("unknown", names)
+	}
+}
+
+func (kw *Keywords) raise(what string, names []string) {
+	s := ""
+	if len(names) > 1 {
+		s = "s"
+	}
+	kw.rt.Raise(kw.rt.ArgumentError, "%s keyword%s: %s", what, s, strings.Join(names, ", "))
+}
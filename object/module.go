@@ -0,0 +1,379 @@
+package object
+
+import "sort"
+
+func (rt *Runtime) initModule() {
+	m := rt.Module
+	rt.DefineMethod(m, "name", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if c := self.(*RClass); c.Name != "" {
+			return rt.NewString(c.Name)
+		}
+		return Nil
+	})
+	rt.DefineMethod(m, "to_s", 0, moduleToS)
+	rt.DefineMethod(m, "inspect", 0, moduleToS)
+	rt.DefineMethod(m, "===", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.IsKindOf(args[0], self.(*RClass)))
+	})
+	rt.DefineMethod(m, "<", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		c, other := self.(*RClass), rt.toClass(args[0])
+		switch {
+		case c == other:
+			return False
+		case rt.isSubclass(c, other):
+			return True
+		case rt.isSubclass(other, c):
+			return False
+		}
+		return Nil
+	})
+	rt.DefineMethod(m, "<=", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		c, other := self.(*RClass), rt.toClass(args[0])
+		switch {
+		case rt.isSubclass(c, other):
+			return True
+		case rt.isSubclass(other, c):
+			return False
+		}
+		return Nil
+	})
+	rt.DefineMethod(m, "ancestors", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var list []Value
+		for _, c := range rt.Ancestors(self.(*RClass)) {
+			list = append(list, c)
+		}
+		return rt.NewArray(list)
+	})
+	rt.DefineMethod(m, "instance_methods", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		inherit := len(args) == 0 || Truthy(args[0])
+		return rt.methodList(self.(*RClass), inherit, func(m *Method) bool { return m.Visibility != Private })
+	})
+	rt.DefineMethod(m, "private_instance_methods", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		inherit := len(args) == 0 || Truthy(args[0])
+		return rt.methodList(self.(*RClass), inherit, func(m *Method) bool { return m.Visibility == Private })
+	})
+	rt.DefineMethod(m, "method_defined?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		m := rt.FindMethod(self.(*RClass), rt.toID(args[0]))
+		return Bool(m != nil && m.Visibility != Private)
+	})
+	rt.DefineMethod(m, "private_method_defined?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		m := rt.FindMethod(self.(*RClass), rt.toID(args[0]))
+		return Bool(m != nil && m.Visibility == Private)
+	})
+	rt.definePrivate(m, "attr_reader", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.defineAttrs(self.(*RClass), args, true, false)
+	})
+	rt.definePrivate(m, "attr_writer", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.defineAttrs(self.(*RClass), args, false, true)
+	})
+	rt.definePrivate(m, "attr_accessor", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.defineAttrs(self.(*RClass), args, true, true)
+	})
+	rt.definePrivate(m, "attr", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.defineAttrs(self.(*RClass), args, true, false)
+	})
+	for _, v := range []Visibility{Public, Private, Protected} {
+		v := v
+		// The visibility of the following definitions by the calls without
+		// arguments is changed by the evaluators.
+		rt.definePrivate(m, v.String(), -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			for _, arg := range args {
+				rt.SetVisibility(self.(*RClass), rt.toID(arg), v)
+			}
+			switch len(args) {
+			case 0:
+				return Nil
+			case 1:
+				return args[0]
+			}
+			return rt.NewArray(append([]Value(nil), args...))
+		})
+	}
+	rt.definePrivate(m, "alias_method", 2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.AliasMethod(self.(*RClass), rt.toID(args[0]), rt.toID(args[1]))
+		return Symbol(rt.toID(args[0]))
+	})
+	rt.definePrivate(m, "undef_method", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		for _, arg := range args {
+			rt.UndefMethod(self.(*RClass), rt.toID(arg))
+		}
+		return self
+	})
+	rt.definePrivate(m, "remove_method", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		for _, arg := range args {
+			rt.RemoveMethod(self.(*RClass), rt.toID(arg))
+		}
+		return self
+	})
+	rt.DefineMethod(m, "include", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.mixin(self.(*RClass), args, "append_features", "included")
+	})
+	rt.DefineMethod(m, "prepend", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.mixin(self.(*RClass), args, "prepend_features", "prepended")
+	})
+	rt.definePrivate(m, "append_features", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.Include(rt.toClass(args[0]), self.(*RClass))
+		return self
+	})
+	rt.definePrivate(m, "prepend_features", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.Prepend(rt.toClass(args[0]), self.(*RClass))
+		return self
+	})
+	rt.definePrivate(m, "extend_object", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.Extend(args[0], self.(*RClass))
+		return args[0]
+	})
+	for _, hook := range []string{"included", "prepended", "extended", "method_added"} {
+		rt.definePrivate(m, hook, 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return Nil
+		})
+	}
+	rt.DefineMethod(m, "include?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		c, other := self.(*RClass), rt.toClass(args[0])
+		return Bool(c != other && other.IsModule && rt.isSubclass(c, other))
+	})
+	rt.DefineMethod(m, "included_modules", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var list []Value
+		for _, c := range rt.Ancestors(self.(*RClass)) {
+			if c.IsModule && c != self {
+				list = append(list, c)
+			}
+		}
+		return rt.NewArray(list)
+	})
+	rt.DefineMethod(m, "singleton_class?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self.(*RClass).Singleton)
+	})
+	for _, v := range []Visibility{Public, Private} {
+		v := v
+		rt.DefineMethod(m, v.String()+"_class_method", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			sc := rt.SingletonClassOf(self)
+			for _, arg := range args {
+				rt.SetVisibility(sc, rt.toID(arg), v)
+			}
+			return Nil
+		})
+	}
+	rt.DefineMethod(m, "class_variable_get", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.CvarGet(self.(*RClass), rt.cvarName(args[0]))
+	})
+	rt.DefineMethod(m, "class_variable_set", 2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CvarSet(self.(*RClass), rt.cvarName(args[0]), args[1])
+		return args[1]
+	})
+	rt.DefineMethod(m, "class_variable_defined?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.CvarDefined(self.(*RClass), rt.cvarName(args[0])))
+	})
+	rt.DefineMethod(m, "class_variables", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		seen := map[string]bool{}
+		var list []Value
+		for k := cvarBase(self.(*RClass)); k != nil; k = k.Super {
+			var own []string
+			for name := range k.CVars {
+				if !seen[name] {
+					seen[name] = true
+					own = append(own, name)
+				}
+			}
+			sort.Strings(own)
+			for _, name := range own {
+				list = append(list, SymbolOf(name))
+			}
+		}
+		return rt.NewArray(list)
+	})
+	rt.DefineMethod(m, "const_get", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		name := rt.SymbolName(args[0])
+		v, ok := rt.ConstGet(self.(*RClass), name)
+		if !ok {
+			rt.ConstMissing(self.(*RClass), name)
+		}
+		return v
+	})
+	rt.DefineMethod(m, "const_set", 2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.ConstSet(self.(*RClass), rt.SymbolName(args[0]), args[1])
+		return args[1]
+	})
+	rt.DefineMethod(m, "const_defined?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		_, ok := rt.ConstGet(self.(*RClass), rt.SymbolName(args[0]))
+		return Bool(ok)
+	})
+	rt.DefineMethod(m, "constants", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var names []string
+		for name := range self.(*RClass).Consts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		list := make([]Value, len(names))
+		for i, name := range names {
+			list[i] = SymbolOf(name)
+		}
+		return rt.NewArray(list)
+	})
+
+	c := rt.Class
+	rt.DefineMethod(c, "new", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if self == Value(rt.Class) {
+			super := rt.Object
+			if len(args) > 0 {
+				super = rt.toClass(args[0])
+			}
+			c := rt.NewClass(super)
+			if blk != nil {
+				rt.ClassExec(c, blk)
+			}
+			return c
+		}
+		obj := rt.Allocate(self.(*RClass))
+		rt.Call(obj, Intern("initialize"), args, blk, true)
+		return obj
+	})
+	rt.definePrivate(c, "inherited", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Nil
+	})
+	rt.DefineMethod(c, "allocate", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.Allocate(self.(*RClass))
+	})
+	rt.DefineMethod(c, "superclass", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if s := self.(*RClass).Superclass(); s != nil {
+			return s
+		}
+		return Nil
+	})
+}
+
+func moduleToS(rt *Runtime, self Value, args []Value, blk Value) Value {
+	c := self.(*RClass)
+	if c.Singleton {
+		if _, ok := c.Attached.(*RClass); !ok {
+			return rt.NewString("#<Class:" + rt.Inspect(c.Attached) + ">")
+		}
+	}
+	return rt.NewString(c.Inspect())
+}
+
+// mixin includes or prepends the modules by the feature methods calling the
+// hooks, in the reverse order so that the first module comes first in the
+// ancestors.
+func (rt *Runtime) mixin(c *RClass, args []Value, feature, hook string) Value {
+	mods := append([]Value(nil), args...)
+	for _, m := range mods {
+		if k, ok := m.(*RClass); !ok || !k.IsModule {
+			rt.Raise(rt.TypeError, "wrong argument type %s (expected Module)", rt.ClassOf(m).RealClass().Name)
+		}
+	}
+	for i := len(mods) - 1; i >= 0; i-- {
+		rt.Send(mods[i], feature, c)
+		rt.Send(mods[i], hook, c)
+	}
+	return c
+}
+
+// cvarName returns the name of class variable given by a symbol or a
+// string, raising NameError for invalid names.
+func (rt *Runtime) cvarName(v Value) string {
+	name := rt.SymbolName(v)
+	if len(name) < 3 || name[0] != '@' || name[1] != '@' {
+		rt.Raise(rt.NameError, "'%s' is not allowed as a class variable name", name)
+	}
+	return name
+}
+
+// isSubclass returns whether c is other or its descendant, including the
+// classes and modules which include other.
+func (rt *Runtime) isSubclass(c, other *RClass) bool {
+	for ; c != nil; c = c.Super {
+		if c == other || c.IClass && c.Module == other {
+			return true
+		}
+	}
+	return false
+}
+
+// Allocate returns a new instance of the class, which is a string, an
+// array, a hash or a range for the subclasses of String, Array, Hash or
+// Range.
+func (rt *Runtime) Allocate(c *RClass) Value {
+	switch {
+	case rt.isSubclass(c, rt.String):
+		s := rt.NewString("")
+		s.class = c
+		return s
+	case rt.isSubclass(c, rt.Array):
+		a := rt.NewArray(nil)
+		a.class = c
+		return a
+	case rt.isSubclass(c, rt.Hash):
+		h := rt.NewHash()
+		h.class = c
+		return h
+	case rt.isSubclass(c, rt.Range):
+		r := &RRange{Begin: Nil, End: Nil}
+		r.class = c
+		return r
+	case rt.isSubclass(c, rt.Module):
+		rt.NotImplemented("allocation of " + c.Name)
+	case c.Singleton:
+		rt.Raise(rt.TypeError, "can't create instance of singleton class")
+	}
+	for _, k := range []*RClass{rt.Integer, rt.Float, rt.Symbol, rt.NilClass, rt.TrueClass, rt.FalseClass} {
+		if rt.isSubclass(c, k) {
+			rt.Raise(rt.NoMethodError, "undefined method `new' for %s:Class", c.Name)
+		}
+	}
+	return rt.NewObject(c)
+}
+
+// defineAttrs defines the readers and the writers of the instance variables.
+func (rt *Runtime) defineAttrs(c *RClass, names []Value, reader, writer bool) Value {
+	var defined []Value
+	for _, n := range names {
+		name := rt.SymbolName(n)
+		ivar := Intern("@" + name)
+		if reader {
+			rt.DefineMethod(c, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+				return rt.Ivar(self, ivar)
+			})
+			defined = append(defined, SymbolOf(name))
+		}
+		if writer {
+			rt.DefineMethod(c, name+"=", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+				rt.SetIvar(self, ivar, args[0])
+				return args[0]
+			})
+			defined = append(defined, SymbolOf(name+"="))
+		}
+	}
+	return rt.NewArray(defined)
+}
+
+// methodList returns the names of the methods which match the filter.
+func (rt *Runtime) methodList(c *RClass, inherit bool, filter func(*Method) bool) Value {
+	seen := map[ID]bool{}
+	var names []string
+	if !inherit {
+		c = c.origin()
+	}
+	for k := c; k != nil; k = k.Super {
+		var own []string
+		for name, m := range k.Methods {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if m != nil && filter(m) {
+				own = append(own, name.String())
+			}
+		}
+		sort.Strings(own)
+		names = append(names, own...)
+		if !inherit {
+			break
+		}
+	}
+	list := make([]Value, len(names))
+	for i, name := range names {
+		list[i] = SymbolOf(name)
+	}
+	return rt.NewArray(list)
+}
@@ -0,0 +1,112 @@
+package object
+
+import "math"
+
+// Fixnum is an Integer which fits in int64.
+type Fixnum int64
+
+// Float is a Float.
+type Float float64
+
+func (Fixnum) value() {}
+func (Float) value()  {}
+
+// toFloat returns the numeric value as float64, or raises TypeError.
+func (rt *Runtime) toFloat(self, v Value) float64 {
+	switch v := v.(type) {
+	case Fixnum:
+		return float64(v)
+	case *Bignum:
+		return intToFloat(v)
+	case Float:
+		return float64(v)
+	}
+	rt.coerceFailed(self, v)
+	return 0
+}
+
+func (rt *Runtime) coerceFailed(self, v Value) {
+	from := rt.ClassOf(v).RealClass().Name
+	switch v.(type) {
+	case nilValue, Bool:
+		from = rt.Inspect(v)
+	}
+	rt.Raise(rt.TypeError, "%s can't be coerced into %s", from, rt.ClassOf(self).RealClass().Name)
+}
+
+func (rt *Runtime) compareFailed(self, v Value) {
+	other := rt.ClassOf(v).RealClass().Name
+	switch v.(type) {
+	case nilValue, Bool, Fixnum, *Bignum, Float:
+		other = rt.Inspect(v)
+	}
+	rt.Raise(rt.ArgumentError, "comparison of %s with %s failed", rt.ClassOf(self).RealClass().Name, other)
+}
+
+func (rt *Runtime) initNumeric() {
+	rt.initInteger()
+	rt.initFloat()
+
+	n := rt.Numeric
+	rt.DefineMethod(n, "integer?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(isInteger(self))
+	})
+	rt.DefineMethod(n, "positive?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.toFloat(self, self) > 0)
+	})
+	rt.DefineMethod(n, "negative?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.toFloat(self, self) < 0)
+	})
+}
+
+// compareFloat returns the result of <=>, which is nil for NaN.
+func compareFloat(a, b float64) Value {
+	switch {
+	case a == b:
+		return Fixnum(0)
+	case a < b:
+		return Fixnum(-1)
+	case a > b:
+		return Fixnum(1)
+	}
+	return Nil
+}
+
+// defineCompare defines the comparison operators by <=>.
+func (rt *Runtime) defineCompare(c *RClass) {
+	ops := map[string]func(int64) bool{
+		"<":  func(n int64) bool { return n < 0 },
+		"<=": func(n int64) bool { return n <= 0 },
+		">":  func(n int64) bool { return n > 0 },
+		">=": func(n int64) bool { return n >= 0 },
+	}
+	for name, op := range ops {
+		op := op
+		rt.DefineMethod(c, name, 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			n, ok := rt.Send(self, "<=>", args[0]).(Fixnum)
+			if !ok {
+				if isNumber(self) && isNumber(args[0]) {
+					return False // NaN
+				}
+				rt.compareFailed(self, args[0])
+			}
+			return Bool(op(int64(n)))
+		})
+	}
+}
+
+// toInt returns the value as a Fixnum, or raises TypeError. It raises
+// RangeError for the Integer beyond Fixnum.
+func (rt *Runtime) toInt(v Value) Fixnum {
+	if f, ok := v.(Float); ok {
+		v = rt.floatToInt(math.Trunc(float64(f)))
+	}
+	switch v := v.(type) {
+	case Fixnum:
+		return v
+	case *Bignum:
+		rt.Raise(rt.RangeError, "bignum too big to convert into `long'")
+	}
+	rt.TypeMismatch(v, "Integer")
+	return 0
+}
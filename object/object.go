@@ -0,0 +1,583 @@
+/*
+Package object implements the Ruby objects and the classes shared by the
+evaluators of ringo.
+
+The values are represented by the Go types as below:
+
+	nil         Nil
+	true/false  Bool
+	Integer     Fixnum, or *Bignum beyond int64
+	Float       Float
+	Symbol      Symbol
+	String      *RString
+	Array       *RArray
+	Hash        *RHash
+	Range       *RRange
+	Proc        *RProc
+	Encoding    *REncoding
+	others      *RObject, or *RClass for classes and modules
+
+A Runtime holds the classes and the global state of a Ruby program. The
+methods written in Ruby are run by the Invoker of the runtime, which is the
+evaluator such as the interp package.
+
+Ruby exceptions are raised as Go panics of *Error, which the evaluators
+rescue and return as errors.
+*/
+package object
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Value is a Ruby object.
+type Value interface {
+	value()
+}
+
+type nilValue struct{}
+
+// Nil is the nil object.
+var Nil Value = nilValue{}
+
+// Bool is true or false.
+type Bool bool
+
+// True and False are the boolean objects.
+const (
+	True  Bool = true
+	False Bool = false
+)
+
+func (nilValue) value() {}
+func (Bool) value()     {}
+
+// Truthy returns whether v is true in conditions, that is neither nil nor
+// false.
+func Truthy(v Value) bool {
+	return v != Nil && v != False
+}
+
+// RObject is an object allocated in the heap. It is embedded in the other
+// heap objects such as strings and classes.
+type RObject struct {
+	class  *RClass // nil for the default class of the Go type
+	ivars  map[ID]Value
+	id     uint64
+	frozen bool
+}
+
+func (o *RObject) value()           {}
+func (o *RObject) object() *RObject { return o }
+
+// heapObject is implemented by the types embedding RObject.
+type heapObject interface {
+	Value
+	object() *RObject
+}
+
+// Ivar returns the instance variable of the name, or nil if it is not set.
+func (o *RObject) Ivar(name ID) Value {
+	if v, ok := o.ivars[name]; ok {
+		return v
+	}
+	return Nil
+}
+
+// SetIvar sets the instance variable.
+func (o *RObject) SetIvar(name ID, v Value) {
+	if o.ivars == nil {
+		o.ivars = map[ID]Value{}
+	}
+	o.ivars[name] = v
+}
+
+// Visibility is the visibility of methods.
+type Visibility int
+
+// Visibilities:
+const (
+	Public Visibility = iota
+	Private
+	Protected
+)
+
+func (v Visibility) String() string {
+	switch v {
+	case Private:
+		return "private"
+	case Protected:
+		return "protected"
+	}
+	return "public"
+}
+
+// BuiltinFunc is the function of a method implemented in Go. The block is
+// nil if not given. The args may be reused by the caller after the
+// function returns, so that the function must copy them to keep.
+type BuiltinFunc func(rt *Runtime, self Value, args []Value, blk Value) Value
+
+// Method is a method of classes and modules. Either Fn is set for the
+// builtin method, or Body is set for the method written in Ruby, which is
+// given by the evaluator defining the method.
+type Method struct {
+	Name       ID
+	Owner      *RClass
+	Visibility Visibility
+	Arity      int // number of the required arguments, or -n-1 for n required and optional arguments
+	Fn         BuiltinFunc
+	Body       interface{}
+}
+
+// Invoker runs the methods and the blocks written in Ruby. ClassExec runs
+// the block as the body of the class. BlockGiven reports whether the block
+// is given to the method running.
+type Invoker interface {
+	Invoke(m *Method, self Value, args []Value, blk Value) Value
+	CallBlock(p *RProc, args []Value, blk Value) Value
+	ClassExec(p *RProc, c *RClass) Value
+	BlockGiven() bool
+}
+
+// Runtime holds the classes and the global state.
+type Runtime struct {
+	BasicObject *RClass
+	Object      *RClass
+	Module      *RClass
+	Class       *RClass
+	Kernel      *RClass
+	Comparable  *RClass
+	Enumerable  *RClass
+	NilClass    *RClass
+	TrueClass   *RClass
+	FalseClass  *RClass
+	Numeric     *RClass
+	Integer     *RClass
+	Float       *RClass
+	String      *RClass
+	Symbol      *RClass
+	Array       *RClass
+	Hash        *RClass
+	Range       *RClass
+	Proc        *RClass
+	Encoding    *RClass
+
+	Exception           *RClass
+	ScriptError         *RClass
+	NotImplementedError *RClass
+	StandardError       *RClass
+	ArgumentError       *RClass
+	NameError           *RClass
+	NoMethodError       *RClass
+	RuntimeError        *RClass
+	FrozenError         *RClass
+	TypeError           *RClass
+	ZeroDivisionError   *RClass
+	RangeError          *RClass
+	FloatDomainError    *RClass
+	IndexError          *RClass
+	KeyError            *RClass
+	StopIteration       *RClass
+	LocalJumpError      *RClass
+	SystemStackError    *RClass
+
+	EncodingError            *RClass
+	CompatibilityError       *RClass // Encoding::CompatibilityError
+	UndefinedConversionError *RClass // Encoding::UndefinedConversionError
+	InvalidByteSequenceError *RClass // Encoding::InvalidByteSequenceError
+
+	Main    *RObject // self of the top level
+	Globals map[string]Value
+	Stdout  io.Writer
+	Invoker Invoker
+
+	encodings map[*Encoding]*REncoding
+	recursion map[recursionKey]bool
+	lastID    uint64
+	serial    uint64 // method serial
+}
+
+// New returns a runtime with the builtin classes, writing to the standard
+// output.
+func New() *Runtime {
+	rt := &Runtime{Globals: map[string]Value{}, Stdout: os.Stdout}
+	rt.initClasses()
+	rt.initKernel()
+	rt.initModule()
+	rt.initComparable()
+	rt.initNumeric()
+	rt.initString()
+	rt.initSymbol()
+	rt.initEnumerable()
+	rt.initArray()
+	rt.initHash()
+	rt.initRange()
+	rt.initProc()
+	rt.initException()
+	rt.initEncoding()
+	rt.Main = rt.NewObject(rt.Object)
+	main := rt.SingletonClassOf(rt.Main)
+	rt.DefineMethod(main, "to_s", 0, mainToS)
+	rt.DefineMethod(main, "inspect", 0, mainToS)
+	return rt
+}
+
+func mainToS(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return rt.NewString("main")
+}
+
+// NewObject returns a new object of the class.
+func (rt *Runtime) NewObject(c *RClass) *RObject {
+	o := &RObject{class: c}
+	rt.track(o)
+	return o
+}
+
+// track assigns the object id.
+func (rt *Runtime) track(o *RObject) {
+	rt.lastID++
+	o.id = rt.lastID
+}
+
+// ClassOf returns the class of the value to look up the methods, which is
+// the singleton class if the value has it. The singleton classes of classes
+// are always created so that the class methods are inherited.
+func (rt *Runtime) ClassOf(v Value) *RClass {
+	if o, ok := v.(heapObject); ok {
+		if c := o.object().class; c != nil {
+			return c
+		}
+	}
+	switch v.(type) {
+	case nilValue:
+		return rt.NilClass
+	case Bool:
+		if v == True {
+			return rt.TrueClass
+		}
+		return rt.FalseClass
+	case Fixnum, *Bignum:
+		return rt.Integer
+	case Float:
+		return rt.Float
+	case Symbol:
+		return rt.Symbol
+	case *RString:
+		return rt.String
+	case *RArray:
+		return rt.Array
+	case *RHash:
+		return rt.Hash
+	case *RRange:
+		return rt.Range
+	case *RProc:
+		return rt.Proc
+	case *RClass:
+		return rt.SingletonClassOf(v)
+	}
+	panic(fmt.Sprintf("object: unknown value %T", v))
+}
+
+// ObjectID returns the object id of the value.
+func (rt *Runtime) ObjectID(v Value) Fixnum {
+	switch v := v.(type) {
+	case heapObject:
+		o := v.object()
+		if o.id == 0 {
+			rt.track(o)
+		}
+		return Fixnum(o.id * 8)
+	case Fixnum:
+		return v*2 + 1
+	case nilValue:
+		return 8
+	case Bool:
+		if v {
+			return 20
+		}
+		return 0
+	}
+	return Fixnum(rt.hashOf(v))
+}
+
+// recursionKey is the key of the recursion guard, which is the name of the
+// method and the receiver, paired with the argument for the methods such as
+// ==.
+type recursionKey struct {
+	name      string
+	obj, pair Value
+}
+
+// recursive calls f for the method of the object, where recur is true if f
+// is already running for the same object in the call stack, such as inspect
+// of the array containing itself.
+func (rt *Runtime) recursive(name string, obj, pair Value, f func(recur bool) Value) Value {
+	key := recursionKey{name, obj, pair}
+	if rt.recursion[key] {
+		return f(true)
+	}
+	if rt.recursion == nil {
+		rt.recursion = map[recursionKey]bool{}
+	}
+	rt.recursion[key] = true
+	defer delete(rt.recursion, key)
+	return f(false)
+}
+
+// hashOf returns the hash of the immediate values.
+func (rt *Runtime) hashOf(v Value) uint64 {
+	return hashBytes([]byte(fmt.Sprintf("%T:%v", v, v)))
+}
+
+// hashBytes returns the FNV-1a hash of b.
+func hashBytes(b []byte) uint64 {
+	var h uint64 = 14695981039346656037
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return h >> 4
+}
+
+// Frozen returns whether the value is frozen. The immediate values are
+// always frozen.
+func (rt *Runtime) Frozen(v Value) bool {
+	if o, ok := v.(heapObject); ok {
+		return o.object().frozen
+	}
+	return true
+}
+
+// Freeze freezes the value.
+func (rt *Runtime) Freeze(v Value) {
+	if o, ok := v.(heapObject); ok {
+		o.object().frozen = true
+	}
+}
+
+// CheckFrozen raises FrozenError if the value is frozen.
+func (rt *Runtime) CheckFrozen(v Value) {
+	if rt.Frozen(v) {
+		rt.Raise(rt.FrozenError, "can't modify frozen %s: %s", rt.ClassOf(v).RealClass().Name, rt.Inspect(v))
+	}
+}
+
+// Ivar returns the instance variable of the value.
+func (rt *Runtime) Ivar(v Value, name ID) Value {
+	if o, ok := v.(heapObject); ok {
+		return o.object().Ivar(name)
+	}
+	return Nil
+}
+
+// IvarDefined returns whether the instance variable of the value is set.
+func (rt *Runtime) IvarDefined(v Value, name ID) bool {
+	if o, ok := v.(heapObject); ok {
+		_, ok := o.object().ivars[name]
+		return ok
+	}
+	return false
+}
+
+// SetIvar sets the instance variable of the value.
+func (rt *Runtime) SetIvar(v Value, name ID, x Value) {
+	o, ok := v.(heapObject)
+	if !ok {
+		rt.Raise(rt.FrozenError, "can't modify frozen %s: %s", rt.ClassOf(v).RealClass().Name, rt.Inspect(v))
+	}
+	rt.CheckFrozen(v)
+	o.object().SetIvar(name, x)
+}
+
+// FindMethod returns the method of the class or its ancestors, or nil.
+func (rt *Runtime) FindMethod(c *RClass, name ID) *Method {
+	for ; c != nil; c = c.Super {
+		if m, ok := c.Methods[name]; ok {
+			if m == nil {
+				return nil // undefined
+			}
+			return m
+		}
+	}
+	return nil
+}
+
+// RespondTo returns whether the value has the public method.
+func (rt *Runtime) RespondTo(v Value, name string) bool {
+	m := rt.FindMethod(rt.ClassOf(v), Intern(name))
+	return m != nil && m.Visibility == Public
+}
+
+// Send calls the method of the value regardless of the visibility.
+func (rt *Runtime) Send(self Value, name string, args ...Value) Value {
+	return rt.Call(self, Intern(name), args, nil, true)
+}
+
+// Yield calls the block with the arguments, or raises LocalJumpError if the
+// block is not given.
+func (rt *Runtime) Yield(blk Value, args ...Value) Value {
+	if blk == nil {
+		rt.Raise(rt.LocalJumpError, "no block given (yield)")
+	}
+	if p, ok := blk.(*RProc); ok {
+		return rt.CallProc(p, args, nil)
+	}
+	return rt.Send(blk, "call", args...)
+}
+
+// Call calls the method of the value with the arguments and the block. The
+// private methods are called only if fcall is true, which means that the
+// receiver is omitted.
+func (rt *Runtime) Call(self Value, name ID, args []Value, blk Value, fcall bool) Value {
+	m := rt.FindMethod(rt.ClassOf(self), name)
+	if m == nil {
+		rt.RaiseNoMethod(self, name, false)
+	}
+	if !fcall {
+		rt.CheckVisibility(m, self, name, nil)
+	}
+	return rt.CallMethod(m, self, args, blk)
+}
+
+// CallFrom calls the method of the value with the explicit receiver in the
+// method of caller. The protected methods are called if caller is a kind of
+// the owner of the method.
+func (rt *Runtime) CallFrom(caller, self Value, name ID, args []Value, blk Value) Value {
+	m := rt.FindMethod(rt.ClassOf(self), name)
+	if m == nil {
+		rt.RaiseNoMethod(self, name, false)
+	}
+	rt.CheckVisibility(m, self, name, caller)
+	return rt.CallMethod(m, self, args, blk)
+}
+
+// CheckVisibility raises NoMethodError if the method cannot be called with
+// the explicit receiver from caller, which is nil for the calls by the
+// runtime. The calls with self as the receiver are not checked, as private
+// methods can be called by self.a and self.a = 1.
+func (rt *Runtime) CheckVisibility(m *Method, self Value, name ID, caller Value) {
+	switch m.Visibility {
+	case Private:
+	case Protected:
+		if caller != nil && rt.IsKindOf(caller, m.Owner) {
+			return
+		}
+	default:
+		return
+	}
+	rt.Raise(rt.NoMethodError, "%s method `%s' called for %s", m.Visibility, name, rt.describe(self))
+}
+
+// CallMethod runs the method found for self.
+func (rt *Runtime) CallMethod(m *Method, self Value, args []Value, blk Value) Value {
+	if m.Fn != nil {
+		rt.CheckArity(len(args), m.Arity)
+		return m.Fn(rt, self, args, blk)
+	}
+	return rt.Invoker.Invoke(m, self, args, blk)
+}
+
+// FindSuperMethod returns the method overridden by the method m running for
+// self, or nil. It is looked up from the next of the class holding m in the
+// ancestors of self, so that super in modules follows the ancestors.
+func (rt *Runtime) FindSuperMethod(self Value, m *Method) *Method {
+	k := rt.ClassOf(self)
+	for k != nil && k.Methods[m.Name] != m {
+		k = k.Super
+	}
+	if k == nil {
+		return nil
+	}
+	return rt.FindMethod(k.Super, m.Name)
+}
+
+// CallSuper calls the method overridden by the method m running for self.
+func (rt *Runtime) CallSuper(self Value, m *Method, args []Value, blk Value) Value {
+	sm := rt.FindSuperMethod(self, m)
+	if sm == nil {
+		rt.Raise(rt.NoMethodError, "super: no superclass method `%s' for %s", m.Name, rt.describe(self))
+	}
+	return rt.CallMethod(sm, self, args, blk)
+}
+
+// CheckArity raises ArgumentError if the number of arguments does not
+// match the arity.
+func (rt *Runtime) CheckArity(n, arity int) {
+	switch {
+	case arity >= 0 && n != arity:
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected %d)", n, arity)
+	case arity < 0 && n < -arity-1:
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected %d+)", n, -arity-1)
+	}
+}
+
+// RaiseNoMethod raises NoMethodError, or NameError for vcall which may be
+// a local variable.
+func (rt *Runtime) RaiseNoMethod(self Value, name ID, vcall bool) {
+	if vcall {
+		rt.Raise(rt.NameError, "undefined local variable or method `%s' for %s", name, rt.describe(self))
+	}
+	rt.Raise(rt.NoMethodError, "undefined method `%s' for %s", name, rt.describe(self))
+}
+
+// describe returns the receiver in the messages of NoMethodError.
+func (rt *Runtime) describe(v Value) string {
+	if v == Value(rt.Main) {
+		return "main:Object"
+	}
+	if _, ok := v.(*RObject); ok {
+		return rt.anyToS(v)
+	}
+	return rt.Inspect(v) + ":" + rt.ClassOf(v).RealClass().Name
+}
+
+// Inspect returns the result of inspect as a Go string.
+func (rt *Runtime) Inspect(v Value) string {
+	return rt.stringOf(rt.Send(v, "inspect"))
+}
+
+// ToS returns the result of to_s as a Go string.
+func (rt *Runtime) ToS(v Value) string {
+	if s, ok := v.(*RString); ok {
+		return string(s.B)
+	}
+	s := rt.Send(v, "to_s")
+	if s, ok := s.(*RString); ok {
+		return string(s.B)
+	}
+	return rt.anyToS(v)
+}
+
+func (rt *Runtime) stringOf(v Value) string {
+	if s, ok := v.(*RString); ok {
+		return string(s.B)
+	}
+	return rt.anyToS(v)
+}
+
+// anyToS returns the default string of the object such as #<Foo:0x0010>.
+func (rt *Runtime) anyToS(v Value) string {
+	return fmt.Sprintf("#<%s:0x%016x>", rt.ClassOf(v).RealClass().Name, int64(rt.ObjectID(v)))
+}
+
+// Identical returns whether a and b are the same object. The Floats are
+// compared by the bits as flonums of MRI, so that NaN is identical to
+// itself while 0.0 is not to -0.0.
+func Identical(a, b Value) bool {
+	if x, ok := a.(Float); ok {
+		y, ok := b.(Float)
+		return ok && math.Float64bits(float64(x)) == math.Float64bits(float64(y))
+	}
+	return a == b
+}
+
+// Equal returns whether a == b.
+func (rt *Runtime) Equal(a, b Value) bool {
+	if Identical(a, b) {
+		return true
+	}
+	return Truthy(rt.Send(a, "==", b))
+}
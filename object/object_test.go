@@ -0,0 +1,417 @@
+package object
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestClassOf(t *testing.T) {
+	rt := New()
+	rules := map[Value]*RClass{
+		Nil:                        rt.NilClass,
+		True:                       rt.TrueClass,
+		Fixnum(1):                  rt.Integer,
+		Float(1):                   rt.Float,
+		SymbolOf("a"):              rt.Symbol,
+		rt.NewString("a"):          rt.String,
+		rt.NewArray(nil):           rt.Array,
+		rt.Object:                  rt.Class,
+		rt.Kernel:                  rt.Module,
+		rt.NewObject(rt.Exception): rt.Exception,
+	}
+	for v, want := range rules {
+		if got := rt.ClassOf(v).RealClass(); got != want {
+			t.Errorf("%#v: class=%v (want=%v)", v, got.Name, want.Name)
+		}
+	}
+}
+
+func TestAncestors(t *testing.T) {
+	rt := New()
+	m := func(name string, includes ...*RClass) *RClass {
+		c := rt.DefineModule(name, nil)
+		for _, k := range includes {
+			rt.Include(c, k)
+		}
+		return c
+	}
+	a := m("A")
+	b, c := m("B", a), m("C", a)
+	d := rt.DefineClass("D", nil, nil)
+	rt.Include(d, b)
+	rt.Include(d, c)
+	e := rt.DefineClass("E", d, nil)
+	rt.Prepend(e, m("P", c))
+	rt.Include(e, m("F", b))
+
+	rules := map[*RClass]string{
+		rt.Object:              "Object Kernel BasicObject",
+		rt.Integer:             "Integer Numeric Comparable Object Kernel BasicObject",
+		rt.Hash:                "Hash Enumerable Object Kernel BasicObject",
+		d:                      "D C B A Object Kernel BasicObject",
+		e:                      "P C A E F D C B A Object Kernel BasicObject",
+		rt.SingletonClassOf(e): "#<Class:E> #<Class:D> #<Class:Object> #<Class:BasicObject> Class Module Object Kernel BasicObject",
+	}
+	for c, want := range rules {
+		var names []string
+		for _, k := range rt.Ancestors(c) {
+			names = append(names, k.Inspect())
+		}
+		if got := strings.Join(names, " "); got != want {
+			t.Errorf("%s: ancestors=%v (want=%v)", c.Inspect(), got, want)
+		}
+	}
+}
+
+func TestCvar(t *testing.T) {
+	rt := New()
+	a := rt.DefineClass("A", nil, nil)
+	b := rt.DefineClass("B", a, nil)
+	rt.CvarSet(a, "@@x", Fixnum(1))
+	rt.CvarSet(b, "@@x", Fixnum(2))
+	rt.CvarSet(rt.SingletonClassOf(b), "@@y", Fixnum(3))
+	if got := rt.CvarGet(a, "@@x"); got != Fixnum(2) {
+		t.Errorf("A @@x=%v (want=2)", got)
+	}
+	if got := rt.CvarGet(b, "@@y"); got != Fixnum(3) {
+		t.Errorf("B @@y=%v (want=3)", got)
+	}
+	if rt.CvarDefined(a, "@@y") {
+		t.Errorf("A @@y defined=true (want=false)")
+	}
+}
+
+func TestCall(t *testing.T) {
+	rt := New()
+	if got := rt.Send(Fixnum(1), "+", Fixnum(2)); got != Fixnum(3) {
+		t.Errorf("1 + 2=%v (want=3)", got)
+	}
+	defer func() {
+		err, ok := recover().(*Error)
+		want := "undefined method `foo' for 1:Integer (NoMethodError)"
+		if !ok || err.Error() != want {
+			t.Errorf("err=%v (want=%v)", err, want)
+		}
+	}()
+	rt.Call(Fixnum(1), Intern("foo"), nil, nil, false)
+}
+
+func TestInteger(t *testing.T) {
+	rt := New()
+	max := Fixnum(math.MaxInt64)
+	rules := map[string]struct {
+		a    Value
+		op   string
+		b    Value
+		want string
+	}{
+		"max+1":     {max, "+", Fixnum(1), "9223372036854775808"},
+		"min-1":     {Fixnum(math.MinInt64), "-", Fixnum(1), "-9223372036854775809"},
+		"max*max":   {max, "*", max, "85070591730234615847396907784232501249"},
+		"min/-1":    {Fixnum(math.MinInt64), "/", Fixnum(-1), "9223372036854775808"},
+		"2**64":     {Fixnum(2), "**", Fixnum(64), "18446744073709551616"},
+		"1<<63":     {Fixnum(1), "<<", Fixnum(63), "9223372036854775808"},
+		"demote":    {rt.Send(max, "+", Fixnum(1)), "-", Fixnum(1), "9223372036854775807"},
+		"big%-3":    {rt.Send(max, "*", max), "%", Fixnum(-3), "-2"},
+		"big<=>flt": {rt.Send(max, "+", Fixnum(1)), "<=>", Float(1 << 63), "0"},
+	}
+	for name, r := range rules {
+		got := rt.Send(r.a, r.op, r.b)
+		s := rt.Inspect(got)
+		if s != r.want {
+			t.Errorf("%s: %v (want=%v)", name, s, r.want)
+		}
+		if n, ok := got.(*Bignum); ok && isFixnum(NewInteger(n.Int)) {
+			t.Errorf("%s: %v is not normalized to Fixnum", name, s)
+		}
+	}
+}
+
+func TestParseInt(t *testing.T) {
+	rules := map[string]Value{
+		"0b101":   Fixnum(5),
+		" -0x1F ": Fixnum(-31),
+		"0_17":    Fixnum(15),
+		"1_000":   Fixnum(1000),
+		"0b1_":    nil,
+		"1 2":     nil,
+		"":        nil,
+	}
+	for s, want := range rules {
+		got, ok := ParseInt(s, 0)
+		if !ok {
+			got = nil
+		}
+		if got != want {
+			t.Errorf("%q: value=%v (want=%v)", s, got, want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	rt := New()
+	rules := []struct {
+		x       float64
+		ndigits int
+		mode    roundMode
+		want    string
+	}{
+		{2.5, 0, roundHalfUp, "3"},
+		{2.5, 0, roundHalfEven, "2"},
+		{2.5, 0, roundHalfDown, "2"},
+		{-2.5, 0, roundHalfUp, "-3"},
+		{-2.5, 0, roundHalfEven, "-2"},
+		{3.5, 0, roundHalfEven, "4"},
+		{2.675, 2, roundHalfUp, "2.68"},
+		{0.125, 2, roundHalfDown, "0.12"},
+		{0.125, 2, roundHalfEven, "0.12"},
+		{0.375, 2, roundHalfEven, "0.38"},
+		{1.0 / 65536, 15, roundHalfEven, "1.5258789062e-05"},
+		{1.0 / 65536, 15, roundHalfUp, "1.5258789063e-05"},
+		{25, -1, roundHalfEven, "20"},
+		{35, -1, roundHalfEven, "40"},
+		{-25, -1, roundHalfDown, "-20"},
+	}
+	for _, r := range rules {
+		if got := rt.Inspect(rt.floatRound(r.x, r.ndigits, r.mode)); got != r.want {
+			t.Errorf("%v.round(%d, %d)=%v (want=%v)", r.x, r.ndigits, r.mode, got, r.want)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	rt := New()
+	rules := map[string]Value{
+		`nil`:                    Nil,
+		`1.0`:                    Float(1),
+		`0.1`:                    Float(0.1),
+		`-Infinity`:              Float(-1.0 / zero),
+		`1.0e+20`:                Float(1e20),
+		`1.0e+16`:                Float(1e16),
+		`1.0e-05`:                Float(0.00001),
+		`0.0001`:                 Float(0.0001),
+		`-0.0`:                   Float(math.Copysign(0, -1)),
+		`1.2345678901234568e+17`: Float(123456789012345678),
+		`1000000000000000.0`:     Float(1e15),
+		`"a\n\#{b} \e"`:          rt.NewString("a\n#{b} \x1b"),
+		`"\xFF"`:                 rt.NewString("\xff"),
+		`"\xC3\xA9"`:             &RString{B: []byte("é"), Enc: Binary},
+		`#<Encoding:US-ASCII>`:   rt.EncodingObject(USASCII),
+		`:a?`:                    SymbolOf("a?"),
+		`:@@a`:                   SymbolOf("@@a"),
+		`:[]=`:                   SymbolOf("[]="),
+		`:"a b"`:                 SymbolOf("a b"),
+		`:"@a?"`:                 SymbolOf("@a?"),
+		`[1, "a", :b]`:           rt.NewArray([]Value{Fixnum(1), rt.NewString("a"), SymbolOf("b")}),
+		`Comparable`:             rt.Comparable,
+	}
+	for want, v := range rules {
+		if got := rt.Inspect(v); got != want {
+			t.Errorf("%#v: inspect=%v (want=%v)", v, got, want)
+		}
+	}
+}
+
+var zero float64
+
+func TestSprintf(t *testing.T) {
+	rt := New()
+	rules := map[string][]Value{
+		"%d|%5d|%-5d|%05d|%+d|% d": {Fixnum(1), Fixnum(-2), Fixnum(3), Fixnum(-4), Fixnum(5), Fixnum(6)},
+		"%.3d|%x|%#X|%#o|%b|%#b":   {Fixnum(7), Fixnum(255), Fixnum(255), Fixnum(8), Fixnum(5), Fixnum(5)},
+		"%x|%o|%b|%+x":             {Fixnum(-255), Fixnum(-8), Fixnum(-5), Fixnum(-255)},
+		"%f|%.2f|%8.3e|%g|%g|%G":   {Float(1.5), Fixnum(2), Float(1234.5), Float(1e-5), Float(123456789), Float(1e20)},
+		"%s|%5s|%-5s|%.2s|%p|%c%c": {SymbolOf("a"), rt.NewString("é"), Nil, rt.NewString("abc"), rt.NewString("x"), Fixnum(0x3042), rt.NewString("yz")},
+		"%*d|%-*d|%%|%a":           {Fixnum(3), Fixnum(1), Fixnum(-3), Fixnum(2), Float(1)},
+		"%2$s %1$s %2$s":           {rt.NewString("a"), rt.NewString("b")},
+	}
+	wants := map[string]string{
+		"%d|%5d|%-5d|%05d|%+d|% d": "1|   -2|3    |-0004|+5| 6",
+		"%.3d|%x|%#X|%#o|%b|%#b":   "007|ff|0XFF|010|101|0b101",
+		"%x|%o|%b|%+x":             "..f01|..70|..1011|-ff",
+		"%f|%.2f|%8.3e|%g|%g|%G":   "1.500000|2.00|1.234e+03|1e-05|1.23457e+08|1E+20",
+		"%s|%5s|%-5s|%.2s|%p|%c%c": `a|    é|     |ab|"x"|あy`,
+		"%*d|%-*d|%%|%a":           "  1|2  |%|0x1p+0",
+		"%2$s %1$s %2$s":           "b a b",
+	}
+	for format, args := range rules {
+		if got := string(rt.sprintf(rt.NewString(format), args).B); got != wants[format] {
+			t.Errorf("%q: sprintf=%q (want=%q)", format, got, wants[format])
+		}
+	}
+}
+
+func TestCaseMap(t *testing.T) {
+	rules := map[string][]string{
+		"Straße ǆ ﬁ": {"STRASSE Ǆ FI", "straße ǆ ﬁ", "Straße ǆ ﬁ", "sTRASSE Ǆ FI"},
+		"İstanbul":   {"İSTANBUL", "i̇stanbul", "İstanbul", "i̇STANBUL"},
+		"ǆemal":      {"ǄEMAL", "ǆemal", "ǅemal", "ǄEMAL"},
+		"aÉ\xff":     {"AÉ\xff", "aé\xff", "Aé\xff", "Aé\xff"},
+	}
+	for s, wants := range rules {
+		for m, want := range wants {
+			if got := string(caseMap([]byte(s), UTF8, caseMapping(m), caseOpts{})); got != want {
+				t.Errorf("%q: %v=%q (want=%q)", s, caseMapping(m), got, want)
+			}
+		}
+	}
+	opts := map[caseOpts]string{
+		{ascii: true}:  "Iéß",
+		{turkic: true}: "İÉSS",
+	}
+	for o, want := range opts {
+		if got := string(caseMap([]byte("iéß"), UTF8, caseUpper, o)); got != want {
+			t.Errorf("%+v: upcase=%q (want=%q)", o, got, want)
+		}
+	}
+	if got := string(caseMap([]byte("Aé"), Binary, caseUpper, caseOpts{})); got != "Aé" {
+		t.Errorf("binary upcase=%q (want=%q)", got, "Aé")
+	}
+}
+
+func TestIntern(t *testing.T) {
+	names := []string{"foo", "bar", "@baz", "qux=", "シンボル"}
+	ids := make(chan []ID)
+	for i := 0; i < 8; i++ {
+		go func() {
+			got := make([]ID, len(names))
+			for j, name := range names {
+				got[j] = Intern(name)
+			}
+			ids <- got
+		}()
+	}
+	want := <-ids
+	for i := 1; i < 8; i++ {
+		got := <-ids
+		for j := range names {
+			if got[j] != want[j] {
+				t.Errorf("%s: Intern=%v (want=%v)", names[j], got[j], want[j])
+			}
+		}
+	}
+	for j, name := range names {
+		if want[j].String() != name {
+			t.Errorf("String=%q (want=%q)", want[j].String(), name)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	rt := New()
+	h := rt.NewHash()
+	for i := 0; i < 20; i++ {
+		rt.HashSet(h, Fixnum(i), Fixnum(i*i))
+	}
+	for i := 0; i < 18; i++ {
+		rt.HashDelete(h, Fixnum(i))
+	}
+	rt.HashSet(h, Fixnum(0), True)
+	rt.HashSet(h, rt.NewString("a"), Nil)
+	if got, want := rt.Inspect(h), `{18=>324, 19=>361, 0=>true, "a"=>nil}`; got != want {
+		t.Errorf("inspect=%v (want=%v)", got, want)
+	}
+	if v, ok := rt.HashGet(h, rt.NewString("a")); !ok || v != Nil {
+		t.Errorf("get=%v, %v (want=nil, true)", v, ok)
+	}
+
+	// the key changed after inserted is found after rehash
+	key := rt.NewArray([]Value{Fixnum(1)})
+	rt.HashSet(h, key, Fixnum(1))
+	key.Elems = append(key.Elems, Fixnum(2))
+	if _, ok := rt.HashGet(h, key); ok {
+		t.Errorf("get of the changed key=true (want=false)")
+	}
+	rt.rehash(h)
+	if v, _ := rt.HashGet(h, rt.NewArray([]Value{Fixnum(1), Fixnum(2)})); v != Fixnum(1) {
+		t.Errorf("get after rehash=%v (want=1)", v)
+	}
+
+	// no key can be added during the iteration
+	func() {
+		defer func() {
+			err, ok := recover().(*Error)
+			want := "can't add a new key into hash during iteration (RuntimeError)"
+			if !ok || err.Error() != want {
+				t.Errorf("err=%v (want=%v)", err, want)
+			}
+		}()
+		h.each(func(k, v Value) {
+			rt.HashDelete(h, k)
+			rt.HashSet(h, SymbolOf("new"), Nil)
+		})
+	}()
+	if h.iter != 0 {
+		t.Errorf("iter=%d (want=0)", h.iter)
+	}
+
+	// the default proc is called with the hash and the key
+	d := rt.NewHash()
+	d.DefaultProc = &RProc{Fn: func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewArray(args)
+	}}
+	if got, want := rt.Inspect(rt.hashAref(d, SymbolOf("k"))), "[{}, :k]"; got != want {
+		t.Errorf("default=%v (want=%v)", got, want)
+	}
+}
+
+func TestSortValues(t *testing.T) {
+	rt := New()
+	elems := []Value{Fixnum(3), Float(1.5), Fixnum(-2), Fixnum(3), Float(0)}
+	rt.sortValues(elems, nil)
+	if got, want := rt.Inspect(rt.NewArray(elems)), "[-2, 0.0, 1.5, 3, 3]"; got != want {
+		t.Errorf("sort=%v (want=%v)", got, want)
+	}
+}
+
+func TestStrSucc(t *testing.T) {
+	rules := map[string]string{
+		"az":    "ba",
+		"zz":    "aaa",
+		"a9":    "b0",
+		"Zz":    "AAa",
+		"1.9.9": "2.0.0",
+		"a.9":   "a.10",
+		"***":   "**+",
+		"":      "",
+		"α":     "β",
+	}
+	for s, want := range rules {
+		if got := string(strSucc([]byte(s), UTF8)); got != want {
+			t.Errorf("%q.succ=%q (want=%q)", s, got, want)
+		}
+	}
+	if got, want := string(strSucc([]byte("\xff"), Binary)), "\x01\x00"; got != want {
+		t.Errorf("binary succ=%q (want=%q)", got, want)
+	}
+}
+
+func TestRange(t *testing.T) {
+	rt := New()
+	collect := func(name string, r *RRange, args ...Value) string {
+		a := rt.NewArray(nil)
+		blk := &RProc{Fn: func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			a.Elems = append(a.Elems, args[0])
+			return Nil
+		}}
+		rt.Call(r, Intern(name), args, blk, false)
+		return rt.Inspect(a)
+	}
+	rules := []struct {
+		got, want string
+	}{
+		{collect("each", rt.NewRange(rt.NewString("az"), rt.NewString("bc"), false)), `["az", "ba", "bb", "bc"]`},
+		{collect("each", rt.NewRange(rt.NewString("9"), rt.NewString("10"), false)), `["9", "10"]`},
+		{collect("step", rt.NewRange(Float(1), Fixnum(2), false), Float(0.25)), "[1.0, 1.25, 1.5, 1.75, 2.0]"},
+		{collect("step", rt.NewRange(Fixnum(0), Float(0.3), true), Float(0.1)), "[0.0, 0.1, 0.2]"},
+		{collect("step", rt.NewRange(rt.NewString("a"), rt.NewString("e"), false), Fixnum(2)), `["a", "c", "e"]`},
+		{collect("each_with_index", rt.NewRange(SymbolOf("a"), SymbolOf("c"), true)), "[:a, :b]"},
+		{rt.Inspect(rt.intervalSize(Float(1), Fixnum(2), Float(0.1), false)), "11"},
+	}
+	for i, r := range rules {
+		if r.got != r.want {
+			t.Errorf("%d: %v (want=%v)", i, r.got, r.want)
+		}
+	}
+}
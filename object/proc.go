@@ -0,0 +1,258 @@
+package object
+
+import "fmt"
+
+// RProc is a Proc. The blocks written in Ruby have the Body given by the
+// evaluator, which runs them by Invoker.CallBlock. The builtin procs such
+// as Symbol#to_proc are implemented by Fn, which is called with the
+// arguments and the block of the call.
+type RProc struct {
+	RObject
+	Fn      BuiltinFunc
+	Body    interface{}
+	Params  []ProcParam
+	Lambda  bool
+	Literal bool   // block literal not taken as an object yet, which lambda turns into a lambda
+	Desc    string // shown by inspect such as (&:upcase) or the location
+}
+
+// ProcParam is a parameter of procs. The kinds are the ones of
+// Proc#parameters: req, opt, rest, keyreq, key, keyrest and block. The
+// name is empty for the anonymous parameters.
+type ProcParam struct {
+	Kind string
+	Name string
+}
+
+// minMax returns the minimum and the maximum number of the arguments, where
+// the maximum is -1 if unlimited. The keyword arguments are counted as a
+// hash.
+func (p *RProc) minMax() (min, max int) {
+	rest, kw, keyreq := false, false, false
+	for _, q := range p.Params {
+		switch q.Kind {
+		case "req":
+			min++
+			max++
+		case "opt":
+			max++
+		case "rest":
+			rest = true
+		case "keyreq":
+			kw, keyreq = true, true
+		case "key", "keyrest":
+			kw = true
+		}
+	}
+	if keyreq {
+		min++
+	}
+	if kw {
+		max++
+	}
+	if rest {
+		max = -1
+	}
+	return min, max
+}
+
+// Arity returns the arity of the proc. The optional parameters make it
+// negative only for lambdas, since procs take any number of arguments.
+func (p *RProc) Arity() int {
+	min, max := p.minMax()
+	if p.Lambda && min == max || !p.Lambda && max >= 0 {
+		return min
+	}
+	return -min - 1
+}
+
+// CallProc calls the proc with the arguments and the block.
+func (rt *Runtime) CallProc(p *RProc, args []Value, blk Value) Value {
+	if p.Fn != nil {
+		return p.Fn(rt, p, args, blk)
+	}
+	return rt.Invoker.CallBlock(p, args, blk)
+}
+
+// ClassExec runs the block as the body of the class. The builtin blocks
+// are called with the class.
+func (rt *Runtime) ClassExec(c *RClass, blk Value) Value {
+	if p, ok := blk.(*RProc); ok && p.Fn == nil {
+		return rt.Invoker.ClassExec(p, c)
+	}
+	return rt.Yield(blk, c)
+}
+
+// BlockArg returns the block passed by & such as &blk or &:sym, which is
+// nil for nil, or the proc converted by to_proc.
+func (rt *Runtime) BlockArg(v Value) Value {
+	if v == Nil {
+		return nil
+	}
+	p, ok := v.(*RProc)
+	if !ok {
+		name := rt.ClassOf(v).RealClass().Name
+		if !rt.RespondTo(v, "to_proc") {
+			rt.Raise(rt.TypeError, "wrong argument type %s (expected Proc)", name)
+		}
+		r := rt.Send(v, "to_proc")
+		if p, ok = r.(*RProc); !ok {
+			rt.Raise(rt.TypeError, "can't convert %s to Proc (%s#to_proc gives %s)", name, name, rt.ClassOf(r).RealClass().Name)
+		}
+	}
+	p.Literal = false
+	return p
+}
+
+// symbolProc returns the proc of Symbol#to_proc, which calls the method of
+// the symbol for the first argument.
+func (rt *Runtime) symbolProc(s Symbol) *RProc {
+	return &RProc{
+		Fn: func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if len(args) == 0 {
+				rt.Raise(rt.ArgumentError, "no receiver given")
+			}
+			return rt.Call(args[0], ID(s), args[1:], blk, false)
+		},
+		Params: []ProcParam{{Kind: "req"}, {Kind: "rest"}},
+		Lambda: true,
+		Desc:   "(&" + InspectSymbol(s.Name()) + ")",
+	}
+}
+
+func (rt *Runtime) initProc() {
+	c := rt.Proc
+	rt.DefineMethod(rt.SingletonClassOf(c), "new", -1, procNew)
+	for _, name := range []string{"call", "()", "yield", "[]", "==="} {
+		rt.DefineMethod(c, name, -1, procCall)
+	}
+	rt.DefineMethod(c, "to_proc", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(c, "arity", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Fixnum(self.(*RProc).Arity())
+	})
+	rt.DefineMethod(c, "lambda?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self.(*RProc).Lambda)
+	})
+	rt.DefineMethod(c, "parameters", -1, procParameters)
+	rt.DefineMethod(c, "curry", -1, procCurry)
+	rt.DefineMethod(c, "inspect", 0, procInspect)
+	rt.DefineMethod(c, "to_s", 0, procInspect)
+
+	k := rt.Kernel
+	rt.definePrivate(k, "proc", 0, procNew)
+	rt.definePrivate(k, "lambda", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		p := rt.blockProc(blk)
+		if p.Literal {
+			p.Lambda = true
+		}
+		return p
+	})
+	rt.definePrivate(k, "block_given?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.Invoker.BlockGiven())
+	})
+}
+
+// blockProc returns the block given to proc, lambda or Proc.new, or raises
+// ArgumentError if it is not given.
+func (rt *Runtime) blockProc(blk Value) *RProc {
+	p, ok := blk.(*RProc)
+	if !ok {
+		rt.Raise(rt.ArgumentError, "tried to create Proc object without a block")
+	}
+	return p
+}
+
+func procNew(rt *Runtime, self Value, args []Value, blk Value) Value {
+	rt.CheckArity(len(args), 0)
+	return rt.blockProc(blk)
+}
+
+func procCall(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return rt.CallProc(self.(*RProc), args, blk)
+}
+
+// procParameters returns the parameters of the proc. The required
+// parameters of procs are optional, unless lambda: true is given.
+func procParameters(rt *Runtime, self Value, args []Value, blk Value) Value {
+	p := self.(*RProc)
+	lambda := p.Lambda
+	switch len(args) {
+	case 0:
+	case 1:
+		if v, ok := rt.HashGet(rt.toHash(args[0]), SymbolOf("lambda")); ok && v != Nil {
+			lambda = Truthy(v)
+		}
+	default:
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+	}
+	params := make([]Value, len(p.Params))
+	for i, q := range p.Params {
+		kind := q.Kind
+		if kind == "req" && !lambda {
+			kind = "opt"
+		}
+		a := []Value{SymbolOf(kind)}
+		if q.Name != "" {
+			a = append(a, SymbolOf(q.Name))
+		}
+		params[i] = rt.NewArray(a)
+	}
+	return rt.NewArray(params)
+}
+
+// procCurry returns the curried proc, which takes the arguments until the
+// number of them reaches the arity. The arity of lambdas is checked.
+func procCurry(rt *Runtime, self Value, args []Value, blk Value) Value {
+	p := self.(*RProc)
+	min, max := p.minMax()
+	arity := min
+	switch len(args) {
+	case 0:
+	case 1:
+		if args[0] == Nil {
+			break
+		}
+		arity = int(rt.toInt(args[0]))
+		if p.Lambda && (arity < min || max >= 0 && arity > max) {
+			expected := fmt.Sprint(min)
+			switch {
+			case max < 0:
+				expected += "+"
+			case max > min:
+				expected += fmt.Sprintf("..%d", max)
+			}
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected %s)", arity, expected)
+		}
+	default:
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+	}
+	return rt.curry(p, nil, arity)
+}
+
+func (rt *Runtime) curry(p *RProc, passed []Value, arity int) *RProc {
+	return &RProc{
+		Fn: func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			all := append(append([]Value(nil), passed...), args...)
+			if len(all) < arity {
+				return rt.curry(p, all, arity)
+			}
+			return rt.CallProc(p, all, blk)
+		},
+		Params: []ProcParam{{Kind: "rest"}},
+		Lambda: p.Lambda,
+	}
+}
+
+func procInspect(rt *Runtime, self Value, args []Value, blk Value) Value {
+	p := self.(*RProc)
+	s := fmt.Sprintf("#<Proc:0x%016x", int64(rt.ObjectID(self)))
+	if p.Desc != "" {
+		s += p.Desc
+	}
+	if p.Lambda {
+		s += " (lambda)"
+	}
+	return rt.NewString(s + ">")
+}
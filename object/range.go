@@ -0,0 +1,677 @@
+package object
+
+import (
+	"math"
+)
+
+// RRange is a Range. The begin is nil for the beginless range, and the end
+// is nil for the endless range. The ranges of Range itself are frozen.
+type RRange struct {
+	RObject
+	Begin, End Value
+	Excl       bool // excludes the end
+}
+
+// NewRange returns a new range, or raises ArgumentError if the ends are not
+// comparable.
+func (rt *Runtime) NewRange(b, e Value, excl bool) *RRange {
+	r := &RRange{}
+	rt.rangeInit(r, b, e, excl)
+	r.frozen = true
+	return r
+}
+
+func (rt *Runtime) rangeInit(r *RRange, b, e Value, excl bool) {
+	_, bi := b.(Fixnum)
+	_, ei := e.(Fixnum)
+	if !(bi && ei) && b != Nil && e != Nil && rt.Send(b, "<=>", e) == Nil {
+		rt.Raise(rt.ArgumentError, "bad value for range")
+	}
+	r.Begin, r.End, r.Excl = b, e, excl
+}
+
+// rangeCompare returns the sign of a <=> b, or math.MaxInt32 if they are
+// not comparable, as r_less of MRI.
+func (rt *Runtime) rangeCompare(a, b Value) int {
+	r := rt.Send(a, "<=>", b)
+	if r == Nil {
+		return math.MaxInt32
+	}
+	return rt.cmpInt(r, a, b)
+}
+
+// rangeCover returns whether the value is between the ends of the range
+// by comparison.
+func (rt *Runtime) rangeCover(r *RRange, v Value) bool {
+	if r.Begin != Nil && rt.rangeCompare(r.Begin, v) > 0 {
+		return false
+	}
+	if r.End == Nil {
+		return true
+	}
+	c := rt.rangeCompare(v, r.End)
+	return c < 0 || c == 0 && !r.Excl
+}
+
+// rangeCoverRange returns whether all the values of the range o are
+// covered by the range r.
+func (rt *Runtime) rangeCoverRange(r, o *RRange) bool {
+	if r.End != Nil && o.End == Nil || r.Begin != Nil && o.Begin == Nil {
+		return false
+	}
+	if o.Begin != Nil && o.End != Nil {
+		if c := rt.rangeCompare(o.Begin, o.End); c > 0 || c == 0 && o.Excl {
+			return false
+		}
+	}
+	if o.Begin != Nil && !rt.rangeCover(r, o.Begin) {
+		return false
+	}
+	c := rt.rangeCompare(r.End, o.End)
+	switch {
+	case r.Excl == o.Excl:
+		return c >= 0
+	case r.Excl:
+		return c > 0
+	case c >= 0:
+		return true
+	}
+	// 1..2 covers 1...3 whose max is 2
+	if !isInteger(o.Begin) || !isInteger(o.End) {
+		return false
+	}
+	return rt.rangeCompare(r.End, intSub(o.End, Fixnum(1))) >= 0
+}
+
+// rangeInclude returns whether the range includes the value. The numeric
+// ranges are compared with the ends, and the string ranges are iterated.
+func (rt *Runtime) rangeInclude(r *RRange, v Value) bool {
+	if isLinear(r.Begin) || isLinear(r.End) {
+		return rt.rangeCover(r, v)
+	}
+	b, bs := r.Begin.(*RString)
+	e, es := r.End.(*RString)
+	switch {
+	case bs && es:
+		s, ok := v.(*RString)
+		if !ok {
+			return false
+		}
+		if len(b.B) == 1 && len(e.B) == 1 && len(s.B) == 1 && isASCII(b.B) && isASCII(e.B) && isASCII(s.B) {
+			return b.B[0] <= s.B[0] && s.B[0] < e.B[0] || !r.Excl && s.B[0] == e.B[0]
+		}
+		found := false
+		rt.strUpto(b, e, r.Excl, func(x Value) bool {
+			found = rt.Equal(x, s)
+			return !found
+		})
+		return found
+	case r.Begin == Nil && es:
+		c := rt.Send(v, "<=>", e)
+		if c == Nil {
+			return false
+		}
+		n := rt.cmpInt(c, v, e)
+		return n < 0 || n == 0 && !r.Excl
+	case bs && r.End == Nil:
+		c := rt.Send(b, "<=>", v)
+		return c != Nil && rt.cmpInt(c, b, v) <= 0
+	}
+	found := false
+	rt.rangeEach(r, func(x Value) bool {
+		found = rt.Equal(x, v)
+		return !found
+	})
+	return found
+}
+
+// isLinear returns whether the value is a number, of which ranges include
+// the values between the ends.
+func isLinear(v Value) bool {
+	switch v.(type) {
+	case Fixnum, *Bignum, Float:
+		return true
+	}
+	return false
+}
+
+// rangeEach calls f for each element of the range until f returns false.
+// The elements are the successors of the begin by succ.
+func (rt *Runtime) rangeEach(r *RRange, f func(Value) bool) {
+	if isInteger(r.Begin) && (r.End == Nil || isInteger(r.End)) {
+		for v := r.Begin; intBefore(v, r); v = intAdd(v, Fixnum(1)) {
+			if !f(v) {
+				return
+			}
+		}
+		return
+	}
+	switch b := r.Begin.(type) {
+	case *RString:
+		if r.End == Nil {
+			rt.strUptoEndless(b, f)
+		} else {
+			rt.strUpto(b, rt.toStr(r.End), r.Excl, f)
+		}
+		return
+	case Symbol:
+		g := func(v Value) bool {
+			return f(SymbolOf(string(v.(*RString).B)))
+		}
+		if e, ok := r.End.(Symbol); ok {
+			rt.strUpto(rt.NewString(b.Name()), rt.NewString(e.Name()), r.Excl, g)
+			return
+		} else if r.End == Nil {
+			rt.strUptoEndless(rt.NewString(b.Name()), g)
+			return
+		}
+	}
+	if !rt.RespondTo(r.Begin, "succ") {
+		rt.Raise(rt.TypeError, "can't iterate from %s", rt.ClassOf(r.Begin).RealClass().Name)
+	}
+	for v := r.Begin; ; v = rt.Send(v, "succ") {
+		c := 0
+		if r.End != Nil {
+			c = rt.rangeCompare(v, r.End)
+			if c > 0 || c == 0 && r.Excl {
+				return
+			}
+		}
+		if !f(v) || r.End != Nil && c == 0 {
+			return
+		}
+	}
+}
+
+// intBefore returns whether the integer is not beyond the integer end of
+// the range.
+func intBefore(v Value, r *RRange) bool {
+	if r.End == Nil {
+		return true
+	}
+	c := intCmp(v, r.End)
+	return c < 0 || c == 0 && !r.Excl
+}
+
+// rangeFirst returns the first n elements of the range.
+func (rt *Runtime) rangeFirst(r *RRange, n int) []Value {
+	elems := []Value{}
+	if n == 0 {
+		return elems
+	}
+	rt.rangeEach(r, func(v Value) bool {
+		elems = append(elems, v)
+		return len(elems) < n
+	})
+	return elems
+}
+
+// rangeToA returns the elements of the range as an array.
+func (rt *Runtime) rangeToA(r *RRange) *RArray {
+	if r.End == Nil {
+		rt.Raise(rt.RangeError, "cannot convert endless range to an array")
+	}
+	var elems []Value
+	rt.rangeEach(r, func(v Value) bool {
+		elems = append(elems, v)
+		return true
+	})
+	return rt.NewArray(elems)
+}
+
+// rangeBegLen returns the start and the length of the range indexing the
+// sequence of the size, as rb_range_beg_len. If the start is out of the
+// sequence, it returns false for err 0 or raises RangeError otherwise. The
+// length is clipped at the end of the sequence unless err is 1.
+func (rt *Runtime) rangeBegLen(r *RRange, size, err int) (int, int, bool) {
+	outOfRange := func() (int, int, bool) {
+		if err != 0 {
+			rt.Raise(rt.RangeError, "%s out of range", rt.Inspect(r))
+		}
+		return 0, 0, false
+	}
+	beg, end, excl := 0, -1, r.Excl
+	if r.Begin != Nil {
+		beg = int(rt.toInt(r.Begin))
+	}
+	if r.End != Nil {
+		end = int(rt.toInt(r.End))
+	} else {
+		excl = false
+	}
+	if beg < 0 {
+		beg += size
+		if beg < 0 {
+			return outOfRange()
+		}
+	}
+	if end < 0 {
+		end += size
+	}
+	if !excl {
+		end++
+	}
+	if err != 1 {
+		if beg > size {
+			return outOfRange()
+		}
+		if end > size {
+			end = size
+		}
+	}
+	if end < beg {
+		end = beg
+	}
+	return beg, end - beg, true
+}
+
+// intervalSize returns the number of the steps from b to e by the step as
+// ruby_num_interval_step_size.
+func (rt *Runtime) intervalSize(b, e, step Value, excl bool) Value {
+	if isInteger(b) && isInteger(e) && isInteger(step) {
+		if intSign(step) == 0 {
+			return Float(math.Inf(1))
+		}
+		delta := intSub(e, b)
+		if intSign(step) < 0 {
+			step, delta = intSub(Fixnum(0), step), intSub(Fixnum(0), delta)
+		}
+		if excl {
+			delta = intSub(delta, Fixnum(1))
+		}
+		if intSign(delta) < 0 {
+			return Fixnum(0)
+		}
+		q, _ := rt.intDivmod(delta, step)
+		return intAdd(q, Fixnum(1))
+	}
+	n := floatStepSize(numToFloat(b), numToFloat(e), numToFloat(step), excl)
+	if math.IsInf(n, 0) {
+		return Float(n)
+	}
+	return rt.floatToInt(n)
+}
+
+func numToFloat(v Value) float64 {
+	if f, ok := v.(Float); ok {
+		return float64(f)
+	}
+	return intToFloat(v)
+}
+
+// floatStepSize returns the number of the steps from beg to end by unit as
+// ruby_float_step_size, allowing the rounding errors of the floats.
+func floatStepSize(beg, end, unit float64, excl bool) float64 {
+	if unit == 0 {
+		return math.Inf(1)
+	}
+	if math.IsInf(unit, 0) {
+		if unit > 0 && beg <= end || unit < 0 && beg >= end {
+			return 1
+		}
+		return 0
+	}
+	n := (end - beg) / unit
+	err := (math.Abs(beg) + math.Abs(end) + math.Abs(end-beg)) / math.Abs(unit) * epsilon
+	if err > 0.5 {
+		err = 0.5
+	}
+	if excl {
+		if n <= 0 {
+			return 0
+		}
+		if n < 1 {
+			n = 0
+		} else {
+			n = math.Floor(n - err)
+		}
+		d := (n+1)*unit + beg
+		if beg < end && d < end || beg > end && d > end {
+			n++
+		}
+		return n + 1
+	}
+	if n < 0 {
+		return 0
+	}
+	return math.Floor(n+err) + 1
+}
+
+// epsilon is DBL_EPSILON.
+const epsilon = 2.220446049250313e-16
+
+// rangeStep calls f for every step elements of the range as Range#step.
+func (rt *Runtime) rangeStep(r *RRange, step Value, f func(Value)) {
+	switch step.(type) {
+	case Fixnum, *Bignum, Float:
+	default:
+		step = rt.toInt(step)
+	}
+	switch c := rt.compareValues(step, Fixnum(0), nil); {
+	case c < 0:
+		rt.Raise(rt.ArgumentError, "step can't be negative")
+	case c == 0:
+		rt.Raise(rt.ArgumentError, "step can't be 0")
+	}
+	b, e := r.Begin, r.End
+	_, bf := b.(Float)
+	_, ef := e.(Float)
+	_, sf := step.(Float)
+	switch {
+	case b == Nil:
+		rt.Raise(rt.ArgumentError, "#step iteration for beginless ranges is meaningless")
+	case isInteger(b) && (e == Nil || isInteger(e)) && isInteger(step):
+		for v := b; intBefore(v, r); v = intAdd(v, step) {
+			f(v)
+		}
+	case isLinear(b) && (bf || ef || sf) && (e == Nil || isLinear(e)):
+		unit, beg := numToFloat(step), numToFloat(b)
+		end := math.Inf(1)
+		if e != Nil {
+			end = numToFloat(e)
+		}
+		n := floatStepSize(beg, end, unit, r.Excl)
+		if math.IsInf(unit, 0) {
+			if n > 0 {
+				f(Float(beg))
+			}
+			return
+		}
+		for i := 0; float64(i) < n; i++ {
+			d := float64(i)*unit + beg
+			if end < d {
+				d = end
+			}
+			f(Float(d))
+		}
+	default:
+		n, i := rt.toInt(step), Fixnum(0)
+		rt.rangeEach(r, func(v Value) bool {
+			if i%n == 0 {
+				f(v)
+			}
+			i++
+			return true
+		})
+	}
+}
+
+func (rt *Runtime) initRange() {
+	c := rt.Range
+	rt.definePrivate(c, "initialize", -3, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 3 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 2..3)", len(args))
+		}
+		rt.CheckFrozen(self)
+		r := self.(*RRange)
+		rt.rangeInit(r, args[0], args[1], len(args) == 3 && Truthy(args[2]))
+		if rt.ClassOf(self) == rt.Range {
+			r.frozen = true
+		}
+		return Nil
+	})
+	rt.DefineMethod(c, "begin", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self.(*RRange).Begin
+	})
+	rt.DefineMethod(c, "end", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self.(*RRange).End
+	})
+	rt.DefineMethod(c, "exclude_end?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self.(*RRange).Excl)
+	})
+	rt.DefineMethod(c, "first", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+		}
+		r := self.(*RRange)
+		if r.Begin == Nil {
+			rt.Raise(rt.RangeError, "cannot get the first element of beginless range")
+		}
+		if len(args) == 0 {
+			return r.Begin
+		}
+		n := int(rt.toInt(args[0]))
+		if n < 0 {
+			rt.Raise(rt.ArgumentError, "negative array size (or size too big)")
+		}
+		return rt.NewArray(rt.rangeFirst(r, n))
+	})
+	rt.DefineMethod(c, "last", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+		}
+		r := self.(*RRange)
+		if r.End == Nil {
+			rt.Raise(rt.RangeError, "cannot get the last element of endless range")
+		}
+		if len(args) == 0 {
+			return r.End
+		}
+		if !isInteger(r.Begin) || !isInteger(r.End) {
+			return rt.Call(rt.rangeToA(r), Intern("last"), args, nil, false)
+		}
+		n := int(rt.toInt(args[0]))
+		if n < 0 {
+			rt.Raise(rt.ArgumentError, "negative array size")
+		}
+		if size := rt.intervalSize(r.Begin, r.End, Fixnum(1), r.Excl); intCmp(size, Fixnum(n)) < 0 {
+			n = int(size.(Fixnum))
+		}
+		e := r.End
+		if r.Excl {
+			e = intSub(e, Fixnum(1))
+		}
+		elems := make([]Value, n)
+		for i := range elems {
+			elems[i] = intSub(e, Fixnum(n-1-i))
+		}
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(c, "min", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		r := self.(*RRange)
+		if r.Begin == Nil {
+			rt.Raise(rt.RangeError, "cannot get the minimum of beginless range")
+		}
+		if blk != nil {
+			if r.End == Nil {
+				rt.Raise(rt.RangeError, "cannot get the minimum of endless range with custom comparison method")
+			}
+			return rt.enumCall(self, "min", args, blk)
+		}
+		if len(args) > 0 {
+			return rt.Call(self, Intern("first"), args, nil, false)
+		}
+		if r.End != Nil {
+			if c := rt.compareValues(r.Begin, r.End, nil); c > 0 || c == 0 && r.Excl {
+				return Nil
+			}
+		}
+		return r.Begin
+	})
+	rt.DefineMethod(c, "max", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		r := self.(*RRange)
+		if r.End == Nil {
+			rt.Raise(rt.RangeError, "cannot get the maximum of endless range")
+		}
+		if blk != nil || len(args) > 0 || r.Excl && !isLinear(r.End) {
+			if r.Begin == Nil {
+				rt.Raise(rt.RangeError, "cannot get the maximum of beginless range with custom comparison method")
+			}
+			return rt.enumCall(self, "max", args, blk)
+		}
+		c := -1
+		if r.Begin != Nil {
+			c = rt.compareValues(r.Begin, r.End, nil)
+		}
+		if c > 0 {
+			return Nil
+		}
+		if r.Excl {
+			if !isInteger(r.End) {
+				rt.Raise(rt.TypeError, "cannot exclude non Integer end value")
+			}
+			if c == 0 {
+				return Nil
+			}
+			if !isInteger(r.Begin) {
+				rt.Raise(rt.TypeError, "cannot exclude end value with non Integer begin value")
+			}
+			return intSub(r.End, Fixnum(1))
+		}
+		return r.End
+	})
+	rt.DefineMethod(c, "size", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.rangeSize(self.(*RRange))
+	})
+	rt.DefineMethod(c, "count", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		r := self.(*RRange)
+		if len(args) == 0 && blk == nil {
+			if r.Begin == Nil || r.End == Nil {
+				return Float(math.Inf(1))
+			}
+			if isInteger(r.Begin) {
+				if n := rt.rangeSize(r); n != Nil {
+					return n
+				}
+			}
+		}
+		return rt.enumCall(self, "count", args, blk)
+	})
+	rt.DefineMethod(c, "each", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		rt.rangeEach(self.(*RRange), func(v Value) bool {
+			rt.Yield(blk, v)
+			return true
+		})
+		return self
+	})
+	for _, name := range []string{"step", "%"} {
+		rt.DefineMethod(c, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			if len(args) > 1 {
+				rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+			}
+			if blk == nil {
+				rt.NotImplemented("ArithmeticSequence")
+			}
+			step := Value(Fixnum(1))
+			if len(args) > 0 {
+				step = args[0]
+			}
+			rt.rangeStep(self.(*RRange), step, func(v Value) {
+				rt.Yield(blk, v)
+			})
+			return self
+		})
+	}
+	for _, name := range []string{"to_a", "entries"} {
+		rt.DefineMethod(c, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return rt.rangeToA(self.(*RRange))
+		})
+	}
+	for _, name := range []string{"include?", "member?"} {
+		rt.DefineMethod(c, name, 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return Bool(rt.rangeInclude(self.(*RRange), args[0]))
+		})
+	}
+	rt.DefineMethod(c, "cover?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if o, ok := args[0].(*RRange); ok {
+			return Bool(rt.rangeCoverRange(self.(*RRange), o))
+		}
+		return Bool(rt.rangeCover(self.(*RRange), args[0]))
+	})
+	rt.DefineMethod(c, "===", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.rangeCover(self.(*RRange), args[0]))
+	})
+	rt.DefineMethod(c, "==", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.rangeEqual(self.(*RRange), args[0], rt.Equal))
+	})
+	rt.DefineMethod(c, "eql?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(rt.rangeEqual(self.(*RRange), args[0], rt.Eql))
+	})
+	rt.DefineMethod(c, "hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		r := self.(*RRange)
+		h := uint64(0)
+		if r.Excl {
+			h = 1
+		}
+		h = h*31 + rt.hashValue(r.Begin)
+		h = h*31 + rt.hashValue(r.End)
+		return Fixnum(h >> 2)
+	})
+	rt.DefineMethod(c, "inspect", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		r := self.(*RRange)
+		var s string
+		if r.Begin != Nil || r.End == Nil {
+			s = rt.Inspect(r.Begin)
+		}
+		s += rangeDots(r)
+		if r.Begin == Nil || r.End != Nil {
+			s += rt.Inspect(r.End)
+		}
+		return rt.NewString(s)
+	})
+	rt.DefineMethod(c, "to_s", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		r := self.(*RRange)
+		return rt.NewString(rt.ToS(r.Begin) + rangeDots(r) + rt.ToS(r.End))
+	})
+
+	// overriding Enumerable
+	rt.DefineMethod(c, "sum", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+		}
+		r := self.(*RRange)
+		init := Value(Fixnum(0))
+		if len(args) > 0 {
+			init = args[0]
+		}
+		if _, ok := init.(Float); blk != nil || ok || !isInteger(r.Begin) || !isInteger(r.End) {
+			return rt.enumCall(self, "sum", args, blk)
+		}
+		// (b + e) * (e - b + 1) / 2
+		e := r.End
+		if r.Excl {
+			e = intSub(e, Fixnum(1))
+		}
+		if intCmp(e, r.Begin) < 0 {
+			return init
+		}
+		n, _ := rt.intDivmod(intMul(intAdd(r.Begin, e), intAdd(intSub(e, r.Begin), Fixnum(1))), Fixnum(2))
+		return rt.Send(init, "+", n)
+	})
+}
+
+// rangeSize returns the number of the elements of the range, or nil if it
+// is not a numeric range.
+func (rt *Runtime) rangeSize(r *RRange) Value {
+	if isInteger(r.Begin) {
+		switch r.End.(type) {
+		case Fixnum, *Bignum, Float:
+			return rt.intervalSize(r.Begin, r.End, Fixnum(1), r.Excl)
+		case nilValue:
+			return Float(math.Inf(1))
+		}
+	}
+	if !rt.RespondTo(r.Begin, "succ") {
+		rt.Raise(rt.TypeError, "can't iterate from %s", rt.ClassOf(r.Begin).RealClass().Name)
+	}
+	return Nil
+}
+
+// rangeEqual returns whether the value is a range of the same ends by eq.
+func (rt *Runtime) rangeEqual(r *RRange, v Value, eq func(a, b Value) bool) bool {
+	if Value(r) == v {
+		return true
+	}
+	o, ok := v.(*RRange)
+	return ok && r.Excl == o.Excl && eq(r.Begin, o.Begin) && eq(r.End, o.End)
+}
+
+func rangeDots(r *RRange) string {
+	if r.Excl {
+		return "..."
+	}
+	return ".."
+}
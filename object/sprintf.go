@@ -0,0 +1,294 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatSpec is a conversion specification of format strings such as
+// %-08.3f.
+type formatSpec struct {
+	minus, plus, space, zero, sharp bool
+	width, prec                     int // -1 if not given
+}
+
+// sprintf formats the arguments as Kernel#format. The arguments are
+// referred in order, by the absolute positions such as %1$s, or by the
+// names such as %<a>s and %{a} in the hash of the only argument.
+func (rt *Runtime) sprintf(format *RString, args []Value) *RString {
+	f := format.B
+	var out []byte
+	next := 0
+	arg := func(pos int) Value {
+		if pos == 0 {
+			if next >= len(args) {
+				rt.Raise(rt.ArgumentError, "too few arguments")
+			}
+			next++
+			return args[next-1]
+		}
+		if pos > len(args) {
+			rt.Raise(rt.ArgumentError, "too few arguments")
+		}
+		return args[pos-1]
+	}
+	named := func(name string) Value {
+		if len(args) != 1 || !rt.RespondTo(args[0], "to_hash") {
+			rt.Raise(rt.ArgumentError, "one hash required")
+		}
+		h := rt.Send(args[0], "to_hash")
+		v := rt.Send(h, "[]", SymbolOf(name))
+		if v == Nil && !Truthy(rt.Send(h, "key?", SymbolOf(name))) {
+			rt.Raise(rt.KeyError, "key<%s> not found", name)
+		}
+		return v
+	}
+
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			out = append(out, f[i])
+			continue
+		}
+		spec := formatSpec{width: -1, prec: -1}
+		var v Value
+		given := false
+	flags:
+		for {
+			i++
+			if i == len(f) {
+				rt.Raise(rt.ArgumentError, "incomplete format specifier; use %%%% (double %%) instead")
+			}
+			switch c := f[i]; c {
+			case '-':
+				spec.minus = true
+			case '+':
+				spec.plus = true
+			case ' ':
+				spec.space = true
+			case '0':
+				spec.zero = true
+			case '#':
+				spec.sharp = true
+			case '1', '2', '3', '4', '5', '6', '7', '8', '9':
+				n := 0
+				for ; i < len(f) && '0' <= f[i] && f[i] <= '9'; i++ {
+					n = n*10 + int(f[i]-'0')
+				}
+				if i < len(f) && f[i] == '$' {
+					v, given = arg(n), true
+				} else {
+					spec.width = n
+					i--
+				}
+			case '*':
+				spec.width = int(rt.toInt(arg(0)))
+				if spec.width < 0 {
+					spec.minus, spec.width = true, -spec.width
+				}
+			case '.':
+				spec.prec = 0
+				if i+1 < len(f) && f[i+1] == '*' {
+					i++
+					spec.prec = int(rt.toInt(arg(0)))
+					continue
+				}
+				for ; i+1 < len(f) && '0' <= f[i+1] && f[i+1] <= '9'; i++ {
+					spec.prec = spec.prec*10 + int(f[i+1]-'0')
+				}
+			case '<', '{':
+				end := bytes.IndexByte(f[i:], map[byte]byte{'<': '>', '{': '}'}[c])
+				if end < 0 {
+					rt.Raise(rt.ArgumentError, "malformed name - unmatched parenthesis")
+				}
+				v, given = named(string(f[i+1:i+end])), true
+				i += end
+				if c == '{' {
+					out = append(out, spec.pad([]byte(rt.ToS(v)), false)...)
+					break flags
+				}
+			case '%':
+				out = append(out, '%')
+				break flags
+			case 'd', 'i', 'u', 'x', 'X', 'o', 'b', 'B', 'f', 'e', 'E', 'g', 'G', 'a', 'A', 's', 'p', 'c':
+				if !given {
+					v = arg(0)
+				}
+				out = append(out, rt.formatValue(c, spec, v)...)
+				break flags
+			default:
+				rt.Raise(rt.ArgumentError, "malformed format string - %%%c", c)
+			}
+		}
+	}
+	return format.derive(out)
+}
+
+// pad pads s with spaces, or zeros after the sign and the prefix, to the
+// width of the characters.
+func (spec formatSpec) pad(s []byte, zero bool) []byte {
+	n := spec.width - utf8.RuneCount(s)
+	if n <= 0 {
+		return s
+	}
+	switch {
+	case spec.minus:
+		return append(s, bytes.Repeat([]byte(" "), n)...)
+	case zero && spec.zero:
+		i := 0
+		for i < len(s) && strings.IndexByte("+- ", s[i]) >= 0 {
+			i++
+		}
+		if len(s) > i+1 && s[i] == '0' && strings.IndexByte("xXbBo", s[i+1]) >= 0 {
+			i += 2
+		}
+		return append(append(append([]byte(nil), s[:i]...), bytes.Repeat([]byte("0"), n)...), s[i:]...)
+	}
+	return append(bytes.Repeat([]byte(" "), n), s...)
+}
+
+// formatValue formats the value with the conversion character.
+func (rt *Runtime) formatValue(c byte, spec formatSpec, v Value) []byte {
+	switch c {
+	case 's', 'p':
+		s := rt.ToS(v)
+		if c == 'p' {
+			s = rt.Inspect(v)
+		}
+		if spec.prec >= 0 {
+			if n := spec.prec; n < utf8.RuneCountInString(s) {
+				s = string([]rune(s)[:n])
+			}
+		}
+		return spec.pad([]byte(s), false)
+	case 'c':
+		var b []byte
+		if s, ok := v.(*RString); ok {
+			_, n := utf8.DecodeRune(s.B)
+			b = append(b, s.B[:n]...)
+		} else {
+			b = append(b, string(rune(rt.toInt(v)))...)
+		}
+		return spec.pad(b, false)
+	case 'f', 'e', 'E', 'g', 'G', 'a', 'A':
+		f := rt.formatFloatArg(v)
+		return spec.pad(formatFloat(c, spec, f), !math.IsInf(f, 0) && !math.IsNaN(f))
+	}
+	s := formatInteger(c, spec, kernelInteger(rt, Nil, []Value{v}, nil))
+	return spec.pad(s, spec.prec < 0 && !bytes.Contains(s, []byte("..")))
+}
+
+// formatFloatArg returns the value of %f and the like as Kernel#Float.
+func (rt *Runtime) formatFloatArg(v Value) float64 {
+	switch v := v.(type) {
+	case Fixnum, *Bignum, Float:
+		return rt.toFloat(v, v)
+	case *RString:
+		f, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(string(v.B)), "_", "", -1), 64)
+		if err != nil {
+			rt.Raise(rt.ArgumentError, "invalid value for Float(): %s", rt.Inspect(v))
+		}
+		return f
+	case nilValue:
+		rt.Raise(rt.TypeError, "can't convert nil into Float")
+	}
+	if rt.RespondTo(v, "to_f") {
+		if f, ok := rt.Send(v, "to_f").(Float); ok {
+			return float64(f)
+		}
+	}
+	rt.Raise(rt.TypeError, "can't convert %s into Float", rt.ClassOf(v).RealClass().Name)
+	return 0
+}
+
+// formatFloat formats f by the conversion of C, which is the same as Go
+// except %a.
+func formatFloat(c byte, spec formatSpec, f float64) []byte {
+	flags := ""
+	for i, set := range []bool{spec.plus, spec.space, spec.sharp} {
+		if set {
+			flags += string("+ #"[i])
+		}
+	}
+	if c == 'a' || c == 'A' {
+		s := strconv.FormatFloat(f, 'x', spec.prec, 64)
+		if i := strings.IndexByte(s, 'p'); i >= 0 {
+			exp := strings.TrimLeft(s[i+2:], "0")
+			if exp == "" {
+				exp = "0"
+			}
+			s = s[:i+2] + exp
+		}
+		if f >= 0 && spec.plus {
+			s = "+" + s
+		} else if f >= 0 && spec.space {
+			s = " " + s
+		}
+		if c == 'A' {
+			s = strings.ToUpper(s)
+		}
+		return []byte(s)
+	}
+	prec := spec.prec
+	if prec < 0 {
+		prec = 6
+	}
+	return []byte(fmt.Sprintf("%"+flags+".*"+string(c), prec, f))
+}
+
+// formatInteger formats n by %d, %x, %o or %b. The negative numbers are
+// shown in the two's complement such as ..f01 for %x unless the sign is
+// requested by + or space.
+func formatInteger(c byte, spec formatSpec, n Value) []byte {
+	base := map[byte]int{'x': 16, 'X': 16, 'o': 8, 'b': 2, 'B': 2}[c]
+	if base == 0 {
+		base = 10
+	}
+	neg := intSign(n) < 0
+	var sign, digits string
+	switch {
+	case neg && base != 10 && !spec.plus && !spec.space:
+		// the digits of n + base**d with the smallest d such that the
+		// leading digit is base-1, which is repeated infinitely
+		x, d := bigOf(n), 1
+		limit := big.NewInt(-1)
+		for x.Cmp(limit) < 0 {
+			limit.Mul(limit, big.NewInt(int64(base)))
+			d++
+		}
+		m := new(big.Int).Exp(big.NewInt(int64(base)), big.NewInt(int64(d)), nil)
+		digits = ".." + m.Add(m, x).Text(base)
+	case neg:
+		sign, digits = "-", bigOf(n).Text(base)[1:]
+	default:
+		digits = bigOf(n).Text(base)
+		if spec.plus {
+			sign = "+"
+		} else if spec.space {
+			sign = " "
+		}
+	}
+	if !strings.HasPrefix(digits, "..") {
+		for len(digits) < spec.prec {
+			digits = "0" + digits
+		}
+	}
+	prefix := ""
+	if spec.sharp {
+		switch c {
+		case 'x', 'X', 'b', 'B':
+			prefix = "0" + string(c)
+		case 'o':
+			prefix = "0"
+		}
+	}
+	s := sign + prefix + digits
+	if c == 'X' {
+		s = strings.ToUpper(s)
+	}
+	return []byte(s)
+}
@@ -0,0 +1,1093 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RString is a String, which is the bytes in the encoding.
+type RString struct {
+	RObject
+	B   []byte
+	Enc *Encoding // nil for UTF-8
+}
+
+// NewString returns a new string.
+func (rt *Runtime) NewString(s string) *RString {
+	return &RString{B: []byte(s)}
+}
+
+// Encoding returns the encoding of the string.
+func (s *RString) Encoding() *Encoding {
+	if s.Enc == nil {
+		return UTF8
+	}
+	return s.Enc
+}
+
+// derive returns a new string of the bytes in the encoding of s.
+func (s *RString) derive(b []byte) *RString {
+	return &RString{B: b, Enc: s.Enc}
+}
+
+// charRange returns the byte range of n characters from the character
+// index i, which is counted from the end if negative, as String#[] does.
+// The range is clipped at the end of the string.
+func (s *RString) charRange(i, n int) (int, int, bool) {
+	offs := s.Encoding().offsets(s.B)
+	size := len(offs) - 1
+	if i < 0 {
+		i += size
+	}
+	if i < 0 || i > size || n < 0 {
+		return 0, 0, false
+	}
+	if n > size-i {
+		n = size - i
+	}
+	return offs[i], offs[i+n], true
+}
+
+// toStr returns the value as a string, or raises TypeError.
+func (rt *Runtime) toStr(v Value) *RString {
+	if s, ok := v.(*RString); ok {
+		return s
+	}
+	rt.TypeMismatch(v, "String")
+	return nil
+}
+
+// QuoteString returns the string literal of s as String#inspect.
+func QuoteString(s []byte) string {
+	return quoteString(s, UTF8)
+}
+
+// quoteString returns the string literal of s in the encoding, where the
+// bytes which are not the characters are escaped as \xFF.
+func quoteString(s []byte, e *Encoding) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRune(s[i:])
+		if e != UTF8 && r >= utf8.RuneSelf {
+			r, size = utf8.RuneError, 1
+		}
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&buf, "\\x%02X", s[i])
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '#':
+			buf.WriteByte('#')
+			if i+1 < len(s) && (s[i+1] == '{' || s[i+1] == '$' || s[i+1] == '@') {
+				buf.Truncate(buf.Len() - 1)
+				buf.WriteString("\\#")
+			}
+		case r == '\n':
+			buf.WriteString("\\n")
+		case r == '\t':
+			buf.WriteString("\\t")
+		case r == '\r':
+			buf.WriteString("\\r")
+		case r == '\f':
+			buf.WriteString("\\f")
+		case r == '\v':
+			buf.WriteString("\\v")
+		case r == '\a':
+			buf.WriteString("\\a")
+		case r == '\b':
+			buf.WriteString("\\b")
+		case r == 0x1b:
+			buf.WriteString("\\e")
+		case r < 0x80 && !unicode.IsPrint(r):
+			fmt.Fprintf(&buf, "\\x%02X", r)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&buf, "\\u%04X", r)
+		default:
+			buf.WriteRune(r)
+		}
+		i += size
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+var operatorNames = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true, "**": true,
+	"==": true, "===": true, "!=": true, "=~": true, "!~": true, "<=>": true,
+	"<": true, "<=": true, ">": true, ">=": true, "<<": true, ">>": true,
+	"&": true, "|": true, "^": true, "~": true, "!": true, "+@": true, "-@": true,
+	"[]": true, "[]=": true,
+}
+
+// isSymbolName returns whether the name is written without quotes.
+func isSymbolName(name string) bool {
+	if operatorNames[name] {
+		return true
+	}
+	s := name
+	switch {
+	case strings.HasPrefix(s, "@@"):
+		s = s[2:]
+	case strings.HasPrefix(s, "@"), strings.HasPrefix(s, "$"):
+		s = s[1:]
+	}
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r >= utf8.RuneSelf || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			continue
+		}
+		if i == len(s)-1 && len(s) == len(name) && (r == '?' || r == '!' || r == '=') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// strEqual returns whether the strings are equal, where the strings of the
+// different encodings are equal only if they are ASCII only.
+func strEqual(a, b *RString) bool {
+	return bytes.Equal(a.B, b.B) && (a.Encoding() == b.Encoding() || isASCII(a.B))
+}
+
+func (rt *Runtime) initString() {
+	s := rt.String
+	rt.DefineMethod(s, "initialize", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 1 {
+			rt.CheckArity(len(args), 1)
+		}
+		str := self.(*RString)
+		if len(args) == 0 {
+			if len(str.B) == 0 {
+				str.Enc = Binary
+			}
+			return Nil
+		}
+		o := rt.toStr(args[0])
+		str.B, str.Enc = append([]byte(nil), o.B...), o.Enc
+		return Nil
+	})
+	rt.DefineMethod(s, "to_s", 0, stringSelf)
+	rt.DefineMethod(s, "to_str", 0, stringSelf)
+	rt.DefineMethod(s, "inspect", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str := self.(*RString)
+		return rt.NewString(quoteString(str.B, str.Encoding()))
+	})
+	rt.DefineMethod(s, "dump", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str := self.(*RString)
+		return str.derive([]byte(quoteString(str.B, str.Encoding())))
+	})
+	rt.DefineMethod(s, "to_sym", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return SymbolOf(string(self.(*RString).B))
+	})
+	rt.DefineMethod(s, "to_i", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		base := 10
+		if len(args) > 0 {
+			base = int(rt.toInt(args[0]))
+			if base < 2 || base > 36 {
+				rt.Raise(rt.ArgumentError, "invalid radix %d", base)
+			}
+		}
+		return strToInt(self.(*RString).B, base)
+	})
+	rt.DefineMethod(s, "to_f", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		f, _ := strconv.ParseFloat(string(bytes.TrimSpace(self.(*RString).B)), 64)
+		return Float(f)
+	})
+	rt.DefineMethod(s, "==", 1, stringEqual)
+	rt.DefineMethod(s, "===", 1, stringEqual)
+	rt.DefineMethod(s, "eql?", 1, stringEqual)
+	rt.DefineMethod(s, "hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Fixnum(stringHash(self.(*RString)))
+	})
+	rt.DefineMethod(s, "<=>", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		o, ok := args[0].(*RString)
+		if !ok {
+			return Nil
+		}
+		return Fixnum(bytes.Compare(self.(*RString).B, o.B))
+	})
+	rt.defineCompare(s)
+	rt.DefineMethod(s, "+", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str, o := self.(*RString), rt.toStr(args[0])
+		enc := rt.compatibleEncoding(str, o)
+		b := make([]byte, 0, len(str.B)+len(o.B))
+		return &RString{B: append(append(b, str.B...), o.B...), Enc: enc}
+	})
+	rt.DefineMethod(s, "*", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		n := rt.toInt(args[0])
+		if n < 0 {
+			rt.Raise(rt.ArgumentError, "negative argument")
+		}
+		str := self.(*RString)
+		if len(str.B) > 0 && int64(n) > int64(maxStringSize/len(str.B)) {
+			rt.Raise(rt.ArgumentError, "argument too big")
+		}
+		return str.derive(bytes.Repeat(str.B, int(n)))
+	})
+	rt.DefineMethod(s, "%", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if a, ok := args[0].(*RArray); ok {
+			return rt.sprintf(self.(*RString), a.Elems)
+		}
+		return rt.sprintf(self.(*RString), args)
+	})
+	rt.DefineMethod(s, "<<", 1, stringConcat)
+	rt.DefineMethod(s, "concat", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		for _, arg := range append([]Value(nil), args...) {
+			if arg == self {
+				arg = rt.Dup(arg)
+			}
+			stringConcat(rt, self, []Value{arg}, nil)
+		}
+		return self
+	})
+	rt.DefineMethod(s, "replace", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		str, o := self.(*RString), rt.toStr(args[0])
+		str.B, str.Enc = append([]byte(nil), o.B...), o.Enc
+		return self
+	})
+	rt.DefineMethod(s, "+@", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if rt.Frozen(self) {
+			return rt.Dup(self)
+		}
+		return self
+	})
+	rt.DefineMethod(s, "-@", 0, stringDedup)
+	rt.DefineMethod(s, "dedup", 0, stringDedup)
+	rt.DefineMethod(s, "length", 0, stringLength)
+	rt.DefineMethod(s, "size", 0, stringLength)
+	rt.DefineMethod(s, "bytesize", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Fixnum(len(self.(*RString).B))
+	})
+	rt.DefineMethod(s, "empty?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(len(self.(*RString).B) == 0)
+	})
+	rt.DefineMethod(s, "[]", -2, stringAref)
+	rt.DefineMethod(s, "slice", -2, stringAref)
+	rt.DefineMethod(s, "[]=", -3, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 3 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 2..3)", len(args))
+		}
+		rt.CheckFrozen(self)
+		str, val := self.(*RString), rt.toStr(args[len(args)-1])
+		start, end := rt.strIndex(str, args[:len(args)-1])
+		enc := rt.compatibleEncoding(str, val)
+		b := make([]byte, 0, len(str.B)-(end-start)+len(val.B))
+		b = append(append(append(b, str.B[:start]...), val.B...), str.B[end:]...)
+		str.B, str.Enc = b, enc
+		return val
+	})
+	rt.DefineMethod(s, "byteslice", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 2 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+		}
+		str := self.(*RString)
+		i, n := int(rt.toInt(args[0])), 1
+		if len(args) == 2 {
+			n = int(rt.toInt(args[1]))
+		}
+		if i < 0 {
+			i += len(str.B)
+		}
+		if i < 0 || i > len(str.B) || n < 0 || len(args) == 1 && i == len(str.B) {
+			return Nil
+		}
+		if n > len(str.B)-i {
+			n = len(str.B) - i
+		}
+		return str.derive(append([]byte(nil), str.B[i:i+n]...))
+	})
+	rt.DefineMethod(s, "getbyte", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		b := self.(*RString).B
+		i := int(rt.toInt(args[0]))
+		if i < 0 {
+			i += len(b)
+		}
+		if i < 0 || i >= len(b) {
+			return Nil
+		}
+		return Fixnum(b[i])
+	})
+	rt.DefineMethod(s, "bytes", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var elems []Value
+		for _, c := range self.(*RString).B {
+			elems = append(elems, Fixnum(c))
+		}
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(s, "each_byte", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		str := self.(*RString)
+		for i := 0; i < len(str.B); i++ {
+			rt.Yield(blk, Fixnum(str.B[i]))
+		}
+		return self
+	})
+	rt.DefineMethod(s, "chars", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewArray(stringChars(self.(*RString)))
+	})
+	rt.DefineMethod(s, "each_char", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		for _, c := range stringChars(self.(*RString)) {
+			rt.Yield(blk, c)
+		}
+		return self
+	})
+	rt.DefineMethod(s, "codepoints", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		var elems []Value
+		for _, c := range stringChars(self.(*RString)) {
+			elems = append(elems, rt.strOrd(c.(*RString)))
+		}
+		return rt.NewArray(elems)
+	})
+	rt.DefineMethod(s, "ord", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.strOrd(self.(*RString))
+	})
+	for _, name := range []string{"succ", "next"} {
+		rt.DefineMethod(s, name, 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			str := self.(*RString)
+			return str.derive(strSucc(str.B, str.Encoding()))
+		})
+		rt.DefineMethod(s, name+"!", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			rt.CheckFrozen(self)
+			str := self.(*RString)
+			str.B = strSucc(str.B, str.Encoding())
+			return self
+		})
+	}
+	rt.DefineMethod(s, "upto", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 2 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+		}
+		if blk == nil {
+			rt.NotImplemented("Enumerator")
+		}
+		excl := len(args) == 2 && Truthy(args[1])
+		rt.strUpto(self.(*RString), rt.toStr(args[0]), excl, func(v Value) bool {
+			rt.Yield(blk, v)
+			return true
+		})
+		return self
+	})
+
+	// encodings
+	rt.DefineMethod(s, "encoding", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.EncodingObject(self.(*RString).Encoding())
+	})
+	rt.DefineMethod(s, "force_encoding", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		self.(*RString).Enc = rt.toEncoding(args[0])
+		return self
+	})
+	rt.DefineMethod(s, "valid_encoding?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str := self.(*RString)
+		return Bool(str.Encoding().Valid(str.B))
+	})
+	rt.DefineMethod(s, "ascii_only?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(isASCII(self.(*RString).B))
+	})
+	rt.DefineMethod(s, "b", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return &RString{B: append([]byte(nil), self.(*RString).B...), Enc: Binary}
+	})
+	rt.DefineMethod(s, "encode", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.strEncode(self.(*RString), args)
+	})
+	rt.DefineMethod(s, "encode!", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		str := self.(*RString)
+		e := rt.strEncode(str, args)
+		str.B, str.Enc = e.B, e.Enc
+		return self
+	})
+	rt.DefineMethod(s, "scrub", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.strScrub(self.(*RString), args)
+	})
+	rt.DefineMethod(s, "scrub!", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.CheckFrozen(self)
+		str := self.(*RString)
+		str.B = rt.strScrub(str, args).B
+		return self
+	})
+	rt.DefineMethod(s, "unicode_normalize", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		rt.NotImplemented("String#unicode_normalize")
+		return nil
+	})
+
+	// case mappings
+	for _, m := range []caseMapping{caseUpper, caseLower, caseCapitalize, caseSwap} {
+		m, name := m, m.String()
+		rt.DefineMethod(s, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			str := self.(*RString)
+			return str.derive(caseMap(str.B, str.Encoding(), m, rt.caseOptions(m, args)))
+		})
+		rt.DefineMethod(s, name+"!", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			rt.CheckFrozen(self)
+			str := self.(*RString)
+			b := caseMap(str.B, str.Encoding(), m, rt.caseOptions(m, args))
+			if bytes.Equal(b, str.B) {
+				return Nil
+			}
+			str.B = b
+			return self
+		})
+	}
+
+	// substitutions
+	rt.DefineMethod(s, "sub", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str := self.(*RString)
+		if r := rt.strSub(str, args, blk, false); r != nil {
+			return r
+		}
+		return str.derive(append([]byte(nil), str.B...))
+	})
+	rt.DefineMethod(s, "gsub", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str := self.(*RString)
+		if r := rt.strSub(str, args, blk, true); r != nil {
+			return r
+		}
+		return str.derive(append([]byte(nil), str.B...))
+	})
+	rt.DefineMethod(s, "sub!", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.strSubBang(self.(*RString), args, blk, false)
+	})
+	rt.DefineMethod(s, "gsub!", -2, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.strSubBang(self.(*RString), args, blk, true)
+	})
+	rt.DefineMethod(s, "split", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if len(args) > 2 {
+			rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..2)", len(args))
+		}
+		str := self.(*RString)
+		sep, limit := Value(Nil), 0
+		if len(args) > 0 {
+			sep = args[0]
+		}
+		if len(args) > 1 {
+			limit = int(rt.toInt(args[1]))
+		}
+		fields := rt.strSplit(str, sep, limit)
+		if blk == nil {
+			return rt.NewArray(fields)
+		}
+		for _, f := range fields {
+			rt.Yield(blk, f)
+		}
+		return self
+	})
+
+	rt.DefineMethod(s, "reverse", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str := self.(*RString)
+		offs := str.Encoding().offsets(str.B)
+		b := make([]byte, 0, len(str.B))
+		for i := len(offs) - 1; i > 0; i-- {
+			b = append(b, str.B[offs[i-1]:offs[i]]...)
+		}
+		return str.derive(b)
+	})
+	rt.DefineMethod(s, "strip", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str := self.(*RString)
+		return str.derive(append([]byte(nil), bytes.Trim(str.B, " \t\n\v\f\r\x00")...))
+	})
+	rt.DefineMethod(s, "chomp", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		str := self.(*RString)
+		b := str.B
+		switch {
+		case bytes.HasSuffix(b, []byte("\r\n")):
+			b = b[:len(b)-2]
+		case bytes.HasSuffix(b, []byte("\n")), bytes.HasSuffix(b, []byte("\r")):
+			b = b[:len(b)-1]
+		}
+		return str.derive(append([]byte(nil), b...))
+	})
+	rt.DefineMethod(s, "include?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(bytes.Contains(self.(*RString).B, rt.toStr(args[0]).B))
+	})
+	rt.DefineMethod(s, "start_with?", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		for _, arg := range args {
+			if bytes.HasPrefix(self.(*RString).B, rt.toStr(arg).B) {
+				return True
+			}
+		}
+		return False
+	})
+	rt.DefineMethod(s, "end_with?", -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		for _, arg := range args {
+			if bytes.HasSuffix(self.(*RString).B, rt.toStr(arg).B) {
+				return True
+			}
+		}
+		return False
+	})
+	rt.definePrivate(rt.Kernel, "format", -2, kernelFormat)
+	rt.definePrivate(rt.Kernel, "sprintf", -2, kernelFormat)
+}
+
+// maxStringSize is the limit of the size of the repeated strings.
+const maxStringSize = 1 << 31
+
+func stringSelf(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return self
+}
+
+// stringHash returns the hash of the string, which depends on the
+// encoding unless the string is ASCII only.
+func stringHash(s *RString) uint64 {
+	if isASCII(s.B) {
+		return hashBytes(s.B)
+	}
+	return hashBytes(append([]byte(s.Encoding().Name+":"), s.B...))
+}
+
+func stringEqual(rt *Runtime, self Value, args []Value, blk Value) Value {
+	o, ok := args[0].(*RString)
+	return Bool(ok && strEqual(self.(*RString), o))
+}
+
+func stringDedup(rt *Runtime, self Value, args []Value, blk Value) Value {
+	if rt.Frozen(self) {
+		return self
+	}
+	dup := rt.Dup(self)
+	rt.Freeze(dup)
+	return dup
+}
+
+func stringLength(rt *Runtime, self Value, args []Value, blk Value) Value {
+	str := self.(*RString)
+	return Fixnum(str.Encoding().charCount(str.B))
+}
+
+func stringChars(s *RString) []Value {
+	var chars []Value
+	offs := s.Encoding().offsets(s.B)
+	for i := 1; i < len(offs); i++ {
+		chars = append(chars, s.derive(append([]byte(nil), s.B[offs[i-1]:offs[i]]...)))
+	}
+	return chars
+}
+
+func stringConcat(rt *Runtime, self Value, args []Value, blk Value) Value {
+	rt.CheckFrozen(self)
+	s := self.(*RString)
+	switch v := args[0].(type) {
+	case Fixnum:
+		e := s.Encoding()
+		switch {
+		case v < 0 || v > 0xffffffff:
+			rt.Raise(rt.RangeError, "%d out of char range", v)
+		case e == UTF8:
+			if !utf8.ValidRune(rune(v)) {
+				rt.Raise(rt.RangeError, "invalid codepoint 0x%X in UTF-8", int64(v))
+			}
+			s.B = append(s.B, string(rune(v))...)
+		case v > 0xff:
+			rt.Raise(rt.RangeError, "%d out of char range", v)
+		default:
+			if e == USASCII && v >= utf8.RuneSelf {
+				s.Enc = Binary
+			}
+			s.B = append(s.B, byte(v))
+		}
+	default:
+		o := rt.toStr(v)
+		s.Enc = rt.compatibleEncoding(s, o)
+		s.B = append(s.B, o.B...)
+	}
+	return self
+}
+
+func stringAref(rt *Runtime, self Value, args []Value, blk Value) Value {
+	if len(args) > 2 {
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 1..2)", len(args))
+	}
+	s := self.(*RString)
+	if len(args) == 2 {
+		start, end, ok := s.charRange(int(rt.toInt(args[0])), int(rt.toInt(args[1])))
+		if !ok {
+			return Nil
+		}
+		return s.derive(append([]byte(nil), s.B[start:end]...))
+	}
+	if o, ok := args[0].(*RString); ok {
+		if !bytes.Contains(s.B, o.B) {
+			return Nil
+		}
+		return s.derive(append([]byte(nil), o.B...))
+	}
+	if r, ok := args[0].(*RRange); ok {
+		i, n, ok := rt.rangeBegLen(r, s.Encoding().charCount(s.B), 0)
+		if !ok {
+			return Nil
+		}
+		start, end, _ := s.charRange(i, n)
+		return s.derive(append([]byte(nil), s.B[start:end]...))
+	}
+	start, end, ok := s.charRange(int(rt.toInt(args[0])), 1)
+	if !ok || start == end {
+		return Nil
+	}
+	return s.derive(append([]byte(nil), s.B[start:end]...))
+}
+
+// strIndex returns the byte range of the index of String#[]=, which is a
+// substring, a character index, a range or an index and a length.
+func (rt *Runtime) strIndex(s *RString, index []Value) (int, int) {
+	if len(index) == 2 {
+		i, n := int(rt.toInt(index[0])), int(rt.toInt(index[1]))
+		if n < 0 {
+			rt.Raise(rt.IndexError, "negative length %d", n)
+		}
+		start, end, ok := s.charRange(i, n)
+		if !ok {
+			rt.Raise(rt.IndexError, "index %d out of string", i)
+		}
+		return start, end
+	}
+	if o, ok := index[0].(*RString); ok {
+		i := bytes.Index(s.B, o.B)
+		if i < 0 {
+			rt.Raise(rt.IndexError, "string not matched")
+		}
+		return i, i + len(o.B)
+	}
+	if r, ok := index[0].(*RRange); ok {
+		i, n, _ := rt.rangeBegLen(r, s.Encoding().charCount(s.B), 2)
+		start, end, _ := s.charRange(i, n)
+		return start, end
+	}
+	i := int(rt.toInt(index[0]))
+	start, end, ok := s.charRange(i, 1)
+	if !ok {
+		rt.Raise(rt.IndexError, "index %d out of string", i)
+	}
+	return start, end
+}
+
+// strOrd returns the code point of the first character.
+func (rt *Runtime) strOrd(s *RString) Value {
+	if len(s.B) == 0 {
+		rt.Raise(rt.ArgumentError, "empty string")
+	}
+	e := s.Encoding()
+	if e != UTF8 {
+		return Fixnum(s.B[0])
+	}
+	r, n := utf8.DecodeRune(s.B)
+	if r == utf8.RuneError && n <= 1 {
+		rt.Raise(rt.ArgumentError, "invalid byte sequence in %s", e.Name)
+	}
+	return Fixnum(r)
+}
+
+// strEncode converts the string as String#encode(to = UTF-8, from = the
+// encoding of the string, **opts).
+func (rt *Runtime) strEncode(s *RString, args []Value) *RString {
+	var opts transcodeOpts
+	if n := len(args); n > 0 && rt.RespondTo(args[n-1], "to_hash") {
+		opts = rt.transcodeOptions(rt.Send(args[n-1], "to_hash"))
+		args = args[:n-1]
+	}
+	if len(args) > 2 {
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..2)", len(args))
+	}
+	from, to := s.Encoding(), UTF8
+	if len(args) > 0 {
+		to = rt.toEncoding(args[0])
+	}
+	if len(args) > 1 {
+		from = rt.toEncoding(args[1])
+	}
+	if from == to && opts.invalid {
+		var repl []Value
+		if opts.replace != nil {
+			repl = []Value{&RString{B: opts.replace}}
+		}
+		return rt.strScrub(&RString{B: s.B, Enc: to}, repl)
+	}
+	return &RString{B: rt.transcode(s.B, from, to, opts), Enc: to}
+}
+
+// strScrub replaces the invalid bytes of the string.
+func (rt *Runtime) strScrub(s *RString, args []Value) *RString {
+	if len(args) > 1 {
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 0..1)", len(args))
+	}
+	e := s.Encoding()
+	repl := []byte("?")
+	if e == UTF8 {
+		repl = []byte("�")
+	}
+	if len(args) == 1 && args[0] != Nil {
+		repl = rt.toStr(args[0]).B
+	}
+	var b []byte
+	for i := 0; i < len(s.B); {
+		n := e.charLen(s.B[i:])
+		if c := s.B[i : i+n]; e.Valid(c) {
+			b = append(b, c...)
+		} else {
+			b = append(b, repl...)
+		}
+		i += n
+	}
+	return s.derive(b)
+}
+
+// subPattern returns the pattern of sub, gsub and split. Regexps are not
+// supported yet.
+func (rt *Runtime) subPattern(v Value) *RString {
+	s, ok := v.(*RString)
+	if !ok {
+		rt.Raise(rt.TypeError, "wrong argument type %s (expected Regexp)", rt.ClassOf(v).RealClass().Name)
+	}
+	return s
+}
+
+// strSub replaces the first or all occurrences of the pattern with the
+// replacement string, the values of the hash, or the results of the block.
+// It returns nil if the pattern is not found.
+func (rt *Runtime) strSub(s *RString, args []Value, blk Value, global bool) *RString {
+	if len(args) > 2 || len(args) == 1 && blk == nil && !global {
+		rt.Raise(rt.ArgumentError, "wrong number of arguments (given %d, expected 2)", len(args))
+	}
+	pat := rt.subPattern(args[0])
+	var repl func(b []byte, i, j int) []byte
+	switch {
+	case len(args) == 2 && rt.RespondTo(args[1], "to_hash"):
+		h := rt.Send(args[1], "to_hash")
+		repl = func(b []byte, i, j int) []byte {
+			return []byte(rt.ToS(rt.Send(h, "[]", s.derive(append([]byte(nil), b[i:j]...)))))
+		}
+	case len(args) == 2:
+		r := rt.toStr(args[1])
+		rt.compatibleEncoding(s, r)
+		repl = func(b []byte, i, j int) []byte {
+			return expandReplacement(r.B, b, i, j)
+		}
+	case blk != nil:
+		repl = func(b []byte, i, j int) []byte {
+			return []byte(rt.ToS(rt.Yield(blk, s.derive(append([]byte(nil), b[i:j]...)))))
+		}
+	default:
+		rt.NotImplemented("Enumerator")
+	}
+
+	b := s.B
+	var out []byte
+	last, found := 0, false
+	for i := 0; i <= len(b); {
+		j := bytes.Index(b[i:], pat.B)
+		if j < 0 {
+			break
+		}
+		found = true
+		start, end := i+j, i+j+len(pat.B)
+		out = append(append(out, b[last:start]...), repl(b, start, end)...)
+		last, i = end, end
+		if len(pat.B) == 0 {
+			if end == len(b) {
+				break
+			}
+			n := s.Encoding().charLen(b[end:])
+			out = append(out, b[end:end+n]...)
+			last, i = end+n, end+n
+		}
+		if !global {
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+	return s.derive(append(out, b[last:]...))
+}
+
+func (rt *Runtime) strSubBang(s *RString, args []Value, blk Value, global bool) Value {
+	rt.CheckFrozen(s)
+	r := rt.strSub(s, args, blk, global)
+	if r == nil {
+		return Nil
+	}
+	s.B = r.B
+	return s
+}
+
+// expandReplacement returns the replacement of b[i:j], where \0 and \& are
+// the matched string, \` is the prematch, \' is the postmatch and \\ is a
+// backslash. The groups \1 to \9 are empty for the string patterns.
+func expandReplacement(r, b []byte, i, j int) []byte {
+	if bytes.IndexByte(r, '\\') < 0 {
+		return r
+	}
+	var out []byte
+	for k := 0; k < len(r); k++ {
+		if r[k] != '\\' || k+1 == len(r) {
+			out = append(out, r[k])
+			continue
+		}
+		k++
+		switch c := r[k]; {
+		case c == '0' || c == '&':
+			out = append(out, b[i:j]...)
+		case c == '`':
+			out = append(out, b[:i]...)
+		case c == '\'':
+			out = append(out, b[j:]...)
+		case c == '\\':
+			out = append(out, '\\')
+		case '1' <= c && c <= '9':
+		default:
+			out = append(out, '\\', c)
+		}
+	}
+	return out
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || '\t' <= c && c <= '\r'
+}
+
+// strSplit splits the string by the separator as String#split. The
+// separator nil or " " splits by the runs of whitespaces ignoring the
+// leading ones, and "" splits into the characters. The positive limit is
+// the maximum number of the fields, and the trailing empty fields are
+// removed if the limit is 0.
+func (rt *Runtime) strSplit(s *RString, sep Value, limit int) []Value {
+	b := s.B
+	if limit == 1 {
+		if len(b) == 0 {
+			return nil
+		}
+		return []Value{s.derive(append([]byte(nil), b...))}
+	}
+	var fields [][]byte
+	full := func() bool {
+		return limit > 0 && len(fields) >= limit-1
+	}
+	var pat []byte
+	awk := sep == Nil
+	if !awk {
+		pat = rt.subPattern(sep).B
+		awk = string(pat) == " "
+	}
+	beg := 0
+	switch {
+	case awk:
+		skip, end := true, 0
+		for i := 0; i < len(b); i++ {
+			switch {
+			case skip && isSpace(b[i]):
+				beg = i + 1
+			case skip:
+				end, skip = i+1, false
+				if full() {
+					i = len(b)
+				}
+			case isSpace(b[i]):
+				fields = append(fields, b[beg:end])
+				skip, beg = true, i+1
+			default:
+				end = i + 1
+			}
+		}
+	case len(pat) == 0:
+		for beg < len(b) && !full() {
+			n := s.Encoding().charLen(b[beg:])
+			fields = append(fields, b[beg:beg+n])
+			beg += n
+		}
+	default:
+		for beg < len(b) && !full() {
+			j := bytes.Index(b[beg:], pat)
+			if j < 0 {
+				break
+			}
+			fields = append(fields, b[beg:beg+j])
+			beg += j + len(pat)
+		}
+	}
+	if len(b) > 0 && (limit != 0 || len(b) > beg) {
+		fields = append(fields, b[beg:])
+	}
+	if limit == 0 {
+		for len(fields) > 0 && len(fields[len(fields)-1]) == 0 {
+			fields = fields[:len(fields)-1]
+		}
+	}
+	var values []Value
+	for _, f := range fields {
+		values = append(values, s.derive(append([]byte(nil), f...)))
+	}
+	return values
+}
+
+func kernelFormat(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return rt.sprintf(rt.toStr(args[0]), args[1:])
+}
+
+// strSucc returns the successor of the string as String#succ. The rightmost
+// alphanumeric is incremented, carrying to the alphanumerics of the same
+// kind on its left, or the rightmost character if there are none.
+func strSucc(b []byte, e *Encoding) []byte {
+	b = append([]byte(nil), b...)
+	if len(b) == 0 {
+		return b
+	}
+	offs := e.offsets(b)
+	var carry []byte
+	pos := -1
+	var last byte // the last wrapped alphanumeric
+	sep := false  // whether a non-alphanumeric is right of the character
+	for i := len(offs) - 2; i >= 0; i-- {
+		c := b[offs[i]]
+		if offs[i+1]-offs[i] != 1 || !isAlnum(c) {
+			sep = true
+			continue
+		}
+		if sep && last != 0 && (isDigit(last) && !isDigit(c) || !isDigit(last) && isDigit(c)) {
+			break
+		}
+		sep = false
+		switch c {
+		case '9':
+			b[offs[i]], carry = '0', []byte{'1'}
+		case 'z':
+			b[offs[i]], carry = 'a', []byte{'a'}
+		case 'Z':
+			b[offs[i]], carry = 'A', []byte{'A'}
+		default:
+			b[offs[i]]++
+			return b
+		}
+		last, pos = b[offs[i]], offs[i]
+	}
+	if pos < 0 {
+		// no alphanumerics, so increment the characters
+		carry = []byte{1}
+		for i := len(offs) - 2; i >= 0; i-- {
+			c, wrapped := succChar(b[offs[i]:offs[i+1]], e)
+			b = append(append(append([]byte(nil), b[:offs[i]]...), c...), b[offs[i+1]:]...)
+			pos = offs[i]
+			if !wrapped {
+				return b
+			}
+		}
+	}
+	return append(append(append([]byte(nil), b[:pos]...), carry...), b[pos:]...)
+}
+
+// succChar returns the next character of c, which wraps around to the
+// first character of the same length.
+func succChar(c []byte, e *Encoding) ([]byte, bool) {
+	r, size := utf8.DecodeRune(c)
+	if e != UTF8 || r == utf8.RuneError && size < 2 {
+		n := append([]byte(nil), c...)
+		for i := len(n) - 1; i >= 0; i-- {
+			n[i]++
+			if n[i] != 0 {
+				return n, false
+			}
+		}
+		return n, true
+	}
+	min := [...]rune{0, 0, 0x80, 0x800, 0x10000}[size]
+	max := [...]rune{0, 0x7f, 0x7ff, 0xffff, utf8.MaxRune}[size]
+	r++
+	if r == 0xd800 {
+		r = 0xe000
+	}
+	if r > max {
+		return []byte(string(min)), true
+	}
+	return []byte(string(r)), false
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// strUpto calls f for the strings from b to e as String#upto until f
+// returns false. The single ASCII characters and the digits of the same
+// width are iterated by their codes.
+func (rt *Runtime) strUpto(b, e *RString, excl bool, f func(Value) bool) {
+	if len(b.B) == 1 && len(e.B) == 1 && b.B[0] < utf8.RuneSelf && e.B[0] < utf8.RuneSelf {
+		for c := b.B[0]; c < e.B[0] || !excl && c == e.B[0]; c++ {
+			if !f(b.derive([]byte{c})) {
+				return
+			}
+		}
+		return
+	}
+	if allDigits(b.B) && allDigits(e.B) {
+		i, err1 := strconv.ParseInt(string(b.B), 10, 64)
+		j, err2 := strconv.ParseInt(string(e.B), 10, 64)
+		if err1 == nil && err2 == nil {
+			for ; i < j || !excl && i == j; i++ {
+				if !f(&RString{B: []byte(fmt.Sprintf("%0*d", len(b.B), i)), Enc: USASCII}) || i == j {
+					return
+				}
+			}
+			return
+		}
+	}
+	n := bytes.Compare(b.B, e.B)
+	if n > 0 || excl && n == 0 {
+		return
+	}
+	after := strSucc(e.B, e.Encoding())
+	for s := b.B; !bytes.Equal(s, after); {
+		var next []byte
+		if excl || !bytes.Equal(s, e.B) {
+			next = strSucc(s, b.Encoding())
+		}
+		if !f(b.derive(append([]byte(nil), s...))) || next == nil {
+			return
+		}
+		s = next
+		if excl && bytes.Equal(s, e.B) || len(s) > len(e.B) || len(s) == 0 {
+			return
+		}
+	}
+}
+
+// strUptoEndless calls f for the successors of the string forever until f
+// returns false.
+func (rt *Runtime) strUptoEndless(b *RString, f func(Value) bool) {
+	if i, err := strconv.ParseInt(string(b.B), 10, 64); err == nil && allDigits(b.B) {
+		for ; ; i++ {
+			if !f(&RString{B: []byte(fmt.Sprintf("%0*d", len(b.B), i)), Enc: USASCII}) {
+				return
+			}
+		}
+	}
+	for s := b.B; f(b.derive(append([]byte(nil), s...))); {
+		s = strSucc(s, b.Encoding())
+	}
+}
+
+func allDigits(b []byte) bool {
+	for _, c := range b {
+		if !isDigit(c) {
+			return false
+		}
+	}
+	return len(b) > 0
+}
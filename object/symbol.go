@@ -0,0 +1,190 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ID is an interned name of methods, variables and symbols. The names are
+// interned in the global table shared by the runtimes, so that the names
+// are compared and hashed as the IDs.
+type ID uint32
+
+// symbols is the global symbol table. The ID 0 is reserved for no name.
+var symbols = struct {
+	sync.RWMutex
+	ids   map[string]ID
+	names []string
+}{ids: map[string]ID{}, names: []string{""}}
+
+// Intern returns the ID of the name, adding the name to the symbol table if
+// it is not interned yet.
+func Intern(name string) ID {
+	symbols.RLock()
+	id, ok := symbols.ids[name]
+	symbols.RUnlock()
+	if ok {
+		return id
+	}
+	symbols.Lock()
+	defer symbols.Unlock()
+	if id, ok := symbols.ids[name]; ok {
+		return id
+	}
+	id = ID(len(symbols.names))
+	symbols.names = append(symbols.names, name)
+	symbols.ids[name] = id
+	return id
+}
+
+// String returns the name of the ID.
+func (id ID) String() string {
+	symbols.RLock()
+	defer symbols.RUnlock()
+	return symbols.names[id]
+}
+
+// allIDs returns the interned IDs in the order of the interning.
+func allIDs() []ID {
+	symbols.RLock()
+	defer symbols.RUnlock()
+	ids := make([]ID, 0, len(symbols.names)-1)
+	for i := 1; i < len(symbols.names); i++ {
+		ids = append(ids, ID(i))
+	}
+	return ids
+}
+
+// Symbol is a Symbol, which is the ID of the name.
+type Symbol ID
+
+func (Symbol) value() {}
+
+// SymbolOf returns the symbol of the name.
+func SymbolOf(name string) Symbol {
+	return Symbol(Intern(name))
+}
+
+// Name returns the name of the symbol.
+func (s Symbol) Name() string {
+	return ID(s).String()
+}
+
+// SymbolName returns the name of a symbol or a string, or raises TypeError.
+func (rt *Runtime) SymbolName(v Value) string {
+	return rt.toID(v).String()
+}
+
+// toID returns the ID of a symbol or a string, or raises TypeError.
+func (rt *Runtime) toID(v Value) ID {
+	switch v := v.(type) {
+	case Symbol:
+		return ID(v)
+	case *RString:
+		return Intern(string(v.B))
+	}
+	rt.Raise(rt.TypeError, "%s is not a symbol nor a string", rt.Inspect(v))
+	return 0
+}
+
+// InspectSymbol returns the symbol literal such as :a or :"a b".
+func InspectSymbol(name string) string {
+	if isSymbolName(name) {
+		return ":" + name
+	}
+	return ":" + QuoteString([]byte(name))
+}
+
+func (rt *Runtime) initSymbol() {
+	y := rt.Symbol
+	rt.UndefMethod(rt.SingletonClassOf(y), Intern("new"))
+	rt.DefineMethod(rt.SingletonClassOf(y), "all_symbols", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		ids := allIDs()
+		list := make([]Value, len(ids))
+		for i, id := range ids {
+			list[i] = Symbol(id)
+		}
+		return rt.NewArray(list)
+	})
+	rt.DefineMethod(y, "to_s", 0, symbolToS)
+	rt.DefineMethod(y, "id2name", 0, symbolToS)
+	rt.DefineMethod(y, "name", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		s := rt.NewString(self.(Symbol).Name())
+		rt.Freeze(s)
+		return s
+	})
+	rt.DefineMethod(y, "to_sym", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return self
+	})
+	rt.DefineMethod(y, "to_proc", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.symbolProc(self.(Symbol))
+	})
+	rt.DefineMethod(y, "inspect", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return rt.NewString(InspectSymbol(self.(Symbol).Name()))
+	})
+	rt.DefineMethod(y, "length", 0, symbolLength)
+	rt.DefineMethod(y, "size", 0, symbolLength)
+	rt.DefineMethod(y, "empty?", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Bool(self.(Symbol).Name() == "")
+	})
+	rt.DefineMethod(y, "hash", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		return Fixnum(rt.hashOf(self))
+	})
+	rt.DefineMethod(y, "encoding", 0, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		if isASCII([]byte(self.(Symbol).Name())) {
+			return rt.EncodingObject(USASCII)
+		}
+		return rt.EncodingObject(UTF8)
+	})
+	rt.DefineMethod(y, "<=>", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		o, ok := args[0].(Symbol)
+		if !ok {
+			return Nil
+		}
+		return Fixnum(strings.Compare(self.(Symbol).Name(), o.Name()))
+	})
+	rt.defineCompare(y)
+	rt.DefineMethod(y, "casecmp", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		o, ok := args[0].(Symbol)
+		if !ok {
+			return Nil
+		}
+		a := caseMap([]byte(self.(Symbol).Name()), UTF8, caseLower, caseOpts{ascii: true})
+		b := caseMap([]byte(o.Name()), UTF8, caseLower, caseOpts{ascii: true})
+		return Fixnum(bytes.Compare(a, b))
+	})
+	rt.DefineMethod(y, "casecmp?", 1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+		o, ok := args[0].(Symbol)
+		if !ok {
+			return Nil
+		}
+		a := caseMap([]byte(self.(Symbol).Name()), UTF8, caseLower, caseOpts{fold: true})
+		b := caseMap([]byte(o.Name()), UTF8, caseLower, caseOpts{fold: true})
+		return Bool(bytes.Equal(a, b))
+	})
+
+	// the methods of the name
+	for _, name := range []string{"upcase", "downcase", "capitalize", "swapcase"} {
+		name := name
+		rt.DefineMethod(y, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			s := rt.Call(rt.NewString(self.(Symbol).Name()), Intern(name), args, nil, false)
+			return SymbolOf(string(rt.toStr(s).B))
+		})
+	}
+	for _, name := range []string{"[]", "slice", "start_with?", "end_with?"} {
+		name := name
+		rt.DefineMethod(y, name, -1, func(rt *Runtime, self Value, args []Value, blk Value) Value {
+			return rt.Call(rt.NewString(self.(Symbol).Name()), Intern(name), args, blk, false)
+		})
+	}
+}
+
+func symbolToS(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return rt.NewString(self.(Symbol).Name())
+}
+
+func symbolLength(rt *Runtime, self Value, args []Value, blk Value) Value {
+	return Fixnum(utf8.RuneCountInString(self.(Symbol).Name()))
+}
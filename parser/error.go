@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/harukasan/ringo/scanner"
+	"github.com/harukasan/ringo/token"
+)
+
+// Error holds a syntax error found by the parser or the scanner. Found and
+// Expected are set for the unexpected token, and End is the end of the
+// token. Filename, Line and Column are given by ParseFile; Line and Column
+// start at 1, and Column counts bytes. Labels point to the related
+// positions given by the scanner, such as the beginning of heredoc.
+type Error struct {
+	Pos      int
+	End      int
+	Filename string
+	Line     int
+	Column   int
+	Msg      string
+	Found    token.Token
+	Expected []token.Token
+	Labels   []scanner.Label
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%v at pos=%d", e.Msg, e.Pos)
+	}
+	if e.Filename == "" {
+		return fmt.Sprintf("%d:%d: %v", e.Line, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", e.Filename, e.Line, e.Column, e.Msg)
+}
+
+// ErrorList is a list of errors sorted by the position.
+type ErrorList []*Error
+
+// Error returns all of the errors, one per line.
+func (l ErrorList) Error() string {
+	if len(l) == 0 {
+		return "no errors"
+	}
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l ErrorList) Len() int           { return len(l) }
+func (l ErrorList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l ErrorList) Less(i, j int) bool { return l[i].Pos < l[j].Pos }
+
+// Err returns the list sorted by the position as an error, or nil if the list
+// is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	sort.Stable(l)
+	return l
+}
+
+// describe returns the description of token used in the error messages.
+func describe(t token.Token) string {
+	if text := t.Text(); text != "" {
+		return "'" + text + "'"
+	}
+	switch t {
+	case token.EOF:
+		return "end-of-input"
+	case token.NewLine:
+		return "new line"
+	case token.Illegal:
+		return "invalid token"
+	case token.IdentConst:
+		return "constant"
+	case token.IdentLocalVar, token.IdentLocalMethod:
+		return "identifier"
+	case token.IdentGlobalVar:
+		return "global variable"
+	case token.IdentInstanceVar:
+		return "instance variable"
+	case token.IdentClassVar:
+		return "class variable"
+	case token.InsertBegin:
+		return "'#{'"
+	case token.InsertEnd:
+		return "'}'"
+	case token.String, token.StringPart:
+		return "string literal"
+	case token.HeredocBegin:
+		return "heredoc"
+	}
+	if t.IsNumber() {
+		return "numeric literal"
+	}
+	if t.IsLiteral() {
+		return "literal"
+	}
+	return fmt.Sprintf("token(%d)", int(t))
+}
+
+// unexpectedMsg returns the message for the unexpected token as Ruby, such
+// as "syntax error, unexpected 'end', expecting ')'".
+func unexpectedMsg(found token.Token, expected []token.Token) string {
+	msg := "syntax error, unexpected " + describe(found)
+	if len(expected) > 0 {
+		names := make([]string, len(expected))
+		for i, t := range expected {
+			names[i] = describe(t)
+		}
+		msg += ", expecting " + strings.Join(names, " or ")
+	}
+	return msg
+}
@@ -0,0 +1,556 @@
+package parser
+
+import (
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/token"
+)
+
+// parseArg parses an expression which can be an argument of method call,
+// including single assignments and conditional operators.
+func (p *parser) parseArg() ast.Expr {
+	start := p.pos
+	x := p.parseBinary(token.Question.Precedence())
+	if !p.tok.IsAssignOp() {
+		return x
+	}
+	op, opPos := p.tok, p.pos
+	lhs := p.toLhs(x, opPos)
+	p.next()
+	p.skipNewLines()
+	var rhs ast.Expr
+	if op == token.Assign && p.tok == token.Mul {
+		s := p.parseSplat()
+		rhs = &ast.ArrayLit{Span: s.Span, Elems: []ast.Expr{s}}
+	} else {
+		rhs = p.parseArg()
+	}
+	return &ast.Assign{Span: p.span(start), Lhs: lhs, Op: op, Rhs: rhs}
+}
+
+// toLhs converts the expression to the left hand side of assignment. The
+// method call without receiver and arguments becomes a local variable.
+func (p *parser) toLhs(x ast.Expr, opPos int) ast.Expr {
+	switch x := x.(type) {
+	case *ast.LocalVar, *ast.InstanceVar, *ast.ClassVar, *ast.GlobalVar, *ast.Const, *ast.Index:
+		return x
+	case *ast.MultiAssign:
+		if x.Rhs == nil {
+			return x // nested group such as (b, c)
+		}
+	case *ast.Splat:
+		if x.Value != nil {
+			x.Value = p.toLhs(x.Value, opPos)
+		}
+		return x
+	case *ast.Call:
+		if x.Args != nil || x.Parens || x.Block != nil {
+			break
+		}
+		if x.Recv != nil {
+			return x // attribute assignment such as a.b = 1
+		}
+		if isLocalName(x.Name) {
+			p.scope.declare(x.Name)
+			return &ast.LocalVar{Span: x.Span, Name: x.Name}
+		}
+	}
+	p.error(opPos, "syntax error, unexpected assignment")
+	return nil
+}
+
+// isLocalName returns whether the name can be a local variable, which is not
+// a method name such as a? or a!.
+func isLocalName(name string) bool {
+	if name == "" {
+		return false
+	}
+	switch name[len(name)-1] {
+	case '?', '!', '=':
+		return false
+	}
+	return !token.IsUppercase(name[0])
+}
+
+// parseBinary parses binary operations whose precedence is prec1 or higher.
+func (p *parser) parseBinary(prec1 int) ast.Expr {
+	x := p.parseUnary()
+	for {
+		if p.isSignedNumber() && token.Plus.Precedence() >= prec1 {
+			p.splitSign()
+		}
+		op, opPos := p.tok, p.pos
+		prec := op.Precedence()
+		if prec == token.LowestPrec || prec < prec1 || op.IsAssignOp() {
+			return x
+		}
+		p.next()
+		switch op {
+		case token.Question:
+			p.skipNewLines()
+			then := p.parseArg()
+			p.skipNewLines()
+			p.expect(token.Colon)
+			p.skipNewLines()
+			els := p.parseArg()
+			x = &ast.Ternary{Span: p.span(x.Pos()), Cond: x, Then: then, Else: els}
+			continue
+		case token.Dot2, token.Dot3:
+			var y ast.Expr
+			if p.canBeginArg() {
+				y = p.parseBinary(prec + 1)
+			}
+			x = &ast.RangeLit{Span: p.span(x.Pos()), Low: x, High: y, Exclusive: op == token.Dot3}
+			continue
+		}
+		p.skipNewLines()
+		var y ast.Expr
+		if op.Associativity() == token.RightAssoc {
+			y = p.parseBinary(prec)
+		} else {
+			y = p.parseBinary(prec + 1)
+		}
+		x = p.binary(op, opPos, x, y)
+		if op.Associativity() == token.NonAssoc && p.tok.Precedence() == prec {
+			p.errorExpected()
+		}
+	}
+}
+
+func (p *parser) binary(op token.Token, opPos int, x, y ast.Expr) ast.Expr {
+	if n, ok := x.(*ast.NumberLit); ok && op == token.Pow && n.Lit[0] == '-' {
+		// -2 ** 2 is -(2 ** 2)
+		abs := &ast.NumberLit{Span: ast.Span{Start: n.Start + 1, Stop: n.Stop}, Kind: n.Kind, Lit: n.Lit[1:]}
+		pow := &ast.Binary{Span: p.span(abs.Start), Op: op, OpPos: opPos, X: abs, Y: y}
+		return &ast.Unary{Span: p.span(n.Start), Op: token.Minus, X: pow}
+	}
+	return &ast.Binary{Span: p.span(x.Pos()), Op: op, OpPos: opPos, X: x, Y: y}
+}
+
+// isSignedNumber returns whether the current token is a numeric literal with
+// a sign such as -1.
+func (p *parser) isSignedNumber() bool {
+	return p.tok.IsNumber() && len(p.lit) > 1 && (p.lit[0] == '-' || p.lit[0] == '+')
+}
+
+// splitSign splits the sign of numeric literal as a binary operator, such as
+// x -1 where x is a local variable.
+func (p *parser) splitSign() {
+	t := p.tokenInfo
+	op := tokenInfo{tok: token.Minus, pos: t.pos, end: t.pos + 1, space: t.space}
+	if t.lit[0] == '+' {
+		op.tok = token.Plus
+	}
+	num := tokenInfo{tok: t.tok, pos: t.pos + 1, end: t.end, lit: t.lit[1:]}
+	toks := make([]tokenInfo, 0, len(p.toks)+1)
+	toks = append(toks, p.toks[:p.i]...)
+	toks = append(toks, op, num)
+	p.toks = append(toks, p.toks[p.i+1:]...)
+	p.tokenInfo = p.toks[p.i]
+}
+
+// parseUnary parses an unary operation or a primary expression.
+func (p *parser) parseUnary() ast.Expr {
+	start := p.pos
+	switch p.tok {
+	case token.Not, token.Invert, token.Plus:
+		op := p.tok
+		p.next()
+		x := p.parseUnary()
+		return &ast.Unary{Span: p.span(start), Op: op, X: x}
+	case token.Minus:
+		if p.adjacent() && p.peek(1).tok == token.Gt {
+			return p.parsePostfix(p.parseLambda())
+		}
+		p.next()
+		x := p.parseBinary(token.Pow.Precedence())
+		return &ast.Unary{Span: p.span(start), Op: token.Minus, X: x}
+	case token.KeywordNot:
+		p.next()
+		x := p.parseUnary()
+		return &ast.Unary{Span: p.span(start), Op: token.KeywordNot, X: x}
+	case token.Dot2, token.Dot3:
+		// beginless range such as ..5
+		op := p.tok
+		p.next()
+		y := p.parseBinary(op.Precedence() + 1)
+		return &ast.RangeLit{Span: p.span(start), High: y, Exclusive: op == token.Dot3}
+	case token.KeywordDefined:
+		p.next()
+		var x ast.Expr
+		if p.tok == token.LParen && !p.space {
+			p.next()
+			p.skipNewLines()
+			x = p.parseExprStmt()
+			p.skipNewLines()
+			p.expectClosing(token.RParen)
+		} else {
+			x = p.parseArg()
+		}
+		return &ast.Defined{Span: p.span(start), X: x}
+	}
+	return p.parsePostfix(p.parsePrimary())
+}
+
+// parsePostfix parses method calls, constant references and element
+// references following the expression.
+func (p *parser) parsePostfix(x ast.Expr) ast.Expr {
+	for {
+		switch p.tok {
+		case token.NewLine:
+			// method chain beginning with dot in the next line
+			n := 1
+			for p.peek(n).tok == token.NewLine {
+				n++
+			}
+			if p.peek(n).tok != token.Dot {
+				return x
+			}
+			p.skipNewLines()
+		case token.Dot:
+			p.next()
+			p.skipNewLines()
+			x = p.parseMethodCall(x, token.Dot)
+		case token.Colon2:
+			p.next()
+			if p.tok == token.IdentConst && !(p.peek(1).tok == token.LParen && p.adjacent()) {
+				x = &ast.Const{Span: ast.Span{Start: x.Pos(), Stop: p.end}, Scope: x, Name: string(p.lit)}
+				p.next()
+				continue
+			}
+			x = p.parseMethodCall(x, token.Colon2)
+		case token.LBracket:
+			if p.space && !isIndexable(x) {
+				return x
+			}
+			p.next()
+			args := p.parseCallArgs(token.RBracket)
+			p.expectClosing(token.RBracket)
+			x = &ast.Index{Span: p.span(x.Pos()), Recv: x, Args: args}
+		case token.ElementRef:
+			if p.space {
+				return x
+			}
+			p.next()
+			x = &ast.Index{Span: p.span(x.Pos()), Recv: x}
+		default:
+			return x
+		}
+	}
+}
+
+// isIndexable returns whether the [ following the expression with spaces is
+// an element reference. It is an argument of the command call such as
+// puts [1] if the expression is a method call.
+func isIndexable(x ast.Expr) bool {
+	if c, ok := x.(*ast.Call); ok {
+		return c.Parens || c.Args != nil || c.Block != nil
+	}
+	return true
+}
+
+// parseMethodCall parses the method call after the dot or ::.
+func (p *parser) parseMethodCall(recv ast.Expr, op token.Token) ast.Expr {
+	call := &ast.Call{Recv: recv, Op: op}
+	switch {
+	case p.tok == token.LParen:
+		call.Name = "call" // a.()
+	case p.tok.IsIdent() || p.tok.IsKeyword() || p.tok.IsOperatorMethod():
+		call.Name = p.name()
+		p.next()
+	default:
+		p.errorExpected(token.IdentLocalVar)
+	}
+	p.parseCallRest(call)
+	call.Span = p.span(recv.Pos())
+	return call
+}
+
+// name returns the name of the current token.
+func (p *parser) name() string {
+	if text := p.tok.Text(); text != "" {
+		return text
+	}
+	return string(p.lit)
+}
+
+// parseCallRest parses the arguments and the block of method call.
+func (p *parser) parseCallRest(call *ast.Call) {
+	if p.tok == token.LParen && !p.space {
+		p.next()
+		noDo := p.noDo
+		p.noDo = 0
+		call.Args = p.parseCallArgs(token.RParen)
+		if call.Args == nil {
+			call.Args = []ast.Expr{}
+		}
+		p.noDo = noDo
+		p.expectClosing(token.RParen)
+		call.Parens = true
+	} else if p.isCommandArgStart() {
+		p.noDo++
+		call.Args = p.parseCallArgs(token.None)
+		p.noDo--
+	}
+	switch {
+	case p.tok == token.LBrace:
+		call.Block = p.parseBlock()
+	case p.tok == token.KeywordDo && p.noDo == 0:
+		call.Block = p.parseBlock()
+	}
+}
+
+// isCommandArgStart returns whether the current token begins the arguments
+// of method call without parentheses, such as 1 of puts 1.
+func (p *parser) isCommandArgStart() bool {
+	return p.space && p.canBeginArg()
+}
+
+// canBeginArg returns whether the current token can begin an argument.
+func (p *parser) canBeginArg() bool {
+	t := p.tok
+	if t.IsNumber() || t.IsIdent() {
+		return true
+	}
+	switch t {
+	case token.String, token.StringPart, token.Symbol, token.HeredocBegin,
+		token.WordsBegin, token.SymbolsBegin, token.RegexpBegin, token.CommandBegin,
+		token.LParen, token.LBracket, token.ElementRef, token.Not, token.Invert,
+		token.KeywordNil, token.KeywordTrue, token.KeywordFalse, token.KeywordSelf,
+		token.KeywordLINE, token.KeywordFILE, token.KeywordENCODING,
+		token.KeywordNot, token.KeywordDefined, token.KeywordDef,
+		token.KeywordSuper, token.KeywordYield, token.KeywordCase, token.KeywordBegin,
+		token.Illegal:
+		return true
+	case token.Colon, token.Colon2, token.Mul, token.Pow, token.Amp, token.Minus:
+		// such as :a, ::A, *a, **h, &b, -a, or ->{}
+		return p.adjacent()
+	}
+	return false
+}
+
+// parseCallArgs parses the arguments of method call until the closing token,
+// or the end of arguments without parentheses if close is None. The labeled
+// arguments such as a: 1 are gathered into a hash.
+func (p *parser) parseCallArgs(close token.Token) []ast.Expr {
+	var args []ast.Expr
+	var hash *ast.HashLit
+	var block ast.Expr
+	for {
+		if close != token.None {
+			p.skipNewLines()
+			if p.tok == close {
+				break
+			}
+		}
+		if block != nil {
+			p.errorExpected(close)
+		}
+		start := p.pos
+		switch {
+		case p.tok == token.Mul:
+			args = append(args, p.parseSplat())
+		case p.tok == token.Amp:
+			p.next()
+			value := p.parseArg()
+			block = &ast.BlockPass{Span: p.span(start), Value: value}
+		case p.tok == token.Pow || p.isLabel():
+			hash = p.addPair(hash, p.parsePair())
+		default:
+			x := p.parseArg()
+			if p.tok == token.Arrow {
+				hash = p.addPair(hash, p.parsePairValue(x))
+				break
+			}
+			if hash != nil {
+				p.errorExpected(token.Arrow)
+			}
+			args = append(args, x)
+		}
+		if !p.got(token.Comma) {
+			break
+		}
+		p.skipNewLines()
+	}
+	if close != token.None {
+		p.skipNewLines()
+	}
+	if hash != nil {
+		args = append(args, hash)
+	}
+	if block != nil {
+		args = append(args, block)
+	}
+	return args
+}
+
+func (p *parser) addPair(hash *ast.HashLit, pair *ast.Pair) *ast.HashLit {
+	if hash == nil {
+		hash = &ast.HashLit{Span: ast.Span{Start: pair.Start}}
+	}
+	hash.Pairs = append(hash.Pairs, pair)
+	hash.Stop = pair.Stop
+	return hash
+}
+
+func (p *parser) parseSplat() *ast.Splat {
+	start := p.pos
+	double := p.tok == token.Pow
+	p.next()
+	value := p.parseArg()
+	return &ast.Splat{Span: p.span(start), Double: double, Value: value}
+}
+
+// parseBlock parses the block beginning with { or do.
+func (p *parser) parseBlock() *ast.Block {
+	start := p.pos
+	braces := p.tok == token.LBrace
+	p.next()
+
+	outer, noDo := p.scope, p.noDo
+	p.pushScope(true)
+	p.noDo = 0
+	defer func() {
+		p.popScope(outer)
+		p.noDo = noDo
+	}()
+
+	var params *ast.Params
+	switch p.tok {
+	case token.Or:
+		pstart := p.pos
+		p.next()
+		params = p.parseParams(token.Or, token.Or.Precedence()+1)
+		p.expect(token.Or)
+		params.Span = p.span(pstart)
+	case token.OrOperator: // ||
+		params = &ast.Params{Span: ast.Span{Start: p.pos, Stop: p.end}}
+		p.next()
+	}
+	var body *ast.BodyStmt
+	if braces {
+		stmts := p.parseStmts(token.RBrace)
+		body = &ast.BodyStmt{Span: stmts.Span, Body: stmts}
+		p.expectClosing(token.RBrace)
+	} else {
+		body = p.parseBodyStmt()
+		p.expectClosing(token.KeywordEnd)
+	}
+	return &ast.Block{Span: p.span(start), Params: params, Body: body, Braces: braces}
+}
+
+// parseLambda parses the lambda literal such as ->(x) { x }. It is parsed as
+// a call of lambda method with the block.
+func (p *parser) parseLambda() ast.Expr {
+	start := p.pos
+	p.next() // -
+	p.next() // >
+
+	outer := p.scope
+	p.pushScope(true)
+	defer p.popScope(outer)
+
+	var params *ast.Params
+	switch {
+	case p.tok == token.LParen:
+		pstart := p.pos
+		p.next()
+		params = p.parseParams(token.RParen, token.Question.Precedence())
+		p.expectClosing(token.RParen)
+		params.Span = p.span(pstart)
+	case p.tok.IsIdent() || p.tok == token.Mul || p.tok == token.Amp:
+		pstart := p.pos
+		params = p.parseParams(token.LBrace, token.Question.Precedence())
+		params.Span = p.span(pstart)
+	}
+	bstart := p.pos
+	braces := p.tok == token.LBrace
+	var body *ast.BodyStmt
+	switch p.tok {
+	case token.LBrace:
+		p.next()
+		stmts := p.parseStmts(token.RBrace)
+		body = &ast.BodyStmt{Span: stmts.Span, Body: stmts}
+		p.expectClosing(token.RBrace)
+	case token.KeywordDo:
+		p.next()
+		body = p.parseBodyStmt()
+		p.expectClosing(token.KeywordEnd)
+	default:
+		p.errorExpected(token.LBrace, token.KeywordDo)
+	}
+	block := &ast.Block{Span: p.span(bstart), Params: params, Body: body, Braces: braces}
+	return &ast.Call{Span: p.span(start), Name: "lambda", Block: block}
+}
+
+// parseParams parses the parameters until the closing token. The default
+// values are parsed as binary operations of the precedence prec or higher, so
+// that | of block parameters is not parsed as an operator.
+func (p *parser) parseParams(close token.Token, prec int) *ast.Params {
+	params := &ast.Params{Span: ast.Span{Start: p.pos, Stop: p.pos}}
+	rest := false
+	for {
+		if close != token.NewLine {
+			p.skipNewLines()
+		}
+		if p.tok == close || p.tok == token.NewLine || p.tok == token.EOF {
+			break
+		}
+		start := p.pos
+		param := &ast.Param{}
+		switch p.tok {
+		case token.Mul, token.Pow, token.Amp:
+			param.Kind = map[token.Token]ast.ParamKind{
+				token.Mul: ast.RestParam,
+				token.Pow: ast.KeywordRestParam,
+				token.Amp: ast.BlockParam,
+			}[p.tok]
+			rest = rest || p.tok == token.Mul
+			p.next()
+			if p.tok == token.IdentLocalVar {
+				param.Name = string(p.lit)
+				p.next()
+			}
+		case token.IdentLocalVar:
+			param.Name = string(p.lit)
+			isLabel := p.isLabel()
+			p.next()
+			switch {
+			case isLabel:
+				p.next()
+				param.Kind = ast.KeywordParam
+				if p.tok != token.Comma && p.tok != close && p.tok != token.NewLine {
+					param.Default = p.parseBinary(prec)
+				}
+			case p.tok == token.Assign:
+				p.next()
+				param.Kind = ast.OptionalParam
+				param.Default = p.parseBinary(prec)
+			case rest:
+				param.Kind = ast.PostParam
+			default:
+				param.Kind = ast.RequiredParam
+			}
+		case token.LParen:
+			p.next()
+			param.Kind = ast.RequiredParam
+			if rest {
+				param.Kind = ast.PostParam
+			}
+			param.Nested = p.parseParams(token.RParen, prec)
+			p.expectClosing(token.RParen)
+			param.Nested.Span = p.span(start)
+		default:
+			p.errorExpected(token.IdentLocalVar)
+		}
+		if param.Name != "" {
+			p.scope.declare(param.Name)
+		}
+		param.Span = p.span(start)
+		params.List = append(params.List, param)
+		params.Stop = param.Stop
+		if !p.got(token.Comma) {
+			break
+		}
+	}
+	return params
+}
@@ -0,0 +1,426 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/token"
+)
+
+// parsePrimary parses a primary expression such as literals, variables, and
+// the compound expressions beginning with keywords.
+func (p *parser) parsePrimary() ast.Expr {
+	start := p.pos
+	t := p.tok
+	switch {
+	case t.IsNumber():
+		x := &ast.NumberLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Kind: t, Lit: string(p.lit)}
+		p.next()
+		return x
+	case t == token.IdentLocalVar || t == token.IdentLocalMethod:
+		name := string(p.lit)
+		p.next()
+		if t == token.IdentLocalVar && p.scope.isLocal(name) && !(p.tok == token.LParen && !p.space) {
+			return &ast.LocalVar{Span: p.span(start), Name: name}
+		}
+		call := &ast.Call{Name: name}
+		p.parseCallRest(call)
+		call.Span = p.span(start)
+		return call
+	case t == token.IdentConst:
+		name := string(p.lit)
+		p.next()
+		if p.tok == token.LParen && !p.space {
+			call := &ast.Call{Name: name}
+			p.parseCallRest(call)
+			call.Span = p.span(start)
+			return call
+		}
+		return &ast.Const{Span: p.span(start), Name: name}
+	case t == token.IdentInstanceVar:
+		x := &ast.InstanceVar{Span: ast.Span{Start: p.pos, Stop: p.end}, Name: string(p.lit)}
+		p.next()
+		return x
+	case t == token.IdentClassVar:
+		x := &ast.ClassVar{Span: ast.Span{Start: p.pos, Stop: p.end}, Name: string(p.lit)}
+		p.next()
+		return x
+	case t == token.IdentGlobalVar:
+		x := &ast.GlobalVar{Span: ast.Span{Start: p.pos, Stop: p.end}, Name: string(p.lit)}
+		p.next()
+		return x
+	}
+
+	switch t {
+	case token.Illegal:
+		// the scanner has reported the error.
+		p.next()
+		return &ast.BadExpr{Span: p.span(start)}
+	case token.String, token.StringPart:
+		return p.parseString()
+	case token.HeredocBegin:
+		return p.parseHeredoc()
+	case token.Colon:
+		return p.parseSymbol()
+	case token.Symbol:
+		x := &ast.SymbolLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Name: string(p.lit)}
+		p.next()
+		return x
+	case token.WordsBegin, token.SymbolsBegin:
+		return p.parseWords()
+	case token.RegexpBegin:
+		p.next()
+		parts := p.parseLiteralParts()
+		opts := string(p.lit[1:])
+		p.expect(token.LiteralEnd)
+		return &ast.RegexpLit{Span: p.span(start), Parts: parts, Options: opts}
+	case token.CommandBegin:
+		p.next()
+		parts := p.parseLiteralParts()
+		p.expect(token.LiteralEnd)
+		return &ast.XStr{Span: p.span(start), Parts: parts}
+	case token.LParen:
+		p.next()
+		noDo := p.noDo
+		p.noDo = 0
+		body := p.parseStmts(token.RParen)
+		p.noDo = noDo
+		p.expectClosing(token.RParen)
+		return &ast.Paren{Span: p.span(start), Body: body}
+	case token.LBracket:
+		p.next()
+		noDo := p.noDo
+		p.noDo = 0
+		elems := p.parseCallArgs(token.RBracket)
+		p.noDo = noDo
+		p.expectClosing(token.RBracket)
+		return &ast.ArrayLit{Span: p.span(start), Elems: elems}
+	case token.ElementRef:
+		p.next()
+		return &ast.ArrayLit{Span: p.span(start)}
+	case token.LBrace:
+		return p.parseHash()
+	case token.Colon2:
+		p.next()
+		if p.tok != token.IdentConst {
+			p.errorExpected(token.IdentConst)
+		}
+		name := string(p.lit)
+		p.next()
+		return &ast.Const{Span: p.span(start), Top: true, Name: name}
+	case token.Minus:
+		if p.adjacent() && p.peek(1).tok == token.Gt {
+			return p.parseLambda()
+		}
+	case token.KeywordNil, token.KeywordTrue, token.KeywordFalse, token.KeywordSelf,
+		token.KeywordLINE, token.KeywordFILE, token.KeywordENCODING:
+		p.next()
+		return &ast.PseudoVar{Span: p.span(start), Kind: t}
+	case token.KeywordDef:
+		return p.parseDef()
+	case token.KeywordClass:
+		return p.parseClass()
+	case token.KeywordModule:
+		return p.parseModule()
+	case token.KeywordIf, token.KeywordUnless:
+		return p.parseIf()
+	case token.KeywordWhile, token.KeywordUntil:
+		return p.parseWhile()
+	case token.KeywordFor:
+		return p.parseFor()
+	case token.KeywordCase:
+		return p.parseCase()
+	case token.KeywordBegin:
+		return p.parseBegin()
+	case token.KeywordReturn:
+		p.next()
+		args := p.parseJumpArgs()
+		return &ast.Return{Span: p.span(start), Args: args}
+	case token.KeywordBreak:
+		p.next()
+		args := p.parseJumpArgs()
+		return &ast.Break{Span: p.span(start), Args: args}
+	case token.KeywordNext:
+		p.next()
+		args := p.parseJumpArgs()
+		return &ast.Next{Span: p.span(start), Args: args}
+	case token.KeywordRedo:
+		p.next()
+		return &ast.Redo{Span: p.span(start)}
+	case token.KeywordRetry:
+		p.next()
+		return &ast.Retry{Span: p.span(start)}
+	case token.KeywordYield:
+		p.next()
+		args, _ := p.parseYieldArgs()
+		return &ast.Yield{Span: p.span(start), Args: args}
+	case token.KeywordSuper:
+		p.next()
+		x := &ast.Super{}
+		x.Args, _ = p.parseYieldArgs()
+		if p.tok == token.LBrace || p.tok == token.KeywordDo && p.noDo == 0 {
+			x.Block = p.parseBlock()
+		}
+		x.Span = p.span(start)
+		return x
+	case token.KeywordAlias:
+		p.next()
+		x := &ast.Alias{New: p.parseAliasName()}
+		x.Old = p.parseAliasName()
+		x.Span = p.span(start)
+		return x
+	case token.KeywordUndef:
+		p.next()
+		x := &ast.Undef{}
+		for {
+			x.Names = append(x.Names, p.parseAliasName())
+			if !p.got(token.Comma) {
+				break
+			}
+			p.skipNewLines()
+		}
+		x.Span = p.span(start)
+		return x
+	}
+	if p.isMissing() {
+		p.reportUnexpected()
+		return &ast.MissingExpr{Span: ast.Span{Start: p.prevEnd, Stop: p.prevEnd}}
+	}
+	p.errorExpected()
+	return nil
+}
+
+// isMissing returns whether the current token follows the place where an
+// expression is missing, such as the end of line after a =.
+func (p *parser) isMissing() bool {
+	switch p.tok {
+	case token.NewLine, token.EOF, token.Comma, token.KeywordThen, token.KeywordDo,
+		token.RParen, token.RBracket, token.RBrace, token.InsertEnd, token.KeywordEnd:
+		return true
+	}
+	return p.isClosing()
+}
+
+// parseString parses a string literal which may be interpolated. The adjacent
+// literals such as "a" "b" are concatenated.
+func (p *parser) parseString() ast.Expr {
+	start := p.pos
+	var parts []ast.Expr
+	interp := false
+	for p.tok == token.String || p.tok == token.StringPart {
+		if p.tok == token.StringPart {
+			interp = true
+			parts = append(parts, p.parseStringParts()...)
+			continue
+		}
+		parts = append(parts, &ast.StrLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Value: string(p.lit)})
+		p.next()
+	}
+	if !interp {
+		var value []byte
+		for _, x := range parts {
+			value = append(value, x.(*ast.StrLit).Value...)
+		}
+		return &ast.StrLit{Span: p.span(start), Value: string(value)}
+	}
+	return &ast.InterpStr{Span: p.span(start), Parts: parts}
+}
+
+// parseStringParts parses the parts of interpolated string until the String
+// token at the end of string.
+func (p *parser) parseStringParts() []ast.Expr {
+	var parts []ast.Expr
+	for {
+		switch p.tok {
+		case token.String:
+			if len(p.lit) > 0 {
+				parts = append(parts, &ast.StrLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Value: string(p.lit)})
+			}
+			p.next()
+			return parts
+		case token.StringPart, token.HeredocPart:
+			if len(p.lit) > 0 {
+				parts = append(parts, &ast.StrLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Value: string(p.lit)})
+			}
+			p.next()
+		case token.HeredocEnd:
+			if len(p.lit) > 0 {
+				parts = append(parts, &ast.StrLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Value: string(p.lit)})
+			}
+			p.next()
+			return parts
+		case token.Illegal:
+			// the scanner has reported the unterminated string.
+			parts = append(parts, &ast.BadExpr{Span: ast.Span{Start: p.pos, Stop: p.end}})
+			p.next()
+			return parts
+		default:
+			parts = append(parts, p.parseInsert())
+		}
+	}
+}
+
+// parseInsert parses an interpolation such as #{a} or #@a.
+func (p *parser) parseInsert() ast.Expr {
+	start := p.pos
+	switch p.tok {
+	case token.InsertBegin:
+		p.next()
+		noDo := p.noDo
+		p.noDo = 0
+		body := p.parseStmts(token.InsertEnd)
+		p.noDo = noDo
+		p.expectClosing(token.InsertEnd)
+		return &ast.Insert{Span: p.span(start), Body: body}
+	case token.IdentInstanceVar, token.IdentClassVar, token.IdentGlobalVar:
+		x := p.parsePrimary()
+		body := &ast.Stmts{Span: ast.Span{Start: x.Pos(), Stop: x.End()}, List: []ast.Expr{x}}
+		return &ast.Insert{Span: p.span(start), Body: body}
+	}
+	p.errorExpected(token.String)
+	return nil
+}
+
+// parseHeredoc parses a heredoc. The body follows HeredocBegin in the token
+// sequence, but the span of the heredoc is only its beginning such as <<EOS.
+func (p *parser) parseHeredoc() ast.Expr {
+	lit := string(p.lit)
+	x := &ast.Heredoc{Span: ast.Span{Start: p.pos, Stop: p.end}}
+	lit = strings.TrimPrefix(lit, "<<")
+	if strings.HasPrefix(lit, "-") {
+		x.Indent = true
+		lit = lit[1:]
+	}
+	x.Term = lit
+	p.next()
+	x.Parts = p.parseStringParts()
+	p.prevEnd = x.Stop
+	return x
+}
+
+// parseSymbol parses a symbol beginning with a colon such as :a or :"a".
+func (p *parser) parseSymbol() ast.Expr {
+	start := p.pos
+	if !p.adjacent() {
+		p.errorExpected()
+	}
+	p.next() // :
+	switch {
+	case p.tok == token.String:
+		name := string(p.lit)
+		p.next()
+		return &ast.SymbolLit{Span: p.span(start), Name: name}
+	case p.tok == token.StringPart:
+		parts := p.parseStringParts()
+		return &ast.InterpSymbol{Span: p.span(start), Parts: parts}
+	case p.tok == token.Illegal:
+		// the scanner has reported the unterminated symbol.
+		p.next()
+		return &ast.BadExpr{Span: p.span(start)}
+	case p.tok.IsIdent() || p.tok.IsKeyword() || p.tok.IsOperatorMethod() ||
+		p.tok == token.Not || p.tok == token.NotEqual || p.tok == token.NotMatch:
+		name := p.name()
+		p.next()
+		return &ast.SymbolLit{Span: p.span(start), Name: name}
+	}
+	p.errorExpected(token.IdentLocalVar)
+	return nil
+}
+
+// parseLiteralParts parses the parts of percent literals until LiteralEnd.
+func (p *parser) parseLiteralParts() []ast.Expr {
+	var parts []ast.Expr
+	for p.tok != token.LiteralEnd && p.tok != token.EOF {
+		parts = append(parts, p.parseStringParts()...)
+	}
+	return parts
+}
+
+// parseWords parses an array of words or symbols such as %w(a b) or %i(a b).
+func (p *parser) parseWords() ast.Expr {
+	start := p.pos
+	symbols := p.tok == token.SymbolsBegin
+	p.next()
+	var elems []ast.Expr
+	for p.tok != token.LiteralEnd && p.tok != token.EOF {
+		wstart := p.pos
+		var x ast.Expr
+		switch {
+		case p.tok == token.String && symbols:
+			x = &ast.SymbolLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Name: string(p.lit)}
+			p.next()
+		case p.tok == token.String:
+			x = &ast.StrLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Value: string(p.lit)}
+			p.next()
+		case symbols:
+			x = &ast.InterpSymbol{Parts: p.parseStringParts(), Span: p.span(wstart)}
+		default:
+			x = &ast.InterpStr{Parts: p.parseStringParts(), Span: p.span(wstart)}
+		}
+		elems = append(elems, x)
+	}
+	p.expect(token.LiteralEnd)
+	return &ast.ArrayLit{Span: p.span(start), Elems: elems}
+}
+
+// parseHash parses a hash literal enclosed by braces.
+func (p *parser) parseHash() ast.Expr {
+	start := p.pos
+	p.next() // {
+	noDo := p.noDo
+	p.noDo = 0
+	x := &ast.HashLit{Braces: true}
+	for {
+		p.skipNewLines()
+		if p.tok == token.RBrace {
+			break
+		}
+		if p.tok == token.Pow || p.isLabel() {
+			x.Pairs = append(x.Pairs, p.parsePair())
+		} else {
+			x.Pairs = append(x.Pairs, p.parsePairValue(p.parseArg()))
+		}
+		if !p.got(token.Comma) {
+			break
+		}
+	}
+	p.skipNewLines()
+	p.noDo = noDo
+	p.expectClosing(token.RBrace)
+	x.Span = p.span(start)
+	return x
+}
+
+// isLabel returns whether the current token is a label of hash such as a: or
+// "a":.
+func (p *parser) isLabel() bool {
+	next := p.peek(1)
+	if next.tok != token.Colon || next.pos != p.end {
+		return false
+	}
+	return p.tok.IsIdent() || p.tok.IsKeyword() || p.tok == token.String
+}
+
+// parsePair parses a labeled pair such as a: 1, or a double splat such as **h.
+func (p *parser) parsePair() *ast.Pair {
+	start := p.pos
+	if p.tok == token.Pow {
+		p.next()
+		value := p.parseArg()
+		return &ast.Pair{Span: p.span(start), Value: value}
+	}
+	key := &ast.SymbolLit{Span: ast.Span{Start: p.pos, Stop: p.end}, Name: p.name()}
+	p.next() // label
+	p.next() // :
+	p.skipNewLines()
+	value := p.parseArg()
+	return &ast.Pair{Span: p.span(start), Key: key, Value: value}
+}
+
+// parsePairValue parses the value of the pair such as => 1 after the key.
+func (p *parser) parsePairValue(key ast.Expr) *ast.Pair {
+	p.expect(token.Arrow)
+	p.skipNewLines()
+	value := p.parseArg()
+	return &ast.Pair{Span: p.span(key.Pos()), Key: key, Value: value}
+}
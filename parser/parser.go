@@ -0,0 +1,259 @@
+/*
+Package parser implements a parser for Ruby source. It reads the tokens from
+the scanner and builds the syntax tree defined by the ast package.
+
+The parser is a recursive-descent parser. The binary operators are parsed by
+the precedence climbing with the precedences given by the token package.
+
+The parser does not stop at syntax errors. The statement containing an error
+is replaced by ast.BadExpr, and the tokens are skipped until the end of line
+or the closing token of the enclosing construct, such as end or }. The
+expression which is not written is given as ast.MissingExpr, and the missing
+closing token is reported but parsed as if it were inserted. The illegal
+token reported by the scanner is skipped as ast.BadExpr. At most one error
+is reported for each line, so that an error does not cascade.
+*/
+package parser
+
+import (
+	"sort"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/scanner"
+	"github.com/harukasan/ringo/token"
+)
+
+// ParseFile parses the source of the file and returns the syntax tree. The
+// error is an ErrorList if any syntax errors are found, and the tree is
+// still returned for the erroneous source.
+func ParseFile(filename string, src []byte) (*ast.File, error) {
+	p := newParser(src)
+	f := p.parseFile(filename)
+	for _, e := range p.errors {
+		e.Filename = filename
+		e.Line, e.Column = p.position(e.Pos)
+	}
+	return f, p.errors.Err()
+}
+
+// ParseExpr parses the source as a sequence of expressions and returns them.
+// It is convenient for testing.
+func ParseExpr(src string) (*ast.Stmts, error) {
+	f, err := ParseFile("", []byte(src))
+	return f.Body, err
+}
+
+// bailout is used to unwind the statement containing a syntax error.
+type bailout struct{}
+
+// scope holds the local variables to distinguish them from method calls.
+type scope struct {
+	vars   map[string]bool
+	parent *scope // outer scope seen from blocks, or nil
+}
+
+func (s *scope) declare(name string) {
+	s.vars[name] = true
+}
+
+func (s *scope) isLocal(name string) bool {
+	for ; s != nil; s = s.parent {
+		if s.vars[name] {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	toks      []tokenInfo
+	i         int // index of the current token
+	tokenInfo     // current token
+	prevEnd   int // end of the previous token
+	errors    ErrorList
+	errLines  map[int]bool // lines where errors are reported
+	lines     []int        // offsets of the beginning of lines
+	scope     *scope
+	ends      [][]token.Token // end tokens of the enclosing statements
+
+	noDo int // whether do is taken by the enclosing command or loop
+}
+
+func newParser(src []byte) *parser {
+	// the scanner decodes escape sequences in place.
+	buf := make([]byte, len(src))
+	copy(buf, src)
+	s := scanner.New(buf)
+	p := &parser{toks: scanAll(s), errLines: map[int]bool{}, lines: []int{0}}
+	for i, c := range src {
+		if c == '\n' {
+			p.lines = append(p.lines, i+1)
+		}
+	}
+	for _, err := range s.Errors() {
+		p.errLines[p.line(err.Pos)] = true
+		p.errors = append(p.errors, &Error{Pos: err.Pos, Msg: err.Err.Error(), Labels: err.Labels})
+	}
+	p.tokenInfo = p.toks[0]
+	p.pushScope(false)
+	return p
+}
+
+// next advances to the next token.
+func (p *parser) next() {
+	p.prevEnd = p.end
+	if p.i < len(p.toks)-1 {
+		p.i++
+	}
+	p.tokenInfo = p.toks[p.i]
+}
+
+// peek returns the n-th token after the current token.
+func (p *parser) peek(n int) tokenInfo {
+	if p.i+n < len(p.toks) {
+		return p.toks[p.i+n]
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+// adjacent returns whether the next token follows the current token without
+// spaces.
+func (p *parser) adjacent() bool {
+	return p.peek(1).pos == p.end
+}
+
+func (p *parser) got(t token.Token) bool {
+	if p.tok == t {
+		p.next()
+		return true
+	}
+	return false
+}
+
+// expect consumes the token t, or reports an error.
+func (p *parser) expect(t token.Token) int {
+	pos := p.pos
+	if p.tok != t {
+		p.errorExpected(t)
+	}
+	p.next()
+	return pos
+}
+
+func (p *parser) skipNewLines() {
+	for p.tok == token.NewLine {
+		p.next()
+	}
+}
+
+// skipTerms skips new lines and the optional then keyword.
+func (p *parser) skipThen() {
+	p.skipNewLines()
+	if p.got(token.KeywordThen) {
+		p.skipNewLines()
+	}
+}
+
+// expectClosing consumes the closing token t such as end. If it is missing,
+// the error is reported and the parser continues as if it were inserted.
+func (p *parser) expectClosing(t token.Token) {
+	if p.tok == t {
+		p.next()
+		return
+	}
+	p.reportUnexpected(t)
+}
+
+// line returns the line number of the offset, starting at 1.
+func (p *parser) line(pos int) int {
+	return sort.SearchInts(p.lines, pos+1)
+}
+
+// position returns the line and column numbers of the offset.
+func (p *parser) position(pos int) (line, column int) {
+	line = p.line(pos)
+	return line, pos - p.lines[line-1] + 1
+}
+
+// report adds the error unless an error is reported in the same line.
+func (p *parser) report(e *Error) {
+	line := p.line(e.Pos)
+	if p.errLines[line] {
+		return
+	}
+	p.errLines[line] = true
+	p.errors = append(p.errors, e)
+}
+
+// reportUnexpected reports the unexpected current token.
+func (p *parser) reportUnexpected(expected ...token.Token) {
+	p.report(&Error{
+		Pos:      p.pos,
+		End:      p.end,
+		Msg:      unexpectedMsg(p.tok, expected),
+		Found:    p.tok,
+		Expected: expected,
+	})
+}
+
+// error reports the error and unwinds the current statement.
+func (p *parser) error(pos int, msg string) {
+	p.report(&Error{Pos: pos, Msg: msg})
+	panic(bailout{})
+}
+
+// errorExpected reports the unexpected current token and unwinds the current
+// statement.
+func (p *parser) errorExpected(expected ...token.Token) {
+	p.reportUnexpected(expected...)
+	panic(bailout{})
+}
+
+// isClosing returns whether the current token ends one of the enclosing
+// statements.
+func (p *parser) isClosing() bool {
+	for _, ends := range p.ends {
+		for _, t := range ends {
+			if p.tok == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sync skips tokens until a new line or the token closing the enclosing
+// statements. It returns false if it stops at the closing token or the end of
+// input.
+func (p *parser) sync() bool {
+	for {
+		switch {
+		case p.tok == token.NewLine:
+			return true
+		case p.tok == token.EOF || p.isClosing():
+			return false
+		}
+		p.next()
+	}
+}
+
+func (p *parser) pushScope(block bool) {
+	s := &scope{vars: map[string]bool{}}
+	if block {
+		s.parent = p.scope
+	}
+	p.scope = s
+}
+
+func (p *parser) popScope(s *scope) {
+	p.scope = s
+}
+
+func (p *parser) span(start int) ast.Span {
+	return ast.Span{Start: start, Stop: p.prevEnd}
+}
+
+func (p *parser) parseFile(filename string) *ast.File {
+	body := p.parseStmts(token.EOF)
+	return &ast.File{Span: ast.Span{Start: 0, Stop: p.end}, Name: filename, Body: body}
+}
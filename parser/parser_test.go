@@ -0,0 +1,294 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/token"
+)
+
+// dump returns the node as a S-expression such as (Call "a" [(NumberLit "1")]).
+// The spans, nil, empty, and false fields, and the tokens without spelling
+// such as the kind of numbers are omitted. The true fields are written by
+// their names.
+func dump(n interface{}) string {
+	var buf bytes.Buffer
+	dumpValue(&buf, reflect.ValueOf(n))
+	return buf.String()
+}
+
+var (
+	tokenType     = reflect.TypeOf(token.None)
+	paramKindType = reflect.TypeOf(ast.RequiredParam)
+	paramKinds    = []string{"req", "opt", "rest", "post", "key", "keyrest", "block"}
+)
+
+func dumpValue(buf *bytes.Buffer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		dumpValue(buf, v.Elem())
+	case reflect.Slice:
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			dumpValue(buf, v.Index(i))
+		}
+		buf.WriteByte(']')
+	case reflect.Struct:
+		buf.WriteString("(" + v.Type().Name())
+		for i := 0; i < v.NumField(); i++ {
+			f, ft := v.Field(i), v.Type().Field(i)
+			if ft.Name == "Span" || ft.Name == "OpPos" || isZero(f) ||
+				f.Type() == tokenType && token.Token(f.Int()).Text() == "" {
+				continue
+			}
+			buf.WriteByte(' ')
+			if f.Kind() == reflect.Bool {
+				buf.WriteString(ft.Name)
+				continue
+			}
+			dumpValue(buf, f)
+		}
+		buf.WriteByte(')')
+	case reflect.String:
+		fmt.Fprintf(buf, "%q", v.String())
+	default:
+		switch v.Type() {
+		case tokenType:
+			buf.WriteString(token.Token(v.Int()).Text())
+		case paramKindType:
+			buf.WriteString(paramKinds[v.Int()])
+		default:
+			fmt.Fprint(buf, v.Interface())
+		}
+	}
+}
+
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Slice:
+		return v.IsNil()
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.String:
+		return v.Len() == 0
+	case reflect.Int:
+		return v.Int() == 0 && v.Type() != paramKindType
+	}
+	return false
+}
+
+func TestParseExpr(t *testing.T) {
+	rules := map[string]string{
+		"1 + 2 * 3":                              `[(Binary + (NumberLit "1") (Binary * (NumberLit "2") (NumberLit "3")))]`,
+		"a = 1; a -1":                            `[(Assign (LocalVar "a") = (NumberLit "1")) (Binary - (LocalVar "a") (NumberLit "1"))]`,
+		"x -1":                                   `[(Call "x" [(NumberLit "-1")])]`,
+		"-2 ** 2":                                `[(Unary - (Binary ** (NumberLit "2") (NumberLit "2")))]`,
+		"a = b = 1":                              `[(Assign (LocalVar "a") = (Assign (LocalVar "b") = (NumberLit "1")))]`,
+		"a ||= {}":                               `[(Assign (LocalVar "a") ||= (HashLit Braces))]`,
+		"a, *b = 1, 2":                           `[(MultiAssign [(LocalVar "a") (Splat (LocalVar "b"))] (ArrayLit [(NumberLit "1") (NumberLit "2")]))]`,
+		"a, (b, *c), d = 1":                      `[(MultiAssign [(LocalVar "a") (MultiAssign [(LocalVar "b") (Splat (LocalVar "c"))]) (LocalVar "d")] (NumberLit "1"))]`,
+		"(a, b), c = 1":                          `[(MultiAssign [(MultiAssign [(LocalVar "a") (LocalVar "b")]) (LocalVar "c")] (NumberLit "1"))]`,
+		"(x).y, z = 1":                           `[(MultiAssign [(Call (Paren (Stmts [(Call "x")])) . "y") (LocalVar "z")] (NumberLit "1"))]`,
+		"a = 1, 2":                               `[(Assign (LocalVar "a") = (ArrayLit [(NumberLit "1") (NumberLit "2")]))]`,
+		"puts 1, 2":                              `[(Call "puts" [(NumberLit "1") (NumberLit "2")])]`,
+		"puts(1) { |x| x }":                      `[(Call "puts" [(NumberLit "1")] Parens (Block (Params [(Param req "x")]) (BodyStmt (Stmts [(LocalVar "x")])) Braces))]`,
+		"foo a: 1, \"b\": 2, :c => 3, **h, &blk": `[(Call "foo" [(HashLit [(Pair (SymbolLit "a") (NumberLit "1")) (Pair (SymbolLit "b") (NumberLit "2")) (Pair (SymbolLit "c") (NumberLit "3")) (Pair (Call "h"))]) (BlockPass (Call "blk"))])]`,
+		"foo\n  .bar":                            `[(Call (Call "foo") . "bar")]`,
+		"A::B.new":                               `[(Call (Const (Const "A") "B") . "new")]`,
+		"::A":                                    `[(Const Top "A")]`,
+		"x ? 1 : 2":                              `[(Ternary (Call "x") (NumberLit "1") (NumberLit "2"))]`,
+		"not a and b or c":                       `[(Binary or (Binary and (Unary not (Call "a")) (Call "b")) (Call "c"))]`,
+		"1...":                                   `[(RangeLit (NumberLit "1") Exclusive)]`,
+		"x = ..5":                                `[(Assign (LocalVar "x") = (RangeLit (NumberLit "5")))]`,
+		"a = [1, *b]":                            `[(Assign (LocalVar "a") = (ArrayLit [(NumberLit "1") (Splat (Call "b"))]))]`,
+		"a[1] += 2":                              `[(Assign (Index (Call "a") [(NumberLit "1")]) += (NumberLit "2"))]`,
+		"a.b = 1":                                `[(Assign (Call (Call "a") . "b") = (NumberLit "1"))]`,
+		"def foo(a, b = 1, *c, d, e:, f: 2, **g, &h) end": `[(Def "foo" (Params [(Param req "a") (Param opt "b" (NumberLit "1")) (Param rest "c") (Param post "d") (Param key "e") (Param key "f" (NumberLit "2")) (Param keyrest "g") (Param block "h")]) (BodyStmt (Stmts)))]`,
+		"def self.foo=(v); end":                           `[(Def (PseudoVar self) "foo=" (Params [(Param req "v")]) (BodyStmt (Stmts)))]`,
+		"def ==(o) end":                                   `[(Def "==" (Params [(Param req "o")]) (BodyStmt (Stmts)))]`,
+		"class A < B; end":                                `[(ClassDef (Const "A") (Const "B") (BodyStmt (Stmts)))]`,
+		"class << self; end":                              `[(SingletonClassDef (PseudoVar self) (BodyStmt (Stmts)))]`,
+		"module A::B; end":                                `[(ModuleDef (Const (Const "A") "B") (BodyStmt (Stmts)))]`,
+		"if a then b elsif c then d else e end":           `[(If (Call "a") (Stmts [(Call "b")]) (If (Call "c") (Stmts [(Call "d")]) (Stmts [(Call "e")])))]`,
+		"unless a; b; end":                                `[(If Unless (Call "a") (Stmts [(Call "b")]))]`,
+		"x if y":                                          `[(If Mod (Call "y") (Stmts [(Call "x")]))]`,
+		"begin; x; end while y":                           `[(While Mod DoWhile (Call "y") (Stmts [(Begin (BodyStmt (Stmts [(Call "x")])))]))]`,
+		"until x; y; end":                                 `[(While Until (Call "x") (Stmts [(Call "y")]))]`,
+		"for a, b in c do d end":                          `[(For [(LocalVar "a") (LocalVar "b")] (Call "c") (Stmts [(Call "d")]))]`,
+		"case x\nwhen 1, *y then a\nelse b\nend":          `[(Case (Call "x") [(When [(NumberLit "1") (Splat (Call "y"))] (Stmts [(Call "a")]))] (Stmts [(Call "b")]))]`,
+		"begin\n  a\nrescue A => e\n  e\nelse\n  b\nensure\n  c\nend": `[(Begin (BodyStmt (Stmts [(Call "a")]) [(Rescue [(Const "A")] (LocalVar "e") (Stmts [(LocalVar "e")]))] (Stmts [(Call "b")]) (Stmts [(Call "c")])))]`,
+		"a = b rescue c":                `[(Assign (LocalVar "a") = (RescueMod (Call "b") (Call "c")))]`,
+		"return 1, 2":                   `[(Return [(NumberLit "1") (NumberLit "2")])]`,
+		"yield(1)":                      `[(Yield [(NumberLit "1")])]`,
+		"super":                         `[(Super)]`,
+		"super()":                       `[(Super [])]`,
+		"alias foo bar":                 `[(Alias (SymbolLit "foo") (SymbolLit "bar"))]`,
+		"undef a, :b":                   `[(Undef [(SymbolLit "a") (SymbolLit "b")])]`,
+		"foo bar do |x| end":            `[(Call "foo" [(Call "bar")] (Block (Params [(Param req "x")]) (BodyStmt (Stmts))))]`,
+		"it \"does\" do end":            `[(Call "it" [(StrLit "does")] (Block (BodyStmt (Stmts))))]`,
+		"[1].each { |a, b = 1| a | b }": `[(Call (ArrayLit [(NumberLit "1")]) . "each" (Block (Params [(Param req "a") (Param opt "b" (NumberLit "1"))]) (BodyStmt (Stmts [(Binary | (LocalVar "a") (LocalVar "b"))])) Braces))]`,
+		"[1].each { |(a, b), c| }":      `[(Call (ArrayLit [(NumberLit "1")]) . "each" (Block (Params [(Param req (Params [(Param req "a") (Param req "b")])) (Param req "c")]) (BodyStmt (Stmts)) Braces))]`,
+		"->(a) { a }":                   `[(Call "lambda" (Block (Params [(Param req "a")]) (BodyStmt (Stmts [(LocalVar "a")])) Braces))]`,
+		"\"a#{b}c#@d\"":                 `[(InterpStr [(StrLit "a") (Insert (Stmts [(Call "b")])) (StrLit "c") (Insert (Stmts [(InstanceVar "@d")]))])]`,
+		"\"a\" 'b'":                     `[(StrLit "ab")]`,
+		":\"a#{b}\"":                    `[(InterpSymbol [(StrLit "a") (Insert (Stmts [(Call "b")]))])]`,
+		":+":                            `[(SymbolLit "+")]`,
+		"%w(a b)":                       `[(ArrayLit [(StrLit "a") (StrLit "b")])]`,
+		"%i(a)":                         `[(ArrayLit [(SymbolLit "a")])]`,
+		"%r(a)im":                       `[(RegexpLit [(StrLit "a")] "im")]`,
+		"%x(ls)":                        `[(XStr [(StrLit "ls")])]`,
+		"x = <<-EOS\n  a\n  EOS":        `[(Assign (LocalVar "x") = (Heredoc "EOS" Indent [(StrLit "  a\n")]))]`,
+		"defined?(a)":                   `[(Defined (Call "a"))]`,
+		"!a.b":                          `[(Unary ! (Call (Call "a") . "b"))]`,
+		"puts -x":                       `[(Call "puts" [(Unary - (Call "x"))])]`,
+		"puts [1]":                      `[(Call "puts" [(ArrayLit [(NumberLit "1")])])]`,
+		"a = []; a [0]":                 `[(Assign (LocalVar "a") = (ArrayLit)) (Index (LocalVar "a") [(NumberLit "0")])]`,
+		"self.class::C":                 `[(Const (Call (PseudoVar self) . "class") "C")]`,
+		"obj.()":                        `[(Call (Call "obj") . "call" [] Parens)]`,
+		"foo.map(&:to_s)":               `[(Call (Call "foo") . "map" [(BlockPass (SymbolLit "to_s"))] Parens)]`,
+		"@a ||= 1":                      `[(Assign (InstanceVar "@a") ||= (NumberLit "1"))]`,
+	}
+	for src, want := range rules {
+		stmts, err := ParseExpr(src)
+		if err != nil {
+			t.Errorf("src=%q: err=%v", src, err)
+			continue
+		}
+		if got := dump(stmts.List); got != want {
+			t.Errorf("src=%q: dump=%s (want=%s)", src, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	rules := map[string]struct {
+		pos int
+		msg string
+	}{
+		"1 +":          {3, "syntax error, unexpected end-of-input"},
+		"(1":           {2, "syntax error, unexpected end-of-input, expecting ')'"},
+		"end":          {0, "syntax error, unexpected 'end'"},
+		"class a; end": {6, "syntax error, unexpected identifier, expecting constant"},
+		"a = 1 2":      {6, "syntax error, unexpected numeric literal, expecting end-of-input or new line"},
+		"if x":         {4, "syntax error, unexpected end-of-input, expecting 'end'"},
+		"a == b == c":  {7, "syntax error, unexpected '=='"},
+		"a + b = 1":    {6, "syntax error, unexpected assignment"},
+		"\"a":          {2, "unterminated string meets end of file"},
+		"x = $":        {4, "'$' without identifiers is not allowed as a global variable name"},
+		"$-w":          {0, "'$' without identifiers is not allowed as a global variable name"},
+		"x = @":        {4, "'@' without identifiers is not allowed as an instance variable name"},
+		"x = @@":       {4, "'@@' without identifiers is not allowed as a class variable name"},
+		"@1":           {0, "'@1' is not allowed as an instance variable name"},
+		"puts @":       {5, "'@' without identifiers is not allowed as an instance variable name"},
+	}
+	for src, want := range rules {
+		_, err := ParseExpr(src)
+		list, ok := err.(ErrorList)
+		if !ok || len(list) == 0 {
+			t.Errorf("src=%q: err=%v (want=%v)", src, err, want.msg)
+			continue
+		}
+		if list[0].Pos != want.pos || list[0].Msg != want.msg {
+			t.Errorf("src=%q: pos=%d msg=%q (want=%d %q)", src, list[0].Pos, list[0].Msg, want.pos, want.msg)
+		}
+	}
+}
+
+func TestParseRecovery(t *testing.T) {
+	rules := map[string]struct {
+		dump   string
+		errors string
+	}{
+		"1 +": {
+			`[(Binary + (NumberLit "1") (MissingExpr))]`,
+			"t.rb:1:4: syntax error, unexpected end-of-input",
+		},
+		"def a\n  x = (1 +\nend\nb": {
+			`[(Def "a" (BodyStmt (Stmts [(Assign (LocalVar "x") = (Paren (Stmts [(Binary + (NumberLit "1") (MissingExpr))])))]))) (Call "b")]`,
+			"t.rb:3:1: syntax error, unexpected 'end'",
+		},
+		"def a\n  1 2\n  b\n": {
+			`[(Def "a" (BodyStmt (Stmts [(NumberLit "1") (BadExpr) (Call "b")])))]`,
+			"t.rb:2:5: syntax error, unexpected numeric literal, expecting 'rescue' or 'else' or 'ensure' or 'end' or new line\n" +
+				"t.rb:4:1: syntax error, unexpected end-of-input, expecting 'end'",
+		},
+		"foo(1, , 2)\nbar": {
+			`[(Call "foo" [(NumberLit "1") (MissingExpr) (NumberLit "2")] Parens) (Call "bar")]`,
+			"t.rb:1:8: syntax error, unexpected ','",
+		},
+		")\na\nend\nb": {
+			`[(MissingExpr) (BadExpr) (Call "a") (MissingExpr) (BadExpr) (Call "b")]`,
+			"t.rb:1:1: syntax error, unexpected ')'\n" +
+				"t.rb:3:1: syntax error, unexpected 'end'",
+		},
+		"a + b = 1\nc": {
+			`[(BadExpr) (Call "c")]`,
+			"t.rb:1:7: syntax error, unexpected assignment",
+		},
+		"x = 0_8\ny": {
+			`[(Assign (LocalVar "x") = (BadExpr)) (Call "y")]`,
+			"t.rb:1:7: Invalid octal digit",
+		},
+		"puts \"abc\n": {
+			`[(Call "puts" [(BadExpr)])]`,
+			"t.rb:2:1: unterminated string meets end of file",
+		},
+		"puts :\"a\n": {
+			`[(Call "puts" [(BadExpr)])]`,
+			"t.rb:2:1: unterminated string meets end of file",
+		},
+		"x = \"a#{1}b\n": {
+			`[(Assign (LocalVar "x") = (InterpStr [(StrLit "a") (Insert (Stmts [(NumberLit "1")])) (BadExpr)]))]`,
+			"t.rb:2:1: unterminated string meets end of file",
+		},
+		"x = <<EOS\nabc\n": {
+			`[(Assign (LocalVar "x") = (Heredoc "EOS" [(BadExpr)]))]`,
+			"t.rb:3:1: unterminated heredoc meets end of file",
+		},
+		"x = 1 \x01 + 2\ny": {
+			`[(Assign (LocalVar "x") = (NumberLit "1")) (BadExpr) (Call "y")]`,
+			"t.rb:1:7: Invalid char '\\x01' in expression",
+		},
+	}
+	for src, want := range rules {
+		f, err := ParseFile("t.rb", []byte(src))
+		if err == nil {
+			t.Errorf("src=%q: err=nil (want=%q)", src, want.errors)
+			continue
+		}
+		if err.Error() != want.errors {
+			t.Errorf("src=%q: err=%q (want=%q)", src, err, want.errors)
+		}
+		if got := dump(f.Body.List); got != want.dump {
+			t.Errorf("src=%q: dump=%s (want=%s)", src, got, want.dump)
+		}
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	files := []string{"model.rb", "template.rb"}
+	for _, name := range files {
+		src, err := ioutil.ReadFile(filepath.Join("../scanner/testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := ParseFile(name, src)
+		if err != nil {
+			t.Errorf("%s: err=%v", name, err)
+			continue
+		}
+		if f.Pos() != 0 || f.End() != len(src) {
+			t.Errorf("%s: span=%v (want=[0 %d])", name, f.Span, len(src))
+		}
+	}
+}
@@ -0,0 +1,613 @@
+package parser
+
+import (
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/token"
+)
+
+// parseStmts parses statements separated by new lines until one of the end
+// tokens. The statement containing a syntax error is replaced by BadExpr.
+func (p *parser) parseStmts(ends ...token.Token) *ast.Stmts {
+	p.ends = append(p.ends, ends)
+	defer func() { p.ends = p.ends[:len(p.ends)-1] }()
+
+	stmts := &ast.Stmts{}
+	p.skipNewLines()
+	for !p.isEnd(ends) {
+		stmts.List = append(stmts.List, p.parseStmtOrBad())
+		if p.tok != token.NewLine && !p.isEnd(ends) {
+			start := p.pos
+			p.reportUnexpected(append(ends, token.NewLine)...)
+			ok := p.sync()
+			if p.prevEnd > start {
+				stmts.List = append(stmts.List, &ast.BadExpr{Span: p.span(start)})
+			}
+			if !ok {
+				break
+			}
+		}
+		p.skipNewLines()
+	}
+	if len(stmts.List) == 0 {
+		stmts.Span = ast.Span{Start: p.pos, Stop: p.pos}
+	} else {
+		stmts.Span = ast.Span{Start: stmts.List[0].Pos(), Stop: stmts.List[len(stmts.List)-1].End()}
+	}
+	return stmts
+}
+
+// parseStmtOrBad parses a statement, or skips the statement containing a
+// syntax error and returns BadExpr.
+func (p *parser) parseStmtOrBad() (x ast.Expr) {
+	start, scope, noDo, ends := p.pos, p.scope, p.noDo, len(p.ends)
+	defer func() {
+		if e := recover(); e != nil {
+			if _, ok := e.(bailout); !ok {
+				panic(e)
+			}
+			p.scope, p.noDo, p.ends = scope, noDo, p.ends[:ends]
+			p.sync()
+			bad := &ast.BadExpr{Span: p.span(start)}
+			if bad.Stop < bad.Start {
+				bad.Stop = bad.Start
+			}
+			x = bad
+		}
+	}()
+	return p.parseStmt()
+}
+
+func (p *parser) isEnd(ends []token.Token) bool {
+	if p.tok == token.EOF {
+		return true
+	}
+	for _, t := range ends {
+		if p.tok == t {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStmt parses a statement followed by modifiers.
+func (p *parser) parseStmt() ast.Expr {
+	x := p.parseExprStmt()
+	for {
+		start := x.Pos()
+		switch p.tok {
+		case token.KeywordIf, token.KeywordUnless:
+			unless := p.tok == token.KeywordUnless
+			p.next()
+			cond := p.parseExprStmt()
+			then := &ast.Stmts{Span: ast.Span{Start: x.Pos(), Stop: x.End()}, List: []ast.Expr{x}}
+			x = &ast.If{Span: p.span(start), Unless: unless, Mod: true, Cond: cond, Then: then}
+		case token.KeywordWhile, token.KeywordUntil:
+			until := p.tok == token.KeywordUntil
+			_, doWhile := x.(*ast.Begin)
+			p.next()
+			cond := p.parseExprStmt()
+			body := &ast.Stmts{Span: ast.Span{Start: x.Pos(), Stop: x.End()}, List: []ast.Expr{x}}
+			x = &ast.While{Span: p.span(start), Until: until, Mod: true, DoWhile: doWhile, Cond: cond, Body: body}
+		case token.KeywordRescue:
+			p.next()
+			r := p.parseExprStmt()
+			if a, ok := x.(*ast.Assign); ok {
+				// a = b rescue c is a = (b rescue c)
+				a.Rhs = &ast.RescueMod{Span: p.span(a.Rhs.Pos()), X: a.Rhs, Rescue: r}
+				a.Span = p.span(start)
+				break
+			}
+			x = &ast.RescueMod{Span: p.span(start), X: x, Rescue: r}
+		default:
+			return x
+		}
+	}
+}
+
+// parseExprStmt parses expressions combined by and, or, and not.
+func (p *parser) parseExprStmt() ast.Expr {
+	x := p.parseNotExpr()
+	for p.tok == token.KeywordAnd || p.tok == token.KeywordOr {
+		op, opPos := p.tok, p.pos
+		p.next()
+		p.skipNewLines()
+		y := p.parseNotExpr()
+		x = &ast.Binary{Span: p.span(x.Pos()), Op: op, OpPos: opPos, X: x, Y: y}
+	}
+	return x
+}
+
+func (p *parser) parseNotExpr() ast.Expr {
+	if p.tok == token.KeywordNot {
+		start := p.pos
+		p.next()
+		x := p.parseNotExpr()
+		return &ast.Unary{Span: p.span(start), Op: token.KeywordNot, X: x}
+	}
+	return p.parseExpr()
+}
+
+// parseExpr parses an expression including multiple assignments.
+func (p *parser) parseExpr() ast.Expr {
+	start := p.pos
+	if p.tok == token.Mul || p.tok == token.LParen && p.atMlhsGroup() {
+		return p.parseMultiAssign(start, nil)
+	}
+	x := p.parseArg()
+	if p.tok != token.Comma {
+		return x
+	}
+	if a, ok := x.(*ast.Assign); ok && a.Op == token.Assign {
+		// a = 1, 2
+		elems := []ast.Expr{a.Rhs}
+		if arr, ok := a.Rhs.(*ast.ArrayLit); ok && len(arr.Elems) == 1 {
+			if _, ok := arr.Elems[0].(*ast.Splat); ok {
+				elems = arr.Elems
+			}
+		}
+		for p.got(token.Comma) {
+			p.skipNewLines()
+			elems = append(elems, p.parseRhsElem())
+		}
+		a.Rhs = &ast.ArrayLit{Span: p.span(a.Rhs.Pos()), Elems: elems}
+		a.Span = p.span(start)
+		return a
+	}
+	return p.parseMultiAssign(start, x)
+}
+
+// parseMultiAssign parses a multiple assignment. The first element of the
+// left hand side is given by first if it has been parsed.
+func (p *parser) parseMultiAssign(start int, first ast.Expr) ast.Expr {
+	var lhs []ast.Expr
+	if first != nil {
+		lhs = append(lhs, first)
+		p.expect(token.Comma)
+	}
+	lhs = append(lhs, p.parseMlhs(token.Assign)...)
+	if first != nil {
+		lhs[0] = p.toLhs(first, p.pos)
+	}
+	if g, ok := lhs[0].(*ast.MultiAssign); ok && len(lhs) == 1 {
+		lhs = g.Lhs // (a, b) = 1, 2
+	}
+	p.expect(token.Assign)
+	p.skipNewLines()
+	elems := []ast.Expr{p.parseRhsElem()}
+	for p.got(token.Comma) {
+		p.skipNewLines()
+		elems = append(elems, p.parseRhsElem())
+	}
+	rhs := elems[0]
+	if _, ok := rhs.(*ast.Splat); ok || len(elems) > 1 {
+		rhs = &ast.ArrayLit{Span: p.span(elems[0].Pos()), Elems: elems}
+	}
+	return &ast.MultiAssign{Span: p.span(start), Lhs: lhs, Rhs: rhs}
+}
+
+// parseMlhs parses the elements of the left hand side of a multiple
+// assignment until the token end.
+func (p *parser) parseMlhs(end token.Token) []ast.Expr {
+	var lhs []ast.Expr
+	for p.tok != end {
+		switch {
+		case p.tok == token.Mul:
+			s := &ast.Splat{Span: ast.Span{Start: p.pos, Stop: p.end}}
+			p.next()
+			if p.tok != token.Comma && p.tok != end {
+				s.Value = p.parseUnary()
+				s.Span = p.span(s.Start)
+			}
+			lhs = append(lhs, s)
+		case p.tok == token.LParen && p.atMlhsGroup():
+			lhs = append(lhs, p.parseMlhsGroup())
+		default:
+			lhs = append(lhs, p.parseUnary())
+		}
+		if !p.got(token.Comma) {
+			break
+		}
+	}
+	for i, x := range lhs {
+		lhs[i] = p.toLhs(x, p.pos)
+	}
+	return lhs
+}
+
+// parseMlhsGroup parses the nested left hand side such as (b, c) in
+// a, (b, c) = 1, [2, 3], which is a MultiAssign without the right hand side.
+func (p *parser) parseMlhsGroup() ast.Expr {
+	start := p.pos
+	p.next() // (
+	lhs := p.parseMlhs(token.RParen)
+	p.expect(token.RParen)
+	return &ast.MultiAssign{Span: p.span(start), Lhs: lhs}
+}
+
+// atMlhsGroup returns whether the parenthesis at the current token begins
+// a nested left hand side, which has a comma inside, or is followed by a
+// comma, =, or the closing parenthesis of the outer group.
+func (p *parser) atMlhsGroup() bool {
+	depth := 0
+	for i := 0; ; i++ {
+		switch p.peek(i).tok {
+		case token.LParen, token.LBracket, token.LBrace, token.InsertBegin:
+			depth++
+		case token.RParen, token.RBracket, token.RBrace, token.InsertEnd:
+			depth--
+			if depth == 0 {
+				switch p.peek(i + 1).tok {
+				case token.Comma, token.Assign, token.RParen:
+					return true
+				}
+				return false
+			}
+		case token.Comma:
+			if depth == 1 {
+				return true
+			}
+		case token.NewLine, token.Semicolon:
+			if depth == 1 {
+				return false
+			}
+		case token.EOF:
+			return false
+		}
+	}
+}
+
+func (p *parser) parseRhsElem() ast.Expr {
+	if p.tok == token.Mul {
+		return p.parseSplat()
+	}
+	return p.parseArg()
+}
+
+// parseBodyStmt parses the body with rescue, else, and ensure clauses. The
+// caller consumes the following end.
+func (p *parser) parseBodyStmt() *ast.BodyStmt {
+	body := &ast.BodyStmt{}
+	body.Body = p.parseStmts(token.KeywordRescue, token.KeywordElse, token.KeywordEnsure, token.KeywordEnd)
+	for p.tok == token.KeywordRescue {
+		body.Rescues = append(body.Rescues, p.parseRescue())
+	}
+	if p.got(token.KeywordElse) {
+		body.Else = p.parseStmts(token.KeywordEnsure, token.KeywordEnd)
+	}
+	if p.got(token.KeywordEnsure) {
+		body.Ensure = p.parseStmts(token.KeywordEnd)
+	}
+	body.Span = p.span(body.Body.Start)
+	if body.Stop < body.Start {
+		body.Stop = body.Start // empty body
+	}
+	return body
+}
+
+func (p *parser) parseRescue() *ast.Rescue {
+	start := p.pos
+	p.next() // rescue
+	r := &ast.Rescue{}
+	for p.tok != token.Arrow && p.tok != token.KeywordThen && p.tok != token.NewLine {
+		if p.tok == token.Mul {
+			r.Classes = append(r.Classes, p.parseSplat())
+		} else {
+			r.Classes = append(r.Classes, p.parseArg())
+		}
+		if !p.got(token.Comma) {
+			break
+		}
+		p.skipNewLines()
+	}
+	if p.tok == token.Arrow {
+		opPos := p.pos
+		p.next()
+		r.Var = p.toLhs(p.parseUnary(), opPos)
+	}
+	p.skipThen()
+	r.Body = p.parseStmts(token.KeywordRescue, token.KeywordElse, token.KeywordEnsure, token.KeywordEnd)
+	r.Span = p.span(start)
+	return r
+}
+
+// parseDef parses a method definition.
+func (p *parser) parseDef() ast.Expr {
+	start := p.pos
+	p.next() // def
+	def := &ast.Def{}
+	if p.peek(1).tok == token.Dot {
+		switch p.tok {
+		case token.KeywordSelf:
+			def.Singleton = &ast.PseudoVar{Span: ast.Span{Start: p.pos, Stop: p.end}, Kind: p.tok}
+		case token.IdentLocalVar:
+			def.Singleton = &ast.LocalVar{Span: ast.Span{Start: p.pos, Stop: p.end}, Name: string(p.lit)}
+		case token.IdentConst:
+			def.Singleton = &ast.Const{Span: ast.Span{Start: p.pos, Stop: p.end}, Name: string(p.lit)}
+		default:
+			p.errorExpected(token.IdentLocalVar)
+		}
+		p.next()
+		p.next()
+	}
+	def.Name = p.parseMethodName()
+
+	outer := p.scope
+	p.pushScope(false)
+	defer p.popScope(outer)
+
+	switch {
+	case p.tok == token.LParen:
+		pstart := p.pos
+		p.next()
+		def.Params = p.parseParams(token.RParen, token.Question.Precedence())
+		p.expectClosing(token.RParen)
+		def.Params.Span = p.span(pstart)
+	case p.tok != token.NewLine:
+		def.Params = p.parseParams(token.NewLine, token.Question.Precedence())
+	}
+	def.Body = p.parseBodyStmt()
+	p.expectClosing(token.KeywordEnd)
+	def.Span = p.span(start)
+	return def
+}
+
+// parseMethodName parses the name of method definition, alias, or undef.
+func (p *parser) parseMethodName() string {
+	t := p.tok
+	if !t.IsIdent() && !t.IsKeyword() && !t.IsOperatorMethod() && t != token.Not && t != token.NotEqual && t != token.NotMatch {
+		p.errorExpected(token.IdentLocalVar)
+	}
+	name := p.name()
+	p.next()
+	if (t == token.IdentLocalVar || t == token.IdentConst) && p.tok == token.Assign && p.pos == p.prevEnd {
+		// setter such as a= in def a=(v)
+		name += "="
+		p.next()
+	}
+	return name
+}
+
+// parseCPath parses the path of class or module definition, such as A::B.
+func (p *parser) parseCPath() *ast.Const {
+	start := p.pos
+	var c *ast.Const
+	if p.got(token.Colon2) {
+		c = &ast.Const{Top: true}
+	} else {
+		if p.tok != token.IdentConst {
+			p.errorExpected(token.IdentConst)
+		}
+		c = &ast.Const{Name: string(p.lit)}
+		p.next()
+		c.Span = p.span(start)
+		if !p.got(token.Colon2) {
+			return c
+		}
+		c = &ast.Const{Scope: c}
+	}
+	for {
+		if p.tok != token.IdentConst {
+			p.errorExpected(token.IdentConst)
+		}
+		c.Name = string(p.lit)
+		p.next()
+		c.Span = p.span(start)
+		if !p.got(token.Colon2) {
+			return c
+		}
+		c = &ast.Const{Scope: c}
+	}
+}
+
+// parseClass parses a class definition or a singleton class definition.
+func (p *parser) parseClass() ast.Expr {
+	start := p.pos
+	p.next() // class
+
+	outer := p.scope
+	defer p.popScope(outer)
+
+	if p.got(token.LShift) {
+		target := p.parseExpr()
+		p.pushScope(false)
+		body := p.parseBodyStmt()
+		p.expectClosing(token.KeywordEnd)
+		return &ast.SingletonClassDef{Span: p.span(start), Target: target, Body: body}
+	}
+	class := &ast.ClassDef{Path: p.parseCPath()}
+	if p.got(token.Lt) {
+		class.Super = p.parseArg()
+	}
+	p.pushScope(false)
+	class.Body = p.parseBodyStmt()
+	p.expectClosing(token.KeywordEnd)
+	class.Span = p.span(start)
+	return class
+}
+
+// parseModule parses a module definition.
+func (p *parser) parseModule() ast.Expr {
+	start := p.pos
+	p.next() // module
+	path := p.parseCPath()
+
+	outer := p.scope
+	p.pushScope(false)
+	defer p.popScope(outer)
+
+	body := p.parseBodyStmt()
+	p.expectClosing(token.KeywordEnd)
+	return &ast.ModuleDef{Span: p.span(start), Path: path, Body: body}
+}
+
+// parseCond parses the condition of if and loops. The do keyword in the
+// condition is taken by the loop.
+func (p *parser) parseCond() ast.Expr {
+	p.noDo++
+	x := p.parseExprStmt()
+	p.noDo--
+	return x
+}
+
+// parseIf parses if or unless expression.
+func (p *parser) parseIf() ast.Expr {
+	x := p.parseIfClause()
+	p.expectClosing(token.KeywordEnd)
+	x.Span = p.span(x.Start)
+	return x
+}
+
+// parseIfClause parses if, unless, or elsif clause except the end.
+func (p *parser) parseIfClause() *ast.If {
+	start := p.pos
+	x := &ast.If{Unless: p.tok == token.KeywordUnless}
+	p.next()
+	x.Cond = p.parseCond()
+	p.skipThen()
+	if x.Unless {
+		x.Then = p.parseStmts(token.KeywordElse, token.KeywordEnd)
+	} else {
+		x.Then = p.parseStmts(token.KeywordElsif, token.KeywordElse, token.KeywordEnd)
+	}
+	switch p.tok {
+	case token.KeywordElsif:
+		x.Else = p.parseIfClause()
+	case token.KeywordElse:
+		p.next()
+		x.Else = p.parseStmts(token.KeywordEnd)
+	}
+	x.Span = p.span(start)
+	return x
+}
+
+// parseWhile parses while or until loop.
+func (p *parser) parseWhile() ast.Expr {
+	start := p.pos
+	until := p.tok == token.KeywordUntil
+	p.next()
+	cond := p.parseCond()
+	p.skipNewLines()
+	p.got(token.KeywordDo)
+	body := p.parseStmts(token.KeywordEnd)
+	p.expectClosing(token.KeywordEnd)
+	return &ast.While{Span: p.span(start), Until: until, Cond: cond, Body: body}
+}
+
+// parseFor parses for loop.
+func (p *parser) parseFor() ast.Expr {
+	start := p.pos
+	p.next() // for
+	var vars []ast.Expr
+	for {
+		x := p.parsePostfix(p.parsePrimary())
+		vars = append(vars, x)
+		if !p.got(token.Comma) {
+			break
+		}
+	}
+	for i, x := range vars {
+		vars[i] = p.toLhs(x, p.pos)
+	}
+	p.expect(token.KeywordIn)
+	iter := p.parseCond()
+	p.skipNewLines()
+	p.got(token.KeywordDo)
+	body := p.parseStmts(token.KeywordEnd)
+	p.expectClosing(token.KeywordEnd)
+	return &ast.For{Span: p.span(start), Vars: vars, Iter: iter, Body: body}
+}
+
+// parseCase parses case expression.
+func (p *parser) parseCase() ast.Expr {
+	start := p.pos
+	p.next() // case
+	x := &ast.Case{}
+	if p.tok != token.NewLine {
+		x.Subject = p.parseExprStmt()
+	}
+	p.skipNewLines()
+	if p.tok != token.KeywordWhen {
+		p.errorExpected(token.KeywordWhen)
+	}
+	for p.tok == token.KeywordWhen {
+		wstart := p.pos
+		p.next()
+		w := &ast.When{}
+		for {
+			p.skipNewLines()
+			if p.tok == token.Mul {
+				w.Conds = append(w.Conds, p.parseSplat())
+			} else {
+				w.Conds = append(w.Conds, p.parseArg())
+			}
+			if !p.got(token.Comma) {
+				break
+			}
+		}
+		p.skipThen()
+		w.Body = p.parseStmts(token.KeywordWhen, token.KeywordElse, token.KeywordEnd)
+		w.Span = p.span(wstart)
+		x.Whens = append(x.Whens, w)
+	}
+	if p.got(token.KeywordElse) {
+		x.Else = p.parseStmts(token.KeywordEnd)
+	}
+	p.expectClosing(token.KeywordEnd)
+	x.Span = p.span(start)
+	return x
+}
+
+// parseBegin parses begin...end block.
+func (p *parser) parseBegin() ast.Expr {
+	start := p.pos
+	p.next() // begin
+	body := p.parseBodyStmt()
+	p.expectClosing(token.KeywordEnd)
+	return &ast.Begin{Span: p.span(start), Body: body}
+}
+
+// parseJumpArgs parses the arguments of return, break, next, and yield
+// without parentheses.
+func (p *parser) parseJumpArgs() []ast.Expr {
+	if !p.canBeginArg() {
+		return nil
+	}
+	p.noDo++
+	defer func() { p.noDo-- }()
+	return p.parseCallArgs(token.None)
+}
+
+// parseYieldArgs parses the arguments of yield and super.
+func (p *parser) parseYieldArgs() (args []ast.Expr, parens bool) {
+	if p.tok == token.LParen && !p.space {
+		p.next()
+		noDo := p.noDo
+		p.noDo = 0
+		args = p.parseCallArgs(token.RParen)
+		p.noDo = noDo
+		p.expectClosing(token.RParen)
+		if args == nil {
+			args = []ast.Expr{}
+		}
+		return args, true
+	}
+	if p.space {
+		return p.parseJumpArgs(), false
+	}
+	return nil, false
+}
+
+// parseAliasName parses a name of alias or undef as a symbol.
+func (p *parser) parseAliasName() ast.Expr {
+	start := p.pos
+	switch p.tok {
+	case token.IdentGlobalVar:
+		name := string(p.lit)
+		p.next()
+		return &ast.GlobalVar{Span: p.span(start), Name: name}
+	case token.Colon, token.Symbol:
+		return p.parsePrimary()
+	}
+	name := p.parseMethodName()
+	return &ast.SymbolLit{Span: p.span(start), Name: name}
+}
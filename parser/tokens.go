@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"github.com/harukasan/ringo/scanner"
+	"github.com/harukasan/ringo/token"
+)
+
+// tokenInfo is a token read from the scanner.
+type tokenInfo struct {
+	tok   token.Token
+	pos   int // offset of the first character
+	end   int // offset just after the last character
+	lit   []byte
+	space bool // whether the token is preceded by white spaces
+}
+
+// scanAll reads all tokens from the scanner. The body of heredocs are moved
+// after the beginning of the heredocs, so that the parser reads the heredoc
+// as a sequence of tokens from HeredocBegin to HeredocEnd.
+func scanAll(s *scanner.Scanner) []tokenInfo {
+	var toks []tokenInfo
+	prevEnd := 0
+	for {
+		pos, tok, lit := s.Scan()
+		t := tokenInfo{tok: tok, pos: pos, end: s.Offset(), lit: lit, space: pos > prevEnd}
+		if tok == token.EOF {
+			t.end = pos
+		}
+		prevEnd = t.end
+		toks = append(toks, splitToken(toks, t)...)
+		if tok == token.EOF {
+			break
+		}
+	}
+	toks, _ = moveHeredocs(toks, 0, false)
+	return toks
+}
+
+// splitToken splits the identifier followed by = such as x of x=1. The
+// identifier is scanned as a setter method name by the scanner, but it is a
+// name of the setter only after def or in a symbol.
+func splitToken(toks []tokenInfo, t tokenInfo) []tokenInfo {
+	n := len(t.lit)
+	if t.tok != token.IdentLocalMethod || n < 2 || t.lit[n-1] != '=' {
+		return []tokenInfo{t}
+	}
+	if len(toks) > 0 {
+		prev := toks[len(toks)-1]
+		switch {
+		case prev.tok == token.KeywordDef:
+			return []tokenInfo{t}
+		case prev.tok == token.Colon && prev.end == t.pos:
+			return []tokenInfo{t}
+		case prev.tok == token.Dot && len(toks) > 2 && toks[len(toks)-3].tok == token.KeywordDef:
+			return []tokenInfo{t}
+		}
+	}
+	ident := t
+	ident.tok, ident.end, ident.lit = token.IdentLocalVar, t.end-1, t.lit[:n-1]
+	assign := tokenInfo{tok: token.Assign, pos: t.end - 1, end: t.end}
+	return []tokenInfo{ident, assign}
+}
+
+// moveHeredocs moves the bodies of heredocs in toks[i:] after their
+// beginnings. If body is true, it stops at the end of the heredoc body. It
+// returns the moved tokens and the index of the next token.
+func moveHeredocs(toks []tokenInfo, i int, body bool) ([]tokenInfo, int) {
+	var out []tokenInfo
+	var pending []int               // indices of HeredocBegin in out
+	bodies := map[int][]tokenInfo{} // bodies by the index of HeredocBegin
+	depth := 0
+	for i < len(toks) {
+		t := toks[i]
+		i++
+		out = append(out, t)
+		switch t.tok {
+		case token.HeredocBegin:
+			pending = append(pending, len(out)-1)
+		case token.InsertBegin:
+			depth++
+		case token.InsertEnd:
+			depth--
+		case token.NewLine:
+			for _, h := range pending {
+				bodies[h], i = moveHeredocs(toks, i, true)
+			}
+			pending = pending[:0]
+		case token.HeredocEnd:
+			if body && depth == 0 {
+				return splice(out, bodies), i
+			}
+		case token.EOF:
+			if body {
+				// the body is not terminated; leave EOF for the parser.
+				return splice(out[:len(out)-1], bodies), i - 1
+			}
+		}
+	}
+	return splice(out, bodies), i
+}
+
+// splice inserts the bodies after the tokens at the indices.
+func splice(toks []tokenInfo, bodies map[int][]tokenInfo) []tokenInfo {
+	if len(bodies) == 0 {
+		return toks
+	}
+	var out []tokenInfo
+	for i, t := range toks {
+		out = append(out, t)
+		out = append(out, bodies[i]...)
+	}
+	return out
+}
@@ -0,0 +1,699 @@
+package ripper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/harukasan/ringo/scanner"
+	"github.com/harukasan/ringo/token"
+)
+
+// State is a lexer state of MRI such as EXPR_BEG. ringo's scanner has no
+// such states, so the state of a token is approximated from the tokens
+// around it. Multiple states are combined by or.
+type State int
+
+// Lexer states, in the order of Ripper::Lexer::State#to_s.
+const (
+	StateBeg State = 1 << iota
+	StateEnd
+	StateEndArg
+	StateEndFn
+	StateArg
+	StateCmdArg
+	StateMid
+	StateFName
+	StateDot
+	StateClass
+	StateLabel
+	StateLabeled
+	StateFItem
+)
+
+var stateNames = []string{
+	"BEG", "END", "ENDARG", "ENDFN", "ARG", "CMDARG", "MID",
+	"FNAME", "DOT", "CLASS", "LABEL", "LABELED", "FITEM",
+}
+
+func (s State) String() string {
+	if s == 0 {
+		return "NONE"
+	}
+	var names []string
+	for i, name := range stateNames {
+		if s&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Token is a token in the format of Ripper.lex. Line starts at 1 and Col
+// counts bytes from 0, as Ripper does.
+type Token struct {
+	Line  int
+	Col   int
+	Kind  string // event name such as on_ident
+	Text  string
+	State State
+}
+
+// String returns the token as an element of Ripper.lex, such as
+// [[1, 0], :on_ident, "puts", CMDARG].
+func (t Token) String() string {
+	return fmt.Sprintf("[[%d, %d], :%s, %s, %v]", t.Line, t.Col, t.Kind, quote(t.Text), t.State)
+}
+
+// Lex splits src into tokens as Ripper.lex. Unlike Ripper, the white spaces
+// and comments are rebuilt from the gaps between the scanned tokens, and the
+// illegal tokens are dropped.
+func Lex(src []byte) []Token {
+	l := &lexer{
+		src:      src,
+		lines:    lineOffsets(src),
+		cmdStart: true,
+		state:    StateBeg,
+		locals:   map[string]bool{},
+	}
+	l.scan()
+	l.run()
+	return l.toks
+}
+
+// WriteLex writes the tokens of src to w, one per line.
+func WriteLex(w io.Writer, src []byte) error {
+	for _, t := range Lex(src) {
+		if _, err := fmt.Fprintln(w, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rawToken is a token read from the scanner.
+type rawToken struct {
+	tok token.Token
+	pos int
+	end int
+	lit []byte
+}
+
+// kinds of literals opened in the lexer
+type literal int
+
+const (
+	litString  literal = iota // strings and symbols
+	litWords                  // word and symbol lists
+	litRegexp                 // regexps
+	litHeredoc                // heredoc bodies
+	litInsert                 // #{} in the literals
+)
+
+type lexer struct {
+	src   []byte
+	lines []int // offsets of the beginnings of lines
+	raw   []rawToken
+	i     int // index of the current raw token
+	off   int // offset just after the last emitted token
+	toks  []Token
+	done  bool // whether __END__ is found
+
+	state    State
+	cmdStart bool      // whether the next token begins a command
+	def      bool      // whether the next name is a method name of def
+	symbol   bool      // whether the next name is a symbol
+	lambda   bool      // whether the next { begins the body of ->
+	block    bool      // whether the last token begins a block
+	params   bool      // whether in block parameters
+	lits     []literal // stack of the opened literals
+	locals   map[string]bool
+}
+
+func lineOffsets(src []byte) []int {
+	lines := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+func (l *lexer) scan() {
+	// the scanner decodes escape sequences in place, so the token text is
+	// taken from the raw source.
+	buf := make([]byte, len(l.src))
+	copy(buf, l.src)
+	s := scanner.New(buf)
+	for {
+		pos, tok, lit := s.Scan()
+		t := rawToken{tok: tok, pos: pos, end: s.Offset(), lit: lit}
+		if tok == token.EOF {
+			t.end = pos
+		}
+		l.raw = append(l.raw, t)
+		if tok == token.EOF {
+			return
+		}
+	}
+}
+
+// peek returns the raw token after the current token.
+func (l *lexer) peek(n int) rawToken {
+	if l.i+n < len(l.raw) {
+		return l.raw[l.i+n]
+	}
+	return l.raw[len(l.raw)-1]
+}
+
+func (l *lexer) top() (literal, bool) {
+	if len(l.lits) == 0 {
+		return 0, false
+	}
+	return l.lits[len(l.lits)-1], true
+}
+
+func (l *lexer) push(lit literal) { l.lits = append(l.lits, lit) }
+func (l *lexer) pop()             { l.lits = l.lits[:len(l.lits)-1] }
+
+// inLiteral returns whether the current token is in the content of literal.
+func (l *lexer) inLiteral() bool {
+	lit, ok := l.top()
+	return ok && lit != litInsert
+}
+
+// emit appends the token of src[pos:end] and changes the state.
+func (l *lexer) emit(kind string, pos, end int, state State) {
+	if pos >= end {
+		return
+	}
+	l.state = state
+	l.cmdStart = false
+	l.block = false
+	l.trivia(kind, pos, end)
+}
+
+// trivia appends the token which does not change the state.
+func (l *lexer) trivia(kind string, pos, end int) {
+	line := sort.SearchInts(l.lines, pos+1)
+	l.toks = append(l.toks, Token{
+		Line:  line,
+		Col:   pos - l.lines[line-1],
+		Kind:  kind,
+		Text:  string(l.src[pos:end]),
+		State: l.state,
+	})
+	l.off = end
+}
+
+func (l *lexer) run() {
+	for ; l.i < len(l.raw) && !l.done; l.i++ {
+		t := l.raw[l.i]
+		if t.tok == token.EOF {
+			break
+		}
+		if t.pos > l.off {
+			l.gap(l.off, t.pos)
+		}
+		if t.end <= l.off || l.done {
+			continue
+		}
+		l.token(t)
+	}
+	if !l.done && l.off < len(l.src) {
+		l.gap(l.off, len(l.src))
+	}
+}
+
+// gap emits white spaces, comments and embedded documents in src[pos:end].
+func (l *lexer) gap(pos, end int) {
+	if lit, ok := l.top(); ok && lit == litWords {
+		l.trivia("on_words_sep", pos, end)
+		return
+	}
+	src := l.src
+	for pos < end && !l.done {
+		bol := pos == 0 || src[pos-1] == '\n'
+		i := pos
+		switch {
+		case bol && bytes.HasPrefix(src[pos:], []byte("__END__")) && lineEnd(src, pos) == pos+7:
+			l.trivia("on___end__", pos, nextLine(src, pos))
+			l.done = true
+			return
+		case bol && bytes.HasPrefix(src[pos:], []byte("=begin")):
+			i = nextLine(src, pos)
+			l.trivia("on_embdoc_beg", pos, i)
+			for i < end && !bytes.HasPrefix(src[i:], []byte("=end")) {
+				j := nextLine(src, i)
+				l.trivia("on_embdoc", i, j)
+				i = j
+			}
+			j := nextLine(src, i)
+			l.trivia("on_embdoc_end", i, j)
+			i = j
+		case src[i] == '#':
+			i = lineEnd(src, i)
+			if i < len(src) {
+				i++ // the comment includes the new line
+			}
+			if i > end {
+				// the new line is scanned as a token, which is taken here.
+				l.newLine(i)
+			}
+			l.trivia("on_comment", pos, i)
+		case src[i] == '\n':
+			i++
+			l.trivia("on_ignored_nl", pos, i)
+		default:
+			for i < end {
+				if src[i] == '\\' && i+1 < end && src[i+1] == '\n' {
+					i += 2
+					continue
+				}
+				if src[i] == '\n' || src[i] == '#' {
+					break
+				}
+				i++
+			}
+			if i == pos {
+				i++
+			}
+			l.trivia("on_sp", pos, i)
+		}
+		pos = i
+	}
+}
+
+// newLine handles the new line taken by a comment. The new line ends the
+// statement unless the state begins an expression.
+func (l *lexer) newLine(end int) {
+	if next := l.peek(0); next.tok == token.NewLine && next.end <= end {
+		if !l.ignoreNewLine() {
+			l.state = StateBeg
+			l.cmdStart = true
+		}
+	}
+}
+
+// ignoreNewLine returns whether the new line does not end the statement.
+func (l *lexer) ignoreNewLine() bool {
+	return l.state&(StateBeg|StateClass|StateFName|StateDot) != 0 && l.state&StateLabeled == 0
+}
+
+func lineEnd(src []byte, pos int) int {
+	if i := bytes.IndexByte(src[pos:], '\n'); i >= 0 {
+		return pos + i
+	}
+	return len(src)
+}
+
+func nextLine(src []byte, pos int) int {
+	i := lineEnd(src, pos)
+	if i < len(src) {
+		i++
+	}
+	return i
+}
+
+// token emits the tokens for the raw token t.
+func (l *lexer) token(t rawToken) {
+	switch t.tok {
+	case token.Illegal:
+		l.off = t.end
+		return
+	case token.NewLine:
+		switch {
+		case l.src[t.pos] == ';':
+			l.emit("on_semicolon", t.pos, t.end, StateBeg)
+			l.cmdStart = true
+		case l.ignoreNewLine():
+			l.trivia("on_ignored_nl", t.pos, t.end)
+		default:
+			l.emit("on_nl", t.pos, t.end, StateBeg)
+			l.cmdStart = true
+			l.params = false
+		}
+		return
+	}
+	if l.literal(t) {
+		return
+	}
+	switch {
+	case t.tok.IsNumber():
+		pos := t.pos
+		if t.lit[0] == '-' {
+			l.emit("on_op", pos, pos+1, StateBeg)
+			pos++
+		}
+		l.emit(numberKind(t.tok), pos, t.end, StateEnd)
+	case t.tok.IsKeyword():
+		l.keyword(t)
+	case t.tok >= token.IdentConst:
+		l.ident(t)
+	default:
+		l.punct(t)
+	}
+}
+
+func numberKind(t token.Token) string {
+	switch t {
+	case token.Float:
+		return "on_float"
+	case token.Rational:
+		return "on_rational"
+	case token.Imaginary:
+		return "on_imaginary"
+	}
+	return "on_int"
+}
+
+// literal emits the tokens of strings, symbols, heredocs and other literals.
+// It returns false if t is not a part of the literals.
+func (l *lexer) literal(t rawToken) bool {
+	switch t.tok {
+	case token.String, token.StringPart:
+		if !l.inLiteral() {
+			l.openString(t, t.pos, t.pos+openerLen(l.src, t.pos))
+			return true
+		}
+		lit, _ := l.top()
+		if t.tok == token.StringPart || lit == litWords {
+			l.emit("on_tstring_content", t.pos, t.end, l.state)
+			return true
+		}
+		l.emit("on_tstring_content", t.pos, t.end-1, l.state)
+		l.emit("on_tstring_end", t.end-1, t.end, StateEnd)
+		l.pop()
+	case token.Symbol:
+		start := t.pos + openerLen(l.src, t.pos)
+		l.emit("on_symbeg", t.pos, start, StateFName)
+		l.emit("on_tstring_content", start, t.end-1, StateFName)
+		l.emit("on_tstring_end", t.end-1, t.end, StateEnd)
+	case token.HeredocBegin:
+		l.emit("on_heredoc_beg", t.pos, t.end, StateEnd)
+	case token.HeredocPart, token.HeredocEnd:
+		if lit, ok := l.top(); !ok || lit != litHeredoc {
+			l.push(litHeredoc)
+		}
+		if t.tok == token.HeredocPart {
+			l.trivia("on_tstring_content", t.pos, t.end)
+			return true
+		}
+		term := heredocTerm(l.src, t.pos, t.end)
+		l.trivia("on_tstring_content", t.pos, term)
+		l.trivia("on_heredoc_end", term, t.end)
+		l.pop()
+	case token.InsertBegin:
+		l.emit("on_embexpr_beg", t.pos, t.end, StateBeg)
+		l.push(litInsert)
+		l.cmdStart = true
+	case token.InsertEnd:
+		l.emit("on_embexpr_end", t.pos, t.end, StateEnd)
+		if lit, ok := l.top(); ok && lit == litInsert {
+			l.pop()
+		}
+	case token.WordsBegin, token.SymbolsBegin:
+		kind := map[byte]string{
+			'w': "on_qwords_beg", 'W': "on_words_beg",
+			'i': "on_qsymbols_beg", 'I': "on_symbols_beg",
+		}[t.lit[1]]
+		l.emit(kind, t.pos, t.end, StateBeg)
+		l.push(litWords)
+	case token.RegexpBegin:
+		l.emit("on_regexp_beg", t.pos, t.end, StateBeg)
+		l.push(litRegexp)
+	case token.CommandBegin:
+		l.emit("on_backtick", t.pos, t.end, StateBeg)
+		l.push(litString)
+	case token.LiteralEnd:
+		kind := "on_tstring_end"
+		if lit, ok := l.top(); ok && lit == litRegexp {
+			kind = "on_regexp_end"
+		}
+		l.emit(kind, t.pos, t.end, StateEnd)
+		if l.inLiteral() {
+			l.pop()
+		}
+	case token.IdentGlobalVar, token.IdentInstanceVar, token.IdentClassVar:
+		if !l.inLiteral() || l.src[t.pos] != '#' {
+			return false
+		}
+		l.emit("on_embvar", t.pos, t.pos+1, l.state)
+		l.emit(identKind(t.tok), t.pos+1, t.end, l.state)
+	default:
+		return false
+	}
+	return true
+}
+
+// heredocTerm returns the offset of the terminator line of the heredoc in
+// src[pos:end], which is the last line of the HeredocEnd token.
+func heredocTerm(src []byte, pos, end int) int {
+	if end > pos && src[end-1] == '\n' {
+		end--
+	}
+	return pos + bytes.LastIndexByte(src[pos:end], '\n') + 1
+}
+
+// openString emits the string t whose opening delimiter is src[pos:start].
+func (l *lexer) openString(t rawToken, pos, start int) {
+	l.emit("on_tstring_beg", pos, start, StateBeg)
+	if t.tok == token.StringPart {
+		l.emit("on_tstring_content", start, t.end, l.state)
+		l.push(litString)
+		return
+	}
+	l.emit("on_tstring_content", start, t.end-1, l.state)
+	l.emit("on_tstring_end", t.end-1, t.end, StateEnd)
+}
+
+// openerLen returns the length of the opening delimiter of the literal at
+// pos, such as " or %q(.
+func openerLen(src []byte, pos int) int {
+	if pos >= len(src) {
+		return 0
+	}
+	switch src[pos] {
+	case '"', '\'', '`':
+		return 1
+	case '%':
+		if pos+1 < len(src) && isAlpha(src[pos+1]) {
+			return 3
+		}
+		return 2
+	}
+	return 0
+}
+
+func isAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func identKind(t token.Token) string {
+	switch t {
+	case token.IdentConst:
+		return "on_const"
+	case token.IdentGlobalVar:
+		return "on_gvar"
+	case token.IdentInstanceVar:
+		return "on_ivar"
+	case token.IdentClassVar:
+		return "on_cvar"
+	}
+	return "on_ident"
+}
+
+// name returns the state of a method name, or 0 if the token is not a name.
+// The name is the name of a method definition, a symbol or a method call
+// with the receiver.
+func (l *lexer) name() State {
+	switch {
+	case l.def:
+		l.def = false
+		return StateEndFn
+	case l.symbol:
+		l.symbol = false
+		return StateEnd
+	case l.state&StateDot != 0:
+		return StateArg
+	}
+	return 0
+}
+
+func (l *lexer) ident(t rawToken) {
+	if t.tok == token.IdentLocalMethod && len(t.lit) > 1 && t.lit[len(t.lit)-1] == '=' && !l.def && !l.symbol {
+		// x of x=1 is scanned as a setter name.
+		name := string(t.lit[:len(t.lit)-1])
+		state := StateArg
+		switch {
+		case l.locals[name]:
+			state = StateEnd | StateLabel
+		case l.cmdStart:
+			state = StateCmdArg
+		}
+		l.locals[name] = true
+		l.emit("on_ident", t.pos, t.end-1, state)
+		l.emit("on_op", t.end-1, t.end, StateBeg)
+		return
+	}
+	kind := identKind(t.tok)
+	if state := l.name(); state != 0 {
+		l.emit(kind, t.pos, t.end, state)
+		return
+	}
+	next := l.peek(1)
+	if (t.tok == token.IdentLocalVar || t.tok == token.IdentLocalMethod || t.tok == token.IdentConst) &&
+		next.tok == token.Colon && next.pos == t.end && l.peek(2).pos > next.end {
+		l.emit("on_label", t.pos, next.end, StateArg|StateLabeled)
+		l.i++
+		return
+	}
+	local := t.tok != token.IdentConst && (l.params || l.locals[string(t.lit)])
+	if t.tok != token.IdentConst && (l.params || next.tok.IsAssignOp()) {
+		l.locals[string(t.lit)] = true
+	}
+	var state State
+	switch {
+	case kind != "on_ident" && kind != "on_const":
+		state = StateEnd
+	case local:
+		state = StateEnd | StateLabel
+	case l.cmdStart:
+		state = StateCmdArg
+	default:
+		state = StateArg
+	}
+	l.emit(kind, t.pos, t.end, state)
+}
+
+var keywordStates = map[token.Token]State{
+	token.KeywordDef:      StateFName,
+	token.KeywordAlias:    StateFName | StateFItem,
+	token.KeywordUndef:    StateFName | StateFItem,
+	token.KeywordClass:    StateClass,
+	token.KeywordEnd:      StateEnd,
+	token.KeywordSelf:     StateEnd,
+	token.KeywordNil:      StateEnd,
+	token.KeywordTrue:     StateEnd,
+	token.KeywordFalse:    StateEnd,
+	token.KeywordRedo:     StateEnd,
+	token.KeywordRetry:    StateEnd,
+	token.KeywordLINE:     StateEnd,
+	token.KeywordFILE:     StateEnd,
+	token.KeywordENCODING: StateEnd,
+	token.KeywordReturn:   StateMid,
+	token.KeywordBreak:    StateMid,
+	token.KeywordNext:     StateMid,
+	token.KeywordDefined:  StateArg,
+	token.KeywordSuper:    StateArg,
+	token.KeywordYield:    StateArg,
+}
+
+func (l *lexer) keyword(t rawToken) {
+	if l.state&StateDot != 0 && !l.def {
+		// a keyword after . is a method name.
+		l.emit("on_ident", t.pos, t.end, StateArg)
+		return
+	}
+	if state := l.name(); state != 0 {
+		l.emit("on_kw", t.pos, t.end, state)
+		return
+	}
+	state, ok := keywordStates[t.tok]
+	if !ok {
+		state = StateBeg
+	}
+	l.emit("on_kw", t.pos, t.end, state)
+	switch t.tok {
+	case token.KeywordDef:
+		l.def = true
+	case token.KeywordDo:
+		l.lambda = false
+	}
+	l.cmdStart = state == StateBeg
+	l.block = t.tok == token.KeywordDo
+}
+
+func (l *lexer) punct(t rawToken) {
+	next := l.peek(1)
+	switch t.tok {
+	case token.Colon:
+		switch {
+		case next.pos == t.end && (next.tok == token.String || next.tok == token.StringPart):
+			start := next.pos + openerLen(l.src, next.pos)
+			l.emit("on_symbeg", t.pos, start, StateFName)
+			l.i++
+			l.openString(next, start, start)
+		case next.pos == t.end && next.tok != token.NewLine && next.tok != token.EOF:
+			l.emit("on_symbeg", t.pos, t.end, StateFName)
+			l.symbol = true
+		default:
+			l.emit("on_op", t.pos, t.end, StateBeg)
+		}
+		return
+	case token.Minus:
+		if next.tok == token.Gt && next.pos == t.end {
+			l.emit("on_tlambda", t.pos, next.end, StateEndFn)
+			l.i++
+			l.lambda = true
+			return
+		}
+	case token.ElementRef, token.ElementSet:
+		if l.def || l.symbol || l.state&StateDot != 0 {
+			break
+		}
+		l.emit("on_lbracket", t.pos, t.pos+1, StateBeg|StateLabel)
+		l.emit("on_rbracket", t.pos+1, t.pos+2, StateEnd)
+		return
+	}
+	if state := l.name(); state != 0 {
+		l.emit("on_op", t.pos, t.end, state)
+		return
+	}
+	switch t.tok {
+	case token.Dot:
+		l.emit("on_period", t.pos, t.end, StateDot)
+	case token.Colon2:
+		l.emit("on_op", t.pos, t.end, StateDot)
+	case token.Comma:
+		l.emit("on_comma", t.pos, t.end, StateBeg|StateLabel)
+	case token.LParen:
+		l.emit("on_lparen", t.pos, t.end, StateBeg|StateLabel)
+		l.cmdStart = true
+	case token.RParen:
+		l.emit("on_rparen", t.pos, t.end, StateEndFn)
+	case token.LBracket:
+		l.emit("on_lbracket", t.pos, t.end, StateBeg|StateLabel)
+	case token.RBracket:
+		l.emit("on_rbracket", t.pos, t.end, StateEnd)
+	case token.LBrace:
+		switch {
+		case l.lambda:
+			l.emit("on_tlambeg", t.pos, t.end, StateBeg)
+			l.lambda = false
+		case l.state&StateBeg != 0:
+			l.emit("on_lbrace", t.pos, t.end, StateBeg|StateLabel)
+		default:
+			l.emit("on_lbrace", t.pos, t.end, StateBeg)
+			l.block = true
+		}
+		l.cmdStart = true
+	case token.RBrace:
+		l.emit("on_rbrace", t.pos, t.end, StateEnd)
+	case token.Or:
+		// | just after { or do begins the block parameters.
+		block := l.block
+		l.emit("on_op", t.pos, t.end, StateBeg|StateLabel)
+		if l.params {
+			l.params = false
+			l.cmdStart = true
+		} else if block {
+			l.params = true
+		}
+	default:
+		l.emit("on_op", t.pos, t.end, StateBeg)
+	}
+}
@@ -0,0 +1,161 @@
+package ripper
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harukasan/ringo/ast"
+)
+
+// The golden files in testdata are the outputs of Ripper, generated by
+// testdata/gen.rb. The -update flag rewrites them by the outputs of ringo,
+// which should be reviewed by diffing with the outputs of Ripper.
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.rb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if filepath.Base(file) == "gen.rb" {
+			continue
+		}
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		base := strings.TrimSuffix(file, ".rb")
+
+		var lex bytes.Buffer
+		if err := WriteLex(&lex, src); err != nil {
+			t.Fatal(err)
+		}
+		compareGolden(t, base+".lex", lex.Bytes())
+
+		var sexp bytes.Buffer
+		if err := WriteSexp(&sexp, file, src); err != nil {
+			t.Errorf("%v: err=%v (want=nil)", file, err)
+			continue
+		}
+		compareGolden(t, base+".sexp", sexp.Bytes())
+	}
+}
+
+func compareGolden(t *testing.T, file string, got []byte) {
+	if *update {
+		if err := ioutil.WriteFile(file, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotLines := strings.Split(string(got), "\n")
+	wantLines := strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Errorf("%v:%d: %v (want=%v)", file, i+1, g, w)
+			return
+		}
+	}
+}
+
+func TestLex(t *testing.T) {
+	rules := map[string][]string{
+		"a # c\n\nb": {
+			`[[1, 0], :on_ident, "a", CMDARG]`,
+			`[[1, 1], :on_sp, " ", CMDARG]`,
+			`[[1, 2], :on_comment, "# c\n", BEG]`,
+			`[[2, 0], :on_ignored_nl, "\n", BEG]`,
+			`[[3, 0], :on_ident, "b", CMDARG]`,
+		},
+		`:"a"`: {
+			`[[1, 0], :on_symbeg, ":\"", FNAME]`,
+			`[[1, 2], :on_tstring_content, "a", FNAME]`,
+			`[[1, 3], :on_tstring_end, "\"", END]`,
+		},
+		"x=1 +\n2": {
+			`[[1, 0], :on_ident, "x", CMDARG]`,
+			`[[1, 1], :on_op, "=", BEG]`,
+			`[[1, 2], :on_int, "1", END]`,
+			`[[1, 3], :on_sp, " ", END]`,
+			`[[1, 4], :on_op, "+", BEG]`,
+			`[[1, 5], :on_ignored_nl, "\n", BEG]`,
+			`[[2, 0], :on_int, "2", END]`,
+		},
+		"p 1\n__END__\nx": {
+			`[[1, 0], :on_ident, "p", CMDARG]`,
+			`[[1, 1], :on_sp, " ", CMDARG]`,
+			`[[1, 2], :on_int, "1", END]`,
+			`[[1, 3], :on_nl, "\n", BEG]`,
+			`[[2, 0], :on___end__, "__END__\n", BEG]`,
+		},
+		"=begin\nx\n=end\n": {
+			`[[1, 0], :on_embdoc_beg, "=begin\n", BEG]`,
+			`[[2, 0], :on_embdoc, "x\n", BEG]`,
+			`[[3, 0], :on_embdoc_end, "=end\n", BEG]`,
+		},
+	}
+	for input, want := range rules {
+		toks := Lex([]byte(input))
+		got := make([]string, len(toks))
+		for i, tok := range toks {
+			got[i] = tok.String()
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%q: tokens=\n%v\n(want=\n%v)", input, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	rules := map[string]interface{}{
+		`nil`:              nil,
+		`[1, false]`:       []interface{}{1, false},
+		`"a\n\#{b}\u0001"`: "a\n#{b}\x01",
+		`"\xFF"`:           "\xff",
+		`:a?`:              Symbol("a?"),
+		`:@@a`:             Symbol("@@a"),
+		`:[]=`:             Symbol("[]="),
+		`:"&&"`:            Symbol("&&"),
+		`:"::"`:            Symbol("::"),
+	}
+	for want, v := range rules {
+		var buf bytes.Buffer
+		inspect(&buf, v)
+		if got := buf.String(); got != want {
+			t.Errorf("%#v: inspect=%v (want=%v)", v, got, want)
+		}
+	}
+}
+
+func TestSexpErrors(t *testing.T) {
+	v, err := Sexp("t.rb", []byte("a = ("))
+	if v != nil || err == nil {
+		t.Errorf("value=%v err=%v (want=nil and errors)", v, err)
+	}
+}
+
+func TestSexpBadNodes(t *testing.T) {
+	src := []byte("a")
+	for _, e := range []ast.Expr{&ast.BadExpr{}, &ast.MissingExpr{}} {
+		b := &builder{filename: "t.rb", src: src, lines: lineOffsets(src)}
+		if v := b.expr(e); v != nil || b.errs.Err() == nil {
+			t.Errorf("%T: value=%v err=%v (want=nil and errors)", e, v, b.errs.Err())
+		}
+	}
+}
@@ -0,0 +1,893 @@
+package ripper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/parser"
+	"github.com/harukasan/ringo/token"
+)
+
+// Symbol is a symbol in the S-expression, such as :program.
+type Symbol string
+
+// Sexp parses src and returns the syntax tree as Ripper.sexp of Ruby 2.7.
+// The tree is a nested []interface{} of Symbol, string, int, bool and nil.
+// As Ripper.sexp, it returns nil with the errors if src has syntax errors.
+func Sexp(filename string, src []byte) (interface{}, error) {
+	f, err := parser.ParseFile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	b := &builder{filename: filename, src: src, lines: lineOffsets(src)}
+	v := list(Symbol("program"), b.stmts(f.Body))
+	if err := b.errs.Err(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// WriteSexp writes the S-expression of src to w as Ruby's p.
+func WriteSexp(w io.Writer, filename string, src []byte) error {
+	v, err := Sexp(filename, src)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	inspect(&buf, v)
+	buf.WriteByte('\n')
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func list(v ...interface{}) []interface{} {
+	return v
+}
+
+// inspect writes v as Ruby's inspect.
+func inspect(w *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.WriteString("nil")
+	case bool:
+		fmt.Fprint(w, v)
+	case int:
+		fmt.Fprint(w, v)
+	case string:
+		w.WriteString(quote(v))
+	case Symbol:
+		w.WriteString(v.String())
+	case []interface{}:
+		w.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			inspect(w, e)
+		}
+		w.WriteByte(']')
+	default:
+		panic(fmt.Sprintf("ripper: unexpected value %T", v))
+	}
+}
+
+var operatorSymbols = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true, "**": true,
+	"==": true, "===": true, "!=": true, "=~": true, "!~": true, "!": true,
+	"<": true, "<=": true, ">": true, ">=": true, "<=>": true, "<<": true,
+	">>": true, "&": true, "|": true, "^": true, "~": true, "+@": true,
+	"-@": true, "[]": true, "[]=": true, "`": true,
+}
+
+// String returns the symbol as Symbol#inspect, which quotes the name unless
+// it is a method name or a variable name.
+func (s Symbol) String() string {
+	name := string(s)
+	for _, prefix := range []string{"@@", "@", "$"} {
+		if strings.HasPrefix(name, prefix) {
+			name = name[len(prefix):]
+			break
+		}
+	}
+	if operatorSymbols[string(s)] || isName(name) {
+		return ":" + string(s)
+	}
+	return ":" + quote(string(s))
+}
+
+func isName(s string) bool {
+	if s == "" || '0' <= s[0] && s[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' || isAlpha(c) || '0' <= c && c <= '9' || c >= 0x80 {
+			continue
+		}
+		if i == len(s)-1 && (c == '?' || c == '!' || c == '=') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// builder builds the S-expression from the syntax tree.
+type builder struct {
+	filename string
+	src      []byte
+	lines    []int
+	errs     parser.ErrorList
+}
+
+// errorf records the error at the offset, for the node which can not be
+// given as the S-expression.
+func (b *builder) errorf(off int, format string, v ...interface{}) {
+	line := sort.SearchInts(b.lines, off+1)
+	b.errs = append(b.errs, &parser.Error{
+		Pos:      off,
+		Filename: b.filename,
+		Line:     line,
+		Column:   off - b.lines[line-1] + 1,
+		Msg:      fmt.Sprintf(format, v...),
+	})
+}
+
+// pos returns the position of the offset as [line, column].
+func (b *builder) pos(off int) []interface{} {
+	line := sort.SearchInts(b.lines, off+1)
+	return list(line, off-b.lines[line-1])
+}
+
+// tok returns the scanner event such as [:@ident, "a", [1, 0]].
+func (b *builder) tok(kind, text string, off int) []interface{} {
+	return list(Symbol("@"+kind), text, b.pos(off))
+}
+
+// find returns the offset of text after the offset from. It is used to
+// find the tokens which are not held by the nodes, such as method names.
+func (b *builder) find(from int, text string) int {
+	if from < 0 {
+		from = 0
+	}
+	if i := bytes.Index(b.src[from:], []byte(text)); i >= 0 {
+		return from + i
+	}
+	return from
+}
+
+// name returns the event of the method name at the offset.
+func (b *builder) name(name string, off int) []interface{} {
+	switch {
+	case operatorSymbols[name]:
+		return b.tok("op", name, off)
+	case token.Lookup(name).IsKeyword():
+		return b.tok("kw", name, off)
+	case name[0] >= 'A' && name[0] <= 'Z':
+		return b.tok("const", name, off)
+	}
+	return b.tok("ident", name, off)
+}
+
+func (b *builder) stmts(s *ast.Stmts) []interface{} {
+	if s == nil || len(s.List) == 0 {
+		return list(list(Symbol("void_stmt")))
+	}
+	l := make([]interface{}, len(s.List))
+	for i, e := range s.List {
+		l[i] = b.expr(e)
+	}
+	return l
+}
+
+func (b *builder) exprs(l []ast.Expr) []interface{} {
+	v := []interface{}{}
+	for _, e := range l {
+		v = append(v, b.expr(e))
+	}
+	return v
+}
+
+func (b *builder) bodyStmt(s *ast.BodyStmt) []interface{} {
+	if s == nil {
+		return list(Symbol("bodystmt"), b.stmts(nil), nil, nil, nil)
+	}
+	var rescue, els, ensure interface{}
+	for i := len(s.Rescues) - 1; i >= 0; i-- {
+		r := s.Rescues[i]
+		var classes, v interface{}
+		if len(r.Classes) > 0 {
+			classes = b.exprs(r.Classes)
+		}
+		if r.Var != nil {
+			v = b.field(r.Var)
+		}
+		rescue = list(Symbol("rescue"), classes, v, b.stmts(r.Body), rescue)
+	}
+	if s.Else != nil {
+		els = list(Symbol("else"), b.stmts(s.Else))
+	}
+	if s.Ensure != nil {
+		ensure = list(Symbol("ensure"), b.stmts(s.Ensure))
+	}
+	return list(Symbol("bodystmt"), b.stmts(s.Body), rescue, els, ensure)
+}
+
+func (b *builder) expr(e ast.Expr) interface{} {
+	switch e := e.(type) {
+	case *ast.NumberLit:
+		return b.number(e)
+	case *ast.StrLit:
+		return list(Symbol("string_literal"), b.content(b.strContent(e)...))
+	case *ast.InterpStr:
+		return list(Symbol("string_literal"), b.content(b.quoted(e.Span, e.Parts)...))
+	case *ast.Heredoc:
+		stop := e.Stop
+		if n := len(e.Parts); n > 0 {
+			stop = heredocTerm(b.src, e.Parts[n-1].Pos(), e.Parts[n-1].End())
+		}
+		return list(Symbol("string_literal"), b.content(b.parts(e.Parts, 0, stop)...))
+	case *ast.XStr:
+		return list(Symbol("xstring_literal"), b.quoted(e.Span, e.Parts))
+	case *ast.SymbolLit:
+		return b.symbol(e)
+	case *ast.InterpSymbol:
+		start := e.Start + 1 + openerLen(b.src, e.Start+1)
+		return list(Symbol("dyna_symbol"), b.content(b.parts(e.Parts, start, e.Stop-1)...))
+	case *ast.RegexpLit:
+		end := e.Stop - len(e.Options) - 1
+		start := e.Start + openerLen(b.src, e.Start)
+		return list(Symbol("regexp_literal"), b.parts(e.Parts, start, end), b.tok("regexp_end", string(b.src[end:e.Stop]), end))
+	case *ast.ArrayLit:
+		return b.array(e)
+	case *ast.HashLit:
+		if !e.Braces {
+			return list(Symbol("bare_assoc_hash"), b.assocs(e.Pairs))
+		}
+		if len(e.Pairs) == 0 {
+			return list(Symbol("hash"), nil)
+		}
+		return list(Symbol("hash"), list(Symbol("assoclist_from_args"), b.assocs(e.Pairs)))
+	case *ast.RangeLit:
+		op := "dot2"
+		if e.Exclusive {
+			op = "dot3"
+		}
+		return list(Symbol(op), b.optExpr(e.Low), b.optExpr(e.High))
+	case *ast.PseudoVar:
+		return list(Symbol("var_ref"), b.tok("kw", e.Kind.Text(), e.Start))
+	case *ast.LocalVar, *ast.InstanceVar, *ast.ClassVar, *ast.GlobalVar:
+		return list(Symbol("var_ref"), b.variable(e))
+	case *ast.Const:
+		return b.constant(e, "var_ref", "const_path_ref", "top_const_ref")
+	case *ast.Assign:
+		return b.assign(e)
+	case *ast.MultiAssign:
+		return list(Symbol("massign"), b.mlhs(e.Lhs), b.mrhs(e.Rhs))
+	case *ast.Binary:
+		return list(Symbol("binary"), b.expr(e.X), Symbol(e.Op.Text()), b.expr(e.Y))
+	case *ast.Unary:
+		op := e.Op.Text()
+		switch e.Op {
+		case token.Minus, token.Plus:
+			op += "@"
+		}
+		return list(Symbol("unary"), Symbol(op), b.expr(e.X))
+	case *ast.Defined:
+		return list(Symbol("defined"), b.expr(e.X))
+	case *ast.Call:
+		return b.call(e)
+	case *ast.Index:
+		return list(Symbol("aref"), b.expr(e.Recv), b.optArgs(e.Args))
+	case *ast.Yield:
+		switch {
+		case len(e.Args) == 0:
+			return list(Symbol("yield0"))
+		case b.parenAfter(e.Start + len("yield")):
+			return list(Symbol("yield"), list(Symbol("paren"), b.args(e.Args)))
+		}
+		return list(Symbol("yield"), b.args(e.Args))
+	case *ast.Super:
+		var call interface{}
+		switch {
+		case e.Args == nil:
+			call = list(Symbol("zsuper"))
+		case b.parenAfter(e.Start + len("super")):
+			call = list(Symbol("super"), b.argParen(e.Args))
+		default:
+			call = list(Symbol("super"), b.args(e.Args))
+		}
+		return b.addBlock(call, e.Block)
+	case *ast.Def:
+		return b.def(e)
+	case *ast.ClassDef:
+		return list(Symbol("class"), b.constant(e.Path, "const_ref", "const_path_ref", "top_const_ref"), b.optExpr(e.Super), b.bodyStmt(e.Body))
+	case *ast.SingletonClassDef:
+		return list(Symbol("sclass"), b.expr(e.Target), b.bodyStmt(e.Body))
+	case *ast.ModuleDef:
+		return list(Symbol("module"), b.constant(e.Path, "const_ref", "const_path_ref", "top_const_ref"), b.bodyStmt(e.Body))
+	case *ast.Alias:
+		if _, ok := e.New.(*ast.GlobalVar); ok {
+			return list(Symbol("var_alias"), b.variable(e.New), b.variable(e.Old))
+		}
+		return list(Symbol("alias"), b.methodSymbol(e.New), b.methodSymbol(e.Old))
+	case *ast.Undef:
+		names := make([]interface{}, len(e.Names))
+		for i, n := range e.Names {
+			names[i] = b.methodSymbol(n)
+		}
+		return list(Symbol("undef"), names)
+	case *ast.If:
+		return b.ifExpr(e)
+	case *ast.Ternary:
+		return list(Symbol("ifop"), b.expr(e.Cond), b.expr(e.Then), b.expr(e.Else))
+	case *ast.While:
+		kind := "while"
+		if e.Until {
+			kind = "until"
+		}
+		if e.Mod {
+			return list(Symbol(kind+"_mod"), b.expr(e.Cond), b.modBody(e.Body))
+		}
+		return list(Symbol(kind), b.expr(e.Cond), b.stmts(e.Body))
+	case *ast.For:
+		var v interface{}
+		if len(e.Vars) == 1 {
+			v = b.field(e.Vars[0])
+		} else {
+			v = b.mlhs(e.Vars)
+		}
+		return list(Symbol("for"), v, b.expr(e.Iter), b.stmts(e.Body))
+	case *ast.Case:
+		var next interface{}
+		if e.Else != nil {
+			next = list(Symbol("else"), b.stmts(e.Else))
+		}
+		for i := len(e.Whens) - 1; i >= 0; i-- {
+			w := e.Whens[i]
+			next = list(Symbol("when"), b.argList(w.Conds), b.stmts(w.Body), next)
+		}
+		return list(Symbol("case"), b.optExpr(e.Subject), next)
+	case *ast.Return:
+		if len(e.Args) == 0 {
+			return list(Symbol("return0"))
+		}
+		return list(Symbol("return"), b.args(e.Args))
+	case *ast.Break:
+		return list(Symbol("break"), b.jumpArgs(e.Args))
+	case *ast.Next:
+		return list(Symbol("next"), b.jumpArgs(e.Args))
+	case *ast.Redo:
+		return list(Symbol("redo"))
+	case *ast.Retry:
+		return list(Symbol("retry"))
+	case *ast.Begin:
+		return list(Symbol("begin"), b.bodyStmt(e.Body))
+	case *ast.RescueMod:
+		return list(Symbol("rescue_mod"), b.expr(e.X), b.expr(e.Rescue))
+	case *ast.Paren:
+		return list(Symbol("paren"), b.stmts(e.Body))
+	case *ast.Splat:
+		return list(Symbol("splat"), b.optExpr(e.Value))
+	case *ast.BadExpr, *ast.MissingExpr:
+		// the parser reports the errors with them, but Sexp must not give
+		// the broken tree even if it does not.
+		b.errorf(e.Pos(), "syntax error")
+		return nil
+	}
+	b.errorf(e.Pos(), "unsupported node %T", e)
+	return nil
+}
+
+func (b *builder) optExpr(e ast.Expr) interface{} {
+	if e == nil {
+		return nil
+	}
+	return b.expr(e)
+}
+
+// modBody returns the body of modifiers, which is a single statement.
+func (b *builder) modBody(s *ast.Stmts) interface{} {
+	if s != nil && len(s.List) == 1 {
+		return b.expr(s.List[0])
+	}
+	return b.stmts(s)
+}
+
+func (b *builder) number(e *ast.NumberLit) interface{} {
+	kind := numberKind(e.Kind)[len("on_"):]
+	if strings.HasPrefix(e.Lit, "-") {
+		return list(Symbol("unary"), Symbol("-@"), b.tok(kind, e.Lit[1:], e.Start+1))
+	}
+	return b.tok(kind, e.Lit, e.Start)
+}
+
+func (b *builder) content(parts ...interface{}) []interface{} {
+	return append(list(Symbol("string_content")), parts...)
+}
+
+// strContent returns the content of the string literal such as "a", which
+// is the source as written, not the value.
+func (b *builder) strContent(e *ast.StrLit) []interface{} {
+	start := e.Start + openerLen(b.src, e.Start)
+	if start == e.Start || e.Stop-1 <= start {
+		if start == e.Start {
+			return list(b.tok("tstring_content", string(b.src[e.Start:e.Stop]), e.Start))
+		}
+		return nil
+	}
+	return list(b.tok("tstring_content", string(b.src[start:e.Stop-1]), start))
+}
+
+// quoted returns the parts of the interpolated literal enclosed by the
+// delimiters such as "a#{b}".
+func (b *builder) quoted(s ast.Span, parts []ast.Expr) []interface{} {
+	return b.parts(parts, s.Start+openerLen(b.src, s.Start), s.Stop-1)
+}
+
+// parts returns the parts of interpolated literals. The static parts are
+// clipped by the content src[start:stop] of the literal, since the scanner
+// includes the delimiters to them.
+func (b *builder) parts(parts []ast.Expr, start, stop int) []interface{} {
+	v := []interface{}{}
+	for _, p := range parts {
+		switch p := p.(type) {
+		case *ast.StrLit:
+			pos, end := p.Start, p.Stop
+			if pos < start {
+				pos = start
+			}
+			if end > stop {
+				end = stop
+			}
+			if end > pos {
+				v = append(v, b.tok("tstring_content", string(b.src[pos:end]), pos))
+			}
+		case *ast.Insert:
+			if p.Start+1 < len(b.src) && b.src[p.Start+1] != '{' && len(p.Body.List) == 1 {
+				v = append(v, list(Symbol("string_dvar"), list(Symbol("var_ref"), b.variable(p.Body.List[0]))))
+				continue
+			}
+			v = append(v, list(Symbol("string_embexpr"), b.stmts(p.Body)))
+		default:
+			v = append(v, b.expr(p))
+		}
+	}
+	return v
+}
+
+func (b *builder) symbol(e *ast.SymbolLit) interface{} {
+	if e.Start+1 < len(b.src) && b.src[e.Start] == ':' && openerLen(b.src, e.Start+1) > 0 {
+		start := e.Start + 2
+		return list(Symbol("dyna_symbol"), b.content(b.tok("tstring_content", string(b.src[start:e.Stop-1]), start)))
+	}
+	if b.src[e.Start] == '%' {
+		start := e.Start + 3
+		return list(Symbol("symbol_literal"), list(Symbol("symbol"), b.tok("tstring_content", string(b.src[start:e.Stop-1]), start)))
+	}
+	return list(Symbol("symbol_literal"), list(Symbol("symbol"), b.symbolName(e.Name, e.Start+1)))
+}
+
+// symbolName returns the event of the name of symbol at the offset.
+func (b *builder) symbolName(name string, off int) interface{} {
+	switch {
+	case strings.HasPrefix(name, "@@"):
+		return b.tok("cvar", name, off)
+	case strings.HasPrefix(name, "@"):
+		return b.tok("ivar", name, off)
+	case strings.HasPrefix(name, "$"):
+		return b.tok("gvar", name, off)
+	}
+	return b.name(name, off)
+}
+
+// methodSymbol returns the method name of alias and undef.
+func (b *builder) methodSymbol(e ast.Expr) interface{} {
+	s, ok := e.(*ast.SymbolLit)
+	if !ok || b.src[s.Start] == ':' {
+		return b.expr(e)
+	}
+	return list(Symbol("symbol_literal"), b.symbolName(s.Name, s.Start))
+}
+
+func (b *builder) array(e *ast.ArrayLit) interface{} {
+	if e.Start+1 < len(b.src) && b.src[e.Start] == '%' {
+		// the words of %W and %I are lists of the parts.
+		interp := b.src[e.Start+1] == 'W' || b.src[e.Start+1] == 'I'
+		words := []interface{}{}
+		for _, w := range e.Elems {
+			var word interface{}
+			switch w := w.(type) {
+			case *ast.StrLit:
+				word = b.tok("tstring_content", string(b.src[w.Start:w.Stop]), w.Start)
+			case *ast.SymbolLit:
+				word = b.tok("tstring_content", string(b.src[w.Start:w.Stop]), w.Start)
+			case *ast.InterpStr:
+				words = append(words, b.parts(w.Parts, w.Start, w.Stop))
+				continue
+			case *ast.InterpSymbol:
+				words = append(words, b.parts(w.Parts, w.Start, w.Stop))
+				continue
+			}
+			if interp {
+				word = list(word)
+			}
+			words = append(words, word)
+		}
+		return list(Symbol("array"), words)
+	}
+	if len(e.Elems) == 0 {
+		return list(Symbol("array"), nil)
+	}
+	return list(Symbol("array"), b.argList(e.Elems))
+}
+
+func (b *builder) assocs(pairs []*ast.Pair) []interface{} {
+	v := make([]interface{}, len(pairs))
+	for i, p := range pairs {
+		if p.Key == nil {
+			v[i] = list(Symbol("assoc_splat"), b.expr(p.Value))
+			continue
+		}
+		key := b.expr(p.Key)
+		if s, ok := p.Key.(*ast.SymbolLit); ok && b.src[s.Start] != ':' && b.src[s.Start] != '%' {
+			key = b.tok("label", s.Name+":", s.Start)
+		}
+		v[i] = list(Symbol("assoc_new"), key, b.expr(p.Value))
+	}
+	return v
+}
+
+func (b *builder) variable(e ast.Expr) interface{} {
+	switch e := e.(type) {
+	case *ast.LocalVar:
+		return b.tok("ident", e.Name, e.Start)
+	case *ast.InstanceVar:
+		return b.tok("ivar", e.Name, e.Start)
+	case *ast.ClassVar:
+		return b.tok("cvar", e.Name, e.Start)
+	case *ast.GlobalVar:
+		return b.tok("gvar", e.Name, e.Start)
+	case *ast.Call:
+		return b.name(e.Name, e.Start)
+	}
+	return b.expr(e)
+}
+
+// constant returns the constant as the event given by ref, path or top.
+func (b *builder) constant(c *ast.Const, ref, path, top string) interface{} {
+	name := b.tok("const", c.Name, c.Stop-len(c.Name))
+	switch {
+	case c.Scope != nil:
+		return list(Symbol(path), b.expr(c.Scope), name)
+	case c.Top:
+		return list(Symbol(top), name)
+	}
+	return list(Symbol(ref), name)
+}
+
+// field returns the left hand side of assignments.
+func (b *builder) field(e ast.Expr) interface{} {
+	switch e := e.(type) {
+	case *ast.LocalVar, *ast.InstanceVar, *ast.ClassVar, *ast.GlobalVar:
+		return list(Symbol("var_field"), b.variable(e))
+	case *ast.Const:
+		return b.constant(e, "var_field", "const_path_field", "top_const_field")
+	case *ast.Index:
+		return list(Symbol("aref_field"), b.expr(e.Recv), b.optArgs(e.Args))
+	case *ast.Call:
+		if e.Recv == nil {
+			return list(Symbol("var_field"), b.name(e.Name, e.Start))
+		}
+		op, off := b.operator(e)
+		return list(Symbol("field"), b.expr(e.Recv), op, b.name(e.Name, b.find(off+1, e.Name)))
+	case *ast.Splat:
+		return list(Symbol("rest_param"), b.optField(e.Value))
+	}
+	return b.expr(e)
+}
+
+func (b *builder) optField(e ast.Expr) interface{} {
+	if e == nil {
+		return nil
+	}
+	return b.field(e)
+}
+
+func (b *builder) assign(e *ast.Assign) interface{} {
+	if e.Op == token.Assign {
+		return list(Symbol("assign"), b.field(e.Lhs), b.expr(e.Rhs))
+	}
+	op := e.Op.Text()
+	return list(Symbol("opassign"), b.field(e.Lhs), b.tok("op", op, b.find(e.Lhs.End(), op)), b.expr(e.Rhs))
+}
+
+func (b *builder) mlhs(l []ast.Expr) interface{} {
+	var v interface{} = []interface{}{}
+	for _, e := range l {
+		if s, ok := e.(*ast.Splat); ok {
+			v = list(Symbol("mlhs_add_star"), v, b.optField(s.Value))
+			continue
+		}
+		if m, ok := e.(*ast.MultiAssign); ok {
+			g := list(Symbol("mlhs"))
+			l := b.mlhs(m.Lhs).([]interface{})
+			if len(l) > 0 {
+				if _, ok := l[0].(Symbol); ok {
+					l = list(l) // mlhs_add_star
+				}
+			}
+			g = append(g, l...)
+			v = appendItem(v, g)
+			continue
+		}
+		v = appendItem(v, b.field(e))
+	}
+	return v
+}
+
+// appendItem appends e to the list v, as the *_add events of SexpBuilderPP.
+func appendItem(v interface{}, e interface{}) interface{} {
+	return append(v.([]interface{}), e)
+}
+
+func (b *builder) mrhs(e ast.Expr) interface{} {
+	a, ok := e.(*ast.ArrayLit)
+	if !ok || a.Start < len(b.src) && (b.src[a.Start] == '[' || b.src[a.Start] == '%') || len(a.Elems) < 2 {
+		return b.expr(e)
+	}
+	n := len(a.Elems)
+	last := a.Elems[n-1]
+	if s, ok := last.(*ast.Splat); ok {
+		return list(Symbol("mrhs_add_star"), list(Symbol("mrhs_new_from_args"), b.argList(a.Elems[:n-1])), b.expr(s.Value))
+	}
+	return list(Symbol("mrhs_new_from_args"), b.argList(a.Elems[:n-1]), b.expr(last))
+}
+
+// argList returns the arguments without the block argument.
+func (b *builder) argList(args []ast.Expr) interface{} {
+	var v interface{} = []interface{}{}
+	for _, a := range args {
+		if s, ok := a.(*ast.Splat); ok && !s.Double {
+			v = list(Symbol("args_add_star"), v, b.optExpr(s.Value))
+			continue
+		}
+		v = appendItem(v, b.expr(a))
+	}
+	return v
+}
+
+// args returns the arguments as args_add_block.
+func (b *builder) args(args []ast.Expr) interface{} {
+	var block interface{} = false
+	if n := len(args); n > 0 {
+		if p, ok := args[n-1].(*ast.BlockPass); ok {
+			block = b.optExpr(p.Value)
+			args = args[:n-1]
+		}
+	}
+	return list(Symbol("args_add_block"), b.argList(args), block)
+}
+
+func (b *builder) optArgs(args []ast.Expr) interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	return b.args(args)
+}
+
+func (b *builder) argParen(args []ast.Expr) interface{} {
+	return list(Symbol("arg_paren"), b.optArgs(args))
+}
+
+func (b *builder) jumpArgs(args []ast.Expr) interface{} {
+	if len(args) == 0 {
+		return []interface{}{}
+	}
+	return b.args(args)
+}
+
+// parenAfter returns whether ( follows the offset.
+func (b *builder) parenAfter(off int) bool {
+	for off < len(b.src) && (b.src[off] == ' ' || b.src[off] == '\t') {
+		off++
+	}
+	return off < len(b.src) && b.src[off] == '('
+}
+
+// operator returns the event of the call operator such as . and its offset.
+func (b *builder) operator(e *ast.Call) (interface{}, int) {
+	if e.Op == token.Colon2 {
+		off := b.find(e.Recv.End(), "::")
+		return Symbol("::"), off + 1
+	}
+	off := b.find(e.Recv.End(), ".")
+	if off > 0 && b.src[off-1] == '&' {
+		return b.tok("op", "&.", off-1), off
+	}
+	return b.tok("period", ".", off), off
+}
+
+func (b *builder) call(e *ast.Call) interface{} {
+	if e.Name == "lambda" && bytes.HasPrefix(b.src[e.Start:], []byte("->")) && e.Block != nil {
+		return b.lambda(e)
+	}
+	var call interface{}
+	if e.Recv == nil {
+		name := b.name(e.Name, e.Start)
+		switch {
+		case e.Parens:
+			call = list(Symbol("method_add_arg"), list(Symbol("fcall"), name), b.argParen(e.Args))
+		case len(e.Args) > 0:
+			call = list(Symbol("command"), name, b.args(e.Args))
+		case e.Block != nil:
+			call = list(Symbol("method_add_arg"), list(Symbol("fcall"), name), []interface{}{})
+		default:
+			call = list(Symbol("vcall"), name)
+		}
+		return b.addBlock(call, e.Block)
+	}
+	recv := b.expr(e.Recv)
+	op, off := b.operator(e)
+	name := b.name(e.Name, b.find(off+1, e.Name))
+	switch {
+	case e.Parens:
+		call = list(Symbol("method_add_arg"), list(Symbol("call"), recv, op, name), b.argParen(e.Args))
+	case len(e.Args) > 0:
+		call = list(Symbol("command_call"), recv, op, name, b.args(e.Args))
+	default:
+		call = list(Symbol("call"), recv, op, name)
+	}
+	return b.addBlock(call, e.Block)
+}
+
+func (b *builder) addBlock(call interface{}, blk *ast.Block) interface{} {
+	if blk == nil {
+		return call
+	}
+	var params interface{}
+	if blk.Params != nil {
+		params = list(Symbol("block_var"), b.params(blk.Params), false)
+	}
+	if blk.Braces {
+		return list(Symbol("method_add_block"), call, list(Symbol("brace_block"), params, b.stmts(blk.Body.Body)))
+	}
+	return list(Symbol("method_add_block"), call, list(Symbol("do_block"), params, b.bodyStmt(blk.Body)))
+}
+
+func (b *builder) lambda(e *ast.Call) interface{} {
+	blk := e.Block
+	var params interface{} = b.params(blk.Params)
+	if b.parenAfter(e.Start + len("->")) {
+		params = list(Symbol("paren"), params)
+	}
+	if blk.Braces {
+		return list(Symbol("lambda"), params, b.stmts(blk.Body.Body))
+	}
+	return list(Symbol("lambda"), params, b.bodyStmt(blk.Body))
+}
+
+func (b *builder) def(e *ast.Def) interface{} {
+	var off int
+	var op interface{}
+	if e.Singleton != nil {
+		off = b.find(e.Singleton.End(), ".")
+		op = b.tok("period", ".", off)
+		off = b.find(off+1, e.Name)
+	} else {
+		off = b.find(e.Start+len("def"), e.Name)
+	}
+	name := b.name(e.Name, off)
+	var params interface{} = b.params(e.Params)
+	if b.parenAfter(off + len(e.Name)) {
+		params = list(Symbol("paren"), params)
+	}
+	if e.Singleton != nil {
+		return list(Symbol("defs"), b.expr(e.Singleton), op, name, params, b.bodyStmt(e.Body))
+	}
+	return list(Symbol("def"), name, params, b.bodyStmt(e.Body))
+}
+
+// params returns the parameters as the 7 slots of the params event.
+func (b *builder) params(p *ast.Params) interface{} {
+	var slots [7][]interface{}
+	var rest, kwrest, block interface{}
+	if p != nil {
+		for _, a := range p.List {
+			switch a.Kind {
+			case ast.RequiredParam, ast.PostParam:
+				v := b.param(a, 0)
+				if a.Nested != nil {
+					v = b.nestedParams(a.Nested)
+				}
+				i := 0
+				if a.Kind == ast.PostParam {
+					i = 3
+				}
+				slots[i] = append(slots[i], v)
+			case ast.OptionalParam:
+				slots[1] = append(slots[1], list(b.param(a, 0), b.expr(a.Default)))
+			case ast.RestParam:
+				rest = list(Symbol("rest_param"), b.param(a, 1))
+			case ast.KeywordParam:
+				var def interface{} = false
+				if a.Default != nil {
+					def = b.expr(a.Default)
+				}
+				slots[4] = append(slots[4], list(b.tok("label", a.Name+":", a.Start), def))
+			case ast.KeywordRestParam:
+				kwrest = list(Symbol("kwrest_param"), b.param(a, 2))
+			case ast.BlockParam:
+				block = list(Symbol("blockarg"), b.param(a, 1))
+			}
+		}
+	}
+	v := list(Symbol("params"))
+	for i, s := range slots {
+		switch {
+		case i == 2:
+			v = append(v, rest)
+		case i == 5:
+			v = append(v, kwrest)
+		case i == 6:
+			v = append(v, block)
+		case s == nil:
+			v = append(v, nil)
+		default:
+			v = append(v, s)
+		}
+	}
+	return v
+}
+
+// param returns the name of the parameter which follows the prefix such as
+// * or &.
+func (b *builder) param(p *ast.Param, prefix int) interface{} {
+	if p.Name == "" {
+		return nil
+	}
+	return b.tok("ident", p.Name, p.Start+prefix)
+}
+
+func (b *builder) nestedParams(p *ast.Params) interface{} {
+	v := list(Symbol("mlhs"))
+	for _, a := range p.List {
+		switch {
+		case a.Nested != nil:
+			v = append(v, b.nestedParams(a.Nested))
+		case a.Kind == ast.RestParam:
+			v = append(v, list(Symbol("rest_param"), b.param(a, 1)))
+		default:
+			v = append(v, b.param(a, 0))
+		}
+	}
+	return v
+}
+
+func (b *builder) ifExpr(e *ast.If) interface{} {
+	kind := "if"
+	if e.Unless {
+		kind = "unless"
+	}
+	if e.Mod {
+		return list(Symbol(kind+"_mod"), b.expr(e.Cond), b.modBody(e.Then))
+	}
+	return list(Symbol(kind), b.expr(e.Cond), b.stmts(e.Then), b.elseClause(e.Else))
+}
+
+func (b *builder) elseClause(n ast.Node) interface{} {
+	switch n := n.(type) {
+	case *ast.Stmts:
+		if n == nil {
+			return nil
+		}
+		return list(Symbol("else"), b.stmts(n))
+	case *ast.If:
+		return list(Symbol("elsif"), b.expr(n.Cond), b.stmts(n.Then), b.elseClause(n.Else))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+puts "hello"
+foo(1, 2)
+foo()
+bar 1, k: 2
+a.b
+a.b(1).c 2
+A::B
+::C
+x = [1, 2]
+x[0] = 3
+x.y = 4
+x += 1
+a, *b = 1, 2
+list.each { |i, (j, k)| i }
+list.map do |v|
+  v * 2
+end
+f(&blk)
+l = ->(z) { z }
+-x ** 2
+!a and not b
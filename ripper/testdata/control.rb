@@ -0,0 +1,22 @@
+if a then b elsif c then d else e end
+f unless g
+while h
+  i
+end
+j until k
+case l
+when 1, 2 then m
+else n
+end
+for o in p do q end
+begin
+  r
+rescue S => t
+  retry
+else
+  u
+ensure
+  v
+end
+w rescue x
+y ? z : 0
@@ -0,0 +1,18 @@
+module M::N
+  class A < B
+    def a(b, c = 1, *d, e:, f: 2, **g, &h)
+      yield(b)
+      return c
+    end
+
+    def self.i
+      super
+    end
+
+    class << self
+      alias j i
+      alias $k $l
+      undef m
+    end
+  end
+end
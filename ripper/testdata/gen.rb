@@ -0,0 +1,17 @@
+# Generates the golden files of Ripper.lex and Ripper.sexp for each .rb file
+# in this directory. Run with Ruby 2.7:
+#
+#   ruby gen.rb
+#
+require 'ripper'
+
+Dir.chdir(__dir__)
+Dir.glob('*.rb').sort.each do |file|
+  next if file == File.basename(__FILE__)
+  src = File.read(file)
+  lex = Ripper.lex(src).map do |pos, kind, tok, state|
+    "[#{pos.inspect}, #{kind.inspect}, #{tok.inspect}, #{state.to_s}]\n"
+  end
+  File.write(file.sub(/\.rb\z/, '.lex'), lex.join)
+  File.write(file.sub(/\.rb\z/, '.sexp'), Ripper.sexp(src).inspect + "\n")
+end
@@ -0,0 +1,19 @@
+# literals
+1
+-2
+3.5
+4r
+5i
+"a#{b}c#@d"
+'e f'
+%q(g)
+:h
+:"i#{j}"
+%w(k l)
+%i(m n)
+[1, *o]
+{p: 1, "q" => 2, **r}
+1..2
+s = <<-EOS
+  t#{u}
+  EOS
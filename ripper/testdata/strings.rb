@@ -0,0 +1,8 @@
+# strings
+x = "a\tb\\c"
+'d\'e'
+"f\n#{g}\x41"
+:"h\ti"
+%s(j\tk)
+%w(l\ m n\tn)
+%i(o\ p)
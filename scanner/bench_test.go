@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/harukasan/ringo/debug"
+	"github.com/harukasan/ringo/token"
+)
+
+// benchFiles lists Ruby sources in testdata and the maximum number of
+// allocations that scanning the whole file may take.
+// Update README.md when changing the targets.
+var benchFiles = []struct {
+	name   string
+	allocs float64
+}{
+	{"model.rb", 4},
+	{"template.rb", 10},
+}
+
+func readTestdata(tb testing.TB, name string) []byte {
+	src, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return src
+}
+
+// scanAll scans the source until EOF, and returns the last token.
+func scanAll(src []byte) (n int, t token.Token) {
+	s := New(src)
+	for {
+		_, t, _ = s.Scan()
+		n++
+		if t == token.EOF || t == token.Illegal {
+			return
+		}
+	}
+}
+
+func TestScanTestdata(t *testing.T) {
+	for _, f := range benchFiles {
+		if n, tk := scanAll(readTestdata(t, f.name)); tk != token.EOF {
+			t.Errorf("%v: scan stopped at token #%v: %v", f.name, n, tk)
+		}
+	}
+}
+
+func TestScanAllocs(t *testing.T) {
+	if debug.Tracing {
+		t.Skip("tracing allocates")
+	}
+	for _, f := range benchFiles {
+		src := readTestdata(t, f.name)
+		work := make([]byte, len(src))
+		got := testing.AllocsPerRun(10, func() {
+			copy(work, src) // the scanner decodes escapes in place
+			scanAll(work)
+		})
+		if got > f.allocs {
+			t.Errorf("%v: allocs=%v (want<=%v)", f.name, got, f.allocs)
+		}
+	}
+}
+
+func benchmarkScan(b *testing.B, src []byte) {
+	work := make([]byte, len(src))
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(work, src)
+		scanAll(work)
+	}
+}
+
+func BenchmarkScanModel(b *testing.B) {
+	benchmarkScan(b, readTestdata(b, "model.rb"))
+}
+
+func BenchmarkScanTemplate(b *testing.B) {
+	benchmarkScan(b, readTestdata(b, "template.rb"))
+}
+
+// BenchmarkScanLarge scans all testdata files concatenated many times, to
+// approximate a large code base.
+func BenchmarkScanLarge(b *testing.B) {
+	var buf bytes.Buffer
+	for i := 0; i < 100; i++ {
+		for _, f := range benchFiles {
+			buf.Write(readTestdata(b, f.name))
+			buf.WriteByte('\n')
+		}
+	}
+	benchmarkScan(b, buf.Bytes())
+}
@@ -2,10 +2,19 @@ package scanner
 
 import "fmt"
 
-// ScanError holds an error which is caused by scanner.
+// ScanError holds an error which is caused by scanner. Labels point to the
+// related positions, such as the beginning of the unterminated string.
 type ScanError struct {
-	Pos int
-	Err error
+	Pos    int
+	Err    error
+	Labels []Label
+}
+
+// Label is a message attached to the range of the source.
+type Label struct {
+	Pos int // offset of the first character
+	End int // offset just after the last character
+	Msg string
 }
 
 func (e *ScanError) Error() string {
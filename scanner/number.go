@@ -0,0 +1,177 @@
+package scanner
+
+import "github.com/harukasan/ringo/token"
+
+/*
+Numeric literals are scanned as below, and may be followed by 'r' for
+rational numbers and 'i' for imaginary numbers, such as 3r, 2i, or 1.5ri:
+
+	0b1010, 0B1010          binary integer
+	0o17, 0O17, 0_17, 017   octadecimal integer
+	0d19, 0D19, 19          decimal integer
+	0x1f, 0X1F              hexadecimal integer
+	1.5, 1e10, 1.5e-3       float
+
+Digits can be separated by single underscores, such as 1_000. Malformed
+literals are reported by the same messages as Ruby, and scanned as Illegal.
+*/
+
+func isBinary(c byte) bool {
+	return c == '0' || c == '1'
+}
+
+// scanDigits scans the digits separated by underscores. An underscore which
+// is not between digits is reported. n is the number of digits already read.
+func (s *Scanner) scanDigits(isDigit func(byte) bool, n int) {
+	bad := -1
+	under := n == 0 // whether the previous is an underscore or nothing
+	for isDigit(s.char) || s.char == '_' {
+		if s.char == '_' {
+			if under && bad < 0 {
+				bad = s.offset
+			}
+			under = true
+		} else {
+			under = false
+		}
+		s.next()
+	}
+	if bad < 0 && s.src[s.offset-1] == '_' {
+		bad = s.offset - 1
+	}
+	if bad >= 0 {
+		s.errorf(bad, "trailing '_' in number")
+	}
+}
+
+// scanPrefixedDigits scans the digits after the prefix of integer, such as 0x.
+func (s *Scanner) scanPrefixedDigits(isDigit func(byte) bool) {
+	if !isDigit(s.char) {
+		s.failf("numeric literal without digits")
+		return
+	}
+	s.scanDigits(isDigit, 0)
+}
+
+// scanInvalidDigits reports the decimal digits that are not allowed in the
+// integer, such as 8 of 018.
+func (s *Scanner) scanInvalidDigits(name string) {
+	if token.IsDecimal(s.char) {
+		s.failf("Invalid %s digit", name)
+		s.scanDigits(token.IsDecimal, 1)
+	}
+}
+
+// failedSince returns whether an error is found after the position.
+func (s *Scanner) failedSince(pos int) bool {
+	return len(s.errs) > 0 && s.errs[len(s.errs)-1].Pos >= pos
+}
+
+func scanZero(s *Scanner) (token.Token, []byte) {
+	var t token.Token
+	switch s.char {
+	case 'x', 'X':
+		s.next()
+		t = token.HexadecimalInteger
+		s.scanPrefixedDigits(token.IsHexadecimal)
+	case 'b', 'B':
+		s.next()
+		t = token.BinaryInteger
+		s.scanPrefixedDigits(isBinary)
+		s.scanInvalidDigits("binary")
+	case 'd', 'D':
+		s.next()
+		t = token.DecimalInteger
+		s.scanPrefixedDigits(token.IsDecimal)
+	case 'o', 'O':
+		s.next()
+		t = token.OctadecimalInteger
+		if !token.IsDecimal(s.char) {
+			s.failf("numeric literal without digits")
+			break
+		}
+		s.scanDigits(token.IsOctadecimal, 0)
+		s.scanInvalidDigits("octal")
+	case '_':
+		s.next()
+		t = token.OctadecimalInteger
+		if !token.IsDecimal(s.char) {
+			s.errorf(s.offset-1, "trailing '_' in number")
+			break
+		}
+		if token.IsOctadecimal(s.char) {
+			s.scanDigits(token.IsOctadecimal, 0)
+		}
+		s.scanInvalidDigits("octal")
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		t = token.OctadecimalInteger
+		s.scanDigits(token.IsOctadecimal, 1)
+		s.scanInvalidDigits("octal")
+	default:
+		return scanFloat(s)
+	}
+	return scanNumberSuffix(s, t, true)
+}
+
+func scanNonZero(s *Scanner) (token.Token, []byte) {
+	s.scanDigits(token.IsDecimal, 1)
+	return scanFloat(s)
+}
+
+// scanFloat scans the fraction and the exponent of the decimal number if
+// they follow.
+func scanFloat(s *Scanner) (token.Token, []byte) {
+	t := token.DecimalInteger
+	if p := s.peek(2); p != nil && p[0] == '.' && token.IsDecimal(p[1]) {
+		s.next()
+		s.scanDigits(token.IsDecimal, 0)
+		t = token.Float
+	}
+	exp := false
+	if p := s.peek(2); p != nil && (p[0] == 'e' || p[0] == 'E') {
+		if token.IsDecimal(p[1]) || p[1] == '+' || p[1] == '-' {
+			s.next()
+			if s.char == '+' || s.char == '-' {
+				s.next()
+			}
+			if token.IsDecimal(s.char) {
+				s.scanDigits(token.IsDecimal, 0)
+			} else {
+				s.errorf(s.offset-1, "trailing '%c' in number", s.src[s.offset-1])
+			}
+			t, exp = token.Float, true
+		}
+	}
+	// the rational with exponent such as 1e3r is not allowed.
+	return scanNumberSuffix(s, t, !exp)
+}
+
+// scanNumberSuffix scans the suffix of number, "r", "i", or "ri", and returns
+// the token. The suffix is not a part of the number if an identifier
+// follows, such as 1if.
+func scanNumberSuffix(s *Scanner, t token.Token, rational bool) (token.Token, []byte) {
+	p := s.src[s.offset:]
+	var n int
+	var r, i bool
+	if rational && n < len(p) && p[n] == 'r' {
+		r = true
+		n++
+	}
+	if n < len(p) && p[n] == 'i' {
+		i = true
+		n++
+	}
+	if n > 0 && (n == len(p) || !(token.IsLetter(p[n]) || p[n] == '_' || p[n] >= 0x80)) {
+		s.skip(n)
+		switch {
+		case i:
+			t = token.Imaginary
+		case r:
+			t = token.Rational
+		}
+	}
+	if s.failedSince(s.begin) {
+		return token.Illegal, s.src[s.begin:s.offset]
+	}
+	return t, s.src[s.begin:s.offset]
+}
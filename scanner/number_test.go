@@ -0,0 +1,56 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/harukasan/ringo/token"
+)
+
+func TestScanNumberErrors(t *testing.T) {
+	rules := map[string]struct {
+		pos int
+		msg string
+	}{
+		"1__2":    {2, "trailing '_' in number"},
+		"1_":      {1, "trailing '_' in number"},
+		"1_.5":    {1, "trailing '_' in number"},
+		"1.5_":    {3, "trailing '_' in number"},
+		"0_":      {1, "trailing '_' in number"},
+		"0__7":    {1, "trailing '_' in number"},
+		"0x_1":    {2, "numeric literal without digits"},
+		"0x":      {2, "numeric literal without digits"},
+		"0b":      {2, "numeric literal without digits"},
+		"0o":      {2, "numeric literal without digits"},
+		"0d":      {2, "numeric literal without digits"},
+		"0b102":   {4, "Invalid binary digit"},
+		"08":      {1, "Invalid octal digit"},
+		"0o19":    {3, "Invalid octal digit"},
+		"0_8":     {2, "Invalid octal digit"},
+		"1e+":     {2, "trailing '+' in number"},
+		"1.5e-":   {4, "trailing '-' in number"},
+		"x = 1_ ": {5, "trailing '_' in number"},
+	}
+	for input, want := range rules {
+		s := NewString(input)
+		for _, tk, _ := s.Scan(); tk != token.EOF; _, tk, _ = s.Scan() {
+		}
+		errs := s.Errors()
+		if len(errs) != 1 {
+			t.Errorf("%v: errors=%v (want=1 error)", input, errs)
+			continue
+		}
+		if errs[0].Pos != want.pos || errs[0].Err.Error() != want.msg {
+			t.Errorf("%v: pos=%v (want=%v), err=%v (want=%v)", input, errs[0].Pos, want.pos, errs[0].Err, want.msg)
+		}
+	}
+}
+
+func TestScanNumberNoErrors(t *testing.T) {
+	for _, input := range []string{"0", "1_000", "0d1_9", "0o1_7", "0_17", "0x1_f", "0b1_0", "1.5e-3", "1e1_0", "3ri"} {
+		s := NewString(input)
+		s.Scan()
+		if err := s.Err(); err != nil {
+			t.Errorf("%v: err=%v (want=nil)", input, err)
+		}
+	}
+}
@@ -4,11 +4,20 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strconv"
+	"unicode/utf8"
 
 	"github.com/harukasan/ringo/debug"
 	"github.com/harukasan/ringo/token"
 )
 
+// debug categories
+var (
+	traceNext  = debug.NewCategory("scanner.next")
+	traceCtx   = debug.NewCategory("scanner.ctx")
+	traceError = debug.NewCategory("scanner.error")
+)
+
 // Scanner implements a scanner for Ruby lex.
 type Scanner struct {
 	src []byte // source buffer
@@ -18,11 +27,17 @@ type Scanner struct {
 	offset int  // current offset
 	begin  int  // offset of begin of the token
 
-	ctx *scannerCtx // scanner context
+	prev     token.Token // previous token
+	ctx      *scannerCtx // scanner context
+	free     *scannerCtx // contexts popped, reused by the next push
+	heredocs []heredoc   // heredocs whose body begins at the next line
+	errs     []*ScanError
 }
 
 type scannerCtx struct {
 	nospace   bool          // whether the previous is not a space
+	braces    int           // depth of braces opened in the context
+	lit       literal       // literal scanned in the context
 	stateScan stateScanFunc // scanner func for the special state
 	parent    *scannerCtx   // parent context
 }
@@ -53,11 +68,22 @@ func (s *Scanner) next() {
 	if s.offset >= len(s.src) {
 		s.err = io.EOF
 		s.char = 0
-		debug.Printf("next: len=%v, offset=%v, char=%v, err=%v", len(s.src), s.offset, s.char, s.err)
+		if debug.Tracing {
+			traceNext.Printf("next: len=%v, offset=%v, char=%v, err=%v", len(s.src), s.offset, s.char, s.err)
+		}
 		return
 	}
 	s.char = s.src[s.offset]
-	debug.Printf("next: len=%v, offset=%v, char=%v", len(s.src), s.offset, s.char)
+	if debug.Tracing {
+		traceNext.Printf("next: len=%v, offset=%v, char=%v", len(s.src), s.offset, s.char)
+	}
+}
+
+// reset moves the offset back to the given offset.
+func (s *Scanner) reset(offset int) {
+	s.offset = offset - 1
+	s.err = nil
+	s.next()
 }
 
 func (s *Scanner) skip(n int) {
@@ -73,25 +99,76 @@ func (s *Scanner) peek(n int) []byte {
 	return s.src[s.offset : s.offset+n]
 }
 
+// failf records an error at the current offset.
 func (s *Scanner) failf(format string, v ...interface{}) {
-	if s.err != nil && s.err != io.EOF {
-		return
+	s.errorf(s.offset, format, v...)
+}
+
+// errorf records an error at the given position. The scanner continues to
+// scan after errors.
+func (s *Scanner) errorf(pos int, format string, v ...interface{}) {
+	err := &ScanError{Pos: pos, Err: fmt.Errorf(format, v...)}
+	s.errs = append(s.errs, err)
+	if debug.Tracing {
+		traceError.Printf("failf: %v", err)
+	}
+}
+
+// label attaches the label to the last error.
+func (s *Scanner) label(pos, end int, msg string) {
+	err := s.errs[len(s.errs)-1]
+	err.Labels = append(err.Labels, Label{Pos: pos, End: end, Msg: msg})
+}
+
+// quoteChar returns the character escaped as in Go string literals.
+func quoteChar(c byte) string {
+	q := strconv.Quote(string([]byte{c}))
+	return q[1 : len(q)-1]
+}
+
+// Offset returns the offset just after the last scanned token.
+func (s *Scanner) Offset() int {
+	return s.offset
+}
+
+// Errors returns the errors found until the last scan.
+func (s *Scanner) Errors() []*ScanError {
+	return s.errs
+}
+
+// Err returns the first error found until the last scan, or nil.
+func (s *Scanner) Err() error {
+	if len(s.errs) == 0 {
+		return nil
 	}
-	s.err = fmt.Errorf(format, v...)
-	debug.Printf("failf: %v", s.err)
+	return s.errs[0]
 }
 
 func (s *Scanner) pushCtx(state stateScanFunc) {
-	s.ctx = &scannerCtx{
+	ctx := s.free
+	if ctx == nil {
+		ctx = new(scannerCtx)
+	} else {
+		s.free = ctx.parent
+	}
+	*ctx = scannerCtx{
 		stateScan: state,
 		parent:    s.ctx,
 	}
-	debug.Printf("push: -> %#v", s.ctx)
+	s.ctx = ctx
+	if debug.Tracing {
+		traceCtx.Printf("push: -> %#v", s.ctx)
+	}
 }
 
 func (s *Scanner) popCtx() {
-	s.ctx = s.ctx.parent
-	debug.Printf("pop: -> %#v", s.ctx)
+	ctx := s.ctx
+	s.ctx = ctx.parent
+	ctx.parent = s.free
+	s.free = ctx
+	if debug.Tracing {
+		traceCtx.Printf("pop: -> %#v", s.ctx)
+	}
 }
 
 // Scan reads and returns a parsed token position, type, and its literal.
@@ -104,12 +181,52 @@ func (s *Scanner) Scan() (pos int, t token.Token, literal []byte) {
 		}
 		pos, t, literal = s.ctx.stateScan(s)
 	}
+	s.prev = t
 	return
 }
 
+// isValueEnd returns whether the token can end a value, such as a literal, an
+// identifier or a closing parenthesis.
+func isValueEnd(t token.Token) bool {
+	switch t {
+	case token.BinaryInteger, token.DecimalInteger, token.OctadecimalInteger,
+		token.HexadecimalInteger, token.Float, token.Rational, token.Imaginary,
+		token.String, token.Symbol,
+		token.LiteralEnd, token.HeredocBegin,
+		token.RParen, token.RBracket, token.RBrace,
+		token.KeywordLINE, token.KeywordENCODING, token.KeywordFILE,
+		token.KeywordEnd, token.KeywordFalse, token.KeywordNil,
+		token.KeywordSelf, token.KeywordTrue,
+		token.IdentConst, token.IdentLocalVar, token.IdentLocalMethod,
+		token.IdentGlobalVar, token.IdentInstanceVar, token.IdentClassVar:
+		return true
+	}
+	return false
+}
+
+// isOperandBegin returns whether an operand can begin at the current offset,
+// that is at the beginning of an expression, or at the first argument of a
+// method call without parentheses such as `puts %w(a b)`.
+func (s *Scanner) isOperandBegin() bool {
+	if !isValueEnd(s.prev) {
+		return true
+	}
+	switch s.prev {
+	case token.IdentLocalVar, token.IdentLocalMethod:
+		return !s.ctx.nospace && !token.IsWhiteSpace(s.char) && s.char != '\n'
+	}
+	return false
+}
+
 func stateCompStmts(s *Scanner) (pos int, t token.Token, literal []byte) {
 	s.begin = s.offset
-	if scan := scanners[s.char]; scan != nil {
+	var scan scanFunc
+	if s.char < 127 {
+		scan = scanners[s.char]
+	} else if s.isIdentStart() {
+		scan = scanMultibyte
+	}
+	if scan != nil {
 		s.next()
 		t, literal = scan(s)
 		if t != token.Continue && t != token.NewLine {
@@ -117,7 +234,51 @@ func stateCompStmts(s *Scanner) (pos int, t token.Token, literal []byte) {
 		}
 		return s.begin, t, literal
 	}
-	return s.offset, token.Illegal, nil
+	if s.char < utf8.RuneSelf {
+		s.failf("Invalid char '%s' in expression", quoteChar(s.char))
+	} else {
+		s.failf("invalid multibyte char (UTF-8)")
+	}
+	s.next()
+	return s.begin, token.Illegal, s.src[s.begin:s.offset]
+}
+
+// peekRune decodes the UTF-8 character at the current offset.
+func (s *Scanner) peekRune() (rune, int) {
+	return token.DecodeRune(s.src[s.offset:])
+}
+
+// isIdentStart returns whether the character at the current offset can begin
+// an identifier.
+func (s *Scanner) isIdentStart() bool {
+	if s.char < utf8.RuneSelf {
+		return token.IsIdentStart(s.char)
+	}
+	return isIdentStartAt(s.src[s.offset:])
+}
+
+func isIdentStartAt(p []byte) bool {
+	r, _ := token.DecodeRune(p)
+	return token.IsIdentStartRune(r)
+}
+
+// skipIdent skips the characters of identifier including non-ASCII
+// characters.
+func (s *Scanner) skipIdent() {
+	for {
+		if token.IsIdent(s.char) {
+			s.next()
+			continue
+		}
+		if s.char < utf8.RuneSelf {
+			return
+		}
+		r, n := s.peekRune()
+		if !token.IsIdentRune(r) {
+			return
+		}
+		s.skip(n)
+	}
 }
 
 func (s *Scanner) skipLine() {
@@ -218,6 +379,9 @@ func skipWhiteSpaces(s *Scanner) (token.Token, []byte) {
 
 func scanNewLine(s *Scanner) (token.Token, []byte) {
 	s.ctx.nospace = false
+	if s.src[s.begin] == '\n' {
+		s.beginHeredoc()
+	}
 	return token.NewLine, nil
 }
 
@@ -234,12 +398,29 @@ func scanNot(s *Scanner) (token.Token, []byte) {
 	return token.Not, nil
 }
 
-func isInsertPrefix(c byte) bool {
-	return c == '@' || c == '$' || c == '{'
+// isInsert returns whether the source at the current offset begins an
+// insertion into the string, such as #{...}, #@ivar, #@@cvar, or #$gvar.
+func (s *Scanner) isInsert() bool {
+	p := s.src[s.offset:]
+	if len(p) < 3 || p[0] != '#' {
+		return len(p) == 2 && p[0] == '#' && p[1] == '{'
+	}
+	switch p[1] {
+	case '{':
+		return true
+	case '$':
+		return isIdentStartAt(p[2:])
+	case '@':
+		if p[2] == '@' {
+			return isIdentStartAt(p[3:])
+		}
+		return isIdentStartAt(p[2:])
+	}
+	return false
 }
 
 func scanDoubleQuote(s *Scanner) (token.Token, []byte) {
-	return scanDoubleQuotedString(s, '"', 1)
+	return scanLiteral(s, literal{kind: token.String, escape: escapeAll, interp: true, term: '"'})
 }
 
 func scanComment(s *Scanner) (token.Token, []byte) {
@@ -252,13 +433,11 @@ func scanDollar(s *Scanner) (token.Token, []byte) {
 }
 
 func scanGlobalVar(s *Scanner) (token.Token, []byte) {
-	if !token.IsIdentStart(s.char) {
+	if !s.isIdentStart() {
+		s.errorf(s.begin, "'$' without identifiers is not allowed as a global variable name")
 		return token.Illegal, s.src[s.begin:s.offset]
 	}
-	s.next()
-	for token.IsIdent(s.char) {
-		s.next()
-	}
+	s.skipIdent()
 	return token.IdentGlobalVar, s.src[s.begin:s.offset]
 }
 
@@ -277,33 +456,31 @@ func closeBracket(c byte) byte {
 }
 
 func scanPercent(s *Scanner) (token.Token, []byte) {
-	switch s.char {
-	case 'Q':
-		p := s.peek(2)
-		if p != nil && !token.IsAlnum(p[1]) { // %Q!...!
-			s.next()
-			term := closeBracket(s.char)
-			s.next()
-			return scanDoubleQuotedString(s, term, 3)
-		}
-	case 'q':
-		p := s.peek(2)
-		if p != nil && !token.IsAlnum(p[1]) { // %q!...!
-			s.next()
-			term := closeBracket(s.char)
-			s.next()
-			return scanSingleQuotedString(s, term, 3)
-		}
-	case '=': // %=
+	if s.char == '=' { // %=
 		s.next()
 		return token.AssignMod, nil
 	}
-	if s.err == nil && !token.IsAlnum(s.char) { // %!...!
-		term := closeBracket(s.char)
+	typ, delim := byte('Q'), s.char // %!...!
+	if token.IsAlnum(s.char) {      // %q!...!
+		p := s.peek(2)
+		if p == nil {
+			return token.Mod, nil
+		}
+		typ, delim = p[0], p[1]
+	}
+	if percentLiterals[typ].kind == token.None || !isPercentDelimiter(delim) || !s.isOperandBegin() {
+		return token.Mod, nil
+	}
+	if token.IsAlnum(s.char) {
 		s.next()
-		return scanDoubleQuotedString(s, term, 2)
 	}
-	return token.Mod, nil
+	s.next()
+	l := percentLiterals[typ]
+	l.term = closeBracket(delim)
+	if l.term != delim {
+		l.open = delim
+	}
+	return scanLiteral(s, l)
 }
 
 func scanAmp(s *Scanner) (token.Token, []byte) {
@@ -323,7 +500,7 @@ func scanAmp(s *Scanner) (token.Token, []byte) {
 }
 
 func scanSingleQuote(s *Scanner) (token.Token, []byte) {
-	return scanSingleQuotedString(s, '\'', 1)
+	return scanLiteral(s, literal{kind: token.String, escape: escapeQuote, term: '\''})
 }
 
 func scanAsterisk(s *Scanner) (token.Token, []byte) {
@@ -390,6 +567,7 @@ func scanDot(s *Scanner) (token.Token, []byte) {
 	if s.char == '.' {
 		s.next()
 		if s.char == '.' {
+			s.next()
 			return token.Dot3, nil
 		}
 		return token.Dot2, nil
@@ -463,7 +641,7 @@ func scanEq(s *Scanner) (token.Token, []byte) {
 			p := s.peek(6)
 			if p != nil && bytes.HasPrefix(p, []byte("begin")) {
 				if token.IsWhiteSpace(p[5]) || p[5] == '\n' {
-					skipMultiLineComment(s)
+					skipMultiLineComment(s, s.offset-1)
 					return token.Continue, nil
 				}
 			}
@@ -475,7 +653,7 @@ func scanEq(s *Scanner) (token.Token, []byte) {
 	return token.Assign, nil
 }
 
-func skipMultiLineComment(s *Scanner) {
+func skipMultiLineComment(s *Scanner, begin int) {
 	for {
 		if s.char == '=' {
 			s.next()
@@ -497,6 +675,7 @@ func skipMultiLineComment(s *Scanner) {
 		if s.err != nil {
 			if s.err == io.EOF {
 				s.failf("multi-line comment must be closed")
+				s.label(begin, begin+len("=begin"), "comment started here")
 			}
 			return
 		}
@@ -521,18 +700,22 @@ func scanGt(s *Scanner) (token.Token, []byte) {
 }
 
 func scanAt(s *Scanner) (token.Token, []byte) {
-	t := token.IdentInstanceVar
+	t, kind := token.IdentInstanceVar, "an instance"
 	if s.char == '@' {
-		t = token.IdentClassVar
+		t, kind = token.IdentClassVar, "a class"
 		s.next()
 	}
-	if !token.IsIdentStart(s.char) {
+	if !s.isIdentStart() {
+		if token.IsDecimal(s.char) {
+			// such as @1
+			s.skipIdent()
+			s.errorf(s.begin, "'%s' is not allowed as %s variable name", s.src[s.begin:s.offset], kind)
+		} else {
+			s.errorf(s.begin, "'%s' without identifiers is not allowed as %s variable name", s.src[s.begin:s.offset], kind)
+		}
 		return token.Illegal, s.src[s.begin:s.offset]
 	}
-	s.next()
-	for token.IsIdent(s.char) {
-		s.next()
-	}
+	s.skipIdent()
 	return t, s.src[s.begin:s.offset]
 }
 
@@ -608,84 +791,37 @@ func scanOr(s *Scanner) (token.Token, []byte) {
 	return token.Or, nil
 }
 
-func scanZero(s *Scanner) (token.Token, []byte) {
-	ch := s.char
-	switch ch {
-	case '.':
-		s.next()
-		return scanFloatDecimal(s)
-	case 'd', 'D':
-		s.next()
-		return scanInt(s)
-	case 'b', 'B':
-		s.next()
-		return scanBinInt(s)
-	case '_', 'o', 'O':
-		s.next()
-		return scanOctInt(s)
-	case 'x', 'X':
-		s.next()
-		return scanHexInt(s)
-	}
-	return token.DecimalInteger, s.src[s.begin:s.offset]
-}
-
-func scanBinInt(s *Scanner) (token.Token, []byte) {
-	for s.char == '0' || s.char == '1' || s.char == '_' {
-		s.next()
-	}
-	return token.BinaryInteger, s.src[s.begin:s.offset]
-}
-
-func scanOctInt(s *Scanner) (token.Token, []byte) {
-	for token.IsOctadecimal(s.char) || s.char == '_' {
-		s.next()
-	}
-	return token.OctadecimalInteger, s.src[s.begin:s.offset]
-}
-
-func scanHexInt(s *Scanner) (token.Token, []byte) {
-	for token.IsHexadecimal(s.char) || s.char == '_' {
-		s.next()
-	}
-	return token.HexadecimalInteger, s.src[s.begin:s.offset]
-}
-
-func scanInt(s *Scanner) (token.Token, []byte) {
-	for token.IsDecimal(s.char) || s.char == '_' {
-		s.next()
-	}
-	return token.DecimalInteger, s.src[s.begin:s.offset]
-}
-
-func scanNonZero(s *Scanner) (token.Token, []byte) {
-	for token.IsDecimal(s.char) || s.char == '_' {
-		s.next()
-	}
-	if s.char == '.' {
-		s.next()
-		return scanFloatDecimal(s)
+// scanMultibyte scans the identifier which begins with a non-ASCII
+// character. It is a constant if the first character is a uppercase or
+// titlecase letter.
+func scanMultibyte(s *Scanner) (token.Token, []byte) {
+	r, n := token.DecodeRune(s.src[s.begin:])
+	s.skip(n - 1)
+	if token.IsUppercaseRune(r) {
+		return scanUppercase(s)
 	}
-	return token.DecimalInteger, s.src[s.begin:s.offset]
+	return scanLowercase(s)
 }
 
-func scanFloatDecimal(s *Scanner) (token.Token, []byte) {
-	for token.IsDecimal(s.char) || s.char == '_' {
-		s.next()
+// isOperatorNext returns whether the character after the current one
+// continues an operator, such as == of a==b or != of a!=b, so the current
+// character is not a part of the method name.
+func (s *Scanner) isOperatorNext() bool {
+	p := s.peek(2)
+	if p == nil {
+		return false
 	}
-	if s.char == 'e' || s.char == 'E' {
-		s.next()
-		for token.IsDecimal(s.char) || s.char == '_' {
-			s.next()
-		}
+	switch p[1] {
+	case '=':
+		return true
+	case '~', '>':
+		return p[0] == '='
 	}
-	return token.Float, s.src[s.begin:s.offset]
+	return false
 }
 
 func scanUppercase(s *Scanner) (token.Token, []byte) {
-	for token.IsIdent(s.char) {
-		s.next()
-	}
+	s.skipIdent()
 	lit := s.src[s.begin:s.offset]
 	if t := token.KeywordToken(lit); t != token.None {
 		return t, nil
@@ -695,10 +831,8 @@ func scanUppercase(s *Scanner) (token.Token, []byte) {
 
 func scanLowercase(s *Scanner) (token.Token, []byte) {
 	t := token.IdentLocalVar
-	for token.IsIdent(s.char) {
-		s.next()
-	}
-	if s.char == '?' || s.char == '!' || s.char == '=' {
+	s.skipIdent()
+	if (s.char == '?' || s.char == '!' || s.char == '=') && !s.isOperatorNext() {
 		t = token.IdentLocalMethod
 		s.next()
 	}
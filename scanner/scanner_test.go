@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/harukasan/ringo/debug"
@@ -51,10 +52,15 @@ var rules = map[string][]struct {
 	".":   {{0, token.Dot, nil}},
 	"..":  {{0, token.Dot2, nil}},
 	"...": {{0, token.Dot3, nil}},
-	"?":   {{0, token.Question, nil}},
-	":":   {{0, token.Colon, nil}},
-	"::":  {{0, token.Colon2, nil}},
-	"=>":  {{0, token.Arrow, nil}},
+	"a...b": {
+		{0, token.IdentLocalVar, []byte("a")},
+		{1, token.Dot3, nil},
+		{4, token.IdentLocalVar, []byte("b")},
+	},
+	"?":  {{0, token.Question, nil}},
+	":":  {{0, token.Colon, nil}},
+	"::": {{0, token.Colon2, nil}},
+	"=>": {{0, token.Arrow, nil}},
 
 	// operators
 	"!":  {{0, token.Not, nil}},
@@ -135,6 +141,47 @@ var rules = map[string][]struct {
 	"-0.1":               {{0, token.Float, []byte("-0.1")}},
 	"123.0456789e10":     {{0, token.Float, []byte("123.0456789e10")}},
 	"123.0456789E10":     {{0, token.Float, []byte("123.0456789E10")}},
+	"1e10":               {{0, token.Float, []byte("1e10")}},
+	"1E+3":               {{0, token.Float, []byte("1E+3")}},
+	"1.5e-3":             {{0, token.Float, []byte("1.5e-3")}},
+	"-1.5e-3":            {{0, token.Float, []byte("-1.5e-3")}},
+	"0e1":                {{0, token.Float, []byte("0e1")}},
+	"1_000.000_1e1_0":    {{0, token.Float, []byte("1_000.000_1e1_0")}},
+	"017":                {{0, token.OctadecimalInteger, []byte("017")}},
+	"3r":                 {{0, token.Rational, []byte("3r")}},
+	"1.5r":               {{0, token.Rational, []byte("1.5r")}},
+	"0x1fr":              {{0, token.Rational, []byte("0x1fr")}},
+	"2i":                 {{0, token.Imaginary, []byte("2i")}},
+	"0b11i":              {{0, token.Imaginary, []byte("0b11i")}},
+	"1.5ri":              {{0, token.Imaginary, []byte("1.5ri")}},
+	"-3ri":               {{0, token.Imaginary, []byte("-3ri")}},
+	"1e3r": {
+		{0, token.Float, []byte("1e3")},
+		{3, token.IdentLocalVar, []byte("r")},
+	},
+	"1if": {
+		{0, token.DecimalInteger, []byte("1")},
+		{1, token.KeywordIf, nil},
+	},
+	"1r2": {
+		{0, token.Rational, []byte("1r")},
+		{2, token.DecimalInteger, []byte("2")},
+	},
+	"1.e": {
+		{0, token.DecimalInteger, []byte("1")},
+		{1, token.Dot, nil},
+		{2, token.IdentLocalVar, []byte("e")},
+	},
+	"1e": {
+		{0, token.DecimalInteger, []byte("1")},
+		{1, token.IdentLocalVar, []byte("e")},
+	},
+	"1__2":  {{0, token.Illegal, []byte("1__2")}},
+	"1_":    {{0, token.Illegal, []byte("1_")}},
+	"0b102": {{0, token.Illegal, []byte("0b102")}},
+	"0x":    {{0, token.Illegal, []byte("0x")}},
+	"08":    {{0, token.Illegal, []byte("08")}},
+	"1e+":   {{0, token.Illegal, []byte("1e+")}},
 	"+1\n-1": {
 		{0, token.DecimalInteger, []byte("+1")},
 		{2, token.NewLine, nil},
@@ -160,8 +207,9 @@ var rules = map[string][]struct {
 	},
 
 	// string literals:
-	`"a"`: {{0, token.String, []byte(`a`)}},
-	`"\"`: {{0, token.String, []byte(``)}},
+	`"a"`:  {{0, token.String, []byte(`a`)}},
+	`"\""`: {{0, token.String, []byte(`"`)}},
+	`"\"`:  {{0, token.Illegal, nil}},
 	`"#{a}"`: {
 		{0, token.StringPart, []byte("")},
 		{1, token.InsertBegin, nil}, // points to '#'
@@ -212,6 +260,79 @@ var rules = map[string][]struct {
 		{5, token.Dot, nil},
 		{6, token.IdentLocalVar, []byte("a")},
 	},
+	`"ab#{x}#y"`: {
+		{0, token.StringPart, []byte("ab")},
+		{3, token.InsertBegin, nil},
+		{5, token.IdentLocalVar, []byte("x")},
+		{6, token.InsertEnd, nil},
+		{7, token.String, []byte("#y")},
+	},
+	`"#"`:   {{0, token.String, []byte("#")}},
+	`"a#b"`: {{0, token.String, []byte("a#b")}},
+	`"#@1"`: {{0, token.String, []byte("#@1")}},
+	`"#{ {a: 1}[:a] }"`: {
+		{0, token.StringPart, []byte("")},
+		{1, token.InsertBegin, nil},
+		{4, token.LBrace, nil},
+		{5, token.IdentLocalVar, []byte("a")},
+		{6, token.Colon, nil},
+		{8, token.DecimalInteger, []byte("1")},
+		{9, token.RBrace, nil},
+		{10, token.LBracket, nil},
+		{11, token.Colon, nil},
+		{12, token.IdentLocalVar, []byte("a")},
+		{13, token.RBracket, nil},
+		{15, token.InsertEnd, nil},
+		{16, token.String, []byte("")},
+	},
+	`"#{ [1].map { |x| x } }"`: {
+		{0, token.StringPart, []byte("")},
+		{1, token.InsertBegin, nil},
+		{4, token.LBracket, nil},
+		{5, token.DecimalInteger, []byte("1")},
+		{6, token.RBracket, nil},
+		{7, token.Dot, nil},
+		{8, token.IdentLocalVar, []byte("map")},
+		{12, token.LBrace, nil},
+		{14, token.Or, nil},
+		{15, token.IdentLocalVar, []byte("x")},
+		{16, token.Or, nil},
+		{18, token.IdentLocalVar, []byte("x")},
+		{20, token.RBrace, nil},
+		{22, token.InsertEnd, nil},
+		{23, token.String, []byte("")},
+	},
+	`"#{ h = {} }"`: {
+		{0, token.StringPart, []byte("")},
+		{1, token.InsertBegin, nil},
+		{4, token.IdentLocalVar, []byte("h")},
+		{6, token.Assign, nil},
+		{8, token.LBrace, nil},
+		{9, token.RBrace, nil},
+		{11, token.InsertEnd, nil},
+		{12, token.String, []byte("")},
+	},
+	`"a#{"b#{ {c: "#{ {} }"} }"}d"`: {
+		{0, token.StringPart, []byte("a")},
+		{2, token.InsertBegin, nil},
+		{4, token.StringPart, []byte("b")},
+		{6, token.InsertBegin, nil},
+		{9, token.LBrace, nil},
+		{10, token.IdentLocalVar, []byte("c")},
+		{11, token.Colon, nil},
+		{13, token.StringPart, []byte("")},
+		{14, token.InsertBegin, nil},
+		{17, token.LBrace, nil},
+		{18, token.RBrace, nil},
+		{20, token.InsertEnd, nil},
+		{21, token.String, []byte("")},
+		{22, token.RBrace, nil},
+		{24, token.InsertEnd, nil},
+		{25, token.String, []byte("")},
+		{26, token.InsertEnd, nil},
+		{27, token.String, []byte("d")},
+		{29, token.EOF, nil},
+	},
 	`"\n"`:       {{0, token.String, []byte{0x0a}}},
 	`"\t"`:       {{0, token.String, []byte{0x09}}},
 	`"\r"`:       {{0, token.String, []byte{0x0d}}},
@@ -251,6 +372,156 @@ var rules = map[string][]struct {
 	`%q{\\}`:   {{0, token.String, []byte(`\`)}},
 	`%q{\}}`:   {{0, token.String, []byte(`}`)}},
 	`%q<\}>`:   {{0, token.String, []byte(`\}`)}},
+	`'a'.b`: {
+		{0, token.String, []byte(`a`)},
+		{3, token.Dot, nil},
+		{4, token.IdentLocalVar, []byte(`b`)},
+	},
+
+	// percent literals
+	`%q(a (b) c)`:  {{0, token.String, []byte(`a (b) c`)}},
+	`%q(a\(b)`:     {{0, token.String, []byte(`a(b`)}},
+	`%q<<a>>`:      {{0, token.String, []byte(`<a>`)}},
+	`%Q[a[b]c]`:    {{0, token.String, []byte(`a[b]c`)}},
+	`%Q(a\)\n)`:    {{0, token.String, []byte("a)\n")}},
+	`%s(sym)`:      {{0, token.Symbol, []byte(`sym`)}},
+	`%s{a{b}}`:     {{0, token.Symbol, []byte(`a{b}`)}},
+	`%(a)`:         {{0, token.String, []byte(`a`)}},
+	`%*a*`:         {{0, token.String, []byte(`a`)}},
+	`%q(a\\)`:      {{0, token.String, []byte(`a\`)}},
+	`%q(a\b)`:      {{0, token.String, []byte(`a\b`)}},
+	`%q(a)b`:       {{0, token.String, []byte(`a`)}, {5, token.IdentLocalVar, []byte(`b`)}},
+	`%Q(a #{b} c)`: {{0, token.StringPart, []byte(`a `)}, {5, token.InsertBegin, nil}},
+	`%Q{#{x}}`: {
+		{0, token.StringPart, []byte("")},
+		{3, token.InsertBegin, nil},
+		{5, token.IdentLocalVar, []byte("x")},
+		{6, token.InsertEnd, nil},
+		{7, token.String, []byte("")},
+		{8, token.EOF, nil},
+	},
+	`%|#{x}|`: {
+		{0, token.StringPart, []byte("")},
+		{2, token.InsertBegin, nil},
+		{4, token.IdentLocalVar, []byte("x")},
+		{5, token.InsertEnd, nil},
+		{6, token.String, []byte("")},
+		{7, token.EOF, nil},
+	},
+	`%w(a b\ c  d)`: {
+		{0, token.WordsBegin, []byte("%w(")},
+		{3, token.String, []byte("a")},
+		{5, token.String, []byte("b c")},
+		{11, token.String, []byte("d")},
+		{12, token.LiteralEnd, []byte(")")},
+		{13, token.EOF, nil},
+	},
+	"%w[\n a\n]": {
+		{0, token.WordsBegin, []byte("%w[")},
+		{5, token.String, []byte("a")},
+		{7, token.LiteralEnd, []byte("]")},
+	},
+	`%w()`: {
+		{0, token.WordsBegin, []byte("%w(")},
+		{3, token.LiteralEnd, []byte(")")},
+	},
+	`%w(a (b c) d)`: {
+		{0, token.WordsBegin, []byte("%w(")},
+		{3, token.String, []byte("a")},
+		{5, token.String, []byte("(b")},
+		{8, token.String, []byte("c)")},
+		{11, token.String, []byte("d")},
+		{12, token.LiteralEnd, []byte(")")},
+	},
+	`%W(a#{b}c #{d} \n)`: {
+		{0, token.WordsBegin, []byte("%W(")},
+		{3, token.StringPart, []byte("a")},
+		{4, token.InsertBegin, nil},
+		{6, token.IdentLocalVar, []byte("b")},
+		{7, token.InsertEnd, nil},
+		{8, token.String, []byte("c")},
+		{10, token.StringPart, []byte("")},
+		{10, token.InsertBegin, nil},
+		{12, token.IdentLocalVar, []byte("d")},
+		{13, token.InsertEnd, nil},
+		{14, token.String, []byte("")},
+		{15, token.String, []byte("\n")},
+		{17, token.LiteralEnd, []byte(")")},
+	},
+	`%i[a b]`: {
+		{0, token.SymbolsBegin, []byte("%i[")},
+		{3, token.String, []byte("a")},
+		{5, token.String, []byte("b")},
+		{6, token.LiteralEnd, []byte("]")},
+	},
+	`%I<a#{b}>`: {
+		{0, token.SymbolsBegin, []byte("%I<")},
+		{3, token.StringPart, []byte("a")},
+		{4, token.InsertBegin, nil},
+		{6, token.IdentLocalVar, []byte("b")},
+		{7, token.InsertEnd, nil},
+		{8, token.String, []byte("")},
+		{8, token.LiteralEnd, []byte(">")},
+	},
+	`%r{a\}b(c)}im`: {
+		{0, token.RegexpBegin, []byte("%r{")},
+		{3, token.String, []byte(`a\}b(c)`)},
+		{10, token.LiteralEnd, []byte("}im")},
+		{13, token.EOF, nil},
+	},
+	`%r(#{x}\n)`: {
+		{0, token.RegexpBegin, []byte("%r(")},
+		{3, token.StringPart, []byte("")},
+		{3, token.InsertBegin, nil},
+		{5, token.IdentLocalVar, []byte("x")},
+		{6, token.InsertEnd, nil},
+		{7, token.String, []byte(`\n`)},
+		{9, token.LiteralEnd, []byte(")")},
+	},
+	`%x(ls -l)`: {
+		{0, token.CommandBegin, []byte("%x(")},
+		{3, token.String, []byte("ls -l")},
+		{8, token.LiteralEnd, []byte(")")},
+	},
+	`%q(a`: {{0, token.Illegal, nil}},
+	`x % 2`: {
+		{0, token.IdentLocalVar, []byte("x")},
+		{2, token.Mod, nil},
+		{4, token.DecimalInteger, []byte("2")},
+	},
+	`x%(a)`: {
+		{0, token.IdentLocalVar, []byte("x")},
+		{1, token.Mod, nil},
+		{2, token.LParen, nil},
+	},
+	`(1)%(a)`: {
+		{0, token.LParen, nil},
+		{1, token.DecimalInteger, []byte("1")},
+		{2, token.RParen, nil},
+		{3, token.Mod, nil},
+	},
+	`1r %(2)`: {
+		{0, token.Rational, []byte("1r")},
+		{3, token.Mod, nil},
+		{4, token.LParen, nil},
+	},
+	`2i /3`: {
+		{0, token.Imaginary, []byte("2i")},
+		{3, token.Div, nil},
+		{4, token.DecimalInteger, []byte("3")},
+	},
+	`puts %w(a)`: {
+		{0, token.IdentLocalVar, []byte("puts")},
+		{5, token.WordsBegin, []byte("%w(")},
+		{8, token.String, []byte("a")},
+		{9, token.LiteralEnd, []byte(")")},
+	},
+	`f(%(a))`: {
+		{0, token.IdentLocalVar, []byte("f")},
+		{1, token.LParen, nil},
+		{2, token.String, []byte("a")},
+		{6, token.RParen, nil},
+	},
 
 	// heredoc
 	"a <<TEXT, x\nabc\n\nTEXT\n": {
@@ -308,12 +579,91 @@ var rules = map[string][]struct {
 		{28, token.EOF, nil},
 	},
 
+	"\"#{<<A}\"\nbody\nA\n": {
+		{0, token.StringPart, []byte("")},
+		{1, token.InsertBegin, nil},
+		{3, token.HeredocBegin, []byte("<<A")},
+		{6, token.InsertEnd, nil},
+		{7, token.String, []byte("")},
+		{8, token.NewLine, nil},
+		{9, token.HeredocEnd, []byte("body\n")},
+		{16, token.EOF, nil},
+	},
+	"f(<<A, <<B)\na\nA\nb\nB\n": {
+		{0, token.IdentLocalVar, []byte("f")},
+		{1, token.LParen, nil},
+		{2, token.HeredocBegin, []byte("<<A")},
+		{5, token.Comma, nil},
+		{7, token.HeredocBegin, []byte("<<B")},
+		{10, token.RParen, nil},
+		{11, token.NewLine, nil},
+		{12, token.HeredocEnd, []byte("a\n")},
+		{16, token.HeredocEnd, []byte("b\n")},
+		{20, token.EOF, nil},
+	},
+	"<<A\n#x #{ {} }\nA\n": {
+		{0, token.HeredocBegin, []byte("<<A")},
+		{3, token.NewLine, nil},
+		{4, token.HeredocPart, []byte("#x ")},
+		{7, token.InsertBegin, nil},
+		{10, token.LBrace, nil},
+		{11, token.RBrace, nil},
+		{13, token.InsertEnd, nil},
+		{14, token.HeredocEnd, []byte("\n")},
+		{17, token.EOF, nil},
+	},
+
+	"<<'A'\n\\t#{x}\\\\\nA\n": {
+		{0, token.HeredocBegin, []byte("<<'A'")},
+		{5, token.NewLine, nil},
+		{6, token.HeredocEnd, []byte("\\t#{x}\\\\\n")},
+		{17, token.EOF, nil},
+	},
+	"<<A\n\\t#{x}\\\n\\x41\nA\n": {
+		{0, token.HeredocBegin, []byte("<<A")},
+		{3, token.NewLine, nil},
+		{4, token.HeredocPart, []byte("\t")},
+		{6, token.InsertBegin, nil},
+		{8, token.IdentLocalVar, []byte("x")},
+		{9, token.InsertEnd, nil},
+		{10, token.HeredocEnd, []byte("A\n")},
+		{19, token.EOF, nil},
+	},
+	"<<-A\n  a\\t\n  b\n  A\n": {
+		{0, token.HeredocBegin, []byte("<<-A")},
+		{4, token.NewLine, nil},
+		{5, token.HeredocEnd, []byte("  a\t\n  b\n")},
+		{19, token.EOF, nil},
+	},
+
 	// ident
-	"v":        {{0, token.IdentLocalVar, []byte("v")}},
-	"_":        {{0, token.IdentLocalVar, []byte("_")}},
-	"v?":       {{0, token.IdentLocalMethod, []byte("v?")}},
-	"v!":       {{0, token.IdentLocalMethod, []byte("v!")}},
-	"v=":       {{0, token.IdentLocalMethod, []byte("v=")}},
+	"v":  {{0, token.IdentLocalVar, []byte("v")}},
+	"_":  {{0, token.IdentLocalVar, []byte("_")}},
+	"v?": {{0, token.IdentLocalMethod, []byte("v?")}},
+	"v!": {{0, token.IdentLocalMethod, []byte("v!")}},
+	"v=": {{0, token.IdentLocalMethod, []byte("v=")}},
+	"v==1": {
+		{0, token.IdentLocalVar, []byte("v")},
+		{1, token.Eq, nil},
+		{3, token.DecimalInteger, []byte("1")},
+	},
+	"v!=1": {
+		{0, token.IdentLocalVar, []byte("v")},
+		{1, token.NotEqual, nil},
+	},
+	"v=~a": {
+		{0, token.IdentLocalVar, []byte("v")},
+		{1, token.Match, nil},
+	},
+	"{v=>1}": {
+		{0, token.LBrace, nil},
+		{1, token.IdentLocalVar, []byte("v")},
+		{2, token.Arrow, nil},
+	},
+	"v=1": {
+		{0, token.IdentLocalMethod, []byte("v=")},
+		{2, token.DecimalInteger, []byte("1")},
+	},
 	"$v":       {{0, token.IdentGlobalVar, []byte("$v")}},
 	"$v1":      {{0, token.IdentGlobalVar, []byte("$v1")}},
 	"@var1":    {{0, token.IdentInstanceVar, []byte("@var1")}},
@@ -366,6 +716,37 @@ var rules = map[string][]struct {
 	"when":         {{0, token.KeywordWhen, nil}},
 	"while":        {{0, token.KeywordWhile, nil}},
 	"yield":        {{0, token.KeywordYield, nil}},
+
+	// non-ASCII identifiers
+	"café":   {{0, token.IdentLocalVar, []byte("café")}},
+	"変数":     {{0, token.IdentLocalVar, []byte("変数")}},
+	"ä?":     {{0, token.IdentLocalMethod, []byte("ä?")}},
+	"Ünder":  {{0, token.IdentConst, []byte("Ünder")}},
+	"ǅx":     {{0, token.IdentConst, []byte("ǅx")}},
+	"Aé":     {{0, token.IdentConst, []byte("Aé")}},
+	"_ñ":     {{0, token.IdentLocalVar, []byte("_ñ")}},
+	"\u3000": {{0, token.IdentLocalVar, []byte("\u3000")}},
+	"@名前":    {{0, token.IdentInstanceVar, []byte("@名前")}},
+	"@@é":    {{0, token.IdentClassVar, []byte("@@é")}},
+	"$グ":     {{0, token.IdentGlobalVar, []byte("$グ")}},
+	"é = 1": {
+		{0, token.IdentLocalVar, []byte("é")},
+		{3, token.Assign, nil},
+		{5, token.DecimalInteger, []byte("1")},
+	},
+	`"#@é"`: {
+		{0, token.StringPart, []byte("")},
+		{1, token.IdentInstanceVar, []byte("@é")},
+		{5, token.String, []byte("")},
+	},
+	"a\xff": {
+		{0, token.IdentLocalVar, []byte("a")},
+		{1, token.Illegal, []byte{0xff}},
+	},
+	"\xe3\x81": {
+		{0, token.Illegal, []byte{0xe3}},
+		{1, token.Illegal, []byte{0x81}},
+	},
 }
 
 func TestScanner(t *testing.T) {
@@ -383,3 +764,37 @@ func TestScanner(t *testing.T) {
 		}
 	}
 }
+
+// TestNestedInsert scans strings nested in insertions in strings, such as
+// "#{"#{"#{x}"}"}".
+func TestNestedInsert(t *testing.T) {
+	for depth := 1; depth <= 32; depth++ {
+		src := strings.Repeat(`"#{ {} + `, depth) + "x" + strings.Repeat(` }"`, depth)
+		s := NewString(src)
+
+		var inserts, braces int
+		for {
+			_, tk, l := s.Scan()
+			switch tk {
+			case token.InsertBegin:
+				inserts++
+			case token.InsertEnd:
+				inserts--
+			case token.LBrace:
+				braces++
+			case token.RBrace:
+				braces--
+			case token.IdentLocalVar:
+				if inserts != depth {
+					t.Errorf("depth=%v: %s must be in %v insertions (got=%v)", depth, l, depth, inserts)
+				}
+			}
+			if tk == token.EOF || tk == token.Illegal {
+				break
+			}
+		}
+		if inserts != 0 || braces != 0 {
+			t.Errorf("depth=%v: unbalanced insertions=%v, braces=%v", depth, inserts, braces)
+		}
+	}
+}
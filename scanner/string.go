@@ -7,47 +7,205 @@ import (
 	"github.com/harukasan/ringo/token"
 )
 
-func scanDoubleQuotedString(s *Scanner, term byte, head int) (token.Token, []byte) {
-	t := token.String
-	next, rOffset := decodeEscapes(s, term)
-	switch {
-	case isInsertPrefix(next):
-		t = token.StringPart
-		s.pushCtx(stateDoubleQuotedStringIn[term])
-	case next == term:
-		s.next()
+// escaping specifies how escape sequences are treated in a literal.
+type escaping int
+
+const (
+	escapeAll    escaping = iota // all escape sequences are decoded as "..."
+	escapeQuote                  // only \\ and escaped delimiters as '...'
+	escapeRegexp                 // escapes are kept, but never terminates
+	escapeNone                   // backslashes have no meaning
+)
+
+// literal describes a string-like literal that is being scanned, such as
+// "...", '...', %w(...), or %r{...}. All of the literals are scanned by the
+// same state, stateLiteral, according to the description.
+type literal struct {
+	kind   token.Token // String, Symbol, WordsBegin, SymbolsBegin, RegexpBegin, or CommandBegin
+	escape escaping
+	interp bool // whether #{...} can be inserted
+	words  bool // whether white spaces separate elements
+	open   byte // opening delimiter that nests, or 0
+	term   byte // closing delimiter
+	nest   int  // depth of the nested delimiters
+	part   bool // whether the current element is followed by an insertion
+	closed bool // whether the content is closed and the end token remains
+	begin  int  // offset of the opening delimiter
+	body   int  // offset just after the opening delimiter
+}
+
+// percentLiterals holds the literals by the type of percent literals, such as
+// 'w' for %w(...). The % without the type is same as %Q.
+var percentLiterals = [127]literal{
+	'Q': {kind: token.String, escape: escapeAll, interp: true},
+	'q': {kind: token.String, escape: escapeQuote},
+	'W': {kind: token.WordsBegin, escape: escapeAll, interp: true, words: true},
+	'w': {kind: token.WordsBegin, escape: escapeQuote, words: true},
+	'I': {kind: token.SymbolsBegin, escape: escapeAll, interp: true, words: true},
+	'i': {kind: token.SymbolsBegin, escape: escapeQuote, words: true},
+	's': {kind: token.Symbol, escape: escapeQuote},
+	'r': {kind: token.RegexpBegin, escape: escapeRegexp, interp: true},
+	'x': {kind: token.CommandBegin, escape: escapeAll, interp: true},
+}
+
+// isPercentDelimiter returns whether the character can delimit a percent
+// literal.
+func isPercentDelimiter(c byte) bool {
+	return ' ' < c && c < 127 && !token.IsAlnum(c)
+}
+
+func isWordSeparator(c byte) bool {
+	return token.IsWhiteSpace(c) || c == '\n'
+}
+
+func isRegexpOption(c byte) bool {
+	switch c {
+	case 'i', 'm', 'x', 'o', 'u', 'e', 's', 'n':
+		return true
 	}
-	off := s.offset - rOffset
-	if off > s.begin+1 {
-		off--
+	return false
+}
+
+// stop reasons of literal.scan
+const (
+	stopTerm   = iota // at the closing delimiter
+	stopInsert        // at an insertion
+	stopSpace         // at a separator of words
+	stopEOF           // at the end of source
+)
+
+// scan scans the content of the literal from the current offset, decoding
+// escape sequences in place. It stops without consuming the character which
+// causes the stop, and returns the reason and the number of bytes reduced by
+// decoding added to skip.
+func (l *literal) scan(s *Scanner, skip int) (int, int) {
+	for s.err == nil {
+		c := s.char
+		switch {
+		case c == l.term && l.nest == 0:
+			return stopTerm, skip
+		case c == l.term:
+			l.nest--
+		case l.open != 0 && c == l.open:
+			l.nest++
+		case l.words && isWordSeparator(c):
+			return stopSpace, skip
+		case l.interp && c == '#' && s.isInsert():
+			return stopInsert, skip
+		case c == '\\' && l.escape != escapeNone:
+			skip = l.decodeEscape(s, skip)
+			continue
+		}
+		replace(s, c, skip)
 	}
-	return t, s.src[s.begin+head : off]
+	return stopEOF, skip
 }
 
-var stateDoubleQuotedStringIn = [...]stateScanFunc{
-	'"': stateDoubleQuotedStringInFunc('"'),
-	')': stateDoubleQuotedStringInFunc(')'),
-	']': stateDoubleQuotedStringInFunc(']'),
-	'>': stateDoubleQuotedStringInFunc('>'),
+// decodeEscape decodes an escape sequence which begins at the current offset.
+func (l *literal) decodeEscape(s *Scanner, skip int) int {
+	p := s.peek(2)
+	if p == nil {
+		replace(s, '\\', skip)
+		return skip
+	}
+	c := p[1]
+	switch l.escape {
+	case escapeAll:
+		if !l.words || !isWordSeparator(c) {
+			return decodeEscape(s, skip)
+		}
+	case escapeQuote:
+		if c != '\\' && c != l.term && (l.open == 0 || c != l.open) && (!l.words || !isWordSeparator(c)) {
+			replace(s, '\\', skip)
+			return skip
+		}
+	case escapeRegexp:
+		replace(s, '\\', skip)
+		replace(s, c, skip)
+		return skip
+	}
+	s.next()
+	skip++
+	replace(s, c, skip)
+	return skip
 }
 
-func stateDoubleQuotedStringInFunc(term byte) stateScanFunc {
-	return func(s *Scanner) (int, token.Token, []byte) {
-		if s.char == '#' {
-			p, t, lit := scanInsert(s)
-			if t != token.Continue {
-				return p, t, lit
-			}
+// scanSegment scans a segment of the literal which continues until an
+// insertion, a separator of words, or the end of literal. It returns the
+// token and the decoded literal of the segment, and whether the literal
+// continues after the segment.
+func scanSegment(s *Scanner, l *literal) (token.Token, []byte, bool) {
+	begin := s.offset
+	stop, skip := l.scan(s, 0)
+	lit := s.src[begin : s.offset-skip]
+	l.part = stop == stopInsert
+	switch stop {
+	case stopInsert:
+		return token.StringPart, lit, true
+	case stopSpace:
+		return token.String, lit, true
+	case stopTerm:
+		if l.kind == token.String || l.kind == token.Symbol {
+			s.next()
+			return l.kind, lit, false
 		}
-		s.begin = s.offset
-		next, nEscape := decodeEscapes(s, '"')
-		if next == '@' || next == '$' || next == '{' {
-			return s.begin, token.StringPart, s.src[s.begin : s.offset-nEscape]
+		l.closed = true
+		return token.String, lit, true
+	}
+	s.failf("unterminated string meets end of file")
+	s.label(l.begin, l.body, "unterminated string opened here")
+	return token.Illegal, nil, false
+}
+
+// scanLiteral begins to scan the literal l after its opening delimiter. The
+// content of strings and symbols follows the opening delimiter in the same
+// token, and the others return the token which begins the literal.
+func scanLiteral(s *Scanner, l literal) (token.Token, []byte) {
+	l.begin, l.body = s.begin, s.offset
+	if l.kind == token.String || l.kind == token.Symbol {
+		t, lit, more := scanSegment(s, &l)
+		if more {
+			s.pushLiteral(l)
 		}
+		return t, lit
+	}
+	s.pushLiteral(l)
+	return l.kind, s.src[s.begin:s.offset]
+}
+
+func (s *Scanner) pushLiteral(l literal) {
+	s.pushCtx(stateLiteral)
+	s.ctx.lit = l
+}
+
+// stateLiteral scans the rest of the literal in the context.
+func stateLiteral(s *Scanner) (int, token.Token, []byte) {
+	l := &s.ctx.lit
+	if l.part && s.char == '#' && s.isInsert() {
+		return scanInsert(s)
+	}
+	if l.words && !l.part && !l.closed {
+		for isWordSeparator(s.char) {
+			s.next()
+		}
+		l.closed = s.char == l.term && l.nest == 0
+	}
+	s.begin = s.offset
+	if l.closed {
 		s.next()
+		if l.kind == token.RegexpBegin {
+			for isRegexpOption(s.char) {
+				s.next()
+			}
+		}
 		s.popCtx()
-		return s.begin, token.String, s.src[s.begin : s.offset-nEscape-1]
+		return s.begin, token.LiteralEnd, s.src[s.begin:s.offset]
 	}
+	t, lit, more := scanSegment(s, l)
+	if !more {
+		s.popCtx()
+	}
+	return s.begin, t, lit
 }
 
 func scanInsert(s *Scanner) (int, token.Token, []byte) {
@@ -74,24 +232,6 @@ func replace(s *Scanner, c byte, offset int) {
 	s.next()
 }
 
-func decodeEscapes(s *Scanner, term byte) (byte, int) {
-	var skip int
-	for s.char != term && s.err == nil {
-		switch s.char {
-		case '#':
-			next := s.peek(2)[1]
-			if isInsertPrefix(next) {
-				return next, skip
-			}
-		case '\\':
-			skip = decodeEscape(s, skip)
-		default:
-			replace(s, s.char, skip)
-		}
-	}
-	return s.char, skip
-}
-
 func decodeEscape(s *Scanner, skip int) int {
 	skip++
 	s.next()
@@ -102,8 +242,10 @@ func decodeEscape(s *Scanner, skip int) int {
 	var n int
 	c := s.char
 	switch c {
-	case '\n':
-		n = 2
+	case '\n': // line continuation
+		skip++
+		s.next()
+		return skip
 	case '0', '1', '2', '3', '4', '5', '6', '7':
 		n, c = decodeOctalEsc(s)
 	case 'x':
@@ -188,28 +330,22 @@ func decodeHexEsc(s *Scanner) (n int, v byte) {
 	return
 }
 
+// stateInsertStmts scans statements in #{...}. Braces opened in the
+// statements are counted so that only the matching '}' ends the insertion.
 func stateInsertStmts(s *Scanner) (pos int, t token.Token, literal []byte) {
 	s.begin = s.offset
-	if s.char == '}' {
-		s.next()
-		s.popCtx()
-		return s.begin, token.InsertEnd, nil
-	}
-	return stateCompStmts(s)
-}
-
-func scanSingleQuotedString(s *Scanner, term byte, head int) (token.Token, []byte) {
-	var skip int
-	for s.char != term && s.err == nil {
-		if s.char == '\\' {
+	switch s.char {
+	case '{':
+		s.ctx.braces++
+	case '}':
+		if s.ctx.braces == 0 {
 			s.next()
-			if s.char == '\\' || s.char == term {
-				skip++
-			}
+			s.popCtx()
+			return s.begin, token.InsertEnd, nil
 		}
-		replace(s, s.char, skip)
+		s.ctx.braces--
 	}
-	return token.String, s.src[s.begin+head : s.offset-skip]
+	return stateCompStmts(s)
 }
 
 func isQuote(c byte) bool {
@@ -221,7 +357,7 @@ func scanHeredocBegin(s *Scanner) (token.Token, []byte) {
 	indent := false
 	termBegin := s.offset
 	switch c := s.char; {
-	case token.IsLetter(c) || c == '_':
+	case token.IsLetter(c) || c == '_' || isQuote(c):
 		s.next()
 	case token.IsDecimal(c):
 		if s.ctx.nospace {
@@ -251,21 +387,34 @@ func scanHeredocBegin(s *Scanner) (token.Token, []byte) {
 		s.next()
 	}
 	term := s.src[termBegin:s.offset]
-	s.pushCtx(stateHeredocFirstLine(term, indent))
+	s.heredocs = append(s.heredocs, heredoc{term: term, indent: indent, begin: s.begin, end: s.offset})
 	return token.HeredocBegin, s.src[s.begin:s.offset]
 }
 
-func stateHeredocFirstLine(term []byte, indent bool) stateScanFunc {
-	return func(s *Scanner) (int, token.Token, []byte) {
-		if s.char == '\n' {
-			begin := s.offset
-			s.next()
-			s.popCtx()
-			s.pushCtx(stateInHeredoc(term, indent))
-			return begin, token.NewLine, nil
-		}
-		return stateCompStmts(s)
+// heredoc holds a heredoc whose body is not scanned yet.
+type heredoc struct {
+	term   []byte // terminator including quotes
+	indent bool   // whether the terminator may be indented
+	begin  int    // offset of the heredoc identifier such as <<EOS
+	end    int    // offset just after the identifier
+}
+
+// beginHeredoc starts scanning the body of the first pending heredoc. The
+// body of a heredoc begins at the line next to the heredoc identifier, so it
+// is called after a new line. The rest of the pending heredocs follows in
+// order.
+func (s *Scanner) beginHeredoc() {
+	if len(s.heredocs) == 0 {
+		return
 	}
+	h := s.heredocs[0]
+	s.heredocs = s.heredocs[1:]
+	s.pushCtx(stateInHeredoc(h))
+}
+
+func (s *Scanner) endHeredoc() {
+	s.popCtx()
+	s.beginHeredoc()
 }
 
 func isHeredocEndTerm(s *Scanner, term []byte, indent bool) (bool, int) {
@@ -287,55 +436,44 @@ func isHeredocEndTerm(s *Scanner, term []byte, indent bool) (bool, int) {
 			return true, tOff
 		}
 	}
+	s.reset(tOff)
 	return false, 0
 }
 
-func stateInHeredoc(term []byte, indent bool) stateScanFunc {
-	if term[0] == '\'' {
+// stateInHeredoc returns the state to scan the body of heredoc. The body is
+// scanned as a literal which is terminated by each new line, and the
+// identifier quoted by single quotes disables insertions and escapes.
+func stateInHeredoc(h heredoc) stateScanFunc {
+	term, indent := h.term, h.indent
+	l := literal{term: '\n', escape: escapeAll, interp: true}
+	switch term[0] {
+	case '\'':
+		l = literal{term: '\n', escape: escapeNone}
 		term = term[1 : len(term)-1]
-		return stateInHeredocSingleQuoted(term, indent)
-	}
-	if term[0] == '"' {
+	case '"':
 		term = term[1 : len(term)-1]
 	}
-	return stateInHeredocDoubleQuoted(term, indent)
-}
-
-func stateInHeredocDoubleQuoted(term []byte, indent bool) stateScanFunc {
 	return func(s *Scanner) (int, token.Token, []byte) {
-		if s.char == '#' {
-			p, t, lit := scanInsert(s)
-			if t != token.Continue {
-				return p, t, lit
-			}
+		if l.interp && s.char == '#' && s.isInsert() {
+			return scanInsert(s)
 		}
 		s.begin = s.offset
+		var skip, stop int
 		for s.err == nil {
 			if isEnd, off := isHeredocEndTerm(s, term, indent); isEnd {
-				s.popCtx()
-				return s.begin, token.HeredocEnd, s.src[s.begin:off]
+				s.endHeredoc()
+				return s.begin, token.HeredocEnd, s.src[s.begin : off-skip]
 			}
-			next, skip := decodeEscapes(s, '\n')
-			if next == '@' || next == '$' || next == '{' {
+			stop, skip = l.scan(s, skip)
+			if stop == stopInsert {
 				return s.begin, token.HeredocPart, s.src[s.begin : s.offset-skip]
 			}
-			s.next()
-		}
-		return s.begin, token.Illegal, nil
-	}
-}
-
-func stateInHeredocSingleQuoted(term []byte, indent bool) stateScanFunc {
-	return func(s *Scanner) (int, token.Token, []byte) {
-		s.begin = s.offset
-		for s.err == nil {
-			if isEnd, off := isHeredocEndTerm(s, term, indent); isEnd {
-				s.popCtx()
-				return s.begin, token.HeredocEnd, s.src[s.begin:off]
+			if s.err == nil {
+				replace(s, '\n', skip)
 			}
-			scanSingleQuotedString(s, '\n', 0)
-			s.next()
 		}
+		s.failf("unterminated heredoc meets end of file")
+		s.label(h.begin, h.end, "heredoc started here")
 		return s.begin, token.Illegal, nil
 	}
 }
@@ -3,6 +3,8 @@ package scanner
 import (
 	"bytes"
 	"testing"
+
+	"github.com/harukasan/ringo/token"
 )
 
 func TestScanSingleQuotedString(t *testing.T) {
@@ -15,7 +17,7 @@ func TestScanSingleQuotedString(t *testing.T) {
 
 	s := New(work)
 	s.next() // skip first '
-	_, got := scanSingleQuotedString(s, '\'', 1)
+	_, got := scanSingleQuote(s)
 
 	if !bytes.Equal(got, want) {
 		t.Fatalf("\ninput =%#v\nwant  =%#v\ngot   =%#v", string(input), string(want), string(got))
@@ -55,3 +57,26 @@ func TestDecodeHexEsc(t *testing.T) {
 		}
 	}
 }
+
+func TestUnterminatedLabels(t *testing.T) {
+	rules := map[string]Label{
+		`x = "abc`:            {4, 5, "unterminated string opened here"},
+		"x = %q(a\nb":         {4, 7, "unterminated string opened here"},
+		"x = <<EOS\nabc\n":    {4, 9, "heredoc started here"},
+		"=begin\nx":           {0, 6, "comment started here"},
+		"f <<-A, <<B\nA\nb\n": {8, 11, "heredoc started here"},
+	}
+	for input, want := range rules {
+		s := NewString(input)
+		for _, tk, _ := s.Scan(); tk != token.EOF; _, tk, _ = s.Scan() {
+		}
+		errs := s.Errors()
+		if len(errs) != 1 || len(errs[0].Labels) != 1 {
+			t.Errorf("%q: errors=%v (want=1 error with 1 label)", input, errs)
+			continue
+		}
+		if got := errs[0].Labels[0]; got != want {
+			t.Errorf("%q: label=%v (want=%v)", input, got, want)
+		}
+	}
+}
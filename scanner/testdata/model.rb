@@ -0,0 +1,192 @@
+# frozen_string_literal: true
+
+module Shop
+  class Error < StandardError; end
+
+  class Money
+    include Comparable
+
+    attr_reader :cents, :currency
+
+    DEFAULT_CURRENCY = "USD"
+    RATES = {
+      "USD" => 1.0,
+      "EUR" => 0.92,
+      "JPY" => 151.25,
+    }
+
+    def initialize(cents, currency = DEFAULT_CURRENCY)
+      raise Error, "unknown currency: #{currency}" unless RATES.key?(currency)
+      @cents = cents
+      @currency = currency
+    end
+
+    def self.zero(currency = DEFAULT_CURRENCY)
+      new(0, currency)
+    end
+
+    def +(other)
+      other = other.exchange_to(currency)
+      Money.new(cents + other.cents, currency)
+    end
+
+    def -(other)
+      other = other.exchange_to(currency)
+      Money.new(cents - other.cents, currency)
+    end
+
+    def *(factor)
+      Money.new((cents * factor).round, currency)
+    end
+
+    def <=>(other)
+      return nil unless other.is_a?(Money)
+      cents <=> other.exchange_to(currency).cents
+    end
+
+    def exchange_to(target)
+      return self if target == currency
+      rate = RATES.fetch(target) * 1.0 / RATES.fetch(currency)
+      Money.new((cents * rate).round, target)
+    end
+
+    def to_s
+      format("%.2f %s", cents * 0.01, currency)
+    end
+
+    def inspect
+      "#<Money #@cents #@currency>"
+    end
+  end
+
+  class LineItem
+    attr_accessor :sku, :quantity, :price
+
+    def initialize(sku:, quantity: 1, price:)
+      @sku = sku
+      @quantity = quantity
+      @price = price
+    end
+
+    def total
+      price * quantity
+    end
+
+    def to_h
+      { sku: sku, quantity: quantity, price: price.to_s }
+    end
+  end
+
+  class Order
+    include Enumerable
+
+    STATES = %i[pending paid shipped cancelled]
+
+    attr_reader :items, :state, :id
+
+    @@sequence = 0
+
+    def self.next_id
+      @@sequence += 1
+    end
+
+    def initialize(customer, items = [])
+      @id = self.class.next_id
+      @customer = customer
+      @items = items
+      @state = :pending
+      @history = []
+    end
+
+    def each(&block)
+      @items.each(&block)
+    end
+
+    def add(sku, quantity, price)
+      existing = @items.find { |item| item.sku == sku }
+      if existing
+        existing.quantity += quantity
+      else
+        @items << LineItem.new(sku: sku, quantity: quantity, price: price)
+      end
+      self
+    end
+
+    def remove(sku)
+      @items.reject! { |item| item.sku == sku }
+    end
+
+    def subtotal
+      @items.inject(Money.zero) { |sum, item| sum + item.total }
+    end
+
+    def tax(rate = 0.08)
+      subtotal * rate
+    end
+
+    def total
+      subtotal + tax
+    end
+
+    def transition!(to)
+      from = @state
+      unless STATES.include?(to)
+        raise Error, "invalid state #{to}"
+      end
+      case [from, to]
+      when [:pending, :paid], [:paid, :shipped]
+        @state = to
+      when [:pending, :cancelled], [:paid, :cancelled]
+        @state = to
+      else
+        raise Error, "cannot move from #{from} to #{to}"
+      end
+      @history << [from, to]
+      to
+    end
+
+    def paid?
+      @state == :paid || @state == :shipped
+    end
+
+    def summary
+      lines = @items.map do |item|
+        "  #{item.sku} x#{item.quantity} @ #{item.price}"
+      end
+      header = "Order ##@id for #{@customer} (#{@state})"
+      [header, *lines, "  total: #{total}"].join("\n")
+    end
+  end
+
+  module Report
+    module_function
+
+    def daily(orders)
+      paid = orders.select(&:paid?)
+      totals = paid.group_by { |o| o.total.currency }
+      totals.each_with_object({}) do |(currency, list), acc|
+        acc[currency] = list.map(&:total).inject(:+)
+      end
+    end
+
+    def print(orders, io = $stdout)
+      daily(orders).each do |currency, money|
+        io.puts "#{currency}: #{money}"
+      end
+      io.puts "count: #{orders.size}"
+    end
+  end
+end
+
+if __FILE__ == $PROGRAM_NAME
+  order = Shop::Order.new("alice")
+  order.add("A-100", 2, Shop::Money.new(1_250))
+  order.add("B-200", 1, Shop::Money.new(99_900, "JPY"))
+  order.add("A-100", 1, Shop::Money.new(1_250))
+  order.transition!(:paid)
+  puts order.summary
+  Shop::Report.print([order])
+  x = 0x1F + 0b1010 - 0o17 + 3.14e2
+  y = x > 10 ? x ** 2 : -x
+  puts y
+end
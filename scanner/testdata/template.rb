@@ -0,0 +1,80 @@
+require 'erb'
+require "set"
+
+class Template
+  HEADER = <<-HTML
+    <!DOCTYPE html>
+    <html>
+    <head><title>#{title}</title></head>
+  HTML
+
+  FOOTER = <<-'HTML'
+    </html>
+  HTML
+
+  ESCAPES = {
+    '&' => '&amp;',
+    '<' => '&lt;',
+    '>' => '&gt;',
+    '"' => '&quot;',
+    "'" => '&#39;',
+  }
+
+  attr_reader :title, :sections
+
+  def initialize(title)
+    @title = title
+    @sections = []
+    @cache = nil
+  end
+
+  def section(name, body)
+    @sections << [name, body]
+    @cache = nil
+    self
+  end
+
+  def escape(text)
+    text.to_s.gsub("&", "&amp;").gsub("<", "&lt;").gsub(">", "&gt;")
+  end
+
+  def render_section(name, body)
+    out = "<section id=\"#{escape(name)}\">\n"
+    out << "  <h2>#{escape(name)}</h2>\n"
+    body.each_line do |line|
+      line = line.chomp
+      next if line.empty?
+      out << "  <p>#{escape(line)}</p>\n"
+    end
+    out << "</section>\n"
+    out
+  end
+
+  def render
+    return @cache if @cache
+    buffer = String.new
+    buffer << HEADER
+    buffer << "<body>\n"
+    @sections.each do |name, body|
+      buffer << render_section(name, body)
+    end
+    buffer << "</body>\n"
+    buffer << FOOTER
+    @cache = buffer
+  end
+
+  def to_s
+    %Q(#<Template "#{title}" sections=#{@sections.size}>)
+  end
+
+  def words
+    %q(a simple template engine)
+  end
+end
+
+page = Template.new("Release notes")
+page.section("Features", "Faster scanning\nHeredocs\n\nString inserts")
+page.section("Fixes", 'Escaped \'quotes\' work')
+page.section("Notes", "Tabs\tand\tnewlines\n are \"escaped\" \\ properly")
+$stdout.puts page.render
+$stderr.puts page.to_s if $VERBOSE
@@ -1,5 +1,10 @@
 package token
 
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
 // IsLetter returns whether the character is a alphabet that matches to [a-zA-Z]
 // in regular expression.
 func IsLetter(c byte) bool {
@@ -58,3 +63,69 @@ func IsIdent(c byte) bool {
 func IsAlnum(c byte) bool {
 	return IsDecimal(c) || IsLetter(c)
 }
+
+/*
+The rune-based classes below follow the rules of Ruby for the source in UTF-8.
+Any non-ASCII character can be a part of identifiers, and the identifier
+which begins with a uppercase or titlecase letter is a constant.
+*/
+
+// DecodeRune decodes the first UTF-8 character in p, and returns the rune and
+// its width in bytes. It returns (utf8.RuneError, 1) for an invalid encoding,
+// and (utf8.RuneError, 0) if p is empty.
+func DecodeRune(p []byte) (rune, int) {
+	if len(p) > 0 && p[0] < utf8.RuneSelf {
+		return rune(p[0]), 1
+	}
+	return utf8.DecodeRune(p)
+}
+
+// IsLetterRune returns whether the rune is a letter in Unicode.
+func IsLetterRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return IsLetter(byte(r))
+	}
+	return unicode.IsLetter(r)
+}
+
+// IsWhiteSpaceRune returns whether the rune is a white space. Note that the
+// non-ASCII spaces such as U+3000 are not white spaces but identifier
+// characters in Ruby.
+func IsWhiteSpaceRune(r rune) bool {
+	return r < utf8.RuneSelf && IsWhiteSpace(byte(r))
+}
+
+// IsUppercaseRune returns whether the rune is a uppercase or titlecase letter,
+// which begins a constant.
+func IsUppercaseRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return IsUppercase(byte(r))
+	}
+	return unicode.IsUpper(r) || unicode.IsTitle(r)
+}
+
+// IsLowercaseRune returns whether the rune is a lowercase letter.
+func IsLowercaseRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return IsLowercase(byte(r))
+	}
+	return unicode.IsLower(r)
+}
+
+// IsIdentStartRune returns whether the rune can begin an identifier. All of
+// non-ASCII characters can begin an identifier, except utf8.RuneError which is
+// returned for an invalid encoding.
+func IsIdentStartRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return IsIdentStart(byte(r))
+	}
+	return r != utf8.RuneError && utf8.ValidRune(r)
+}
+
+// IsIdentRune returns whether the rune can consists an identifier.
+func IsIdentRune(r rune) bool {
+	if r < utf8.RuneSelf {
+		return IsIdent(byte(r))
+	}
+	return r != utf8.RuneError && utf8.ValidRune(r)
+}
@@ -112,3 +112,92 @@ func TestClass(t *testing.T) {
 		}
 	}
 }
+
+var runeTests = map[rune]class{
+	'a':      Letter | Lowercase | IdentStart | Ident,
+	'Z':      Letter | Uppercase | IdentStart | Ident,
+	'_':      IdentStart | Ident,
+	'0':      Ident,
+	' ':      WhiteSpace,
+	'\t':     WhiteSpace,
+	'\n':     0,
+	'!':      0,
+	0x7f:     0,
+	'é':      Letter | Lowercase | IdentStart | Ident,
+	'É':      Letter | Uppercase | IdentStart | Ident,
+	'ß':      Letter | Lowercase | IdentStart | Ident,
+	'ǅ':      Letter | Uppercase | IdentStart | Ident, // titlecase
+	'Ω':      Letter | Uppercase | IdentStart | Ident,
+	'ω':      Letter | Lowercase | IdentStart | Ident,
+	'Ж':      Letter | Uppercase | IdentStart | Ident,
+	'変':      Letter | IdentStart | Ident,
+	'ア':      Letter | IdentStart | Ident,
+	'١':      IdentStart | Ident, // arabic-indic digit one
+	'\u00a0': IdentStart | Ident, // no-break space
+	'\u3000': IdentStart | Ident, // ideographic space
+	'€':      IdentStart | Ident,
+	'😀':      IdentStart | Ident,
+	0xfffd:   0, // utf8.RuneError
+	0xd800:   0, // surrogate
+	0x110000: 0, // out of range
+	-1:       0,
+}
+
+var runeFuncs = map[class]func(rune) bool{
+	Letter:     IsLetterRune,
+	WhiteSpace: IsWhiteSpaceRune,
+	Uppercase:  IsUppercaseRune,
+	Lowercase:  IsLowercaseRune,
+	IdentStart: IsIdentStartRune,
+	Ident:      IsIdentRune,
+}
+
+func TestRuneClass(t *testing.T) {
+	for sbj, sbjC := range runeTests {
+		for fC, f := range runeFuncs {
+			if want := sbjC&fC > 0; want != f(sbj) {
+				t.Errorf("%v: %U must returns %v", fC, sbj, want)
+			}
+		}
+	}
+}
+
+// TestRuneClassASCII checks that the rune-based classes are same as the
+// byte-based classes for all ASCII characters.
+func TestRuneClassASCII(t *testing.T) {
+	for c := 0; c < 0x80; c++ {
+		for fC, f := range runeFuncs {
+			if got, want := f(rune(c)), funcs[fC](byte(c)); got != want {
+				t.Errorf("%v: %U must returns %v", fC, c, want)
+			}
+		}
+	}
+}
+
+func TestDecodeRune(t *testing.T) {
+	rules := map[string]struct {
+		r    rune
+		size int
+	}{
+		"":             {0xfffd, 0},
+		"a":            {'a', 1},
+		"ab":           {'a', 1},
+		"\x00":         {0, 1},
+		"\x7f":         {0x7f, 1},
+		"é":            {'é', 2},
+		"変数":           {'変', 3},
+		"😀":            {'😀', 4},
+		"\xef\xbf\xbd": {0xfffd, 3},
+		"\xff":         {0xfffd, 1},
+		"\x80":         {0xfffd, 1},
+		"\xe3\x81":     {0xfffd, 1},
+		"\xc0\xaf":     {0xfffd, 1}, // overlong
+		"\xed\xa0\x80": {0xfffd, 1}, // surrogate
+	}
+	for input, want := range rules {
+		r, size := DecodeRune([]byte(input))
+		if r != want.r || size != want.size {
+			t.Errorf("DecodeRune(%q)=%U, %v (want=%U, %v)", input, r, size, want.r, want.size)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+//go:build ignore
+// +build ignore
+
+// gen_keywords generates keyword_table.go, the perfect hash table of the
+// keywords defined in token.go.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+// keep in sync with keywordHash in keyword.go.
+const (
+	tableBits = 7
+	tableSize = 1 << tableBits
+)
+
+func hash(s string, mul uint32) uint32 {
+	n := len(s)
+	c := s[n-1]
+	if n > 2 {
+		c = s[2]
+	}
+	key := uint32(s[0]) | uint32(c)<<8 | uint32(s[n-1])<<16 | uint32(n)<<24
+	return key * mul >> (32 - tableBits)
+}
+
+type keyword struct {
+	name string
+	text string
+}
+
+// keywords reads the names and the spellings of keywords from the const
+// block of token.go.
+func keywords() []keyword {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "token.go", nil, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var kws []keyword
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		section := ""
+		for _, spec := range gen.Specs {
+			v := spec.(*ast.ValueSpec)
+			if v.Doc != nil {
+				section = strings.TrimSpace(v.Doc.Text())
+			}
+			if section != "keywords:" || v.Comment == nil {
+				continue
+			}
+			kws = append(kws, keyword{v.Names[0].Name, strings.TrimSpace(v.Comment.Text())})
+		}
+	}
+	return kws
+}
+
+// search finds the multiplier which maps all keywords to different slots.
+func search(kws []keyword) uint32 {
+	for mul := uint32(1); mul != 0; mul += 2 {
+		var used [tableSize]bool
+		ok := true
+		for _, kw := range kws {
+			h := hash(kw.text, mul)
+			if used[h] {
+				ok = false
+				break
+			}
+			used[h] = true
+		}
+		if ok {
+			return mul
+		}
+	}
+	log.Fatal("no perfect hash is found")
+	return 0
+}
+
+func main() {
+	kws := keywords()
+	mul := search(kws)
+
+	var table [tableSize]*keyword
+	minLen, maxLen := len(kws[0].text), 0
+	for i, kw := range kws {
+		table[hash(kw.text, mul)] = &kws[i]
+		if len(kw.text) < minLen {
+			minLen = len(kw.text)
+		}
+		if len(kw.text) > maxLen {
+			maxLen = len(kw.text)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "// Code generated by gen_keywords.go; DO NOT EDIT.")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "package token")
+	fmt.Fprintln(&b)
+	fmt.Fprintf(&b, "const (\n\tkeywordHashMul = %d\n\tkeywordMinLen = %d\n\tkeywordMaxLen = %d\n)\n\n", mul, minLen, maxLen)
+	fmt.Fprintf(&b, "var keywordTable = [%d]struct {\n\ttext string\n\ttok Token\n}{\n", tableSize)
+	for h, kw := range table {
+		if kw != nil {
+			fmt.Fprintf(&b, "\t%d: {%q, %s},\n", h, kw.text, kw.name)
+		}
+	}
+	fmt.Fprintln(&b, "}")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("keyword_table.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
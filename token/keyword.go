@@ -0,0 +1,55 @@
+package token
+
+// The keywords are looked up by the perfect hash table generated by
+// gen_keywords.go. The hash is calculated from the length and three
+// characters of the keyword, so the lookup compares a string at most once.
+
+func keywordHash(n int, first, third, last byte) uint32 {
+	key := uint32(first) | uint32(third)<<8 | uint32(last)<<16 | uint32(n)<<24
+	return key * keywordHashMul >> (32 - 7)
+}
+
+// KeywordToken returns the keyword token that is matched to given literal.
+// If the literal is not matched to any keyword, it returns None.
+func KeywordToken(literal []byte) Token {
+	n := len(literal)
+	if n < keywordMinLen || n > keywordMaxLen {
+		return None
+	}
+	third := literal[n-1]
+	if n > 2 {
+		third = literal[2]
+	}
+	kw := &keywordTable[keywordHash(n, literal[0], third, literal[n-1])]
+	if kw.text != string(literal) {
+		return None
+	}
+	return kw.tok
+}
+
+// Lookup returns the keyword token that is matched to given string. If the
+// string is not matched to any keyword, it returns None.
+func Lookup(s string) Token {
+	n := len(s)
+	if n < keywordMinLen || n > keywordMaxLen {
+		return None
+	}
+	third := s[n-1]
+	if n > 2 {
+		third = s[2]
+	}
+	kw := &keywordTable[keywordHash(n, s[0], third, s[n-1])]
+	if kw.text != s {
+		return None
+	}
+	return kw.tok
+}
+
+// KeywordText returns the spelling of the keyword token. It returns an empty
+// string if the token is not a keyword.
+func KeywordText(t Token) string {
+	if !t.IsKeyword() {
+		return ""
+	}
+	return texts[t]
+}
@@ -0,0 +1,57 @@
+package token
+
+import (
+	"bytes"
+	"testing"
+)
+
+// legacyKeywordToken is the previous implementation of KeywordToken, which
+// scans the keywords beginning with the same character.
+var (
+	legacyKeywordLiterals [127][][]byte
+	legacyKeywordTokens   [127][]Token
+)
+
+func init() {
+	for tok := KeywordLINE; tok <= KeywordYield; tok++ {
+		text := KeywordText(tok)
+		legacyKeywordLiterals[text[0]] = append(legacyKeywordLiterals[text[0]], []byte(text))
+		legacyKeywordTokens[text[0]] = append(legacyKeywordTokens[text[0]], tok)
+	}
+}
+
+func legacyKeywordToken(literal []byte) Token {
+	initial := literal[0]
+	if list := legacyKeywordLiterals[initial]; list != nil {
+		for i := 0; i < len(list); i++ {
+			if bytes.Equal(list[i], literal) {
+				return legacyKeywordTokens[initial][i]
+			}
+		}
+	}
+	return None
+}
+
+// benchWords is a mix of keywords and identifiers as seen in Ruby sources.
+var benchWords = [][]byte{
+	[]byte("def"), []byte("end"), []byte("if"), []byte("self"),
+	[]byte("attr_reader"), []byte("name"), []byte("return"), []byte("nil"),
+	[]byte("each"), []byte("do"), []byte("elsif"), []byte("unless"),
+	[]byte("puts"), []byte("ensure"), []byte("value"), []byte("true"),
+}
+
+func BenchmarkKeywordToken(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, w := range benchWords {
+			KeywordToken(w)
+		}
+	}
+}
+
+func BenchmarkLegacyKeywordToken(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		for _, w := range benchWords {
+			legacyKeywordToken(w)
+		}
+	}
+}
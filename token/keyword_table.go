@@ -0,0 +1,56 @@
+// Code generated by gen_keywords.go; DO NOT EDIT.
+
+package token
+
+const (
+	keywordHashMul = 2590171
+	keywordMinLen  = 2
+	keywordMaxLen  = 12
+)
+
+var keywordTable = [128]struct {
+	text string
+	tok  Token
+}{
+	0:   {"unless", KeywordUnless},
+	5:   {"case", KeywordCase},
+	6:   {"else", KeywordElse},
+	8:   {"BEGIN", KeywordBEGIN},
+	15:  {"undef", KeywordUndef},
+	24:  {"not", KeywordNot},
+	29:  {"yield", KeywordYield},
+	31:  {"super", KeywordSuper},
+	34:  {"in", KeywordIn},
+	39:  {"__LINE__", KeywordLINE},
+	44:  {"and", KeywordAnd},
+	45:  {"end", KeywordEnd},
+	46:  {"true", KeywordTrue},
+	47:  {"while", KeywordWhile},
+	48:  {"__FILE__", KeywordFILE},
+	54:  {"elsif", KeywordElsif},
+	56:  {"class", KeywordClass},
+	57:  {"module", KeywordModule},
+	63:  {"self", KeywordSelf},
+	66:  {"retry", KeywordRetry},
+	75:  {"defined?", KeywordDefined},
+	76:  {"then", KeywordThen},
+	77:  {"when", KeywordWhen},
+	80:  {"return", KeywordReturn},
+	83:  {"__ENCODING__", KeywordENCODING},
+	84:  {"END", KeywordEND},
+	85:  {"next", KeywordNext},
+	86:  {"alias", KeywordAlias},
+	90:  {"def", KeywordDef},
+	93:  {"until", KeywordUntil},
+	96:  {"begin", KeywordBegin},
+	97:  {"ensure", KeywordEnsure},
+	98:  {"rescue", KeywordRescue},
+	99:  {"nil", KeywordNil},
+	105: {"false", KeywordFalse},
+	106: {"for", KeywordFor},
+	109: {"if", KeywordIf},
+	112: {"break", KeywordBreak},
+	121: {"do", KeywordDo},
+	123: {"redo", KeywordRedo},
+	126: {"or", KeywordOr},
+}
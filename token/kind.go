@@ -0,0 +1,293 @@
+package token
+
+// IsLiteral returns whether the token is a part of literal, such as numbers,
+// strings, heredocs, symbols, or the parts of them.
+func (t Token) IsLiteral() bool {
+	return BinaryInteger <= t && t <= LiteralEnd
+}
+
+// IsNumber returns whether the token is a numeric literal.
+func (t Token) IsNumber() bool {
+	return BinaryInteger <= t && t <= Imaginary
+}
+
+// IsBracket returns whether the token is a opening or closing bracket.
+func (t Token) IsBracket() bool {
+	return LParen <= t && t <= RBrace
+}
+
+// IsDelimiter returns whether the token is a delimiter, such as comma or dot.
+func (t Token) IsDelimiter() bool {
+	return Colon2 <= t && t <= Arrow
+}
+
+// IsOperator returns whether the token is an operator including assign
+// operators.
+func (t Token) IsOperator() bool {
+	return Not <= t && t <= AssignPow
+}
+
+// IsOperatorMethod returns whether the token is an operator which can be
+// defined as a method, such as + or [].
+func (t Token) IsOperatorMethod() bool {
+	return Xor <= t && t <= ElementRef
+}
+
+// IsAssignOp returns whether the token is an assign operator, such as = or +=.
+func (t Token) IsAssignOp() bool {
+	return Assign <= t && t <= AssignPow
+}
+
+// IsKeyword returns whether the token is a reserved word.
+func (t Token) IsKeyword() bool {
+	return KeywordLINE <= t && t <= KeywordYield
+}
+
+// IsIdent returns whether the token is an identifier.
+func (t Token) IsIdent() bool {
+	return IdentConst <= t && t <= IdentClassVar
+}
+
+// BaseOperator returns the operator of the compound assign operator, such as
+// Plus for AssignPlus. It returns None for other tokens including Assign.
+func (t Token) BaseOperator() Token {
+	if AssignAndOperator <= t && t <= AssignPow {
+		return baseOperators[t-AssignAndOperator]
+	}
+	return None
+}
+
+var baseOperators = [...]Token{
+	AndOperator, // &&=
+	OrOperator,  // ||=
+	Xor,         // ^=
+	Amp,         // &=
+	Or,          // |=
+	LShift,      // <<=
+	RShift,      // >>=
+	Plus,        // +=
+	Minus,       // -=
+	Mul,         // *=
+	Div,         // /=
+	Mod,         // %=
+	Pow,         // **=
+}
+
+// Text returns the spelling of the token in source. It returns an empty
+// string for the tokens which have no fixed spelling, such as literals and
+// identifiers.
+func (t Token) Text() string {
+	if 0 <= t && int(t) < len(texts) {
+		return texts[t]
+	}
+	return ""
+}
+
+var texts = [...]string{
+	LParen:   "(",
+	RParen:   ")",
+	LBracket: "[",
+	RBracket: "]",
+	LBrace:   "{",
+	RBrace:   "}",
+
+	Colon2:    "::",
+	Comma:     ",",
+	Semicolon: ";",
+	Dot:       ".",
+	Dot2:      "..",
+	Dot3:      "...",
+	Question:  "?",
+	Colon:     ":",
+	Arrow:     "=>",
+
+	Not:         "!",
+	NotEqual:    "!=",
+	NotMatch:    "!~",
+	AndOperator: "&&",
+	OrOperator:  "||",
+
+	Xor:        "^",
+	Amp:        "&",
+	Or:         "|",
+	Compare:    "<=>",
+	Eq:         "==",
+	Eql:        "===",
+	Match:      "=~",
+	Gt:         ">",
+	GtEq:       ">=",
+	Lt:         "<",
+	LtEq:       "<=",
+	LShift:     "<<",
+	RShift:     ">>",
+	Plus:       "+",
+	Minus:      "-",
+	Mul:        "*",
+	Div:        "/",
+	Mod:        "%",
+	Pow:        "**",
+	Invert:     "~",
+	UnaryPlus:  "+@",
+	UnaryMinus: "-@",
+	ElementSet: "[]=",
+	ElementRef: "[]",
+
+	Assign:            "=",
+	AssignAndOperator: "&&=",
+	AssignOrOperator:  "||=",
+	AssignXor:         "^=",
+	AssignAnd:         "&=",
+	AssignOr:          "|=",
+	AssignLShift:      "<<=",
+	AssignRShift:      ">>=",
+	AssignPlus:        "+=",
+	AssignMinus:       "-=",
+	AssignMul:         "*=",
+	AssignDiv:         "/=",
+	AssignMod:         "%=",
+	AssignPow:         "**=",
+
+	KeywordLINE:     "__LINE__",
+	KeywordENCODING: "__ENCODING__",
+	KeywordFILE:     "__FILE__",
+	KeywordBEGIN:    "BEGIN",
+	KeywordEND:      "END",
+	KeywordAlias:    "alias",
+	KeywordAnd:      "and",
+	KeywordBegin:    "begin",
+	KeywordBreak:    "break",
+	KeywordCase:     "case",
+	KeywordClass:    "class",
+	KeywordDef:      "def",
+	KeywordDefined:  "defined?",
+	KeywordDo:       "do",
+	KeywordElse:     "else",
+	KeywordElsif:    "elsif",
+	KeywordEnd:      "end",
+	KeywordEnsure:   "ensure",
+	KeywordFor:      "for",
+	KeywordFalse:    "false",
+	KeywordIf:       "if",
+	KeywordIn:       "in",
+	KeywordModule:   "module",
+	KeywordNext:     "next",
+	KeywordNil:      "nil",
+	KeywordNot:      "not",
+	KeywordOr:       "or",
+	KeywordRedo:     "redo",
+	KeywordRescue:   "rescue",
+	KeywordRetry:    "retry",
+	KeywordReturn:   "return",
+	KeywordSelf:     "self",
+	KeywordSuper:    "super",
+	KeywordThen:     "then",
+	KeywordTrue:     "true",
+	KeywordUndef:    "undef",
+	KeywordUnless:   "unless",
+	KeywordUntil:    "until",
+	KeywordWhen:     "when",
+	KeywordWhile:    "while",
+	KeywordYield:    "yield",
+}
+
+// Assoc represents the associativity of binary operator.
+type Assoc int
+
+// Associativities:
+const (
+	NonAssoc   Assoc = iota // a == b == c is a syntax error
+	LeftAssoc               // a - b - c is (a - b) - c
+	RightAssoc              // a ** b ** c is a ** (b ** c)
+)
+
+// Precedences of the operators in Ruby. The higher binds tighter. The unary
+// operators are not binary operators, but their precedences are given to
+// compare with the binary operators; -2 ** 2 is -(2 ** 2), and !a ** 2 is
+// (!a) ** 2.
+const (
+	LowestPrec     = 0 // not a binary operator
+	UnaryMinusPrec = 14
+	UnaryPrec      = 16 // !, ~, and unary +
+	HighestPrec    = 16
+)
+
+type binary struct {
+	prec  int
+	assoc Assoc
+}
+
+var binaries = [...]binary{
+	KeywordOr:  {1, LeftAssoc},
+	KeywordAnd: {1, LeftAssoc},
+
+	Assign:            {2, RightAssoc},
+	AssignAndOperator: {2, RightAssoc},
+	AssignOrOperator:  {2, RightAssoc},
+	AssignXor:         {2, RightAssoc},
+	AssignAnd:         {2, RightAssoc},
+	AssignOr:          {2, RightAssoc},
+	AssignLShift:      {2, RightAssoc},
+	AssignRShift:      {2, RightAssoc},
+	AssignPlus:        {2, RightAssoc},
+	AssignMinus:       {2, RightAssoc},
+	AssignMul:         {2, RightAssoc},
+	AssignDiv:         {2, RightAssoc},
+	AssignMod:         {2, RightAssoc},
+	AssignPow:         {2, RightAssoc},
+
+	Question: {3, RightAssoc}, // a ? b : c
+
+	Dot2: {4, NonAssoc},
+	Dot3: {4, NonAssoc},
+
+	OrOperator: {5, LeftAssoc},
+
+	AndOperator: {6, LeftAssoc},
+
+	Compare:  {7, NonAssoc},
+	Eq:       {7, NonAssoc},
+	Eql:      {7, NonAssoc},
+	NotEqual: {7, NonAssoc},
+	Match:    {7, NonAssoc},
+	NotMatch: {7, NonAssoc},
+
+	Gt:   {8, LeftAssoc},
+	GtEq: {8, LeftAssoc},
+	Lt:   {8, LeftAssoc},
+	LtEq: {8, LeftAssoc},
+
+	Or:  {9, LeftAssoc},
+	Xor: {9, LeftAssoc},
+
+	Amp: {10, LeftAssoc},
+
+	LShift: {11, LeftAssoc},
+	RShift: {11, LeftAssoc},
+
+	Plus:  {12, LeftAssoc},
+	Minus: {12, LeftAssoc},
+
+	Mul: {13, LeftAssoc},
+	Div: {13, LeftAssoc},
+	Mod: {13, LeftAssoc},
+
+	Pow: {15, RightAssoc},
+}
+
+// Precedence returns the precedence of the binary operator. It returns
+// LowestPrec if the token is not a binary operator.
+func (t Token) Precedence() int {
+	if 0 <= t && int(t) < len(binaries) {
+		return binaries[t].prec
+	}
+	return LowestPrec
+}
+
+// Associativity returns the associativity of the binary operator. It returns
+// NonAssoc if the token is not a binary operator.
+func (t Token) Associativity() Assoc {
+	if 0 <= t && int(t) < len(binaries) {
+		return binaries[t].assoc
+	}
+	return NonAssoc
+}
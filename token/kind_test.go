@@ -0,0 +1,154 @@
+package token
+
+import (
+	"go/ast"
+	"go/parser"
+	gotoken "go/token"
+	"strings"
+	"testing"
+)
+
+type constSpec struct {
+	tok     Token
+	name    string
+	section string // group comment such as "keywords"
+	comment string // trailing comment
+}
+
+// parseConsts reads the const block of token.go, so that the tables in
+// kind.go are checked against the definitions.
+func parseConsts(t *testing.T) []constSpec {
+	fset := gotoken.NewFileSet()
+	f, err := parser.ParseFile(fset, "token.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var specs []constSpec
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != gotoken.CONST {
+			continue
+		}
+		section := ""
+		for _, spec := range gen.Specs {
+			v := spec.(*ast.ValueSpec)
+			if v.Doc != nil {
+				section = strings.TrimSuffix(strings.TrimSpace(v.Doc.Text()), ":")
+			}
+			comment := ""
+			if v.Comment != nil {
+				comment = strings.TrimSpace(v.Comment.Text())
+			}
+			for _, name := range v.Names {
+				specs = append(specs, constSpec{Token(len(specs)), name.Name, section, comment})
+			}
+		}
+	}
+	if len(specs) == 0 || specs[0].name != "None" || specs[len(specs)-1].tok != IdentClassVar {
+		t.Fatalf("unexpected const block: %v", specs)
+	}
+	return specs
+}
+
+func TestKindInSync(t *testing.T) {
+	for _, c := range parseConsts(t) {
+		tok := c.tok
+		want := map[string]bool{
+			"IsLiteral":        c.section == "" && tok > NewLine,
+			"IsBracket":        c.section == "brackets",
+			"IsDelimiter":      c.section == "delimiters",
+			"IsOperator":       c.section == "operators" || c.section == "operator methods" || c.section == "assign operator",
+			"IsOperatorMethod": c.section == "operator methods",
+			"IsAssignOp":       c.section == "assign operator",
+			"IsKeyword":        c.section == "keywords",
+			"IsIdent":          c.section == "identifiers",
+		}
+		got := map[string]bool{
+			"IsLiteral":        tok.IsLiteral(),
+			"IsBracket":        tok.IsBracket(),
+			"IsDelimiter":      tok.IsDelimiter(),
+			"IsOperator":       tok.IsOperator(),
+			"IsOperatorMethod": tok.IsOperatorMethod(),
+			"IsAssignOp":       tok.IsAssignOp(),
+			"IsKeyword":        tok.IsKeyword(),
+			"IsIdent":          tok.IsIdent(),
+		}
+		for m, w := range want {
+			if got[m] != w {
+				t.Errorf("%v.%v()=%v (want=%v)", c.name, m, got[m], w)
+			}
+		}
+
+		wantText := ""
+		if c.section != "" && c.section != "identifiers" {
+			wantText = c.comment
+		}
+		if got := tok.Text(); got != wantText {
+			t.Errorf("%v.Text()=%q (want=%q)", c.name, got, wantText)
+		}
+	}
+}
+
+func TestBaseOperator(t *testing.T) {
+	for tok := Assign; tok <= AssignPow; tok++ {
+		base := tok.BaseOperator()
+		if tok == Assign {
+			if base != None {
+				t.Errorf("Assign.BaseOperator()=%v (want=None)", base)
+			}
+			continue
+		}
+		if got, want := base.Text()+"=", tok.Text(); got != want {
+			t.Errorf("%v.BaseOperator().Text()+\"=\"=%q (want=%q)", tok.Text(), got, want)
+		}
+	}
+	if got := Plus.BaseOperator(); got != None {
+		t.Errorf("Plus.BaseOperator()=%v (want=None)", got)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	// each pair of operators is ordered from the higher precedence.
+	rules := [][2]Token{
+		{Pow, Mul},
+		{Mul, Plus},
+		{Plus, LShift},
+		{LShift, Amp},
+		{Amp, Or},
+		{Or, Gt},
+		{Gt, Eq},
+		{Eq, AndOperator},
+		{AndOperator, OrOperator},
+		{OrOperator, Dot2},
+		{Dot2, Question},
+		{Question, Assign},
+		{Assign, KeywordAnd},
+	}
+	for _, r := range rules {
+		if r[0].Precedence() <= r[1].Precedence() {
+			t.Errorf("%q.Precedence()=%v must be higher than %q.Precedence()=%v", r[0].Text(), r[0].Precedence(), r[1].Text(), r[1].Precedence())
+		}
+	}
+	if p := Pow.Precedence(); p <= UnaryMinusPrec || p >= UnaryPrec {
+		t.Errorf("Pow.Precedence()=%v (want between %v and %v)", p, UnaryMinusPrec, UnaryPrec)
+	}
+	if p := Mul.Precedence(); p >= UnaryMinusPrec {
+		t.Errorf("Mul.Precedence()=%v (want lower than %v)", p, UnaryMinusPrec)
+	}
+	for tok, want := range map[Token]Assoc{
+		Plus:      LeftAssoc,
+		Pow:       RightAssoc,
+		AssignMul: RightAssoc,
+		Eq:        NonAssoc,
+		Dot2:      NonAssoc,
+	} {
+		if got := tok.Associativity(); got != want {
+			t.Errorf("%q.Associativity()=%v (want=%v)", tok.Text(), got, want)
+		}
+	}
+	for _, tok := range []Token{None, Comma, KeywordIf, IdentConst, Not, Token(-1), Token(1 << 20)} {
+		if got := tok.Precedence(); got != LowestPrec {
+			t.Errorf("%v.Precedence()=%v (want=%v)", int(tok), got, LowestPrec)
+		}
+	}
+}
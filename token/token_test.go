@@ -3,11 +3,79 @@ package token
 import "testing"
 
 func TestKeywordToken(t *testing.T) {
-	for i, l := range keywordLiterals {
-		for j, literal := range l {
-			if got, want := KeywordToken(literal), keywordTokens[i][j]; got != want {
-				t.Errorf("KeywordToken(%v)=%v (want=%v)", literal, got, want)
-			}
+	for tok := KeywordLINE; tok <= KeywordYield; tok++ {
+		literal := KeywordText(tok)
+		if got := KeywordToken([]byte(literal)); got != tok {
+			t.Errorf("KeywordToken(%v)=%v (want=%v)", literal, got, tok)
 		}
+		if got := Lookup(literal); got != tok {
+			t.Errorf("Lookup(%v)=%v (want=%v)", literal, got, tok)
+		}
+	}
+}
+
+func TestKeywordTokenNotMatched(t *testing.T) {
+	rules := []string{
+		"",
+		"i",
+		"_",
+		"If",
+		"ifx",
+		"iff",
+		"end_",
+		"defined",
+		"__LINE",
+		"__ENCODING___",
+		"puts",
+		"\x80",
+		"\xff\xff",
+		"é",
+		"\x00\x00\x00",
+	}
+	for _, literal := range rules {
+		if got := KeywordToken([]byte(literal)); got != None {
+			t.Errorf("KeywordToken(%q)=%v (want=None)", literal, got)
+		}
+		if got := Lookup(literal); got != None {
+			t.Errorf("Lookup(%q)=%v (want=None)", literal, got)
+		}
+	}
+}
+
+func TestKeywordText(t *testing.T) {
+	rules := map[Token]string{
+		KeywordDefined: "defined?",
+		KeywordLINE:    "__LINE__",
+		KeywordYield:   "yield",
+		Plus:           "",
+		IdentConst:     "",
+		Token(-1):      "",
+	}
+	for tok, want := range rules {
+		if got := KeywordText(tok); got != want {
+			t.Errorf("KeywordText(%v)=%q (want=%q)", int(tok), got, want)
+		}
+	}
+}
+
+func TestKeywordTableInSync(t *testing.T) {
+	n := 0
+	for _, c := range parseConsts(t) {
+		if c.section != "keywords" {
+			continue
+		}
+		n++
+		if got := Lookup(c.comment); got != c.tok {
+			t.Errorf("Lookup(%v)=%v (want=%v); run go generate", c.comment, got, c.name)
+		}
+	}
+	m := 0
+	for _, kw := range keywordTable {
+		if kw.text != "" {
+			m++
+		}
+	}
+	if m != n {
+		t.Errorf("len(keywordTable)=%v (want=%v); run go generate", m, n)
 	}
 }
@@ -0,0 +1,113 @@
+package vm
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/harukasan/ringo/interp"
+	"github.com/harukasan/ringo/object"
+	"github.com/harukasan/ringo/parser"
+)
+
+const fibSrc = `
+def fib(n)
+  n < 2 ? n : fib(n - 1) + fib(n - 2)
+end
+p fib(20)
+`
+
+const nbodySrc = `
+class Body
+  attr_accessor :x, :y, :z, :vx, :vy, :vz, :mass
+  def initialize(x, y, z, vx, vy, vz, mass)
+    @x, @y, @z = x, y, z
+    @vx, @vy, @vz = vx, vy, vz
+    @mass = mass
+  end
+end
+
+def advance(bodies, dt)
+  n = bodies.size
+  i = 0
+  while i < n
+    b = bodies[i]
+    j = i + 1
+    while j < n
+      b2 = bodies[j]
+      dx = b.x - b2.x
+      dy = b.y - b2.y
+      dz = b.z - b2.z
+      d2 = dx * dx + dy * dy + dz * dz
+      mag = dt / (d2 * d2 ** 0.5)
+      b.vx -= dx * b2.mass * mag
+      b.vy -= dy * b2.mass * mag
+      b.vz -= dz * b2.mass * mag
+      b2.vx += dx * b.mass * mag
+      b2.vy += dy * b.mass * mag
+      b2.vz += dz * b.mass * mag
+      j += 1
+    end
+    i += 1
+  end
+  i = 0
+  while i < n
+    b = bodies[i]
+    b.x += dt * b.vx
+    b.y += dt * b.vy
+    b.z += dt * b.vz
+    i += 1
+  end
+end
+
+bodies = [
+  Body.new(0.0, 0.0, 0.0, 0.0, 0.0, 0.0, 39.47),
+  Body.new(4.84, -1.16, -0.10, 0.60, 2.81, -0.02, 0.037),
+  Body.new(8.34, 4.12, -0.40, -1.01, 1.82, 0.008, 0.011),
+  Body.new(12.89, -15.11, -0.22, 1.08, 0.86, -0.01, 0.0017),
+  Body.new(15.37, -25.91, 0.17, 0.97, 0.59, -0.03, 0.002),
+]
+k = 0
+while k < 1000
+  advance(bodies, 0.01)
+  k += 1
+end
+p bodies[0].x
+`
+
+const stringSrc = `
+s = ""
+i = 0
+while i < 10000
+  s << "item " << i.to_s << ", "
+  i += 1
+end
+p s.size
+`
+
+func benchmark(b *testing.B, src string, useVM bool) {
+	f, err := parser.ParseFile("bench.rb", []byte(src))
+	if err != nil {
+		b.Fatal(err)
+	}
+	iseq := Compile(f, []byte(src))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rt := object.New()
+		rt.Stdout = ioutil.Discard
+		if useVM {
+			_, err = New(rt).RunISeq(iseq)
+		} else {
+			_, err = interp.New(rt).Run(f, []byte(src))
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFibInterp(b *testing.B)    { benchmark(b, fibSrc, false) }
+func BenchmarkFibVM(b *testing.B)        { benchmark(b, fibSrc, true) }
+func BenchmarkNbodyInterp(b *testing.B)  { benchmark(b, nbodySrc, false) }
+func BenchmarkNbodyVM(b *testing.B)      { benchmark(b, nbodySrc, true) }
+func BenchmarkStringInterp(b *testing.B) { benchmark(b, stringSrc, false) }
+func BenchmarkStringVM(b *testing.B)     { benchmark(b, stringSrc, true) }
@@ -0,0 +1,1694 @@
+package vm
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/literal"
+	"github.com/harukasan/ringo/object"
+	"github.com/harukasan/ringo/token"
+)
+
+// Compile compiles the file parsed from the source to the instruction
+// sequence of the top level. The expressions which are not supported yet
+// are compiled to raise NotImplementedError when they run, as the
+// tree-walking interpreter does.
+func Compile(f *ast.File, src []byte) *ISeq {
+	lines := []int{0}
+	for i, c := range src {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	c := newCompiler(TopISeq, "<main>", f.Name, lines)
+	c.iseq.Line = 1
+	c.stmts(f.Body)
+	c.emit(Leave, 0, 0, 0)
+	return c.iseq
+}
+
+// compiler compiles an instruction sequence.
+type compiler struct {
+	iseq   *ISeq
+	lines  []int // offsets of the beginning of lines
+	line   int   // line of the instruction emitted
+	sp     int   // stack depth
+	names  map[string]int
+	locals map[string]int
+	ctxs   []*context
+	parent *compiler // compiler of the scope enclosing the block
+	start  *label    // beginning of the block body, where redo jumps
+}
+
+// label is a position of the instructions, which may be referenced before
+// it is placed.
+type label struct {
+	pc    int
+	sp    int
+	spSet bool
+	refs  []int
+}
+
+// context is an enclosing loop, ensure clause or rescue clause, which
+// the jumps such as break, return or retry pass through.
+type context struct {
+	kind int
+
+	// loop
+	brk, next, redo *label
+
+	// ensure
+	ensure *ast.Stmts
+
+	// rescue
+	retry   *label
+	errinfo int // local holding $! before the rescue clause
+
+	sp int // stack depth of the beginning
+}
+
+const (
+	ctxLoop = iota
+	ctxEnsure
+	ctxRescue
+)
+
+func newCompiler(typ ISeqType, name, file string, lines []int) *compiler {
+	return &compiler{
+		iseq:   &ISeq{Type: typ, Name: name, File: file, Rest: -1, Block: -1, KeyRest: -1, KeyBits: -1},
+		lines:  lines,
+		names:  map[string]int{},
+		locals: map[string]int{},
+	}
+}
+
+// child returns the compiler of the method or class body.
+func (c *compiler) child(typ ISeqType, name string, pos int) *compiler {
+	cc := newCompiler(typ, name, c.iseq.File, c.lines)
+	cc.iseq.Line = c.lineOf(pos)
+	cc.line = cc.iseq.Line
+	return cc
+}
+
+func (c *compiler) lineOf(pos int) int {
+	return sort.SearchInts(c.lines, pos+1)
+}
+
+func (c *compiler) pc() int {
+	return len(c.iseq.Insns)
+}
+
+// emit appends the instruction, and tracks the depth of the stack.
+func (c *compiler) emit(op Opcode, a, b, cc int) {
+	in := Insn{Op: op, A: a, B: b, C: cc}
+	c.iseq.Insns = append(c.iseq.Insns, in)
+	c.iseq.Lines = append(c.iseq.Lines, c.line)
+	c.sp += c.stackEffect(in)
+	if c.sp > c.iseq.StackMax {
+		c.iseq.StackMax = c.sp
+	}
+}
+
+// stackEffect returns the difference of the stack depth by the
+// instruction.
+func (c *compiler) stackEffect(in Insn) int {
+	switch in.Op {
+	case Nop, Swap, SetN, ToString, Intern, SplatArray, Jump, Undef, DefineSMethod:
+		return 0
+	case Pop, ConcatArray, MergeHash, NewRange, SetLocal, SetInstanceVariable, SetGlobal, SetClassVariable,
+		BranchIf, BranchUnless, Raise, Throw, Leave, DefineClass,
+		OptPlus, OptMinus, OptMult, OptDiv, OptMod, OptEq, OptLt, OptLe, OptGt, OptGe, OptAref:
+		return -1
+	case DupN:
+		return in.A
+	case AdjustStack:
+		return -in.A
+	case ConcatStrings, NewArray, NewHash:
+		return 1 - in.A
+	case ExpandArray:
+		return in.A + in.B + in.C - 1
+	case GetConstant:
+		return 1 - in.B
+	case SetConstant:
+		return -2
+	case CheckMatch:
+		if in.A == matchCase || in.A == matchRescue {
+			return -1
+		}
+		return 0
+	case Defined:
+		if in.A == definedPublic {
+			return 0
+		}
+		return 1
+	case Send, InvokeSuper:
+		return -c.iseq.Calls[in.A].popped()
+	case InvokeBlock:
+		return 1 - c.iseq.Calls[in.A].popped()
+	}
+	return 1 // push
+}
+
+func (c *compiler) newLabel() *label {
+	return &label{pc: -1}
+}
+
+// place places the label at the current position.
+func (c *compiler) place(l *label) {
+	l.pc = c.pc()
+	for _, ref := range l.refs {
+		c.iseq.Insns[ref].A = l.pc
+	}
+	if l.spSet {
+		c.sp = l.sp
+	} else {
+		l.sp, l.spSet = c.sp, true
+	}
+}
+
+// jump emits the jump or the branch to the label.
+func (c *compiler) jump(op Opcode, l *label) {
+	c.emit(op, l.pc, 0, 0)
+	if l.pc < 0 {
+		l.refs = append(l.refs, c.pc()-1)
+	}
+	if !l.spSet {
+		l.sp, l.spSet = c.sp, true
+	}
+}
+
+// catch adds the catch entry for the range between the labels.
+func (c *compiler) catch(kind int, start, end, handler *label) {
+	c.iseq.Catch = append(c.iseq.Catch, CatchEntry{
+		Kind:    kind,
+		Start:   start.pc,
+		End:     end.pc,
+		Handler: handler.pc,
+		SP:      start.sp,
+	})
+}
+
+func (c *compiler) object(v object.Value) int {
+	c.iseq.Consts = append(c.iseq.Consts, v)
+	return len(c.iseq.Consts) - 1
+}
+
+func (c *compiler) name(s string) int {
+	if i, ok := c.names[s]; ok {
+		return i
+	}
+	c.iseq.Names = append(c.iseq.Names, s)
+	c.iseq.IDs = append(c.iseq.IDs, object.Intern(s))
+	c.names[s] = len(c.iseq.Names) - 1
+	return len(c.iseq.Names) - 1
+}
+
+// lookupLocal returns the index and the level of the local variable, which
+// is looked up also in the scopes enclosing the blocks. The variable not
+// found is declared in the current scope.
+func (c *compiler) lookupLocal(s string) (idx, level int) {
+	for cc := c; cc != nil; cc = cc.parent {
+		if i, ok := cc.locals[s]; ok {
+			return i, level
+		}
+		level++
+	}
+	return c.local(s), 0
+}
+
+// local returns the index of the local variable, declaring it if not yet.
+func (c *compiler) local(s string) int {
+	if i, ok := c.locals[s]; ok {
+		return i
+	}
+	c.locals[s] = c.newLocal(s)
+	return c.locals[s]
+}
+
+// newLocal adds the local variable. The hidden variables have the names
+// which cannot be the identifiers.
+func (c *compiler) newLocal(s string) int {
+	c.iseq.Locals = append(c.iseq.Locals, s)
+	return len(c.iseq.Locals) - 1
+}
+
+func (c *compiler) putObject(v object.Value) {
+	c.emit(PutObject, c.object(v), 0, 0)
+}
+
+func (c *compiler) putString(s string) {
+	c.emit(PutString, c.object(&object.RString{B: []byte(s)}), 0, 0)
+}
+
+func (c *compiler) send(name string, argc, flags int) {
+	c.sendBlock(name, argc, flags, nil)
+}
+
+// sendBlock compiles the method call with the block literal, which is nil
+// if not given.
+func (c *compiler) sendBlock(name string, argc, flags int, block *ISeq) {
+	c.iseq.Calls = append(c.iseq.Calls, &CallInfo{Name: object.Intern(name), Argc: argc, Flags: flags, Block: block})
+	ci := len(c.iseq.Calls) - 1
+	if block != nil {
+		c.emit(Send, ci, 0, 0)
+		return
+	}
+	if op, ok := optOperators[name]; ok && argc == 1 && flags == 0 {
+		c.emit(op, ci, 0, 0)
+		return
+	}
+	if name == "[]" && argc == 1 && flags == 0 {
+		c.emit(OptAref, ci, 0, 0)
+		return
+	}
+	c.emit(Send, ci, 0, 0)
+}
+
+// unsupported emits the instruction raising NotImplementedError, which
+// pushes nothing but is counted as a value.
+func (c *compiler) unsupported(feature string) {
+	c.emit(Unsupported, c.name(feature), 0, 0)
+}
+
+// stmts compiles the expressions, leaving the value of the last one.
+func (c *compiler) stmts(stmts *ast.Stmts) {
+	if stmts == nil || len(stmts.List) == 0 {
+		c.emit(PutNil, 0, 0, 0)
+		return
+	}
+	for i, x := range stmts.List {
+		if i > 0 {
+			c.emit(Pop, 0, 0, 0)
+		}
+		c.expr(x)
+	}
+}
+
+// expr compiles the expression, leaving the value on the stack.
+func (c *compiler) expr(x ast.Expr) {
+	c.line = c.lineOf(x.Pos())
+	switch x := x.(type) {
+	case *ast.NumberLit:
+		c.number(x)
+	case *ast.StrLit:
+		c.putString(x.Value)
+	case *ast.InterpStr:
+		c.interpolate(x.Parts)
+	case *ast.Heredoc:
+		c.interpolate(x.Parts)
+	case *ast.SymbolLit:
+		c.putObject(object.SymbolOf(x.Name))
+	case *ast.InterpSymbol:
+		c.interpolate(x.Parts)
+		c.emit(Intern, 0, 0, 0)
+	case *ast.ArrayLit:
+		c.list(x.Elems)
+	case *ast.PseudoVar:
+		c.pseudoVar(x)
+
+	case *ast.LocalVar:
+		idx, level := c.lookupLocal(x.Name)
+		c.emit(GetLocal, idx, level, 0)
+	case *ast.InstanceVar:
+		c.emit(GetInstanceVariable, c.name(x.Name), 0, 0)
+	case *ast.GlobalVar:
+		c.emit(GetGlobal, c.name(x.Name), 0, 0)
+	case *ast.Const:
+		switch {
+		case x.Top:
+			c.emit(PutSpecialObject, specialObject, 0, 0)
+			c.emit(GetConstant, c.name(x.Name), 1, 0)
+		case x.Scope != nil:
+			c.expr(x.Scope)
+			c.emit(GetConstant, c.name(x.Name), 1, 0)
+		default:
+			c.emit(GetConstant, c.name(x.Name), 0, 0)
+		}
+
+	case *ast.Assign:
+		c.assign(x)
+	case *ast.MultiAssign:
+		for _, lhs := range x.Lhs {
+			c.declare(lhs)
+		}
+		c.expr(x.Rhs)
+		c.emit(Dup, 0, 0, 0)
+		c.multiAssign(x.Lhs)
+	case *ast.Binary:
+		c.binary(x)
+	case *ast.Unary:
+		switch x.Op {
+		case token.Not, token.KeywordNot:
+			c.cond(x.X)
+			c.send("!", 0, 0)
+			return
+		}
+		c.expr(x.X)
+		switch x.Op {
+		case token.Minus:
+			c.send("-@", 0, 0)
+		case token.Plus:
+			c.send("+@", 0, 0)
+		default:
+			c.send(x.Op.Text(), 0, 0)
+		}
+	case *ast.Defined:
+		c.defined(x.X)
+
+	case *ast.Call:
+		c.call(x)
+	case *ast.Index:
+		c.expr(x.Recv)
+		argc, flags := c.args(x.Args)
+		c.send("[]", argc, flags)
+
+	case *ast.Def:
+		c.def(x)
+	case *ast.ClassDef:
+		c.constBase(x.Path)
+		if x.Super != nil {
+			c.expr(x.Super)
+		} else {
+			c.emit(PutNil, 0, 0, 0)
+		}
+		body := c.child(ClassISeq, "<class:"+x.Path.Name+">", x.Pos())
+		body.classBody(x.Body)
+		c.emit(DefineClass, c.name(x.Path.Name), c.childISeq(body), typeClass)
+	case *ast.ModuleDef:
+		c.constBase(x.Path)
+		c.emit(PutNil, 0, 0, 0)
+		body := c.child(ClassISeq, "<module:"+x.Path.Name+">", x.Pos())
+		body.classBody(x.Body)
+		c.emit(DefineClass, c.name(x.Path.Name), c.childISeq(body), typeModule)
+	case *ast.Alias:
+		c.alias(x)
+	case *ast.Undef:
+		for _, name := range x.Names {
+			s, ok := name.(*ast.SymbolLit)
+			if !ok {
+				c.unsupported("undef of dynamic symbol")
+				c.emit(Pop, 0, 0, 0)
+				continue
+			}
+			c.emit(Undef, c.name(s.Name), 0, 0)
+		}
+		c.emit(PutNil, 0, 0, 0)
+
+	case *ast.If:
+		c.ifExpr(x)
+	case *ast.Ternary:
+		lelse, lend := c.newLabel(), c.newLabel()
+		c.cond(x.Cond)
+		c.jump(BranchUnless, lelse)
+		c.expr(x.Then)
+		c.jump(Jump, lend)
+		c.place(lelse)
+		c.expr(x.Else)
+		c.place(lend)
+	case *ast.While:
+		c.while(x)
+	case *ast.Case:
+		c.caseExpr(x)
+	case *ast.Return:
+		c.jumpValue(x.Args)
+		c.unwind(len(c.ctxs))
+		if c.iseq.Type == BlockISeq {
+			c.emit(Throw, throwReturn, 0, 0)
+		} else {
+			c.emit(Leave, 0, 0, 0)
+		}
+		c.emit(PutNil, 0, 0, 0) // unreachable value
+	case *ast.Break:
+		c.jumpValue(x.Args)
+		c.loopJump(throwBreak)
+	case *ast.Next:
+		c.jumpValue(x.Args)
+		c.loopJump(throwNext)
+	case *ast.Redo:
+		c.emit(PutNil, 0, 0, 0)
+		c.loopJump(throwRedo)
+	case *ast.Retry:
+		c.retry()
+	case *ast.Begin:
+		c.bodyStmt(x.Body)
+	case *ast.RescueMod:
+		lstart, lend, lhandler, lout := c.newLabel(), c.newLabel(), c.newLabel(), c.newLabel()
+		c.place(lstart)
+		c.expr(x.X)
+		c.place(lend)
+		c.jump(Jump, lout)
+		c.sp = lstart.sp + 1
+		c.place(lhandler)
+		c.emit(Pop, 0, 0, 0)
+		c.expr(x.Rescue)
+		c.place(lout)
+		c.catch(catchStandard, lstart, lend, lhandler)
+	case *ast.Paren:
+		c.stmts(x.Body)
+
+	case *ast.HashLit:
+		c.hash(x)
+	case *ast.RangeLit:
+		for _, e := range []ast.Expr{x.Low, x.High} {
+			if e == nil {
+				c.emit(PutNil, 0, 0, 0)
+			} else {
+				c.expr(e)
+			}
+		}
+		excl := 0
+		if x.Exclusive {
+			excl = 1
+		}
+		c.emit(NewRange, excl, 0, 0)
+	case *ast.RegexpLit:
+		c.unsupported("Regexp")
+	case *ast.XStr:
+		c.unsupported("command output")
+	case *ast.Yield:
+		argc, flags := c.args(x.Args)
+		c.iseq.Calls = append(c.iseq.Calls, &CallInfo{Argc: argc, Flags: flags})
+		c.emit(InvokeBlock, len(c.iseq.Calls)-1, 0, 0)
+	case *ast.Super:
+		c.super(x)
+	case *ast.ClassVar:
+		c.emit(GetClassVariable, c.name(x.Name), 0, 0)
+	case *ast.SingletonClassDef:
+		cc := c.child(ClassISeq, "singleton class", x.Pos())
+		cc.classBody(x.Body)
+		c.expr(x.Target)
+		c.emit(PutNil, 0, 0, 0)
+		c.emit(DefineClass, c.name("singletonclass"), c.childISeq(cc), typeSingleton)
+	case *ast.For:
+		c.unsupported("for loop")
+	case *ast.Splat:
+		c.unsupported("splat here")
+	case *ast.BlockPass:
+		c.unsupported("block argument here")
+	default:
+		c.unsupported("expression")
+	}
+}
+
+// number compiles the numeric literal.
+func (c *compiler) number(x *ast.NumberLit) {
+	n, err := literal.Parse(x.Kind, []byte(x.Lit))
+	if err != nil {
+		c.unsupported(err.Error())
+		return
+	}
+	switch n := n.(type) {
+	case int64:
+		c.putObject(object.Fixnum(n))
+	case float64:
+		c.putObject(object.Float(n))
+	case *big.Int:
+		c.putObject(object.NewInteger(n))
+	case *big.Rat:
+		c.unsupported("Rational")
+	case literal.Complex:
+		c.unsupported("Complex")
+	}
+}
+
+// interpolate compiles the parts of the string, which are *ast.StrLit or
+// *ast.Insert.
+func (c *compiler) interpolate(parts []ast.Expr) {
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *ast.StrLit:
+			c.putObject(&object.RString{B: []byte(p.Value)})
+		case *ast.Insert:
+			c.stmts(p.Body)
+			c.emit(ToString, 0, 0, 0)
+		default:
+			c.expr(p)
+			c.emit(ToString, 0, 0, 0)
+		}
+	}
+	c.emit(ConcatStrings, len(parts), 0, 0)
+}
+
+func (c *compiler) pseudoVar(x *ast.PseudoVar) {
+	switch x.Kind {
+	case token.KeywordSelf:
+		c.emit(PutSelf, 0, 0, 0)
+	case token.KeywordTrue:
+		c.putObject(object.True)
+	case token.KeywordFalse:
+		c.putObject(object.False)
+	case token.KeywordFILE:
+		c.putString(c.iseq.File)
+	case token.KeywordLINE:
+		c.putObject(object.Fixnum(c.line))
+	case token.KeywordENCODING:
+		c.emit(PutSpecialObject, specialEncoding, 0, 0)
+	default:
+		c.emit(PutNil, 0, 0, 0)
+	}
+}
+
+// list compiles the elements to an array, expanding the splats.
+func (c *compiler) list(elems []ast.Expr) {
+	n := 0         // elements not pushed to an array yet
+	array := false // whether an array is on the stack
+	flush := func() {
+		if n == 0 && array {
+			return
+		}
+		c.emit(NewArray, n, 0, 0)
+		if array {
+			c.emit(ConcatArray, 0, 0, 0)
+		}
+		n, array = 0, true
+	}
+	for _, x := range elems {
+		switch x := x.(type) {
+		case *ast.Splat:
+			if n > 0 {
+				flush()
+			}
+			if x.Double {
+				c.emit(NewHash, 0, 0, 0)
+				c.expr(x.Value)
+				c.emit(MergeHash, 0, 0, 0)
+				n++
+				continue
+			}
+			c.expr(x.Value)
+			c.emit(SplatArray, 0, 0, 0)
+			if array {
+				c.emit(ConcatArray, 0, 0, 0)
+			}
+			array = true
+		case *ast.BlockPass:
+			c.unsupported("block argument")
+			n++
+		default:
+			c.expr(x)
+			n++
+		}
+	}
+	flush()
+}
+
+// hash compiles the hash literal. The pairs are pushed to a new hash, and
+// the double splats are merged to it.
+func (c *compiler) hash(x *ast.HashLit) {
+	n := 0 // pairs not pushed to the hash yet
+	hash := false
+	flush := func() {
+		c.emit(NewHash, n*2, 0, 0)
+		if hash {
+			c.emit(MergeHash, 0, 0, 0)
+		}
+		n, hash = 0, true
+	}
+	for _, p := range x.Pairs {
+		if p.Key == nil {
+			if !hash || n > 0 {
+				flush()
+			}
+			c.expr(p.Value)
+			c.emit(MergeHash, 0, 0, 0)
+			continue
+		}
+		c.expr(p.Key)
+		c.expr(p.Value)
+		n++
+	}
+	if !hash || n > 0 {
+		flush()
+	}
+}
+
+// args compiles the arguments, which are given as an array if a splat is
+// contained, and then the block passed by &. It returns the number of the
+// arguments and the call flags.
+func (c *compiler) args(args []ast.Expr) (argc, flags int) {
+	var blk *ast.BlockPass
+	if n := len(args); n > 0 {
+		if b, ok := args[n-1].(*ast.BlockPass); ok {
+			blk, args = b, args[:n-1]
+		}
+	}
+	argc = len(args)
+	for _, x := range args {
+		if _, ok := x.(*ast.Splat); ok {
+			argc, flags = 1, callSplat
+			break
+		}
+	}
+	if flags == 0 {
+		for _, x := range args {
+			c.expr(x)
+		}
+	} else {
+		c.list(args)
+	}
+	if blk != nil {
+		c.expr(blk.Value)
+		flags |= callBlockArg
+	}
+	return argc, flags
+}
+
+// jumpValue compiles the value of return, break and next, which is an
+// array for multiple arguments.
+func (c *compiler) jumpValue(args []ast.Expr) {
+	switch len(args) {
+	case 0:
+		c.emit(PutNil, 0, 0, 0)
+		return
+	case 1:
+		if _, ok := args[0].(*ast.Splat); !ok {
+			c.expr(args[0])
+			return
+		}
+	}
+	c.list(args)
+}
+
+// constBase compiles the class where the constant is defined.
+func (c *compiler) constBase(x *ast.Const) {
+	switch {
+	case x.Top:
+		c.emit(PutSpecialObject, specialObject, 0, 0)
+	case x.Scope != nil:
+		c.expr(x.Scope)
+	default:
+		c.emit(PutSpecialObject, specialCBase, 0, 0)
+	}
+}
+
+// binary compiles the binary operation, which is a method call except for
+// the logical operators.
+func (c *compiler) binary(x *ast.Binary) {
+	switch x.Op {
+	case token.AndOperator, token.KeywordAnd, token.OrOperator, token.KeywordOr:
+		c.logical(x, c.expr)
+		return
+	}
+	c.expr(x.X)
+	c.expr(x.Y)
+	if x.Op == token.NotMatch {
+		c.send("=~", 1, 0)
+		c.send("!", 0, 0)
+		return
+	}
+	c.send(x.Op.Text(), 1, 0)
+}
+
+// logical compiles the logical operator, where the operands are compiled
+// by f.
+func (c *compiler) logical(x *ast.Binary, f func(ast.Expr)) {
+	lend := c.newLabel()
+	f(x.X)
+	c.emit(Dup, 0, 0, 0)
+	if x.Op == token.AndOperator || x.Op == token.KeywordAnd {
+		c.jump(BranchUnless, lend)
+	} else {
+		c.jump(BranchIf, lend)
+	}
+	c.emit(Pop, 0, 0, 0)
+	f(x.Y)
+	c.place(lend)
+}
+
+// cond compiles the condition of if, unless, while, until, the ternary
+// operator or the not operator, where the range literals are the
+// flip-flops.
+func (c *compiler) cond(x ast.Expr) {
+	switch x := x.(type) {
+	case *ast.RangeLit:
+		if x.Low != nil && x.High != nil {
+			c.flipFlop(x)
+			return
+		}
+	case *ast.Binary:
+		switch x.Op {
+		case token.AndOperator, token.KeywordAnd, token.OrOperator, token.KeywordOr:
+			c.logical(x, c.cond)
+			return
+		}
+	case *ast.Paren:
+		if len(x.Body.List) == 1 {
+			c.cond(x.Body.List[0])
+			return
+		}
+	}
+	c.expr(x)
+}
+
+// flipFlop compiles the flip-flop, whose state is kept in a hidden local
+// variable of the method, so that it lasts over the calls of the block.
+// The end condition of a..b is checked also when it turns on, but not of
+// a...b.
+func (c *compiler) flipFlop(x *ast.RangeLit) {
+	m, level := c, 0
+	for m.iseq.Type == BlockISeq && m.parent != nil {
+		m, level = m.parent, level+1
+	}
+	flip := m.newLocal("flip-flop")
+	lon, ltrue, lfalse, lend := c.newLabel(), c.newLabel(), c.newLabel(), c.newLabel()
+	c.emit(GetLocal, flip, level, 0)
+	c.jump(BranchIf, lon)
+	c.cond(x.Low)
+	c.jump(BranchUnless, lfalse)
+	c.putObject(object.True)
+	c.emit(SetLocal, flip, level, 0)
+	if x.Exclusive {
+		c.jump(Jump, ltrue)
+	}
+	c.place(lon)
+	c.cond(x.High)
+	c.jump(BranchUnless, ltrue)
+	c.putObject(object.False)
+	c.emit(SetLocal, flip, level, 0)
+	c.place(ltrue)
+	c.putObject(object.True)
+	c.jump(Jump, lend)
+	c.place(lfalse)
+	c.putObject(object.False)
+	c.place(lend)
+}
+
+// call compiles the method call.
+func (c *compiler) call(x *ast.Call) {
+	if x.Recv == nil && len(x.Args) == 0 && x.Block == nil && c.iseq.Type == ClassISeq {
+		if v, ok := visibilities[x.Name]; ok {
+			c.emit(SetVisibility, int(v), 0, 0)
+			return
+		}
+	}
+	flags := 0
+	if x.Recv == nil {
+		c.emit(PutSelf, 0, 0, 0)
+		flags = callFCall
+		if len(x.Args) == 0 && !x.Parens {
+			flags |= callVCall
+		}
+	} else {
+		c.expr(x.Recv)
+		if isSelf(x.Recv) {
+			flags = callFCall // private methods can be called by self.
+		}
+	}
+	argc, f := c.args(x.Args)
+	var block *ISeq
+	if x.Block != nil {
+		flags &^= callVCall
+		block = c.block(x.Block)
+	}
+	c.line = c.lineOf(x.Pos())
+	c.sendBlock(x.Name, argc, flags|f, block)
+}
+
+// block compiles the block literal to the child instruction sequence,
+// whose local variables include the ones of the enclosing scopes.
+func (c *compiler) block(x *ast.Block) *ISeq {
+	name := c.iseq.Name
+	if c.iseq.Type != BlockISeq {
+		name = "block in " + name
+	}
+	b := c.child(BlockISeq, name, x.Pos())
+	b.parent = c
+	b.iseq.Parent = c.iseq
+	b.iseq.Params = procParams(x.Params)
+	b.params(x.Params)
+	b.start = b.newLabel()
+	b.place(b.start)
+	b.bodyStmt(x.Body)
+	b.emit(Leave, 0, 0, 0)
+	c.childISeq(b)
+	return b.iseq
+}
+
+// procParams returns the parameters of the block for Proc#parameters.
+func procParams(params *ast.Params) []object.ProcParam {
+	if params == nil {
+		return nil
+	}
+	list := make([]object.ProcParam, len(params.List))
+	for i, p := range params.List {
+		kind := "req"
+		switch p.Kind {
+		case ast.OptionalParam:
+			kind = "opt"
+		case ast.RestParam:
+			kind = "rest"
+		case ast.KeywordParam:
+			kind = "key"
+			if p.Default == nil {
+				kind = "keyreq"
+			}
+		case ast.KeywordRestParam:
+			kind = "keyrest"
+		case ast.BlockParam:
+			kind = "block"
+		}
+		list[i] = object.ProcParam{Kind: kind, Name: p.Name}
+	}
+	return list
+}
+
+// super compiles the call of the method of the superclass. The arguments
+// omitted are the current values of the parameters of the method, which
+// may enclose the block.
+func (c *compiler) super(x *ast.Super) {
+	var block *ISeq
+	if x.Block != nil {
+		block = c.block(x.Block)
+	}
+	c.emit(PutSelf, 0, 0, 0)
+	if x.Args != nil {
+		argc, flags := c.args(x.Args)
+		c.line = c.lineOf(x.Pos())
+		c.invokeSuper(argc, flags, block)
+		return
+	}
+	m, level := c, 0
+	for m.iseq.Type == BlockISeq && m.parent != nil {
+		m, level = m.parent, level+1
+	}
+	iseq := m.iseq
+	if iseq.Type != MethodISeq {
+		c.invokeSuper(0, 0, block)
+		return
+	}
+	pre := iseq.Lead
+	if len(iseq.OptTable) > 0 {
+		pre += len(iseq.OptTable) - 1
+	}
+	for i := 0; i < pre; i++ {
+		c.emit(GetLocal, i, level, 0)
+	}
+	kw := 0
+	if iseq.keywords() {
+		kw = 1
+	}
+	if iseq.Rest < 0 {
+		for i := 0; i < iseq.Post; i++ {
+			c.emit(GetLocal, pre+i, level, 0)
+		}
+		c.superKeywords(iseq, level)
+		c.invokeSuper(pre+iseq.Post+kw, 0, block)
+		return
+	}
+	c.emit(NewArray, pre, 0, 0)
+	c.emit(GetLocal, iseq.Rest, level, 0)
+	c.emit(ConcatArray, 0, 0, 0)
+	for i := 0; i < iseq.Post; i++ {
+		c.emit(GetLocal, iseq.Rest+1+i, level, 0)
+	}
+	c.superKeywords(iseq, level)
+	c.emit(NewArray, iseq.Post+kw, 0, 0)
+	c.emit(ConcatArray, 0, 0, 0)
+	c.invokeSuper(1, callSplat, block)
+}
+
+// superKeywords pushes the hash of the keyword parameters of the method
+// for the super without arguments, if the method takes keywords.
+func (c *compiler) superKeywords(iseq *ISeq, level int) {
+	if !iseq.keywords() {
+		return
+	}
+	for _, k := range iseq.Keywords {
+		c.putObject(object.Symbol(k.ID))
+		c.emit(GetLocal, k.Index, level, 0)
+	}
+	c.emit(NewHash, 2*len(iseq.Keywords), 0, 0)
+	if iseq.KeyRest >= 0 {
+		c.emit(GetLocal, iseq.KeyRest, level, 0)
+		c.emit(MergeHash, 0, 0, 0)
+	}
+}
+
+func (c *compiler) invokeSuper(argc, flags int, block *ISeq) {
+	ci := &CallInfo{Name: object.Intern("super"), Argc: argc, Flags: flags | callFCall, Block: block}
+	c.iseq.Calls = append(c.iseq.Calls, ci)
+	c.emit(InvokeSuper, len(c.iseq.Calls)-1, 0, 0)
+}
+
+// visibilities are the methods changing the default visibility of the
+// following method definitions.
+var visibilities = map[string]object.Visibility{
+	"public":    object.Public,
+	"private":   object.Private,
+	"protected": object.Protected,
+}
+
+func isSelf(x ast.Expr) bool {
+	p, ok := x.(*ast.PseudoVar)
+	return ok && p.Kind == token.KeywordSelf
+}
+
+// assign compiles the single assignment, including the compound
+// assignments such as a += 1 or a ||= 1.
+func (c *compiler) assign(x *ast.Assign) {
+	c.declare(x.Lhs)
+	if x.Op == token.Assign {
+		c.expr(x.Rhs)
+		c.assignTo(x.Lhs)
+		return
+	}
+
+	// The receiver and the arguments of the left hand side are evaluated
+	// only once, and the result is set to the placeholder pushed first.
+	switch lhs := x.Lhs.(type) {
+	case *ast.Index:
+		c.emit(PutNil, 0, 0, 0)
+		c.expr(lhs.Recv)
+		argc, flags := c.args(lhs.Args)
+		c.emit(DupN, argc+1, 0, 0)
+		c.send("[]", argc, flags)
+		c.opAssign(x, argc+1, func() {
+			if flags&callSplat != 0 {
+				c.emit(NewArray, 1, 0, 0)
+				c.emit(ConcatArray, 0, 0, 0)
+				c.send("[]=", 1, flags)
+				return
+			}
+			c.send("[]=", argc+1, flags)
+		})
+	case *ast.Call:
+		flags := 0
+		if isSelf(lhs.Recv) {
+			flags = callFCall
+		}
+		c.emit(PutNil, 0, 0, 0)
+		c.expr(lhs.Recv)
+		c.emit(Dup, 0, 0, 0)
+		c.send(lhs.Name, 0, flags)
+		c.opAssign(x, 1, func() { c.send(lhs.Name+"=", 1, flags) })
+	case *ast.Const:
+		allowNil := 0
+		if x.Op == token.AssignOrOperator {
+			allowNil = 1
+		}
+		c.emit(PutNil, 0, 0, 0)
+		c.constBase(lhs)
+		c.emit(Dup, 0, 0, 0)
+		c.emit(GetConstant, c.name(lhs.Name), 1, allowNil)
+		c.opAssign(x, 1, func() {
+			c.emit(Swap, 0, 0, 0)
+			c.emit(SetConstant, c.name(lhs.Name), 0, 0)
+			c.emit(PutNil, 0, 0, 0)
+		})
+	default:
+		lend := c.newLabel()
+		if cv, ok := lhs.(*ast.ClassVar); ok && x.Op == token.AssignOrOperator {
+			// The undefined class variable is assigned without errors.
+			lset := c.newLabel()
+			c.emit(Defined, definedCvar, c.name(cv.Name), 0)
+			c.jump(BranchUnless, lset)
+			c.expr(lhs)
+			c.emit(Dup, 0, 0, 0)
+			c.jump(BranchIf, lend)
+			c.emit(Pop, 0, 0, 0)
+			c.place(lset)
+			c.expr(x.Rhs)
+			c.assignTo(lhs)
+			c.place(lend)
+			return
+		}
+		c.expr(lhs)
+		switch x.Op {
+		case token.AssignAndOperator, token.AssignOrOperator:
+			c.emit(Dup, 0, 0, 0)
+			if x.Op == token.AssignAndOperator {
+				c.jump(BranchUnless, lend)
+			} else {
+				c.jump(BranchIf, lend)
+			}
+			c.emit(Pop, 0, 0, 0)
+			c.expr(x.Rhs)
+		default:
+			c.expr(x.Rhs)
+			c.send(x.Op.BaseOperator().Text(), 1, 0)
+		}
+		c.assignTo(lhs)
+		c.place(lend)
+	}
+}
+
+// opAssign compiles the rest of the compound assignment, where the old
+// value is on the top of n values, the receiver and the arguments, above
+// the placeholder. set sends the setter to the n values and the new value.
+func (c *compiler) opAssign(x *ast.Assign, n int, set func()) {
+	lkeep, lend := c.newLabel(), c.newLabel()
+	switch x.Op {
+	case token.AssignAndOperator, token.AssignOrOperator:
+		c.emit(Dup, 0, 0, 0)
+		if x.Op == token.AssignAndOperator {
+			c.jump(BranchUnless, lkeep)
+		} else {
+			c.jump(BranchIf, lkeep)
+		}
+		c.emit(Pop, 0, 0, 0)
+		c.expr(x.Rhs)
+	default:
+		c.expr(x.Rhs)
+		c.send(x.Op.BaseOperator().Text(), 1, 0)
+	}
+	c.emit(SetN, n+1, 0, 0)
+	set()
+	c.emit(Pop, 0, 0, 0)
+	c.jump(Jump, lend)
+	c.place(lkeep)
+	c.emit(SetN, n+1, 0, 0)
+	c.emit(AdjustStack, n+1, 0, 0)
+	c.place(lend)
+}
+
+// declare declares the local variables assigned before the right hand
+// side, which may be the block referring to them.
+func (c *compiler) declare(lhs ast.Expr) {
+	switch lhs := lhs.(type) {
+	case *ast.LocalVar:
+		c.lookupLocal(lhs.Name)
+	case *ast.Splat:
+		c.declare(lhs.Value)
+	case *ast.MultiAssign:
+		for _, x := range lhs.Lhs {
+			c.declare(x)
+		}
+	}
+}
+
+// assignTo assigns the value on the top of the stack to the left hand
+// side, leaving the value.
+func (c *compiler) assignTo(lhs ast.Expr) {
+	switch lhs := lhs.(type) {
+	case *ast.LocalVar:
+		idx, level := c.lookupLocal(lhs.Name)
+		c.emit(Dup, 0, 0, 0)
+		c.emit(SetLocal, idx, level, 0)
+	case *ast.InstanceVar:
+		c.emit(Dup, 0, 0, 0)
+		c.emit(SetInstanceVariable, c.name(lhs.Name), 0, 0)
+	case *ast.GlobalVar:
+		c.emit(Dup, 0, 0, 0)
+		c.emit(SetGlobal, c.name(lhs.Name), 0, 0)
+	case *ast.Const:
+		c.emit(Dup, 0, 0, 0)
+		c.constBase(lhs)
+		c.emit(SetConstant, c.name(lhs.Name), 0, 0)
+	case *ast.Index:
+		c.expr(lhs.Recv)
+		argc, flags := c.args(lhs.Args)
+		c.emit(TopN, argc+1, 0, 0)
+		if flags&callSplat != 0 {
+			c.emit(NewArray, 1, 0, 0)
+			c.emit(ConcatArray, 0, 0, 0)
+			c.send("[]=", 1, flags)
+		} else {
+			c.send("[]=", argc+1, flags)
+		}
+		c.emit(Pop, 0, 0, 0)
+	case *ast.Call:
+		flags := 0
+		if isSelf(lhs.Recv) {
+			flags = callFCall
+		}
+		c.expr(lhs.Recv)
+		c.emit(TopN, 1, 0, 0)
+		c.send(lhs.Name+"=", 1, flags)
+		c.emit(Pop, 0, 0, 0)
+	case *ast.MultiAssign:
+		c.emit(Dup, 0, 0, 0)
+		c.multiAssign(lhs.Lhs)
+	case *ast.ClassVar:
+		c.emit(Dup, 0, 0, 0)
+		c.emit(SetClassVariable, c.name(lhs.Name), 0, 0)
+	default:
+		c.unsupported("assignment")
+		c.emit(Pop, 0, 0, 0)
+	}
+}
+
+// multiAssign assigns the elements of the value on the top of the stack
+// to the left hand sides, popping the value.
+func (c *compiler) multiAssign(lhs []ast.Expr) {
+	splat := -1
+	for i, x := range lhs {
+		if _, ok := x.(*ast.Splat); ok {
+			splat = i
+		}
+	}
+	if splat < 0 {
+		c.emit(ExpandArray, len(lhs), 0, 0)
+	} else {
+		c.emit(ExpandArray, splat, 1, len(lhs)-splat-1)
+	}
+	for _, x := range lhs {
+		if s, ok := x.(*ast.Splat); ok {
+			if s.Value == nil {
+				c.emit(Pop, 0, 0, 0)
+				continue
+			}
+			x = s.Value
+		}
+		c.assignTo(x)
+		c.emit(Pop, 0, 0, 0)
+	}
+}
+
+// def compiles the method definition, or the singleton method definition
+// with the receiver.
+func (c *compiler) def(x *ast.Def) {
+	m := c.child(MethodISeq, x.Name, x.Pos())
+	m.params(x.Params)
+	m.bodyStmt(x.Body)
+	m.emit(Leave, 0, 0, 0)
+	if x.Singleton != nil {
+		c.expr(x.Singleton)
+		c.emit(DefineSMethod, c.name(x.Name), c.childISeq(m), 0)
+		return
+	}
+	c.emit(DefineMethod, c.name(x.Name), c.childISeq(m), 0)
+}
+
+// params declares the parameters as the first local variables, and
+// compiles the default values of the optional parameters. The nested
+// parameters such as (a, b) are expanded after them.
+func (c *compiler) params(params *ast.Params) {
+	if params == nil {
+		return
+	}
+	iseq := c.iseq
+	var opts, kwopts, nested []*ast.Param
+	var nestedLocals []int
+	param := func(p *ast.Param) {
+		if p.Nested == nil {
+			c.local(p.Name)
+			return
+		}
+		nested = append(nested, p)
+		nestedLocals = append(nestedLocals, c.newLocal("()"))
+	}
+	for _, p := range params.List {
+		switch p.Kind {
+		case ast.RequiredParam:
+			iseq.Lead++
+			param(p)
+		case ast.OptionalParam:
+			opts = append(opts, p)
+			c.local(p.Name)
+		case ast.RestParam:
+			if p.Name == "" {
+				iseq.Rest = c.newLocal("*")
+			} else {
+				iseq.Rest = c.local(p.Name)
+			}
+		case ast.PostParam:
+			iseq.Post++
+			param(p)
+		case ast.KeywordParam:
+			iseq.Keywords = append(iseq.Keywords, Keyword{ID: object.Intern(p.Name), Index: c.local(p.Name), Required: p.Default == nil})
+			if p.Default != nil {
+				kwopts = append(kwopts, p)
+			}
+		case ast.KeywordRestParam:
+			if p.Name == "" {
+				iseq.KeyRest = c.newLocal("**")
+			} else {
+				iseq.KeyRest = c.local(p.Name)
+			}
+		case ast.BlockParam:
+			iseq.Block = c.local(p.Name)
+		}
+	}
+	if len(opts) > 0 {
+		iseq.OptTable = []int{c.pc()}
+		for _, p := range opts {
+			c.line = c.lineOf(p.Pos())
+			c.expr(p.Default)
+			c.emit(SetLocal, c.local(p.Name), 0, 0)
+			iseq.OptTable = append(iseq.OptTable, c.pc())
+		}
+	}
+
+	// The default values of the optional keywords are evaluated unless the
+	// bits of the keywords given tell they are given.
+	if len(kwopts) > 0 {
+		iseq.KeyBits = c.newLocal("?")
+		for i, k := range iseq.Keywords {
+			if k.Required {
+				continue
+			}
+			p := kwopts[0]
+			kwopts = kwopts[1:]
+			lskip := c.newLabel()
+			c.line = c.lineOf(p.Pos())
+			c.emit(CheckKeyword, iseq.KeyBits, i, 0)
+			c.jump(BranchIf, lskip)
+			c.expr(p.Default)
+			c.emit(SetLocal, k.Index, 0, 0)
+			c.place(lskip)
+		}
+	}
+	for i, p := range nested {
+		c.emit(GetLocal, nestedLocals[i], 0, 0)
+		c.destructure(p.Nested)
+	}
+}
+
+// destructure assigns the elements of the value on the top of the stack
+// to the nested parameters, popping the value.
+func (c *compiler) destructure(params *ast.Params) {
+	pre, splat, post := 0, 0, 0
+	for _, p := range params.List {
+		switch p.Kind {
+		case ast.RestParam:
+			splat = 1
+		case ast.PostParam:
+			post++
+		default:
+			pre++
+		}
+	}
+	c.emit(ExpandArray, pre, splat, post)
+	for _, p := range params.List {
+		switch {
+		case p.Nested != nil:
+			c.destructure(p.Nested)
+		case p.Name == "":
+			c.emit(Pop, 0, 0, 0)
+		default:
+			c.emit(SetLocal, c.local(p.Name), 0, 0)
+		}
+	}
+}
+
+// classBody compiles the body of the class or the module.
+func (c *compiler) classBody(body *ast.BodyStmt) {
+	c.bodyStmt(body)
+	c.emit(Leave, 0, 0, 0)
+}
+
+func (c *compiler) childISeq(cc *compiler) int {
+	c.iseq.Children = append(c.iseq.Children, cc.iseq)
+	return len(c.iseq.Children) - 1
+}
+
+// alias compiles the alias of methods.
+func (c *compiler) alias(x *ast.Alias) {
+	if _, ok := x.New.(*ast.GlobalVar); ok {
+		c.unsupported("alias of global variable")
+		return
+	}
+	newName, ok1 := x.New.(*ast.SymbolLit)
+	oldName, ok2 := x.Old.(*ast.SymbolLit)
+	if !ok1 || !ok2 {
+		c.unsupported("alias of dynamic symbol")
+		return
+	}
+	c.emit(Alias, c.name(newName.Name), c.name(oldName.Name), 0)
+}
+
+// ifExpr compiles the if or unless expression.
+func (c *compiler) ifExpr(x *ast.If) {
+	lelse, lend := c.newLabel(), c.newLabel()
+	c.cond(x.Cond)
+	if x.Unless {
+		c.jump(BranchIf, lelse)
+	} else {
+		c.jump(BranchUnless, lelse)
+	}
+	c.stmts(x.Then)
+	c.jump(Jump, lend)
+	c.place(lelse)
+	switch e := x.Else.(type) {
+	case *ast.Stmts:
+		c.stmts(e)
+	case *ast.If:
+		c.ifExpr(e)
+	default:
+		c.emit(PutNil, 0, 0, 0)
+	}
+	c.place(lend)
+}
+
+// while compiles the while or until loop. The jumps in the loop are
+// compiled to the jumps to the labels of the loop.
+func (c *compiler) while(x *ast.While) {
+	ctx := &context{kind: ctxLoop, brk: c.newLabel(), next: c.newLabel(), redo: c.newLabel(), sp: c.sp}
+	if !x.DoWhile {
+		c.jump(Jump, ctx.next)
+	}
+	c.place(ctx.redo)
+	c.ctxs = append(c.ctxs, ctx)
+	c.stmts(x.Body)
+	c.ctxs = c.ctxs[:len(c.ctxs)-1]
+	c.emit(Pop, 0, 0, 0)
+	c.place(ctx.next)
+	c.cond(x.Cond)
+	if x.Until {
+		c.jump(BranchUnless, ctx.redo)
+	} else {
+		c.jump(BranchIf, ctx.redo)
+	}
+	c.emit(PutNil, 0, 0, 0)
+	c.place(ctx.brk)
+}
+
+// loopJump compiles break, next or redo with the value on the stack. Out
+// of the loops in the block, next leaves the block, redo restarts the
+// block, and break is thrown to leave the method call given the block.
+func (c *compiler) loopJump(kind int) {
+	i := len(c.ctxs) - 1
+	for ; i >= 0 && c.ctxs[i].kind != ctxLoop; i-- {
+	}
+	if i < 0 && c.iseq.Type == BlockISeq {
+		sp := c.sp
+		c.unwind(len(c.ctxs))
+		switch kind {
+		case throwNext:
+			c.emit(Leave, 0, 0, 0)
+		case throwRedo:
+			c.adjust(c.sp - c.start.sp)
+			c.jump(Jump, c.start)
+		default:
+			c.emit(Throw, kind, 0, 0)
+		}
+		c.sp = sp // value of the unreachable code
+		return
+	}
+	if i < 0 {
+		c.emit(Throw, kind, 0, 0)
+		c.emit(PutNil, 0, 0, 0) // unreachable value
+		return
+	}
+	ctx := c.ctxs[i]
+	sp := c.sp
+	c.unwind(len(c.ctxs) - i - 1)
+	switch kind {
+	case throwBreak:
+		if n := c.sp - 1 - ctx.sp; n > 0 {
+			c.emit(SetN, n, 0, 0)
+			c.emit(AdjustStack, n, 0, 0)
+		}
+		c.jump(Jump, ctx.brk)
+	case throwNext:
+		c.adjust(c.sp - ctx.sp)
+		c.jump(Jump, ctx.next)
+	case throwRedo:
+		c.adjust(c.sp - ctx.sp)
+		c.jump(Jump, ctx.redo)
+	}
+	c.sp = sp // value of the unreachable code
+}
+
+// adjust pops the n values.
+func (c *compiler) adjust(n int) {
+	if n > 0 {
+		c.emit(AdjustStack, n, 0, 0)
+	}
+}
+
+// unwind runs the ensure clauses and restores $! of the rescue clauses
+// for the n inner contexts, which are left by the jump.
+func (c *compiler) unwind(n int) {
+	ctxs := c.ctxs
+	defer func() { c.ctxs = ctxs }()
+	for i := len(ctxs) - 1; i >= len(ctxs)-n; i-- {
+		switch ctx := ctxs[i]; ctx.kind {
+		case ctxEnsure:
+			c.ctxs = ctxs[:i]
+			c.stmts(ctx.ensure)
+			c.emit(Pop, 0, 0, 0)
+		case ctxRescue:
+			c.restoreErrinfo(ctx)
+		}
+	}
+}
+
+// caseExpr compiles the case expression, which compares the subject by
+// the === method of the conditions.
+func (c *compiler) caseExpr(x *ast.Case) {
+	lend := c.newLabel()
+	subject := x.Subject != nil
+	if subject {
+		c.expr(x.Subject)
+	}
+	var bodies []*label
+	for _, w := range x.Whens {
+		lbody := c.newLabel()
+		bodies = append(bodies, lbody)
+		for _, cond := range w.Conds {
+			c.line = c.lineOf(cond.Pos())
+			if s, ok := cond.(*ast.Splat); ok {
+				c.expr(s.Value)
+				c.emit(SplatArray, 0, 0, 0)
+				if subject {
+					c.emit(TopN, 1, 0, 0)
+					c.emit(CheckMatch, matchCase, 0, 0)
+				} else {
+					c.emit(CheckMatch, matchTruthy, 0, 0)
+				}
+			} else {
+				c.expr(cond)
+				if subject {
+					c.emit(TopN, 1, 0, 0)
+					c.send("===", 1, 0)
+				}
+			}
+			c.jump(BranchIf, lbody)
+		}
+	}
+	if subject {
+		c.emit(Pop, 0, 0, 0)
+	}
+	c.stmts(x.Else)
+	c.jump(Jump, lend)
+	for i, w := range x.Whens {
+		c.place(bodies[i])
+		if subject {
+			c.emit(Pop, 0, 0, 0)
+		}
+		c.stmts(w.Body)
+		c.jump(Jump, lend)
+	}
+	c.place(lend)
+}
+
+// bodyStmt compiles the body with the rescue, else and ensure clauses.
+func (c *compiler) bodyStmt(b *ast.BodyStmt) {
+	if b == nil {
+		c.emit(PutNil, 0, 0, 0)
+		return
+	}
+	if b.Ensure == nil {
+		c.rescueBody(b)
+		return
+	}
+
+	// The ensure clause runs after the body, and by the handler of the
+	// exceptions which raises them again.
+	lstart, lend, lhandler, lout := c.newLabel(), c.newLabel(), c.newLabel(), c.newLabel()
+	c.place(lstart)
+	c.ctxs = append(c.ctxs, &context{kind: ctxEnsure, ensure: b.Ensure, sp: c.sp})
+	c.rescueBody(b)
+	c.ctxs = c.ctxs[:len(c.ctxs)-1]
+	c.place(lend)
+	c.stmts(b.Ensure)
+	c.emit(Pop, 0, 0, 0)
+	c.jump(Jump, lout)
+	c.sp = lstart.sp + 1
+	c.place(lhandler)
+	c.stmts(b.Ensure)
+	c.emit(Pop, 0, 0, 0)
+	c.emit(Raise, 0, 0, 0)
+	c.sp = lstart.sp + 1
+	c.place(lout)
+	c.catch(catchEnsure, lstart, lend, lhandler)
+}
+
+// rescueBody compiles the body with the rescue and else clauses.
+func (c *compiler) rescueBody(b *ast.BodyStmt) {
+	if len(b.Rescues) == 0 {
+		c.stmts(b.Body)
+		if b.Else != nil {
+			c.emit(Pop, 0, 0, 0)
+			c.stmts(b.Else)
+		}
+		return
+	}
+	lstart, lend, lhandler, lout := c.newLabel(), c.newLabel(), c.newLabel(), c.newLabel()
+	c.place(lstart)
+	c.stmts(b.Body)
+	c.place(lend)
+	if b.Else != nil {
+		c.emit(Pop, 0, 0, 0)
+		c.stmts(b.Else)
+	}
+	c.jump(Jump, lout)
+
+	// The handler saves $! to the hidden local variable, and sets the
+	// exception while the rescue clause runs.
+	c.sp = lstart.sp + 1
+	c.place(lhandler)
+	ctx := &context{kind: ctxRescue, retry: lstart, errinfo: c.newLocal("$!"), sp: lstart.sp}
+	errinfo := c.name("$!")
+	c.emit(GetGlobal, errinfo, 0, 0)
+	c.emit(SetLocal, ctx.errinfo, 0, 0)
+	c.emit(Dup, 0, 0, 0)
+	c.emit(SetGlobal, errinfo, 0, 0)
+	var bodies []*label
+	for _, r := range b.Rescues {
+		c.line = c.lineOf(r.Pos())
+		lbody := c.newLabel()
+		bodies = append(bodies, lbody)
+		c.emit(Dup, 0, 0, 0)
+		if len(r.Classes) == 0 {
+			c.emit(CheckMatch, matchStandard, 0, 0)
+		} else {
+			c.list(r.Classes)
+			c.emit(Swap, 0, 0, 0)
+			c.emit(CheckMatch, matchRescue, 0, 0)
+		}
+		c.jump(BranchIf, lbody)
+	}
+	c.emit(GetLocal, ctx.errinfo, 0, 0)
+	c.emit(SetGlobal, errinfo, 0, 0)
+	c.emit(Raise, 0, 0, 0)
+	for i, r := range b.Rescues {
+		c.place(bodies[i])
+		if r.Var != nil {
+			c.assignTo(r.Var)
+		}
+		c.emit(Pop, 0, 0, 0)
+		c.ctxs = append(c.ctxs, ctx)
+		c.stmts(r.Body)
+		c.ctxs = c.ctxs[:len(c.ctxs)-1]
+		c.restoreErrinfo(ctx)
+		c.jump(Jump, lout)
+	}
+	c.place(lout)
+	c.catch(catchRescue, lstart, lend, lhandler)
+}
+
+// restoreErrinfo restores $! saved by the rescue clause.
+func (c *compiler) restoreErrinfo(ctx *context) {
+	c.emit(GetLocal, ctx.errinfo, 0, 0)
+	c.emit(SetGlobal, c.name("$!"), 0, 0)
+}
+
+// retry compiles retry, which jumps to the beginning of the body of the
+// rescue clause.
+func (c *compiler) retry() {
+	i := len(c.ctxs) - 1
+	for ; i >= 0 && c.ctxs[i].kind != ctxRescue; i-- {
+	}
+	if i < 0 {
+		c.emit(PutNil, 0, 0, 0)
+		c.emit(Throw, throwRetry, 0, 0)
+		c.emit(PutNil, 0, 0, 0) // unreachable value
+		return
+	}
+	ctx := c.ctxs[i]
+	sp := c.sp
+	c.unwind(len(c.ctxs) - i)
+	c.adjust(c.sp - ctx.sp)
+	c.jump(Jump, ctx.retry)
+	c.sp = sp + 1 // value of the unreachable code
+}
+
+// defined compiles defined?, which pushes the description of the
+// expression or nil.
+func (c *compiler) defined(x ast.Expr) {
+	switch x := x.(type) {
+	case *ast.LocalVar:
+		c.putString("local-variable")
+	case *ast.InstanceVar:
+		c.emit(Defined, definedIvar, c.name(x.Name), 0)
+	case *ast.GlobalVar:
+		c.emit(Defined, definedGlobal, c.name(x.Name), 0)
+	case *ast.ClassVar:
+		c.emit(Defined, definedCvar, c.name(x.Name), 0)
+	case *ast.Super:
+		c.emit(Defined, definedSuper, c.name("super"), 0)
+	case *ast.Yield:
+		c.emit(Defined, definedYield, c.name("yield"), 0)
+	case *ast.Const:
+		if !x.Top && x.Scope == nil {
+			c.emit(Defined, definedConst, c.name(x.Name), 0)
+			return
+		}
+		c.rescueNil(func() {
+			c.expr(x)
+			c.emit(Pop, 0, 0, 0)
+			c.putString("constant")
+		})
+	case *ast.Call:
+		if x.Recv == nil {
+			c.emit(Defined, definedMethod, c.name(x.Name), 0)
+			return
+		}
+		lnil, lout := c.newLabel(), c.newLabel()
+		c.defined(x.Recv)
+		c.jump(BranchUnless, lnil)
+		c.rescueNil(func() {
+			c.expr(x.Recv)
+			c.emit(Defined, definedPublic, c.name(x.Name), 0)
+		})
+		c.jump(Jump, lout)
+		c.place(lnil)
+		c.emit(PutNil, 0, 0, 0)
+		c.place(lout)
+	case *ast.PseudoVar:
+		switch x.Kind {
+		case token.KeywordSelf:
+			c.putString("self")
+		case token.KeywordNil:
+			c.putString("nil")
+		case token.KeywordTrue:
+			c.putString("true")
+		case token.KeywordFalse:
+			c.putString("false")
+		default:
+			c.putString("expression")
+		}
+	case *ast.Assign, *ast.MultiAssign:
+		c.putString("assignment")
+	case *ast.Paren:
+		if len(x.Body.List) == 1 {
+			c.defined(x.Body.List[0])
+			return
+		}
+		c.putString("expression")
+	case *ast.Binary:
+		switch x.Op {
+		case token.AndOperator, token.OrOperator, token.KeywordAnd, token.KeywordOr:
+			c.putString("expression")
+		default:
+			c.putString("method")
+		}
+	case *ast.Unary:
+		if x.Op == token.KeywordNot {
+			c.putString("expression")
+		} else {
+			c.putString("method")
+		}
+	default:
+		c.putString("expression")
+	}
+}
+
+// rescueNil compiles the code pushing a value, which pushes nil instead
+// if any exception is raised.
+func (c *compiler) rescueNil(code func()) {
+	lstart, lend, lhandler, lout := c.newLabel(), c.newLabel(), c.newLabel(), c.newLabel()
+	c.place(lstart)
+	code()
+	c.place(lend)
+	c.jump(Jump, lout)
+	c.sp = lstart.sp + 1
+	c.place(lhandler)
+	c.emit(Pop, 0, 0, 0)
+	c.emit(PutNil, 0, 0, 0)
+	c.place(lout)
+	c.catch(catchRescue, lstart, lend, lhandler)
+}
@@ -0,0 +1,194 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/harukasan/ringo/object"
+)
+
+// Disasm returns the instructions of the sequence and its children in the
+// format like RubyVM::InstructionSequence#disasm.
+func (iseq *ISeq) Disasm() string {
+	var buf bytes.Buffer
+	iseq.disasm(&buf)
+	return buf.String()
+}
+
+func (iseq *ISeq) disasm(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "== disasm: #<ISeq:%s@%s:%d>\n", iseq.Name, iseq.File, iseq.Line)
+	if len(iseq.Catch) > 0 {
+		buf.WriteString("== catch table\n")
+		for _, e := range iseq.Catch {
+			fmt.Fprintf(buf, "| catch type: %-8s st: %04d ed: %04d sp: %04d cont: %04d\n",
+				catchNames[e.Kind], e.Start, e.End, e.SP, e.Handler)
+		}
+		buf.WriteString("|" + strings.Repeat("-", 72) + "\n")
+	}
+	if len(iseq.Locals) > 0 {
+		fmt.Fprintf(buf, "local table (size: %d", len(iseq.Locals))
+		if iseq.Type == MethodISeq || iseq.Type == BlockISeq {
+			opts := 0
+			if len(iseq.OptTable) > 0 {
+				opts = len(iseq.OptTable) - 1
+			}
+			fmt.Fprintf(buf, ", argc: %d [opts: %d, rest: %d, post: %d]", iseq.Lead, opts, iseq.Rest, iseq.Post)
+		}
+		buf.WriteString(")\n")
+		for i, name := range iseq.Locals {
+			if i > 0 {
+				buf.WriteString(" ")
+			}
+			fmt.Fprintf(buf, "[%2d] %s@%d", len(iseq.Locals)-i, name, i)
+		}
+		buf.WriteString("\n")
+	}
+	line := 0
+	for pc, in := range iseq.Insns {
+		s := fmt.Sprintf("%04d %-20s %s", pc, in.Op, iseq.operands(in))
+		if iseq.Lines[pc] != line {
+			line = iseq.Lines[pc]
+			s = fmt.Sprintf("%-66s(%4d)", s, line)
+		}
+		buf.WriteString(strings.TrimRight(s, " ") + "\n")
+	}
+	for _, child := range iseq.Children {
+		buf.WriteString("\n")
+		child.disasm(buf)
+	}
+}
+
+var catchNames = [...]string{catchRescue: "rescue", catchStandard: "rescue", catchEnsure: "ensure"}
+
+var specialNames = [...]string{specialObject: "Object", specialCBase: "cbase", specialEncoding: "encoding"}
+
+// operands returns the operands of the instruction.
+func (iseq *ISeq) operands(in Insn) string {
+	switch in.Op {
+	case DupN, TopN, SetN, AdjustStack, ConcatStrings, NewArray, NewHash, NewRange:
+		return strconv.Itoa(in.A)
+	case ExpandArray:
+		return fmt.Sprintf("%d, %d, %d", in.A, in.B, in.C)
+	case PutObject, PutString:
+		return inspectConst(iseq.Consts[in.A])
+	case PutSpecialObject:
+		return specialNames[in.A]
+	case GetLocal, SetLocal:
+		env := iseq
+		for i := 0; i < in.B; i++ {
+			env = env.Parent
+		}
+		s := fmt.Sprintf("%s@%d", env.Locals[in.A], in.A)
+		if in.B > 0 {
+			s += fmt.Sprintf(", %d", in.B)
+		}
+		return s
+	case GetInstanceVariable, SetInstanceVariable, GetGlobal, SetGlobal, SetConstant, Undef,
+		GetClassVariable, SetClassVariable:
+		return object.InspectSymbol(iseq.Names[in.A])
+	case GetConstant:
+		s := object.InspectSymbol(iseq.Names[in.A])
+		if in.B == 0 {
+			s += ", lexical"
+		}
+		if in.C == 1 {
+			s += ", allow_nil"
+		}
+		return s
+	case DefineMethod, DefineSMethod:
+		return fmt.Sprintf("%s, <ISeq:%s>", object.InspectSymbol(iseq.Names[in.A]), iseq.Children[in.B].Name)
+	case DefineClass:
+		s := fmt.Sprintf("%s, <ISeq:%s>", object.InspectSymbol(iseq.Names[in.A]), iseq.Children[in.B].Name)
+		switch in.C {
+		case typeModule:
+			s += ", module"
+		case typeSingleton:
+			s += ", singleton"
+		}
+		return s
+	case Alias:
+		return object.InspectSymbol(iseq.Names[in.A]) + ", " + object.InspectSymbol(iseq.Names[in.B])
+	case SetVisibility:
+		return object.Visibility(in.A).String()
+	case Send, InvokeSuper:
+		ci := iseq.Calls[in.A]
+		if ci.Block != nil {
+			return ci.String() + ", " + ci.Block.Name
+		}
+		return ci.String()
+	case InvokeBlock, OptPlus, OptMinus, OptMult, OptDiv, OptMod, OptEq, OptLt, OptLe, OptGt, OptGe, OptAref:
+		return iseq.Calls[in.A].String()
+	case Jump, BranchIf, BranchUnless:
+		return fmt.Sprintf("%04d", in.A)
+	case CheckMatch:
+		return matchNames[in.A]
+	case CheckKeyword:
+		return fmt.Sprintf("%d, %d", in.A, in.B)
+	case Defined:
+		return definedNames[in.A] + ", " + object.InspectSymbol(iseq.Names[in.B])
+	case Throw:
+		return throwNames[in.A]
+	case Unsupported:
+		return strconv.Quote(iseq.Names[in.A])
+	}
+	return ""
+}
+
+var matchNames = [...]string{matchCase: "case", matchRescue: "rescue", matchStandard: "StandardError", matchTruthy: "truthy"}
+
+var definedNames = [...]string{
+	definedIvar:   "ivar",
+	definedGlobal: "gvar",
+	definedConst:  "const",
+	definedMethod: "func",
+	definedPublic: "method",
+	definedCvar:   "cvar",
+	definedSuper:  "super",
+	definedYield:  "yield",
+}
+
+func (ci *CallInfo) String() string {
+	var flags []string
+	if ci.Flags&callFCall != 0 {
+		flags = append(flags, "FCALL")
+	}
+	if ci.Flags&callVCall != 0 {
+		flags = append(flags, "VCALL")
+	}
+	if ci.Flags&callSplat != 0 {
+		flags = append(flags, "ARGS_SPLAT")
+	}
+	if ci.Flags&callBlockArg != 0 {
+		flags = append(flags, "ARGS_BLOCKARG")
+	}
+	s := fmt.Sprintf("<calldata!argc:%d", ci.Argc)
+	if ci.Name != 0 {
+		s = fmt.Sprintf("<calldata!mid:%s, argc:%d", ci.Name, ci.Argc)
+	}
+	if len(flags) > 0 {
+		s += ", " + strings.Join(flags, "|")
+	}
+	return s + ">"
+}
+
+// inspectConst returns the literal of the constant.
+func inspectConst(v object.Value) string {
+	switch v := v.(type) {
+	case object.Fixnum, *object.Bignum:
+		return object.FormatInt(v, 10)
+	case object.Float:
+		return object.FormatFloat(float64(v))
+	case object.Symbol:
+		return object.InspectSymbol(v.Name())
+	case *object.RString:
+		return object.QuoteString(v.B)
+	case object.Bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+	return "nil"
+}
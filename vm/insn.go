@@ -0,0 +1,352 @@
+package vm
+
+import "github.com/harukasan/ringo/object"
+
+// Opcode is an instruction of the VM.
+type Opcode uint8
+
+// Opcodes. The operands are A, B and C of Insn, which are the indices of
+// the tables in ISeq, the program counters, or the numbers.
+const (
+	Nop Opcode = iota
+
+	// stack manipulation
+	Pop         // pop the value
+	Dup         // push the top value
+	DupN        // A: push the top A values
+	Swap        // swap the top two values
+	TopN        // A: push the value under the top A values
+	SetN        // A: set the top value to the value under the top A values
+	AdjustStack // A: pop A values
+
+	// values
+	PutNil           // push nil
+	PutSelf          // push self
+	PutObject        // A: push Consts[A]
+	PutString        // A: push a copy of the string Consts[A]
+	PutSpecialObject // A: push Object (1), the current class (2) or the source encoding (3)
+	ConcatStrings    // A: pop A strings and push the concatenated string
+	ToString         // convert the top value by to_s
+	Intern           // convert the top string to a symbol
+	NewArray         // A: pop A values and push an array of them
+	SplatArray       // convert the top value to a new array for splats
+	ConcatArray      // pop two arrays and push the concatenated array
+	ExpandArray      // A: pre, B: splat (1 or 0), C: post; expand the top value
+	NewHash          // A: pop A keys and values and push a hash of them
+	MergeHash        // pop a value and merge it to the hash under it by to_hash
+	NewRange         // A: 1 if exclusive; pop the begin and the end and push a range
+
+	// variables
+	GetLocal            // A: index, B: level; push the local variable of the A-th outer scope
+	SetLocal            // A: index, B: level; pop to the local variable of the A-th outer scope
+	GetInstanceVariable // A: push the instance variable Names[A]
+	SetInstanceVariable // A: pop to the instance variable Names[A]
+	GetGlobal           // A: push the global variable Names[A]
+	SetGlobal           // A: pop to the global variable Names[A]
+	GetConstant         // A: name, B: scoped (1) or lexical (0), C: nil if missing (1)
+	SetConstant         // A: name; pop the class and the value
+	GetClassVariable    // A: push the class variable Names[A]
+	SetClassVariable    // A: pop to the class variable Names[A]
+
+	// definitions
+	DefineMethod  // A: name, B: Children[B]; push the symbol of the method
+	DefineSMethod // A: name, B: Children[B]; pop the receiver and push the symbol of the singleton method
+	DefineClass   // A: name, B: Children[B], C: type; pop the namespace or the object, and the superclass
+	Alias         // A: new name, B: old name; push nil
+	Undef         // A: name
+	SetVisibility // A: visibility of the following definitions; push nil
+
+	// calls
+	Send        // A: Calls[A]
+	InvokeSuper // A: Calls[A]; call the method of the superclass for self
+	InvokeBlock // A: Calls[A]; call the block given to the method
+	OptPlus
+	OptMinus
+	OptMult
+	OptDiv
+	OptMod
+	OptEq
+	OptLt
+	OptLe
+	OptGt
+	OptGe
+	OptAref
+
+	// control flow
+	Jump         // A: jump to A
+	BranchIf     // A: pop and jump to A if truthy
+	BranchUnless // A: pop and jump to A if falsy
+	CheckMatch   // A: kind of match
+	CheckKeyword // A: local of the keyword bits, B: keyword; push whether the keyword is given
+	Defined      // A: kind, B: name; push the description or nil
+	Raise        // pop and raise the exception
+	Throw        // A: kind; jump out of the block, or raise LocalJumpError outside loops
+	Unsupported  // A: raise NotImplementedError for the feature Names[A]
+	Leave        // return the top value
+
+	numOpcodes
+)
+
+var opcodeNames = [...]string{
+	Nop:                 "nop",
+	Pop:                 "pop",
+	Dup:                 "dup",
+	DupN:                "dupn",
+	Swap:                "swap",
+	TopN:                "topn",
+	SetN:                "setn",
+	AdjustStack:         "adjuststack",
+	PutNil:              "putnil",
+	PutSelf:             "putself",
+	PutObject:           "putobject",
+	PutString:           "putstring",
+	PutSpecialObject:    "putspecialobject",
+	ConcatStrings:       "concatstrings",
+	ToString:            "tostring",
+	Intern:              "intern",
+	NewArray:            "newarray",
+	SplatArray:          "splatarray",
+	ConcatArray:         "concatarray",
+	ExpandArray:         "expandarray",
+	NewHash:             "newhash",
+	MergeHash:           "mergehash",
+	NewRange:            "newrange",
+	GetLocal:            "getlocal",
+	SetLocal:            "setlocal",
+	GetInstanceVariable: "getinstancevariable",
+	SetInstanceVariable: "setinstancevariable",
+	GetGlobal:           "getglobal",
+	SetGlobal:           "setglobal",
+	GetConstant:         "getconstant",
+	SetConstant:         "setconstant",
+	GetClassVariable:    "getclassvariable",
+	SetClassVariable:    "setclassvariable",
+	DefineMethod:        "definemethod",
+	DefineSMethod:       "definesmethod",
+	DefineClass:         "defineclass",
+	Alias:               "alias",
+	Undef:               "undef",
+	SetVisibility:       "setvisibility",
+	Send:                "send",
+	InvokeSuper:         "invokesuper",
+	InvokeBlock:         "invokeblock",
+	OptPlus:             "opt_plus",
+	OptMinus:            "opt_minus",
+	OptMult:             "opt_mult",
+	OptDiv:              "opt_div",
+	OptMod:              "opt_mod",
+	OptEq:               "opt_eq",
+	OptLt:               "opt_lt",
+	OptLe:               "opt_le",
+	OptGt:               "opt_gt",
+	OptGe:               "opt_ge",
+	OptAref:             "opt_aref",
+	Jump:                "jump",
+	BranchIf:            "branchif",
+	BranchUnless:        "branchunless",
+	CheckMatch:          "checkmatch",
+	CheckKeyword:        "checkkeyword",
+	Defined:             "defined",
+	Raise:               "raise",
+	Throw:               "throw",
+	Unsupported:         "unsupported",
+	Leave:               "leave",
+}
+
+func (op Opcode) String() string {
+	if op < numOpcodes {
+		return opcodeNames[op]
+	}
+	return "unknown"
+}
+
+// optimized operators sent by the Opt instructions
+var optOperators = map[string]Opcode{
+	"+":  OptPlus,
+	"-":  OptMinus,
+	"*":  OptMult,
+	"/":  OptDiv,
+	"%":  OptMod,
+	"==": OptEq,
+	"<":  OptLt,
+	"<=": OptLe,
+	">":  OptGt,
+	">=": OptGe,
+}
+
+// Special objects of PutSpecialObject
+const (
+	specialObject   = 1 // Object for ::A
+	specialCBase    = 2 // class of the lexical scope
+	specialEncoding = 3 // __ENCODING__
+)
+
+// Types of DefineClass
+const (
+	typeClass     = 0
+	typeModule    = 1
+	typeSingleton = 2 // class << obj
+)
+
+// Kinds of CheckMatch
+const (
+	matchCase     = 1 // pop the subject and an array, and push whether any element === subject
+	matchRescue   = 2 // pop the exception and an array of classes, and push whether any class === exception
+	matchStandard = 3 // pop the exception and push whether it is a StandardError
+	matchTruthy   = 4 // pop an array and push whether any element is truthy
+)
+
+// Kinds of Defined
+const (
+	definedIvar   = 1 // instance variable Names[B]
+	definedGlobal = 2 // global variable Names[B]
+	definedConst  = 3 // constant Names[B] in the lexical scope
+	definedMethod = 4 // method Names[B] of self, including private methods
+	definedPublic = 5 // public method Names[B] of the popped receiver
+	definedCvar   = 6 // class variable Names[B]
+	definedSuper  = 7 // method of the superclass
+	definedYield  = 8 // block given to the method
+)
+
+// Kinds of Throw
+const (
+	throwBreak  = 1
+	throwNext   = 2
+	throwRedo   = 3
+	throwRetry  = 4
+	throwReturn = 5 // return from the method enclosing the block
+)
+
+var throwNames = [...]string{throwBreak: "break", throwNext: "next", throwRedo: "redo", throwRetry: "retry", throwReturn: "return"}
+
+// Insn is an instruction with the operands.
+type Insn struct {
+	Op      Opcode
+	A, B, C int
+}
+
+// Call flags
+const (
+	callFCall    = 1 << iota // receiver is omitted or self, which can call private methods
+	callVCall                // may be a local variable, such as foo
+	callSplat                // the arguments are given as an array
+	callBlockArg             // the block passed by & is pushed after the arguments
+)
+
+// CallInfo is the method call of Send. It caches the method found for the
+// class of the last receiver.
+type CallInfo struct {
+	Name  object.ID // 0 for yield
+	Argc  int
+	Flags int
+	Block *ISeq // block literal, or nil
+
+	class  *object.RClass
+	serial uint64
+	method *object.Method
+}
+
+// popped returns the number of the values popped for the arguments.
+func (ci *CallInfo) popped() int {
+	n := ci.Argc
+	if ci.Flags&callSplat != 0 {
+		n = 1
+	}
+	if ci.Flags&callBlockArg != 0 {
+		n++
+	}
+	return n
+}
+
+// ISeqType is the type of instruction sequences.
+type ISeqType int
+
+// Types of instruction sequences:
+const (
+	TopISeq ISeqType = iota
+	MethodISeq
+	ClassISeq
+	BlockISeq
+)
+
+// Catch kinds
+const (
+	catchRescue   = iota // any exception, matched by the handler
+	catchStandard        // StandardError only, for rescue modifiers
+	catchEnsure          // any exception, re-raised by the handler
+)
+
+// CatchEntry is the handler of the exceptions raised in the range of
+// instructions. The stack is restored to the depth of SP, and the
+// exception is pushed before jumping to the handler.
+type CatchEntry struct {
+	Kind       int
+	Start, End int // range of program counters
+	Handler    int
+	SP         int
+}
+
+// ISeq is an instruction sequence compiled from the top level, a method
+// body, a class body, or a block.
+type ISeq struct {
+	Type     ISeqType
+	Name     string
+	File     string
+	Line     int
+	Insns    []Insn
+	Lines    []int // line numbers of the instructions
+	Consts   []object.Value
+	Names    []string
+	IDs      []object.ID // interned Names
+	Calls    []*CallInfo
+	Children []*ISeq
+	Catch    []CatchEntry
+	Locals   []string // names of the local variables; the parameters come first
+	StackMax int
+	Parent   *ISeq // enclosing the block
+
+	// parameters of methods and blocks
+	Lead        int   // required parameters before the optional ones
+	OptTable    []int // start positions for the number of the optional arguments given
+	Rest        int   // index of the rest parameter, or -1
+	Post        int   // required parameters after the rest parameter
+	Block       int   // index of the block parameter, or -1
+	Keywords    []Keyword
+	KeyRest     int // index of the keyword rest parameter, or -1
+	KeyBits     int // index of the hidden local of the bits of the keywords given, or -1
+	Params      []object.ProcParam
+	Unsupported string
+}
+
+// Keyword is a keyword parameter of methods and blocks.
+type Keyword struct {
+	ID       object.ID
+	Index    int // index of the local variable
+	Required bool
+}
+
+// keywords reports whether the keyword parameters are accepted.
+func (iseq *ISeq) keywords() bool {
+	return len(iseq.Keywords) > 0 || iseq.KeyRest >= 0
+}
+
+// arity returns the arity of the method. The required keywords are counted
+// as one parameter, and the optional ones make the arity negative only if
+// no keyword is required.
+func (iseq *ISeq) arity() int {
+	n := iseq.Lead + iseq.Post
+	opt := len(iseq.OptTable) > 1 || iseq.Rest >= 0
+	keyreq := false
+	for _, k := range iseq.Keywords {
+		keyreq = keyreq || k.Required
+	}
+	if keyreq {
+		n++
+	} else if iseq.keywords() {
+		opt = true
+	}
+	if opt {
+		return -n - 1
+	}
+	return n
+}
@@ -0,0 +1,1176 @@
+/*
+Package vm implements the compiler from the syntax tree to the instruction
+sequences, and the virtual machine running them.
+
+The instructions are modelled loosely on YARV. Each method call creates a
+frame holding the local variables and the operand stack of its own. The
+blocks are compiled to the child instruction sequences, whose frames are
+linked to the frame where they are defined to access its local variables.
+The loops and the jumps in them are compiled to the branch instructions, and
+the Ruby exceptions are Go panics recovered by the frame, which looks up
+the catch table of the instruction sequence to find the handler. The
+return and break out of the blocks are Go panics as well.
+
+The VM runs the methods written in Ruby for the runtime of the object
+package, as the tree-walking interpreter of the interp package does. The
+basic operators of Integer and Float are run without method calls unless
+they are redefined.
+*/
+package vm
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/harukasan/ringo/ast"
+	"github.com/harukasan/ringo/object"
+	"github.com/harukasan/ringo/parser"
+)
+
+// maxDepth is the limit of nested method calls.
+const maxDepth = 10000
+
+// VM is a virtual machine running instruction sequences.
+type VM struct {
+	rt    *object.Runtime
+	depth int
+	cur   *frame // frame running
+
+	// builtin methods of the optimized operators, and whether they are
+	// not redefined at the method serial
+	serial     uint64
+	intOrig    [numOpcodes]*object.Method
+	floatOrig  [numOpcodes]*object.Method
+	arefOrig   *object.Method
+	intBasic   [numOpcodes]bool
+	floatBasic [numOpcodes]bool
+	arefBasic  bool
+}
+
+// New returns a VM running the methods of the runtime.
+func New(rt *object.Runtime) *VM {
+	vm := &VM{rt: rt}
+	for name, op := range optOperators {
+		vm.intOrig[op] = rt.FindMethod(rt.Integer, object.Intern(name))
+		vm.floatOrig[op] = rt.FindMethod(rt.Float, object.Intern(name))
+	}
+	vm.arefOrig = rt.FindMethod(rt.Array, object.Intern("[]"))
+	vm.checkBasic()
+	rt.Invoker = vm
+	return vm
+}
+
+// Eval parses, compiles and runs the source.
+func (vm *VM) Eval(filename string, src []byte) (object.Value, error) {
+	f, err := parser.ParseFile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	return vm.Run(f, src)
+}
+
+// Run compiles and runs the file parsed from the source. The error is an
+// *object.Error for the uncaught exception.
+func (vm *VM) Run(f *ast.File, src []byte) (object.Value, error) {
+	return vm.RunISeq(Compile(f, src))
+}
+
+// RunISeq runs the instruction sequence of the top level.
+func (vm *VM) RunISeq(iseq *ISeq) (v object.Value, err error) {
+	fr := vm.newFrame(iseq, vm.rt.Main, &scope{class: vm.rt.Object})
+	fr.visibility = object.Private
+	prev := vm.cur
+	vm.cur = fr
+	defer func() {
+		vm.cur = prev
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *object.Error:
+				v, err = nil, r
+				return
+			case *returnJump:
+				// return in the procs at the top level
+				if r.frame == fr {
+					v, err = r.val, nil
+					return
+				}
+			}
+			panic(r)
+		}
+	}()
+	return vm.exec(fr), nil
+}
+
+// frame is the context of a method call, a block call, a class body, or
+// the top level.
+type frame struct {
+	iseq       *ISeq
+	self       object.Value
+	locals     []object.Value
+	stack      []object.Value
+	sp         int
+	pc         int
+	outer      *frame         // frame where the block is defined, nil outside blocks
+	scope      *scope         // lexical scope for constants and definitions
+	method     *object.Method // nil outside methods
+	visibility object.Visibility
+	blk        object.Value  // block given to the method, nil if not given
+	proc       *object.RProc // proc of the block running, nil outside blocks
+	done       bool          // whether the method or the block has returned
+	jump       interface{}   // return or break run through the ensure clause
+}
+
+// env returns the frame of the level-th outer scope.
+func (fr *frame) env(level int) *frame {
+	for ; level > 0; level-- {
+		fr = fr.outer
+	}
+	return fr
+}
+
+// returnFrame returns the frame which return leaves, that is the frame of
+// the method where the block is defined, or the frame of the lambda.
+func (fr *frame) returnFrame() *frame {
+	for fr.outer != nil && !fr.proc.Lambda {
+		fr = fr.outer
+	}
+	return fr
+}
+
+// scope is the lexical scope of class and module definitions.
+type scope struct {
+	class  *object.RClass
+	parent *scope
+}
+
+// method is the body of the method compiled by the VM.
+type method struct {
+	iseq  *ISeq
+	scope *scope
+}
+
+// block is the body of the proc of a block literal.
+type block struct {
+	iseq  *ISeq
+	outer *frame
+	done  bool // whether the method call given the block has returned
+}
+
+type (
+	// returnJump is panicked by return in the block, which returns from
+	// the method where the block is defined.
+	returnJump struct {
+		val   object.Value
+		frame *frame
+	}
+
+	// breakJump is panicked by break in the block, which returns from the
+	// method call given the block.
+	breakJump struct {
+		val  object.Value
+		proc *object.RProc
+	}
+)
+
+func (vm *VM) newFrame(iseq *ISeq, self object.Value, s *scope) *frame {
+	n := len(iseq.Locals)
+	buf := make([]object.Value, n+iseq.StackMax)
+	for i := 0; i < n; i++ {
+		buf[i] = object.Nil
+	}
+	return &frame{iseq: iseq, self: self, locals: buf[:n:n], stack: buf[n:], scope: s}
+}
+
+// Invoke runs the method compiled by the VM.
+func (vm *VM) Invoke(m *object.Method, self object.Value, args []object.Value, blk object.Value) object.Value {
+	return vm.invoke(m, m.Body.(*method), self, args, blk)
+}
+
+func (vm *VM) invoke(m *object.Method, body *method, self object.Value, args []object.Value, blk object.Value) (ret object.Value) {
+	rt := vm.rt
+	if body.iseq.Unsupported != "" {
+		rt.NotImplemented(body.iseq.Unsupported)
+	}
+	if vm.depth >= maxDepth {
+		rt.Raise(rt.SystemStackError, "stack level too deep")
+	}
+	vm.depth++
+	fr := vm.newFrame(body.iseq, self, body.scope)
+	fr.method = m
+	fr.blk = blk
+	prev := vm.cur
+	vm.cur = fr
+	defer func() {
+		vm.depth--
+		vm.cur = prev
+		fr.done = true
+		if r := recover(); r != nil {
+			if j, ok := r.(*returnJump); ok && j.frame == fr {
+				ret = j.val
+				return
+			}
+			panic(r)
+		}
+	}()
+	fr.pc = vm.bindParams(fr, args, blk)
+	return vm.exec(fr)
+}
+
+// newProc returns the proc of the block literal in the frame.
+func (vm *VM) newProc(fr *frame, iseq *ISeq) *object.RProc {
+	return &object.RProc{
+		Body:    &block{iseq: iseq, outer: fr},
+		Params:  iseq.Params,
+		Literal: true,
+		Desc:    fmt.Sprintf(" %s:%d", iseq.File, iseq.Line),
+	}
+}
+
+// withBlock calls f with the proc of the block literal, and returns the
+// value of break in the block instead if it is broken.
+func (vm *VM) withBlock(fr *frame, iseq *ISeq, f func(blk object.Value) object.Value) (v object.Value) {
+	p := vm.newProc(fr, iseq)
+	defer func() {
+		p.Body.(*block).done = true
+		if r := recover(); r != nil {
+			if j, ok := r.(*breakJump); ok && j.proc == p {
+				v = j.val
+				return
+			}
+			panic(r)
+		}
+	}()
+	return f(p)
+}
+
+// CallBlock runs the block of the proc. The arguments of procs are
+// adjusted to the parameters, while lambdas check the number of them.
+func (vm *VM) CallBlock(p *object.RProc, args []object.Value, blk object.Value) object.Value {
+	outer := p.Body.(*block).outer
+	return vm.callBlock(p, outer.self, outer.scope, outer.visibility, args, blk)
+}
+
+// ClassExec runs the block of the proc as the body of the class, where
+// self is the class and the methods are defined in the class.
+func (vm *VM) ClassExec(p *object.RProc, c *object.RClass) object.Value {
+	outer := p.Body.(*block).outer
+	return vm.callBlock(p, c, &scope{class: c, parent: outer.scope}, object.Public, []object.Value{c}, nil)
+}
+
+// callBlock runs the block with self in the scope.
+func (vm *VM) callBlock(p *object.RProc, self object.Value, s *scope, visibility object.Visibility, args []object.Value, blk object.Value) (ret object.Value) {
+	rt := vm.rt
+	b := p.Body.(*block)
+	outer := b.outer
+	if b.iseq.Unsupported != "" {
+		rt.NotImplemented(b.iseq.Unsupported)
+	}
+	if vm.depth >= maxDepth {
+		rt.Raise(rt.SystemStackError, "stack level too deep")
+	}
+	vm.depth++
+	fr := vm.newFrame(b.iseq, self, s)
+	fr.outer = outer
+	fr.method = outer.method
+	fr.visibility = visibility
+	fr.blk = outer.blk
+	fr.proc = p
+	prev := vm.cur
+	vm.cur = fr
+	defer func() {
+		vm.depth--
+		vm.cur = prev
+		fr.done = true
+		if r := recover(); r != nil {
+			if j, ok := r.(*returnJump); ok && j.frame == fr {
+				ret = j.val
+				return
+			}
+			panic(r)
+		}
+	}()
+	if !p.Lambda {
+		args = procArgs(b.iseq, args)
+	}
+	fr.pc = vm.bindParams(fr, args, blk)
+	return vm.exec(fr)
+}
+
+// BlockGiven reports whether the block is given to the method running.
+func (vm *VM) BlockGiven() bool {
+	return vm.cur != nil && vm.cur.blk != nil
+}
+
+// procArgs adjusts the arguments of the proc to the parameters. An array
+// given alone is expanded for the parameters except for |a|, and the
+// missing arguments are nil, while the extra ones are dropped. The keyword
+// arguments are kept at the end for the keyword parameters.
+func procArgs(iseq *ISeq, args []object.Value) []object.Value {
+	req, opt := iseq.Lead+iseq.Post, 0
+	if len(iseq.OptTable) > 0 {
+		opt = len(iseq.OptTable) - 1
+	}
+	rest := iseq.Rest >= 0
+	single := req == 1 && opt == 0 && !rest
+	if len(args) == 1 && req+opt > 0 && !single {
+		if a, ok := args[0].(*object.RArray); ok {
+			args = a.Elems
+		}
+	}
+	var kwargs object.Value
+	if iseq.keywords() && len(args) > req {
+		if h, ok := args[len(args)-1].(*object.RHash); ok {
+			kwargs, args = h, args[:len(args)-1]
+		}
+	}
+	switch {
+	case len(args) < req:
+		args = append([]object.Value(nil), args...)
+		for len(args) < req {
+			args = append(args, object.Nil)
+		}
+	case !rest && len(args) > req+opt:
+		args = args[:req+opt]
+	}
+	if kwargs != nil {
+		args = append(args[:len(args):len(args)], kwargs)
+	}
+	return args
+}
+
+// bindParams assigns the arguments and the block to the parameters, and
+// returns the position to start, which skips the default values of the
+// optional parameters given.
+func (vm *VM) bindParams(fr *frame, args []object.Value, blk object.Value) int {
+	iseq := fr.iseq
+	lead, post, opt := iseq.Lead, iseq.Post, 0
+	if len(iseq.OptTable) > 0 {
+		opt = len(iseq.OptTable) - 1
+	}
+	var kws *object.Keywords
+	if iseq.keywords() {
+		args, kws = vm.rt.SplitKeywords(args, lead+post)
+	}
+	rest := iseq.Rest >= 0
+	n := len(args)
+	if n < lead+post || !rest && n > lead+post+opt {
+		var expected string
+		switch {
+		case rest:
+			expected = fmt.Sprintf("%d+", lead+post)
+		case opt > 0:
+			expected = fmt.Sprintf("%d..%d", lead+post, lead+post+opt)
+		default:
+			expected = fmt.Sprint(lead + post)
+		}
+		vm.rt.Raise(vm.rt.ArgumentError, "wrong number of arguments (given %d, expected %s)", n, expected)
+	}
+
+	// The optional parameters take the arguments left by the required ones
+	// from the beginning, and the rest parameter takes the remainder.
+	l := fr.locals
+	copy(l, args[:lead])
+	k := n - lead - post
+	if k > opt {
+		k = opt
+	}
+	copy(l[lead:], args[lead:lead+k])
+	i := lead + k
+	postStart := lead + opt
+	if rest {
+		r := n - post - i
+		l[iseq.Rest] = vm.rt.NewArray(append([]object.Value(nil), args[i:i+r]...))
+		i += r
+		postStart++
+	}
+	copy(l[postStart:], args[i:i+post])
+	if kws != nil {
+		vm.bindKeywords(fr, kws)
+	}
+	if iseq.Block >= 0 {
+		if blk == nil {
+			blk = object.Nil
+		}
+		l[iseq.Block] = blk
+	}
+	if opt > 0 {
+		return iseq.OptTable[k]
+	}
+	return 0
+}
+
+// bindKeywords assigns the keyword arguments to the keyword parameters.
+// The optional keywords not given are left to the default values, which
+// are evaluated by CheckKeyword with the bits of the keywords given.
+func (vm *VM) bindKeywords(fr *frame, kws *object.Keywords) {
+	iseq := fr.iseq
+	var bits object.Fixnum
+	for i, k := range iseq.Keywords {
+		v, ok := kws.Take(k.ID)
+		switch {
+		case ok:
+			bits |= 1 << uint(i)
+		case k.Required:
+			kws.Missing(k.ID)
+		}
+		fr.locals[k.Index] = v
+	}
+	if iseq.KeyRest >= 0 {
+		fr.locals[iseq.KeyRest] = kws.Rest()
+	}
+	if iseq.KeyBits >= 0 {
+		fr.locals[iseq.KeyBits] = bits
+	}
+	kws.Check()
+}
+
+// exec runs the frame, and returns the value of leave.
+func (vm *VM) exec(fr *frame) object.Value {
+	for {
+		if v, ok := vm.run(fr); ok {
+			return v
+		}
+	}
+}
+
+// run runs the instructions until leave. It returns false if an exception
+// is caught, which continues from the handler.
+func (vm *VM) run(fr *frame) (ret object.Value, done bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !vm.handle(fr, r) {
+				panic(r)
+			}
+		}
+	}()
+
+	rt := vm.rt
+	iseq := fr.iseq
+	insns := iseq.Insns
+	st := fr.stack
+	sp := fr.sp
+	for {
+		in := &insns[fr.pc]
+		fr.pc++
+		switch in.Op {
+		case Nop:
+		case Pop:
+			sp--
+		case Dup:
+			st[sp] = st[sp-1]
+			sp++
+		case DupN:
+			copy(st[sp:], st[sp-in.A:sp])
+			sp += in.A
+		case Swap:
+			st[sp-1], st[sp-2] = st[sp-2], st[sp-1]
+		case TopN:
+			st[sp] = st[sp-1-in.A]
+			sp++
+		case SetN:
+			st[sp-1-in.A] = st[sp-1]
+		case AdjustStack:
+			sp -= in.A
+
+		case PutNil:
+			st[sp] = object.Nil
+			sp++
+		case PutSelf:
+			st[sp] = fr.self
+			sp++
+		case PutObject:
+			st[sp] = iseq.Consts[in.A]
+			sp++
+		case PutString:
+			s := iseq.Consts[in.A].(*object.RString)
+			st[sp] = &object.RString{B: append([]byte(nil), s.B...), Enc: s.Enc}
+			sp++
+		case PutSpecialObject:
+			switch in.A {
+			case specialObject:
+				st[sp] = rt.Object
+			case specialCBase:
+				st[sp] = fr.scope.class
+			case specialEncoding:
+				st[sp] = rt.EncodingObject(object.UTF8)
+			}
+			sp++
+		case ConcatStrings:
+			var buf bytes.Buffer
+			for _, v := range st[sp-in.A : sp] {
+				buf.Write(v.(*object.RString).B)
+			}
+			sp -= in.A
+			st[sp] = &object.RString{B: buf.Bytes()}
+			sp++
+		case ToString:
+			st[sp-1] = rt.NewString(rt.ToS(st[sp-1]))
+		case Intern:
+			st[sp-1] = object.SymbolOf(string(st[sp-1].(*object.RString).B))
+		case NewArray:
+			elems := append([]object.Value(nil), st[sp-in.A:sp]...)
+			sp -= in.A
+			st[sp] = rt.NewArray(elems)
+			sp++
+		case SplatArray:
+			st[sp-1] = rt.NewArray(vm.splat(st[sp-1]))
+		case ConcatArray:
+			a, b := st[sp-2].(*object.RArray), st[sp-1].(*object.RArray)
+			elems := make([]object.Value, 0, len(a.Elems)+len(b.Elems))
+			st[sp-2] = rt.NewArray(append(append(elems, a.Elems...), b.Elems...))
+			sp--
+		case ExpandArray:
+			sp--
+			sp = vm.expandArray(st, sp, st[sp], in.A, in.B == 1, in.C)
+		case NewHash:
+			h := rt.NewHash()
+			for i := sp - in.A; i < sp; i += 2 {
+				rt.HashSet(h, st[i], st[i+1])
+			}
+			sp -= in.A
+			st[sp] = h
+			sp++
+		case MergeHash:
+			sp--
+			rt.MergeHash(st[sp-1].(*object.RHash), st[sp])
+		case NewRange:
+			sp--
+			st[sp-1] = rt.NewRange(st[sp-1], st[sp], in.A == 1)
+
+		case GetLocal:
+			if in.B == 0 {
+				st[sp] = fr.locals[in.A]
+			} else {
+				st[sp] = fr.env(in.B).locals[in.A]
+			}
+			sp++
+		case SetLocal:
+			sp--
+			if in.B == 0 {
+				fr.locals[in.A] = st[sp]
+			} else {
+				fr.env(in.B).locals[in.A] = st[sp]
+			}
+		case GetInstanceVariable:
+			st[sp] = rt.Ivar(fr.self, iseq.IDs[in.A])
+			sp++
+		case SetInstanceVariable:
+			sp--
+			rt.SetIvar(fr.self, iseq.IDs[in.A], st[sp])
+		case GetGlobal:
+			v, ok := rt.Globals[iseq.Names[in.A]]
+			if !ok {
+				v = object.Nil
+			}
+			st[sp] = v
+			sp++
+		case SetGlobal:
+			sp--
+			rt.Globals[iseq.Names[in.A]] = st[sp]
+		case GetConstant:
+			name := iseq.Names[in.A]
+			if in.B == 0 {
+				st[sp] = vm.constGet(fr, name)
+				sp++
+				break
+			}
+			c := vm.toClass(st[sp-1])
+			v, ok := rt.ConstGet(c, name)
+			if !ok {
+				if in.C == 0 {
+					rt.ConstMissing(c, name)
+				}
+				v = object.Nil
+			}
+			st[sp-1] = v
+		case SetConstant:
+			rt.ConstSet(vm.toClass(st[sp-1]), iseq.Names[in.A], st[sp-2])
+			sp -= 2
+		case GetClassVariable:
+			st[sp] = rt.CvarGet(vm.cvarBase(fr), iseq.Names[in.A])
+			sp++
+		case SetClassVariable:
+			sp--
+			rt.CvarSet(vm.cvarBase(fr), iseq.Names[in.A], st[sp])
+
+		case DefineMethod:
+			vm.defineMethod(fr, iseq.Names[in.A], iseq.Children[in.B])
+			st[sp] = object.Symbol(iseq.IDs[in.A])
+			sp++
+		case DefineSMethod:
+			vm.defineSMethod(fr, iseq.Names[in.A], iseq.Children[in.B], st[sp-1])
+			st[sp-1] = object.Symbol(iseq.IDs[in.A])
+		case DefineClass:
+			sp -= 2
+			st[sp] = vm.defineClass(fr, iseq.Names[in.A], iseq.Children[in.B], in.C, st[sp], st[sp+1])
+			sp++
+		case Alias:
+			rt.AliasMethod(fr.scope.class, iseq.IDs[in.A], iseq.IDs[in.B])
+			st[sp] = object.Nil
+			sp++
+		case Undef:
+			rt.UndefMethod(fr.scope.class, iseq.IDs[in.A])
+		case SetVisibility:
+			fr.visibility = object.Visibility(in.A)
+			st[sp] = object.Nil
+			sp++
+
+		case Send:
+			ci := iseq.Calls[in.A]
+			args, blk, n := vm.callArgs(ci, st, sp)
+			recv := st[sp-n-1]
+			var v object.Value
+			if ci.Block != nil {
+				v = vm.withBlock(fr, ci.Block, func(blk object.Value) object.Value {
+					return vm.send(fr, ci, recv, args, blk)
+				})
+			} else {
+				v = vm.send(fr, ci, recv, args, blk)
+			}
+			sp -= n
+			st[sp-1] = v
+		case InvokeSuper:
+			ci := iseq.Calls[in.A]
+			args, blk, n := vm.callArgs(ci, st, sp)
+			args = append([]object.Value(nil), args...)
+			if fr.method == nil {
+				rt.Raise(rt.RuntimeError, "super called outside of method")
+			}
+			if ci.Flags&callBlockArg == 0 {
+				blk = fr.blk
+			}
+			recv := st[sp-n-1]
+			var v object.Value
+			if ci.Block != nil {
+				v = vm.withBlock(fr, ci.Block, func(blk object.Value) object.Value {
+					return rt.CallSuper(recv, fr.method, args, blk)
+				})
+			} else {
+				v = rt.CallSuper(recv, fr.method, args, blk)
+			}
+			sp -= n
+			st[sp-1] = v
+		case InvokeBlock:
+			args, _, n := vm.callArgs(iseq.Calls[in.A], st, sp)
+			v := rt.Yield(fr.blk, args...)
+			sp -= n
+			st[sp] = v
+			sp++
+		case OptPlus, OptMinus, OptMult, OptDiv, OptMod, OptEq, OptLt, OptLe, OptGt, OptGe:
+			v, ok := vm.optimize(in.Op, st[sp-2], st[sp-1])
+			if !ok {
+				v = vm.send(fr, iseq.Calls[in.A], st[sp-2], st[sp-1:sp], nil)
+			}
+			sp--
+			st[sp-1] = v
+		case OptAref:
+			v, ok := vm.optAref(st[sp-2], st[sp-1])
+			if !ok {
+				v = vm.send(fr, iseq.Calls[in.A], st[sp-2], st[sp-1:sp], nil)
+			}
+			sp--
+			st[sp-1] = v
+
+		case Jump:
+			fr.pc = in.A
+		case BranchIf:
+			sp--
+			if object.Truthy(st[sp]) {
+				fr.pc = in.A
+			}
+		case BranchUnless:
+			sp--
+			if !object.Truthy(st[sp]) {
+				fr.pc = in.A
+			}
+		case CheckMatch:
+			switch in.A {
+			case matchCase, matchRescue:
+				sp--
+				st[sp-1] = object.Bool(vm.checkMatch(in.A, st[sp-1], st[sp]))
+			default:
+				st[sp-1] = object.Bool(vm.checkMatch(in.A, st[sp-1], nil))
+			}
+		case CheckKeyword:
+			bits := fr.locals[in.A].(object.Fixnum)
+			st[sp] = object.Bool(bits>>uint(in.B)&1 != 0)
+			sp++
+		case Defined:
+			if in.A == definedPublic {
+				st[sp-1] = vm.defined(fr, in.A, iseq.Names[in.B], st[sp-1])
+				break
+			}
+			st[sp] = vm.defined(fr, in.A, iseq.Names[in.B], nil)
+			sp++
+		case Raise:
+			sp--
+			if st[sp] == object.Nil && fr.jump != nil {
+				// resume the jump after the ensure clause
+				j := fr.jump
+				fr.jump = nil
+				panic(j)
+			}
+			panic(&object.Error{Exception: st[sp].(*object.RObject)})
+		case Throw:
+			switch {
+			case in.A == throwReturn:
+				if to := fr.returnFrame(); to == fr {
+					return st[sp-1], true
+				} else if to.done || to.iseq.Type == ClassISeq {
+					rt.Raise(rt.LocalJumpError, "unexpected return")
+				} else {
+					panic(&returnJump{val: st[sp-1], frame: to})
+				}
+			case in.A == throwBreak && fr.proc != nil:
+				if fr.proc.Lambda {
+					return st[sp-1], true
+				}
+				if fr.proc.Body.(*block).done {
+					rt.Raise(rt.LocalJumpError, "break from proc-closure")
+				}
+				panic(&breakJump{val: st[sp-1], proc: fr.proc})
+			case in.A == throwRetry:
+				rt.Raise(rt.LocalJumpError, "retry outside of rescue clause")
+			}
+			rt.Raise(rt.LocalJumpError, "unexpected %s", throwNames[in.A])
+		case Unsupported:
+			rt.NotImplemented(iseq.Names[in.A])
+		case Leave:
+			return st[sp-1], true
+		default:
+			panic(fmt.Sprintf("vm: unknown instruction %v", in.Op))
+		}
+	}
+}
+
+// handle finds the handler of the exception raised by the instruction
+// just run, and prepares the frame to continue from the handler. The
+// return and break out of the blocks, and the builtin iterations stopped
+// early run only the ensure clauses, which are given nil and resume the
+// jump.
+func (vm *VM) handle(fr *frame, r interface{}) bool {
+	var exc object.Value
+	switch r := r.(type) {
+	case *object.Error:
+		exc = r.Exception
+	case *returnJump, *breakJump, *object.BreakIteration:
+		exc = object.Nil
+	default:
+		return false
+	}
+	pc := fr.pc - 1
+	for _, e := range fr.iseq.Catch {
+		if pc < e.Start || e.End <= pc {
+			continue
+		}
+		if exc == object.Nil && e.Kind != catchEnsure {
+			continue
+		}
+		if e.Kind == catchStandard && !vm.rt.IsKindOf(exc, vm.rt.StandardError) {
+			continue
+		}
+		if exc == object.Nil {
+			fr.jump = r
+		}
+		fr.stack[e.SP] = exc
+		fr.sp = e.SP + 1
+		fr.pc = e.Handler
+		return true
+	}
+	return false
+}
+
+// callArgs returns the arguments and the block passed by & of the call,
+// and the number of the values on the stack for them.
+func (vm *VM) callArgs(ci *CallInfo, st []object.Value, sp int) (args []object.Value, blk object.Value, n int) {
+	n = ci.popped()
+	if ci.Flags&callBlockArg != 0 {
+		sp--
+		blk = vm.rt.BlockArg(st[sp])
+	}
+	if ci.Flags&callSplat != 0 {
+		args = st[sp-1].(*object.RArray).Elems
+	} else {
+		args = st[sp-ci.Argc : sp]
+	}
+	return args, blk, n
+}
+
+// send calls the method from the frame, caching the method found for the
+// class of the receiver.
+func (vm *VM) send(fr *frame, ci *CallInfo, recv object.Value, args []object.Value, blk object.Value) object.Value {
+	rt := vm.rt
+	c := rt.ClassOf(recv)
+	serial := rt.MethodSerial()
+	m := ci.method
+	if ci.class != c || ci.serial != serial {
+		m = rt.FindMethod(c, ci.Name)
+		ci.class, ci.serial, ci.method = c, serial, m
+	}
+	if m == nil {
+		rt.RaiseNoMethod(recv, ci.Name, ci.Flags&callVCall != 0)
+	}
+	if ci.Flags&callFCall == 0 && m.Visibility != object.Public {
+		rt.CheckVisibility(m, recv, ci.Name, fr.self)
+	}
+	if body, ok := m.Body.(*method); ok {
+		return vm.invoke(m, body, recv, args, blk)
+	}
+	return rt.CallMethod(m, recv, args, blk)
+}
+
+// checkBasic checks whether the optimized operators are redefined.
+func (vm *VM) checkBasic() {
+	rt := vm.rt
+	for name, op := range optOperators {
+		vm.intBasic[op] = vm.intOrig[op] != nil && rt.FindMethod(rt.Integer, object.Intern(name)) == vm.intOrig[op]
+		vm.floatBasic[op] = vm.floatOrig[op] != nil && rt.FindMethod(rt.Float, object.Intern(name)) == vm.floatOrig[op]
+	}
+	vm.arefBasic = rt.FindMethod(rt.Array, object.Intern("[]")) == vm.arefOrig
+	vm.serial = rt.MethodSerial()
+}
+
+// optimize runs the operator of Integers or Floats without the method
+// call. It returns false if the operands are not supported, or the result
+// overflows.
+func (vm *VM) optimize(op Opcode, a, b object.Value) (object.Value, bool) {
+	if vm.serial != vm.rt.MethodSerial() {
+		vm.checkBasic()
+	}
+	switch x := a.(type) {
+	case object.Fixnum:
+		y, ok := b.(object.Fixnum)
+		if !ok || !vm.intBasic[op] {
+			return nil, false
+		}
+		switch op {
+		case OptPlus:
+			if r := x + y; (r > x) == (y > 0) {
+				return r, true
+			}
+		case OptMinus:
+			if r := x - y; (r < x) == (y > 0) {
+				return r, true
+			}
+		case OptMult:
+			if x == 0 || y == 0 {
+				return object.Fixnum(0), true
+			}
+			if x == -1 && y == math.MinInt64 || y == -1 && x == math.MinInt64 {
+				return nil, false
+			}
+			if r := x * y; r/y == x {
+				return r, true
+			}
+		case OptDiv:
+			if y == 0 || x == math.MinInt64 && y == -1 {
+				return nil, false
+			}
+			q := x / y
+			if x%y != 0 && (x < 0) != (y < 0) {
+				q--
+			}
+			return q, true
+		case OptMod:
+			if y == 0 {
+				return nil, false
+			}
+			m := x % y
+			if m != 0 && (m < 0) != (y < 0) {
+				m += y
+			}
+			return m, true
+		case OptEq:
+			return object.Bool(x == y), true
+		case OptLt:
+			return object.Bool(x < y), true
+		case OptLe:
+			return object.Bool(x <= y), true
+		case OptGt:
+			return object.Bool(x > y), true
+		case OptGe:
+			return object.Bool(x >= y), true
+		}
+	case object.Float:
+		y, ok := b.(object.Float)
+		if !ok || !vm.floatBasic[op] {
+			return nil, false
+		}
+		switch op {
+		case OptPlus:
+			return x + y, true
+		case OptMinus:
+			return x - y, true
+		case OptMult:
+			return x * y, true
+		case OptDiv:
+			return x / y, true
+		case OptEq:
+			return object.Bool(x == y), true
+		case OptLt:
+			return object.Bool(x < y), true
+		case OptLe:
+			return object.Bool(x <= y), true
+		case OptGt:
+			return object.Bool(x > y), true
+		case OptGe:
+			return object.Bool(x >= y), true
+		}
+	}
+	return nil, false
+}
+
+// optAref returns the element of the array at the Integer index without
+// the method call.
+func (vm *VM) optAref(a, b object.Value) (object.Value, bool) {
+	if vm.serial != vm.rt.MethodSerial() {
+		vm.checkBasic()
+	}
+	arr, ok := a.(*object.RArray)
+	i, ok2 := b.(object.Fixnum)
+	if !ok || !ok2 || !vm.arefBasic || vm.rt.ClassOf(arr) != vm.rt.Array {
+		return nil, false
+	}
+	if i < 0 {
+		i += object.Fixnum(len(arr.Elems))
+	}
+	if i < 0 || int64(i) >= int64(len(arr.Elems)) {
+		return object.Nil, true
+	}
+	return arr.Elems[i], true
+}
+
+// splat returns the new slice of the elements of the value expanded by *.
+func (vm *VM) splat(v object.Value) []object.Value {
+	rt := vm.rt
+	switch v := v.(type) {
+	case *object.RArray:
+		return append([]object.Value(nil), v.Elems...)
+	}
+	if v == object.Nil {
+		return nil
+	}
+	if rt.RespondTo(v, "to_a") {
+		if a, ok := rt.Send(v, "to_a").(*object.RArray); ok {
+			return append([]object.Value(nil), a.Elems...)
+		}
+	}
+	return []object.Value{v}
+}
+
+// expandArray pushes the elements of the value for the multiple
+// assignment, so that the first one is on the top. The value which is not
+// an array is expanded as an array of the value.
+func (vm *VM) expandArray(st []object.Value, sp int, v object.Value, pre int, splat bool, post int) int {
+	var elems []object.Value
+	if a, ok := v.(*object.RArray); ok {
+		elems = a.Elems
+	} else {
+		elems = []object.Value{v}
+	}
+	at := func(i int) object.Value {
+		if i < len(elems) {
+			return elems[i]
+		}
+		return object.Nil
+	}
+	if splat {
+		n := len(elems) - pre - post
+		if n < 0 {
+			n = 0
+		}
+		for i := post - 1; i >= 0; i-- {
+			st[sp] = at(pre + n + i)
+			sp++
+		}
+		var rest []object.Value
+		if n > 0 {
+			rest = append(rest, elems[pre:pre+n]...)
+		}
+		st[sp] = vm.rt.NewArray(rest)
+		sp++
+	}
+	for i := pre - 1; i >= 0; i-- {
+		st[sp] = at(i)
+		sp++
+	}
+	return sp
+}
+
+func (vm *VM) toClass(v object.Value) *object.RClass {
+	c, ok := v.(*object.RClass)
+	if !ok {
+		vm.rt.Raise(vm.rt.TypeError, "%s is not a class/module", vm.rt.Inspect(v))
+	}
+	return c
+}
+
+// constGet returns the constant without scope, which is looked up in the
+// lexical scopes, and then the ancestors of the current class.
+func (vm *VM) constGet(fr *frame, name string) object.Value {
+	rt := vm.rt
+	for s := fr.scope; s.parent != nil; s = s.parent {
+		if v, ok := s.class.Consts[name]; ok {
+			return v
+		}
+	}
+	c := fr.scope.class
+	if v, ok := rt.ConstGet(c, name); ok {
+		return v
+	}
+	if v, ok := rt.Object.Consts[name]; ok {
+		return v
+	}
+	rt.ConstMissing(c, name)
+	return nil
+}
+
+// defineMethod defines the method in the current class.
+func (vm *VM) defineMethod(fr *frame, name string, iseq *ISeq) {
+	m := &object.Method{
+		Name:       object.Intern(name),
+		Visibility: fr.visibility,
+		Arity:      iseq.arity(),
+		Body:       &method{iseq: iseq, scope: fr.scope},
+	}
+	if fr.method != nil {
+		m.Visibility = object.Public
+	}
+	switch name {
+	case "initialize", "initialize_copy", "respond_to_missing?":
+		m.Visibility = object.Private
+	}
+	vm.rt.AddMethod(fr.scope.class, m)
+}
+
+// defineSMethod defines the singleton method of the object.
+func (vm *VM) defineSMethod(fr *frame, name string, iseq *ISeq, obj object.Value) {
+	m := &object.Method{
+		Name:  object.Intern(name),
+		Arity: iseq.arity(),
+		Body:  &method{iseq: iseq, scope: fr.scope},
+	}
+	vm.rt.AddMethod(vm.rt.SingletonClassOf(obj), m)
+}
+
+// cvarBase returns the class to look up the class variables, which is the
+// class of the lexical scope.
+func (vm *VM) cvarBase(fr *frame) *object.RClass {
+	if fr.scope.parent == nil {
+		vm.rt.Raise(vm.rt.RuntimeError, "class variable access from toplevel")
+	}
+	return fr.scope.class
+}
+
+// defineClass defines the class, the module, or opens the singleton class
+// of the object given as cbase, and runs the body where self is the class.
+func (vm *VM) defineClass(fr *frame, name string, body *ISeq, typ int, cbase, super object.Value) object.Value {
+	rt := vm.rt
+	var c *object.RClass
+	switch typ {
+	case typeSingleton:
+		c = rt.SingletonClassOf(cbase)
+	case typeModule:
+		c = rt.DefineModule(name, vm.toClass(cbase))
+	default:
+		namespace := vm.toClass(cbase)
+		var sc *object.RClass
+		if super != object.Nil {
+			k, ok := super.(*object.RClass)
+			if !ok || k.IsModule {
+				rt.Raise(rt.TypeError, "superclass must be a Class (%s given)", rt.ClassOf(super).RealClass().Name)
+			}
+			sc = k
+		}
+		c = rt.DefineClass(name, sc, namespace)
+	}
+	cfr := vm.newFrame(body, c, &scope{class: c, parent: fr.scope})
+	prev := vm.cur
+	vm.cur = cfr
+	defer func() { vm.cur = prev }()
+	return vm.exec(cfr)
+}
+
+// checkMatch returns the result of CheckMatch for the values popped.
+func (vm *VM) checkMatch(kind int, a, b object.Value) bool {
+	rt := vm.rt
+	switch kind {
+	case matchCase:
+		for _, v := range a.(*object.RArray).Elems {
+			if object.Truthy(rt.Call(v, object.Intern("==="), []object.Value{b}, nil, false)) {
+				return true
+			}
+		}
+	case matchRescue:
+		for _, c := range a.(*object.RArray).Elems {
+			if _, ok := c.(*object.RClass); !ok {
+				rt.Raise(rt.TypeError, "class or module required for rescue clause")
+			}
+			if object.Truthy(rt.Send(c, "===", b)) {
+				return true
+			}
+		}
+	case matchStandard:
+		return rt.IsKindOf(a, rt.StandardError)
+	case matchTruthy:
+		for _, v := range a.(*object.RArray).Elems {
+			if object.Truthy(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// defined returns the description for defined?, or nil if the name is not
+// defined.
+func (vm *VM) defined(fr *frame, kind int, name string, recv object.Value) (v object.Value) {
+	rt := vm.rt
+	desc := ""
+	switch kind {
+	case definedIvar:
+		if rt.IvarDefined(fr.self, object.Intern(name)) {
+			desc = "instance-variable"
+		}
+	case definedGlobal:
+		if _, ok := rt.Globals[name]; ok {
+			desc = "global-variable"
+		}
+	case definedConst:
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(*object.Error); !ok {
+					panic(r)
+				}
+				v = object.Nil
+			}
+		}()
+		vm.constGet(fr, name)
+		desc = "constant"
+	case definedMethod:
+		if rt.FindMethod(rt.ClassOf(fr.self), object.Intern(name)) != nil {
+			desc = "method"
+		}
+	case definedPublic:
+		if rt.RespondTo(recv, name) {
+			desc = "method"
+		}
+	case definedCvar:
+		if fr.scope.parent != nil && rt.CvarDefined(fr.scope.class, name) {
+			desc = "class variable"
+		}
+	case definedSuper:
+		if fr.method != nil && rt.FindSuperMethod(fr.self, fr.method) != nil {
+			desc = "super"
+		}
+	case definedYield:
+		if fr.blk != nil {
+			desc = "yield"
+		}
+	}
+	if desc == "" {
+		return object.Nil
+	}
+	return rt.NewString(desc)
+}
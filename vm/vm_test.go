@@ -0,0 +1,51 @@
+package vm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/harukasan/ringo/internal/evaltest"
+	"github.com/harukasan/ringo/object"
+	"github.com/harukasan/ringo/parser"
+)
+
+func run(src string) (string, error) {
+	rt := object.New()
+	var out bytes.Buffer
+	rt.Stdout = &out
+	_, err := New(rt).Eval("t.rb", []byte(src))
+	return out.String(), err
+}
+
+func TestEval(t *testing.T) {
+	evaltest.Outputs(t, run)
+}
+
+func TestDisasm(t *testing.T) {
+	src := "def f(a, b = 1) a + b end\np f(1)\ny = 1\n[1].each { |x| p x + y; yield }\n"
+	f, err := parser.ParseFile("t.rb", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Compile(f, []byte(src)).Disasm()
+	for _, want := range []string{
+		"== disasm: #<ISeq:<main>@t.rb:1>\n",
+		"0000 definemethod         :f, <ISeq:f>",
+		"0005 send                 <calldata!mid:f, argc:1, FCALL>",
+		"local table (size: 2, argc: 1 [opts: 1, rest: -1, post: 0])\n[ 2] a@0 [ 1] b@1\n",
+		"0004 opt_plus             <calldata!mid:+, argc:1>",
+		"send                 <calldata!mid:each, argc:0>, block in <main>",
+		"== disasm: #<ISeq:block in <main>@t.rb:4>\n",
+		"getlocal             y@0, 1",
+		"invokeblock          <calldata!argc:0>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Disasm()=%s (want=%q)", got, want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	evaltest.Errors(t, run)
+}